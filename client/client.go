@@ -11,10 +11,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"os"
 	"runtime"
 	"strings"
+	"sync"
 
 	"github.com/danos/configd/rpc"
 )
@@ -23,6 +25,36 @@ const DEFAULT_CONFIG_SOCKET = "/var/run/vyatta/configd/main.sock"
 
 var defaultOpts = map[string]interface{}{"Defaults": true, "Secrets": true}
 
+// Files larger than this are uploaded in chunks by Load
+const (
+	loadChunkThreshold = 1 << 20
+	loadChunkSize      = 512 << 10
+)
+
+// TreeOpts control how configuration is returned by TreeGet and Show.
+// ForceSecrets is only honoured for superusers and members of the
+// secrets group; other callers are denied access.
+type TreeOpts struct {
+	Defaults     bool
+	Secrets      bool
+	ForceSecrets bool
+	CouldExist   bool
+	Annotations  bool
+}
+
+func (o *TreeOpts) flags() map[string]interface{} {
+	if o == nil {
+		return defaultOpts
+	}
+	return map[string]interface{}{
+		"Defaults":     o.Defaults,
+		"Secrets":      o.Secrets,
+		"ForceSecrets": o.ForceSecrets,
+		"CouldExist":   o.CouldExist,
+		"Annotations":  o.Annotations,
+	}
+}
+
 //GetFuncName() returns the unqualified name of the caller
 func GetFuncName() string {
 	pc, _, _, ok := runtime.Caller(1)
@@ -91,11 +123,92 @@ func Connect(opts ...ConnectOption) (*Client, error) {
 }
 
 type Client struct {
-	conn net.Conn
-	sid  string
-	enc  *json.Encoder
-	dec  *json.Decoder
-	id   int
+	conn    net.Conn
+	sid     string
+	enc     *json.Encoder
+	dec     *json.Decoder
+	id      int
+	version int
+
+	// Serialises writing requests
+	sending sync.Mutex
+	// Guards id, pending and err
+	mu      sync.Mutex
+	pending map[int]*Call
+	err     error
+}
+
+// Call is a request sent by Go, whose response may not yet have arrived.
+type Call struct {
+	Method string
+	Result interface{}
+	Error  error
+	id     int
+	done   chan struct{}
+}
+
+// Done is closed once the call has completed.
+func (call *Call) Done() <-chan struct{} {
+	return call.done
+}
+
+// Wait returns the result of the call once it has completed.
+func (call *Call) Wait() (interface{}, error) {
+	<-call.done
+	return call.Result, call.Error
+}
+
+func (call *Call) Bool() (bool, error) {
+	i, err := call.Wait()
+	if err != nil {
+		return false, err
+	}
+	if v, ok := i.(bool); ok {
+		return v, nil
+	} else {
+		return false, fmt.Errorf("wrong return type for %s got %T expecting bool", call.Method, i)
+	}
+}
+
+func (call *Call) Int() (int, error) {
+	i, err := call.Wait()
+	if err != nil {
+		return -1, err
+	}
+	if v, ok := i.(float64); ok {
+		return int(v), nil
+	} else {
+		return -1, fmt.Errorf("wrong return type for %s got %T expecting float64", call.Method, i)
+	}
+}
+
+func (call *Call) String() (string, error) {
+	i, err := call.Wait()
+	if err != nil {
+		return "", err
+	}
+	if v, ok := i.(string); ok {
+		return v, nil
+	} else {
+		return "", fmt.Errorf("wrong return type for %s got %T expecting string", call.Method, i)
+	}
+}
+
+func (call *Call) finish(rep *rpc.Response, err error) {
+	defer close(call.done)
+	if err != nil {
+		call.Error = err
+		return
+	}
+	call.Result = rep.Result
+
+	// If we have an error, it may be a basic error (encoded as a string) or
+	// it may be a MgmtErrorList in which case it is stored as a map.
+	if str, ok := rep.Error.(string); ok {
+		call.Error = errors.New(str)
+	} else if len(rep.MgmtErrList.Errors()) != 0 {
+		call.Error = rep.MgmtErrList
+	}
 }
 
 func Dial(network, address, sid string) (*Client, error) {
@@ -105,16 +218,32 @@ func Dial(network, address, sid string) (*Client, error) {
 	}
 
 	client := &Client{
-		conn: c,
-		enc:  json.NewEncoder(c),
-		dec:  json.NewDecoder(c),
-		id:   0,
-		sid:  sid,
+		conn:    c,
+		enc:     json.NewEncoder(c),
+		dec:     json.NewDecoder(c),
+		id:      0,
+		sid:     sid,
+		pending: make(map[int]*Call),
 	}
+	go client.readResponses()
+	client.negotiateVersion()
 
 	return client, nil
 }
 
+// Daemons from before protocol versions were introduced don't know the
+// ProtocolVersion method, so any failure means we speak version 1.
+func (c *Client) negotiateVersion() {
+	c.version = rpc.ProtocolVersion1
+	if v, err := c.callInt("ProtocolVersion", rpc.ProtocolVersion); err == nil {
+		c.version = v
+	}
+}
+
+func (c *Client) ProtocolVersion() int {
+	return c.version
+}
+
 func (c *Client) Close() {
 	if c.conn == nil {
 		return
@@ -122,29 +251,67 @@ func (c *Client) Close() {
 	c.conn.Close()
 }
 
-func (c *Client) call(method string, args ...interface{}) (interface{}, error) {
-	var rep rpc.Response
-	c.id++
-	enc_err := c.enc.Encode(&rpc.Request{Method: method, Args: args, Id: c.id})
-	if enc_err != nil {
-		return nil, enc_err
-	}
-	dec_err := c.dec.Decode(&rep)
-	if dec_err != nil {
-		return nil, dec_err
+// readResponses completes each call as its response arrives.  Daemons
+// speaking rpc.ProtocolVersion3 or later may respond out of order.
+func (c *Client) readResponses() {
+	for {
+		var rep rpc.Response
+		if err := c.dec.Decode(&rep); err != nil {
+			c.mu.Lock()
+			c.err = err
+			pending := c.pending
+			c.pending = make(map[int]*Call)
+			c.mu.Unlock()
+			for _, call := range pending {
+				call.finish(nil, err)
+			}
+			return
+		}
+		c.mu.Lock()
+		call, ok := c.pending[rep.Id]
+		delete(c.pending, rep.Id)
+		c.mu.Unlock()
+		if ok {
+			call.finish(&rep, nil)
+		}
 	}
+}
 
-	// If we have an error, it may be a basic error (encoded as a string) or
-	// it may be a MgmtErrorList in which case it is stored as a map.
-	if err, ok := rep.Error.(string); ok {
-		return rep.Result, errors.New(err)
+// Go sends a request without waiting for its response, so several may be
+// in progress at once.  Daemons speaking rpc.ProtocolVersion3 or later run
+// reads concurrently; others handle requests in the order they are sent.
+func (c *Client) Go(method string, args ...interface{}) *Call {
+	call := &Call{Method: method, done: make(chan struct{})}
+	c.mu.Lock()
+	if c.err != nil {
+		err := c.err
+		c.mu.Unlock()
+		call.finish(nil, err)
+		return call
 	}
+	c.id++
+	id := c.id
+	call.id = id
+	c.pending[id] = call
+	c.mu.Unlock()
 
-	if len(rep.MgmtErrList.Errors()) != 0 {
-		return rep.Result, rep.MgmtErrList
+	c.sending.Lock()
+	err := c.enc.Encode(&rpc.Request{Method: method, Args: args, Id: id})
+	c.sending.Unlock()
+	if err != nil {
+		c.mu.Lock()
+		_, ok := c.pending[id]
+		delete(c.pending, id)
+		c.mu.Unlock()
+		if ok {
+			call.finish(nil, err)
+		}
 	}
+	return call
+}
 
-	return rep.Result, nil
+func (c *Client) call(method string, args ...interface{}) (interface{}, error) {
+	return c.Go(method, args...).Wait()
 }
 
 //Per JSON RPC spec we must return a value upon success. This is not idomatic for go,
@@ -162,39 +329,15 @@ func (c *Client) callBoolIgnore(method string, args ...interface{}) error {
 }
 
 func (c *Client) callBool(method string, args ...interface{}) (bool, error) {
-	i, err := c.call(method, args...)
-	if err != nil {
-		return false, err
-	}
-	if v, ok := i.(bool); ok {
-		return v, nil
-	} else {
-		return false, fmt.Errorf("wrong return type for %s got %T expecting bool", method, i)
-	}
+	return c.Go(method, args...).Bool()
 }
 
 func (c *Client) callInt(method string, args ...interface{}) (int, error) {
-	i, err := c.call(method, args...)
-	if err != nil {
-		return -1, err
-	}
-	if v, ok := i.(float64); ok {
-		return int(v), nil
-	} else {
-		return -1, fmt.Errorf("wrong return type for %s got %T expecting float64", method, i)
-	}
+	return c.Go(method, args...).Int()
 }
 
 func (c *Client) callString(method string, args ...interface{}) (string, error) {
-	i, err := c.call(method, args...)
-	if err != nil {
-		return "", err
-	}
-	if v, ok := i.(string); ok {
-		return v, nil
-	} else {
-		return "", fmt.Errorf("wrong return type for %s got %T expecting string", method, i)
-	}
+	return c.Go(method, args...).String()
 }
 
 func (c *Client) callMap(method string, args ...interface{}) (map[string]interface{}, error) {
@@ -229,6 +372,29 @@ func (c *Client) callMapString(method string, args ...interface{}) (map[string]s
 	}
 }
 
+func (c *Client) callMapMapString(method string, args ...interface{}) (map[string]map[string]string, error) {
+	v, err := c.callMap(method, args...)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]map[string]string)
+	for name, val := range v {
+		info, ok := val.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("wrong return type for %s got %T expecting map[string]interface{}", method, val)
+		}
+		out[name] = make(map[string]string)
+		for k, field := range info {
+			str, ok := field.(string)
+			if !ok {
+				return nil, fmt.Errorf("wrong return type for %s got %T expecting string", method, field)
+			}
+			out[name][k] = str
+		}
+	}
+	return out, nil
+}
+
 func (c *Client) callMapStruct(method string, args ...interface{}) (map[string]struct{}, error) {
 	v, err := c.callMap(method, args...)
 	if err != nil {
@@ -241,6 +407,45 @@ func (c *Client) callMapStruct(method string, args ...interface{}) (map[string]s
 	return out, nil
 }
 
+func (c *Client) callMapInt(method string, args ...interface{}) (map[string]int, error) {
+	v, err := c.callMap(method, args...)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]int)
+	for k, val := range v {
+		num, ok := val.(float64)
+		if !ok {
+			return nil, fmt.Errorf("wrong return type for %s got %T expecting float64", method, val)
+		}
+		out[k] = int(num)
+	}
+	return out, nil
+}
+
+func (c *Client) callMapCounters(method string, args ...interface{}) (map[string]map[string]uint64, error) {
+	v, err := c.callMap(method, args...)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]map[string]uint64)
+	for key, val := range v {
+		counters, ok := val.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("wrong return type for %s got %T expecting map[string]interface{}", method, val)
+		}
+		out[key] = make(map[string]uint64)
+		for name, counter := range counters {
+			num, ok := counter.(float64)
+			if !ok {
+				return nil, fmt.Errorf("wrong return type for %s got %T expecting float64", method, counter)
+			}
+			out[key][name] = uint64(num)
+		}
+	}
+	return out, nil
+}
+
 func (c *Client) callSlice(method string, args ...interface{}) ([]interface{}, error) {
 	i, err := c.call(method, args...)
 	if err != nil {
@@ -282,12 +487,18 @@ func (c *Client) SessionSetup() error {
 func (c *Client) SessionSetupShared() error {
 	return c.callBoolIgnore(GetFuncName(), c.sid)
 }
+func (c *Client) SessionSetupPrivate() error {
+	return c.callBoolIgnore(GetFuncName(), c.sid)
+}
 func (c *Client) SessionTeardown() error {
 	return c.callBoolIgnore(GetFuncName(), c.sid)
 }
 func (c *Client) SessionChanged() (bool, error) {
 	return c.callBool(GetFuncName(), c.sid)
 }
+func (c *Client) SessionBaseChanged() (bool, error) {
+	return c.callBool(GetFuncName(), c.sid)
+}
 func (c *Client) SessionSaved() (bool, error) {
 	return c.callBool(GetFuncName(), c.sid)
 }
@@ -300,6 +511,12 @@ func (c *Client) SessionMarkUnsaved() error {
 func (c *Client) SessionGetEnv() (map[string]interface{}, error) {
 	return c.callMap(GetFuncName(), c.sid)
 }
+func (c *Client) EditGetEnv() (map[string]string, error) {
+	return c.callMapString(GetFuncName(), c.sid)
+}
+func (c *Client) EditSetLevel(path string) error {
+	return c.callBoolIgnore(GetFuncName(), c.sid, path)
+}
 
 func (c *Client) TmplGet(path string) (map[string]string, error) {
 	return c.callMapString(GetFuncName(), path)
@@ -320,15 +537,47 @@ func (c *Client) TmplValidateValues(path string) (bool, error) {
 func (c *Client) Get(db rpc.DB, path string) ([]string, error) {
 	return c.callSliceString(GetFuncName(), db, c.sid, path)
 }
+func (c *Client) GetAsync(db rpc.DB, path string) *Call {
+	return c.Go("Get", db, c.sid, path)
+}
 func (c *Client) TreeGet(db rpc.DB, path, encoding string) (string, error) {
 	return c.callString(GetFuncName(), db, c.sid, path, encoding, defaultOpts)
 }
 func (c *Client) TreeGetFull(db rpc.DB, path, encoding string) (string, error) {
 	return c.callString(GetFuncName(), db, c.sid, path, encoding, defaultOpts)
 }
+func (c *Client) TreeGetAsync(db rpc.DB, path, encoding string) *Call {
+	return c.Go("TreeGet", db, c.sid, path, encoding, defaultOpts)
+}
+func (c *Client) TreeGetFullAsync(db rpc.DB, path, encoding string) *Call {
+	return c.Go("TreeGetFull", db, c.sid, path, encoding, defaultOpts)
+}
+func (c *Client) TreeGetWithOpts(db rpc.DB, path, encoding string, opts *TreeOpts) (string, error) {
+	return c.callString("TreeGet", db, c.sid, path, encoding, opts.flags())
+}
+func (c *Client) TreeGetFullWithOpts(db rpc.DB, path, encoding string, opts *TreeOpts) (string, error) {
+	return c.callString("TreeGetFull", db, c.sid, path, encoding, opts.flags())
+}
+func (c *Client) GetData(
+	datastore, path, encoding string,
+	withOrigin bool,
+) (string, error) {
+	return c.callString(GetFuncName(), c.sid, datastore, path, encoding,
+		withOrigin, defaultOpts)
+}
+func (c *Client) ExportList(
+	db rpc.DB,
+	path, format string,
+	flags map[string]interface{},
+) (string, error) {
+	return c.callString(GetFuncName(), db, c.sid, path, format, flags)
+}
 func (c *Client) Exists(db rpc.DB, path string) (bool, error) {
 	return c.callBool(GetFuncName(), db, c.sid, path)
 }
+func (c *Client) ExistsAsync(db rpc.DB, path string) *Call {
+	return c.Go("Exists", db, c.sid, path)
+}
 func (c *Client) NodeGetStatus(db rpc.DB, path string) (int, error) {
 	return c.callInt(GetFuncName(), db, c.sid, path)
 }
@@ -336,6 +585,9 @@ func (c *Client) NodeGetType(path string) (rpc.NodeType, error) {
 	nt, err := c.callInt(GetFuncName(), c.sid, path)
 	return rpc.NodeType(nt), err
 }
+func (c *Client) NodeGetCompleteEnv(path string) (map[string]int, error) {
+	return c.callMapInt(GetFuncName(), c.sid, path)
+}
 
 func (c *Client) Set(path string) (string, error) {
 	return c.callString(GetFuncName(), c.sid, path)
@@ -343,9 +595,25 @@ func (c *Client) Set(path string) (string, error) {
 func (c *Client) ValidatePath(path string) (string, error) {
 	return c.callString(GetFuncName(), c.sid, path)
 }
+func (c *Client) CheckConstraints(path string) error {
+	return c.callBoolIgnore(GetFuncName(), c.sid, path)
+}
 func (c *Client) Delete(path string) error {
 	return c.callBoolIgnore(GetFuncName(), c.sid, path)
 }
+func (c *Client) EditConfigJSON(
+	target, defop, testopt, erropt, encoding, config string,
+) (string, error) {
+	return c.callString(GetFuncName(), c.sid, target, defop, testopt,
+		erropt, encoding, config)
+}
+func (c *Client) SetBatch(ops []rpc.PathOp) error {
+	buf, err := json.Marshal(ops)
+	if err != nil {
+		return err
+	}
+	return c.callBoolIgnore("SetMultiple", c.sid, string(buf))
+}
 func (c *Client) Rename(fpath, tpath string) error {
 	return c.callBoolIgnore(GetFuncName(), c.sid, fpath, tpath)
 }
@@ -370,6 +638,12 @@ func (c *Client) ConfirmPersistId(persistid string) (string, error) {
 func (c *Client) ConfirmSilent() (string, error) {
 	return c.callString(GetFuncName(), c.sid)
 }
+func (c *Client) ExtendConfirmTimeout(mins int) (string, error) {
+	return c.callString(GetFuncName(), mins)
+}
+func (c *Client) ConfirmedCommitStatus() (map[string]string, error) {
+	return c.callMapString(GetFuncName(), c.sid)
+}
 func (c *Client) CommitConfirm(
 	message string,
 	debug bool,
@@ -377,12 +651,48 @@ func (c *Client) CommitConfirm(
 ) (string, error) {
 	return c.callString(GetFuncName(), c.sid, message, debug, mins)
 }
+func (c *Client) ConfirmedCommit(
+	message string,
+	confirmed bool,
+	timeout, persist, persistid string,
+	debug bool,
+) (string, error) {
+	return c.callString(GetFuncName(), c.sid, message, confirmed, timeout,
+		persist, persistid, debug)
+}
 func (c *Client) Commit(message string, debug bool) (string, error) {
 	return c.callString(GetFuncName(), c.sid, message, debug)
 }
+func (c *Client) CommitExpire(message, expire string, debug bool) (string, error) {
+	return c.callString(GetFuncName(), c.sid, message, expire, debug)
+}
+func (c *Client) CommitExpiries() (string, error) {
+	return c.callString(GetFuncName(), c.sid)
+}
+func (c *Client) CancelCommitExpiry(id string) (string, error) {
+	return c.callString(GetFuncName(), c.sid, id)
+}
+func (c *Client) CommitWithApprovalToken(message, token string, debug bool) (string, error) {
+	return c.callString(GetFuncName(), c.sid, message, token, debug)
+}
 func (c *Client) Discard() error {
 	return c.callBoolIgnore(GetFuncName(), c.sid)
 }
+func (c *Client) DiscardPath(path string) error {
+	return c.callBoolIgnore(GetFuncName(), c.sid, path)
+}
+func (c *Client) Rebase() ([]string, error) {
+	return c.callSliceString(GetFuncName(), c.sid)
+}
+func (c *Client) DeleteImpact(path string) ([]string, error) {
+	return c.callSliceString(GetFuncName(), c.sid, path)
+}
+func (c *Client) XPathCoverage() (string, error) {
+	return c.callString(GetFuncName(), c.sid)
+}
+func (c *Client) ReloadSchema() (map[string]string, error) {
+	return c.callMapString(GetFuncName())
+}
 func (c *Client) Save(file string) error {
 	return c.callBoolIgnore(GetFuncName(), file)
 }
@@ -393,9 +703,80 @@ func (c *Client) ExtractArchive(file, destination string) (string, error) {
 	s, e := c.callString(GetFuncName(), c.sid, file, destination)
 	return s, e
 }
+func (c *Client) GetConfigRevisionNodes(revision string) ([]string, error) {
+	return c.callSliceString(GetFuncName(), revision)
+}
+func (c *Client) GetArchivePolicy() (map[string]int, error) {
+	return c.callMapInt(GetFuncName())
+}
+func (c *Client) SetArchivePolicy(maxRevisions, maxAge, minFreeSpace uint64) error {
+	return c.callBoolIgnore(GetFuncName(), maxRevisions, maxAge, minFreeSpace)
+}
+func (c *Client) PruneArchive() ([]string, error) {
+	return c.callSliceString(GetFuncName())
+}
+func (c *Client) ListConfigRevisions() (map[string]map[string]string, error) {
+	return c.callMapMapString(GetFuncName())
+}
+func (c *Client) TagConfigRevision(revision, tag string) (bool, error) {
+	return c.callBool(GetFuncName(), revision, tag)
+}
+func (c *Client) UntagConfigRevision(tag string) (bool, error) {
+	return c.callBool(GetFuncName(), tag)
+}
 func (c *Client) Load(file string) error {
+	if info, err := os.Stat(file); err == nil &&
+		info.Mode().IsRegular() && info.Size() > loadChunkThreshold {
+		return c.LoadWithProgress(file, nil)
+	}
 	return c.callBoolIgnore(GetFuncName(), c.sid, file)
 }
+
+// LoadWithProgress uploads the file in chunks to be loaded into the
+// session, calling progress (if set) after each chunk is sent.
+func (c *Client) LoadWithProgress(file string, progress func(sent, size int64)) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	id, err := c.callString("LoadBegin", c.sid, file, info.Size())
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, loadChunkSize)
+	var sent int64
+	for {
+		n, rerr := io.ReadFull(f, buf)
+		if n > 0 {
+			if _, err := c.callInt("LoadAppend", id,
+				string(buf[:n])); err != nil {
+				c.callBool("LoadAbort", id)
+				return err
+			}
+			sent += int64(n)
+			if progress != nil {
+				progress(sent, info.Size())
+			}
+		}
+		if rerr == io.EOF || rerr == io.ErrUnexpectedEOF {
+			break
+		}
+		if rerr != nil {
+			c.callBool("LoadAbort", id)
+			return rerr
+		}
+	}
+	return c.callBoolIgnore("LoadFinish", id, false)
+}
+func (c *Client) LoadProgress(id string) (map[string]string, error) {
+	return c.callMapString(GetFuncName(), id)
+}
 func (c *Client) LoadFrom(source string, routingInstance string) error {
 	return c.callBoolIgnore(GetFuncName(), c.sid, source, routingInstance)
 }
@@ -414,9 +795,15 @@ func (c *Client) MergeReportWarnings(file string) (bool, error) {
 func (c *Client) Validate() (string, error) {
 	return c.callString(GetFuncName(), c.sid)
 }
+func (c *Client) ValidateConfig(encoding, config string) (string, error) {
+	return c.callString(GetFuncName(), c.sid, encoding, config)
+}
 func (c *Client) Show(db rpc.DB, path string) (string, error) {
 	return c.callString(GetFuncName(), db, c.sid, path)
 }
+func (c *Client) ShowWithOpts(db rpc.DB, path string, opts *TreeOpts) (string, error) {
+	return c.callString(GetFuncName(), db, c.sid, path, opts.flags())
+}
 func (c *Client) ShowConfigWithContextDiffs(path string, showDefaults bool) (string, error) {
 	return c.callString(GetFuncName(), c.sid, path, showDefaults)
 }
@@ -441,9 +828,239 @@ func (c *Client) GetCommitLog() (map[string]string, error) {
 func (c *Client) GetConfigSystemFeatures() (map[string]struct{}, error) {
 	return c.callMapStruct(GetFuncName())
 }
+func (c *Client) GetRpcOutputViolations() (map[string]int, error) {
+	return c.callMapInt(GetFuncName())
+}
+func (c *Client) GetHealthAlarms() (map[string]string, error) {
+	return c.callMapString(GetFuncName())
+}
+func (c *Client) GetHeapStats() (map[string]int, error) {
+	return c.callMapInt(GetFuncName())
+}
+func (c *Client) GetInstance() (map[string]string, error) {
+	return c.callMapString(GetFuncName())
+}
+func (c *Client) ExportConfigBundle() (string, error) {
+	return c.callString(GetFuncName())
+}
+func (c *Client) ImportConfigBundle(bundle string) (bool, error) {
+	return c.callBool(GetFuncName(), c.sid, bundle)
+}
+func (c *Client) ListScheduledJobs() (map[string]map[string]string, error) {
+	return c.callMapMapString(GetFuncName())
+}
+func (c *Client) GetComponentMappings() (map[string]map[string]string, error) {
+	return c.callMapMapString(GetFuncName())
+}
+func (c *Client) RunScheduledJob(name string) (bool, error) {
+	return c.callBool(GetFuncName(), name)
+}
+func (c *Client) SetScheduledJobEnabled(name string, enabled bool) (bool, error) {
+	return c.callBool(GetFuncName(), name, enabled)
+}
+func (c *Client) GetChangesSinceBoot() (map[string][]uint64, error) {
+	method := GetFuncName()
+	v, err := c.callMap(method)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string][]uint64)
+	for path, val := range v {
+		ids, ok := val.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("wrong return type for %s got %T expecting []interface{}", method, val)
+		}
+		for _, id := range ids {
+			num, ok := id.(float64)
+			if !ok {
+				return nil, fmt.Errorf("wrong return type for %s got %T expecting float64", method, id)
+			}
+			out[path] = append(out[path], uint64(num))
+		}
+	}
+	return out, nil
+}
+func (c *Client) GetUserStats() (map[string]map[string]uint64, error) {
+	return c.callMapCounters(GetFuncName())
+}
+func (c *Client) GetCommitUsers() (map[string]string, error) {
+	return c.callMapString(GetFuncName())
+}
+func (c *Client) GetCommitStats() (map[string]map[string]uint64, error) {
+	return c.callMapCounters(GetFuncName())
+}
+func (c *Client) GetCommitStatsTotal() (map[string]uint64, error) {
+	method := GetFuncName()
+	v, err := c.callMap(method)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]uint64)
+	for name, counter := range v {
+		num, ok := counter.(float64)
+		if !ok {
+			return nil, fmt.Errorf("wrong return type for %s got %T expecting float64", method, counter)
+		}
+		out[name] = uint64(num)
+	}
+	return out, nil
+}
+func (c *Client) StartProfile(kind string) (string, error) {
+	return c.callString(GetFuncName(), kind)
+}
+func (c *Client) StopProfile(kind string) (string, error) {
+	return c.callString(GetFuncName(), kind)
+}
+func (c *Client) Subscribe(path, encoding string) (string, error) {
+	return c.callString(GetFuncName(), path, encoding)
+}
+func (c *Client) Unsubscribe(id string) error {
+	return c.callBoolIgnore(GetFuncName(), id)
+}
+func (c *Client) WaitNotification(id string, timeout int) (string, error) {
+	return c.callString(GetFuncName(), id, timeout)
+}
+func (c *Client) EstablishSubscription(
+	path, encoding, trigger string,
+	period int,
+) (string, error) {
+	return c.callString(GetFuncName(), path, encoding, trigger, period)
+}
+func (c *Client) ModifySubscription(id string, period int) error {
+	return c.callBoolIgnore(GetFuncName(), id, period)
+}
+func (c *Client) DeleteSubscription(id string) error {
+	return c.callBoolIgnore(GetFuncName(), id)
+}
+func (c *Client) WaitPushUpdate(id string, timeout int) (string, error) {
+	return c.callString(GetFuncName(), id, timeout)
+}
+func (c *Client) SetAnnotation(path, key, value string) error {
+	return c.callBoolIgnore(GetFuncName(), path, key, value)
+}
+func (c *Client) DeleteAnnotation(path, key string) error {
+	return c.callBoolIgnore(GetFuncName(), path, key)
+}
+func (c *Client) GetAnnotations(path string) (map[string]map[string]string, error) {
+	method := GetFuncName()
+	v, err := c.callMap(method, path)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]map[string]string)
+	for p, val := range v {
+		kvs, ok := val.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("wrong return type for %s got %T expecting map[string]interface{}", method, val)
+		}
+		out[p] = make(map[string]string)
+		for k, kv := range kvs {
+			str, ok := kv.(string)
+			if !ok {
+				return nil, fmt.Errorf("wrong return type for %s got %T expecting string", method, kv)
+			}
+			out[p][k] = str
+		}
+	}
+	return out, nil
+}
+func (c *Client) SetSessionVariable(name, value string) error {
+	return c.callBoolIgnore(GetFuncName(), c.sid, name, value)
+}
+func (c *Client) UnsetSessionVariable(name string) error {
+	return c.callBoolIgnore(GetFuncName(), c.sid, name)
+}
+func (c *Client) ClearSessionVariables() error {
+	return c.callBoolIgnore(GetFuncName(), c.sid)
+}
+func (c *Client) GetSessionVariables() (map[string]string, error) {
+	method := GetFuncName()
+	v, err := c.callMap(method, c.sid)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]string, len(v))
+	for name, val := range v {
+		str, ok := val.(string)
+		if !ok {
+			return nil, fmt.Errorf("wrong return type for %s got %T expecting string", method, val)
+		}
+		out[name] = str
+	}
+	return out, nil
+}
+func (c *Client) SetConfigGroup(name string, paths []string) error {
+	buf, err := json.Marshal(paths)
+	if err != nil {
+		return err
+	}
+	return c.callBoolIgnore(GetFuncName(), name, string(buf))
+}
+func (c *Client) DeleteConfigGroup(name string) error {
+	return c.callBoolIgnore(GetFuncName(), name)
+}
+func (c *Client) ApplyConfigGroup(path, name string) error {
+	return c.callBoolIgnore(GetFuncName(), path, name)
+}
+func (c *Client) UnapplyConfigGroup(path, name string) error {
+	return c.callBoolIgnore(GetFuncName(), path, name)
+}
+func (c *Client) callStringsMap(method string, args ...interface{}) (map[string][]string, error) {
+	v, err := c.callMap(method, args...)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string][]string)
+	for k, val := range v {
+		vals, ok := val.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("wrong return type for %s got %T expecting []interface{}", method, val)
+		}
+		for _, elem := range vals {
+			str, ok := elem.(string)
+			if !ok {
+				return nil, fmt.Errorf("wrong return type for %s got %T expecting string", method, elem)
+			}
+			out[k] = append(out[k], str)
+		}
+	}
+	return out, nil
+}
+func (c *Client) GetConfigGroups() (map[string][]string, error) {
+	return c.callStringsMap(GetFuncName())
+}
+func (c *Client) GetConfigGroupApplications() (map[string][]string, error) {
+	return c.callStringsMap(GetFuncName())
+}
 func (c *Client) AuthAuthorize(path string, perm int) (bool, error) {
 	return c.callBool(GetFuncName(), path, perm)
 }
+func (c *Client) AuthAuthorizePaths(
+	user string,
+	groups, paths []string,
+) (map[string]map[string]bool, error) {
+	method := GetFuncName()
+	v, err := c.callMap(method, user, groups, paths)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]map[string]bool)
+	for path, val := range v {
+		perms, ok := val.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("wrong return type for %s got %T expecting map[string]interface{}", method, val)
+		}
+		out[path] = make(map[string]bool)
+		for perm, allowed := range perms {
+			b, ok := allowed.(bool)
+			if !ok {
+				return nil, fmt.Errorf("wrong return type for %s got %T expecting bool", method, allowed)
+			}
+			out[path][perm] = b
+		}
+	}
+	return out, nil
+}
 
 func (c *Client) AuthGetPerms() (map[string]string, error) {
 	return c.callMapString(GetFuncName())
@@ -488,6 +1105,15 @@ func (c *Client) ExpandWithPrefix(
 ) (string, error) {
 	return c.callString(GetFuncName(), path, prefix, pos)
 }
+func (c *Client) ExpandWithOptions(
+	path, prefix string, pos int,
+	flags map[string]interface{},
+) (string, error) {
+	return c.callString(GetFuncName(), path, prefix, pos, flags)
+}
+func (c *Client) ExpandCandidates(path, prefix string, pos int) ([]string, error) {
+	return c.callSliceString(GetFuncName(), path, prefix, pos)
+}
 
 func (c *Client) Compare(old, new, spath string, ctxdiff bool) (string, error) {
 	return c.callString(GetFuncName(), old, new, spath, ctxdiff)
@@ -500,6 +1126,165 @@ func (c *Client) CompareConfigRevisions(revOne string, revTwo string) (string, e
 func (c *Client) CompareSessionChanges() (string, error) {
 	return c.callString(GetFuncName(), c.sid)
 }
+func (c *Client) EffectiveDiff(path string) (string, error) {
+	return c.callString(GetFuncName(), c.sid, path)
+}
+func (c *Client) CompareTree(path string) ([]rpc.TreeChange, error) {
+	out, err := c.callString(GetFuncName(), c.sid, path)
+	if err != nil {
+		return nil, err
+	}
+	var changes []rpc.TreeChange
+	err = json.Unmarshal([]byte(out), &changes)
+	return changes, err
+}
+
+func (c *Client) GetAuditLog(since, count int) ([]rpc.AuditRecord, error) {
+	out, err := c.callString(GetFuncName(), since, count)
+	if err != nil {
+		return nil, err
+	}
+	var recs []rpc.AuditRecord
+	err = json.Unmarshal([]byte(out), &recs)
+	return recs, err
+}
+
+func (c *Client) ReplicateRevision(rev *rpc.ReplicatedRevision) error {
+	buf, err := json.Marshal(rev)
+	if err != nil {
+		return err
+	}
+	return c.callBoolIgnore(GetFuncName(), string(buf))
+}
+
+func (c *Client) GetStandbySyncStatus() (map[string]string, error) {
+	return c.callMapString(GetFuncName())
+}
+
+func (c *Client) StandbyFailover(comment string) (string, error) {
+	return c.callString(GetFuncName(), c.sid, comment)
+}
+
+func (c *Client) GetLastError() (*rpc.LastFailure, error) {
+	out, err := c.callString(GetFuncName(), c.sid)
+	if err != nil || out == "" {
+		return nil, err
+	}
+	failure := &rpc.LastFailure{}
+	if err := json.Unmarshal([]byte(out), failure); err != nil {
+		return nil, err
+	}
+	return failure, nil
+}
+
+func (c *Client) GetSchemaAudit(path string) (*rpc.SchemaAudit, error) {
+	out, err := c.callString(GetFuncName(), path)
+	if err != nil {
+		return nil, err
+	}
+	audit := &rpc.SchemaAudit{}
+	err = json.Unmarshal([]byte(out), audit)
+	return audit, err
+}
+
+func (c *Client) ValidateSchemaOnly() (string, error) {
+	return c.callString(GetFuncName(), c.sid)
+}
+
+func (c *Client) InvalidateStateCache(path string) (int, error) {
+	return c.callInt(GetFuncName(), path)
+}
+
+func (c *Client) WatchLeaf(path string) (string, error) {
+	return c.callString(GetFuncName(), path)
+}
+
+func (c *Client) UnwatchLeaf(id string) error {
+	return c.callBoolIgnore(GetFuncName(), id)
+}
+
+func (c *Client) WaitLeafChange(id string, timeout int) (string, error) {
+	return c.callString(GetFuncName(), id, timeout)
+}
+
+func (c *Client) HashTree(db rpc.DB, path, algo string) (string, error) {
+	return c.callString(GetFuncName(), db, path, algo)
+}
+
+func (c *Client) Cancel(call *Call) (bool, error) {
+	return c.callBool(GetFuncName(), call.id)
+}
+
+func (c *Client) FreezePath(path, reason string) (bool, error) {
+	return c.callBool(GetFuncName(), path, reason)
+}
+
+func (c *Client) UnfreezePath(path string) (bool, error) {
+	return c.callBool(GetFuncName(), path)
+}
+
+func (c *Client) GetFrozenPaths() (string, error) {
+	return c.callString(GetFuncName())
+}
+
+func (c *Client) LoadPreview(file string) (map[string][]string, error) {
+	return c.callStringsMap(GetFuncName(), file)
+}
+
+func (c *Client) SetClientOrigin(origin string) (bool, error) {
+	return c.callBool(GetFuncName(), origin)
+}
+
+func (c *Client) SupportConfig(profileFile string) (string, error) {
+	return c.callString(GetFuncName(), profileFile)
+}
+
+func (c *Client) EncryptConfigFile(file string) (bool, error) {
+	return c.callBool(GetFuncName(), file)
+}
+
+func (c *Client) SessionLockWithReason(reason string) (int, error) {
+	return c.callInt(GetFuncName(), c.sid, reason)
+}
+
+func (c *Client) SessionLockInfo() (*rpc.LockInfo, error) {
+	out, err := c.callString(GetFuncName(), c.sid)
+	if err != nil || out == "" {
+		return nil, err
+	}
+	info := &rpc.LockInfo{}
+	if err := json.Unmarshal([]byte(out), info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func (c *Client) SessionForceUnlock(sid string) (int, error) {
+	return c.callInt(GetFuncName(), sid)
+}
+
+func (c *Client) ConfigureExclusive() (bool, error) {
+	return c.callBool(GetFuncName(), c.sid)
+}
+
+func (c *Client) ReleaseExclusive() (bool, error) {
+	return c.callBool(GetFuncName(), c.sid)
+}
+
+func (c *Client) ExclusiveStatus() (map[string]string, error) {
+	return c.callMapString(GetFuncName(), c.sid)
+}
+
+func (c *Client) WaitExclusiveRelease(timeout int) (bool, error) {
+	return c.callBool(GetFuncName(), timeout)
+}
+
+func (c *Client) GetComment(path string) (string, error) {
+	return c.callString(GetFuncName(), c.sid, path)
+}
+func (c *Client) CompareSessions(sidA, sidB string) (string, error) {
+	return c.callString(GetFuncName(), sidA, sidB)
+}
 
 func (c *Client) SetConfigDebug(dbgType, level string) (string, error) {
 	return c.callString(GetFuncName(), c.sid, dbgType, level)
@@ -0,0 +1,199 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: MPL-2.0
+
+package client
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ConfirmedCommitSession runs the confirmed commit workflow for a client's
+// session.  Commit commits the session's changes, to be reverted unless
+// confirmed within the timeout; further Commits before then are follow-up
+// commits, restarting the timeout.  Confirm keeps the changes and Cancel
+// reverts them at once.  Every call carries the persist-id, so the commit
+// can also be confirmed or cancelled from another connection, eg after
+// losing this one, with ConfirmPersistId or CancelCommit.  Expired is
+// closed when the timeout passes without the commit being confirmed.
+type ConfirmedCommitSession struct {
+	c         *Client
+	persistId string
+	timeout   time.Duration
+
+	mu       sync.Mutex
+	pending  bool
+	deadline time.Time
+	timer    *time.Timer
+	expired  chan struct{}
+}
+
+func newPersistId() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// NewConfirmedCommitSession returns a ConfirmedCommitSession for the
+// client's session, reverting commits not confirmed within timeout.  If
+// persistId is empty one is generated.
+func (c *Client) NewConfirmedCommitSession(
+	persistId string,
+	timeout time.Duration,
+) *ConfirmedCommitSession {
+	if persistId == "" {
+		persistId = newPersistId()
+	}
+	if timeout < time.Second {
+		timeout = time.Second
+	}
+	return &ConfirmedCommitSession{
+		c:         c,
+		persistId: persistId,
+		timeout:   timeout,
+		expired:   make(chan struct{}),
+	}
+}
+
+func (s *ConfirmedCommitSession) PersistId() string {
+	return s.persistId
+}
+
+// Pending reports whether a commit is waiting to be confirmed.
+func (s *ConfirmedCommitSession) Pending() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pending
+}
+
+// Remaining returns the time left to confirm the pending commit.
+func (s *ConfirmedCommitSession) Remaining() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.pending {
+		return 0
+	}
+	if left := time.Until(s.deadline); left > 0 {
+		return left
+	}
+	return 0
+}
+
+// Expired returns a channel closed if the pending commit isn't confirmed
+// in time, and so has been reverted.
+func (s *ConfirmedCommitSession) Expired() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.expired
+}
+
+// startTimer (re)starts the timeout, which ends at deadline.  Must be
+// called with s.mu held.
+func (s *ConfirmedCommitSession) startTimer(deadline time.Time) {
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	s.pending = true
+	s.deadline = deadline
+	expired := s.expired
+	s.timer = time.AfterFunc(time.Until(deadline), func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.pending && s.expired == expired && !time.Now().Before(s.deadline) {
+			s.pending = false
+			close(expired)
+		}
+	})
+}
+
+// finish stops the timeout, once the commit has been confirmed or
+// cancelled.  Must be called with s.mu held.
+func (s *ConfirmedCommitSession) finish() {
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	if s.pending {
+		s.pending = false
+		s.expired = make(chan struct{})
+	}
+}
+
+// Commit commits the session's changes, to be reverted unless confirmed
+// before the timeout.  While a commit is pending this is a follow-up
+// commit, which restarts the timeout.
+func (s *ConfirmedCommitSession) Commit(message string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	persistid := ""
+	if s.pending {
+		persistid = s.persistId
+	} else {
+		select {
+		case <-s.expired:
+			s.expired = make(chan struct{})
+		default:
+		}
+	}
+	secs := strconv.Itoa(int(s.timeout / time.Second))
+	out, err := s.c.ConfirmedCommit(message, true, secs, s.persistId,
+		persistid, false)
+	if err != nil {
+		return out, err
+	}
+	s.startTimer(time.Now().Add(s.timeout))
+	return out, nil
+}
+
+// Confirm keeps the pending commit.
+func (s *ConfirmedCommitSession) Confirm() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out, err := s.c.ConfirmPersistId(s.persistId)
+	if err != nil {
+		return out, err
+	}
+	s.finish()
+	return out, nil
+}
+
+// Cancel reverts the pending commit at once.
+func (s *ConfirmedCommitSession) Cancel(comment string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out, err := s.c.CancelCommit(comment, s.persistId, false, false)
+	if err != nil {
+		return out, err
+	}
+	s.finish()
+	return out, nil
+}
+
+// Refresh updates the deadline from configd, where it may have been
+// extended, and notices the commit being confirmed or cancelled from
+// another connection.
+func (s *ConfirmedCommitSession) Refresh() error {
+	status, err := s.c.ConfirmedCommitStatus()
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.pending {
+		return nil
+	}
+	if status["persist-id"] != s.persistId {
+		s.finish()
+		return nil
+	}
+	deadline, err := time.Parse(time.RFC3339, status["deadline"])
+	if err != nil {
+		return err
+	}
+	s.startTimer(deadline)
+	return nil
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/danos/configd/rpc"
+)
+
+// 'show -audit' lists the most recent records in the audit log, one line
+// per commit, rollback, load or copy-config followed by the changes it
+// made, most recent last.
+
+const auditRecordsShown = 100
+
+// parseShowAudit - strip a lone '-audit' flag from the show command,
+// returning whether it was given.
+func parseShowAudit(args []string) ([]string, bool) {
+	if len(args) == 2 && args[1] == "-audit" {
+		return args[:1], true
+	}
+	return args, false
+}
+
+func writeAuditLog(w io.Writer, recs []rpc.AuditRecord) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "Id\tTime\tOperation\tUser\tSession\tResult")
+	for _, rec := range recs {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n", rec.Id, rec.Time,
+			rec.Operation, rec.User, rec.Session, rec.Result)
+		for _, ch := range rec.Changes {
+			switch {
+			case ch.Old != "" && ch.New != "":
+				fmt.Fprintf(tw, "\t  %s %s %s -> %s\n", ch.Op, ch.Path,
+					ch.Old, ch.New)
+			case ch.New != "":
+				fmt.Fprintf(tw, "\t  %s %s %s\n", ch.Op, ch.Path, ch.New)
+			default:
+				fmt.Fprintf(tw, "\t  %s %s\n", ch.Op, ch.Path)
+			}
+		}
+	}
+	tw.Flush()
+}
+
+func showAuditRun(ctx *Ctx) {
+	var recs []rpc.AuditRecord
+	for since := 0; ; {
+		page, err := ctx.Client.GetAuditLog(since, auditRecordsShown)
+		handleError(err)
+		if len(page) == 0 {
+			break
+		}
+		recs = append(recs, page...)
+		if len(recs) > auditRecordsShown {
+			recs = recs[len(recs)-auditRecordsShown:]
+		}
+		since = int(page[len(page)-1].Id)
+	}
+	var buf bytes.Buffer
+	writeAuditLog(&buf, recs)
+	doSnippit(ctx, fmt.Sprintf("echo -n \"%s\" | %s",
+		escapeConfig(buf.String()), pager))
+}
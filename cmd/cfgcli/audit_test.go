@@ -0,0 +1,49 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/danos/configd/rpc"
+)
+
+func TestParseShowAudit(t *testing.T) {
+	args, ok := parseShowAudit([]string{"show", "-audit"})
+	if !ok || !reflect.DeepEqual(args, []string{"show"}) {
+		t.Fatalf("-audit not parsed: %v", args)
+	}
+	args, ok = parseShowAudit([]string{"show", "-audit", "system"})
+	if ok || len(args) != 3 {
+		t.Fatalf("Unexpected -audit parsed: %v", args)
+	}
+}
+
+func TestWriteAuditLog(t *testing.T) {
+	var buf bytes.Buffer
+	writeAuditLog(&buf, []rpc.AuditRecord{
+		{Id: 1, Time: "2021-06-01T10:00:00Z", Operation: "commit",
+			User: "vyatta", Session: "1234", Result: "success",
+			Changes: []rpc.TreeChange{
+				{Op: "update", Path: "/system/host-name",
+					Old: "old", New: "new"},
+				{Op: "delete", Path: "/interfaces/dataplane/dp0s3"},
+			}},
+	})
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("Unexpected output:\n%s", buf.String())
+	}
+	checkTextContains(t, lines[1], []string{"1", "commit", "vyatta",
+		"1234", "success"})
+	checkTextContains(t, lines[2], []string{"update /system/host-name",
+		"old -> new"})
+	checkTextContains(t, lines[3], []string{
+		"delete /interfaces/dataplane/dp0s3"})
+}
@@ -0,0 +1,214 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/danos/utils/pathutil"
+)
+
+// configure batch
+//
+// Automation usually drives configuration by running cfgcli once per
+// command, paying for a new process, connection and feature lookup each
+// time.  '-action batch' instead reads commands, one per line, from stdin
+// and runs them all in the one session.  The commands are set, delete,
+// discard, validate and commit, each taking the same arguments as on the
+// command line, with paths always from the top of the configuration.
+// Arguments containing spaces are quoted as they would be in the shell.
+// Blank lines and lines starting with '#' are ignored.
+//
+// The batch stops at the first command that fails unless
+// -continue-on-error is given.  A result is printed for each command,
+// followed by a summary, and the exit status is non-zero if any failed.
+
+type batchFunc func(c cfgManager, args []string) (string, error)
+
+var batchCommands = map[string]batchFunc{
+	"commit":   batchCommit,
+	"delete":   batchDelete,
+	"discard":  batchDiscard,
+	"set":      batchSet,
+	"validate": batchValidate,
+}
+
+func batchPath(c cfgManager, cmd string, args []string) (string, error) {
+	if len(args) == 0 {
+		return "", fmt.Errorf(notspec, cmd)
+	}
+	return c.Expand(pathutil.Pathstr(args))
+}
+
+func batchSet(c cfgManager, args []string) (string, error) {
+	path, err := batchPath(c, "set", args)
+	if err != nil {
+		return "", err
+	}
+	return c.Set(path)
+}
+
+func batchDelete(c cfgManager, args []string) (string, error) {
+	path, err := batchPath(c, "delete", args)
+	if err != nil {
+		return "", err
+	}
+	return "", c.Delete(path)
+}
+
+func batchDiscard(c cfgManager, args []string) (string, error) {
+	if len(args) == 0 {
+		return "", c.Discard()
+	}
+	path, err := batchPath(c, "discard", args)
+	if err != nil {
+		return "", err
+	}
+	return "", c.DiscardPath(path)
+}
+
+func batchValidate(c cfgManager, args []string) (string, error) {
+	if len(args) != 0 {
+		return "", errors.New("validate takes no arguments in a batch")
+	}
+	return c.Validate()
+}
+
+// batchCommit commits, and as for an interactive commit saves, the changes
+// made so far.
+func batchCommit(c cfgManager, args []string) (string, error) {
+	var comment string
+	switch {
+	case len(args) == 0:
+	case len(args) == 2 && args[0] == "comment":
+		comment = args[1]
+	default:
+		return "", errors.New("Usage: commit [comment <text>]")
+	}
+
+	changed, err := c.SessionChanged()
+	if err != nil {
+		return "", err
+	}
+	if !changed {
+		return "", errors.New("No configuration changes to commit")
+	}
+	out, err := c.Commit(comment, isCommitDebugOn())
+	if err != nil {
+		return out, err
+	}
+	if err := c.Save(configBootPath); err != nil {
+		return out, err
+	}
+	return out, c.SessionMarkSaved()
+}
+
+// splitBatchLine splits a line into its arguments, as the shell would for
+// simple quoting and backslash escapes.
+func splitBatchLine(line string) ([]string, error) {
+	var args []string
+	var arg strings.Builder
+	var quote rune
+	inArg, escaped := false, false
+	for _, r := range line {
+		switch {
+		case escaped:
+			arg.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inArg = true, true
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			arg.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, errors.New("Unterminated quote or escape")
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
+
+func runBatchLine(c cfgManager, line string) (string, error) {
+	args, err := splitBatchLine(line)
+	if err != nil {
+		return "", err
+	}
+	fn, ok := batchCommands[args[0]]
+	if !ok {
+		return "", fmt.Errorf("Invalid command %s in batch", args[0])
+	}
+	return fn(c, args[1:])
+}
+
+func indentOutput(out string) string {
+	return "  " + strings.Replace(strings.TrimRight(out, "\n"), "\n", "\n  ", -1)
+}
+
+// runBatch runs the commands read from r, writing the results to w, and
+// returns the number of commands that failed.
+func runBatch(
+	c cfgManager,
+	r io.Reader,
+	w io.Writer,
+	continueOnError bool,
+) (int, error) {
+	var run, failed int
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		run++
+		out, err := runBatchLine(c, line)
+		if err != nil {
+			failed++
+			fmt.Fprintf(w, "line %d: FAILED: %s\n%s\n", lineNum, line,
+				indentOutput(err.Error()))
+			if !continueOnError {
+				break
+			}
+			continue
+		}
+		fmt.Fprintf(w, "line %d: OK: %s\n", lineNum, line)
+		if out != "" {
+			fmt.Fprintln(w, indentOutput(out))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return failed, err
+	}
+	fmt.Fprintf(w, "\n%d commands run, %d succeeded, %d failed\n",
+		run, run-failed, failed)
+	return failed, nil
+}
+
+func batch_handler(c cfgManager, params cmdLineParams) {
+	failed, err := runBatch(c, os.Stdin, os.Stdout, params.continueOnError)
+	handleError(err)
+	if failed != 0 {
+		os.Exit(1)
+	}
+}
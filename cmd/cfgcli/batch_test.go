@@ -0,0 +1,128 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func expectCall(fnName string, ret *MockReturnParams, params ...string,
+) MockExpectation {
+	if params == nil {
+		params = []string{}
+	}
+	if ret == nil {
+		ret = &MockReturnParams{}
+	}
+	return MockExpectation{fnName: fnName, callParams: params, retParams: ret}
+}
+
+func TestSplitBatchLine(t *testing.T) {
+	tests := []struct {
+		line string
+		exp  []string
+	}{
+		{`set system host-name  foo`, []string{"set", "system", "host-name", "foo"}},
+		{`set a b "two words"`, []string{"set", "a", "b", "two words"}},
+		{`set a b 'it"s'`, []string{"set", "a", "b", `it"s`}},
+		{`set a b two\ words`, []string{"set", "a", "b", "two words"}},
+		{`set a b ""`, []string{"set", "a", "b", ""}},
+	}
+	for _, test := range tests {
+		args, err := splitBatchLine(test.line)
+		if err != nil {
+			t.Fatalf("Unable to split %q: %s", test.line, err)
+		}
+		if !reflect.DeepEqual(args, test.exp) {
+			t.Fatalf("Split %q.\nExp: %q\nGot: %q", test.line, test.exp, args)
+		}
+	}
+	if _, err := splitBatchLine(`set a "b`); err == nil {
+		t.Fatalf("Unterminated quote accepted")
+	}
+}
+
+const testBatch = `
+# add the interface
+set interfaces dataplane dp0s1
+delete protocols
+commit comment "batch test"
+`
+
+func TestRunBatch(t *testing.T) {
+	tc := newTestClient(t)
+	for _, call := range []MockExpectation{
+		expectCall("Expand", &MockReturnParams{
+			retStr: "/interfaces/dataplane/dp0s1"},
+			"/interfaces/dataplane/dp0s1"),
+		expectCall("Set", nil, "/interfaces/dataplane/dp0s1"),
+		expectCall("Expand", &MockReturnParams{retStr: "/protocols"},
+			"/protocols"),
+		expectCall("Delete", nil, "/protocols"),
+		expectCall("SessionChanged", &MockReturnParams{retBool: true}),
+		expectCall("Commit", nil, "batch test"),
+		expectCall("Save", nil, configBootPath),
+		expectCall("SessionMarkSaved", nil),
+	} {
+		tc.AddExpectedCall(call)
+	}
+
+	var out bytes.Buffer
+	failed, err := runBatch(tc, strings.NewReader(testBatch), &out, false)
+	checkNoError(t, err)
+	tc.CheckAllCallsMade(t)
+	if failed != 0 {
+		t.Fatalf("Unexpected failures:\n%s", out.String())
+	}
+	checkTextContains(t, out.String(), []string{
+		"line 3: OK: set interfaces dataplane dp0s1",
+		"line 5: OK: commit comment \"batch test\"",
+		"3 commands run, 3 succeeded, 0 failed",
+	})
+}
+
+func testRunFailingBatch(t *testing.T, continueOnError bool) string {
+	tc := newTestClient(t)
+	tc.AddExpectedCall(expectCall("Expand",
+		&MockReturnParams{retErr: errors.New("Configuration path is invalid")},
+		"/foo"))
+	if continueOnError {
+		tc.AddExpectedCall(expectCall("Expand",
+			&MockReturnParams{retStr: "/protocols"}, "/protocols"))
+		tc.AddExpectedCall(expectCall("Delete", nil, "/protocols"))
+	}
+
+	var out bytes.Buffer
+	failed, err := runBatch(tc, strings.NewReader("set foo\nbogus\ndelete protocols\n"),
+		&out, continueOnError)
+	checkNoError(t, err)
+	tc.CheckAllCallsMade(t)
+	if continueOnError && failed != 2 || !continueOnError && failed != 1 {
+		t.Fatalf("Unexpected number of failures %d:\n%s", failed,
+			out.String())
+	}
+	return out.String()
+}
+
+func TestRunBatchFailFast(t *testing.T) {
+	out := testRunFailingBatch(t, false)
+	checkTextContains(t, out, []string{
+		"line 1: FAILED: set foo\n  Configuration path is invalid",
+		"1 commands run, 0 succeeded, 1 failed",
+	})
+}
+
+func TestRunBatchContinueOnError(t *testing.T) {
+	out := testRunFailingBatch(t, true)
+	checkTextContains(t, out, []string{
+		"line 2: FAILED: bogus\n  Invalid command bogus in batch",
+		"line 3: OK: delete protocols",
+		"3 commands run, 1 succeeded, 2 failed",
+	})
+}
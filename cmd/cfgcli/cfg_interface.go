@@ -22,25 +22,36 @@ type getSetter interface {
 // grouping.
 type commander interface {
 	CancelCommit(comment, persistid string, force, debug bool) (string, error)
+	Comment(path string) error
 	Commit(message string, debug bool) (string, error)
 	CommitConfirm(message string, debug bool, mins int) (string, error)
+	CommitExpire(message, expire string, debug bool) (string, error)
 	CompareConfigRevisions(revOne, revTwo string) (string, error)
 	CompareSessionChanges() (string, error)
 	Confirm() (string, error)
 	ConfirmSilent() (string, error)
 	ConfirmPersistId(persistid string) (string, error)
+	ConfirmedCommitStatus() (map[string]string, error)
+	Copy(fpath, tpath string) error
 	Delete(path string) error
 	Discard() error
+	DiscardPath(path string) error
+	EditGetEnv() (map[string]string, error)
+	EditSetLevel(path string) error
+	ExclusiveStatus() (map[string]string, error)
+	ExtendConfirmTimeout(mins int) (string, error)
 	getSetter
 	Load(file string) error
 	LoadFrom(source, routingInstance string) error
 	LoadKeys(user, source, routingInstance string) (string, error)
 	MergeReportWarnings(file string) (bool, error)
+	Rename(fpath, tpath string) error
 	Rollback(string, string, bool) (string, error)
 	Save(file string) error
 	SaveTo(dest, routingInstance string) error
 	ShowConfigWithContextDiffs(path string, showDefaults bool) (string, error)
 	Validate() (string, error)
+	ValidateConfig(encoding, config string) (string, error)
 }
 
 type completer interface {
@@ -61,10 +72,16 @@ type cfgManager interface {
 	completer
 	Exists(db rpc.DB, path string) (bool, error)
 	expander
+	ExportList(db rpc.DB, path, format string,
+		flags map[string]interface{}) (string, error)
 	ExtractArchive(file, destination string) (string, error)
 	Get(db rpc.DB, path string) ([]string, error)
+	GetAuditLog(since, count int) ([]rpc.AuditRecord, error)
 	GetCommitLog() (map[string]string, error)
+	GetComponentMappings() (map[string]map[string]string, error)
+	GetConfigRevisionNodes(revision string) ([]string, error)
 	GetConfigSystemFeatures() (map[string]struct{}, error)
+	GetLastError() (*rpc.LastFailure, error)
 	SessionChanged() (bool, error)
 	SessionMarkSaved() error
 	typeGetter
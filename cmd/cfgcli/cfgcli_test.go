@@ -307,3 +307,53 @@ func TestParseCmdLine(t *testing.T) {
 	checkArgs(t, args, 1, []string{"first", "prefixSuffixAndSpace", ""})
 	checkParams(t, params, 2, "prefixSuffixAndSpace", "prefix")
 }
+
+func TestParseShowFormat(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		expArgs   []string
+		expFormat string
+	}{
+		{"No modifier",
+			[]string{"show", "interfaces", "dataplane"},
+			[]string{"show", "interfaces", "dataplane"}, ""},
+		{"CSV",
+			[]string{"show", "interfaces", "dataplane", "format", "csv"},
+			[]string{"show", "interfaces", "dataplane"}, "csv"},
+		{"Table",
+			[]string{"show", "interfaces", "dataplane", "format", "table"},
+			[]string{"show", "interfaces", "dataplane"}, "table"},
+		{"Unknown format treated as path",
+			[]string{"show", "system", "format", "xml"},
+			[]string{"show", "system", "format", "xml"}, ""},
+		{"No path",
+			[]string{"show", "format", "csv"},
+			[]string{"show", "format", "csv"}, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			args, format := parseShowFormat(test.args)
+			if format != test.expFormat {
+				t.Fatalf("Expected format '%s', got '%s'",
+					test.expFormat, format)
+			}
+			if strings.Join(args, " ") != strings.Join(test.expArgs, " ") {
+				t.Fatalf("Expected args %v, got %v", test.expArgs, args)
+			}
+		})
+	}
+}
+
+func TestEnvSnippit(t *testing.T) {
+	env := map[string]string{
+		"VYATTA_EDIT_LEVEL": "/interfaces/dataplane/dp0s1",
+		"PS1":               `[edit interfaces dataplane 'dp0s1']\n\u@\h# `,
+	}
+	exp := `export PS1='[edit interfaces dataplane '\''dp0s1'\'']\n\u@\h# '; ` +
+		`export VYATTA_EDIT_LEVEL='/interfaces/dataplane/dp0s1'; `
+	if out := envSnippit(env); out != exp {
+		t.Fatalf("Expected %s, got %s", exp, out)
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"testing"
 
 	"fmt"
+	"strconv"
+
 	"github.com/danos/configd/rpc"
 )
 
@@ -15,6 +17,7 @@ type MockReturnParams struct {
 	retStr  string
 	retInt  int
 	retBool bool
+	retMap  map[string]string
 	retErr  error
 }
 
@@ -104,8 +107,23 @@ func (tc *testClient) CancelCommit(comment, persistid string, force, debug bool)
 	panic("Rollback testClient method not yet implemented")
 }
 
+func (tc *testClient) Comment(path string) error {
+	retParams := tc.MakeActualCall(tc.t, "Comment", []string{path})
+	return retParams.retErr
+}
+
 func (tc *testClient) Commit(message string, debug bool) (string, error) {
-	panic("Commit testClient method not yet implemented")
+	retParams := tc.MakeActualCall(tc.t, "Commit", []string{message})
+	return retParams.retStr, retParams.retErr
+}
+
+func (tc *testClient) CommitExpire(
+	message, expire string,
+	debug bool,
+) (string, error) {
+	retParams := tc.MakeActualCall(tc.t, "CommitExpire",
+		[]string{message, expire})
+	return retParams.retStr, retParams.retErr
 }
 
 func (tc *testClient) CommitConfirm(message string, debug bool, mins int,
@@ -132,13 +150,43 @@ func (tc *testClient) ConfirmSilent() (string, error) {
 	panic("ConfirmSilent testClient method not yet implemented")
 }
 
+func (tc *testClient) ConfirmedCommitStatus() (map[string]string, error) {
+	retParams := tc.MakeActualCall(tc.t, "ConfirmedCommitStatus", []string{})
+	return retParams.retMap, retParams.retErr
+}
+
+func (tc *testClient) Copy(fpath, tpath string) error {
+	retParams := tc.MakeActualCall(tc.t, "Copy", []string{fpath, tpath})
+	return retParams.retErr
+}
+
 func (tc *testClient) Delete(path string) error {
-	panic("Delete testClient method not yet implemented")
+	retParams := tc.MakeActualCall(tc.t, "Delete", []string{path})
+	return retParams.retErr
 }
 
 func (tc *testClient) Discard() error {
 	panic("Discard testClient method not yet implemented")
 }
+
+func (tc *testClient) DiscardPath(path string) error {
+	panic("DiscardPath testClient method not yet implemented")
+}
+func (tc *testClient) EditGetEnv() (map[string]string, error) {
+	retParams := tc.MakeActualCall(tc.t, "EditGetEnv", []string{})
+	return retParams.retMap, retParams.retErr
+}
+
+func (tc *testClient) EditSetLevel(path string) error {
+	retParams := tc.MakeActualCall(tc.t, "EditSetLevel", []string{path})
+	return retParams.retErr
+}
+
+func (tc *testClient) ExclusiveStatus() (map[string]string, error) {
+	retParams := tc.MakeActualCall(tc.t, "ExclusiveStatus", []string{})
+	return retParams.retMap, retParams.retErr
+}
+
 func (tc *testClient) Exists(db rpc.DB, path string) (bool, error) {
 	panic("Exists testClient method not yet implemented")
 }
@@ -159,6 +207,20 @@ func (tc *testClient) ExpandWithPrefix(
 	return retParams.retStr, retParams.retErr
 }
 
+func (tc *testClient) ExportList(
+	db rpc.DB,
+	path, format string,
+	flags map[string]interface{},
+) (string, error) {
+	panic("ExportList testClient method not yet implemented")
+}
+
+func (tc *testClient) ExtendConfirmTimeout(mins int) (string, error) {
+	retParams := tc.MakeActualCall(tc.t, "ExtendConfirmTimeout",
+		[]string{strconv.Itoa(mins)})
+	return retParams.retStr, retParams.retErr
+}
+
 func (tc *testClient) ExtractArchive(file, destination string) (string, error) {
 	panic("ExtractArchive testClient method not yet implemented")
 }
@@ -171,6 +233,29 @@ func (tc *testClient) GetCommitLog() (map[string]string, error) {
 	return tc.commitLog, nil
 }
 
+func (tc *testClient) GetAuditLog(since, count int) (
+	[]rpc.AuditRecord, error,
+) {
+	panic("GetAuditLog testClient method not yet implemented")
+}
+
+func (tc *testClient) GetLastError() (*rpc.LastFailure, error) {
+	panic("GetLastError testClient method not yet implemented")
+}
+
+func (tc *testClient) GetComponentMappings() (
+	map[string]map[string]string, error,
+) {
+	panic("GetComponentMappings testClient method not yet implemented")
+}
+
+func (tc *testClient) GetConfigRevisionNodes(revision string) ([]string, error) {
+	if _, ok := tc.commitLog[revision]; !ok {
+		return nil, fmt.Errorf("Invalid revision [%s]", revision)
+	}
+	return []string{"interfaces", "system"}, nil
+}
+
 func (tc *testClient) GetConfigSystemFeatures() (map[string]struct{}, error) {
 	return tc.cfgSysFeatures, nil
 }
@@ -201,12 +286,18 @@ func (tc *testClient) NodeGetType(path string) (rpc.NodeType, error) {
 	panic("NodeGetType testClient method not yet implemented")
 }
 
+func (tc *testClient) Rename(fpath, tpath string) error {
+	retParams := tc.MakeActualCall(tc.t, "Rename", []string{fpath, tpath})
+	return retParams.retErr
+}
+
 func (tc *testClient) Rollback(revision, comment string, debug bool) (string, error) {
 	panic("Rollback testClient method not yet implemented")
 }
 
 func (tc *testClient) Save(file string) error {
-	panic("Save testClient method not yet implemented")
+	retParams := tc.MakeActualCall(tc.t, "Save", []string{file})
+	return retParams.retErr
 }
 
 func (tc *testClient) SaveTo(dest, routingInstance string) error {
@@ -214,15 +305,18 @@ func (tc *testClient) SaveTo(dest, routingInstance string) error {
 }
 
 func (tc *testClient) SessionChanged() (bool, error) {
-	panic("SessionChanged testClient method not yet implemented")
+	retParams := tc.MakeActualCall(tc.t, "SessionChanged", []string{})
+	return retParams.retBool, retParams.retErr
 }
 
 func (tc *testClient) SessionMarkSaved() error {
-	panic("SessionMarkSaved testClient method not yet implemented")
+	retParams := tc.MakeActualCall(tc.t, "SessionMarkSaved", []string{})
+	return retParams.retErr
 }
 
 func (tc *testClient) Set(path string) (string, error) {
-	panic("Set testClient method not yet implemented")
+	retParams := tc.MakeActualCall(tc.t, "Set", []string{path})
+	return retParams.retStr, retParams.retErr
 }
 
 func (tc *testClient) ShowConfigWithContextDiffs(path string, showDefs bool,
@@ -243,3 +337,7 @@ func (tc *testClient) TmplValidatePath(path string) (bool, error) {
 func (tc *testClient) Validate() (string, error) {
 	panic("Validate testClient method not yet implemented")
 }
+
+func (tc *testClient) ValidateConfig(encoding, config string) (string, error) {
+	panic("ValidateConfig testClient method not yet implemented")
+}
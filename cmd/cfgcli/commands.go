@@ -50,18 +50,24 @@ var Commands = populateCommands()
 
 func populateCommands() map[string]*Command {
 	cmds := map[string]*Command{
+		"comment": NewCommand("comment",
+			"Add a comment to a configuration element",
+			commentComp, commentRun, nil),
 		"commit": NewCommand("commit",
 			"Commit the current set of changes",
 			commitComp, commitRun, commitValid),
 		"compare": NewCommand("compare",
 			"Compare configuration revisions",
 			compareComp, compareRun, compareValid),
+		"copy": NewCommand("copy",
+			"Copy a list entry to a new entry",
+			renameCopyComp, copyRun, renameCopyValid),
 		"delete": NewCommand("delete",
 			"Delete a configuration element",
 			pathComp, deleteRun, checkValidPath),
 		"discard": NewCommand("discard",
 			"Discard uncommitted changes",
-			singleCommandComp, discardRun, validSingleCommand),
+			discardComp, discardRun, checkValidPath),
 		"edit": NewCommand("edit",
 			"Edit a sub-element",
 			pathComp, editRun, checkValidPath),
@@ -74,6 +80,9 @@ func populateCommands() map[string]*Command {
 		"merge": NewCommand("merge",
 			"Merge configuration from a file into the candidate configuration",
 			mergeComp, mergeRun, mergeValid),
+		"rename": NewCommand("rename",
+			"Rename a list entry",
+			renameCopyComp, renameRun, renameCopyValid),
 		"run": NewCommand("run",
 			"Run an operational-mode command",
 			runComp, runRun, nil),
@@ -93,8 +102,8 @@ func populateCommands() map[string]*Command {
 			"Set the edit level one level up",
 			singleCommandComp, upRun, validSingleCommand),
 		"validate": NewCommand("validate",
-			"Validate the current set of changes",
-			singleCommandComp, validateRun, validSingleCommand),
+			"Validate the current set of changes, or a configuration file",
+			validateComp, validateRun, validateValid),
 	}
 
 	return cmds
@@ -132,12 +141,7 @@ func updateDynamicCommands(c cfgManager) error {
 }
 
 func checkLoadKey(c cfgManager) bool {
-	feats, err := c.GetConfigSystemFeatures()
-	if err != nil {
-		return false
-	}
-	_, exists := feats[common.LoadKeysFeature]
-	return exists
+	return checkFeature(c, common.LoadKeysFeature)
 }
 
 var cfgMgmtPtr = checkConfigMgmtInternal
@@ -152,12 +156,7 @@ func checkConfigMgmt(c cfgManager) bool {
 }
 
 func checkConfigMgmtInternal(c cfgManager) bool {
-	feats, err := c.GetConfigSystemFeatures()
-	if err != nil {
-		return false
-	}
-	_, exists := feats[common.ConfigManagementFeature]
-	return exists
+	return checkFeature(c, common.ConfigManagementFeature)
 }
 
 func CommandHelps() map[string]string {
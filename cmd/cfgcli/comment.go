@@ -0,0 +1,63 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package main
+
+import (
+	"errors"
+	"os"
+
+	"github.com/danos/utils/pathutil"
+)
+
+// comment takes the path of a node and the text of its comment, eg
+//
+//	comment interfaces dataplane dp0s1 "Uplink to the core"
+//
+// and an empty comment, "", removes the node's comment.
+
+const commentUsage = "Usage: comment <path> \"<text>\""
+
+// commentPath returns the path Comment expects for the arguments of
+// comment: the expanded path of the node, below the edit level, then the
+// text, left off if it is empty.
+func commentPath(e expander, args []string) (string, error) {
+	if len(args) < 2 {
+		return "", errors.New(commentUsage)
+	}
+	path, err := e.Expand(pathutil.Pathstr(editPath(args[:len(args)-1])))
+	if err != nil {
+		return "", err
+	}
+	text := args[len(args)-1]
+	if text == "" {
+		return path + "/", nil
+	}
+	return pathutil.Pathstr(pathutil.CopyAppend(pathutil.Makepath(path),
+		text)), nil
+}
+
+func commentRun(ctx *Ctx) {
+	path, err := commentPath(ctx.Client, ctx.Args[1:])
+	handleError(err)
+	handleError(ctx.Client.Comment(path))
+	os.Exit(0)
+}
+
+// commentComp completes the path of the node, and offers the text of the
+// comment once there is one.
+func commentComp(ctx *Ctx) (completionText string) {
+	epath, elen := editPathLength(ctx.Args[1:ctx.CompCurIdx])
+	path := ExpandPath(ctx.Client, epath)
+	ctx.Args = append(ctx.Args[0:1], path...)
+	ctx.CompCurIdx = ctx.CompCurIdx + elen
+	m := getcompletions(ctx.Client, ctx.Args)
+	if len(path) > 0 {
+		if m == nil {
+			m = make(map[string]string)
+		}
+		m["<\"text\">"] = "Comment for the node"
+	}
+	return doComplete(ctx, true, m, printPathHelp)
+}
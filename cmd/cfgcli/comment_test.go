@@ -0,0 +1,41 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestCommentPath(t *testing.T) {
+	os.Unsetenv("VYATTA_EDIT_LEVEL")
+	tests := []struct {
+		args []string
+		exp  string
+	}{
+		{[]string{"interfaces", "data", "dp0s1", "Uplink"},
+			"/interfaces/dataplane/dp0s1/Uplink"},
+		{[]string{"interfaces", "data", "dp0s1", ""},
+			"/interfaces/dataplane/dp0s1/"},
+	}
+	for _, test := range tests {
+		tc := newTestClient(t)
+		tc.AddExpectedCall(expectCall("Expand",
+			&MockReturnParams{retStr: "/interfaces/dataplane/dp0s1"},
+			"/interfaces/data/dp0s1"))
+		path, err := commentPath(tc, test.args)
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %s", test.args, err)
+		}
+		if path != test.exp {
+			t.Fatalf("%v: expected %s, got %s", test.args, test.exp, path)
+		}
+		tc.CheckAllCallsMade(t)
+	}
+
+	if _, err := commentPath(newTestClient(t), []string{"Uplink"}); err == nil {
+		t.Fatal("Unexpected success without a path")
+	}
+}
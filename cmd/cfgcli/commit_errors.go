@@ -0,0 +1,54 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/danos/configd/rpc"
+)
+
+// 'show commit errors' shows the errors from the session's last failed
+// validation or commit again, each with its full path and the component
+// it is attributed to, for when they have scrolled away.
+
+// parseShowCommitErrors - strip 'commit errors' from the show command,
+// returning whether it was given.
+func parseShowCommitErrors(args []string) ([]string, bool) {
+	if len(args) == 3 && args[1] == "commit" && args[2] == "errors" {
+		return args[:1], true
+	}
+	return args, false
+}
+
+func writeLastFailure(w io.Writer, failure *rpc.LastFailure) {
+	if failure == nil {
+		fmt.Fprintln(w, "No failed validation or commit in this session")
+		return
+	}
+	fmt.Fprintf(w, "Last %s failed at %s with %d error(s):\n",
+		failure.Operation, failure.Time, len(failure.Errors))
+	for _, e := range failure.Errors {
+		fmt.Fprintln(w)
+		if e.Path != "" {
+			fmt.Fprintf(w, "Path:      %s\n", e.Path)
+		}
+		if e.Component != "" {
+			fmt.Fprintf(w, "Component: %s\n", e.Component)
+		}
+		fmt.Fprintf(w, "%s\n", e.Message)
+	}
+}
+
+func showCommitErrorsRun(ctx *Ctx) {
+	failure, err := ctx.Client.GetLastError()
+	handleError(err)
+	var buf bytes.Buffer
+	writeLastFailure(&buf, failure)
+	doSnippit(ctx, fmt.Sprintf("echo -n \"%s\" | %s",
+		escapeConfig(buf.String()), pager))
+}
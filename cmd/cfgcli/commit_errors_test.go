@@ -0,0 +1,49 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/danos/configd/rpc"
+)
+
+func TestParseShowCommitErrors(t *testing.T) {
+	args, ok := parseShowCommitErrors([]string{"show", "commit", "errors"})
+	if !ok || !reflect.DeepEqual(args, []string{"show"}) {
+		t.Fatalf("'commit errors' not parsed: %v", args)
+	}
+	args, ok = parseShowCommitErrors([]string{"show", "commit"})
+	if ok || len(args) != 2 {
+		t.Fatalf("Unexpected 'commit errors' parsed: %v", args)
+	}
+}
+
+func TestWriteLastFailure(t *testing.T) {
+	var buf bytes.Buffer
+	writeLastFailure(&buf, nil)
+	checkTextContains(t, buf.String(), []string{"No failed validation"})
+
+	buf.Reset()
+	writeLastFailure(&buf, &rpc.LastFailure{
+		Operation: "commit",
+		Time:      "2021-06-01T10:00:00Z",
+		Errors: []rpc.FailureError{
+			{Path: "/interfaces/dataplane/dp0s3/mtu",
+				Message:   "MTU too large",
+				Component: "net.vyatta.vci.dataplane"},
+			{Message: "Commit failed"},
+		},
+	})
+	checkTextContains(t, buf.String(), []string{
+		"Last commit failed at 2021-06-01T10:00:00Z with 2 error(s)",
+		"Path:      /interfaces/dataplane/dp0s3/mtu",
+		"Component: net.vyatta.vci.dataplane",
+		"MTU too large",
+		"Commit failed",
+	})
+}
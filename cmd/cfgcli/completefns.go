@@ -15,6 +15,7 @@ import (
 	"strconv"
 	"strings"
 	"text/tabwriter"
+	"time"
 	"unicode/utf8"
 
 	"github.com/danos/configd/common"
@@ -48,6 +49,14 @@ type Ctx struct {
 	Print    bool
 	Client   cfgManager
 	All      bool
+	//Format is the table format requested for 'show ... format <fmt>'
+	Format string
+	//Components is set for 'show -components'
+	Components bool
+	//Audit is set for 'show -audit'
+	Audit bool
+	//CommitErrors is set for 'show commit errors'
+	CommitErrors bool
 
 	HasLoadKey         bool
 	HasConfigMgmt      bool
@@ -55,12 +64,7 @@ type Ctx struct {
 }
 
 func checkRoutingInstance(c cfgManager) bool {
-	feats, err := c.GetConfigSystemFeatures()
-	if err != nil {
-		return false
-	}
-	_, exists := feats[common.RoutingInstanceFeature]
-	return exists
+	return checkFeature(c, common.RoutingInstanceFeature)
 }
 
 func getcompletions(c completer, args []string) map[string]string {
@@ -112,7 +116,8 @@ func gettypeprefix(c typeGetter, args []string) string {
 
 func fromschema(cmd string) bool {
 	switch cmd {
-	case "delete", "show", "comment", "activate", "deactivate":
+	case "delete", "show", "comment", "activate", "deactivate",
+		"rename", "copy":
 		return false
 	default:
 		return true
@@ -540,6 +545,12 @@ func rollbackComp(ctx *Ctx) (completionText string) {
 			"<Enter>": "Execute the current command",
 			"comment": "Comment for commit log",
 		}
+		// Peek into the revision so the user can see what they are
+		// rolling back to.
+		nodes, err := ctx.Client.GetConfigRevisionNodes(ctx.Args[1])
+		if err == nil && len(nodes) > 0 {
+			m["<Nodes>"] = "Revision contains: " + strings.Join(nodes, " ")
+		}
 	case 3: // comment argument
 		m = map[string]string{
 			"<text>": "Comment for commit log",
@@ -791,6 +802,8 @@ func removeTrailingEmptyArgument(args []string) []string {
 }
 
 // Command format is: commit-confirm <timeout> [comment <comment>]
+//               or: commit-confirm status
+//               or: commit-confirm extend <timeout>
 func commitConfValid(ctx *Ctx) error {
 	if len(ctx.Args) == 1 {
 		return fmt.Errorf("Timeout must be specified for commit-confirm")
@@ -798,6 +811,35 @@ func commitConfValid(ctx *Ctx) error {
 
 	args := removeTrailingEmptyArgument(ctx.Args)
 
+	if len(ctx.Args) == 2 && ctx.Prefix == args[1] &&
+		(strings.HasPrefix("status", args[1]) ||
+			strings.HasPrefix("extend", args[1])) {
+		// Keyword still being completed
+		return nil
+	}
+
+	switch args[1] {
+	case "status":
+		if len(args) > 2 {
+			return fmt.Errorf("Invalid argument: %s", args[2])
+		}
+		return nil
+	case "extend":
+		if len(ctx.Args) == 2 {
+			return fmt.Errorf("Extension must be specified for commit-confirm extend")
+		}
+		if len(args) > 3 {
+			return fmt.Errorf("Invalid argument: %s", args[3])
+		}
+		if len(args) == 3 {
+			mins, err := strconv.Atoi(args[2])
+			if err != nil || mins <= 0 {
+				return fmt.Errorf("Invalid extension: %s", args[2])
+			}
+		}
+		return nil
+	}
+
 	if len(args) >= 2 {
 		timeout, err := strconv.Atoi(args[1])
 		if err != nil || timeout <= 0 {
@@ -810,17 +852,31 @@ func commitConfValid(ctx *Ctx) error {
 
 func commitConfComp(ctx *Ctx) (completionText string) {
 	var m map[string]string
-	switch ctx.CompCurIdx {
-	case 1:
+	var sub string
+	if len(ctx.Args) > 2 {
+		sub = ctx.Args[1]
+	}
+	switch {
+	case ctx.CompCurIdx == 1:
 		m = map[string]string{
 			"<value>": "Time (minutes) to issue 'confirm' before automatic rollback",
+			"status":  "Show the time left to confirm a pending commit",
+			"extend":  "Extend the time left to confirm a pending commit",
 		}
-	case 2:
+	case sub == "status":
+		m = defaultcomps
+	case sub == "extend" && ctx.CompCurIdx == 2:
+		m = map[string]string{
+			"<value>": "Time (minutes) to add before automatic rollback",
+		}
+	case sub == "extend":
+		m = defaultcomps
+	case ctx.CompCurIdx == 2:
 		m = map[string]string{
 			"<Enter>": "Commit working configuration subject to confirmation",
 			"comment": "Comment for commit log",
 		}
-	case 3:
+	case ctx.CompCurIdx == 3:
 		m = map[string]string{
 			"<text>": "Comment for the commit log",
 		}
@@ -830,24 +886,54 @@ func commitConfComp(ctx *Ctx) (completionText string) {
 	return doComplete(ctx, true, m, printHelp)
 }
 
+// Command format is: commit [comment <comment>]
+//               or: commit expire <duration> [comment <comment>]
 func commitValid(ctx *Ctx) error {
 	if len(ctx.Args) == 1 {
 		return nil
 	}
 
 	args := removeTrailingEmptyArgument(ctx.Args)
-	return validateCommentIfAny(args, 1, ctx.Prefix)
+	if len(ctx.Args) == 2 && ctx.Prefix == args[1] &&
+		strings.HasPrefix("expire", args[1]) {
+		// Keyword still being completed
+		return nil
+	}
+	if args[1] != "expire" {
+		return validateCommentIfAny(args, 1, ctx.Prefix)
+	}
+
+	if len(ctx.Args) == 2 {
+		return fmt.Errorf("Expiry must be specified for commit expire")
+	}
+	if len(args) >= 3 && !(len(ctx.Args) == 3 && ctx.Prefix == args[2]) {
+		if dur, err := time.ParseDuration(args[2]); err != nil || dur <= 0 {
+			return fmt.Errorf("Invalid expiry: %s", args[2])
+		}
+	}
+	return validateCommentIfAny(args, 3, ctx.Prefix)
 }
 
 func commitComp(ctx *Ctx) (completionText string) {
 	var m map[string]string
-	switch ctx.CompCurIdx {
-	case 1:
+	expire := len(ctx.Args) > 2 && ctx.Args[1] == "expire"
+	switch {
+	case ctx.CompCurIdx == 1:
 		m = map[string]string{
 			"<Enter>": "Commit working configuration",
 			"comment": "Comment for commit log",
+			"expire":  "Commit working configuration, reverting it after a time",
 		}
-	case 2:
+	case expire && ctx.CompCurIdx == 2:
+		m = map[string]string{
+			"<duration>": "Time until the changes are reverted (eg 30m, 2h)",
+		}
+	case expire && ctx.CompCurIdx == 3:
+		m = map[string]string{
+			"<Enter>": "Commit working configuration subject to expiry",
+			"comment": "Comment for commit log",
+		}
+	case expire && ctx.CompCurIdx == 4, !expire && ctx.CompCurIdx == 2:
 		m = map[string]string{
 			"<text>": "Comment for the commit log",
 		}
@@ -968,6 +1054,23 @@ func pathComp(ctx *Ctx) (completionText string) {
 	return doComplete(ctx, true, m, printPathHelp)
 }
 
+// discardComp completes the path to discard, or <Enter> to discard all
+// changes.
+func discardComp(ctx *Ctx) (completionText string) {
+	wholeSession := ctx.CompCurIdx == 1
+	epath, elen := editPathLength(ctx.Args[1:ctx.CompCurIdx])
+	ctx.Args = append(ctx.Args[0:1], ExpandPath(ctx.Client, epath)...)
+	ctx.CompCurIdx = ctx.CompCurIdx + elen
+	m := getcompletions(ctx.Client, ctx.Args)
+	if wholeSession {
+		if m == nil {
+			m = make(map[string]string)
+		}
+		m["<Enter>"] = "Discard all uncommitted changes"
+	}
+	return doComplete(ctx, true, m, printPathHelp)
+}
+
 func exitComp(ctx *Ctx) (completionText string) {
 	m := defaultcomps
 	if ctx.CompCurIdx == 1 {
@@ -1109,6 +1212,25 @@ func mergeValid(ctx *Ctx) (err error) {
 	return nil
 }
 
+func validateComp(ctx *Ctx) (completionText string) {
+	m := defaultcomps
+	if ctx.CompCurIdx == 1 {
+		m = map[string]string{
+			"<Enter>": "Validate the current set of changes",
+			"<file>":  "Validate configuration in a file on local machine",
+		}
+	}
+	return doComplete(ctx, true, m, printHelp)
+}
+
+func validateValid(ctx *Ctx) (err error) {
+	if ctx.CompCurIdx != 1 && len(ctx.Args) >= 3 {
+		return fmt.Errorf("Invalid command: %s [%s]",
+			strings.Join(ctx.Args[0:2], " "), ctx.Args[2])
+	}
+	return nil
+}
+
 func runComp(ctx *Ctx) (completionText string) {
 	//TODO(jhs): Op mode completion needs to be reconciled with the way config completion
 	//           works in order for them to be easily composable. Leaving this stub
@@ -590,6 +590,7 @@ func TestRollbackCommand(t *testing.T) {
 			cmdLine: "rollback 1 ",
 			expOutput: []string{
 				"<Enter> Execute the current command",
+				"<Nodes> Revision contains: interfaces system",
 				"comment Comment for commit log"},
 			success: true,
 		},
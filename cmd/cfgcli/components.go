@@ -0,0 +1,64 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+)
+
+// 'show -components' lists the namespace of each module in the schema with
+// the model owning it and the state of the service behind that model, so
+// configuration that nothing applies can be tracked down.  Unowned
+// namespaces are listed first as they are the usual reason for asking.
+
+// parseShowComponents - strip a lone '-components' flag from the show
+// command, returning whether it was given.
+func parseShowComponents(args []string) ([]string, bool) {
+	if len(args) == 2 && args[1] == "-components" {
+		return args[:1], true
+	}
+	return args, false
+}
+
+func writeComponentMappings(w io.Writer, maps map[string]map[string]string) {
+	nss := make([]string, 0, len(maps))
+	for ns := range maps {
+		nss = append(nss, ns)
+	}
+	sort.Slice(nss, func(i, j int) bool {
+		iu := maps[nss[i]]["status"] == "unowned"
+		ju := maps[nss[j]]["status"] == "unowned"
+		if iu != ju {
+			return iu
+		}
+		return maps[nss[i]]["module"] < maps[nss[j]]["module"]
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "Module\tNamespace\tModel\tStatus")
+	for _, ns := range nss {
+		m := maps[ns]
+		model := m["model"]
+		if model == "" {
+			model = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", m["module"], ns, model,
+			m["status"])
+	}
+	tw.Flush()
+}
+
+func showComponentsRun(ctx *Ctx) {
+	maps, err := ctx.Client.GetComponentMappings()
+	handleError(err)
+	var buf bytes.Buffer
+	writeComponentMappings(&buf, maps)
+	doSnippit(ctx, fmt.Sprintf("echo -n \"%s\" | %s",
+		escapeConfig(buf.String()), pager))
+}
@@ -0,0 +1,44 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseShowComponents(t *testing.T) {
+	args, ok := parseShowComponents([]string{"show", "-components"})
+	if !ok || !reflect.DeepEqual(args, []string{"show"}) {
+		t.Fatalf("-components not parsed: %v", args)
+	}
+	args, ok = parseShowComponents([]string{"show", "interfaces"})
+	if ok || len(args) != 2 {
+		t.Fatalf("Unexpected -components parsed: %v", args)
+	}
+}
+
+func TestWriteComponentMappings(t *testing.T) {
+	var buf bytes.Buffer
+	writeComponentMappings(&buf, map[string]map[string]string{
+		"urn:a": {"module": "a", "model": "net.vyatta.a.v1",
+			"status": "active"},
+		"urn:b": {"module": "b", "status": "unowned"},
+	})
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Unexpected output:\n%s", buf.String())
+	}
+	if !strings.HasPrefix(lines[1], "b ") ||
+		!strings.Contains(lines[1], "-") ||
+		!strings.HasSuffix(lines[1], "unowned") {
+		t.Fatalf("Unowned namespace not listed first:\n%s", buf.String())
+	}
+	checkTextContains(t, lines[2], []string{"urn:a", "net.vyatta.a.v1",
+		"active"})
+}
@@ -0,0 +1,65 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Confirmed commit status
+//
+// After a commit-confirm the configuration is reverted unless confirmed in
+// time, and it is easy to lose track of how long is left.  'commit-confirm
+// status' shows the pending confirmed commit and the time remaining, and
+// 'commit-confirm extend <mins>' pushes the revert back.  So that the
+// countdown is seen without asking, the shell set up by '-action init'
+// runs '-action confirm-prompt' before each prompt, which prints a one line
+// reminder while a confirmed commit is pending and nothing otherwise.
+
+// confirmRemaining returns the time left before the pending confirmed
+// commit described by status is reverted, and false if none is pending.
+func confirmRemaining(status map[string]string) (time.Duration, bool) {
+	secs, err := strconv.Atoi(status["remaining-seconds"])
+	if err != nil {
+		return 0, false
+	}
+	return time.Duration(secs) * time.Second, true
+}
+
+func formatConfirmedCommitStatus(status map[string]string) string {
+	remaining, ok := confirmRemaining(status)
+	if !ok {
+		return "No confirmed commit pending"
+	}
+
+	msg := "Confirmed commit pending from the CLI"
+	if sid := status["session"]; sid != "" {
+		msg = "Confirmed commit pending from session " + sid
+	}
+	if id := status["persist-id"]; id != "" {
+		msg += ", persist-id " + id
+	}
+	msg += fmt.Sprintf("\nConfiguration will be reverted in %s", remaining)
+	if deadline, err := time.Parse(time.RFC3339, status["deadline"]); err == nil {
+		msg += " at " + deadline.Local().Format("15:04:05")
+	}
+	return msg + " unless confirmed"
+}
+
+func writeConfirmPrompt(w io.Writer, status map[string]string) {
+	if remaining, ok := confirmRemaining(status); ok {
+		fmt.Fprintf(w, "[commit-confirm: reverting in %s]\n", remaining)
+	}
+}
+
+func confirmPromptHandler(c cfgManager, w io.Writer) {
+	// The prompt must never fail, so errors are ignored.
+	if status, err := c.ConfirmedCommitStatus(); err == nil {
+		writeConfirmPrompt(w, status)
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestFormatConfirmedCommitStatus(t *testing.T) {
+	out := formatConfirmedCommitStatus(map[string]string{})
+	if out != "No confirmed commit pending" {
+		t.Fatalf("Unexpected status with nothing pending: %s", out)
+	}
+
+	out = formatConfirmedCommitStatus(map[string]string{
+		"session":           "1234",
+		"persist-id":        "abc",
+		"remaining-seconds": "272",
+	})
+	checkTextContains(t, out, []string{
+		"Confirmed commit pending from session 1234, persist-id abc",
+		"Configuration will be reverted in 4m32s unless confirmed",
+	})
+
+	out = formatConfirmedCommitStatus(map[string]string{
+		"remaining-seconds": "60",
+	})
+	checkTextContains(t, out, []string{
+		"Confirmed commit pending from the CLI\n",
+		"Configuration will be reverted in 1m0s unless confirmed",
+	})
+}
+
+func TestConfirmPrompt(t *testing.T) {
+	tests := []struct {
+		name string
+		ret  *MockReturnParams
+		exp  string
+	}{
+		{"pending", &MockReturnParams{retMap: map[string]string{
+			"session": "1234", "remaining-seconds": "90"}},
+			"[commit-confirm: reverting in 1m30s]\n"},
+		{"nothing pending", &MockReturnParams{retMap: map[string]string{}},
+			""},
+		{"error", &MockReturnParams{retErr: errors.New("no configd")},
+			""},
+	}
+	for _, test := range tests {
+		tc := newTestClient(t)
+		tc.AddExpectedCall(expectCall("ConfirmedCommitStatus", test.ret))
+
+		var out bytes.Buffer
+		confirmPromptHandler(tc, &out)
+		tc.CheckAllCallsMade(t)
+		if out.String() != test.exp {
+			t.Fatalf("%s: unexpected prompt.\nExp: %q\nGot: %q",
+				test.name, test.exp, out.String())
+		}
+	}
+}
+
+func TestCommitConfirmValid(t *testing.T) {
+	tests := []struct {
+		args   []string
+		expErr string
+	}{
+		{[]string{"commit-confirm", "status"}, ""},
+		{[]string{"commit-confirm", "status", "now"}, "Invalid argument: now"},
+		{[]string{"commit-confirm", "extend", "5"}, ""},
+		{[]string{"commit-confirm", "extend"},
+			"Extension must be specified"},
+		{[]string{"commit-confirm", "extend", "0"}, "Invalid extension: 0"},
+		{[]string{"commit-confirm", "10"}, ""},
+	}
+	for _, test := range tests {
+		err := commitConfValid(&Ctx{Args: test.args})
+		switch {
+		case test.expErr == "" && err != nil:
+			t.Fatalf("%v: unexpected error: %s", test.args, err)
+		case test.expErr != "" && err == nil:
+			t.Fatalf("%v: unexpected success", test.args)
+		case test.expErr != "":
+			checkTextContains(t, err.Error(), []string{test.expErr})
+		}
+	}
+}
@@ -0,0 +1,35 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// Exclusive configuration
+//
+// While a session holds the configuration exclusively, sets, deletes and
+// commits from every other session fail.  So that this is seen before
+// trying, '-action confirm-prompt' also prints a one line note while the
+// configuration is held exclusively, by this session or another.
+
+func writeExclusivePrompt(w io.Writer, status map[string]string) {
+	switch {
+	case status["session"] == "":
+	case status["held-by-session"] == "true":
+		fmt.Fprintln(w, "[configure exclusive]")
+	default:
+		fmt.Fprintf(w, "[configuration held exclusively by %s]\n",
+			status["user"])
+	}
+}
+
+func exclusivePromptHandler(c cfgManager, w io.Writer) {
+	// The prompt must never fail, so errors are ignored.
+	if status, err := c.ExclusiveStatus(); err == nil {
+		writeExclusivePrompt(w, status)
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestExclusivePrompt(t *testing.T) {
+	tests := []struct {
+		name string
+		ret  *MockReturnParams
+		exp  string
+	}{
+		{"held by session", &MockReturnParams{retMap: map[string]string{
+			"session": "1234", "user": "alice",
+			"held-by-session": "true"}},
+			"[configure exclusive]\n"},
+		{"held by another", &MockReturnParams{retMap: map[string]string{
+			"session": "5678", "user": "bob",
+			"held-by-session": "false"}},
+			"[configuration held exclusively by bob]\n"},
+		{"not held", &MockReturnParams{retMap: map[string]string{}},
+			""},
+		{"error", &MockReturnParams{retErr: errors.New("no configd")},
+			""},
+	}
+	for _, test := range tests {
+		tc := newTestClient(t)
+		tc.AddExpectedCall(expectCall("ExclusiveStatus", test.ret))
+
+		var out bytes.Buffer
+		exclusivePromptHandler(tc, &out)
+		tc.CheckAllCallsMade(t)
+		if out.String() != test.exp {
+			t.Fatalf("%s: unexpected prompt.\nExp: %q\nGot: %q",
+				test.name, test.exp, out.String())
+		}
+	}
+}
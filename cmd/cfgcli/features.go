@@ -0,0 +1,74 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package main
+
+import (
+	"os"
+	"sort"
+	"strings"
+)
+
+// The shell caches the config system features in this variable when it
+// runs 'cfgcli -action init', so that subsequent invocations need not ask
+// configd for them every time a command is run or completed.
+const cfgFeaturesEnvVar = "VYATTA_CFG_FEATURES"
+
+// featureCache wraps a cfgManager so that GetConfigSystemFeatures() is
+// answered from the environment if available, and otherwise from at most
+// one call to configd per cfgcli invocation.
+type featureCache struct {
+	cfgManager
+	feats map[string]struct{}
+}
+
+func newFeatureCache(c cfgManager) *featureCache {
+	return &featureCache{cfgManager: c}
+}
+
+func (fc *featureCache) GetConfigSystemFeatures() (map[string]struct{}, error) {
+	if fc.feats != nil {
+		return fc.feats, nil
+	}
+
+	if env, ok := os.LookupEnv(cfgFeaturesEnvVar); ok {
+		fc.feats = decodeFeatures(env)
+		return fc.feats, nil
+	}
+
+	feats, err := fc.cfgManager.GetConfigSystemFeatures()
+	if err != nil {
+		return nil, err
+	}
+	fc.feats = feats
+	return fc.feats, nil
+}
+
+func encodeFeatures(feats map[string]struct{}) string {
+	names := make([]string, 0, len(feats))
+	for name := range feats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+func decodeFeatures(env string) map[string]struct{} {
+	feats := make(map[string]struct{})
+	for _, name := range strings.Split(env, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			feats[name] = struct{}{}
+		}
+	}
+	return feats
+}
+
+func checkFeature(c cfgManager, feature string) bool {
+	feats, err := c.GetConfigSystemFeatures()
+	if err != nil {
+		return false
+	}
+	_, exists := feats[feature]
+	return exists
+}
@@ -0,0 +1,88 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/danos/configd/common"
+)
+
+type countingClient struct {
+	*testClient
+	featCalls int
+}
+
+func (cc *countingClient) GetConfigSystemFeatures() (map[string]struct{}, error) {
+	cc.featCalls++
+	return cc.testClient.GetConfigSystemFeatures()
+}
+
+func TestFeatureCacheQueriesConfigdOnce(t *testing.T) {
+	os.Unsetenv(cfgFeaturesEnvVar)
+	cc := &countingClient{
+		testClient: newTestClient(t).
+			enableFeature(common.ConfigManagementFeature).
+			enableFeature(common.LoadKeysFeature),
+	}
+	fc := newFeatureCache(cc)
+
+	if !checkConfigMgmt(fc) || !checkLoadKey(fc) || checkRoutingInstance(fc) {
+		t.Fatalf("Unexpected feature set: %v", fc.feats)
+	}
+	if cc.featCalls != 1 {
+		t.Fatalf("Expected 1 call to GetConfigSystemFeatures, got %d",
+			cc.featCalls)
+	}
+}
+
+func TestFeatureCacheUsesEnvironment(t *testing.T) {
+	os.Setenv(cfgFeaturesEnvVar, common.RoutingInstanceFeature+","+
+		common.SecretsAccessFeature)
+	defer os.Unsetenv(cfgFeaturesEnvVar)
+
+	cc := &countingClient{
+		testClient: newTestClient(t).
+			enableFeature(common.ConfigManagementFeature),
+	}
+	fc := newFeatureCache(cc)
+
+	if checkConfigMgmt(fc) || !checkRoutingInstance(fc) ||
+		!checkFeature(fc, common.SecretsAccessFeature) {
+		t.Fatalf("Unexpected feature set: %v", fc.feats)
+	}
+	if cc.featCalls != 0 {
+		t.Fatalf("Expected no calls to GetConfigSystemFeatures, got %d",
+			cc.featCalls)
+	}
+}
+
+func TestEncodeDecodeFeatures(t *testing.T) {
+	feats := map[string]struct{}{
+		common.LoadKeysFeature:         {},
+		common.ArchiveFeature:          {},
+		common.ConfigManagementFeature: {},
+	}
+
+	enc := encodeFeatures(feats)
+	if enc != "archive,config-mgmt,loadkeys" {
+		t.Fatalf("Unexpected encoding: %s", enc)
+	}
+
+	dec := decodeFeatures(enc)
+	if len(dec) != len(feats) {
+		t.Fatalf("Expected %v, got %v", feats, dec)
+	}
+	for feat := range feats {
+		if _, ok := dec[feat]; !ok {
+			t.Fatalf("Feature %s missing from %v", feat, dec)
+		}
+	}
+
+	if len(decodeFeatures("")) != 0 {
+		t.Fatalf("Empty string should decode to no features")
+	}
+}
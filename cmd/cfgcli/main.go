@@ -1,4 +1,4 @@
-// Copyright (c) 2018-2019, AT&T Intellectual Property.
+// Copyright (c) 2018-2021, AT&T Intellectual Property.
 // All rights reserved.
 //
 // Copyright (c) 2015-2017 by Brocade Communications Systems, Inc.
@@ -30,13 +30,15 @@ type cmdLineParams struct {
 	socketpath string
 	printcmd   bool
 	argsInEnv  bool
+	// continueOnError keeps a batch going after a command fails
+	continueOnError bool
 }
 
 var cliParams cmdLineParams
 
 func init() {
 	flag.StringVar(&cliParams.action, "action", "run",
-		"Action to perform [ run | complete | expand | init ]")
+		"Action to perform [ run | complete | expand | init | batch | confirm-prompt ]")
 	flag.StringVar(&cliParams.pfx, "prefix", "", "Prefix to filter")
 	flag.StringVar(&cliParams.cword, "curword", "", "Current word")
 	flag.IntVar(&cliParams.cidx, "curidx", 0, "Current word index")
@@ -50,6 +52,8 @@ func init() {
 		"Print the command that would be executed")
 	flag.BoolVar(&cliParams.argsInEnv, "args-in-env", false,
 		"Arguments to this tool are provided in the CFGCLI_ARGS environment variable")
+	flag.BoolVar(&cliParams.continueOnError, "continue-on-error", false,
+		"Run the rest of a batch after a command fails")
 }
 
 func expand(e expander, path []string) {
@@ -85,7 +89,11 @@ func run(c cfgManager, args []string, params cmdLineParams) error {
 	}
 	ctx.Args[0] = cmd.Name
 	if cmd.Name == "show" {
+		ctx.Args, ctx.Components = parseShowComponents(ctx.Args)
+		ctx.Args, ctx.Audit = parseShowAudit(ctx.Args)
+		ctx.Args, ctx.CommitErrors = parseShowCommitErrors(ctx.Args)
 		ctx.Args, ctx.All = parseShowAll(ctx)
+		ctx.Args, ctx.Format = parseShowFormat(ctx.Args)
 	}
 
 	if cmd.ValidFn != nil {
@@ -172,6 +180,22 @@ func parseShowAll(ctx *Ctx) ([]string, bool) {
 	return append(ctx.Args[0:1], showFlags.Args()...), all
 }
 
+// parseShowFormat - strip any trailing 'format <table|csv|tsv>' modifier
+// from the show command, returning the requested format.  The modifier is
+// only recognised with one of these formats so that configuration nodes
+// that happen to be called 'format' can still be shown.
+func parseShowFormat(args []string) ([]string, string) {
+	// Need at least 'show <path> format <fmt>'
+	if len(args) < 4 || args[len(args)-2] != "format" {
+		return args, ""
+	}
+	switch format := args[len(args)-1]; format {
+	case "table", "csv", "tsv":
+		return args[:len(args)-2], format
+	}
+	return args, ""
+}
+
 func isSecret(c getSetter, path string) bool {
 	tmpl, err := c.TmplGet(path)
 	handleError(err)
@@ -222,10 +246,11 @@ func argsFromEnv(data string) []string {
 
 func main() {
 	flag.Parse()
-	c, err := client.Dial("unix", cliParams.socketpath,
+	cl, err := client.Dial("unix", cliParams.socketpath,
 		os.ExpandEnv("$VYATTA_CONFIG_SID"))
-	defer c.Close()
+	defer cl.Close()
 	handleError(err)
+	c := newFeatureCache(cl)
 	err = updateDynamicCommands(c)
 	handleError(err)
 	args := flag.Args()
@@ -239,9 +264,15 @@ func main() {
 		expand(c, args)
 	case "run":
 		run_handler(c, args, cliParams)
+	case "batch":
+		batch_handler(c, cliParams)
 	case "setSecret":
 		setSecret(c, args)
+	case "confirm-prompt":
+		confirmPromptHandler(c, os.Stdout)
+		exclusivePromptHandler(c, os.Stdout)
 	case "init":
-		initShell()
+		// Bypass any cached features so the shell picks up current ones.
+		initShell(cl)
 	}
 }
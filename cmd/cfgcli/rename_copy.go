@@ -0,0 +1,106 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/danos/utils/pathutil"
+)
+
+// rename and copy take the path of a list entry, then 'to' and the new key
+// for the entry, eg
+//
+//	rename interfaces dataplane dp0s1 vif 10 to 20
+//	copy interfaces dataplane dp0s1 vif 10 to 20
+//
+// The new entry is in the same list as the original.
+
+const renameCopyUsage = "Usage: %s <path to list entry> to <new key>"
+
+// renameCopyArgs splits the arguments of rename or copy, returning the
+// path of the entry and its new key.
+func renameCopyArgs(cmd string, args []string) ([]string, string, error) {
+	if len(args) < 3 || args[len(args)-2] != "to" {
+		return nil, "", fmt.Errorf(renameCopyUsage, cmd)
+	}
+	return args[:len(args)-2], args[len(args)-1], nil
+}
+
+// renameCopy calls fn with the expanded paths of the entry given in args,
+// below the edit level, and of the entry it becomes.
+func renameCopy(
+	e expander, cmd string, args []string,
+	fn func(fpath, tpath string) error,
+) error {
+	from, key, err := renameCopyArgs(cmd, args)
+	if err != nil {
+		return err
+	}
+	fpath, err := e.Expand(pathutil.Pathstr(editPath(from)))
+	if err != nil {
+		return err
+	}
+	fps := pathutil.Makepath(fpath)
+	if len(fps) == 0 {
+		return fmt.Errorf(renameCopyUsage, cmd)
+	}
+	tps := pathutil.CopyAppend(fps[:len(fps)-1], key)
+	return fn(fpath, pathutil.Pathstr(tps))
+}
+
+func renameRun(ctx *Ctx) {
+	handleError(renameCopy(ctx.Client, "rename", ctx.Args[1:],
+		ctx.Client.Rename))
+	os.Exit(0)
+}
+
+func copyRun(ctx *Ctx) {
+	handleError(renameCopy(ctx.Client, "copy", ctx.Args[1:],
+		ctx.Client.Copy))
+	os.Exit(0)
+}
+
+// renameCopyValid only checks the path of the entry, as the new key
+// needn't exist.
+func renameCopyValid(ctx *Ctx) error {
+	args := ctx.Args[1:]
+	for i := len(args) - 2; i < len(args); i++ {
+		if i >= 0 && args[i] == "to" {
+			return nil
+		}
+	}
+	return checkValidPath(ctx)
+}
+
+// renameCopyComp completes the path of the entry, offering 'to' once it
+// is a list entry, and then the new key.
+func renameCopyComp(ctx *Ctx) (completionText string) {
+	args := ctx.Args[1:ctx.CompCurIdx]
+	switch {
+	case len(args) > 0 && args[len(args)-1] == "to":
+		m := map[string]string{"<text>": "New key for the entry"}
+		return doComplete(ctx, true, m, printHelp)
+	case len(args) > 1 && args[len(args)-2] == "to":
+		return doComplete(ctx, true, defaultcomps, printHelp)
+	}
+
+	epath, elen := editPathLength(args)
+	path := ExpandPath(ctx.Client, epath)
+	ctx.Args = append(ctx.Args[0:1], path...)
+	ctx.CompCurIdx = ctx.CompCurIdx + elen
+	m := getcompletions(ctx.Client, ctx.Args)
+	if len(path) > 0 {
+		tmpl, err := ctx.Client.TmplGet(pathutil.Pathstr(path))
+		if err == nil && isListKey(tmpl) {
+			if m == nil {
+				m = make(map[string]string)
+			}
+			m["to"] = "Give the entry a new key"
+		}
+	}
+	return doComplete(ctx, true, m, printPathHelp)
+}
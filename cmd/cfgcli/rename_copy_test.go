@@ -0,0 +1,59 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestRenameCopyArgs(t *testing.T) {
+	tests := []struct {
+		args   []string
+		expErr bool
+		from   int
+		key    string
+	}{
+		{[]string{"rules", "rule", "a", "to", "b"}, false, 3, "b"},
+		{[]string{"rules", "rule", "to", "to", "b"}, false, 3, "b"},
+		{[]string{"rules", "rule", "a", "to"}, true, 0, ""},
+		{[]string{"rules", "rule", "a", "b"}, true, 0, ""},
+		{[]string{"to", "b"}, true, 0, ""},
+	}
+	for _, test := range tests {
+		from, key, err := renameCopyArgs("rename", test.args)
+		if (err != nil) != test.expErr {
+			t.Fatalf("%v: unexpected error: %v", test.args, err)
+		}
+		if len(from) != test.from || key != test.key {
+			t.Fatalf("%v: unexpected entry %v, key %s",
+				test.args, from, key)
+		}
+	}
+}
+
+func TestRenameCopy(t *testing.T) {
+	os.Unsetenv("VYATTA_EDIT_LEVEL")
+	for _, cmd := range []string{"Rename", "Copy"} {
+		tc := newTestClient(t)
+		tc.AddExpectedCall(expectCall("Expand",
+			&MockReturnParams{retStr: "/interfaces/dataplane/dp0s1/vif/10"},
+			"/interfaces/data/dp0s1/vif/10"))
+		tc.AddExpectedCall(expectCall(cmd, nil,
+			"/interfaces/dataplane/dp0s1/vif/10",
+			"/interfaces/dataplane/dp0s1/vif/20"))
+
+		fn := tc.Rename
+		if cmd == "Copy" {
+			fn = tc.Copy
+		}
+		args := []string{"interfaces", "data", "dp0s1", "vif", "10",
+			"to", "20"}
+		if err := renameCopy(tc, "rename", args, fn); err != nil {
+			t.Fatalf("%s failed: %s", cmd, err)
+		}
+		tc.CheckAllCallsMade(t)
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"os"
 	"os/exec"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -183,6 +184,20 @@ func validateCommitCommentIfAny(
 }
 
 func commitConfRun(ctx *Ctx) {
+	switch ctx.Args[1] {
+	case "status":
+		status, err := ctx.Client.ConfirmedCommitStatus()
+		handleError(err)
+		fmt.Println(formatConfirmedCommitStatus(status))
+		os.Exit(0)
+	case "extend":
+		mins, _ := strconv.Atoi(ctx.Args[2])
+		out, err := ctx.Client.ExtendConfirmTimeout(mins)
+		handleErrorNoIndent("Extend", err)
+		fmt.Println(out)
+		os.Exit(0)
+	}
+
 	comment := validateCommitCommentIfAny(ctx, 2)
 
 	// Find timeout.  Params have been validated already.
@@ -191,6 +206,10 @@ func commitConfRun(ctx *Ctx) {
 }
 
 func commitRun(ctx *Ctx) {
+	if len(ctx.Args) > 1 && ctx.Args[1] == "expire" {
+		commitExpireRun(ctx)
+	}
+
 	comment := validateCommitCommentIfAny(ctx, 1)
 
 	confirmSilentRun(ctx)
@@ -199,6 +218,28 @@ func commitRun(ctx *Ctx) {
 	os.Exit(0)
 }
 
+// commitExpireRun commits the changes, to be reverted once the expiry,
+// which has been validated already, has passed.
+func commitExpireRun(ctx *Ctx) {
+	comment := validateCommitCommentIfAny(ctx, 3)
+
+	confirmSilentRun(ctx)
+
+	if !sessionChanged(ctx) {
+		handleError(errors.New("No configuration changes to commit"))
+	}
+	out, err := ctx.Client.CommitExpire(comment, ctx.Args[2],
+		isCommitDebugOn())
+	handleErrorNoIndent("Commit", err)
+	if out != "" {
+		doSnippitAndContinue(ctx, fmt.Sprintf("echo \"%s\"\n", out))
+	}
+
+	// commit = save ...
+	saveRunInternal(ctx, []string{})
+	os.Exit(0)
+}
+
 func isCommitDebugOn() bool {
 	return os.ExpandEnv("$COMMIT_DEBUG") != ""
 }
@@ -335,32 +376,37 @@ func deleteRun(ctx *Ctx) {
 }
 
 func discardRun(ctx *Ctx) {
-	handleError(ctx.Client.Discard())
+	if len(ctx.Args[1:]) == 0 {
+		handleError(ctx.Client.Discard())
+		os.Exit(0)
+	}
+	handleError(ctx.Client.DiscardPath(
+		expandPathString(ctx.Client, editPath(ctx.Args[1:]), handleError)))
 	os.Exit(0)
 }
 
-func doEditSnippit(ctx *Ctx, path []string) {
-	const editFmt = "export %s='%s'; export PS1='[%s]\\n\\u@\\h# ';"
-
-	var promptstr = func(path []string) string {
-		var buf = new(bytes.Buffer)
-		fmt.Fprint(buf, "edit")
-		if len(path) == 0 {
-			return buf.String()
-		}
-		for _, elem := range path {
-			fmt.Fprintf(buf, " %s", elem)
-		}
-		return buf.String()
+// envSnippit returns the shell commands exporting env, sorted by name.
+func envSnippit(env map[string]string) string {
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
 	}
-
-	pathstr := "/"
-	if len(path) > 0 {
-		pathstr = pathutil.Pathstr(path)
+	sort.Strings(names)
+	var buf = new(bytes.Buffer)
+	for _, name := range names {
+		fmt.Fprintf(buf, "export %s='%s'; ", name,
+			strings.Replace(env[name], "'", `'\''`, -1))
 	}
+	return buf.String()
+}
 
-	doSnippit(ctx,
-		fmt.Sprintf(editFmt, editenv, pathstr, promptstr(path)))
+// doEditSnippit moves the session's edit level to path, and exports the
+// level and prompt the session then gives.
+func doEditSnippit(ctx *Ctx, path []string) {
+	handleError(ctx.Client.EditSetLevel(pathutil.Pathstr(path)))
+	env, err := ctx.Client.EditGetEnv()
+	handleError(err)
+	doSnippit(ctx, envSnippit(env))
 }
 
 func isListKey(tmpl map[string]string) bool {
@@ -632,10 +678,32 @@ func escapeConfig(in string) string {
 }
 
 func showRun(ctx *Ctx) {
+	if ctx.Components {
+		showComponentsRun(ctx)
+		return
+	}
+	if ctx.Audit {
+		showAuditRun(ctx)
+		return
+	}
+	if ctx.CommitErrors {
+		showCommitErrorsRun(ctx)
+		return
+	}
 	if err := checkValidPath(ctx); err != nil {
 		handleError(err)
 	}
 	path := expandPathString(ctx.Client, editPath(ctx.Args[1:]), printError)
+	if ctx.Format != "" {
+		out, err := ctx.Client.ExportList(rpc.AUTO, path, ctx.Format,
+			map[string]interface{}{"Defaults": ctx.All, "Flatten": true})
+		handleError(err)
+		if out != "" {
+			doSnippit(ctx, fmt.Sprintf("echo -n \"%s\" | %s",
+				escapeConfig(out), pager))
+		}
+		return
+	}
 	out, err := ctx.Client.ShowConfigWithContextDiffs(path, ctx.All)
 	handleError(err)
 	if out != "" {
@@ -647,9 +715,16 @@ func showRun(ctx *Ctx) {
 	}
 }
 
+// sessionEditLevel returns the session's edit level, rather than trusting
+// the shell's copy of it.
+func sessionEditLevel(ctx *Ctx) []string {
+	env, err := ctx.Client.EditGetEnv()
+	handleError(err)
+	return pathutil.Makepath(env[editenv])
+}
+
 func topRun(ctx *Ctx) {
-	editlvl := os.Getenv(editenv)
-	if editlvl == "/" {
+	if len(sessionEditLevel(ctx)) == 0 {
 		handleError(errors.New("Already at the top level"))
 	}
 
@@ -658,13 +733,11 @@ func topRun(ctx *Ctx) {
 
 func upRun(ctx *Ctx) {
 	popnum := 1
-	editlvl := os.Getenv(editenv)
-	if editlvl == "/" {
+	path := sessionEditLevel(ctx)
+	if len(path) == 0 {
 		handleError(errors.New("Already at the top level"))
 	}
 
-	path := pathutil.Makepath(editlvl)
-
 	tmpl, err := ctx.Client.TmplGet(pathutil.Pathstr(path))
 	handleError(err)
 
@@ -678,6 +751,9 @@ func upRun(ctx *Ctx) {
 }
 
 func validateRun(ctx *Ctx) {
+	if len(ctx.Args) > 1 {
+		validateFileRun(ctx)
+	}
 	if !sessionChanged(ctx) {
 		handleError(errors.New("No configuration changes to validate"))
 	}
@@ -1,4 +1,4 @@
-// Copyright (c) 2018-2019 AT&T Intellectual Property.
+// Copyright (c) 2018-2019, 2021 AT&T Intellectual Property.
 // All rights reserved.
 // Copyright (c) 2015 by Brocade Communications Systems, Inc.
 // All rights reserved.
@@ -13,7 +13,7 @@ import (
 	"strings"
 )
 
-func initShell() {
+func initShell(c cfgManager) {
 	buf := new(bytes.Buffer)
 	fmt.Fprintln(buf, "complete -E -F vyatta_config_complete")
 	fmt.Fprintln(buf, "complete -I -F vyatta_config_default_complete")
@@ -38,5 +38,19 @@ func initShell() {
 		fmt.Fprintf(buf, "alias %s='vyatta_cfg_run %[1]s'\n", k)
 	}
 	fmt.Fprintln(buf, "shopt -s histverify")
+	if checkConfigMgmt(c) {
+		// Countdown to the revert of a pending confirmed commit
+		fmt.Fprintln(buf, "[[ $PROMPT_COMMAND == *confirm-prompt* ]] || "+
+			"PROMPT_COMMAND=\"cfgcli -action confirm-prompt"+
+			"${PROMPT_COMMAND:+; $PROMPT_COMMAND}\"")
+	}
+	// Start at the session's edit level, with its prompt
+	if env, err := c.EditGetEnv(); err == nil {
+		fmt.Fprintln(buf, envSnippit(env))
+	}
+	if feats, err := c.GetConfigSystemFeatures(); err == nil {
+		fmt.Fprintf(buf, "export %s='%s'\n",
+			cfgFeaturesEnvVar, encodeFeatures(feats))
+	}
 	fmt.Printf("%s", buf)
 }
@@ -0,0 +1,146 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/danos/mgmterror"
+	"github.com/danos/utils/pathutil"
+)
+
+// validate <file>
+//
+// Validates a configuration file, in curly, json, rfc7951 or xml format,
+// without touching the candidate: configd loads it into a scratch session
+// and runs full validation there.  Errors only carry the configuration path
+// they relate to, so each is mapped back to a line of the file by finding
+// the elements of its path, in turn, further down the file.
+
+var moduleQualifiedMember = regexp.MustCompile(
+	`"[A-Za-z_][A-Za-z0-9_.-]*:[A-Za-z_][A-Za-z0-9_.-]*"\s*:`)
+
+// validateFileEncoding guesses the encoding of the file from its name,
+// or failing that its content.
+func validateFileEncoding(name, content string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".xml":
+		return "xml"
+	case ".json":
+		return jsonEncoding(content)
+	}
+	switch trimmed := strings.TrimSpace(content); {
+	case strings.HasPrefix(trimmed, "<"):
+		return "xml"
+	case strings.HasPrefix(trimmed, "{"):
+		return jsonEncoding(content)
+	}
+	return "curly"
+}
+
+func jsonEncoding(content string) string {
+	if moduleQualifiedMember.MatchString(content) {
+		return "rfc7951"
+	}
+	return "json"
+}
+
+func isNameChar(c byte) bool {
+	return c == '-' || c == '_' ||
+		(c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') ||
+		(c >= 'A' && c <= 'Z')
+}
+
+// lineHasElement returns true if elem appears in line other than as part of
+// a longer name or value.
+func lineHasElement(line, elem string) bool {
+	for off := 0; off < len(line); {
+		i := strings.Index(line[off:], elem)
+		if i < 0 {
+			return false
+		}
+		start, end := off+i, off+i+len(elem)
+		if (start == 0 || !isNameChar(line[start-1])) &&
+			(end == len(line) || !isNameChar(line[end])) {
+			return true
+		}
+		off = start + 1
+	}
+	return false
+}
+
+// errorLine returns the line of the file the path relates to, or 0 if it
+// can't be found.
+func errorLine(lines []string, path []string) int {
+	var line, from int
+	for _, elem := range path {
+		found := false
+		for i := from; i < len(lines); i++ {
+			if lineHasElement(lines[i], elem) {
+				line, from, found = i+1, i, true
+				break
+			}
+		}
+		if !found {
+			break
+		}
+	}
+	return line
+}
+
+func formatValidateFileErrors(file, content string, err error) string {
+	merr, ok := err.(mgmterror.MgmtErrorList)
+	if !ok {
+		return err.Error()
+	}
+	lines := strings.Split(content, "\n")
+	var b bytes.Buffer
+	for _, e := range merr.Errors() {
+		me, ok := e.(mgmterror.Formattable)
+		if !ok {
+			fmt.Fprintf(&b, "%s: %s\n\n", file, e)
+			continue
+		}
+		path := pathutil.Makepath(me.GetPath())
+		if line := errorLine(lines, path); line != 0 {
+			fmt.Fprintf(&b, "%s line %d: ", file, line)
+		} else {
+			fmt.Fprintf(&b, "%s: ", file)
+		}
+		fmt.Fprintf(&b, "[%s]\n\n%s\n\n", strings.Join(path, " "),
+			me.GetMessage())
+	}
+	return b.String()
+}
+
+func validateFileRun(ctx *Ctx) {
+	file := ctx.Args[1]
+	buf, err := ioutil.ReadFile(file)
+	handleError(err)
+	content := string(buf)
+
+	encoding := validateFileEncoding(file, content)
+	config := content
+	if encoding == "xml" && !strings.Contains(content, "<config") {
+		config = "<config>" + content + "</config>"
+	}
+
+	out, err := ctx.Client.ValidateConfig(encoding, config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\n%s\nValidate failed!\n\n",
+			formatValidateFileErrors(file, content, err))
+		os.Exit(1)
+	}
+	if out != "" {
+		doSnippit(ctx, fmt.Sprintf("echo \"%s\"\n", out))
+	}
+	os.Exit(0)
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateFileEncoding(t *testing.T) {
+	tests := []struct {
+		name, content, exp string
+	}{
+		{"config.boot", "interfaces {\n}\n", "curly"},
+		{"config.xml", "interfaces", "xml"},
+		{"config", "  <config></config>", "xml"},
+		{"config.json", `{"interfaces": {}}`, "json"},
+		{"config", `{"vyatta-interfaces-v1:interfaces": {}}`, "rfc7951"},
+	}
+	for _, test := range tests {
+		if act := validateFileEncoding(test.name, test.content); act != test.exp {
+			t.Errorf("%s: expected %s, got %s", test.name, test.exp, act)
+		}
+	}
+}
+
+const validateFileCurly = `musts {
+	val2 foo-bar
+	val1 foo
+}
+other {
+	val1 foo
+}`
+
+func TestErrorLine(t *testing.T) {
+	lines := strings.Split(validateFileCurly, "\n")
+	tests := []struct {
+		path []string
+		exp  int
+	}{
+		{[]string{"musts", "val1", "foo"}, 3},
+		{[]string{"other", "val1", "foo"}, 6},
+		{[]string{"musts", "val2", "foo-bar"}, 2},
+		// Only the part of the path that is found is used.
+		{[]string{"other", "val3"}, 5},
+		{[]string{"missing"}, 0},
+	}
+	for _, test := range tests {
+		if act := errorLine(lines, test.path); act != test.exp {
+			t.Errorf("%v: expected line %d, got %d",
+				test.path, test.exp, act)
+		}
+	}
+}
@@ -8,33 +8,170 @@
 configd is a daemon that manages run-time configuration based on YANG definition files.
 
 Usage:
+	-approval-hook=<url>
+		Ask the given URL to approve each commit before it is applied,
+		for change-approval workflows.  The commit is POSTed to it as
+		JSON, and only proceeds if the reply approves it (see
+		server/commit_approval.go).
+
+	-concurrent-reads=<n>
+		How many reads, such as Exists and TreeGetFull, each client
+		connection may have running at once, for clients that pipeline
+		requests (default: 4).
+
+	-configdir=<dir>
+		Directory holding the saved configuration, its archive and any
+		confirmed commit job (default: /config).
+
+	-container
+		Run in a containerized deployment, where user and group databases may
+		not be available: the socket is not chowned, so access relies on the
+		permissions of the socket directory, and clients whose user cannot be
+		looked up are identified by uid alone.
+
 	-cpuprofile=<filename>
 		Defines a file which to write a cpu profile that can be parsed with go pprof.
 		When defined, the daemon will begin recording cpu profile information when it
 		receives a SIGUSR1 signal. Then on a subsequent SIGUSR1 it will write the profile
 		information to the defined file.
 
+	-eventlog=<syslog|unix:<socket>|filename>
+		Write session lifecycle events (sessions created, destroyed, locked
+		and unlocked, commits started and finished, validation failures) as
+		JSON Lines to syslog, a unix socket, or the defined file.
+
+	-gnmi-listen=<address>
+		Serve gNMI over gRPC on the given address as well as serving the
+		unix socket.  Clients must present a certificate signed by the CA
+		given by -gnmi-tls-ca, whose common name is the user they act as.
+
+	-gnmi-tls-ca=<filename>, -gnmi-tls-cert=<filename>, -gnmi-tls-key=<filename>
+		The CA for gNMI client certificates, and the certificate and key
+		gNMI is served with.
+
+	-gid=<gid>
+		Use the given gid for the socket group rather than looking up -group.
+
+	-instance=<name>
+		Name of this instance, where several configd instances run on one
+		host.  The name tags the instance's logs and events, and the runtime
+		files not given explicitly (pidfile, runfile, socketfile, sessiondir,
+		profiles) are kept in /run/configd/<name> rather than /run/configd.
+		Each instance needs its own -yangdir and, usually, -configdir.
+
 	-logfile=<filename>
 		When defined configd will redirect its stdout and stderr to the defined file.
 
+	-log-format=<logfmt|json>
+		Format of the structured log messages written to stderr, whose
+		level is set for each subsystem with cfgdbg or SetConfigDebug
+		(default: logfmt).
+
+	-max-user-connections=<n>
+		Refuse further connections from a user already holding the given
+		number (default: 0, no limit).
+
+	-max-user-requests=<n>
+		Refuse requests from a user already running the given number at
+		once across their connections (default: 0, no limit).
+
+	-profiledir=<dir>
+		Directory to which profiles started with the StartProfile API are
+		written (default: /run/configd/profiles).
+
+	-persist-sessions
+		Keep CLI sessions with uncommitted changes, or locked, in the session
+		directory and restore them when configd starts, so the changes
+		survive a restart of the daemon.
+
+	-trace-commit
+		Record, and log, the hooks, components and scripts each commit runs,
+		in the order they are run, for use by tests checking that order.
+
 	-pidfile=<filename>
 		Sepecify file for the daemon to write pid in (default: /run/configd/configd.pid).
 
+	-redaction=<secrets-group|strict>
+		Which callers may see the values of nodes marked as secret: members
+		of the secrets group, or only configd itself (default: secrets-group).
+
+	-revalidate-interval=<minutes>
+		Revalidate the running configuration against the current schema
+		every given number of minutes, raising an alarm if it is no longer
+		valid (default: 0, never).
+
+	-rpc-output-validation=<none|warn|fail>
+		How to handle RPC output from components that does not match the YANG
+		output schema: ignore it, log a warning, or fail the RPC (default: warn).
+
 	-runfile=<filename>
 		Sepecify file for the daemon to write running configuration into (default:
 		/run/configd/running.config).
 
+	-script-timeout=<seconds>
+		Abort a commit whose validation, or commit hooks, take longer than
+		the given number of seconds, and fail a set whose configd:subst
+		scripts do (default: 0, no limit).
+
+	-secret-key=<file:path|keyring:description>
+		Encrypt the values of configd:secret leaves in the running,
+		saved and archived configuration files with the 32 byte key,
+		raw or in hex, read from the given file or kernel keyring user
+		key.  Files are decrypted as they are read (default: none, secrets
+		are stored in plain text).
+
+	-shadow-peer=<socket>
+		Validate the configuration resulting from each commit on the
+		configd listening on the given socket, typically the backup of an
+		HA pair, and add the result to the commit output.  The peer's
+		configuration is left unchanged.
+
+	-sessiondir=<dir>
+		Directory in which configd maintains a file per CLI session reflecting
+		whether the session is edited and/or locked, for use by the shell
+		prompt (default: /run/configd/sessions).
+
 	-socketfile=<filename>
 		When defined configd will write its pid to the defined file (defualt:
 		/run/configd/main.sock).
 
+	-standby-peer=<socket>
+		Replicate each commit to the warm standby configd listening on the
+		given socket, which keeps it in its archive for failover.
+
+	-state-cache-ttl=<seconds>
+		Cache the output of each node's configd:get-state scripts for the
+		given number of seconds, so frequent pollers don't rerun slow
+		scripts (default: 0, no cache).
+
+	-state-script-timeout=<seconds>
+		Leave a node without state, and warn, if its configd:get-state
+		scripts take longer than the given number of seconds (default: 0,
+		no limit).
+
+	-uid=<uid>
+		Use the given uid for the configd user rather than looking up -user.
+
 	-yangdir=<dir>
-		Directory configd will load YANG files and watch for updates (default:
-		/usr/share/configd/yang).
+		Directory configd will load YANG files from (default:
+		/usr/share/configd/yang).  They are reloaded on SIGHUP.
+
+	yangtest <dir>
+		Rather than running the daemon, compile the YANG files in <dir> and
+		run the *.yangtest files found there against an in-process session,
+		reporting the results.  See yangtest.go for the test file format.
 
 	SIGUSR1
 		Issuing SIGUSR1 to the daemon will toggle run-time profiling. Profile data will
-		be written to the file specified by the cpuprofile option.
+		be written to the file specified by the cpuprofile option.  Prefer the
+		StartProfile and StopProfile APIs, which can't clash with other users of
+		the signal.
+
+	SIGHUP
+		Issuing SIGHUP to the daemon recompiles the YANG files in -yangdir and
+		moves configd onto the new schema without a restart, as the
+		ReloadSchema API does.  Sessions whose configuration isn't valid with
+		the new schema are logged and keep the old one.
 
 */
 package main
@@ -48,9 +185,9 @@ import (
 	"os"
 	"os/signal"
 	"os/user"
+	"path/filepath"
 	"runtime"
 	"runtime/debug"
-	"runtime/pprof"
 	"strconv"
 	"syscall"
 	"time"
@@ -58,7 +195,9 @@ import (
 	"github.com/coreos/go-systemd/activation"
 	"github.com/danos/config/schema"
 	"github.com/danos/configd"
+	"github.com/danos/configd/common"
 	"github.com/danos/configd/server"
+	"github.com/danos/configd/session"
 	"github.com/danos/utils/os/group"
 	"github.com/danos/vci"
 	"github.com/danos/vci/conf"
@@ -72,8 +211,6 @@ const (
 )
 
 var basepath string = "/run/configd"
-var runningprof bool
-var cpuproffile os.File
 var elog *log.Logger
 
 /* Command line options */
@@ -84,10 +221,78 @@ var cpuprofile *string = flag.String("cpuprofile",
 var memprofile = flag.String("memprofile", basepath+"/configd_mem.pprof",
 	"Write memory profile to specified file on SIGUSR2")
 
+var approvalhook *string = flag.String("approval-hook",
+	"",
+	"URL asked to approve each commit")
+
+var shadowpeer *string = flag.String("shadow-peer",
+	"",
+	"Socket of a peer configd each commit is also validated on")
+
+var standbypeer *string = flag.String("standby-peer",
+	"",
+	"Socket of a warm standby configd each commit is replicated to")
+
+var revalidateinterval *int = flag.Int("revalidate-interval",
+	0,
+	"Minutes between background revalidations of running, 0 for none")
+
+var concurrentreads *int = flag.Int("concurrent-reads",
+	0,
+	"Reads each client connection may run at once, 0 for the default")
+
+var statecachettl *int = flag.Int("state-cache-ttl",
+	0,
+	"Seconds the output of get-state scripts is cached, 0 for none")
+
+var statescripttimeout *int = flag.Int("state-script-timeout",
+	0,
+	"Seconds the get-state scripts of a node may take, 0 for no limit")
+
+var scripttimeout *int = flag.Int("script-timeout",
+	0,
+	"Seconds validation, commit hooks and subst scripts may take, 0 for no limit")
+
+var secretkey *string = flag.String("secret-key",
+	"",
+	"Where the key secrets are encrypted at rest with is read from, <file:path|keyring:description>")
+
+var maxuserconnections *int = flag.Int("max-user-connections",
+	0,
+	"Connections each user may hold, 0 for no limit")
+
+var maxuserrequests *int = flag.Int("max-user-requests",
+	0,
+	"Requests each user may run at once, 0 for no limit")
+
+var persistsessions *bool = flag.Bool("persist-sessions",
+	false,
+	"Keep CLI sessions in the session directory across restarts")
+
+var tracecommit *bool = flag.Bool("trace-commit",
+	false,
+	"Record what each commit runs, in order")
+
+var configdir *string = flag.String("configdir",
+	"/config",
+	"Directory holding the saved configuration and archive")
+
+var instance *string = flag.String("instance",
+	"",
+	"Name distinguishing this instance from others on the host")
+
+var profiledir *string = flag.String("profiledir",
+	basepath+"/profiles",
+	"Directory for profiles started through the API")
+
 var logfile *string = flag.String("logfile",
 	"",
 	"Redirect std{out,err} to supplied file.")
 
+var logformat *string = flag.String("log-format",
+	"logfmt",
+	"Format of structured log messages <logfmt|json>")
+
 var pidfile *string = flag.String("pidfile",
 	basepath+"/configd.pid",
 	"Write pid to supplied file.")
@@ -112,6 +317,38 @@ var groupname *string = flag.String("group",
 	"configd",
 	"Group that owns the socket")
 
+var staticuid *int = flag.Int("uid",
+	-1,
+	"Static uid of the configd user, rather than looking up -user")
+
+var staticgid *int = flag.Int("gid",
+	-1,
+	"Static gid of the socket group, rather than looking up -group")
+
+var container *bool = flag.Bool("container",
+	false,
+	"Containerized deployment; don't chown the socket")
+
+var eventlog *string = flag.String("eventlog",
+	"",
+	"Write session events as JSON Lines to <syslog|unix:<socket>|file>")
+
+var gnmilisten *string = flag.String("gnmi-listen",
+	"",
+	"Serve gNMI on the given address")
+
+var gnmitlsca *string = flag.String("gnmi-tls-ca",
+	"",
+	"CA for gNMI client certificates")
+
+var gnmitlscert *string = flag.String("gnmi-tls-cert",
+	"",
+	"Certificate gNMI is served with")
+
+var gnmitlskey *string = flag.String("gnmi-tls-key",
+	"",
+	"Key for the certificate gNMI is served with")
+
 var runfile *string = flag.String("runfile",
 	basepath+"/running.config",
 	"File to store current running config into incase of restart")
@@ -124,6 +361,18 @@ var supergroup *string = flag.String("supergroup",
 	"",
 	"Group that is permitted access to all sessions")
 
+var sessiondir *string = flag.String("sessiondir",
+	basepath+"/sessions",
+	"Directory for per-session activity files used by the shell prompt")
+
+var redaction *string = flag.String("redaction",
+	configd.SecretsGroupRedaction,
+	"Which callers may see secrets <secrets-group|strict>")
+
+var rpcoutputvalidation *string = flag.String("rpc-output-validation",
+	"warn",
+	"Handling of invalid RPC output from components <none|warn|fail>")
+
 var capabilities *string = flag.String("capabilities",
 	compile.DefaultCapsLocation,
 	"File specifying system capabilities")
@@ -136,25 +385,97 @@ func sigstartprof() {
 		sig := <-sigch
 		switch sig {
 		case syscall.SIGUSR1:
-			if !runningprof {
-				cpuproffile, err := os.Create(*cpuprofile)
-				if err != nil {
-					log.Fatal(err)
-				}
-				pprof.StartCPUProfile(cpuproffile)
-				runningprof = true
+			// Go through the server so as not to clash with profiles
+			// started through the API.
+			var err error
+			if server.ProfileRunning(server.ProfileCPU) {
+				_, err = server.StopProfile(server.ProfileCPU)
 			} else {
-				pprof.StopCPUProfile()
-				cpuproffile.Close()
-				runningprof = false
+				err = server.StartProfileToFile(server.ProfileCPU,
+					*cpuprofile)
+			}
+			if err != nil {
+				elog.Println(err)
 			}
 		case syscall.SIGUSR2:
-			f, err := os.Create(*memprofile)
+			err := server.StartProfileToFile(server.ProfileHeap,
+				*memprofile)
 			if err != nil {
-				log.Fatal(err)
+				elog.Println(err)
 			}
-			pprof.WriteHeapProfile(f)
-			f.Close()
+		}
+	}
+}
+
+// Runtime files live under basepath unless given explicitly, so each named
+// instance gets its own.
+var basepathFlags = []string{
+	"cpuprofile", "memprofile", "profiledir", "pidfile", "socketfile",
+	"runfile", "sessiondir",
+}
+
+func applyInstanceBasepath() {
+	if *instance == "" {
+		return
+	}
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	instpath := filepath.Join(basepath, *instance)
+	for _, name := range basepathFlags {
+		if set[name] {
+			continue
+		}
+		f := flag.Lookup(name)
+		rel, err := filepath.Rel(basepath, f.Value.String())
+		if err != nil {
+			continue
+		}
+		f.Value.Set(filepath.Join(instpath, rel))
+	}
+	basepath = instpath
+}
+
+// schemaLoader recompiles the schema, with the current component
+// configuration, for reload.
+type schemaLoader struct {
+	comp     vci.Component
+	st       schema.ModelSet
+	stFull   schema.ModelSet
+	mappings *schema.ComponentMappings
+}
+
+func (l *schemaLoader) Load() (
+	schema.ModelSet, schema.ModelSet, schema.ComponentManager, error,
+) {
+	compConfig, err := conf.LoadComponentConfigDir(*compdir)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	l.st, l.stFull, l.mappings, err = compileSchema(
+		VyattaV1ModelSet, compConfig)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	compMgr := schema.NewCompMgr(
+		newConfigdOpsMgr(l.comp),
+		services.NewManager(),
+		l.stFull,
+		l.mappings)
+	return l.st, l.stFull, compMgr, nil
+}
+
+func (l *schemaLoader) Loaded() {
+	runningYangd.update(l.st, l.stFull, l.mappings)
+}
+
+func sigreloadschema(srv *server.Srv) {
+	sigch := make(chan os.Signal, 1)
+	signal.Notify(sigch, syscall.SIGHUP)
+	for range sigch {
+		// Sessions left on the old schema are logged as they are found.
+		if _, err := srv.ReloadSchema(); err != nil {
+			elog.Printf("Schema reload failed: %s", err)
 		}
 	}
 }
@@ -195,21 +516,26 @@ func writePid() {
 }
 
 func getIds(username, groupname string) (uid, gid int) {
-	u, err := user.Lookup(username)
-	if err != nil {
-		uid = 0
-	} else {
+	if *staticuid >= 0 {
+		uid = *staticuid
+	} else if u, err := user.Lookup(username); err == nil {
 		uid, _ = strconv.Atoi(u.Uid)
 	}
-	g, err := group.Lookup(groupname)
-	if err != nil {
-		gid = 0
-	} else {
+	if *staticgid >= 0 {
+		gid = *staticgid
+	} else if g, err := group.Lookup(groupname); err == nil {
 		gid = int(g.Gid)
 	}
 	return uid, gid
 }
 
+func staticUserId() string {
+	if *staticuid < 0 {
+		return ""
+	}
+	return strconv.Itoa(*staticuid)
+}
+
 func initialiseLogging() {
 	var err error
 
@@ -222,7 +548,8 @@ func initialiseLogging() {
 		//rsyslog may not be up even though it returns to the init system so we
 		//have to do this mess to ensure that logging works.
 		for i := 0; i < 5; i++ {
-			elog, err = configd.NewLogger(syslog.LOG_ERR|syslog.LOG_DAEMON, 0)
+			elog, err = configd.NewInstanceLogger(
+				syslog.LOG_ERR|syslog.LOG_DAEMON, 0, *instance)
 
 			if err == nil {
 				break
@@ -254,9 +581,11 @@ func getListeners() net.Listener {
 		err = os.Chmod(*socket, 0777)
 		fatal(err)
 
-		uid, gid := getIds(*username, *groupname)
-		err = os.Chown(*socket, uid, gid)
-		fatal(err)
+		if !*container {
+			uid, gid := getIds(*username, *groupname)
+			err = os.Chown(*socket, uid, gid)
+			fatal(err)
+		}
 
 		listeners = append(listeners, l)
 	}
@@ -286,7 +615,8 @@ func (com *configdOpsMgr) SetConfigForModel(
 			"Must dial client for %s before calling SetConfigForModel.",
 			modelName)
 	}
-	return com.client.SetConfigForModel(modelName, object)
+	return common.FormatComponentError(modelName,
+		com.client.SetConfigForModel(modelName, object))
 }
 
 func (com *configdOpsMgr) CheckConfigForModel(
@@ -298,7 +628,8 @@ func (com *configdOpsMgr) CheckConfigForModel(
 			"Must dial client for %s before calling CheckConfigForModel.",
 			modelName)
 	}
-	return com.client.CheckConfigForModel(modelName, object)
+	return common.FormatComponentError(modelName,
+		com.client.CheckConfigForModel(modelName, object))
 }
 
 func (com *configdOpsMgr) StoreConfigByModelInto(
@@ -334,9 +665,31 @@ func main() {
 	}
 	flag.Parse()
 
+	if flag.Arg(0) == "yangtest" {
+		os.Exit(runYangTest(flag.Args()[1:], os.Stdout))
+	}
+
+	applyInstanceBasepath()
 	initialiseLogging()
+	fatal(common.SetLogFormat(*logformat))
 
 	fatal(os.MkdirAll(basepath, 0755))
+	fatal(os.MkdirAll(*sessiondir, 0755))
+
+	if !server.IsRpcOutputValidationMode(*rpcoutputvalidation) {
+		fatal(fmt.Errorf("Invalid rpc-output-validation mode: %s",
+			*rpcoutputvalidation))
+	}
+
+	if !configd.IsRedactionMode(*redaction) {
+		fatal(fmt.Errorf("Invalid redaction mode: %s", *redaction))
+	}
+
+	if *eventlog != "" {
+		if err := session.OpenEventLog(*eventlog); err != nil {
+			elog.Printf("Unable to open event log %s: %s", *eventlog, err)
+		}
+	}
 
 	go sigstartprof()
 
@@ -351,15 +704,41 @@ func main() {
 	l := getListeners()
 
 	config := &configd.Config{
-		User:         *username,
-		Runfile:      *runfile,
-		Logfile:      *logfile,
-		Pidfile:      *pidfile,
-		Yangdir:      *yangdir,
-		Socket:       *socket,
-		SecretsGroup: *secretsgroup,
-		SuperGroup:   *supergroup,
-		Capabilities: *capabilities,
+		User:                *username,
+		UserId:              staticUserId(),
+		Runfile:             *runfile,
+		Logfile:             *logfile,
+		Pidfile:             *pidfile,
+		Yangdir:             *yangdir,
+		Socket:              *socket,
+		SecretsGroup:        *secretsgroup,
+		SuperGroup:          *supergroup,
+		Capabilities:        *capabilities,
+		SessionActivityDir:  *sessiondir,
+		RpcOutputValidation: *rpcoutputvalidation,
+		Redaction:           *redaction,
+		Container:           *container,
+		ProfileDir:          *profiledir,
+		ConfigDir:           *configdir,
+		Instance:            *instance,
+		ApprovalHook:        *approvalhook,
+		ShadowPeer:          *shadowpeer,
+		StandbyPeer:         *standbypeer,
+		PersistSessions:     *persistsessions,
+		TraceCommit:         *tracecommit,
+		RevalidateInterval:  *revalidateinterval,
+		ConcurrentReads:     *concurrentreads,
+		StateCacheTTL:       *statecachettl,
+		StateScriptTimeout:  *statescripttimeout,
+		ScriptTimeout:       *scripttimeout,
+		MaxUserConnections:  *maxuserconnections,
+		MaxUserRequests:     *maxuserrequests,
+	}
+
+	if *secretkey != "" {
+		key, err := session.ReadSecretKey(*secretkey)
+		fatal(err)
+		fatal(session.SetSecretKey(key))
 	}
 
 	compMgr := schema.NewCompMgr(
@@ -370,6 +749,20 @@ func main() {
 
 	srv := server.NewSrv(l.(*net.UnixListener), st, stFull, *username,
 		config, elog, compMgr)
+	srv.SetSchemaLoader(&schemaLoader{comp: comp})
+	go sigreloadschema(srv)
+
+	if *gnmilisten != "" {
+		go func() {
+			err := srv.ServeGnmi(&server.GnmiConfig{
+				Listen: *gnmilisten,
+				Cert:   *gnmitlscert,
+				Key:    *gnmitlskey,
+				CA:     *gnmitlsca,
+			})
+			elog.Printf("gNMI server stopped: %s", err)
+		}()
+	}
 
 	writePid()
 
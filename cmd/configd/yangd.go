@@ -12,6 +12,7 @@ package main
 import (
 	"fmt"
 	"log"
+	"sync"
 
 	"github.com/danos/config/schema"
 	"github.com/danos/config/yangconfig"
@@ -34,6 +35,7 @@ type Yangd interface {
 
 // Object implementing yangd methods
 type yangd struct {
+	mu       sync.RWMutex // Guards the schema, which may be reloaded
 	st       schema.ModelSet
 	stFull   schema.ModelSet
 	mappings *schema.ComponentMappings
@@ -50,9 +52,31 @@ func NewYangd(
 	}
 }
 
+func (y *yangd) models() schema.ModelSet {
+	y.mu.RLock()
+	defer y.mu.RUnlock()
+	return y.st
+}
+
+func (y *yangd) componentMappings() *schema.ComponentMappings {
+	y.mu.RLock()
+	defer y.mu.RUnlock()
+	return y.mappings
+}
+
+// update moves yangd onto a reloaded schema.
+func (y *yangd) update(
+	st, stFull schema.ModelSet,
+	mappings *schema.ComponentMappings,
+) {
+	y.mu.Lock()
+	defer y.mu.Unlock()
+	y.st, y.stFull, y.mappings = st, stFull, mappings
+}
+
 // Lookup the Yang module-name for a Yang namespace
 func (y *yangd) getModuleName(namespace string) string {
-	for name, module := range y.st.Modules() {
+	for name, module := range y.models().Modules() {
 		if namespace == module.Namespace() {
 			return name
 		}
@@ -74,7 +98,7 @@ func (y *yangd) findRpc(
 func (y *yangd) findRpcByModuleName(
 	moduleName, name string,
 ) (schema.Rpc, bool, error) {
-	mod, ok := y.st.Modules()[moduleName]
+	mod, ok := y.models().Modules()[moduleName]
 	if !ok {
 		return nil, false, fmt.Errorf(
 			"Unable to find RPC '%s' for module '%s'",
@@ -87,7 +111,7 @@ func (y *yangd) findRpcByNamespace(
 	namespace, name string,
 ) (schema.Rpc, bool, error) {
 
-	allrpcs := y.st.Rpcs()
+	allrpcs := y.models().Rpcs()
 	mod_rpcs, ok := allrpcs[namespace]
 	if !ok {
 		return nil, false,
@@ -349,7 +373,7 @@ func (y *yangd) findNotification(
 func (y *yangd) findNotificationByModuleName(
 	moduleName, name string,
 ) (schema.Notification, bool, error) {
-	mod, ok := y.st.Modules()[moduleName]
+	mod, ok := y.models().Modules()[moduleName]
 	if !ok {
 		return nil, false, fmt.Errorf(
 			"Unable to find Notification '%s' for module '%s'",
@@ -362,7 +386,7 @@ func (y *yangd) findNotificationByNamespace(
 	namespace, name string,
 ) (schema.Notification, bool, error) {
 
-	allnots := y.st.Notifications()
+	allnots := y.models().Notifications()
 	mod_nots, ok := allnots[namespace]
 	if !ok {
 		return nil, false,
@@ -452,11 +476,11 @@ func (y *yangd) LookupRpcDestinationByModuleName(
 		return nil, err
 	}
 
-	mod, ok := y.st.Modules()[in.ModuleName]
+	mod, ok := y.models().Modules()[in.ModuleName]
 	if !ok {
 		return nil, fmt.Errorf("Unable to find model name for module '%s'", in.ModuleName)
 	}
-	modelName, ok := y.mappings.GetModelNameForNamespace(mod.Namespace())
+	modelName, ok := y.componentMappings().GetModelNameForNamespace(mod.Namespace())
 	if !ok {
 		return nil, fmt.Errorf("Unable to find model name for module '%s'", in.ModuleName)
 	}
@@ -465,21 +489,25 @@ func (y *yangd) LookupRpcDestinationByModuleName(
 	}, nil
 }
 
-func startYangd(
+// compileSchema compiles the YANG in -yangdir, returning the config and
+// full model sets and the mappings of their namespaces to components.
+func compileSchema(
 	modelSetName string,
 	compConfig []*conf.ServiceConfig,
-) (st, stFull schema.ModelSet, mappings *schema.ComponentMappings) {
+) (st, stFull schema.ModelSet, mappings *schema.ComponentMappings, err error) {
 
 	ycfg := yangconfig.NewConfig().IncludeYangDirs(*yangdir).
 		IncludeFeatures(*capabilities).SystemConfig()
 
-	st, err := schema.CompileDir(
+	st, err = schema.CompileDir(
 		&compile.Config{
 			YangLocations: ycfg.YangLocator(),
 			Features:      ycfg.FeaturesChecker(),
 			Filter:        compile.IsConfig},
 		&schema.CompilationExtensions{})
-	fatal(err)
+	if err != nil {
+		return nil, nil, nil, err
+	}
 
 	stFull, err = schema.CompileDir(
 		&compile.Config{
@@ -487,7 +515,9 @@ func startYangd(
 			Features:      ycfg.FeaturesChecker(),
 			Filter:        compile.IsConfigOrState()},
 		&schema.CompilationExtensions{})
-	fatal(err)
+	if err != nil {
+		return nil, nil, nil, err
+	}
 
 	err = validateComponents(compConfig)
 	if err != nil {
@@ -496,13 +526,28 @@ func startYangd(
 
 	mappings, err = schema.CreateComponentNSMappings(
 		stFull, modelSetName, compConfig)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return st, stFull, mappings, nil
+}
+
+// The yangd started with configd, updated when the schema is reloaded.
+var runningYangd *yangd
+
+func startYangd(
+	modelSetName string,
+	compConfig []*conf.ServiceConfig,
+) (st, stFull schema.ModelSet, mappings *schema.ComponentMappings) {
+
+	st, stFull, mappings, err := compileSchema(modelSetName, compConfig)
 	fatal(err)
 
 	// Start up yangd
-	yangd := NewYangd(st, stFull, mappings)
+	runningYangd = NewYangd(st, stFull, mappings).(*yangd)
 	comp := vci.NewComponent("net.vyatta.vci.config.yangd")
 	comp.Model("net.vyatta.vci.config.yangd.v1").
-		RPC("yangd-v1", yangd)
+		RPC("yangd-v1", runningYangd)
 	comp.Run()
 
 	return st, stFull, mappings
@@ -0,0 +1,343 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+// YANG test harness
+//
+// 'configd yangtest <dir>' compiles the YANG files in <dir>, starts an
+// in-process session (set up in the same way as sessiontest's TstSrv), and
+// runs each *.yangtest file found in <dir> against it.  This lets YANG
+// authors test their models and configd scripts without a full device.
+//
+// Each test file starts with empty configuration and contains one step per
+// line.  Blank lines and lines starting with '#' are ignored.
+//
+//	set <path>             - set must succeed
+//	set-fails <path>       - set must fail
+//	delete <path>          - delete must succeed
+//	delete-fails <path>    - delete must fail
+//	validate               - candidate must be valid
+//	validate-fails [text]  - validation must fail, with text in the error
+//	commit                 - commit must succeed
+//	commit-fails [text]    - commit must fail, with text in the error
+//	discard                - discard candidate changes
+//	exists <path>          - path must exist in the candidate
+//	not-exists <path>      - path must not exist in the candidate
+//
+// Paths are space separated, as on the CLI.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/danos/config/auth"
+	"github.com/danos/config/data"
+	"github.com/danos/config/schema"
+	"github.com/danos/config/yangconfig"
+	"github.com/danos/configd"
+	"github.com/danos/configd/session"
+	"github.com/danos/vci/conf"
+	"github.com/danos/vci/services"
+	"github.com/danos/yang/compile"
+)
+
+const (
+	yangTestSuffix       = ".yangtest"
+	yangTestModelSetName = "YangTestModelSetV1"
+)
+
+type yangTestStep struct {
+	file string
+	line int
+	op   string
+	args []string
+}
+
+func (s *yangTestStep) String() string {
+	return fmt.Sprintf("%s:%d: %s %s", filepath.Base(s.file), s.line,
+		s.op, strings.Join(s.args, " "))
+}
+
+func parseYangTestFile(file string) ([]*yangTestStep, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var steps []*yangTestStep
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		step := &yangTestStep{
+			file: file, line: lineNo, op: fields[0], args: fields[1:]}
+		switch step.op {
+		case "set", "set-fails", "delete", "delete-fails",
+			"exists", "not-exists":
+			if len(step.args) == 0 {
+				return nil, fmt.Errorf("%s:%d: %s requires a path",
+					file, lineNo, step.op)
+			}
+		case "validate", "validate-fails", "commit", "commit-fails",
+			"discard":
+		default:
+			return nil, fmt.Errorf("%s:%d: unknown step '%s'",
+				file, lineNo, step.op)
+		}
+		steps = append(steps, step)
+	}
+	return steps, scanner.Err()
+}
+
+// yangTestOpsMgr stands in for VCI, as there are no components to talk to.
+type yangTestOpsMgr struct{}
+
+func (yangTestOpsMgr) Dial() error { return nil }
+func (yangTestOpsMgr) SetConfigForModel(string, interface{}) error {
+	return nil
+}
+func (yangTestOpsMgr) CheckConfigForModel(string, interface{}) error {
+	return nil
+}
+func (yangTestOpsMgr) StoreConfigByModelInto(string, interface{}) error {
+	return nil
+}
+func (yangTestOpsMgr) StoreStateByModelInto(string, interface{}) error {
+	return nil
+}
+
+type yangTestEnv struct {
+	ms, msFull schema.ModelSet
+	ctx        *configd.Context
+	runDir     string
+}
+
+func compileYangTestDir(dir string) (st, stFull schema.ModelSet, err error) {
+	ycfg := yangconfig.NewConfig().IncludeYangDirs(dir).
+		IncludeFeatures(*capabilities).SystemConfig()
+
+	st, err = schema.CompileDir(
+		&compile.Config{
+			YangLocations: ycfg.YangLocator(),
+			Features:      ycfg.FeaturesChecker(),
+			Filter:        compile.IsConfig},
+		&schema.CompilationExtensions{})
+	if err != nil {
+		return nil, nil, err
+	}
+	stFull, err = schema.CompileDir(
+		&compile.Config{
+			YangLocations: ycfg.YangLocator(),
+			Features:      ycfg.FeaturesChecker(),
+			Filter:        compile.IsConfigOrState()},
+		&schema.CompilationExtensions{})
+	return st, stFull, err
+}
+
+func newYangTestEnv(dir string) (*yangTestEnv, error) {
+	st, stFull, err := compileYangTestDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Without a component, configuration isn't mapped to any model, so
+	// use a placeholder the same way sessiontest does.
+	dummy, err := conf.ParseConfiguration([]byte(
+		conf.CreateTestDotComponentFile("dummy").AddBaseModel().String()))
+	if err != nil {
+		return nil, err
+	}
+	mappings, _ := schema.CreateComponentNSMappings(
+		stFull, yangTestModelSetName, []*conf.ServiceConfig{dummy})
+	compMgr := schema.NewCompMgr(
+		yangTestOpsMgr{}, services.NewManager(), stFull, mappings)
+
+	u, err := user.Current()
+	if err != nil {
+		return nil, err
+	}
+	uid, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return nil, err
+	}
+	runDir, err := ioutil.TempDir("", "configd-yangtest")
+	if err != nil {
+		return nil, err
+	}
+
+	dlog := log.New(ioutil.Discard, "", 0)
+	elog := log.New(os.Stderr, "", 0)
+	return &yangTestEnv{
+		ms:     st,
+		msFull: stFull,
+		runDir: runDir,
+		ctx: &configd.Context{
+			User:     u.Username,
+			UserHome: u.HomeDir,
+			Uid:      uint32(uid),
+			Pid:      int32(configd.SYSTEM),
+			Auth:     auth.NewAuth(auth.NewAuthGlobal(u.Username, dlog, elog)),
+			Dlog:     dlog,
+			Elog:     elog,
+			Wlog:     elog,
+			CompMgr:  compMgr,
+			Configd:  true,
+			Config: &configd.Config{
+				Runfile:      filepath.Join(runDir, "running.config"),
+				Yangdir:      dir,
+				Capabilities: *capabilities,
+			},
+		},
+	}, nil
+}
+
+func (env *yangTestEnv) cleanup() {
+	os.RemoveAll(env.runDir)
+}
+
+// newSession returns a session against fresh, empty, running configuration
+// so each test file is independent of the others.
+func (env *yangTestEnv) newSession(name string) *session.Session {
+	cmgr := session.NewCommitMgr(data.NewAtomicNode(data.New("root")), env.ms)
+	return session.NewSession(name, cmgr, env.ms, env.msFull)
+}
+
+func yangTestExpectFail(err error, text []string) error {
+	if err == nil {
+		return fmt.Errorf("succeeded, expected failure")
+	}
+	if exp := strings.Join(text, " "); !strings.Contains(err.Error(), exp) {
+		return fmt.Errorf("failed with '%s', expected '%s'", err, exp)
+	}
+	return nil
+}
+
+func yangTestErrs(errs []error) error {
+	if len(errs) == 0 {
+		return fmt.Errorf("failed")
+	}
+	msgs := make([]string, 0, len(errs))
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Errorf("%s", strings.Join(msgs, "\n"))
+}
+
+func (env *yangTestEnv) runStep(sess *session.Session, step *yangTestStep) error {
+	switch step.op {
+	case "set":
+		return sess.Set(env.ctx, step.args)
+	case "set-fails":
+		return yangTestExpectFail(sess.Set(env.ctx, step.args), nil)
+	case "delete":
+		return sess.Delete(env.ctx, step.args)
+	case "delete-fails":
+		return yangTestExpectFail(sess.Delete(env.ctx, step.args), nil)
+	case "validate", "validate-fails":
+		_, errs, ok := sess.Validate(env.ctx)
+		if step.op == "validate" {
+			if !ok {
+				return yangTestErrs(errs)
+			}
+			return nil
+		}
+		if ok {
+			return yangTestExpectFail(nil, step.args)
+		}
+		return yangTestExpectFail(yangTestErrs(errs), step.args)
+	case "commit", "commit-fails":
+		_, errs, ok := sess.Commit(env.ctx, "yangtest", false)
+		if step.op == "commit" {
+			if !ok {
+				return yangTestErrs(errs)
+			}
+			return nil
+		}
+		if ok {
+			return yangTestExpectFail(nil, step.args)
+		}
+		return yangTestExpectFail(yangTestErrs(errs), step.args)
+	case "discard":
+		return sess.Discard(env.ctx)
+	case "exists":
+		if !sess.Exists(env.ctx, step.args) {
+			return fmt.Errorf("path does not exist")
+		}
+	case "not-exists":
+		if sess.Exists(env.ctx, step.args) {
+			return fmt.Errorf("path exists")
+		}
+	}
+	return nil
+}
+
+// runYangTestFile runs the steps in a file, stopping at the first failure
+// as later steps usually depend on earlier ones.
+func (env *yangTestEnv) runYangTestFile(file string, out io.Writer) bool {
+	steps, err := parseYangTestFile(file)
+	if err != nil {
+		fmt.Fprintf(out, "FAIL %s\n", err)
+		return false
+	}
+
+	sess := env.newSession(filepath.Base(file))
+	defer sess.Kill()
+	for _, step := range steps {
+		if err := env.runStep(sess, step); err != nil {
+			fmt.Fprintf(out, "FAIL %s\n\t%s\n", step,
+				strings.Replace(err.Error(), "\n", "\n\t", -1))
+			return false
+		}
+	}
+	fmt.Fprintf(out, "PASS %s (%d steps)\n", filepath.Base(file), len(steps))
+	return true
+}
+
+// runYangTest runs the tests in the given directory, returning the exit
+// status for the command.
+func runYangTest(args []string, out io.Writer) int {
+	if len(args) != 1 {
+		fmt.Fprintf(out, "Usage: %s yangtest <dir>\n", os.Args[0])
+		return 2
+	}
+	dir := args[0]
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"+yangTestSuffix))
+	if err != nil || len(files) == 0 {
+		fmt.Fprintf(out, "No %s files found in %s\n", yangTestSuffix, dir)
+		return 2
+	}
+
+	env, err := newYangTestEnv(dir)
+	if err != nil {
+		fmt.Fprintf(out, "Unable to compile YANG in %s: %s\n", dir, err)
+		return 2
+	}
+	defer env.cleanup()
+
+	failed := 0
+	for _, file := range files {
+		if !env.runYangTestFile(file, out) {
+			failed++
+		}
+	}
+	fmt.Fprintf(out, "%d passed, %d failed\n", len(files)-failed, failed)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
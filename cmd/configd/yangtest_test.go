@@ -0,0 +1,87 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeYangTestFile returns the test file written, and the directory
+// containing it for the caller to remove.
+func writeYangTestFile(t *testing.T, content string) (string, string) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "yangtest")
+	if err != nil {
+		t.Fatalf("Unable to create test dir: %s", err)
+	}
+
+	file := filepath.Join(dir, "example"+yangTestSuffix)
+	if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("Unable to write test file: %s", err)
+	}
+	return file, dir
+}
+
+func TestParseYangTestFile(t *testing.T) {
+	file, dir := writeYangTestFile(t, `
+# Set and commit
+set interfaces dataplane dp0s1 mtu 1500
+set-fails interfaces dataplane dp0s1 mtu 10
+
+commit
+exists interfaces dataplane dp0s1
+delete interfaces dataplane dp0s1
+commit-fails must have a dataplane
+`)
+	defer os.RemoveAll(dir)
+
+	steps, err := parseYangTestFile(file)
+	if err != nil {
+		t.Fatalf("Unexpected parse error: %s", err)
+	}
+
+	expOps := []string{"set", "set-fails", "commit", "exists", "delete",
+		"commit-fails"}
+	if len(steps) != len(expOps) {
+		t.Fatalf("Expected %d steps, got %d", len(expOps), len(steps))
+	}
+	for i, op := range expOps {
+		if steps[i].op != op {
+			t.Fatalf("Step %d: expected %s, got %s", i, op, steps[i].op)
+		}
+	}
+	if steps[0].line != 3 ||
+		strings.Join(steps[0].args, " ") != "interfaces dataplane dp0s1 mtu 1500" {
+		t.Fatalf("Unexpected first step: %s", steps[0])
+	}
+	if strings.Join(steps[5].args, " ") != "must have a dataplane" {
+		t.Fatalf("Unexpected expected error text: %v", steps[5].args)
+	}
+}
+
+func TestParseYangTestFileUnknownStep(t *testing.T) {
+	file, dir := writeYangTestFile(t, "set foo\nfrobnicate foo\n")
+	defer os.RemoveAll(dir)
+
+	_, err := parseYangTestFile(file)
+	if err == nil || !strings.Contains(err.Error(), ":2: unknown step") {
+		t.Fatalf("Expected unknown step error, got %v", err)
+	}
+}
+
+func TestParseYangTestFileMissingPath(t *testing.T) {
+	file, dir := writeYangTestFile(t, "delete\n")
+	defer os.RemoveAll(dir)
+
+	_, err := parseYangTestFile(file)
+	if err == nil || !strings.Contains(err.Error(), "requires a path") {
+		t.Fatalf("Expected missing path error, got %v", err)
+	}
+}
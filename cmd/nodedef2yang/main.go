@@ -0,0 +1,70 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+// nodedef2yang converts a legacy Vyatta node.def template tree into a YANG
+// module with configd extensions, reporting on stderr anything that must
+// be finished by hand.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/danos/configd/nodedef"
+)
+
+var (
+	module    = flag.String("module", "", "Name of the YANG module generated")
+	namespace = flag.String("namespace", "", "Namespace of the module")
+	prefix    = flag.String("prefix", "", "Prefix of the module")
+	output    = flag.String("o", "", "File to write the module to, rather than stdout")
+	strict    = flag.Bool("strict", false, "Fail if anything couldn't be converted")
+)
+
+func handleError(err error) {
+	if err == nil {
+		return
+	}
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(2)
+}
+
+func showUsageAndExit() {
+	fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
+	fmt.Fprintf(os.Stderr,
+		"    %s -module <name> -namespace <ns> -prefix <prefix> <template dir>\n",
+		os.Args[0])
+	flag.PrintDefaults()
+	os.Exit(1)
+}
+
+func main() {
+	flag.Usage = showUsageAndExit
+	flag.Parse()
+	if flag.NArg() != 1 || *module == "" || *namespace == "" ||
+		*prefix == "" {
+		showUsageAndExit()
+	}
+
+	yang, issues, err := nodedef.Convert(flag.Arg(0), nodedef.Module{
+		Name:      *module,
+		Namespace: *namespace,
+		Prefix:    *prefix,
+	})
+	handleError(err)
+
+	if *output == "" {
+		fmt.Print(yang)
+	} else {
+		handleError(ioutil.WriteFile(*output, []byte(yang), 0644))
+	}
+	for _, issue := range issues {
+		fmt.Fprintln(os.Stderr, issue)
+	}
+	if *strict && len(issues) > 0 {
+		os.Exit(3)
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+// schemaaudit lists the schema nodes with defaults, the mandatory nodes and
+// the presence containers at and below a path, for reviewing what a model
+// set does when configuration is left out.
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	client "github.com/danos/configd/client"
+)
+
+func handleError(err error) {
+	if err == nil {
+		return
+	}
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(2)
+}
+
+func showUsageAndExit() {
+	fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "    %s [<path>]\n", os.Args[0])
+	os.Exit(1)
+}
+
+func main() {
+	path := ""
+	switch len(os.Args) {
+	case 1:
+	case 2:
+		path = os.Args[1]
+	default:
+		showUsageAndExit()
+	}
+
+	cl, err := client.Dial("unix", "/run/vyatta/configd/main.sock", "")
+	handleError(err)
+	defer cl.Close()
+
+	audit, err := cl.GetSchemaAudit(path)
+	handleError(err)
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "Defaults:")
+	for _, def := range audit.Defaults {
+		fmt.Fprintf(tw, "  %s\t%s\n", def.Path, def.Value)
+	}
+	tw.Flush()
+	fmt.Println("\nMandatory:")
+	for _, path := range audit.Mandatory {
+		fmt.Printf("  %s\n", path)
+	}
+	fmt.Println("\nPresence containers:")
+	for _, path := range audit.Presence {
+		fmt.Printf("  %s\n", path)
+	}
+}
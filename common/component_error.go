@@ -0,0 +1,85 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package common
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/danos/mgmterror"
+)
+
+// Component failure reason codes
+//
+// When a component rejects a commit its error has been free text, which
+// automation can't act on.  A component may instead return an error whose
+// text is a JSON object:
+//
+//	{"code": "...", "subsystem": "...", "retriable": true, "message": "..."}
+//
+// This is turned into an operation-failed error with the code as its
+// error-app-tag, and subsystem, retriable and model error-info elements, so
+// clients can decide whether to retry, page a human, or roll back.  The
+// error is passed through commit to clients unchanged.  Errors in any other
+// form are reported as before.
+
+const (
+	ComponentErrorSubsystemInfo = "subsystem"
+	ComponentErrorRetriableInfo = "retriable"
+	ComponentErrorModelInfo     = "model"
+)
+
+type ComponentError struct {
+	Code      string `json:"code"`
+	Subsystem string `json:"subsystem"`
+	Retriable bool   `json:"retriable"`
+	Message   string `json:"message"`
+}
+
+// parseComponentError returns the structured error in msg, if there is one.
+func parseComponentError(msg string) (*ComponentError, bool) {
+	msg = strings.TrimSpace(msg)
+	if !strings.HasPrefix(msg, "{") {
+		return nil, false
+	}
+	cerr := &ComponentError{}
+	if err := json.Unmarshal([]byte(msg), cerr); err != nil {
+		return nil, false
+	}
+	if cerr.Code == "" {
+		return nil, false
+	}
+	return cerr, true
+}
+
+// FormatComponentError converts a structured error returned by the component
+// implementing model into a management error, and returns any other error
+// unchanged.  The subsystem defaults to the model if the component doesn't
+// name one.
+func FormatComponentError(model string, err error) error {
+	if err == nil {
+		return nil
+	}
+	cerr, ok := parseComponentError(err.Error())
+	if !ok {
+		return err
+	}
+	if cerr.Subsystem == "" {
+		cerr.Subsystem = model
+	}
+
+	merr := mgmterror.NewOperationFailedApplicationError()
+	merr.AppTag = cerr.Code
+	merr.Message = cerr.Message
+	merr.Info = append(merr.Info,
+		*mgmterror.NewMgmtErrorInfoTag("",
+			ComponentErrorSubsystemInfo, cerr.Subsystem),
+		*mgmterror.NewMgmtErrorInfoTag("",
+			ComponentErrorRetriableInfo, strconv.FormatBool(cerr.Retriable)),
+		*mgmterror.NewMgmtErrorInfoTag("",
+			ComponentErrorModelInfo, model))
+	return merr
+}
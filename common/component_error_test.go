@@ -0,0 +1,55 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package common_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/danos/configd/common"
+	"github.com/danos/mgmterror"
+)
+
+func TestFormatComponentErrorStructured(t *testing.T) {
+	err := common.FormatComponentError("vyatta-test-v1", errors.New(
+		`{"code":"resource-busy","retriable":true,"message":"Try later"}`))
+
+	var mel mgmterror.MgmtErrorList
+	mel.MgmtErrorListAppend(err)
+	buf, jerr := json.Marshal(mel)
+	if jerr != nil {
+		t.Fatalf("Unable to encode error: %s", jerr)
+	}
+	for _, exp := range []string{
+		`"error-tag":"operation-failed"`,
+		`"error-app-tag":"resource-busy"`,
+		`"error-message":"Try later"`,
+		`{"subsystem":"vyatta-test-v1"}`,
+		`{"retriable":"true"}`,
+		`{"model":"vyatta-test-v1"}`,
+	} {
+		if !strings.Contains(string(buf), exp) {
+			t.Fatalf("Expected %s in:\n%s", exp, buf)
+		}
+	}
+}
+
+func TestFormatComponentErrorFreeText(t *testing.T) {
+	for _, msg := range []string{
+		"Interface dp0s1 does not exist",
+		`{"message":"No code given"}`,
+		"{not json",
+	} {
+		err := errors.New(msg)
+		if act := common.FormatComponentError("model", err); act != err {
+			t.Fatalf("Expected %q to be unchanged, got: %v", msg, act)
+		}
+	}
+	if common.FormatComponentError("model", nil) != nil {
+		t.Fatalf("Expected no error")
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 type LogLevel int
@@ -54,11 +55,15 @@ func MapLogLevelToName(level LogLevel) string {
 type LogType int
 
 const (
-	// Any changes need to be reflected in cfgDebugSettings
+	// Any changes need to be reflected in defaultCfgDebugSettings
 	TypeNone LogType = iota
 	TypeCommit
 	TypeState
 	TypeMust
+	TypeSession
+	TypeXpath
+	TypeAaa
+	TypeVci
 	TypeLast // Keep at end so we can size slices
 )
 
@@ -79,13 +84,29 @@ type cfgDebugSetting struct {
 	value   int
 }
 
-var cfgDebugSettings = map[LogType]cfgDebugSetting{
-	TypeNone:   {valType: StringVal, level: LevelNone, value: 0},
-	TypeCommit: {valType: StringVal, level: LevelError, value: 0},
-	TypeState:  {valType: StringVal, level: LevelNone, value: 0},
-	TypeMust:   {valType: IntVal, level: LevelNone, value: 0},
+var defaultCfgDebugSettings = map[LogType]cfgDebugSetting{
+	TypeNone:    {valType: StringVal, level: LevelNone, value: 0},
+	TypeCommit:  {valType: StringVal, level: LevelError, value: 0},
+	TypeState:   {valType: StringVal, level: LevelNone, value: 0},
+	TypeMust:    {valType: IntVal, level: LevelNone, value: 0},
+	TypeSession: {valType: StringVal, level: LevelError, value: 0},
+	TypeXpath:   {valType: StringVal, level: LevelError, value: 0},
+	TypeAaa:     {valType: StringVal, level: LevelError, value: 0},
+	TypeVci:     {valType: StringVal, level: LevelError, value: 0},
 }
 
+// Settings may be changed by SetConfigDebug while being checked from any
+// goroutine.
+var cfgDebugMu sync.RWMutex
+
+var cfgDebugSettings = func() map[LogType]cfgDebugSetting {
+	settings := make(map[LogType]cfgDebugSetting, len(defaultCfgDebugSettings))
+	for logType, setting := range defaultCfgDebugSettings {
+		settings[logType] = setting
+	}
+	return settings
+}()
+
 func MapLogNameToType(name string) (LogType, error) {
 	switch strings.ToLower(name) {
 	case "commit":
@@ -94,9 +115,18 @@ func MapLogNameToType(name string) (LogType, error) {
 		return TypeState, nil
 	case "must":
 		return TypeMust, nil
+	case "session":
+		return TypeSession, nil
+	case "xpath":
+		return TypeXpath, nil
+	case "aaa":
+		return TypeAaa, nil
+	case "vci":
+		return TypeVci, nil
 	}
 	return TypeNone, fmt.Errorf(
-		"LogType '%s' not recognised. Use <validate|commit|state>.", name)
+		"LogType '%s' not recognised. "+
+			"Use <commit|state|must|session|xpath|aaa|vci>.", name)
 }
 
 func MapLogTypeToName(logType LogType) string {
@@ -107,6 +137,14 @@ func MapLogTypeToName(logType LogType) string {
 		return "state"
 	case TypeMust:
 		return "must"
+	case TypeSession:
+		return "session"
+	case TypeXpath:
+		return "xpath"
+	case TypeAaa:
+		return "aaa"
+	case TypeVci:
+		return "vci"
 	default:
 		return "none"
 	}
@@ -116,6 +154,8 @@ func LoggingIsEnabledAtLevel(level LogLevel, logType LogType) bool {
 	if logType >= TypeLast || level >= LevelLast {
 		return false
 	}
+	cfgDebugMu.RLock()
+	defer cfgDebugMu.RUnlock()
 	return cfgDebugSettings[logType].level >= level
 }
 
@@ -123,6 +163,8 @@ func LoggingValueAndStatus(logType LogType) (int, bool) {
 	if logType >= TypeLast {
 		return 0, false
 	}
+	cfgDebugMu.RLock()
+	defer cfgDebugMu.RUnlock()
 	if cfgDebugSettings[logType].valType != IntVal {
 		return 0, false
 	}
@@ -131,6 +173,8 @@ func LoggingValueAndStatus(logType LogType) (int, bool) {
 }
 
 func CurrentLogStatus() string {
+	cfgDebugMu.RLock()
+	defer cfgDebugMu.RUnlock()
 	var retStr = "\nCurrent Debug Status:\n\n"
 	for logType, dbgSetting := range cfgDebugSettings {
 		if LogType(logType) == TypeNone {
@@ -149,7 +193,8 @@ func CurrentLogStatus() string {
 			// Ignore.
 		}
 	}
-	retStr += "\nValid levels: none, error, debug\n"
+	retStr += "\nValid levels: none, error, debug, default\n"
+	retStr += fmt.Sprintf("Log format: %s\n", currentLogFormat())
 
 	return retStr
 }
@@ -169,7 +214,17 @@ func SetConfigDebug(logName, levelOrValue string) (string, error) {
 		return CurrentLogStatus(),
 			fmt.Errorf("%s\n%s", typeErr, CurrentLogStatus())
 	}
-	switch cfgDebugSettings[logType].valType {
+	if strings.ToLower(levelOrValue) == "default" {
+		cfgDebugMu.Lock()
+		cfgDebugSettings[logType] = defaultCfgDebugSettings[logType]
+		cfgDebugMu.Unlock()
+		return CurrentLogStatus(), nil
+	}
+
+	cfgDebugMu.Lock()
+	valType := cfgDebugSettings[logType].valType
+	cfgDebugMu.Unlock()
+	switch valType {
 	case StringVal:
 		logLevel, levelErr := MapLevelNameToLevel(levelOrValue)
 		if levelErr != nil {
@@ -178,7 +233,9 @@ func SetConfigDebug(logName, levelOrValue string) (string, error) {
 		}
 
 		newCfgSetting := cfgDebugSetting{valType: StringVal, level: logLevel}
+		cfgDebugMu.Lock()
 		cfgDebugSettings[logType] = newCfgSetting
+		cfgDebugMu.Unlock()
 
 	case IntVal:
 		// If we can parse value as number, all good
@@ -194,7 +251,9 @@ func SetConfigDebug(logName, levelOrValue string) (string, error) {
 		}
 		newCfgSetting := cfgDebugSetting{
 			valType: IntVal, level: newLevel, value: val}
+		cfgDebugMu.Lock()
 		cfgDebugSettings[logType] = newCfgSetting
+		cfgDebugMu.Unlock()
 	}
 
 	return CurrentLogStatus(), nil
@@ -230,3 +230,16 @@ func TestConfigDebugIntValueDisplayedCorrectly(t *testing.T) {
 
 	restoreDefaults()
 }
+
+func TestConfigDebugDefaultRestoresDefault(t *testing.T) {
+	common.SetConfigDebug(COMMIT, DEBUG)
+	common.SetConfigDebug(MUST, "42")
+
+	common.SetConfigDebug(COMMIT, "default")
+	out, err := common.SetConfigDebug(MUST, "default")
+	if err != nil {
+		t.Fatalf("Unable to restore default: %s", err)
+	}
+	checkDebugDefaults(t, out)
+	checkLoggingValue(t, MUST, 0, false)
+}
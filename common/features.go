@@ -1,4 +1,4 @@
-// Copyright (c) 2019, AT&T Intellectual Property. All rights reserved.
+// Copyright (c) 2019-2021, AT&T Intellectual Property. All rights reserved.
 //
 // SPDX-License-Identifier: LGPL-2.1-only
 
@@ -6,7 +6,11 @@ package common
 
 // Config System Feature well known names
 const (
+	ArchiveFeature          = "archive"
 	ConfigManagementFeature = "config-mgmt"
+	ConfirmedCommitFeature  = "confirmed-commit"
+	ExclusiveConfigFeature  = "exclusive-config"
 	LoadKeysFeature         = "loadkeys"
 	RoutingInstanceFeature  = "routing-instance"
+	SecretsAccessFeature    = "secrets-access"
 )
@@ -0,0 +1,66 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package common
+
+import (
+	"sync"
+
+	"github.com/danos/utils/pathutil"
+)
+
+// Path interning
+//
+// Most read requests, eg from completion and the show commands, name their
+// path as a string, which used to be split into a new slice on every call.
+// Clients ask for the same few paths over and over, so under load this was
+// a large share of the garbage configd made.  Read-only requests instead
+// take the split path from a cache, shared by all connections, as do the
+// session and diff code that turn the same stored paths back into slices
+// on every commit.
+//
+// Interned paths are shared, so must never be changed in place.  Their
+// capacity is clipped to their length, so appending to one always copies.
+// Requests that change configuration still split their own paths, as those
+// may be normalized in place.  Rather than track use, the cache is simply
+// emptied when full.
+
+const maxInternedPaths = 4096
+
+type pathInterner struct {
+	mu    sync.RWMutex
+	paths map[string][]string
+	max   int
+}
+
+func newPathInterner(max int) *pathInterner {
+	return &pathInterner{paths: make(map[string][]string), max: max}
+}
+
+var internedPaths = newPathInterner(maxInternedPaths)
+
+func (p *pathInterner) intern(path string) []string {
+	p.mu.RLock()
+	ps, ok := p.paths[path]
+	p.mu.RUnlock()
+	if ok {
+		return ps
+	}
+
+	ps = pathutil.Makepath(path)
+	ps = ps[:len(ps):len(ps)]
+	p.mu.Lock()
+	if len(p.paths) >= p.max {
+		p.paths = make(map[string][]string)
+	}
+	p.paths[path] = ps
+	p.mu.Unlock()
+	return ps
+}
+
+// InternPath returns the path split into its elements, which the caller
+// must not change.
+func InternPath(path string) []string {
+	return internedPaths.intern(path)
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package common
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/danos/utils/pathutil"
+)
+
+const benchPath = "/interfaces/dataplane/dp0s3/address/10.0.0.1%2F24"
+
+func TestInternPath(t *testing.T) {
+	p := newPathInterner(2)
+
+	ps := p.intern(benchPath)
+	if exp := pathutil.Makepath(benchPath); !reflect.DeepEqual(ps, exp) {
+		t.Fatalf("Unexpected path %v, expected %v", ps, exp)
+	}
+	if again := p.intern(benchPath); &again[0] != &ps[0] {
+		t.Fatal("Path not shared")
+	}
+
+	// Appending must not change the interned path
+	child := append(ps, "child")
+	child[0] = "changed"
+	if ps[0] != "interfaces" {
+		t.Fatalf("Interned path changed by append: %v", ps)
+	}
+
+	p.intern("/a")
+	p.intern("/b")
+	if len(p.paths) != 1 {
+		t.Fatalf("Cache not emptied when full: %v", p.paths)
+	}
+}
+
+func BenchmarkMakepath(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		pathutil.Makepath(benchPath)
+	}
+}
+
+func BenchmarkInternPath(b *testing.B) {
+	p := newPathInterner(maxInternedPaths)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		p.intern(benchPath)
+	}
+}
+
+func BenchmarkInternPathParallel(b *testing.B) {
+	p := newPathInterner(maxInternedPaths)
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			p.intern(benchPath)
+		}
+	})
+}
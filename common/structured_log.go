@@ -0,0 +1,155 @@
+// Copyright (c) 2021, AT&T Intellectual Property.
+// All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Structured logging
+//
+// Log writes one line per message, with the time, level, subsystem
+// (LogType) and any key/value pairs given, either as logfmt or as a JSON
+// object, so logs can be searched and parsed rather than read.  Whether a
+// message is written depends on the subsystem's level, as set with
+// SetConfigDebug, so debug logging can be turned on for one subsystem at a
+// time without restarting configd.
+
+type LogFormat int
+
+const (
+	FormatLogfmt LogFormat = iota
+	FormatJSON
+)
+
+var structuredLog = struct {
+	mu     sync.Mutex
+	out    io.Writer
+	format LogFormat
+}{out: os.Stderr}
+
+func MapLogFormatNameToFormat(name string) (LogFormat, error) {
+	switch strings.ToLower(name) {
+	case "logfmt":
+		return FormatLogfmt, nil
+	case "json":
+		return FormatJSON, nil
+	}
+	return FormatLogfmt, fmt.Errorf(
+		"Log format '%s' not recognised. Use <logfmt|json>.", name)
+}
+
+func MapLogFormatToName(format LogFormat) string {
+	if format == FormatJSON {
+		return "json"
+	}
+	return "logfmt"
+}
+
+// SetLogFormat sets how Log writes messages.
+func SetLogFormat(name string) error {
+	format, err := MapLogFormatNameToFormat(name)
+	if err != nil {
+		return err
+	}
+	structuredLog.mu.Lock()
+	structuredLog.format = format
+	structuredLog.mu.Unlock()
+	return nil
+}
+
+func currentLogFormat() string {
+	structuredLog.mu.Lock()
+	defer structuredLog.mu.Unlock()
+	return MapLogFormatToName(structuredLog.format)
+}
+
+// SetLogOutput sets where Log writes messages, stderr by default.
+func SetLogOutput(w io.Writer) {
+	structuredLog.mu.Lock()
+	structuredLog.out = w
+	structuredLog.mu.Unlock()
+}
+
+func logfmtValue(v string) string {
+	if v == "" || strings.ContainsAny(v, " =\"\t\n") {
+		return strconv.Quote(v)
+	}
+	return v
+}
+
+// formatLogLine returns the line for the message, without its newline.
+func formatLogLine(
+	format LogFormat,
+	t time.Time,
+	level LogLevel,
+	logType LogType,
+	msg string,
+	keyvals []interface{},
+) []byte {
+	keys := []string{"time", "level", "subsystem", "msg"}
+	vals := []string{
+		t.UTC().Format(time.RFC3339Nano),
+		MapLogLevelToName(level),
+		MapLogTypeToName(logType),
+		msg,
+	}
+	for i := 0; i < len(keyvals); i += 2 {
+		keys = append(keys, fmt.Sprint(keyvals[i]))
+		if i+1 < len(keyvals) {
+			vals = append(vals, fmt.Sprint(keyvals[i+1]))
+		} else {
+			vals = append(vals, "")
+		}
+	}
+
+	var b bytes.Buffer
+	switch format {
+	case FormatJSON:
+		b.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			k, _ := json.Marshal(key)
+			v, _ := json.Marshal(vals[i])
+			b.Write(k)
+			b.WriteByte(':')
+			b.Write(v)
+		}
+		b.WriteByte('}')
+	default:
+		for i, key := range keys {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteString(key)
+			b.WriteByte('=')
+			b.WriteString(logfmtValue(vals[i]))
+		}
+	}
+	return b.Bytes()
+}
+
+// Log writes msg, with keyvals as alternating keys and values, if logging
+// for logType is enabled at level.
+func Log(level LogLevel, logType LogType, msg string, keyvals ...interface{}) {
+	if level == LevelNone || !LoggingIsEnabledAtLevel(level, logType) {
+		return
+	}
+	structuredLog.mu.Lock()
+	defer structuredLog.mu.Unlock()
+	line := formatLogLine(structuredLog.format, time.Now(), level, logType,
+		msg, keyvals)
+	structuredLog.out.Write(append(line, '\n'))
+}
@@ -0,0 +1,88 @@
+// Copyright (c) 2021, AT&T Intellectual Property.
+// All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+package common_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/danos/configd/common"
+)
+
+func captureLog(t *testing.T, format string, fn func()) string {
+	t.Helper()
+	var buf bytes.Buffer
+	common.SetLogOutput(&buf)
+	defer common.SetLogOutput(os.Stderr)
+	if err := common.SetLogFormat(format); err != nil {
+		t.Fatalf("Unable to set log format: %s", err)
+	}
+	defer common.SetLogFormat("logfmt")
+	fn()
+	return buf.String()
+}
+
+func TestLogLogfmt(t *testing.T) {
+	out := captureLog(t, "logfmt", func() {
+		common.Log(common.LevelError, common.TypeSession, "Session failed",
+			"sid", "1234", "reason", "no such user")
+	})
+	for _, exp := range []string{
+		"level=error",
+		"subsystem=session",
+		`msg="Session failed"`,
+		"sid=1234",
+		`reason="no such user"`,
+	} {
+		if !strings.Contains(out, exp) {
+			t.Fatalf("Missing %s in log:\n%s", exp, out)
+		}
+	}
+}
+
+func TestLogJSON(t *testing.T) {
+	out := captureLog(t, "json", func() {
+		common.Log(common.LevelError, common.TypeAaa, "Denied",
+			"user", "fred")
+	})
+	var line map[string]string
+	if err := json.Unmarshal([]byte(out), &line); err != nil {
+		t.Fatalf("Invalid JSON log line %s: %s", out, err)
+	}
+	if line["subsystem"] != "aaa" || line["msg"] != "Denied" ||
+		line["user"] != "fred" || line["level"] != "error" {
+		t.Fatalf("Unexpected log line: %s", out)
+	}
+}
+
+func TestLogFollowsSubsystemLevel(t *testing.T) {
+	defer common.SetConfigDebug("xpath", "default")
+
+	out := captureLog(t, "logfmt", func() {
+		common.Log(common.LevelDebug, common.TypeXpath, "Not logged")
+	})
+	if out != "" {
+		t.Fatalf("Debug logged at default level:\n%s", out)
+	}
+
+	common.SetConfigDebug("xpath", DEBUG)
+	out = captureLog(t, "logfmt", func() {
+		common.Log(common.LevelDebug, common.TypeXpath, "Logged")
+		common.Log(common.LevelDebug, common.TypeVci, "Not logged")
+	})
+	if !strings.Contains(out, "msg=Logged") ||
+		strings.Contains(out, "Not logged") {
+		t.Fatalf("Unexpected log:\n%s", out)
+	}
+}
+
+func TestLogFormatInvalid(t *testing.T) {
+	if err := common.SetLogFormat("xml"); err == nil {
+		t.Fatal("Unknown log format accepted")
+	}
+}
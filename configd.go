@@ -1,4 +1,4 @@
-// Copyright (c) 2018-2020, AT&T Intellectual Property. All rights reserved.
+// Copyright (c) 2018-2021, AT&T Intellectual Property. All rights reserved.
 //
 // Copyright (c) 2014-2017 by Brocade Communications Systems, Inc.
 // All rights reserved.
@@ -8,6 +8,7 @@
 package configd
 
 import (
+	"context"
 	"log"
 	"log/syslog"
 	"os"
@@ -39,6 +40,8 @@ type Context struct {
 	Auth      auth.Auther
 	Pid       int32
 	Uid       uint32
+	PeerUid   uint32 // Verified by the kernel, see server/peer_cred.go
+	PeerGid   uint32
 	User      string
 	UserHome  string
 	Groups    []string
@@ -49,6 +52,19 @@ type Context struct {
 	Wlog      *log.Logger
 	CompMgr   schema.ComponentManager
 	Noexec    bool
+	// Supplied by the committer for the commit approver, if any.
+	ApprovalToken string
+	// Cancelled once the client no longer wants the result, if set.
+	Request context.Context
+}
+
+// Done returns a channel closed once the request is cancelled, or nil,
+// which is never ready, if it can't be.
+func (c *Context) Done() <-chan struct{} {
+	if c.Request == nil {
+		return nil
+	}
+	return c.Request.Done()
 }
 
 // Raising privileges should be done sparingly as it bypasses things like
@@ -62,22 +78,52 @@ func (c *Context) DropPrivileges() {
 }
 
 type Config struct {
-	User         string
-	Runfile      string
-	Logfile      string
-	Pidfile      string
-	Yangdir      string
-	Socket       string
-	SecretsGroup string
-	SuperGroup   string
-	Capabilities string
+	User                string
+	UserId              string // Static uid for User, if set
+	Runfile             string
+	Logfile             string
+	Pidfile             string
+	Yangdir             string
+	Socket              string
+	SecretsGroup        string
+	SuperGroup          string
+	Capabilities        string
+	SessionActivityDir  string
+	RpcOutputValidation string
+	Redaction           string
+	Container           bool
+	ProfileDir          string
+	ConfigDir           string // Defaults to /config
+	Instance            string // Name distinguishing this instance, if set
+	ApprovalHook        string // URL asked to approve each commit, if set
+	ShadowPeer          string // Socket of a peer each commit is validated on
+	StandbyPeer         string // Socket of a warm standby commits are replicated to
+	PersistSessions     bool   // Keep CLI sessions across restarts
+	TraceCommit         bool   // Record what each commit runs, in order
+	RevalidateInterval  int    // Minutes between revalidations of running, 0 for none
+	ConcurrentReads     int    // Reads each connection may run at once, 0 for the default
+	StateCacheTTL       int    // Seconds get-state script output is cached, 0 for none
+	StateScriptTimeout  int    // Seconds a node's get-state scripts may take, 0 for no limit
+	ScriptTimeout       int    // Seconds validation, hooks and sub scripts may take, 0 for no limit
+	MaxUserConnections  int    // Connections each user may hold, 0 for no limit
+	MaxUserRequests     int    // Requests each user may run at once, 0 for no limit
 }
 
 //version of syslog.NewLogger which uses base program name as logging tag
 func NewLogger(p syslog.Priority, logFlag int) (*log.Logger, error) {
-	var tag string
+	return NewInstanceLogger(p, logFlag, "")
+}
 
-	tag = filepath.Base(os.Args[0])
+// NewInstanceLogger is as NewLogger, but the tag also names the instance,
+// if set, so the logs of several instances running on one host can be told
+// apart.
+func NewInstanceLogger(
+	p syslog.Priority, logFlag int, instance string,
+) (*log.Logger, error) {
+	tag := filepath.Base(os.Args[0])
+	if instance != "" {
+		tag += "-" + instance
+	}
 	s, err := syslog.New(p, tag)
 	if err != nil {
 		return nil, err
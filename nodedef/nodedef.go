@@ -0,0 +1,227 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+// Package nodedef converts legacy Vyatta node.def template trees into YANG
+// with configd extensions, to ease migrating deployments still using them.
+//
+// A template tree is a directory per node, holding the node's node.def and
+// a directory for each child.  Tag nodes keep the template for their
+// entries in a node.tag directory.  Nodes become:
+//
+//	tag node                   - list keyed by "tagnode", of the tag's type
+//	node with children         - container, with presence if it has scripts
+//	node with a type           - leaf, or leaf-list if multi
+//	node with neither          - leaf of type empty
+//
+// Fields are converted where configd has an equivalent: help, default,
+// priority and allowed, the create, delete, update, begin and end scripts,
+// syntax:expressions that are an exec, a list of values, a pattern or a
+// range, and commit:expressions that are an exec.  Anything else is left
+// out and reported as an Issue, for the YANG to be finished by hand.
+package nodedef
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	nodeDefFile = "node.def"
+	tagDir      = "node.tag"
+	tagKey      = "tagnode"
+)
+
+// Fields known to node.def templates
+var knownFields = []string{
+	"tag", "multi", "type", "help", "default", "priority", "allowed",
+	"syntax:expression", "commit:expression",
+	"create", "delete", "update", "begin", "end",
+	"val_help", "comp_help", "enumeration",
+}
+
+var scriptFields = []string{"create", "delete", "update", "begin", "end"}
+
+// Issue is a construct that couldn't be converted.
+type Issue struct {
+	Path    string
+	Field   string
+	Message string
+}
+
+func (i Issue) String() string {
+	if i.Field == "" {
+		return fmt.Sprintf("%s: %s", i.Path, i.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", i.Path, i.Field, i.Message)
+}
+
+// Module names the YANG module generated.
+type Module struct {
+	Name      string
+	Namespace string
+	Prefix    string
+}
+
+// node is a template, with the values of each of its fields.
+type node struct {
+	name     string
+	path     []string
+	fields   map[string][]string
+	children []*node
+}
+
+func (n *node) pathstr() string {
+	return "/" + strings.Join(n.path, "/")
+}
+
+func (n *node) field(name string) (string, bool) {
+	vals := n.fields[name]
+	if len(vals) == 0 {
+		return "", false
+	}
+	return vals[0], true
+}
+
+func (n *node) has(name string) bool {
+	_, ok := n.fields[name]
+	return ok
+}
+
+func isKnownField(name string) bool {
+	for _, f := range knownFields {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+var fieldRe = regexp.MustCompile(`^([a-z_]+(:[a-z_]+)?):\s?(.*)$`)
+
+// parseNodeDef returns the values of each field in a node.def.  A field's
+// value, eg a script, continues on each following line not starting a
+// field.
+func parseNodeDef(r io.Reader) (map[string][]string, error) {
+	fields := make(map[string][]string)
+	var cur string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if m := fieldRe.FindStringSubmatch(line); m != nil {
+			cur = m[1]
+			fields[cur] = append(fields[cur], m[3])
+			continue
+		}
+		if cur == "" {
+			if strings.TrimSpace(line) == "" ||
+				strings.HasPrefix(line, "#") {
+				continue
+			}
+			return nil, fmt.Errorf("Unexpected line: %s", line)
+		}
+		vals := fields[cur]
+		vals[len(vals)-1] += "\n" + line
+	}
+	for name, vals := range fields {
+		for i, val := range vals {
+			vals[i] = strings.TrimSpace(val)
+		}
+		fields[name] = vals
+	}
+	return fields, scanner.Err()
+}
+
+func readNodeDef(dir string) (map[string][]string, error) {
+	f, err := os.Open(filepath.Join(dir, nodeDefFile))
+	if os.IsNotExist(err) {
+		return map[string][]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseNodeDef(f)
+}
+
+// readChildren reads the templates of the child directories of dir.
+func readChildren(dir string, path []string) ([]*node, error) {
+	ents, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var children []*node
+	for _, ent := range ents {
+		if !ent.IsDir() || ent.Name() == tagDir {
+			continue
+		}
+		ch, err := readNode(filepath.Join(dir, ent.Name()), ent.Name(),
+			append(append([]string(nil), path...), ent.Name()))
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, ch)
+	}
+	sort.Slice(children, func(i, j int) bool {
+		return children[i].name < children[j].name
+	})
+	return children, nil
+}
+
+func readNode(dir, name string, path []string) (*node, error) {
+	fields, err := readNodeDef(dir)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", dir, err)
+	}
+	n := &node{name: name, path: path, fields: fields}
+	if !n.has("tag") {
+		n.children, err = readChildren(dir, path)
+		return n, err
+	}
+	childDir := filepath.Join(dir, tagDir)
+	if _, err := os.Stat(childDir); os.IsNotExist(err) {
+		return n, nil
+	}
+	n.children, err = readChildren(childDir,
+		append(append([]string(nil), path...), tagDir))
+	return n, err
+}
+
+// Convert reads the template tree in dir, returning the equivalent YANG
+// module and the constructs that couldn't be converted.
+func Convert(dir string, mod Module) (string, []Issue, error) {
+	roots, err := readChildren(dir, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	c := &converter{}
+	c.line("module %s {", mod.Name)
+	c.indent++
+	c.line("namespace %s;", quote(mod.Namespace))
+	c.line("prefix %s;", mod.Prefix)
+	c.line("")
+	c.line("import configd-v1 {")
+	c.line("\tprefix configd;")
+	c.line("}")
+	header := c.buf.Len()
+	for _, n := range roots {
+		c.line("")
+		c.node(n)
+	}
+	c.indent--
+	c.line("}")
+
+	out := c.buf.String()
+	if c.usesTypes {
+		imp := "\timport vyatta-types-v1 {\n\t\tprefix types;\n\t}\n"
+		out = out[:header] + imp + out[header:]
+	}
+	return out, c.issues, nil
+}
@@ -0,0 +1,175 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package nodedef
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeTemplates creates the node.def files, keyed by their directory.
+func writeTemplates(t *testing.T, defs map[string]string) string {
+	dir, err := ioutil.TempDir("", "nodedef")
+	if err != nil {
+		t.Fatalf("Unable to create dir: %s", err)
+	}
+	for path, def := range defs {
+		full := filepath.Join(dir, path)
+		if err := os.MkdirAll(full, 0755); err != nil {
+			t.Fatalf("Unable to create %s: %s", full, err)
+		}
+		err := ioutil.WriteFile(filepath.Join(full, nodeDefFile),
+			[]byte(def), 0644)
+		if err != nil {
+			t.Fatalf("Unable to write %s: %s", full, err)
+		}
+	}
+	return dir
+}
+
+var testModule = Module{
+	Name:      "test-v1",
+	Namespace: "urn:test:1",
+	Prefix:    "test",
+}
+
+func TestConvert(t *testing.T) {
+	dir := writeTemplates(t, map[string]string{
+		"system": "help: System parameters\n",
+		"system/host-name": `type: txt
+help: Host name
+default: "vyatta"
+syntax:expression: pattern $VAR(@) "^[a-z]+$" ; "Invalid host name"
+update: /opt/vyatta/sbin/set-hostname $VAR(@)
+`,
+		"system/ntp": `help: NTP
+begin: echo start
+`,
+		"system/ntp/server": `tag:
+type: ipv4,ipv6
+help: NTP server
+`,
+		"system/ntp/server/node.tag/prefer": "help: Prefer this server\n",
+		"system/ntp/server/node.tag/mode": `type: txt
+syntax:expression: $VAR(@) in "client", "peer"
+`,
+		"system/mtu": `type: u32
+syntax:expression: $VAR(@) >= 68 && $VAR(@) <= 9000
+`,
+		"system/domain-search": `multi:
+type: txt
+commit:expression: exec "/bin/check-domain"
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	yang, issues, err := Convert(dir, testModule)
+	if err != nil {
+		t.Fatalf("Unable to convert: %s", err)
+	}
+	exp := `module test-v1 {
+	namespace "urn:test:1";
+	prefix test;
+
+	import configd-v1 {
+		prefix configd;
+	}
+	import vyatta-types-v1 {
+		prefix types;
+	}
+
+	container system {
+		configd:help "System parameters";
+		leaf-list domain-search {
+			type string;
+			configd:validate "/bin/check-domain";
+		}
+		leaf host-name {
+			type string {
+				pattern "[a-z]+" {
+					error-message "Invalid host name";
+				}
+			}
+			default "vyatta";
+			configd:help "Host name";
+			configd:update "/opt/vyatta/sbin/set-hostname $VAR(@)";
+		}
+		leaf mtu {
+			type uint32 {
+				range "68..9000";
+			}
+		}
+		container ntp {
+			presence "Converted from node.def";
+			configd:help "NTP";
+			configd:begin "echo start";
+			list server {
+				key tagnode;
+				leaf tagnode {
+					type union {
+						type types:ipv4-address;
+						type types:ipv6-address;
+					}
+				}
+				configd:help "NTP server";
+				leaf mode {
+					type enumeration {
+						enum "client";
+						enum "peer";
+					}
+				}
+				leaf prefer {
+					type empty;
+					configd:help "Prefer this server";
+				}
+			}
+		}
+	}
+}
+`
+	if yang != exp {
+		t.Fatalf("Unexpected YANG:\n%s\nExpected:\n%s", yang, exp)
+	}
+	if len(issues) != 0 {
+		t.Fatalf("Unexpected issues: %v", issues)
+	}
+}
+
+func TestConvertReportsIssues(t *testing.T) {
+	dir := writeTemplates(t, map[string]string{
+		"service": `type: txt
+val_help: txt; Service name
+syntax:expression: $VAR(@) != "bad"
+commit:expression: $VAR(../other) != ""
+frobnicate: yes
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	yang, issues, err := Convert(dir, testModule)
+	if err != nil {
+		t.Fatalf("Unable to convert: %s", err)
+	}
+	if !strings.Contains(yang, "leaf service {") {
+		t.Fatalf("Node not converted:\n%s", yang)
+	}
+	var act []string
+	for _, issue := range issues {
+		act = append(act, issue.String())
+	}
+	exp := []string{
+		`/service: syntax:expression: Not converted: $VAR(@) != "bad"`,
+		`/service: commit:expression: Not converted: $VAR(../other) != ""`,
+		`/service: frobnicate: Unknown field`,
+		`/service: val_help: Not converted`,
+	}
+	if !reflect.DeepEqual(act, exp) {
+		t.Fatalf("Unexpected issues:\n%v\nExpected:\n%v", act, exp)
+	}
+}
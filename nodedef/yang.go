@@ -0,0 +1,329 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package nodedef
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var typeMap = map[string]string{
+	"txt":     "string",
+	"u32":     "uint32",
+	"bool":    "boolean",
+	"ipv4":    "types:ipv4-address",
+	"ipv4net": "types:ipv4-prefix",
+	"ipv6":    "types:ipv6-address",
+	"ipv6net": "types:ipv6-prefix",
+	"macaddr": "types:mac-address",
+}
+
+var (
+	// An expression may end with the message shown when it fails.
+	messageRe = regexp.MustCompile(`^(.*?)\s*;\s*"(.*)"$`)
+	execRe    = regexp.MustCompile(`^exec\s+"(.*)"$`)
+	inRe      = regexp.MustCompile(`^\$VAR\(@\)\s+in\s+(.*)$`)
+	patternRe = regexp.MustCompile(`^pattern\s+\$VAR\(@\)\s+"(.*)"$`)
+	rangeRe   = regexp.MustCompile(
+		`^\$VAR\(@\)\s*>=\s*(\d+)\s*&&\s*\$VAR\(@\)\s*<=\s*(\d+)$`)
+	quotedRe = regexp.MustCompile(`"([^"]*)"`)
+)
+
+func quote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`)
+	return `"` + r.Replace(s) + `"`
+}
+
+type converter struct {
+	buf       bytes.Buffer
+	indent    int
+	usesTypes bool
+	issues    []Issue
+}
+
+func (c *converter) line(format string, args ...interface{}) {
+	if format == "" {
+		c.buf.WriteString("\n")
+		return
+	}
+	c.buf.WriteString(strings.Repeat("\t", c.indent))
+	fmt.Fprintf(&c.buf, format, args...)
+	c.buf.WriteString("\n")
+}
+
+// stmt writes a statement whose argument is a string.
+func (c *converter) stmt(keyword, arg string) {
+	c.line("%s %s;", keyword, quote(arg))
+}
+
+func (c *converter) issue(n *node, field, format string, args ...interface{}) {
+	c.issues = append(c.issues, Issue{
+		Path:    n.pathstr(),
+		Field:   field,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// typeStmt describes the type of a leaf, or tag node's key.
+type typeStmt struct {
+	members  []string // More than one for a union
+	enums    []string
+	patterns [][2]string // Pattern and error message
+	ranges   [][2]string // Range and error message
+}
+
+// base returns the type being restricted, or "" for a union.
+func (t *typeStmt) base() string {
+	if len(t.members) != 1 {
+		return ""
+	}
+	return t.members[0]
+}
+
+func (t *typeStmt) write(c *converter) {
+	switch {
+	case len(t.enums) > 0:
+		c.line("type enumeration {")
+		c.indent++
+		for _, e := range t.enums {
+			c.line("enum %s;", quote(e))
+		}
+		c.indent--
+		c.line("}")
+		return
+	case len(t.members) > 1:
+		c.line("type union {")
+		c.indent++
+		for _, m := range t.members {
+			c.line("type %s;", m)
+		}
+		c.indent--
+		c.line("}")
+		return
+	case len(t.patterns) == 0 && len(t.ranges) == 0:
+		c.line("type %s;", t.base())
+		return
+	}
+	c.line("type %s {", t.base())
+	c.indent++
+	for _, restr := range []struct {
+		keyword string
+		vals    [][2]string
+	}{{"range", t.ranges}, {"pattern", t.patterns}} {
+		for _, v := range restr.vals {
+			if v[1] == "" {
+				c.stmt(restr.keyword, v[0])
+				continue
+			}
+			c.line("%s %s {", restr.keyword, quote(v[0]))
+			c.indent++
+			c.stmt("error-message", v[1])
+			c.indent--
+			c.line("}")
+		}
+	}
+	c.indent--
+	c.line("}")
+}
+
+// newTypeStmt returns the YANG type for the node's type field.
+func (c *converter) newTypeStmt(n *node, untyped string) *typeStmt {
+	typ, ok := n.field("type")
+	if !ok {
+		return &typeStmt{members: []string{untyped}}
+	}
+	if m := messageRe.FindStringSubmatch(typ); m != nil {
+		typ = m[1]
+	}
+	t := &typeStmt{}
+	for _, name := range strings.Split(typ, ",") {
+		name = strings.TrimSpace(name)
+		yt, ok := typeMap[name]
+		if !ok {
+			c.issue(n, "type", "Unknown type %s, using string", name)
+			yt = "string"
+		}
+		if strings.HasPrefix(yt, "types:") {
+			c.usesTypes = true
+		}
+		t.members = append(t.members, yt)
+	}
+	return t
+}
+
+// syntax converts the node's syntax:expressions into restrictions on its
+// type, if it has one, returning any scripts to check the value with.
+func (c *converter) syntax(n *node, t *typeStmt) []string {
+	var scripts []string
+	base := ""
+	if t != nil {
+		base = t.base()
+	}
+	for _, expr := range n.fields["syntax:expression"] {
+		msg := ""
+		if m := messageRe.FindStringSubmatch(expr); m != nil {
+			expr, msg = m[1], m[2]
+		}
+		switch {
+		case execRe.MatchString(expr):
+			scripts = append(scripts, execRe.FindStringSubmatch(expr)[1])
+		case inRe.MatchString(expr) && base == "string":
+			for _, m := range quotedRe.FindAllStringSubmatch(
+				inRe.FindStringSubmatch(expr)[1], -1) {
+				t.enums = append(t.enums, m[1])
+			}
+		case patternRe.MatchString(expr) && base != "" && base != "empty":
+			re := patternRe.FindStringSubmatch(expr)[1]
+			re = strings.TrimSuffix(strings.TrimPrefix(re, "^"), "$")
+			t.patterns = append(t.patterns, [2]string{re, msg})
+		case rangeRe.MatchString(expr) && base == "uint32":
+			m := rangeRe.FindStringSubmatch(expr)
+			t.ranges = append(t.ranges, [2]string{m[1] + ".." + m[2], msg})
+		default:
+			c.issue(n, "syntax:expression", "Not converted: %s", expr)
+		}
+	}
+	return scripts
+}
+
+// common writes the statements shared by all kinds of node.
+func (c *converter) common(n *node, syntaxScripts []string) {
+	if help, ok := n.field("help"); ok {
+		c.stmt("configd:help", help)
+	}
+	if prio, ok := n.field("priority"); ok {
+		c.stmt("configd:priority", prio)
+	}
+	if allowed, ok := n.field("allowed"); ok {
+		c.stmt("configd:allowed", allowed)
+	}
+	for _, script := range syntaxScripts {
+		c.stmt("configd:syntax", script)
+	}
+	for _, expr := range n.fields["commit:expression"] {
+		if m := messageRe.FindStringSubmatch(expr); m != nil {
+			expr = m[1]
+		}
+		if m := execRe.FindStringSubmatch(expr); m != nil {
+			c.stmt("configd:validate", m[1])
+			continue
+		}
+		c.issue(n, "commit:expression", "Not converted: %s", expr)
+	}
+	for _, field := range scriptFields {
+		for _, script := range n.fields[field] {
+			c.stmt("configd:"+field, script)
+		}
+	}
+	fields := make([]string, 0, len(n.fields))
+	for field := range n.fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		switch {
+		case field == "val_help" || field == "comp_help" ||
+			field == "enumeration":
+			c.issue(n, field, "Not converted")
+		case !isKnownField(field):
+			c.issue(n, field, "Unknown field")
+		}
+	}
+}
+
+func (c *converter) hasScripts(n *node) bool {
+	for _, field := range scriptFields {
+		if n.has(field) {
+			return true
+		}
+	}
+	return n.has("commit:expression")
+}
+
+func (c *converter) children(n *node) {
+	for _, ch := range n.children {
+		c.node(ch)
+	}
+}
+
+func (c *converter) node(n *node) {
+	switch {
+	case n.has("tag"):
+		c.list(n)
+	case len(n.children) > 0:
+		c.container(n)
+	default:
+		c.leaf(n)
+	}
+}
+
+func (c *converter) list(n *node) {
+	if n.has("multi") {
+		c.issue(n, "multi", "Ignored on a tag node")
+	}
+	if n.has("default") {
+		c.issue(n, "default", "Ignored on a tag node")
+	}
+	c.line("list %s {", n.name)
+	c.indent++
+	c.line("key %s;", tagKey)
+	c.line("leaf %s {", tagKey)
+	c.indent++
+	t := c.newTypeStmt(n, "string")
+	scripts := c.syntax(n, t)
+	t.write(c)
+	for _, script := range scripts {
+		c.stmt("configd:syntax", script)
+	}
+	c.indent--
+	c.line("}")
+	c.common(n, nil)
+	c.children(n)
+	c.indent--
+	c.line("}")
+}
+
+func (c *converter) container(n *node) {
+	if n.has("type") {
+		c.issue(n, "type", "Ignored on a node with children")
+	}
+	c.line("container %s {", n.name)
+	c.indent++
+	// Template nodes exist once set, which only matters if that runs
+	// something.
+	if c.hasScripts(n) {
+		c.stmt("presence", "Converted from node.def")
+	}
+	c.common(n, c.syntax(n, nil))
+	c.children(n)
+	c.indent--
+	c.line("}")
+}
+
+func (c *converter) leaf(n *node) {
+	keyword := "leaf"
+	if n.has("multi") {
+		keyword = "leaf-list"
+	}
+	c.line("%s %s {", keyword, n.name)
+	c.indent++
+	t := c.newTypeStmt(n, "empty")
+	scripts := c.syntax(n, t)
+	t.write(c)
+	if def, ok := n.field("default"); ok {
+		if keyword == "leaf" && t.base() != "empty" {
+			c.stmt("default", strings.Trim(def, `"`))
+		} else {
+			c.issue(n, "default", "Not allowed on a %s of this type",
+				keyword)
+		}
+	}
+	c.common(n, scripts)
+	c.indent--
+	c.line("}")
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package configd
+
+import "sync"
+
+// Redaction of secrets
+//
+// Whether a caller sees the value of nodes marked as secret used to be
+// decided separately wherever configuration was serialized.  Instead, each
+// serialization path (show, compare, tree get, export, and the session
+// auther used by archives and audit logging) asks the Redactor selected at
+// daemon start, so the policy is applied consistently and can be changed
+// in one place.
+
+const (
+	// Secrets are shown to members of the secrets group (the default)
+	SecretsGroupRedaction = "secrets-group"
+
+	// Secrets are only shown to configd itself
+	StrictRedaction = "strict"
+)
+
+// Redactor decides whether secrets are shown to the caller.
+type Redactor interface {
+	ShowSecrets(ctx *Context) bool
+}
+
+type secretsGroupRedactor struct{}
+
+func (secretsGroupRedactor) ShowSecrets(ctx *Context) bool {
+	return InSecretsGroup(ctx)
+}
+
+type strictRedactor struct{}
+
+func (strictRedactor) ShowSecrets(ctx *Context) bool {
+	return ctx.Configd
+}
+
+var redactors = struct {
+	mu sync.RWMutex
+	m  map[string]Redactor
+}{
+	m: map[string]Redactor{
+		SecretsGroupRedaction: secretsGroupRedactor{},
+		StrictRedaction:       strictRedactor{},
+	},
+}
+
+// RegisterRedactor makes a redactor available for selection by name,
+// replacing any existing redactor of that name.
+func RegisterRedactor(name string, r Redactor) {
+	redactors.mu.Lock()
+	defer redactors.mu.Unlock()
+	redactors.m[name] = r
+}
+
+func IsRedactionMode(mode string) bool {
+	redactors.mu.RLock()
+	defer redactors.mu.RUnlock()
+	_, ok := redactors.m[mode]
+	return ok
+}
+
+// ShowSecrets returns true if the redactor configured for the daemon
+// allows the caller to see secrets.
+func ShowSecrets(ctx *Context) bool {
+	mode := SecretsGroupRedaction
+	if ctx.Config != nil && ctx.Config.Redaction != "" {
+		mode = ctx.Config.Redaction
+	}
+
+	redactors.mu.RLock()
+	r, ok := redactors.m[mode]
+	redactors.mu.RUnlock()
+	if !ok {
+		r = secretsGroupRedactor{}
+	}
+	return r.ShowSecrets(ctx)
+}
@@ -14,6 +14,31 @@ import (
 	"github.com/danos/mgmterror"
 )
 
+// Protocol versions
+//
+// Clients announce the protocol version they speak by calling
+// ProtocolVersion once connected, and the connection then uses the lower of
+// theirs and the daemon's.  Clients that don't, including all those from
+// before versions were introduced, are taken to speak ProtocolVersion1.
+//
+// Requests and responses only ever evolve by adding fields, which are
+// ignored by peers that don't know about them, so fields must never be
+// removed, renamed or change type.  Likewise arguments are only added to the
+// end of a method's arguments.  ProtocolVersion1 clients may leave these
+// out, and they are passed to the method as zero values; later clients
+// must provide every argument.
+const (
+	ProtocolVersion1 = 1
+	ProtocolVersion2 = 2
+	// Clients may send requests without waiting for earlier responses,
+	// which may be returned out of order, and are matched by Id.
+	ProtocolVersion3 = 3
+
+	// ProtocolVersion is the latest version, spoken by this package's
+	// users.
+	ProtocolVersion = ProtocolVersion3
+)
+
 //Request represents an RPC request
 type Request struct {
 	//Op is the method that was called via json rpc
@@ -40,6 +65,109 @@ type Response struct {
 	Id int `json:"id"`
 }
 
+// PathOp is an operation in a batch applied by SetMultiple, Op being
+// "set" or "delete".
+type PathOp struct {
+	Op   string `json:"op"`
+	Path string `json:"path"`
+}
+
+// TreeChange is a change to a node returned by CompareTree, Op being
+// "create", "delete" or "update".  Old and New hold leaf and leaf-list
+// values.
+type TreeChange struct {
+	Op   string `json:"op"`
+	Path string `json:"path"`
+	Old  string `json:"old,omitempty"`
+	New  string `json:"new,omitempty"`
+}
+
+// SchemaDefault is a schema node with a default, and the default.
+type SchemaDefault struct {
+	Path  string `json:"path"`
+	Value string `json:"value"`
+}
+
+// SchemaAudit is returned by GetSchemaAudit.
+type SchemaAudit struct {
+	Defaults  []SchemaDefault `json:"defaults"`
+	Mandatory []string        `json:"mandatory"`
+	Presence  []string        `json:"presence"`
+}
+
+// FailureError is one of the errors from a failed validation or commit,
+// with the model of the component it is attributed to, if known.
+type FailureError struct {
+	Path      string `json:"path"`
+	Message   string `json:"message"`
+	Component string `json:"component,omitempty"`
+}
+
+// LastFailure is a session's most recent failed validation or commit, as
+// returned by GetLastError.
+type LastFailure struct {
+	Operation string         `json:"operation"`
+	Time      string         `json:"time"`
+	Errors    []FailureError `json:"errors"`
+}
+
+// ReplicatedRevision is a commit replicated to a warm standby, with the
+// configuration resulting from it.
+type ReplicatedRevision struct {
+	CommitId uint64       `json:"commit-id"`
+	User     string       `json:"user"`
+	Time     string       `json:"time"`
+	Message  string       `json:"message"`
+	Changes  []TreeChange `json:"changes"`
+	Config   string       `json:"config"`
+}
+
+// LeafChange is a commit's change to a leaf watched with WatchLeaf, Op
+// being "create", "delete" or "update".  Dropped is the number of changes
+// discarded, as they weren't waited for, since the last returned.
+type LeafChange struct {
+	CommitId uint64 `json:"commit-id"`
+	User     string `json:"user"`
+	Time     string `json:"time"`
+	Op       string `json:"op"`
+	Path     string `json:"path"`
+	Old      string `json:"old,omitempty"`
+	New      string `json:"new,omitempty"`
+	Dropped  uint64 `json:"dropped,omitempty"`
+}
+
+// FrozenPath is a configuration subtree frozen with FreezePath, which only
+// superusers may change until it is unfrozen.
+type FrozenPath struct {
+	Path   string `json:"path"`
+	User   string `json:"user"`
+	Time   string `json:"time"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// LockInfo is who holds a session's lock, as returned by SessionLockInfo.
+// Locks held by configd itself have a negative pid.
+type LockInfo struct {
+	Pid    int32  `json:"pid"`
+	User   string `json:"user,omitempty"`
+	Time   string `json:"time,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// AuditRecord is a record of a commit, rollback, load or copy-config
+// returned by GetAuditLog, Result being "success" or "failure".
+type AuditRecord struct {
+	Id        uint64       `json:"id"`
+	Time      string       `json:"time"`
+	Operation string       `json:"operation"`
+	User      string       `json:"user"`
+	Uid       uint32       `json:"uid"`
+	Session   string       `json:"session"`
+	Result    string       `json:"result"`
+	Error     string       `json:"error,omitempty"`
+	Changes   []TreeChange `json:"changes"`
+}
+
 type ExecOutput struct {
 	Path   []string
 	Output string
@@ -127,7 +255,17 @@ const (
 	AUTO DB = iota
 	RUNNING
 	CANDIDATE
+	// EFFECTIVE is the configuration that has been applied.  While a
+	// commit runs it reads as the configuration before the commit until
+	// the commit has been applied in full, then as the committed
+	// configuration; it is never seen part way through a commit.
 	EFFECTIVE
+	// INTENDED is the NMDA (RFC 8342) intended datastore.  configd has no
+	// inactive or templated configuration, so it reads as RUNNING.
+	INTENDED
+	// OPERATIONAL is the NMDA operational datastore: the configuration
+	// that has been applied, as EFFECTIVE, merged with state.
+	OPERATIONAL
 )
 
 type NodeType int
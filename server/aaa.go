@@ -5,6 +5,8 @@ package server
 import (
 	"github.com/danos/config/auth"
 	"github.com/danos/config/schema"
+	"github.com/danos/configd/common"
+	"github.com/danos/configd/session"
 	"github.com/danos/utils/pathutil"
 )
 
@@ -28,18 +30,18 @@ func (d *Disp) newCommandArgs(cmd string, args []string, pathArgs []string) *com
 
 	// Ensure any path arguments are expanded
 	// The arguments should already have been normalized (if required for the command)
-	pathArgs, err := d.expandPath(pathArgs, NoPrefix, InvalidPos)
+	pathArgs, err := d.expandPath(pathArgs, NoPrefix, InvalidPos, nil)
 	if err != nil {
 		return nil
 	}
 
 	// Attempt to generate attributes for any path arguments to the command.
 	// If we failed to generate attributes for the path we can still attempt
-	// to authorize the command since the attributes may not always be required.
+	// to authorize the command since the attributes may not always be required,
+	// but must still tell the accounter which arguments are secrets.
 	attrs := schema.AttrsForPath(d.msFull, pathArgs)
-	if attrs == nil {
-		newAttrs := pathutil.NewPathAttrs()
-		attrs = &newAttrs
+	if attrs == nil || len(attrs.Attrs) != len(pathArgs) {
+		attrs = secretPathAttrs(d.msFull, pathArgs)
 	}
 
 	// We also need to generate attributes for the command and any arguments which
@@ -58,6 +60,18 @@ func (d *Disp) newCommandArgs(cmd string, args []string, pathArgs []string) *com
 	return &commandArgs{cmd: append(cmdArgs, pathArgs...), attrs: attrs}
 }
 
+// secretPathAttrs returns attributes for the path marking which elements
+// are secrets, see session.SecretPathElems.
+func secretPathAttrs(sch schema.Node, path []string) *pathutil.PathAttrs {
+	attrs := pathutil.NewPathAttrs()
+	for _, secret := range session.SecretPathElems(sch, path) {
+		elemAttrs := pathutil.NewPathElementAttrs()
+		elemAttrs.Secret = secret
+		attrs.Attrs = append(attrs.Attrs, elemAttrs)
+	}
+	return &attrs
+}
+
 func (d *Disp) newCommandArgsForAaa(cmd string, args []string, pathArgs []string) *commandArgs {
 	// Shortcut - since AAA does not happen with elevated privileges
 	if d.ctx.Configd {
@@ -75,13 +89,23 @@ func (d *Disp) authCommand(args *commandArgs) bool {
 		return false
 	}
 
-	return d.ctx.Auth.AuthorizeCommand(d.ctx.Uid, d.ctx.Groups, args.cmd, args.attrs)
+	if !d.ctx.Auth.AuthorizeCommand(d.ctx.Uid, d.ctx.Groups, args.cmd, args.attrs) {
+		// Only the command's name, as its arguments may be secret.
+		common.Log(common.LevelDebug, common.TypeAaa,
+			"Command not authorized", "user", d.ctx.User,
+			"command", args.cmd[0])
+		return false
+	}
+	return true
 }
 
 func (d *Disp) getAccounter(args *commandArgs) auth.TaskAccounter {
 	if args == nil {
 		return nil
 	}
+	if d.protocolOrigin() {
+		args = args.withOrigin(d.origin)
+	}
 
 	return d.ctx.Auth.NewTaskAccounter(d.ctx.Uid, d.ctx.Groups, args.cmd, args.attrs)
 }
@@ -114,3 +138,10 @@ func (d *Disp) accountCmdWrapBoolErr(
 	ret, err := d.accountCmdWrap(args, fn)
 	return ret.(bool), err
 }
+
+func (d *Disp) accountCmdWrapStrSliceErr(
+	args *commandArgs, fn func() (interface{}, error)) ([]string, error,
+) {
+	ret, err := d.accountCmdWrap(args, fn)
+	return ret.([]string), err
+}
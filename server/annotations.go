@@ -0,0 +1,119 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/danos/config/auth"
+	"github.com/danos/configd/common"
+	"github.com/danos/configd/rpc"
+	"github.com/danos/mgmterror"
+	"github.com/danos/utils/pathutil"
+)
+
+// Annotations are held by the commit manager (see session/annotations.go).
+// Setting or removing one requires permission to update the annotated
+// path, so tools can only annotate configuration they could change.
+
+// TreeGet returns annotations under this member of the top-level object,
+// named as for RFC 7952 metadata.
+const treeAnnotationsMember = "@configd:annotations"
+
+func (d *Disp) authAnnotation(ps []string) error {
+	if err := d.validatePath(ps); err != nil {
+		return common.FormatConfigPathError(err)
+	}
+	if !d.ctx.Superuser && !d.authPath(ps, auth.P_UPDATE) {
+		return mgmterror.NewAccessDeniedApplicationError()
+	}
+	return nil
+}
+
+func (d *Disp) SetAnnotation(path, key, value string) (bool, error) {
+	ps := pathutil.Makepath(path)
+	if err := d.authAnnotation(ps); err != nil {
+		return false, err
+	}
+	if key == "" {
+		err := mgmterror.NewInvalidValueApplicationError()
+		err.Message = "Annotation key must not be empty"
+		return false, err
+	}
+	if !d.getROSession(rpc.RUNNING, "").Exists(d.ctx, ps) {
+		err := mgmterror.NewInvalidValueApplicationError()
+		err.Path = pathutil.Pathstr(ps)
+		err.Message = "Only configured paths may be annotated"
+		return false, err
+	}
+	if err := d.cmgr.SetAnnotation(d.ctx.Config.Runfile, ps, key,
+		value); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (d *Disp) DeleteAnnotation(path, key string) (bool, error) {
+	ps := pathutil.Makepath(path)
+	if err := d.authAnnotation(ps); err != nil {
+		return false, err
+	}
+	found, err := d.cmgr.DeleteAnnotation(d.ctx.Config.Runfile, ps, key)
+	if err != nil {
+		return false, err
+	}
+	if !found {
+		err := mgmterror.NewInvalidValueApplicationError()
+		err.Path = pathutil.Pathstr(ps)
+		err.Message = fmt.Sprintf("No annotation %s", key)
+		return false, err
+	}
+	return true, nil
+}
+
+// GetAnnotations returns the annotations at or below path that the caller
+// may read, keyed by path.
+func (d *Disp) GetAnnotations(path string) (map[string]map[string]string, error) {
+	all := d.cmgr.Annotations(pathutil.Makepath(path))
+	out := make(map[string]map[string]string, len(all))
+	for pstr, kvs := range all {
+		if d.authRead(pathutil.Makepath(pstr)) {
+			out[pstr] = kvs
+		}
+	}
+	return out, nil
+}
+
+func checkAnnotationsEncoding(encoding string) error {
+	switch encoding {
+	case "json", "rfc7951":
+		return nil
+	}
+	err := mgmterror.NewInvalidValueApplicationError()
+	err.Message = fmt.Sprintf(
+		"Annotations are not supported for %s encoding", encoding)
+	return err
+}
+
+func (d *Disp) addTreeAnnotations(out string, ps []string) (string, error) {
+	anns, _ := d.GetAnnotations(pathutil.Pathstr(ps))
+	if len(anns) == 0 {
+		return out, nil
+	}
+	var obj map[string]interface{}
+	if err := json.Unmarshal([]byte(out), &obj); err != nil {
+		return out, err
+	}
+	if obj == nil {
+		obj = make(map[string]interface{})
+	}
+	obj[treeAnnotationsMember] = anns
+	buf, err := json.Marshal(obj)
+	if err != nil {
+		return out, err
+	}
+	return string(buf), nil
+}
@@ -0,0 +1,136 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/danos/config/auth"
+	"github.com/danos/configd/rpc"
+	"github.com/danos/configd/server"
+)
+
+const annotationsSchema = `
+container first {
+	leaf value {
+		type string;
+	}
+}
+container second {
+	leaf value {
+		type string;
+	}
+}`
+
+const annotationsConfig = `
+first {
+	value one
+}
+second {
+	value two
+}
+`
+
+func newAnnotationsTestDispatcher(t *testing.T) *server.Disp {
+	d := newTestDispatcher(t, auth.TestAutherAllowAll(),
+		annotationsSchema, annotationsConfig)
+	if _, err := d.SetAnnotation("/first", "managed-by", "ansible"); err != nil {
+		t.Fatalf("Unable to set annotation: %s", err)
+	}
+	if _, err := d.SetAnnotation("/second", "ticket", "CHG1234"); err != nil {
+		t.Fatalf("Unable to set annotation: %s", err)
+	}
+	return d
+}
+
+func checkAnnotations(
+	t *testing.T,
+	d *server.Disp,
+	path string,
+	exp map[string]map[string]string,
+) {
+	t.Helper()
+	anns, err := d.GetAnnotations(path)
+	if err != nil {
+		t.Fatalf("Unable to get annotations: %s", err)
+	}
+	if !reflect.DeepEqual(anns, exp) {
+		t.Fatalf("Unexpected annotations.\nExp: %v\nGot: %v", exp, anns)
+	}
+}
+
+func TestAnnotationsSetAndGet(t *testing.T) {
+	d := newAnnotationsTestDispatcher(t)
+
+	checkAnnotations(t, d, "", map[string]map[string]string{
+		"/first":  {"managed-by": "ansible"},
+		"/second": {"ticket": "CHG1234"},
+	})
+	checkAnnotations(t, d, "/first", map[string]map[string]string{
+		"/first": {"managed-by": "ansible"},
+	})
+
+	if _, err := d.DeleteAnnotation("/second", "ticket"); err != nil {
+		t.Fatalf("Unable to delete annotation: %s", err)
+	}
+	checkAnnotations(t, d, "/second", map[string]map[string]string{})
+	if _, err := d.DeleteAnnotation("/second", "ticket"); err == nil {
+		t.Fatalf("Deleting a missing annotation should fail")
+	}
+}
+
+func TestAnnotationsRequireConfiguredPath(t *testing.T) {
+	d := newTestDispatcher(t, auth.TestAutherAllowAll(),
+		annotationsSchema, emptyconfig)
+
+	if _, err := d.SetAnnotation("/first", "managed-by", "ansible"); err == nil {
+		t.Fatalf("Annotating an unconfigured path should fail")
+	}
+	if _, err := d.SetAnnotation("/third", "managed-by", "ansible"); err == nil {
+		t.Fatalf("Annotating an unknown path should fail")
+	}
+}
+
+func TestAnnotationsDroppedWhenTouched(t *testing.T) {
+	d := newAnnotationsTestDispatcher(t)
+	dispTestSetupSession(t, d, testSID)
+	dispTestSet(t, d, testSID, "first/value/changed")
+	dispTestCommit(t, d, testSID)
+
+	checkAnnotations(t, d, "", map[string]map[string]string{
+		"/second": {"ticket": "CHG1234"},
+	})
+}
+
+func TestTreeGetAnnotations(t *testing.T) {
+	d := newAnnotationsTestDispatcher(t)
+
+	out, err := d.TreeGet(rpc.RUNNING, testSID, "first", "json", nil)
+	if err != nil {
+		t.Fatalf("Unexpected tree get error: %s", err)
+	}
+	if strings.Contains(out, "managed-by") {
+		t.Fatalf("Annotations should only be returned on request:\n%s", out)
+	}
+
+	out, err = d.TreeGet(rpc.RUNNING, testSID, "first", "json",
+		map[string]interface{}{"Annotations": true})
+	if err != nil {
+		t.Fatalf("Unexpected tree get error: %s", err)
+	}
+	if !strings.Contains(out, `"@configd:annotations"`) ||
+		!strings.Contains(out, `"managed-by":"ansible"`) ||
+		strings.Contains(out, "CHG1234") {
+		t.Fatalf("Unexpected annotations in tree:\n%s", out)
+	}
+
+	_, err = d.TreeGet(rpc.RUNNING, testSID, "first", "xml",
+		map[string]interface{}{"Annotations": true})
+	if err == nil {
+		t.Fatalf("Annotations should not be supported for XML")
+	}
+}
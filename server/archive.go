@@ -0,0 +1,356 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/danos/config/data"
+	"github.com/danos/config/union"
+	"github.com/danos/configd/server/archive"
+	"github.com/danos/configd/session"
+	"github.com/danos/mgmterror"
+)
+
+// Archive retention
+//
+// Each commit archives the configuration in configDir/archive as
+// config.boot.<n>.gz, with revision 0 the most recent.  How many revisions
+// were kept used to be decided by the scripts writing the archive; instead
+// the retention policy is held here, where operators can see and change it,
+// and the archive is pruned against it periodically and on demand.  Older
+// revisions are always removed first, and the most recent revision is
+// never removed.  Tagged revisions are kept regardless of the policy.  A
+// limit of 0 means no limit.
+//
+// Without a policy the archive is limited to defaultArchiveRevisions.  The
+// first commit archived without a policy imports the commit-revisions
+// setting the scripts used, if there is one, as the policy.
+
+type archivePolicy struct {
+	MaxRevisions uint64 `json:"max-revisions"`
+	MaxAge       uint64 `json:"max-age"`        // seconds
+	MinFreeSpace uint64 `json:"min-free-space"` // bytes
+}
+
+const (
+	ArchivePruneJob      = "archive-prune"
+	archivePruneInterval = time.Hour
+	archivePolicyFile    = ".retention-policy"
+
+	defaultArchiveRevisions = 100
+)
+
+var commitRevisionsPath = []string{
+	"system", "config-management", "commit-revisions"}
+
+// Serialises changes to the archive and its policy.
+var archiveMu sync.Mutex
+
+func archiveDir() string {
+	return configDir + "/archive"
+}
+
+func readArchivePolicy() (*archivePolicy, error) {
+	policy := &archivePolicy{}
+	buf, err := ioutil.ReadFile(filepath.Join(archiveDir(), archivePolicyFile))
+	if os.IsNotExist(err) {
+		policy.MaxRevisions = defaultArchiveRevisions
+		return policy, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(buf, policy); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+func writeArchivePolicy(policy *archivePolicy) error {
+	buf, err := json.Marshal(policy)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(
+		filepath.Join(archiveDir(), archivePolicyFile), buf, 0644)
+}
+
+func archiveStore() *archive.Store {
+	return archive.New(archiveDir())
+}
+
+type archiveRevision struct {
+	num     int
+	modTime time.Time
+	tagged  bool
+}
+
+// archiveRevisions returns the archived revisions, oldest first.
+func archiveRevisions() ([]archiveRevision, error) {
+	list, err := archiveStore().List()
+	if err != nil {
+		return nil, err
+	}
+	revs := make([]archiveRevision, 0, len(list))
+	for i := len(list) - 1; i >= 0; i-- {
+		revs = append(revs, archiveRevision{
+			num:     list[i].Num,
+			modTime: list[i].Time,
+			tagged:  len(list[i].Tags) > 0,
+		})
+	}
+	return revs, nil
+}
+
+func archiveFreeSpace() (uint64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(archiveDir(), &st); err != nil {
+		return 0, err
+	}
+	return st.Bavail * uint64(st.Bsize), nil
+}
+
+// pruneArchive removes revisions not allowed by the policy, returning the
+// revision numbers removed.
+func pruneArchive(policy *archivePolicy, now time.Time) ([]string, error) {
+	revs, err := archiveRevisions()
+	if err != nil {
+		return nil, err
+	}
+
+	removed := []string{}
+	if len(revs) == 0 {
+		return removed, nil
+	}
+	remaining := len(revs)
+	for _, rev := range revs[:len(revs)-1] {
+		tooMany := policy.MaxRevisions != 0 &&
+			uint64(remaining) > policy.MaxRevisions
+		tooOld := policy.MaxAge != 0 &&
+			now.Sub(rev.modTime) > time.Duration(policy.MaxAge)*time.Second
+		tooFull := false
+		if policy.MinFreeSpace != 0 {
+			free, err := archiveFreeSpace()
+			if err != nil {
+				return removed, err
+			}
+			tooFull = free < policy.MinFreeSpace
+		}
+		if !tooMany && !tooOld && !tooFull {
+			break
+		}
+		if rev.tagged {
+			continue
+		}
+		if err := archiveStore().Remove(rev.num); err != nil {
+			return removed, err
+		}
+		removed = append(removed, strconv.Itoa(rev.num))
+		remaining--
+	}
+	return removed, nil
+}
+
+func runArchivePrune() error {
+	archiveMu.Lock()
+	defer archiveMu.Unlock()
+	if _, err := os.Stat(archiveDir()); os.IsNotExist(err) {
+		return nil
+	}
+	policy, err := readArchivePolicy()
+	if err != nil {
+		return err
+	}
+	_, err = pruneArchive(policy, time.Now())
+	return err
+}
+
+func (d *Disp) GetArchivePolicy() (map[string]uint64, error) {
+	archiveMu.Lock()
+	defer archiveMu.Unlock()
+	policy, err := readArchivePolicy()
+	if err != nil {
+		return nil, err
+	}
+	return map[string]uint64{
+		"max-revisions":  policy.MaxRevisions,
+		"max-age":        policy.MaxAge,
+		"min-free-space": policy.MinFreeSpace,
+	}, nil
+}
+
+// SetArchivePolicy sets the retention policy, with max age in seconds and
+// min free space in bytes.  The archive is pruned against the new policy
+// when it is next due; use PruneArchive to apply it now.
+func (d *Disp) SetArchivePolicy(
+	maxRevisions, maxAge, minFreeSpace uint64,
+) (bool, error) {
+	if !d.ctx.Superuser {
+		return false, mgmterror.NewAccessDeniedApplicationError()
+	}
+	archiveMu.Lock()
+	defer archiveMu.Unlock()
+	if _, err := os.Stat(archiveDir()); err != nil {
+		operr := mgmterror.NewOperationFailedApplicationError()
+		operr.Message = fmt.Sprintf("Archive %s is not available",
+			archiveDir())
+		return false, operr
+	}
+	err := writeArchivePolicy(&archivePolicy{
+		MaxRevisions: maxRevisions,
+		MaxAge:       maxAge,
+		MinFreeSpace: minFreeSpace,
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// PruneArchive applies the retention policy now, returning the revisions
+// removed.
+func (d *Disp) PruneArchive() ([]string, error) {
+	if !d.ctx.Superuser {
+		return nil, mgmterror.NewAccessDeniedApplicationError()
+	}
+	archiveMu.Lock()
+	defer archiveMu.Unlock()
+	policy, err := readArchivePolicy()
+	if err != nil {
+		return nil, err
+	}
+	return pruneArchive(policy, time.Now())
+}
+
+// importArchivePolicy writes the commit-revisions setting in t, if there
+// is one, as the policy when there is no policy yet.
+func importArchivePolicy(t *data.Node) error {
+	file := filepath.Join(archiveDir(), archivePolicyFile)
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		return nil
+	}
+	val, ok := committedLeafValue(t, commitRevisionsPath)
+	if !ok || val == "" {
+		return nil
+	}
+	max, err := strconv.ParseUint(val, 10, 64)
+	if err != nil {
+		return err
+	}
+	return writeArchivePolicy(&archivePolicy{MaxRevisions: max})
+}
+
+// archiveCommit archives the configuration committed, with secrets,
+// encrypted if a key is set, as the most recent revision, then prunes the
+// archive against the retention policy.
+func (s *Srv) archiveCommit(n *session.CommitNotification) {
+	ms, _, _ := s.schemas()
+	t, err := session.EncryptSecrets(ms, n.New)
+	if err != nil {
+		s.LogError(err)
+		return
+	}
+	cfg, err := union.NewNode(nil, t, ms, nil, 0).Show(
+		nil, union.ForceShowSecrets)
+	if err != nil {
+		s.LogError(err)
+		return
+	}
+	archiveMu.Lock()
+	defer archiveMu.Unlock()
+	err = archiveStore().Add([]byte(cfg+getCurrentConfigVersion()),
+		archive.Revision{
+			Time:    n.Time,
+			User:    n.User,
+			Comment: n.Message,
+		})
+	if err != nil {
+		s.LogError(err)
+		return
+	}
+	if err := importArchivePolicy(n.New); err != nil {
+		s.LogError(err)
+	}
+	policy, err := readArchivePolicy()
+	if err != nil {
+		s.LogError(err)
+		return
+	}
+	if _, err := pruneArchive(policy, time.Now()); err != nil {
+		s.LogError(err)
+	}
+}
+
+// resolveConfigRevision returns the number of the archived revision named
+// by number or tag.
+func resolveConfigRevision(revision string) (string, error) {
+	num, err := archiveStore().Resolve(revision)
+	if err != nil {
+		return "", err
+	}
+	return strconv.Itoa(num), nil
+}
+
+// ListConfigRevisions returns the archived revisions by number, each with
+// its time, user, via, comment and a comma separated list of its tags.
+func (d *Disp) ListConfigRevisions() (map[string]map[string]string, error) {
+	revs, err := archiveStore().List()
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]map[string]string)
+	for _, rev := range revs {
+		out[strconv.Itoa(rev.Num)] = map[string]string{
+			"time":    rev.Time.Format(time.RFC3339),
+			"user":    rev.User,
+			"via":     rev.Via,
+			"comment": rev.Comment,
+			"tags":    strings.Join(rev.Tags, ","),
+		}
+	}
+	return out, nil
+}
+
+// TagConfigRevision names the archived revision so that it can be referred
+// to by tag, wherever a revision number is accepted, as newer revisions
+// push it back.  Tagged revisions are not pruned.
+func (d *Disp) TagConfigRevision(revision, tag string) (bool, error) {
+	if !d.ctx.Superuser {
+		return false, mgmterror.NewAccessDeniedApplicationError()
+	}
+	archiveMu.Lock()
+	defer archiveMu.Unlock()
+	store := archiveStore()
+	num, err := store.Resolve(revision)
+	if err != nil {
+		return false, err
+	}
+	if err := store.Tag(num, tag); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (d *Disp) UntagConfigRevision(tag string) (bool, error) {
+	if !d.ctx.Superuser {
+		return false, mgmterror.NewAccessDeniedApplicationError()
+	}
+	archiveMu.Lock()
+	defer archiveMu.Unlock()
+	if err := archiveStore().Untag(tag); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,465 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+// Package archive is the store of configuration revisions archived on
+// commit.
+//
+// Revisions are kept as config.boot.<n>.gz in the archive directory, with
+// revision 0 the most recent, and the commit log records who made each
+// one, one line per revision in the same order.  This is the layout the
+// vyatta-config-mgmt.pl tool used, so existing archives carry over.  Tags
+// name revisions so they can be found again as newer revisions push them
+// back; they follow their revision as it is renumbered, and go when it is
+// removed.
+//
+// A Store does no locking of its own; callers must serialise changes to
+// the archive.
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/danos/mgmterror"
+)
+
+const (
+	CommitLogFile = "commits"
+	TagsFile      = ".tags"
+)
+
+var (
+	revisionRe = regexp.MustCompile(`^config\.boot\.([0-9]+)\.gz$`)
+	tagRe      = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_.-]*$`)
+)
+
+type Revision struct {
+	Num     int
+	Time    time.Time
+	User    string
+	Via     string
+	Comment string
+	Tags    []string
+}
+
+// Brief describes the revision as the commit log always has.
+func (r *Revision) Brief() string {
+	out := r.Time.Format("2006-01-02 15:04:05") + " by " + r.User
+	if r.Via != "" {
+		out += " via " + r.Via
+	}
+	return out
+}
+
+// Summary is the brief description followed by the revision's comment, if
+// it has one, so revisions can be told apart by what they were for.
+func (r *Revision) Summary() string {
+	if r.Comment == "" {
+		return r.Brief()
+	}
+	return r.Brief() + ": " + r.Comment
+}
+
+type Store struct {
+	dir string
+}
+
+func New(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// FileName returns the file holding the revision.
+func (s *Store) FileName(num int) string {
+	return filepath.Join(s.dir, "config.boot."+strconv.Itoa(num)+".gz")
+}
+
+func newNoRevisionError(name string) error {
+	err := mgmterror.NewOperationFailedApplicationError()
+	err.Message = "Invalid revision [" + name + "]"
+	return err
+}
+
+type logEntry struct {
+	time    time.Time
+	user    string
+	via     string
+	comment string
+}
+
+// Commit log lines are |<unix time>|<user>|<via>|<comment>|
+func parseLogEntry(line string) logEntry {
+	var e logEntry
+	fields := strings.Split(strings.Trim(line, "|"), "|")
+	if len(fields) > 0 {
+		if secs, err := strconv.ParseInt(fields[0], 10, 64); err == nil {
+			e.time = time.Unix(secs, 0)
+		}
+	}
+	if len(fields) > 1 {
+		e.user = fields[1]
+	}
+	if len(fields) > 2 {
+		e.via = fields[2]
+	}
+	if len(fields) > 3 {
+		e.comment = strings.Join(fields[3:], "|")
+	}
+	return e
+}
+
+func (e logEntry) String() string {
+	clean := func(s string) string {
+		return strings.NewReplacer("|", " ", "\n", " ").Replace(s)
+	}
+	return fmt.Sprintf("|%d|%s|%s|%s|", e.time.Unix(), clean(e.user),
+		clean(e.via), clean(e.comment))
+}
+
+func (s *Store) readLog() ([]logEntry, error) {
+	buf, err := ioutil.ReadFile(filepath.Join(s.dir, CommitLogFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var log []logEntry
+	for _, line := range strings.Split(string(buf), "\n") {
+		if line != "" {
+			log = append(log, parseLogEntry(line))
+		}
+	}
+	return log, nil
+}
+
+// writeFile replaces the file in the archive with one holding buf, so
+// that it is never left part written.
+func (s *Store) writeFile(name string, buf []byte) error {
+	f, err := ioutil.TempFile(s.dir, "."+name+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(buf); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filepath.Join(s.dir, name))
+}
+
+func (s *Store) writeLog(log []logEntry) error {
+	var b bytes.Buffer
+	for _, e := range log {
+		b.WriteString(e.String() + "\n")
+	}
+	return s.writeFile(CommitLogFile, b.Bytes())
+}
+
+func (s *Store) readTags() (map[string]int, error) {
+	tags := make(map[string]int)
+	buf, err := ioutil.ReadFile(filepath.Join(s.dir, TagsFile))
+	if os.IsNotExist(err) {
+		return tags, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(buf, &tags); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+func (s *Store) writeTags(tags map[string]int) error {
+	buf, err := json.Marshal(tags)
+	if err != nil {
+		return err
+	}
+	return s.writeFile(TagsFile, buf)
+}
+
+// revisionNums returns the numbers of the archived revisions, most recent
+// first.
+func (s *Store) revisionNums() ([]int, error) {
+	infos, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var nums []int
+	for _, info := range infos {
+		m := revisionRe.FindStringSubmatch(info.Name())
+		if m == nil {
+			continue
+		}
+		num, _ := strconv.Atoi(m[1])
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+	return nums, nil
+}
+
+// List returns the archived revisions, most recent first.  Revisions
+// missing from the commit log are dated by their file.
+func (s *Store) List() ([]Revision, error) {
+	nums, err := s.revisionNums()
+	if err != nil {
+		return nil, err
+	}
+	log, err := s.readLog()
+	if err != nil {
+		return nil, err
+	}
+	tags, err := s.readTags()
+	if err != nil {
+		return nil, err
+	}
+	byNum := make(map[int][]string)
+	for tag, num := range tags {
+		byNum[num] = append(byNum[num], tag)
+	}
+
+	revs := make([]Revision, 0, len(nums))
+	for _, num := range nums {
+		rev := Revision{Num: num, Tags: byNum[num]}
+		sort.Strings(rev.Tags)
+		if num < len(log) {
+			e := log[num]
+			rev.Time, rev.User, rev.Via, rev.Comment =
+				e.time, e.user, e.via, e.comment
+		}
+		if rev.Time.IsZero() {
+			if info, err := os.Stat(s.FileName(num)); err == nil {
+				rev.Time = info.ModTime()
+			}
+		}
+		revs = append(revs, rev)
+	}
+	return revs, nil
+}
+
+// Resolve returns the number of the revision named by number or tag.
+func (s *Store) Resolve(name string) (int, error) {
+	num, err := strconv.Atoi(name)
+	if err != nil {
+		tags, err := s.readTags()
+		if err != nil {
+			return 0, err
+		}
+		var ok bool
+		if num, ok = tags[name]; !ok {
+			return 0, newNoRevisionError(name)
+		}
+	}
+	if num < 0 {
+		return 0, newNoRevisionError(name)
+	}
+	if _, err := os.Stat(s.FileName(num)); err != nil {
+		return 0, newNoRevisionError(name)
+	}
+	return num, nil
+}
+
+// Add archives cfg as revision 0, renumbering the existing revisions.  The
+// new revision is written before any are renumbered, and renumbering is
+// undone if it fails, so a failure leaves the revisions as they were.  The
+// commit log and tags are then updated to match.
+func (s *Store) Add(cfg []byte, rev Revision) error {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+	nums, err := s.revisionNums()
+	if err != nil {
+		return err
+	}
+	log, err := s.readLog()
+	if err != nil {
+		return err
+	}
+	tags, err := s.readTags()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := s.writeTemp(cfg)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	for i := len(nums) - 1; i >= 0; i-- {
+		err := os.Rename(s.FileName(nums[i]), s.FileName(nums[i]+1))
+		if err != nil {
+			s.undoRenumber(nums[i+1:])
+			return err
+		}
+	}
+	if err := os.Rename(tmp, s.FileName(0)); err != nil {
+		s.undoRenumber(nums)
+		return err
+	}
+
+	for tag := range tags {
+		tags[tag]++
+	}
+	// Drop log entries left behind by revisions removed from the end.
+	keep := 0
+	if len(nums) > 0 {
+		keep = nums[len(nums)-1] + 1
+	}
+	if len(log) > keep {
+		log = log[:keep]
+	}
+	if rev.Time.IsZero() {
+		rev.Time = time.Now()
+	}
+	log = append([]logEntry{{
+		time:    rev.Time,
+		user:    rev.User,
+		via:     rev.Via,
+		comment: rev.Comment,
+	}}, log...)
+
+	if err := s.writeLog(log); err != nil {
+		return err
+	}
+	return s.writeTags(tags)
+}
+
+// undoRenumber moves the revisions given, which have been renumbered, back
+// to their original numbers.
+func (s *Store) undoRenumber(nums []int) {
+	for _, num := range nums {
+		os.Rename(s.FileName(num+1), s.FileName(num))
+	}
+}
+
+// writeTemp writes the compressed cfg to a temporary file in the archive,
+// returning its name.
+func (s *Store) writeTemp(cfg []byte) (string, error) {
+	f, err := ioutil.TempFile(s.dir, ".config.boot.")
+	if err != nil {
+		return "", err
+	}
+	w := gzip.NewWriter(f)
+	_, err = w.Write(cfg)
+	if err == nil {
+		err = w.Close()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0660)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// Open returns a reader of the uncompressed revision.
+func (s *Store) Open(num int) (io.ReadCloser, error) {
+	f, err := os.Open(s.FileName(num))
+	if err != nil {
+		return nil, newNoRevisionError(strconv.Itoa(num))
+	}
+	r, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{r, f}, nil
+}
+
+// Read returns the uncompressed revision.
+func (s *Store) Read(num int) ([]byte, error) {
+	r, err := s.Open(num)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// Remove removes the given revisions, and their tags.  The remaining
+// revisions keep their numbers.
+func (s *Store) Remove(nums ...int) error {
+	tags, err := s.readTags()
+	if err != nil {
+		return err
+	}
+	for _, num := range nums {
+		if err := os.Remove(s.FileName(num)); err != nil &&
+			!os.IsNotExist(err) {
+			return err
+		}
+		for tag, tagged := range tags {
+			if tagged == num {
+				delete(tags, tag)
+			}
+		}
+	}
+	return s.writeTags(tags)
+}
+
+func newInvalidTagError(tag string) error {
+	err := mgmterror.NewInvalidValueApplicationError()
+	err.Message = "Invalid tag [" + tag + "]: must start with a letter " +
+		"and contain only letters, digits, '.', '_' and '-'"
+	return err
+}
+
+// Tag names the revision, moving the tag if it already names another.
+func (s *Store) Tag(num int, tag string) error {
+	if !tagRe.MatchString(tag) {
+		return newInvalidTagError(tag)
+	}
+	if _, err := os.Stat(s.FileName(num)); err != nil {
+		return newNoRevisionError(strconv.Itoa(num))
+	}
+	tags, err := s.readTags()
+	if err != nil {
+		return err
+	}
+	tags[tag] = num
+	return s.writeTags(tags)
+}
+
+func (s *Store) Untag(tag string) error {
+	tags, err := s.readTags()
+	if err != nil {
+		return err
+	}
+	if _, ok := tags[tag]; !ok {
+		err := mgmterror.NewInvalidValueApplicationError()
+		err.Message = "No tag [" + tag + "]"
+		return err
+	}
+	delete(tags, tag)
+	return s.writeTags(tags)
+}
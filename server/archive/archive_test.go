@@ -0,0 +1,175 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package archive
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func newTestStore(t *testing.T, n int) (*Store, func()) {
+	dir, err := ioutil.TempDir("", "configd-archive")
+	if err != nil {
+		t.Fatalf("Unable to create archive dir: %s", err)
+	}
+	s := New(dir)
+	start := time.Unix(1600000000, 0)
+	for i := 0; i < n; i++ {
+		err := s.Add([]byte("config "+strconv.Itoa(i)), Revision{
+			Time:    start.Add(time.Duration(i) * time.Hour),
+			User:    "user" + strconv.Itoa(i),
+			Comment: "commit " + strconv.Itoa(i),
+		})
+		if err != nil {
+			t.Fatalf("Unable to add revision: %s", err)
+		}
+	}
+	return s, func() { os.RemoveAll(dir) }
+}
+
+func checkRevision(t *testing.T, s *Store, name, expCfg string) {
+	t.Helper()
+	num, err := s.Resolve(name)
+	if err != nil {
+		t.Fatalf("Unable to resolve %s: %s", name, err)
+	}
+	cfg, err := s.Read(num)
+	if err != nil {
+		t.Fatalf("Unable to read %s: %s", name, err)
+	}
+	if string(cfg) != expCfg {
+		t.Fatalf("Unexpected revision %s.\nExp: %s\nGot: %s",
+			name, expCfg, cfg)
+	}
+}
+
+func TestAddRenumbers(t *testing.T) {
+	s, cleanup := newTestStore(t, 3)
+	defer cleanup()
+
+	revs, err := s.List()
+	if err != nil {
+		t.Fatalf("Unable to list revisions: %s", err)
+	}
+	if len(revs) != 3 {
+		t.Fatalf("Expected 3 revisions, got %d", len(revs))
+	}
+	for i, rev := range revs {
+		exp := strconv.Itoa(2 - i)
+		if rev.Num != i || rev.User != "user"+exp ||
+			rev.Comment != "commit "+exp {
+			t.Errorf("Unexpected revision %d: %+v", i, rev)
+		}
+	}
+	checkRevision(t, s, "0", "config 2")
+	checkRevision(t, s, "2", "config 0")
+}
+
+func TestTagFollowsRevision(t *testing.T) {
+	s, cleanup := newTestStore(t, 2)
+	defer cleanup()
+
+	if err := s.Tag(0, "known-good"); err != nil {
+		t.Fatalf("Unable to tag revision: %s", err)
+	}
+	s.Add([]byte("config 2"), Revision{User: "user2"})
+	checkRevision(t, s, "known-good", "config 1")
+
+	revs, _ := s.List()
+	if !reflect.DeepEqual(revs[1].Tags, []string{"known-good"}) {
+		t.Fatalf("Unexpected tags: %v", revs[1].Tags)
+	}
+
+	if err := s.Untag("known-good"); err != nil {
+		t.Fatalf("Unable to untag revision: %s", err)
+	}
+	if _, err := s.Resolve("known-good"); err == nil {
+		t.Fatalf("Removed tag still resolves")
+	}
+}
+
+func TestTagInvalid(t *testing.T) {
+	s, cleanup := newTestStore(t, 1)
+	defer cleanup()
+
+	for _, tag := range []string{"", "1", "a b", "a|b"} {
+		if err := s.Tag(0, tag); err == nil {
+			t.Errorf("Invalid tag %q accepted", tag)
+		}
+	}
+	if err := s.Tag(5, "missing"); err == nil {
+		t.Errorf("Tagged revision that doesn't exist")
+	}
+}
+
+func TestRemoveKeepsLogAligned(t *testing.T) {
+	s, cleanup := newTestStore(t, 4)
+	defer cleanup()
+
+	s.Tag(2, "old")
+	if err := s.Remove(2, 3); err != nil {
+		t.Fatalf("Unable to remove revisions: %s", err)
+	}
+	if _, err := s.Resolve("old"); err == nil {
+		t.Fatalf("Tag of removed revision still resolves")
+	}
+	s.Add([]byte("config 4"), Revision{User: "user4"})
+
+	revs, _ := s.List()
+	var users []string
+	for _, rev := range revs {
+		users = append(users, strconv.Itoa(rev.Num)+":"+rev.User)
+	}
+	exp := []string{"0:user4", "1:user3", "2:user2"}
+	if !reflect.DeepEqual(users, exp) {
+		t.Fatalf("Unexpected revisions.\nExp: %v\nGot: %v", exp, users)
+	}
+}
+
+func TestSummaryIncludesComment(t *testing.T) {
+	s, cleanup := newTestStore(t, 1)
+	defer cleanup()
+	if err := s.Add([]byte("config 1"), Revision{
+		Time: time.Unix(1600000000, 0).UTC(),
+		User: "vyatta",
+		Via:  "cli",
+	}); err != nil {
+		t.Fatalf("Unable to add revision: %s", err)
+	}
+
+	revs, err := s.List()
+	if err != nil {
+		t.Fatalf("Unable to list revisions: %s", err)
+	}
+	if sum := revs[0].Summary(); sum != revs[0].Brief() {
+		t.Fatalf("Unexpected summary without comment: %s", sum)
+	}
+	if sum := revs[1].Summary(); sum != revs[1].Brief()+": commit 0" {
+		t.Fatalf("Unexpected summary with comment: %s", sum)
+	}
+}
+
+func TestAddLeavesNoTempFiles(t *testing.T) {
+	s, cleanup := newTestStore(t, 3)
+	defer cleanup()
+
+	infos, err := ioutil.ReadDir(s.Dir())
+	if err != nil {
+		t.Fatalf("Unable to read archive: %s", err)
+	}
+	var names []string
+	for _, info := range infos {
+		names = append(names, info.Name())
+	}
+	exp := []string{TagsFile, "commits", "config.boot.0.gz",
+		"config.boot.1.gz", "config.boot.2.gz"}
+	if !reflect.DeepEqual(names, exp) {
+		t.Fatalf("Unexpected archive files.\nExp: %v\nGot: %v", exp, names)
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/danos/config/data"
+)
+
+// setupTestArchive creates revisions 0 to n-1, each a day older than the
+// last, returning the config dir to be removed by the caller.
+func setupTestArchive(t *testing.T, n int) string {
+	dir, err := ioutil.TempDir("", "configd-archive")
+	if err != nil {
+		t.Fatalf("Unable to create config dir: %s", err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "archive"), 0755); err != nil {
+		t.Fatalf("Unable to create archive dir: %s", err)
+	}
+	now := time.Now()
+	for i := 0; i < n; i++ {
+		file := filepath.Join(dir, "archive",
+			"config.boot."+strconv.Itoa(i)+".gz")
+		if err := ioutil.WriteFile(file, nil, 0644); err != nil {
+			t.Fatalf("Unable to create revision: %s", err)
+		}
+		mtime := now.Add(-time.Duration(i) * 24 * time.Hour)
+		if err := os.Chtimes(file, mtime, mtime); err != nil {
+			t.Fatalf("Unable to set revision time: %s", err)
+		}
+	}
+	configDir = dir
+	return dir
+}
+
+func checkArchiveRevisions(t *testing.T, exp []int) {
+	t.Helper()
+	revs, err := archiveRevisions()
+	if err != nil {
+		t.Fatalf("Unable to read archive: %s", err)
+	}
+	var nums []int
+	for _, rev := range revs {
+		nums = append(nums, rev.num)
+	}
+	if !reflect.DeepEqual(nums, exp) {
+		t.Fatalf("Unexpected revisions.\nExp: %v\nGot: %v", exp, nums)
+	}
+}
+
+func TestArchivePruneMaxRevisions(t *testing.T) {
+	orig := configDir
+	defer func() { configDir = orig }()
+	defer os.RemoveAll(setupTestArchive(t, 5))
+	d := newSchedulerTestDisp(true)
+
+	if _, err := d.SetArchivePolicy(3, 0, 0); err != nil {
+		t.Fatalf("Unable to set policy: %s", err)
+	}
+	removed, err := d.PruneArchive()
+	if err != nil {
+		t.Fatalf("Unable to prune: %s", err)
+	}
+	if !reflect.DeepEqual(removed, []string{"4", "3"}) {
+		t.Fatalf("Unexpected revisions removed: %v", removed)
+	}
+	checkArchiveRevisions(t, []int{2, 1, 0})
+
+	policy, _ := d.GetArchivePolicy()
+	if policy["max-revisions"] != 3 || policy["max-age"] != 0 {
+		t.Fatalf("Unexpected policy: %v", policy)
+	}
+}
+
+func TestArchivePruneMaxAge(t *testing.T) {
+	orig := configDir
+	defer func() { configDir = orig }()
+	defer os.RemoveAll(setupTestArchive(t, 5))
+
+	// Revision 0 is kept even if it is too old
+	if _, err := pruneArchive(&archivePolicy{MaxAge: 1}, time.Now().Add(
+		48*time.Hour)); err != nil {
+		t.Fatalf("Unable to prune: %s", err)
+	}
+	checkArchiveRevisions(t, []int{0})
+}
+
+func TestArchivePruneNoPolicy(t *testing.T) {
+	orig := configDir
+	defer func() { configDir = orig }()
+	defer os.RemoveAll(setupTestArchive(t, 3))
+
+	if err := runArchivePrune(); err != nil {
+		t.Fatalf("Unable to prune: %s", err)
+	}
+	checkArchiveRevisions(t, []int{2, 1, 0})
+}
+
+func TestArchivePolicyDefault(t *testing.T) {
+	orig := configDir
+	defer func() { configDir = orig }()
+	defer os.RemoveAll(setupTestArchive(t, 0))
+	d := newSchedulerTestDisp(true)
+
+	policy, err := d.GetArchivePolicy()
+	if err != nil {
+		t.Fatalf("Unable to get policy: %s", err)
+	}
+	if policy["max-revisions"] != defaultArchiveRevisions {
+		t.Fatalf("Unexpected default policy: %v", policy)
+	}
+}
+
+func TestArchivePolicyRequiresSuperuser(t *testing.T) {
+	d := newSchedulerTestDisp(false)
+	if _, err := d.SetArchivePolicy(1, 0, 0); err == nil {
+		t.Fatalf("Only superusers should be able to set the policy")
+	}
+	if _, err := d.PruneArchive(); err == nil {
+		t.Fatalf("Only superusers should be able to prune")
+	}
+}
+
+func TestArchivePruneKeepsTagged(t *testing.T) {
+	orig := configDir
+	defer func() { configDir = orig }()
+	defer os.RemoveAll(setupTestArchive(t, 5))
+	d := newSchedulerTestDisp(true)
+
+	if _, err := d.TagConfigRevision("4", "baseline"); err != nil {
+		t.Fatalf("Unable to tag revision: %s", err)
+	}
+	removed, err := pruneArchive(&archivePolicy{MaxRevisions: 2}, time.Now())
+	if err != nil {
+		t.Fatalf("Unable to prune: %s", err)
+	}
+	if !reflect.DeepEqual(removed, []string{"3", "2", "1"}) {
+		t.Fatalf("Unexpected revisions removed: %v", removed)
+	}
+	checkArchiveRevisions(t, []int{4, 0})
+}
+
+func commitRevisionsTree(val string) *data.Node {
+	t := data.New("root")
+	n := t
+	for _, elem := range append(commitRevisionsPath, val) {
+		ch := data.New(elem)
+		n.AddChild(ch)
+		n = ch
+	}
+	return t
+}
+
+func TestArchivePolicyImported(t *testing.T) {
+	orig := configDir
+	defer func() { configDir = orig }()
+	defer os.RemoveAll(setupTestArchive(t, 0))
+
+	if err := importArchivePolicy(commitRevisionsTree("20")); err != nil {
+		t.Fatalf("Unable to import policy: %s", err)
+	}
+	// Only imported when there is no policy
+	if err := importArchivePolicy(commitRevisionsTree("30")); err != nil {
+		t.Fatalf("Unable to import policy: %s", err)
+	}
+	policy, err := readArchivePolicy()
+	if err != nil {
+		t.Fatalf("Unable to read policy: %s", err)
+	}
+	if policy.MaxRevisions != 20 {
+		t.Fatalf("Unexpected policy: %+v", policy)
+	}
+}
@@ -0,0 +1,239 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/danos/config/data"
+	"github.com/danos/config/diff"
+	"github.com/danos/configd/rpc"
+	"github.com/danos/configd/session"
+	"github.com/danos/mgmterror"
+	"github.com/danos/utils/pathutil"
+)
+
+// Audit log
+//
+// Commits were only recorded as free text in syslog, which tools reviewing
+// who changed what had to scrape.  Every commit, rollback, load and
+// copy-config now appends a structured record to a file of JSON lines
+// alongside the runfile, with who ran it, from which session, whether it
+// succeeded, and the changes it made to the datastore it targets (running,
+// or the session's candidate for a load) as for CompareTree, with secrets
+// always hidden, as they are in the paths of any errors.  The changes are taken from the datastore before and after
+// the operation, so may include those of a commit made in between.  The
+// log may only be read by superusers and configd, with GetAuditLog.
+
+const (
+	AuditCommit     = "commit"
+	AuditRollback   = "rollback"
+	AuditLoad       = "load"
+	AuditCopyConfig = "copy-config"
+
+	auditSuccess = "success"
+	auditFailure = "failure"
+
+	// GetAuditLog returns at most this many records.
+	maxAuditRecords = 1000
+)
+
+func auditFileForRunfile(runfile string) string {
+	return runfile + ".audit"
+}
+
+// Serialises appending to the audit log, and numbering its records.
+var auditLog struct {
+	mu     sync.Mutex
+	file   string
+	nextId uint64
+}
+
+// readAuditLog returns the records in file, oldest first.
+func readAuditLog(file string) ([]rpc.AuditRecord, error) {
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var recs []rpc.AuditRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 16*1024*1024)
+	for scanner.Scan() {
+		var rec rpc.AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			// Skip anything left by a write cut short.
+			continue
+		}
+		recs = append(recs, rec)
+	}
+	return recs, scanner.Err()
+}
+
+func appendAuditRecord(file string, rec *rpc.AuditRecord) error {
+	auditLog.mu.Lock()
+	defer auditLog.mu.Unlock()
+	if auditLog.file != file {
+		recs, err := readAuditLog(file)
+		if err != nil {
+			return err
+		}
+		auditLog.file = file
+		auditLog.nextId = 1
+		if len(recs) > 0 {
+			auditLog.nextId = recs[len(recs)-1].Id + 1
+		}
+	}
+	rec.Id = auditLog.nextId
+
+	buf, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Write(append(buf, '\n')); err != nil {
+		return err
+	}
+	auditLog.nextId++
+	return nil
+}
+
+// auditTree returns the configuration in the datastore audited for db.
+func (d *Disp) auditTree(sid string, db rpc.DB) *data.Node {
+	if db == rpc.RUNNING {
+		return d.cmgr.Running()
+	}
+	if sess, err := d.smgr.Get(d.ctx, sid); err == nil {
+		if t := sess.MergeTreeWithoutDefaults(d.ctx); t != nil {
+			return t
+		}
+	}
+	return data.New("root")
+}
+
+func (d *Disp) recordAudit(
+	op, sid string,
+	before, after *data.Node,
+	failed bool,
+	err error,
+) {
+	tc := &treeChanges{hide: true, changes: []rpc.TreeChange{}}
+	if dn := diff.NewNode(after, before, d.ms, nil); dn != nil {
+		tc.walk(dn, nil)
+	}
+	rec := &rpc.AuditRecord{
+		Time:      time.Now().Format(time.RFC3339),
+		Operation: op,
+		User:      d.ctx.User,
+		Uid:       d.ctx.Uid,
+		Session:   sid,
+		Result:    auditSuccess,
+		Changes:   tc.changes,
+	}
+	if failed {
+		rec.Result = auditFailure
+	}
+	if err != nil {
+		rec.Error = d.auditError(err)
+	}
+	if werr := appendAuditRecord(
+		auditFileForRunfile(d.ctx.Config.Runfile), rec); werr != nil {
+		d.ctx.Elog.Printf("Unable to write audit record: %s", werr)
+	}
+}
+
+// auditError returns the text of err with the values of secrets in the
+// paths it reports hidden.
+func (d *Disp) auditError(err error) string {
+	msg := err.Error()
+	errs := []error{err}
+	if list, ok := err.(mgmterror.MgmtErrorList); ok {
+		errs = list.Errors()
+	}
+	for _, e := range errs {
+		me, ok := e.(mgmterror.Formattable)
+		if !ok || me.GetPath() == "" {
+			continue
+		}
+		path := me.GetPath()
+		redacted := pathutil.Pathstr(
+			session.RedactPath(d.ms, pathutil.Makepath(path)))
+		msg = strings.Replace(msg, path, redacted, -1)
+	}
+	return msg
+}
+
+// audited wraps fn, an operation changing db, so it is recorded in the
+// audit log.  An operation returning true has succeeded even if it also
+// returns an error, as for a load reporting warnings.
+func (d *Disp) audited(
+	op, sid string,
+	db rpc.DB,
+	fn func() (interface{}, error),
+) func() (interface{}, error) {
+	return func() (interface{}, error) {
+		before := d.auditTree(sid, db)
+		ret, err := fn()
+		failed := err != nil
+		if ok, isBool := ret.(bool); isBool {
+			failed = !ok
+		}
+		d.recordAudit(op, sid, before, d.auditTree(sid, db), failed, err)
+		return ret, err
+	}
+}
+
+// GetAuditLog returns, as a JSON encoded list of rpc.AuditRecords, up to
+// count of the records after the one numbered since, oldest first.
+func (d *Disp) GetAuditLog(since, count int) (string, error) {
+	if !d.ctx.Superuser && !d.ctx.Configd {
+		return "", mgmterror.NewAccessDeniedApplicationError()
+	}
+	if since < 0 {
+		since = 0
+	}
+	if count <= 0 || count > maxAuditRecords {
+		err := mgmterror.NewInvalidValueApplicationError()
+		err.Message = "Count must be between 1 and " +
+			strconv.Itoa(maxAuditRecords)
+		return "", err
+	}
+
+	auditLog.mu.Lock()
+	recs, err := readAuditLog(auditFileForRunfile(d.ctx.Config.Runfile))
+	auditLog.mu.Unlock()
+	if err != nil {
+		return "", err
+	}
+	out := []rpc.AuditRecord{}
+	for _, rec := range recs {
+		if rec.Id <= uint64(since) {
+			continue
+		}
+		out = append(out, rec)
+		if len(out) == count {
+			break
+		}
+	}
+	buf, err := json.Marshal(out)
+	if err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
@@ -0,0 +1,109 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/danos/configd/rpc"
+	"github.com/danos/configd/session/sessiontest"
+)
+
+const auditSchema = `
+container cont {
+	leaf value {
+		type string;
+	}
+	leaf password {
+		type string;
+		configd:secret "true";
+	}
+}`
+
+func getTestAuditLog(t *testing.T, d *Disp, since int) []rpc.AuditRecord {
+	t.Helper()
+	out, err := d.GetAuditLog(since, maxAuditRecords)
+	if err != nil {
+		t.Fatalf("Unable to get audit log: %s", err)
+	}
+	var recs []rpc.AuditRecord
+	if err := json.Unmarshal([]byte(out), &recs); err != nil {
+		t.Fatalf("Invalid audit log %s: %s", out, err)
+	}
+	return recs
+}
+
+func TestAuditLogRecordsCommits(t *testing.T) {
+	dir, err := ioutil.TempDir("", "configd-audit")
+	if err != nil {
+		t.Fatalf("Unable to create runfile dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	srv, sess := sessiontest.NewTestSpec(t).
+		SetSingleSchema(auditSchema).
+		Init()
+	srv.Ctx.Config.Runfile = filepath.Join(dir, "running.config")
+	srv.Ctx.Superuser = true
+	d := &Disp{smgr: srv.Smgr, cmgr: srv.Cmgr, ms: srv.Ms,
+		msFull: srv.MsFull, ctx: srv.Ctx}
+
+	commit := d.audited(AuditCommit, "test", rpc.RUNNING,
+		func() (interface{}, error) {
+			_, errs, ok := sess.Commit(srv.Ctx, "", false)
+			if !ok {
+				return nil, errs[0]
+			}
+			return "", nil
+		})
+
+	sessiontest.ValidateSet(t, sess, srv.Ctx,
+		[]string{"cont", "value", "foo"}, false)
+	sessiontest.ValidateSet(t, sess, srv.Ctx,
+		[]string{"cont", "password", "secret"}, false)
+	if _, err := commit(); err != nil {
+		t.Fatalf("Unable to commit: %s", err)
+	}
+	// Nothing to commit, so a failure is recorded
+	if _, err := commit(); err == nil {
+		t.Fatalf("Empty commit should fail")
+	}
+
+	recs := getTestAuditLog(t, d, 0)
+	if len(recs) != 2 {
+		t.Fatalf("Expected 2 audit records, got %v", recs)
+	}
+	rec := recs[0]
+	if rec.Id != 1 || rec.Operation != AuditCommit ||
+		rec.Session != "test" || rec.Result != auditSuccess {
+		t.Fatalf("Unexpected audit record: %+v", rec)
+	}
+	exp := []rpc.TreeChange{
+		{Op: "create", Path: "/cont/password", New: hiddenSecret},
+		{Op: "create", Path: "/cont/value", New: "foo"},
+	}
+	if !reflect.DeepEqual(rec.Changes, exp) {
+		t.Fatalf("Expected changes:\n%v\nGot:\n%v", exp, rec.Changes)
+	}
+	if recs[1].Id != 2 || recs[1].Result != auditFailure ||
+		recs[1].Error == "" || len(recs[1].Changes) != 0 {
+		t.Fatalf("Unexpected audit record: %+v", recs[1])
+	}
+
+	if recs := getTestAuditLog(t, d, 1); len(recs) != 1 || recs[0].Id != 2 {
+		t.Fatalf("Unexpected records after 1: %v", recs)
+	}
+
+	srv.Ctx.Superuser = false
+	srv.Ctx.Configd = false
+	if _, err := d.GetAuditLog(0, 1); err == nil {
+		t.Fatalf("Audit log should only be readable by superusers")
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"fmt"
+	"os/user"
+	"strconv"
+
+	"github.com/danos/config/auth"
+	"github.com/danos/config/schema"
+	"github.com/danos/mgmterror"
+	"github.com/danos/utils/pathutil"
+)
+
+// Frontends such as web UIs need to know in advance which parts of the
+// configuration a user may read or change, so they can grey out fields
+// rather than have the user's changes rejected.  AuthAuthorizePaths answers
+// this for a batch of paths in one call, for any user and groups.  As this
+// reveals other users' permissions it is restricted to superusers.
+
+var authPathPerms = map[string]auth.AuthPerm{
+	"read":    auth.P_READ,
+	"create":  auth.P_CREATE,
+	"update":  auth.P_UPDATE,
+	"delete":  auth.P_DELETE,
+	"execute": auth.P_EXECUTE,
+}
+
+func interfaceSliceToStrings(name string, vals []interface{}) ([]string, error) {
+	out := make([]string, 0, len(vals))
+	for _, val := range vals {
+		str, ok := val.(string)
+		if !ok {
+			err := mgmterror.NewInvalidValueApplicationError()
+			err.Message = fmt.Sprintf("%s must be strings, got %T", name, val)
+			return nil, err
+		}
+		out = append(out, str)
+	}
+	return out, nil
+}
+
+func lookupUid(username string) (uint32, error) {
+	u, err := user.Lookup(username)
+	if err != nil {
+		merr := mgmterror.NewInvalidValueApplicationError()
+		merr.Message = fmt.Sprintf("Unknown user %s", username)
+		return 0, merr
+	}
+	uid, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return 0, err
+	}
+	return uint32(uid), nil
+}
+
+func (d *Disp) authPathsInternal(
+	uid uint32,
+	groups, paths []string,
+) (map[string]map[string]bool, error) {
+	out := make(map[string]map[string]bool, len(paths))
+	for _, path := range paths {
+		ps, err := d.normalizePath(pathutil.Makepath(path))
+		if err != nil {
+			return nil, err
+		}
+		attrs := schema.AttrsForPath(d.msFull, ps)
+		perms := make(map[string]bool, len(authPathPerms))
+		for name, perm := range authPathPerms {
+			perms[name] = d.ctx.Auth.AuthorizePath(
+				uid, groups, ps, attrs, perm)
+		}
+		out[path] = perms
+	}
+	return out, nil
+}
+
+// AuthAuthorizePaths returns, for each path, whether the given user with
+// the given groups has read, create, update, delete and execute
+// permission.
+func (d *Disp) AuthAuthorizePaths(
+	username string,
+	groups, paths []interface{},
+) (map[string]map[string]bool, error) {
+	if !d.ctx.Superuser {
+		return nil, mgmterror.NewAccessDeniedApplicationError()
+	}
+
+	grps, err := interfaceSliceToStrings("groups", groups)
+	if err != nil {
+		return nil, err
+	}
+	ps, err := interfaceSliceToStrings("paths", paths)
+	if err != nil {
+		return nil, err
+	}
+	uid, err := lookupUid(username)
+	if err != nil {
+		return nil, err
+	}
+	return d.authPathsInternal(uid, grps, ps)
+}
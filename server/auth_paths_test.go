@@ -0,0 +1,120 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server_test
+
+import (
+	"os/user"
+	"testing"
+
+	"github.com/danos/config/auth"
+	"github.com/danos/configd/server"
+	"github.com/danos/configd/session/sessiontest"
+)
+
+const authPathsSchema = `
+container testcontainer {
+	leaf testleaf {
+		type string;
+	}
+	leaf otherleaf {
+		type string;
+	}
+}`
+
+func newAuthPathsTestDispatcher(
+	t *testing.T,
+	a auth.Auther,
+	superuser bool,
+) *server.Disp {
+	srv, _ := sessiontest.NewTestSpec(t).
+		SetSingleSchema(authPathsSchema).
+		SetAuther(a, false, false).
+		Init()
+	srv.Ctx.Superuser = superuser
+	return server.NewDispatcher(srv.Smgr, srv.Cmgr, srv.Ms, srv.MsFull,
+		srv.Ctx)
+}
+
+func currentUsername(t *testing.T) string {
+	u, err := user.Current()
+	if err != nil {
+		t.Fatalf("Unable to get current user: %s", err)
+	}
+	return u.Username
+}
+
+var authPathsTestPaths = []interface{}{
+	"/testcontainer/testleaf",
+	"/testcontainer/otherleaf/foo",
+}
+
+func checkAuthPaths(
+	t *testing.T,
+	perms map[string]map[string]bool,
+	exp bool,
+) {
+	t.Helper()
+	if len(perms) != len(authPathsTestPaths) {
+		t.Fatalf("Expected %d paths, got %v", len(authPathsTestPaths), perms)
+	}
+	for _, path := range authPathsTestPaths {
+		pathPerms, ok := perms[path.(string)]
+		if !ok {
+			t.Fatalf("Missing permissions for %s", path)
+		}
+		for _, perm := range []string{
+			"read", "create", "update", "delete", "execute"} {
+			if got, ok := pathPerms[perm]; !ok || got != exp {
+				t.Fatalf("%s %s: expected %v, got %v", path, perm, exp,
+					pathPerms)
+			}
+		}
+	}
+}
+
+func TestAuthAuthorizePathsAllowed(t *testing.T) {
+	d := newAuthPathsTestDispatcher(t, auth.TestAutherAllowAll(), true)
+
+	perms, err := d.AuthAuthorizePaths(currentUsername(t),
+		[]interface{}{"vyattacfg"}, authPathsTestPaths)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	checkAuthPaths(t, perms, true)
+}
+
+func TestAuthAuthorizePathsDenied(t *testing.T) {
+	d := newAuthPathsTestDispatcher(t, auth.TestAutherDenyAll(), true)
+
+	perms, err := d.AuthAuthorizePaths(currentUsername(t),
+		[]interface{}{"vyattacfg"}, authPathsTestPaths)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	checkAuthPaths(t, perms, false)
+}
+
+func TestAuthAuthorizePathsRequiresSuperuser(t *testing.T) {
+	d := newAuthPathsTestDispatcher(t, auth.TestAutherAllowAll(), false)
+
+	_, err := d.AuthAuthorizePaths(currentUsername(t),
+		[]interface{}{"vyattacfg"}, authPathsTestPaths)
+	if err == nil {
+		t.Fatalf("Only superusers should be able to check permissions")
+	}
+}
+
+func TestAuthAuthorizePathsInvalidArgs(t *testing.T) {
+	d := newAuthPathsTestDispatcher(t, auth.TestAutherAllowAll(), true)
+
+	if _, err := d.AuthAuthorizePaths("no-such-user-configd",
+		nil, authPathsTestPaths); err == nil {
+		t.Fatalf("Unknown user should be rejected")
+	}
+	if _, err := d.AuthAuthorizePaths(currentUsername(t),
+		[]interface{}{1}, authPathsTestPaths); err == nil {
+		t.Fatalf("Non-string group should be rejected")
+	}
+}
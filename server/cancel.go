@@ -0,0 +1,127 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/danos/configd/rpc"
+	"github.com/danos/mgmterror"
+)
+
+// Request cancellation
+//
+// A connection's requests are read as they arrive, rather than once the
+// previous one has been answered, so configd learns of a client that has
+// gone, or of a Cancel request, while a request is still running.  Each
+// request is run with a context, as the configd.Context's Request,
+// cancelled when the client goes or asks for it to be cancelled; see
+// session/cancel.go for what stops then.  Cancel is answered at once,
+// with whether the request was still running, and needs no authorization
+// as it only affects the caller's own requests.  As it may be answered
+// before the request it cancels, it is for clients matching responses to
+// requests by their ids, ie rpc.ProtocolVersion3.
+
+const cancelMethod = "Cancel"
+
+// Requests read ahead of the one running on each connection
+const maxQueuedRequests = 64
+
+type inflightRequests struct {
+	conn   context.Context
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	requests map[int]context.CancelFunc
+}
+
+func newInflightRequests() *inflightRequests {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &inflightRequests{
+		conn:     ctx,
+		cancel:   cancel,
+		requests: make(map[int]context.CancelFunc),
+	}
+}
+
+// begin returns the context to run the request with.
+func (r *inflightRequests) begin(id int) context.Context {
+	ctx, cancel := context.WithCancel(r.conn)
+	r.mu.Lock()
+	r.requests[id] = cancel
+	r.mu.Unlock()
+	return ctx
+}
+
+// end releases the request's context, once it has been answered.
+func (r *inflightRequests) end(id int) {
+	r.mu.Lock()
+	cancel, ok := r.requests[id]
+	delete(r.requests, id)
+	r.mu.Unlock()
+	if ok {
+		cancel()
+	}
+}
+
+// cancelRequest cancels the request whose id is the argument, returning
+// whether it was running.
+func (r *inflightRequests) cancelRequest(args []interface{}) (bool, error) {
+	if len(args) != 1 {
+		return false, &rpc.ArgNErr{Method: cancelMethod, Len: len(args),
+			Elen: 1}
+	}
+	id, ok := args[0].(float64)
+	if !ok {
+		err := mgmterror.NewInvalidValueApplicationError()
+		err.Message = "Request id must be a number"
+		return false, err
+	}
+	r.mu.Lock()
+	cancel, ok := r.requests[int(id)]
+	r.mu.Unlock()
+	if ok {
+		cancel()
+	}
+	return ok, nil
+}
+
+// cancelAll cancels every request, once the client has gone.
+func (r *inflightRequests) cancelAll() {
+	r.cancel()
+}
+
+// readRequests reads the connection's requests until it is closed,
+// answering Cancel requests as they arrive and queueing the rest.
+func (conn *SrvConn) readRequests(inflight *inflightRequests) <-chan *rpc.Request {
+	reqs := make(chan *rpc.Request, maxQueuedRequests)
+	go func() {
+		defer close(reqs)
+		defer inflight.cancelAll()
+		for {
+			req, err := conn.readRequest()
+			if err != nil {
+				// Not an error if the connection was closed here.
+				if err != io.EOF && inflight.conn.Err() == nil {
+					conn.srv.LogError(err)
+				}
+				return
+			}
+			if req.Method == cancelMethod {
+				result, err := inflight.cancelRequest(req.Args)
+				conn.sendResponse(newResponse(result, err, req.Id))
+				continue
+			}
+			select {
+			case reqs <- req:
+			case <-inflight.conn.Done():
+				return
+			}
+		}
+	}()
+	return reqs
+}
@@ -0,0 +1,40 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"testing"
+)
+
+func TestCancelRequest(t *testing.T) {
+	inflight := newInflightRequests()
+	first := inflight.begin(1)
+	second := inflight.begin(2)
+
+	if ok, err := inflight.cancelRequest([]interface{}{float64(1)}); !ok ||
+		err != nil {
+		t.Fatalf("Unable to cancel a running request: %t, %v", ok, err)
+	}
+	if first.Err() == nil {
+		t.Fatal("Request not cancelled")
+	}
+	if second.Err() != nil {
+		t.Fatal("Other request cancelled")
+	}
+
+	inflight.end(2)
+	if ok, _ := inflight.cancelRequest([]interface{}{float64(2)}); ok {
+		t.Fatal("Cancelled a request which had been answered")
+	}
+	if _, err := inflight.cancelRequest([]interface{}{"2"}); err == nil {
+		t.Fatal("Accepted a request id which isn't a number")
+	}
+
+	third := inflight.begin(3)
+	inflight.cancelAll()
+	if third.Err() == nil {
+		t.Fatal("Request not cancelled when the client went")
+	}
+}
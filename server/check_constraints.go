@@ -0,0 +1,226 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/danos/config/schema"
+	"github.com/danos/config/union"
+	"github.com/danos/configd/rpc"
+	"github.com/danos/configd/session"
+	"github.com/danos/mgmterror"
+	"github.com/danos/utils/pathutil"
+)
+
+// Constraint pre-check
+//
+// Violations of min-elements, max-elements and unique are only reported
+// when the whole configuration is validated, at commit time.  UIs want to
+// flag them while the user is editing, so CheckConstraints evaluates just
+// these constraints over a subtree of the candidate, without running any
+// of the other, more expensive, validation.  Duplicate keys and leaf-list
+// values can't be held in the candidate tree, so uniqueness of keys is
+// already assured when entries are set; unique statements cover the other
+// leaves of a list entry.
+
+// Lists with unique statements provide the descendant leaves named by
+// each statement, as paths relative to the list entry.
+type uniqueList interface {
+	Uniques() [][][]xml.Name
+}
+
+func newTooFewElementsError(path []string, min uint) error {
+	err := mgmterror.NewOperationFailedApplicationError()
+	err.Path = pathutil.Pathstr(path)
+	err.Message = fmt.Sprintf("Too few elements, at least %d required", min)
+	return err
+}
+
+func newTooManyElementsError(path []string, max uint) error {
+	err := mgmterror.NewOperationFailedApplicationError()
+	err.Path = pathutil.Pathstr(path)
+	err.Message = fmt.Sprintf("Too many elements, at most %d allowed", max)
+	return err
+}
+
+func newNonUniqueError(path []string, leaves, values, entries []string) error {
+	err := mgmterror.NewOperationFailedApplicationError()
+	err.Path = pathutil.Pathstr(path)
+	err.Message = fmt.Sprintf("%s %s is not unique: %s",
+		strings.Join(leaves, " "), strings.Join(values, " "),
+		strings.Join(entries, " "))
+	return err
+}
+
+func checkElementLimits(path []string, lim schema.Limit, count int) error {
+	if uint(count) < lim.Min {
+		return newTooFewElementsError(path, lim.Min)
+	}
+	// A max of 0 is never valid, so denotes unbounded.
+	if lim.Max != 0 && uint(count) > lim.Max {
+		return newTooManyElementsError(path, lim.Max)
+	}
+	return nil
+}
+
+// uniqueValue returns the value of the leaf at path below the list entry,
+// and whether it is set.
+func uniqueValue(entry union.Node, path []xml.Name) (string, bool) {
+	n := entry
+	for _, elem := range path {
+		if n = n.Child(elem.Local); n == nil {
+			return "", false
+		}
+	}
+	vals := n.Children()
+	if len(vals) == 0 {
+		return "", false
+	}
+	return vals[0].Name(), true
+}
+
+func checkUniques(list union.Node, path []string) []error {
+	ul, ok := list.GetSchema().(uniqueList)
+	if !ok {
+		return nil
+	}
+	var errs []error
+	for _, unique := range ul.Uniques() {
+		leaves := make([]string, 0, len(unique))
+		for _, leaf := range unique {
+			names := make([]string, 0, len(leaf))
+			for _, elem := range leaf {
+				names = append(names, elem.Local)
+			}
+			leaves = append(leaves, strings.Join(names, "/"))
+		}
+
+		seen := make(map[string][]string)
+		tuples := make(map[string][]string)
+		for _, entry := range list.Children() {
+			values := make([]string, 0, len(unique))
+			complete := true
+			for _, leaf := range unique {
+				val, ok := uniqueValue(entry, leaf)
+				if !ok {
+					// Only entries with all the leaves set are
+					// constrained.
+					complete = false
+					break
+				}
+				values = append(values, val)
+			}
+			if !complete {
+				continue
+			}
+			key := strings.Join(values, "\x00")
+			seen[key] = append(seen[key], entry.Name())
+			tuples[key] = values
+		}
+
+		keys := make([]string, 0, len(seen))
+		for key := range seen {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if entries := seen[key]; len(entries) > 1 {
+				errs = append(errs, newNonUniqueError(path, leaves,
+					tuples[key], entries))
+			}
+		}
+	}
+	return errs
+}
+
+// checkConstraints checks the lists and leaf-lists at and below n, which
+// is at path, skipping those that can't be read.  Lists that aren't
+// configured are counted as having no elements.
+func checkConstraints(
+	n union.Node, path []string, authRead func([]string) bool,
+) []error {
+	if !authRead(path) {
+		return nil
+	}
+	var errs []error
+	switch sch := n.GetSchema().(type) {
+	case schema.List:
+		if err := checkElementLimits(path, sch.Limit(),
+			len(n.Children())); err != nil {
+			errs = append(errs, err)
+		}
+		errs = append(errs, checkUniques(n, path)...)
+		for _, entry := range n.Children() {
+			errs = append(errs, checkConstraints(entry,
+				pathutil.CopyAppend(path, entry.Name()), authRead)...)
+		}
+		return errs
+	case schema.LeafList:
+		if err := checkElementLimits(path, sch.Limit(),
+			len(n.Children())); err != nil {
+			errs = append(errs, err)
+		}
+		return errs
+	case schema.Leaf:
+		return nil
+	}
+
+	for _, csch := range n.GetSchema().Children() {
+		cpath := pathutil.CopyAppend(path, csch.Name())
+		if ch := n.Child(csch.Name()); ch != nil {
+			errs = append(errs, checkConstraints(ch, cpath, authRead)...)
+			continue
+		}
+		if !authRead(cpath) {
+			continue
+		}
+		switch v := csch.(type) {
+		case schema.List:
+			if err := checkElementLimits(cpath, v.Limit(), 0); err != nil {
+				errs = append(errs, err)
+			}
+		case schema.LeafList:
+			if err := checkElementLimits(cpath, v.Limit(), 0); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errs
+}
+
+// CheckConstraints evaluates the min-elements, max-elements and unique
+// constraints for the candidate subtree at path, returning the violations
+// the caller may read.
+func (d *Disp) CheckConstraints(sid, path string) (bool, error) {
+	ps := pathutil.Makepath(path)
+	if !d.authRead(ps) {
+		return false, mgmterror.NewAccessDeniedApplicationError()
+	}
+	if _, err := d.smgr.Get(d.ctx, sid); err != nil {
+		return false, err
+	}
+
+	sess := d.getROSession(rpc.CANDIDATE, sid)
+	ut, err := sess.GetTree(d.ctx, ps,
+		&session.TreeOpts{Defaults: true, Secrets: true})
+	if err != nil {
+		return false, err
+	}
+	if ut == nil {
+		return true, nil
+	}
+
+	errs := checkConstraints(ut, ps, d.authRead)
+	if len(errs) == 0 {
+		return true, nil
+	}
+	var merr mgmterror.MgmtErrorList
+	merr.MgmtErrorListAppend(errs...)
+	return false, merr
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/danos/config/auth"
+	"github.com/danos/configd/server"
+)
+
+const checkConstraintsSchema = `
+container cont {
+	presence "Constraints only apply when present";
+	list servers {
+		key name;
+		unique address;
+		min-elements 1;
+		max-elements 2;
+		leaf name {
+			type string;
+		}
+		leaf address {
+			type string;
+		}
+	}
+	leaf-list dns {
+		type string;
+		max-elements 1;
+	}
+}`
+
+func checkConstraintsFail(
+	t *testing.T,
+	d *server.Disp,
+	path string,
+	expMsgs ...string,
+) {
+	t.Helper()
+	ok, err := d.CheckConstraints(testSID, path)
+	if ok || err == nil {
+		t.Fatalf("Expected constraint violations at %s", path)
+	}
+	for _, msg := range expMsgs {
+		if !strings.Contains(err.Error(), msg) {
+			t.Fatalf("Expected '%s' in:\n%s", msg, err)
+		}
+	}
+}
+
+func TestCheckConstraints(t *testing.T) {
+	d := newTestDispatcher(t, auth.TestAutherAllowAll(),
+		checkConstraintsSchema, emptyconfig)
+	dispTestSetupSession(t, d, testSID)
+
+	dispTestSet(t, d, testSID, "cont")
+	checkConstraintsFail(t, d, "/cont",
+		"Too few elements, at least 1 required")
+
+	dispTestSet(t, d, testSID, "cont/servers/a/address/10.0.0.1")
+	if ok, err := d.CheckConstraints(testSID, "/cont"); !ok || err != nil {
+		t.Fatalf("Unexpected constraint violations: %s", err)
+	}
+
+	dispTestSet(t, d, testSID, "cont/servers/b/address/10.0.0.1")
+	dispTestSet(t, d, testSID, "cont/servers/c/address/10.0.0.2")
+	dispTestSet(t, d, testSID, "cont/dns/one")
+	dispTestSet(t, d, testSID, "cont/dns/two")
+	checkConstraintsFail(t, d, "/cont",
+		"Too many elements, at most 2 allowed",
+		"address 10.0.0.1 is not unique: a b")
+	checkConstraintsFail(t, d, "/cont/dns",
+		"Too many elements, at most 1 allowed")
+}
@@ -0,0 +1,233 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/danos/mgmterror"
+)
+
+// Chunked load
+//
+// Loading a large config file as a single request blocks the client with no
+// feedback, and sending its content risks hitting message size limits.
+// Instead the client can begin an upload, declaring its size, append the
+// content in chunks, then finish the upload to load it into the session.
+// Progress can be polled, from another connection if need be, at any point.
+// Uploads are held in memory, so their size is limited, and any left idle
+// are discarded when the next upload begins.
+
+const (
+	maxLoadUploadSize     = 128 << 20
+	maxLoadChunkSize      = 1 << 20
+	loadUploadIdleTimeout = 10 * time.Minute
+
+	LoadUploadUploading = "uploading"
+	LoadUploadLoading   = "loading"
+)
+
+type loadUpload struct {
+	id   string
+	sid  string
+	name string
+	uid  uint32
+	size int
+
+	mu         sync.Mutex
+	buf        bytes.Buffer
+	state      string
+	lastActive time.Time
+}
+
+func (u *loadUpload) progress() map[string]string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return map[string]string{
+		"state":    u.state,
+		"received": strconv.Itoa(u.buf.Len()),
+		"size":     strconv.Itoa(u.size),
+	}
+}
+
+type loadUploads struct {
+	mu sync.Mutex
+	m  map[string]*loadUpload
+}
+
+var chunkedLoads = &loadUploads{m: make(map[string]*loadUpload)}
+
+func (l *loadUploads) add(u *loadUpload) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for id, old := range l.m {
+		old.mu.Lock()
+		idle := old.state == LoadUploadUploading &&
+			time.Since(old.lastActive) > loadUploadIdleTimeout
+		old.mu.Unlock()
+		if idle {
+			delete(l.m, id)
+		}
+	}
+	l.m[u.id] = u
+}
+
+func (l *loadUploads) remove(id string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.m, id)
+}
+
+func newNoLoadUploadError(id string) error {
+	err := mgmterror.NewInvalidValueApplicationError()
+	err.Message = fmt.Sprintf("No load upload %s", id)
+	return err
+}
+
+// getLoadUpload returns the upload, which must belong to the caller.
+func (d *Disp) getLoadUpload(id string) (*loadUpload, error) {
+	chunkedLoads.mu.Lock()
+	u, ok := chunkedLoads.m[id]
+	chunkedLoads.mu.Unlock()
+	if !ok {
+		return nil, newNoLoadUploadError(id)
+	}
+	if u.uid != d.ctx.Uid && !d.ctx.Configd && !d.ctx.Superuser {
+		return nil, newNoLoadUploadError(id)
+	}
+	return u, nil
+}
+
+func newLoadUploadId() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// LoadBegin starts an upload of size bytes to be loaded into the session,
+// returning the id to use for the rest of the upload.  The name is only
+// used for reporting, and authorization is as for loading that file.
+func (d *Disp) LoadBegin(sid, name string, size int) (string, error) {
+	if _, err := d.smgr.Get(d.ctx, sid); err != nil {
+		return "", err
+	}
+	if size < 0 || size > maxLoadUploadSize {
+		err := mgmterror.NewInvalidValueApplicationError()
+		err.Message = fmt.Sprintf(
+			"Load size must be between 0 and %d bytes", maxLoadUploadSize)
+		return "", err
+	}
+
+	id, err := newLoadUploadId()
+	if err != nil {
+		return "", err
+	}
+	u := &loadUpload{
+		id:         id,
+		sid:        sid,
+		name:       name,
+		uid:        d.ctx.Uid,
+		size:       size,
+		state:      LoadUploadUploading,
+		lastActive: time.Now(),
+	}
+	u.buf.Grow(size)
+	chunkedLoads.add(u)
+	return id, nil
+}
+
+// LoadAppend adds the next chunk of content to the upload, returning the
+// number of bytes received so far.
+func (d *Disp) LoadAppend(id, chunk string) (int, error) {
+	u, err := d.getLoadUpload(id)
+	if err != nil {
+		return 0, err
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.state != LoadUploadUploading {
+		err := mgmterror.NewOperationFailedApplicationError()
+		err.Message = fmt.Sprintf("Load upload %s is %s", id, u.state)
+		return u.buf.Len(), err
+	}
+	if len(chunk) > maxLoadChunkSize {
+		err := mgmterror.NewInvalidValueApplicationError()
+		err.Message = fmt.Sprintf("Load chunks must not exceed %d bytes",
+			maxLoadChunkSize)
+		return u.buf.Len(), err
+	}
+	if u.buf.Len()+len(chunk) > u.size {
+		err := mgmterror.NewInvalidValueApplicationError()
+		err.Message = fmt.Sprintf("Load upload exceeds declared size of %d bytes",
+			u.size)
+		return u.buf.Len(), err
+	}
+	u.buf.WriteString(chunk)
+	u.lastActive = time.Now()
+	return u.buf.Len(), nil
+}
+
+// LoadProgress reports the state of the upload, and how much of it has
+// been received.
+func (d *Disp) LoadProgress(id string) (map[string]string, error) {
+	u, err := d.getLoadUpload(id)
+	if err != nil {
+		return nil, err
+	}
+	return u.progress(), nil
+}
+
+func (d *Disp) LoadAbort(id string) (bool, error) {
+	u, err := d.getLoadUpload(id)
+	if err != nil {
+		return false, err
+	}
+	chunkedLoads.remove(u.id)
+	return true, nil
+}
+
+// LoadFinish loads the uploaded content into the session, once all of it
+// has been received.  Warnings are only returned if reportWarnings is set,
+// as for Load and LoadReportWarnings.
+func (d *Disp) LoadFinish(id string, reportWarnings bool) (bool, error) {
+	u, err := d.getLoadUpload(id)
+	if err != nil {
+		return false, err
+	}
+
+	u.mu.Lock()
+	if u.state != LoadUploadUploading || u.buf.Len() != u.size {
+		err := mgmterror.NewOperationFailedApplicationError()
+		err.Message = fmt.Sprintf(
+			"Load upload %s is incomplete: received %d of %d bytes",
+			id, u.buf.Len(), u.size)
+		u.mu.Unlock()
+		return false, err
+	}
+	u.state = LoadUploadLoading
+	u.mu.Unlock()
+	defer chunkedLoads.remove(u.id)
+
+	args := d.newCommandArgsForAaa("load", []string{u.name}, nil)
+	if !d.authCommand(args) {
+		return false, mgmterror.NewAccessDeniedApplicationError()
+	}
+	ok, errOrWarns := d.accountCmdWrapBoolErr(args, func() (interface{}, error) {
+		return d.loadReportWarningsReader(u.sid, u.name,
+			bytes.NewReader(u.buf.Bytes()))
+	})
+	if ok && !reportWarnings {
+		return ok, nil
+	}
+	return ok, errOrWarns
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server_test
+
+import (
+	"testing"
+
+	"github.com/danos/config/auth"
+	"github.com/danos/configd/rpc"
+	"github.com/danos/configd/server"
+)
+
+const chunkedLoadSchema = `
+container testcontainer {
+	leaf first {
+		type string;
+	}
+	leaf second {
+		type string;
+	}
+}`
+
+const chunkedLoadConfig = `testcontainer {
+	first foo
+	second bar
+}
+`
+
+func newChunkedLoadTestDispatcher(t *testing.T) *server.Disp {
+	d := newTestDispatcher(t, auth.TestAutherAllowAll(),
+		chunkedLoadSchema, emptyconfig)
+	dispTestSetupSession(t, d, testSID)
+	return d
+}
+
+func TestChunkedLoad(t *testing.T) {
+	d := newChunkedLoadTestDispatcher(t)
+
+	id, err := d.LoadBegin(testSID, "chunked.config", len(chunkedLoadConfig))
+	if err != nil {
+		t.Fatalf("Unable to begin load: %s", err)
+	}
+	half := len(chunkedLoadConfig) / 2
+	for _, chunk := range []string{
+		chunkedLoadConfig[:half], chunkedLoadConfig[half:]} {
+		if _, err := d.LoadAppend(id, chunk); err != nil {
+			t.Fatalf("Unable to append to load: %s", err)
+		}
+	}
+
+	progress, err := d.LoadProgress(id)
+	if err != nil {
+		t.Fatalf("Unable to get load progress: %s", err)
+	}
+	if progress["state"] != server.LoadUploadUploading ||
+		progress["received"] != progress["size"] {
+		t.Fatalf("Unexpected progress: %v", progress)
+	}
+
+	if _, err := d.LoadFinish(id, true); err != nil {
+		t.Fatalf("Unable to finish load: %s", err)
+	}
+	for _, path := range []string{
+		"testcontainer/first/foo", "testcontainer/second/bar"} {
+		if ok, _ := d.Exists(rpc.CANDIDATE, testSID, path); !ok {
+			t.Fatalf("%s not loaded", path)
+		}
+	}
+	if _, err := d.LoadProgress(id); err == nil {
+		t.Fatalf("Upload should be removed once finished")
+	}
+}
+
+func TestChunkedLoadExceedsSize(t *testing.T) {
+	d := newChunkedLoadTestDispatcher(t)
+
+	id, err := d.LoadBegin(testSID, "chunked.config", 4)
+	if err != nil {
+		t.Fatalf("Unable to begin load: %s", err)
+	}
+	if _, err := d.LoadAppend(id, chunkedLoadConfig); err == nil {
+		t.Fatalf("Appending beyond the declared size should fail")
+	}
+	if _, err := d.LoadBegin(testSID, "huge.config", 1<<30); err == nil {
+		t.Fatalf("Load size limit not enforced")
+	}
+}
+
+func TestChunkedLoadIncomplete(t *testing.T) {
+	d := newChunkedLoadTestDispatcher(t)
+
+	id, err := d.LoadBegin(testSID, "chunked.config", len(chunkedLoadConfig))
+	if err != nil {
+		t.Fatalf("Unable to begin load: %s", err)
+	}
+	if _, err := d.LoadAppend(id, chunkedLoadConfig[:4]); err != nil {
+		t.Fatalf("Unable to append to load: %s", err)
+	}
+	if _, err := d.LoadFinish(id, true); err == nil {
+		t.Fatalf("Finishing an incomplete upload should fail")
+	}
+	if _, err := d.LoadAbort(id); err != nil {
+		t.Fatalf("Unable to abort load: %s", err)
+	}
+	if _, err := d.LoadAppend(id, chunkedLoadConfig[4:]); err == nil {
+		t.Fatalf("Appending to an aborted upload should fail")
+	}
+}
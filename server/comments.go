@@ -0,0 +1,68 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"strings"
+
+	"github.com/danos/configd/common"
+	"github.com/danos/configd/rpc"
+	"github.com/danos/mgmterror"
+	"github.com/danos/utils/pathutil"
+)
+
+// Comments on configuration nodes are kept by the session (see
+// session/comments.go).  Setting one is authorized as an update of the
+// node, and accounted as the comment command.
+
+// Comment sets the comment on a node.  The last element of path is the
+// comment, the rest the path of the node.  An empty comment, ie a path
+// ending in /, removes it.
+func (d *Disp) Comment(sid string, path string) (bool, error) {
+	var text string
+	ps := pathutil.Makepath(strings.TrimSuffix(path, "/"))
+	if !strings.HasSuffix(path, "/") && len(ps) > 0 {
+		text, ps = ps[len(ps)-1], ps[:len(ps)-1]
+	}
+	if len(ps) == 0 {
+		err := mgmterror.NewInvalidValueApplicationError()
+		err.Message = "A comment needs the path of a node and its text"
+		return false, err
+	}
+	ps, err := d.normalizePath(d.expandVariables(sid, ps))
+	if err != nil {
+		return false, common.FormatConfigPathErrorMultiline(err)
+	}
+
+	args := d.newCommandArgsForAaa("comment", nil, ps)
+	if !d.authCommand(args) {
+		return false, mgmterror.NewAccessDeniedApplicationError()
+	}
+
+	return d.accountCmdWrapBoolErr(args, func() (interface{}, error) {
+		if err := d.checkExclusive(sid); err != nil {
+			return false, err
+		}
+		sess, err := d.smgr.Get(d.ctx, sid)
+		if err != nil {
+			return false, err
+		}
+		if err := sess.Comment(d.ctx, ps, text); err != nil {
+			return false, common.FormatConfigPathErrorMultiline(err)
+		}
+		return true, nil
+	})
+}
+
+// GetComment returns the comment on the node at path in the session's
+// candidate, or an empty string if it has none.
+func (d *Disp) GetComment(sid string, path string) (string, error) {
+	ps, err := d.normalizePath(
+		d.expandVariables(sid, pathutil.Makepath(path)))
+	if err != nil {
+		return "", common.FormatConfigPathErrorMultiline(err)
+	}
+	return d.getROSession(rpc.CANDIDATE, sid).GetComment(d.ctx, ps)
+}
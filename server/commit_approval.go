@@ -0,0 +1,155 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/danos/configd/session"
+	"github.com/danos/mgmterror"
+	"github.com/danos/utils/pathutil"
+)
+
+// Commit approval hook
+//
+// Where changes must be approved, eg by an ITSM system, the approval hook
+// URL is configured.  Each commit is POSTed to it as JSON, once validated
+// but before it is applied (see session/commit_approval.go):
+//
+//	{"session": ..., "user": ..., "comment": ..., "approval-token": ...,
+//	 "changes": [{"path": ..., "operation": ...}, ...], "diff": ...}
+//
+// and the hook replies with its decision:
+//
+//	{"decision": "approve" | "reject" | "require-approval", "message": ...}
+//
+// A commit requiring approval may be retried, with the token obtained from
+// a second approver, using CommitWithApprovalToken.  If the hook can't be
+// reached, or its reply can't be understood, the commit is rejected.
+
+const (
+	ApprovalApprove         = "approve"
+	ApprovalReject          = "reject"
+	ApprovalRequireApproval = "require-approval"
+
+	approvalHookTimeout = 30 * time.Second
+)
+
+type approvalHookChange struct {
+	Path      string `json:"path"`
+	Operation string `json:"operation"`
+}
+
+type approvalHookRequest struct {
+	Session string               `json:"session"`
+	User    string               `json:"user"`
+	Comment string               `json:"comment,omitempty"`
+	Token   string               `json:"approval-token,omitempty"`
+	Changes []approvalHookChange `json:"changes"`
+	Diff    string               `json:"diff"`
+}
+
+type approvalHookResponse struct {
+	Decision string `json:"decision"`
+	Message  string `json:"message"`
+}
+
+func newApprovalHookRequest(req *session.CommitApprovalRequest) *approvalHookRequest {
+	out := &approvalHookRequest{
+		Session: req.Session,
+		User:    req.User,
+		Comment: req.Message,
+		Token:   req.Token,
+		Changes: make([]approvalHookChange, 0, len(req.Changes)),
+		Diff:    req.Diff,
+	}
+	for _, ch := range req.Changes {
+		out.Changes = append(out.Changes, approvalHookChange{
+			Path:      pathutil.Pathstr(ch.Path),
+			Operation: ch.Operation,
+		})
+	}
+	return out
+}
+
+func newApprovalHookError(err error) error {
+	merr := mgmterror.NewOperationFailedApplicationError()
+	merr.Message = fmt.Sprintf("Unable to obtain commit approval: %s", err)
+	return merr
+}
+
+func approvalDecisionError(resp *approvalHookResponse) error {
+	switch resp.Decision {
+	case ApprovalApprove:
+		return nil
+	case ApprovalReject:
+		err := mgmterror.NewAccessDeniedApplicationError()
+		err.Message = "Commit rejected"
+		if resp.Message != "" {
+			err.Message += ": " + resp.Message
+		}
+		return err
+	case ApprovalRequireApproval:
+		err := mgmterror.NewAccessDeniedApplicationError()
+		err.Message = "Commit requires a second approver's token"
+		if resp.Message != "" {
+			err.Message += ": " + resp.Message
+		}
+		return err
+	}
+	return newApprovalHookError(
+		fmt.Errorf("unknown decision %q", resp.Decision))
+}
+
+// newApprovalHook returns the approver which asks the hook at url.
+func newApprovalHook(url string) func(*session.CommitApprovalRequest) error {
+	client := &http.Client{Timeout: approvalHookTimeout}
+	return func(req *session.CommitApprovalRequest) error {
+		buf, err := json.Marshal(newApprovalHookRequest(req))
+		if err != nil {
+			return newApprovalHookError(err)
+		}
+		httpResp, err := client.Post(url, "application/json",
+			bytes.NewReader(buf))
+		if err != nil {
+			return newApprovalHookError(err)
+		}
+		defer httpResp.Body.Close()
+		if httpResp.StatusCode != http.StatusOK {
+			return newApprovalHookError(
+				fmt.Errorf("hook returned %s", httpResp.Status))
+		}
+		var resp approvalHookResponse
+		if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+			return newApprovalHookError(err)
+		}
+		return approvalDecisionError(&resp)
+	}
+}
+
+// CommitWithApprovalToken commits as Commit does, passing the token from a
+// second approver to the approval hook.
+func (d *Disp) CommitWithApprovalToken(
+	sid string,
+	message string,
+	token string,
+	debug bool,
+) (string, error) {
+	var args []string
+	if message != "" {
+		args = append(args, "comment", message)
+	}
+	cmdArgs := d.newCommandArgsForAaa("commit", args, nil)
+
+	return d.accountCmdWrapStrErr(cmdArgs, func() (interface{}, error) {
+		d.ctx.ApprovalToken = token
+		defer func() { d.ctx.ApprovalToken = "" }()
+		return d.commitInternal(sid, message, debug, 0, false)
+	})
+}
@@ -0,0 +1,78 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/danos/configd/session"
+)
+
+func TestApprovalHook(t *testing.T) {
+	var got approvalHookRequest
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+				t.Errorf("Invalid approval request: %s", err)
+			}
+			resp := approvalHookResponse{Decision: ApprovalRequireApproval,
+				Message: "CHG0001 needs sign off"}
+			switch got.Token {
+			case "approved":
+				resp.Decision = ApprovalApprove
+			case "refused":
+				resp.Decision = ApprovalReject
+				resp.Message = "Not in change window"
+			case "garbled":
+				resp.Decision = "maybe"
+			}
+			json.NewEncoder(w).Encode(&resp)
+		}))
+	defer ts.Close()
+
+	hook := newApprovalHook(ts.URL)
+	req := &session.CommitApprovalRequest{
+		Session: "1234",
+		User:    "alice",
+		Message: "change the leaf",
+		Changes: []session.CommitChange{{
+			Path:      []string{"cont", "value"},
+			Operation: session.ChangeUpdate,
+		}},
+		Diff: "[edit cont]\n-value foo\n+value bar\n",
+	}
+
+	checkDecision := func(token, expErr string) {
+		t.Helper()
+		req.Token = token
+		err := hook(req)
+		switch {
+		case expErr == "" && err != nil:
+			t.Fatalf("Unexpected error with token %q: %s", token, err)
+		case expErr != "" && err == nil:
+			t.Fatalf("Unexpected approval with token %q", token)
+		case expErr != "" && !strings.Contains(err.Error(), expErr):
+			t.Fatalf("Unexpected error with token %q.\nExp: %s\nGot: %s",
+				token, expErr, err)
+		}
+	}
+
+	checkDecision("", "requires a second approver's token: CHG0001")
+	if got.User != "alice" || got.Comment != "change the leaf" ||
+		len(got.Changes) != 1 || got.Changes[0].Path != "/cont/value" ||
+		got.Diff != req.Diff {
+		t.Fatalf("Unexpected approval request: %+v", got)
+	}
+	checkDecision("approved", "")
+	checkDecision("refused", "Commit rejected: Not in change window")
+	checkDecision("garbled", "unknown decision")
+
+	ts.Close()
+	checkDecision("approved", "Unable to obtain commit approval")
+}
@@ -0,0 +1,186 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/danos/config/auth"
+	"github.com/danos/configd"
+	"github.com/danos/configd/session"
+	"github.com/danos/mgmterror"
+	"github.com/danos/utils/pathutil"
+)
+
+// Commit expiry is tracked by the commit manager (see
+// session/commit_expiry.go) in the job file.  Once a commit expires the
+// leaf paths it added are deleted, if still there, and those it removed
+// are set again, and the result committed.
+var commitExpiryJobFile = defaultConfigDir + "/commit_expiry.job"
+
+func parseCommitExpiry(expire string) (time.Duration, error) {
+	dur, err := time.ParseDuration(expire)
+	if err != nil || dur <= 0 {
+		merr := mgmterror.NewInvalidValueProtocolError()
+		merr.Message = fmt.Sprintf(
+			"Invalid expiry %s: must be a positive duration, eg 2h or 30m",
+			expire)
+		return 0, merr
+	}
+	return dur, nil
+}
+
+func commitExpiryMessage(exp session.CommitExpiry) string {
+	return fmt.Sprintf(
+		"Changes will be reverted at %s unless commit expiry %s is cancelled",
+		exp.Deadline.Format("2006-01-02 15:04:05"), exp.Id)
+}
+
+func (d *Disp) commitExpireInternal(
+	sid, message string,
+	expire time.Duration,
+	debug bool,
+) (string, error) {
+	before := d.cmgr.Running()
+	out, err := d.commitInternal(sid, message, debug, 0, false)
+	if err != nil {
+		return out, err
+	}
+	added, removed := session.CommitLeafChanges(before, d.cmgr.Running())
+	if len(added) == 0 && len(removed) == 0 {
+		return out, nil
+	}
+
+	exp, err := d.cmgr.StartCommitExpiry(commitExpiryJobFile,
+		session.CommitExpiry{
+			User:    d.ctx.User,
+			Comment: message,
+			Added:   added,
+			Removed: removed,
+		}, expire)
+	if err != nil {
+		return out, err
+	}
+	d.logEvent("Commit Expiry", fmt.Sprintf(
+		"Commit %s by %s expires at %s", exp.Id, exp.User,
+		exp.Deadline.Format(time.RFC3339)))
+	if out != "" {
+		out += "\n"
+	}
+	return out + commitExpiryMessage(exp), nil
+}
+
+// CommitExpire commits the session's changes, backing out exactly those
+// changes once expire, a duration such as 2h, has passed, unless the
+// expiry is cancelled with CancelCommitExpiry first.
+func (d *Disp) CommitExpire(
+	sid, message, expire string,
+	debug bool,
+) (string, error) {
+	dur, err := parseCommitExpiry(expire)
+	if err != nil {
+		return "", err
+	}
+	args := []string{"expire", expire}
+	if message != "" {
+		args = append(args, "comment", message)
+	}
+	cmdArgs := d.newCommandArgsForAaa("commit", args, nil)
+	if !d.authCommand(cmdArgs) {
+		return "", mgmterror.NewAccessDeniedApplicationError()
+	}
+
+	return d.accountCmdWrapStrErr(cmdArgs, func() (interface{}, error) {
+		return d.commitExpireInternal(sid, message, dur, debug)
+	})
+}
+
+// CommitExpiries returns, as JSON, the pending commit expiries, soonest
+// first.
+func (d *Disp) CommitExpiries(sid string) (string, error) {
+	exps := d.cmgr.CommitExpiries(commitExpiryJobFile)
+	if exps == nil {
+		exps = []session.CommitExpiry{}
+	}
+	buf, err := json.Marshal(exps)
+	if err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+func (d *Disp) cancelCommitExpiryInternal(id string) (string, error) {
+	for _, exp := range d.cmgr.CommitExpiries(commitExpiryJobFile) {
+		if exp.Id == id && exp.User != d.ctx.User && !d.ctx.Superuser {
+			err := mgmterror.NewAccessDeniedApplicationError()
+			err.Message = "Commit expiry set by another user"
+			return "", err
+		}
+	}
+	exp, err := d.cmgr.CancelCommitExpiry(commitExpiryJobFile, id)
+	if err != nil {
+		return "", err
+	}
+	d.logEvent("Commit Expiry", fmt.Sprintf(
+		"Expiry of commit %s cancelled by %s", exp.Id, d.ctx.User))
+	return fmt.Sprintf("Changes of commit %s are now permanent", exp.Id), nil
+}
+
+// CancelCommitExpiry cancels a pending commit expiry, making the changes
+// permanent.  Only the user who set the expiry, or a member of the
+// supergroup, may do this.
+func (d *Disp) CancelCommitExpiry(sid, id string) (string, error) {
+	args := d.newCommandArgsForAaa("cancel-commit-expiry", []string{id}, nil)
+	if !d.authCommand(args) {
+		return "", mgmterror.NewAccessDeniedApplicationError()
+	}
+
+	return d.accountCmdWrapStrErr(args, func() (interface{}, error) {
+		return d.cancelCommitExpiryInternal(id)
+	})
+}
+
+// revertCommitExpiry backs out the changes of the expired commit, leaving
+// any made since alone.
+func (d *Disp) revertCommitExpiry(exp session.CommitExpiry) error {
+	sid := "commit-expiry-revert"
+	if _, err := d.SessionSetup(sid); err != nil {
+		return err
+	}
+	defer d.SessionTeardown(sid)
+	sess, err := d.smgr.Get(d.ctx, sid)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range exp.Added {
+		// Already gone if changed again since
+		sess.Delete(d.ctx, pathutil.Makepath(path))
+	}
+	for _, path := range exp.Removed {
+		if err := sess.Set(d.ctx, pathutil.Makepath(path)); err != nil {
+			d.logEvent("Commit Expiry", fmt.Sprintf(
+				"Unable to restore %s: %s", path, err))
+		}
+	}
+	if !sess.Changed(d.ctx) {
+		return nil
+	}
+	_, err = d.commitInternal(sid,
+		fmt.Sprintf("Commit %s expired", exp.Id), false, 0, false)
+	return err
+}
+
+// revertCommitExpiry is called by the commit manager once a commit has
+// expired.
+func (s *Srv) revertCommitExpiry(exp session.CommitExpiry) {
+	d := s.newDisp(s.uid, int32(configd.SYSTEM), &auth.AuthEnv{}, s.CompMgr)
+	d.logEvent("Commit Expiry", "Commit "+exp.Id+" expired, reverting")
+	if err := d.revertCommitExpiry(exp); err != nil {
+		s.LogError(err)
+	}
+}
@@ -0,0 +1,328 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/danos/config/union"
+	"github.com/danos/configd/rpc"
+	"github.com/danos/configd/session"
+	"github.com/danos/mgmterror"
+	"github.com/danos/utils/pathutil"
+)
+
+// Commit notifications
+//
+// Rather than polling TreeGet, monitoring agents subscribe to the changes
+// made to RUNNING below a path, then wait for notifications, each of which
+// describes a commit: who made it, and each path changed with the new value
+// in the encoding asked for.  Waiting blocks the connection, so agents
+// should wait on a connection of their own.  Notifications are queued until
+// fetched, up to a limit after which the oldest are dropped, and the number
+// dropped is reported with the next notification.  Subscriptions that
+// aren't waited on for a while are discarded.
+//
+// Only changes the subscriber may read are reported, and their values are
+// filtered as for TreeGet, so a notification never reveals more than
+// reading RUNNING would.
+
+const (
+	maxQueuedNotifications       = 100
+	maxNotificationWait          = 5 * time.Minute
+	notificationIdleTimeout      = 10 * time.Minute
+	NotificationEncodingRFC7951  = "rfc7951"
+	NotificationEncodingInternal = "internal"
+)
+
+type commitSubscription struct {
+	id       string
+	uid      uint32
+	path     []string
+	encoding string
+	wake     chan struct{}
+
+	mu         sync.Mutex
+	queue      []*session.CommitNotification
+	dropped    uint64
+	lastActive time.Time
+}
+
+func (s *commitSubscription) wants(n *session.CommitNotification) bool {
+	for _, ch := range n.Changes {
+		if pathIsPrefix(s.path, ch.Path) || pathIsPrefix(ch.Path, s.path) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *commitSubscription) push(n *session.CommitNotification) {
+	s.mu.Lock()
+	if len(s.queue) == maxQueuedNotifications {
+		s.queue = s.queue[1:]
+		s.dropped++
+	}
+	s.queue = append(s.queue, n)
+	s.mu.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// pop returns the oldest queued notification, if any, along with the number
+// of notifications dropped before it.
+func (s *commitSubscription) pop() (*session.CommitNotification, uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastActive = time.Now()
+	if len(s.queue) == 0 {
+		return nil, 0
+	}
+	n := s.queue[0]
+	s.queue = s.queue[1:]
+	dropped := s.dropped
+	s.dropped = 0
+	return n, dropped
+}
+
+func (s *commitSubscription) idle() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return time.Since(s.lastActive) > notificationIdleTimeout
+}
+
+type commitSubscriptions struct {
+	mu sync.Mutex
+	m  map[string]*commitSubscription
+}
+
+var subscriptions = &commitSubscriptions{m: make(map[string]*commitSubscription)}
+
+// publish queues the notification for each subscriber interested in it,
+// discarding any subscriptions left idle.
+func (c *commitSubscriptions) publish(n *session.CommitNotification) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, sub := range c.m {
+		if sub.idle() {
+			delete(c.m, id)
+			continue
+		}
+		if sub.wants(n) {
+			sub.push(n)
+		}
+	}
+}
+
+func (c *commitSubscriptions) add(sub *commitSubscription) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.m[sub.id] = sub
+}
+
+func (c *commitSubscriptions) remove(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.m, id)
+}
+
+func pathIsPrefix(prefix, path []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func newNoSubscriptionError(id string) error {
+	err := mgmterror.NewInvalidValueApplicationError()
+	err.Message = fmt.Sprintf("No subscription %s", id)
+	return err
+}
+
+// getSubscription returns the subscription, which must belong to the caller.
+func (d *Disp) getSubscription(id string) (*commitSubscription, error) {
+	subscriptions.mu.Lock()
+	sub, ok := subscriptions.m[id]
+	subscriptions.mu.Unlock()
+	if !ok {
+		return nil, newNoSubscriptionError(id)
+	}
+	if sub.uid != d.ctx.Uid && !d.ctx.Configd && !d.ctx.Superuser {
+		return nil, newNoSubscriptionError(id)
+	}
+	return sub, nil
+}
+
+func newSubscriptionId() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Subscribe registers for notifications of commits changing RUNNING at or
+// below path, with values in the given encoding, returning the id to wait
+// on.
+func (d *Disp) Subscribe(path, encoding string) (string, error) {
+	switch encoding {
+	case NotificationEncodingRFC7951, NotificationEncodingInternal:
+	default:
+		err := mgmterror.NewInvalidValueApplicationError()
+		err.Message = fmt.Sprintf("Unsupported notification encoding %s",
+			encoding)
+		return "", err
+	}
+	ps := pathutil.Makepath(path)
+	if !d.authRead(ps) {
+		return "", mgmterror.NewAccessDeniedApplicationError()
+	}
+	if err := d.validatePath(ps); err != nil {
+		return "", err
+	}
+
+	id, err := newSubscriptionId()
+	if err != nil {
+		return "", err
+	}
+	subscriptions.add(&commitSubscription{
+		id:         id,
+		uid:        d.ctx.Uid,
+		path:       ps,
+		encoding:   encoding,
+		wake:       make(chan struct{}, 1),
+		lastActive: time.Now(),
+	})
+	return id, nil
+}
+
+func (d *Disp) Unsubscribe(id string) (bool, error) {
+	sub, err := d.getSubscription(id)
+	if err != nil {
+		return false, err
+	}
+	subscriptions.remove(sub.id)
+	return true, nil
+}
+
+type notificationChange struct {
+	Path      string          `json:"path"`
+	Operation string          `json:"operation"`
+	Value     json.RawMessage `json:"value,omitempty"`
+}
+
+type notification struct {
+	CommitId uint64               `json:"commit-id"`
+	User     string               `json:"user"`
+	Time     string               `json:"time"`
+	Dropped  uint64               `json:"dropped,omitempty"`
+	Changes  []notificationChange `json:"changes"`
+}
+
+// notificationValue returns the value at path after the commit, encoded
+// and filtered as TreeGet would.
+func (d *Disp) notificationValue(
+	n *session.CommitNotification, path []string, encoding string,
+) (json.RawMessage, error) {
+	auther := d.getROSession(rpc.RUNNING, "").NewAuther(d.ctx)
+	ut, err := union.NewNode(nil, n.New, d.ms, nil, 0).Descendant(auther, path)
+	if err != nil || ut == nil {
+		return nil, err
+	}
+	out, err := ut.Marshal("data", encoding, union.Authorizer(auther))
+	if err != nil {
+		return nil, err
+	}
+	if encoding == NotificationEncodingRFC7951 {
+		return json.RawMessage(out), nil
+	}
+	return json.Marshal(out)
+}
+
+// newNotification describes the changes the commit made that the
+// subscriber may read.
+func (d *Disp) newNotification(
+	sub *commitSubscription, n *session.CommitNotification,
+) (*notification, error) {
+	out := &notification{
+		CommitId: n.Id,
+		User:     n.User,
+		Time:     n.Time.Format(time.RFC3339),
+		Changes:  make([]notificationChange, 0, len(n.Changes)),
+	}
+	for _, ch := range n.Changes {
+		if !pathIsPrefix(sub.path, ch.Path) &&
+			!pathIsPrefix(ch.Path, sub.path) {
+			continue
+		}
+		if !d.authRead(ch.Path) {
+			continue
+		}
+		change := notificationChange{
+			Path:      pathutil.Pathstr(ch.Path),
+			Operation: ch.Operation,
+		}
+		if ch.Operation != session.ChangeDelete {
+			val, err := d.notificationValue(n, ch.Path, sub.encoding)
+			if err != nil {
+				return nil, err
+			}
+			change.Value = val
+		}
+		out.Changes = append(out.Changes, change)
+	}
+	return out, nil
+}
+
+// WaitNotification returns the next notification for the subscription, as
+// JSON, waiting up to timeout seconds for one.  An empty string is returned
+// if none arrives in time.
+func (d *Disp) WaitNotification(id string, timeout int) (string, error) {
+	sub, err := d.getSubscription(id)
+	if err != nil {
+		return "", err
+	}
+	wait := time.Duration(timeout) * time.Second
+	if wait > maxNotificationWait {
+		wait = maxNotificationWait
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	var dropped uint64
+	for {
+		n, ndropped := sub.pop()
+		dropped += ndropped
+		if n != nil {
+			out, err := d.newNotification(sub, n)
+			if err != nil {
+				return "", err
+			}
+			if len(out.Changes) == 0 {
+				continue
+			}
+			out.Dropped = dropped
+			buf, err := json.Marshal(out)
+			return string(buf), err
+		}
+		select {
+		case <-sub.wake:
+		case <-timer.C:
+			return "", nil
+		}
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/danos/configd/session"
+	"github.com/danos/configd/session/sessiontest"
+)
+
+const notifySchema = `
+container cont {
+	leaf value {
+		type string;
+	}
+	leaf existing {
+		type string;
+	}
+}
+container other {
+	leaf value {
+		type string;
+	}
+}`
+
+const notifyConfig = `
+cont {
+	existing foo
+}
+`
+
+func TestCommitNotifications(t *testing.T) {
+	srv, sess := sessiontest.NewTestSpec(t).
+		SetSingleSchema(notifySchema).
+		SetConfig(notifyConfig).
+		Init()
+	srv.Cmgr.AddCommitListener(subscriptions.publish)
+	d := &Disp{smgr: srv.Smgr, cmgr: srv.Cmgr, ms: srv.Ms,
+		msFull: srv.MsFull, ctx: srv.Ctx}
+
+	if _, err := d.Subscribe("/cont", "xml"); err == nil {
+		t.Fatal("Subscribed with an unsupported encoding")
+	}
+	id, err := d.Subscribe("/cont", NotificationEncodingRFC7951)
+	if err != nil {
+		t.Fatalf("Unable to subscribe: %s", err)
+	}
+	defer d.Unsubscribe(id)
+
+	commit := func(path []string) {
+		sessiontest.ValidateSet(t, sess, srv.Ctx, path, false)
+		if _, errs, ok := sess.Commit(srv.Ctx, "", false); !ok {
+			t.Fatalf("Unable to commit: %v", errs)
+		}
+	}
+
+	commit([]string{"other", "value", "foo"})
+	if out, err := d.WaitNotification(id, 0); err != nil || out != "" {
+		t.Fatalf("Unexpected notification of other change: %s %v", out, err)
+	}
+
+	commit([]string{"cont", "value", "bar"})
+	out, err := d.WaitNotification(id, 1)
+	if err != nil {
+		t.Fatalf("Unable to wait for notification: %s", err)
+	}
+	var n notification
+	if err := json.Unmarshal([]byte(out), &n); err != nil {
+		t.Fatalf("Invalid notification %s: %s", out, err)
+	}
+	if n.CommitId != srv.Cmgr.CommitId() {
+		t.Errorf("Unexpected commit id %d, expected %d", n.CommitId,
+			srv.Cmgr.CommitId())
+	}
+	if len(n.Changes) != 1 {
+		t.Fatalf("Unexpected changes: %s", out)
+	}
+	ch := n.Changes[0]
+	if ch.Path != "/cont/value" || ch.Operation != session.ChangeCreate ||
+		!strings.Contains(string(ch.Value), "bar") {
+		t.Fatalf("Unexpected change: %s", out)
+	}
+
+	if _, err := d.Unsubscribe(id); err != nil {
+		t.Fatalf("Unable to unsubscribe: %s", err)
+	}
+	if _, err := d.WaitNotification(id, 0); err == nil {
+		t.Fatal("Waited on a removed subscription")
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"os"
+	"sync"
+
+	"github.com/danos/config/data"
+	"github.com/danos/config/diff"
+	"github.com/danos/config/load"
+	"github.com/danos/configd"
+	"github.com/danos/configd/common"
+	"github.com/danos/configd/rpc"
+	"github.com/danos/configd/session"
+	"github.com/danos/mgmterror"
+	"github.com/danos/utils/pathutil"
+)
+
+// Comparing revisions
+//
+// Comparing two archived revisions read, parsed and showed each in turn,
+// then parsed both shows again to diff them, so large configurations were
+// held several times over.  The revisions are now loaded at the same time
+// and their data trees diffed directly, leaving out what the caller may
+// not read.  The comparison stops if the request is cancelled, though a
+// file being loaded is read to the end first.
+
+func newCompareCancelledError() error {
+	err := mgmterror.NewOperationFailedApplicationError()
+	err.Message = "Request cancelled"
+	return err
+}
+
+func requestCancelled(done <-chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
+}
+
+// readableTree returns a copy of n, the node at path, without anything the
+// caller may not read, or nil if the request is cancelled.
+func (d *Disp) readableTree(n *data.Node, path []string) *data.Node {
+	if requestCancelled(d.ctx.Done()) {
+		return nil
+	}
+	out := data.New(n.Name())
+	for _, ch := range n.Children() {
+		chPath := pathutil.CopyAppend(path, ch.Name())
+		if !d.authRead(chPath) {
+			continue
+		}
+		copied := d.readableTree(ch, chPath)
+		if copied == nil {
+			return nil
+		}
+		out.AddChild(copied)
+	}
+	return out
+}
+
+func (d *Disp) loadConfigFileTree(file string) (*data.Node, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r, err := d.cfgFileReader(f)
+	if err != nil {
+		return nil, err
+	}
+	if r, err = session.DecryptConfigReader(r); err != nil {
+		return nil, err
+	}
+	t, err, _ := load.LoadFile(file, r, d.ms)
+	return t, err
+}
+
+// loadConfigRevision returns the data tree of revision, the session's
+// candidate for "session", holding what the caller may read.
+func (d *Disp) loadConfigRevision(sid, revision string) (*data.Node, error) {
+	var t *data.Node
+	if revision == "session" {
+		candSess := d.getROSession(rpc.CANDIDATE, sid)
+		ut, err := candSess.GetTree(d.ctx, nil,
+			&session.TreeOpts{Defaults: false, Secrets: true})
+		if err != nil {
+			return nil, err
+		}
+		t = data.New("root")
+		if ut != nil {
+			t = ut.MergeWithoutDefaults()
+		}
+	} else {
+		var err error
+		t, err = d.loadConfigFileTree(configRevisionFileName(revision))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if d.ctx.Configd {
+		return t, nil
+	}
+	if t = d.readableTree(t, nil); t == nil {
+		return nil, newCompareCancelledError()
+	}
+	return t, nil
+}
+
+// loadConfigRevisions loads the revisions at the same time, returning
+// their trees in the same order.
+func (d *Disp) loadConfigRevisions(
+	sid string,
+	revisions ...string,
+) ([]*data.Node, error) {
+	trees := make([]*data.Node, len(revisions))
+	errs := make([]error, len(revisions))
+	var wg sync.WaitGroup
+	for i, revision := range revisions {
+		wg.Add(1)
+		go func(i int, revision string) {
+			defer wg.Done()
+			trees[i], errs[i] = d.loadConfigRevision(sid, revision)
+		}(i, revision)
+	}
+	loaded := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(loaded)
+	}()
+
+	select {
+	case <-loaded:
+	case <-d.ctx.Done():
+		return nil, newCompareCancelledError()
+	}
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return trees, nil
+}
+
+// compareConfigTrees returns the differences from old to new below spath.
+func (d *Disp) compareConfigTrees(
+	old, new *data.Node,
+	spath string,
+	ctxdiff bool,
+) string {
+	dtree := diff.NewNode(old, new, d.ms, nil)
+	dtree = dtree.Descendant(common.InternPath(spath))
+	hide := !configd.ShowSecrets(d.ctx)
+	return dtree.Serialize(ctxdiff, diff.HideSecrets(hide))
+}
@@ -0,0 +1,171 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/danos/configd/session/sessiontest"
+)
+
+const compareRevisionsSchema = `
+container cont {
+	list entry {
+		key name;
+		leaf name {
+			type string;
+		}
+		leaf value {
+			type string;
+		}
+		leaf password {
+			type string;
+			configd:secret "true";
+		}
+	}
+}`
+
+// compareRevisionsConfig returns a configuration of n entries, with each
+// entry whose number is a multiple of change given another value.
+func compareRevisionsConfig(n, change int) string {
+	var b bytes.Buffer
+	b.WriteString("cont {\n")
+	for i := 0; i < n; i++ {
+		value := "one"
+		if change != 0 && i%change == 0 {
+			value = "two"
+		}
+		fmt.Fprintf(&b, "\tentry entry%d {\n\t\tvalue %s\n"+
+			"\t\tpassword secret%d\n\t}\n", i, value, i)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func writeTestRevision(tb testing.TB, dir string, rev int, config string) {
+	var b bytes.Buffer
+	w := gzip.NewWriter(&b)
+	w.Write([]byte(config))
+	w.Close()
+	file := filepath.Join(dir, "archive", fmt.Sprintf("config.boot.%d.gz", rev))
+	if err := ioutil.WriteFile(file, b.Bytes(), 0644); err != nil {
+		tb.Fatalf("Unable to write revision: %s", err)
+	}
+}
+
+// setupCompareRevisions archives two revisions of n entries, the second
+// changing every change'th entry.
+func setupCompareRevisions(t *testing.T, n, change int) (*Disp, string) {
+	dir, err := ioutil.TempDir("", "configd-compare")
+	if err != nil {
+		t.Fatalf("Unable to create config dir: %s", err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "archive"), 0755); err != nil {
+		t.Fatalf("Unable to create archive dir: %s", err)
+	}
+	writeTestRevision(t, dir, 0, compareRevisionsConfig(n, 0))
+	writeTestRevision(t, dir, 1, compareRevisionsConfig(n, change))
+
+	srv, _ := sessiontest.NewTestSpec(t).
+		SetSingleSchema(compareRevisionsSchema).
+		Init()
+	d := &Disp{smgr: srv.Smgr, cmgr: srv.Cmgr, ms: srv.Ms,
+		msFull: srv.MsFull, ctx: srv.Ctx}
+	return d, dir
+}
+
+// compareRevisionsText compares the revisions by showing them, as they
+// were compared before their trees were diffed directly.
+func compareRevisionsText(d *Disp, revOne, revTwo string) (string, error) {
+	one, err := d.readCfgFile(configRevisionFileName(revOne), false, true)
+	if err != nil {
+		return "", err
+	}
+	two, err := d.readCfgFile(configRevisionFileName(revTwo), false, true)
+	if err != nil {
+		return "", err
+	}
+	return d.Compare(one, two, "", true)
+}
+
+func TestCompareConfigRevisionsTrees(t *testing.T) {
+	orig := configDir
+	defer func() { configDir = orig }()
+	d, dir := setupCompareRevisions(t, 20, 7)
+	defer os.RemoveAll(dir)
+	configDir = dir
+
+	out, err := d.compareConfigRevisionsInternal("", "1", "0")
+	if err != nil {
+		t.Fatalf("Unable to compare revisions: %s", err)
+	}
+	exp, err := compareRevisionsText(d, "1", "0")
+	if err != nil {
+		t.Fatalf("Unable to compare revisions as text: %s", err)
+	}
+	if out != exp {
+		t.Fatalf("Unexpected comparison\nexpect: %s\nactual: %s", exp, out)
+	}
+	if out == "" {
+		t.Fatal("No differences found")
+	}
+}
+
+func TestCompareConfigRevisionsCancelled(t *testing.T) {
+	orig := configDir
+	defer func() { configDir = orig }()
+	d, dir := setupCompareRevisions(t, 20, 7)
+	defer os.RemoveAll(dir)
+	configDir = dir
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	d.ctx.Request = ctx
+	defer func() { d.ctx.Request = nil }()
+	if _, err := d.compareConfigRevisionsInternal("", "1", "0"); err == nil {
+		t.Fatal("Cancelled comparison succeeded")
+	}
+}
+
+// Entries in each revision, making it a few megabytes
+const benchRevisionEntries = 40000
+
+func benchmarkCompareRevisions(
+	b *testing.B,
+	compare func(d *Disp, revOne, revTwo string) (string, error),
+) {
+	orig := configDir
+	defer func() { configDir = orig }()
+	// sessiontest needs a *testing.T
+	d, dir := setupCompareRevisions(new(testing.T), benchRevisionEntries, 100)
+	defer os.RemoveAll(dir)
+	configDir = dir
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := compare(d, "1", "0"); err != nil {
+			b.Fatalf("Unable to compare revisions: %s", err)
+		}
+	}
+}
+
+func BenchmarkCompareConfigRevisionsText(b *testing.B) {
+	benchmarkCompareRevisions(b, compareRevisionsText)
+}
+
+func BenchmarkCompareConfigRevisionsTrees(b *testing.B) {
+	benchmarkCompareRevisions(b,
+		func(d *Disp, revOne, revTwo string) (string, error) {
+			return d.compareConfigRevisionsInternal("", revOne, revTwo)
+		})
+}
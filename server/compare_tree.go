@@ -0,0 +1,169 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"encoding/json"
+
+	"github.com/danos/config/diff"
+	"github.com/danos/config/load"
+	"github.com/danos/config/schema"
+	"github.com/danos/configd"
+	"github.com/danos/configd/rpc"
+	"github.com/danos/configd/session"
+	"github.com/danos/mgmterror"
+	"github.com/danos/utils/pathutil"
+)
+
+// Structured session changes
+//
+// CompareSessionChanges returns the same curly brace diff the CLI shows,
+// which automation and audit tools must then parse.  CompareTree returns
+// the changes as a JSON encoded list of rpc.TreeChanges instead: the
+// topmost containers and list entries added or deleted, and each leaf or
+// leaf-list value created, deleted or updated, with its old and new value.
+// Secrets are hidden unless the caller may see them, as for Compare.
+
+const hiddenSecret = session.RedactedSecret
+
+type treeChanges struct {
+	hide    bool
+	changes []rpc.TreeChange
+}
+
+func (tc *treeChanges) add(
+	op string,
+	path []string,
+	sch schema.Node,
+	old, new string,
+) {
+	if tc.hide && sch.ConfigdExt().Secret {
+		if old != "" {
+			old = hiddenSecret
+		}
+		if new != "" {
+			new = hiddenSecret
+		}
+	}
+	tc.changes = append(tc.changes, rpc.TreeChange{
+		Op:   op,
+		Path: pathutil.Pathstr(path),
+		Old:  old,
+		New:  new,
+	})
+}
+
+// leafValues returns the deleted and added values below a leaf or
+// leaf-list.
+func leafValues(dn *diff.Node) (old, new []string) {
+	for _, ch := range dn.Children() {
+		switch {
+		case ch.Added():
+			new = append(new, ch.Name())
+		case ch.Deleted():
+			old = append(old, ch.Name())
+		}
+	}
+	return old, new
+}
+
+func (tc *treeChanges) walk(dn *diff.Node, path []string) {
+	for _, ch := range dn.Children() {
+		if !ch.Added() && !ch.Deleted() && !ch.Changed() {
+			continue
+		}
+		chPath := pathutil.CopyAppend(path, ch.Name())
+		switch ch.Schema().(type) {
+		case schema.Leaf:
+			old, new := leafValues(ch)
+			op := session.ChangeUpdate
+			switch {
+			case ch.Added():
+				op = session.ChangeCreate
+			case ch.Deleted():
+				op = session.ChangeDelete
+			}
+			var oldVal, newVal string
+			if len(old) > 0 {
+				oldVal = old[0]
+			}
+			if len(new) > 0 {
+				newVal = new[0]
+			}
+			tc.add(op, chPath, ch.Schema(), oldVal, newVal)
+		case schema.LeafList:
+			old, new := leafValues(ch)
+			for _, val := range old {
+				tc.add(session.ChangeDelete, chPath, ch.Schema(), val, "")
+			}
+			for _, val := range new {
+				tc.add(session.ChangeCreate, chPath, ch.Schema(), "", val)
+			}
+		case schema.List:
+			// Entries are reported, rather than the list as a whole.
+			tc.walk(ch, chPath)
+		default:
+			switch {
+			case ch.Added():
+				tc.add(session.ChangeCreate, chPath, ch.Schema(), "", "")
+			case ch.Deleted():
+				tc.add(session.ChangeDelete, chPath, ch.Schema(), "", "")
+			default:
+				tc.walk(ch, chPath)
+			}
+		}
+	}
+}
+
+func (d *Disp) compareTreeInternal(sid, path string) (string, error) {
+	runningShow, err := d.getROSession(rpc.RUNNING, sid).ShowForceSecrets(
+		d.ctx, nil, false, false)
+	if err != nil {
+		return "", err
+	}
+	candShow, err := d.getROSession(rpc.CANDIDATE, sid).ShowForceSecrets(
+		d.ctx, nil, false, false)
+	if err != nil {
+		return "", err
+	}
+	running, err := load.LoadStringNoValidate("running", runningShow)
+	if err != nil {
+		return "", err
+	}
+	cand, err := load.LoadStringNoValidate("candidate", candShow)
+	if err != nil {
+		return "", err
+	}
+
+	ps := pathutil.Makepath(path)
+	tc := &treeChanges{
+		hide:    !configd.ShowSecrets(d.ctx),
+		changes: []rpc.TreeChange{},
+	}
+	if dn := diff.NewNode(cand, running, d.ms, nil); dn != nil {
+		if dn = dn.Descendant(ps); dn != nil {
+			tc.walk(dn, ps)
+		}
+	}
+	buf, err := json.Marshal(tc.changes)
+	if err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+// CompareTree returns the changes at or below path in the session's
+// candidate, relative to running, as a JSON encoded list of
+// rpc.TreeChanges.
+func (d *Disp) CompareTree(sid, path string) (string, error) {
+	args := d.newCommandArgsForAaa("compare", nil, pathutil.Makepath(path))
+	if !d.authCommand(args) {
+		return "", mgmterror.NewAccessDeniedApplicationError()
+	}
+
+	return d.accountCmdWrapStrErr(args, func() (interface{}, error) {
+		return d.compareTreeInternal(sid, path)
+	})
+}
@@ -0,0 +1,114 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/danos/config/auth"
+	"github.com/danos/configd/rpc"
+)
+
+const compareTreeSchema = `
+container top {
+	leaf name {
+		type string;
+	}
+	leaf password {
+		type string;
+		configd:secret "true";
+	}
+	leaf-list dns {
+		type string;
+	}
+	list peer {
+		key id;
+		leaf id {
+			type string;
+		}
+		leaf addr {
+			type string;
+		}
+	}
+}`
+
+const compareTreeConfig = `
+top {
+	name old
+	password oldpass
+	dns 1.1.1.1
+	peer a {
+		addr 10.0.0.1
+	}
+}
+`
+
+func TestCompareTree(t *testing.T) {
+	a := auth.TestAutherAllowAll()
+	d := newTestDispatcher(t, a, compareTreeSchema, compareTreeConfig)
+	dispTestSetupSession(t, d, testSID)
+	dispTestSet(t, d, testSID, "top/name/new")
+	dispTestSet(t, d, testSID, "top/dns/8.8.8.8")
+	dispTestSet(t, d, testSID, "top/peer/b/addr/10.0.0.2")
+	dispTestDelete(t, d, testSID, "top/peer/a")
+
+	out, err := d.CompareTree(testSID, "")
+	if err != nil {
+		t.Fatalf("Unable to compare tree: %s", err)
+	}
+	var changes []rpc.TreeChange
+	if err := json.Unmarshal([]byte(out), &changes); err != nil {
+		t.Fatalf("Invalid changes %s: %s", out, err)
+	}
+	exp := []rpc.TreeChange{
+		{Op: "create", Path: "/top/dns", New: "8.8.8.8"},
+		{Op: "update", Path: "/top/name", Old: "old", New: "new"},
+		{Op: "delete", Path: "/top/peer/a"},
+		{Op: "create", Path: "/top/peer/b"},
+	}
+	if !reflect.DeepEqual(changes, exp) {
+		t.Fatalf("Expected changes:\n%v\nGot:\n%v", exp, changes)
+	}
+	assertCommandAaaNoSecrets(t, a, []string{"compare"})
+}
+
+func TestCompareTreeNoChanges(t *testing.T) {
+	d := newTestDispatcher(t, auth.TestAutherAllowAll(), compareTreeSchema,
+		compareTreeConfig)
+	dispTestSetupSession(t, d, testSID)
+
+	out, err := d.CompareTree(testSID, "/top")
+	if err != nil {
+		t.Fatalf("Unable to compare tree: %s", err)
+	}
+	if out != "[]" {
+		t.Fatalf("Unexpected changes: %s", out)
+	}
+}
+
+func TestCompareTreeHidesSecrets(t *testing.T) {
+	d := newTestDispatcherWithCustomAuth(t, auth.TestAutherAllowAll(),
+		compareTreeSchema, compareTreeConfig, false, false)
+	dispTestSetupSession(t, d, testSID)
+	dispTestSet(t, d, testSID, "top/password/newpass")
+
+	out, err := d.CompareTree(testSID, "/top/password")
+	if err != nil {
+		t.Fatalf("Unable to compare tree: %s", err)
+	}
+	var changes []rpc.TreeChange
+	if err := json.Unmarshal([]byte(out), &changes); err != nil {
+		t.Fatalf("Invalid changes %s: %s", out, err)
+	}
+	exp := []rpc.TreeChange{
+		{Op: "update", Path: "/top/password",
+			Old: "********", New: "********"},
+	}
+	if !reflect.DeepEqual(changes, exp) {
+		t.Fatalf("Expected changes:\n%v\nGot:\n%v", exp, changes)
+	}
+}
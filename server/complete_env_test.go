@@ -0,0 +1,84 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/danos/config/auth"
+	"github.com/danos/configd/server"
+)
+
+const completeEnvSchema = `
+container cont {
+	presence "For completion environment testing";
+	list tagnode {
+		key name;
+		leaf name {
+			type string;
+		}
+	}
+	leaf-list multi {
+		type string;
+	}
+	leaf flag {
+		type empty;
+	}
+	leaf value {
+		type string;
+	}
+}`
+
+const completeEnvConfig = `
+cont {
+	value foo
+}
+`
+
+func checkCompleteEnv(
+	t *testing.T,
+	d *server.Disp,
+	path string,
+	set ...string,
+) {
+	t.Helper()
+	exp := map[string]int{
+		"is_value":    0,
+		"is_leaf":     0,
+		"is_multi":    0,
+		"is_tag":      0,
+		"is_typeless": 0,
+		"is_presence": 0,
+		"is_secret":   0,
+		"has_allowed": 0,
+		"exists":      0,
+		"is_frozen":   0,
+	}
+	for _, flag := range set {
+		exp[flag] = 1
+	}
+	env, err := d.NodeGetCompleteEnv(testSID, path)
+	if err != nil {
+		t.Fatalf("Unable to get completion environment for %s: %s",
+			path, err)
+	}
+	if !reflect.DeepEqual(env, exp) {
+		t.Fatalf("Unexpected completion environment for %s:\nExp: %v\nGot: %v",
+			path, exp, env)
+	}
+}
+
+func TestNodeGetCompleteEnv(t *testing.T) {
+	d := newTestDispatcher(t, auth.TestAutherAllowAll(),
+		completeEnvSchema, completeEnvConfig)
+	dispTestSetupSession(t, d, testSID)
+
+	checkCompleteEnv(t, d, "/cont", "is_typeless", "is_presence", "exists")
+	checkCompleteEnv(t, d, "/cont/tagnode", "is_tag")
+	checkCompleteEnv(t, d, "/cont/multi", "is_leaf", "is_multi")
+	checkCompleteEnv(t, d, "/cont/flag", "is_leaf", "is_typeless")
+	checkCompleteEnv(t, d, "/cont/value", "is_leaf", "exists")
+}
@@ -0,0 +1,73 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"strings"
+
+	"github.com/danos/config/schema"
+)
+
+// Value hints
+//
+// When completing a value, help lists placeholders for the values the node
+// takes, eg <0..65535>, alongside any existing values.  For strings this
+// was only ever the generic <text>, even where configd:pattern-help says
+// what the value looks like.  The schema's help map holds a placeholder
+// for each configd:pattern-help, and for the ranges and enumerations of
+// the node's type, so those are offered instead, letting the CLI show eg
+// <x.x.x.x> as it does for other types.  Leafrefs take the values of their
+// target, which help already lists, so are left alone.
+
+const genericValueHint = "<text>"
+
+func isValueHint(s string) bool {
+	return strings.HasPrefix(s, "<") && strings.HasSuffix(s, ">") &&
+		s != "<Enter>"
+}
+
+// valueHints returns the placeholders, with their help, for the values of
+// the node at path.
+func (d *Disp) valueHints(ps []string) map[string]string {
+	hints := make(map[string]string)
+	tmpl, err := d.schemaPathDescendant(ps)
+	if err != nil || tmpl.Val {
+		return hints
+	}
+	if _, ok := tmpl.Node.Type().(schema.Leafref); ok {
+		return hints
+	}
+	hm, ok := tmpl.Node.(interface {
+		HelpMap() map[string]string
+	})
+	if !ok {
+		return hints
+	}
+	for val, help := range hm.HelpMap() {
+		if isValueHint(val) {
+			hints[val] = help
+		}
+	}
+	return hints
+}
+
+// addValueHints replaces the generic placeholder in help with the node's
+// own, if it has any more specific.
+func (d *Disp) addValueHints(ps []string, help map[string]string) {
+	if _, ok := help[genericValueHint]; !ok {
+		return
+	}
+	hints := d.valueHints(ps)
+	delete(hints, genericValueHint)
+	if len(hints) == 0 {
+		return
+	}
+	delete(help, genericValueHint)
+	for val, h := range hints {
+		if _, ok := help[val]; !ok {
+			help[val] = h
+		}
+	}
+}
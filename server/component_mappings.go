@@ -0,0 +1,65 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+// Component mappings
+//
+// Configuration under a namespace is only applied if some VCI component
+// provides a model owning that namespace.  When none does the
+// configuration is accepted and committed but silently does nothing,
+// which is hard to diagnose from outside configd.  GetComponentMappings
+// reports, for the namespace of every module in the schema, the module,
+// the model owning it, if any, and whether the service behind it is
+// running, where the component manager can tell.
+
+const (
+	componentActive   = "active"
+	componentInactive = "inactive"
+	componentUnknown  = "unknown"
+	componentUnowned  = "unowned"
+)
+
+// Not all component managers can report on the services behind models.
+type componentStatusChecker interface {
+	IsActive(name string) (bool, error)
+}
+
+func componentStatus(compMgr interface{}, model string) string {
+	checker, ok := compMgr.(componentStatusChecker)
+	if !ok {
+		return componentUnknown
+	}
+	active, err := checker.IsActive(model)
+	switch {
+	case err != nil:
+		return componentUnknown
+	case active:
+		return componentActive
+	default:
+		return componentInactive
+	}
+}
+
+// GetComponentMappings returns, keyed by namespace, the module, owning
+// model and its status ("active", "inactive" or "unknown"), or a status of
+// "unowned" if no model owns the namespace.
+func (d *Disp) GetComponentMappings() (map[string]map[string]string, error) {
+	out := make(map[string]map[string]string)
+	compMgr := d.ctx.CompMgr
+	for name, mod := range d.ms.Modules() {
+		ns := mod.Namespace()
+		entry := map[string]string{"module": name, "status": componentUnowned}
+		if compMgr != nil {
+			model, ok := compMgr.GetComponentNSMappings().
+				GetModelNameForNamespace(ns)
+			if ok {
+				entry["model"] = model
+				entry["status"] = componentStatus(compMgr, model)
+			}
+		}
+		out[ns] = entry
+	}
+	return out, nil
+}
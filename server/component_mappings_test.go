@@ -0,0 +1,37 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server_test
+
+import (
+	"testing"
+
+	"github.com/danos/config/auth"
+)
+
+func TestGetComponentMappings(t *testing.T) {
+	d := newTestDispatcher(t, auth.TestAutherAllowAll(), confirmSchema,
+		emptyconfig)
+
+	maps, err := d.GetComponentMappings()
+	if err != nil {
+		t.Fatalf("Unable to get component mappings: %s", err)
+	}
+	entry, ok := maps["urn:vyatta.com:test:configd-session"]
+	if !ok {
+		t.Fatalf("Test module namespace not found in: %v", maps)
+	}
+	if entry["module"] != "test-configd-session" {
+		t.Fatalf("Unexpected module for test namespace: %v", entry)
+	}
+	switch entry["status"] {
+	case "active", "inactive", "unknown":
+		if entry["model"] == "" {
+			t.Fatalf("Owned namespace has no model: %v", entry)
+		}
+	case "unowned":
+	default:
+		t.Fatalf("Unexpected status: %v", entry)
+	}
+}
@@ -0,0 +1,404 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/danos/configd/common"
+	"github.com/danos/configd/rpc"
+	"github.com/danos/mgmterror"
+)
+
+// Config bundles
+//
+// When a device is replaced, its replacement needs more than the running
+// configuration: the feature markers enabled in the capabilities directory
+// change which parts of the schema exist, and the configuration only makes
+// sense against the same modules and deviations.  A config bundle is a tar
+// archive, base64 encoded for transport, holding:
+//
+//	manifest.json    - bundle version and when it was made
+//	config.boot      - the running configuration, including secrets
+//	features/...     - the feature marker files
+//	schemas.xml      - the modules, with their revisions
+//	features.json    - the features enabled in each module
+//	deviations.json  - the deviations applied to each module
+//
+// Importing a bundle replaces the candidate with its configuration, writes
+// its feature markers and commits.  If anything fails the feature markers
+// are put back as they were and the candidate discarded, so either all of
+// the bundle is restored or none of it is.  Modules and deviations are
+// part of the installed software so can't be restored; differences from
+// those of the bundle are reported as warnings.  Changes to the features
+// enabled take effect once configd is restarted.
+
+const (
+	configBundleVersion = 1
+
+	bundleManifest   = "manifest.json"
+	bundleConfig     = "config.boot"
+	bundleSchemas    = "schemas.xml"
+	bundleFeatures   = "features.json"
+	bundleDeviations = "deviations.json"
+	bundleMarkerDir  = "features/"
+
+	maxConfigBundleSize = 64 << 20
+)
+
+type configBundleManifest struct {
+	Version  int    `json:"version"`
+	Created  string `json:"created"`
+	Instance string `json:"instance,omitempty"`
+}
+
+func newInvalidBundleError(format string, args ...interface{}) error {
+	err := mgmterror.NewInvalidValueApplicationError()
+	err.Message = "Invalid config bundle: " + fmt.Sprintf(format, args...)
+	return err
+}
+
+// readFeatureMarkers returns the content of each file below the
+// capabilities directory, by path relative to it.
+func readFeatureMarkers(root string) (map[string][]byte, error) {
+	markers := make(map[string][]byte)
+	if root == "" {
+		return markers, nil
+	}
+	if fi, err := os.Stat(root); err != nil || !fi.IsDir() {
+		return markers, nil
+	}
+	err := filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || !fi.Mode().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		buf, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		markers[filepath.ToSlash(rel)] = buf
+		return nil
+	})
+	return markers, err
+}
+
+// writeFeatureMarkers makes the capabilities directory hold exactly the
+// given markers.
+func writeFeatureMarkers(root string, markers map[string][]byte) error {
+	if root == "" {
+		return nil
+	}
+	old, err := readFeatureMarkers(root)
+	if err != nil {
+		return err
+	}
+	for rel := range old {
+		if _, ok := markers[rel]; !ok {
+			if err := os.Remove(filepath.Join(root, rel)); err != nil {
+				return err
+			}
+		}
+	}
+	for rel, buf := range markers {
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(path, buf, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeConfigBundle(files map[string][]byte) (string, error) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b bytes.Buffer
+	tw := tar.NewWriter(&b)
+	now := time.Now()
+	for _, name := range names {
+		hdr := &tar.Header{
+			Name:    name,
+			Mode:    0600,
+			Size:    int64(len(files[name])),
+			ModTime: now,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return "", err
+		}
+		if _, err := tw.Write(files[name]); err != nil {
+			return "", err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b.Bytes()), nil
+}
+
+// validBundleMarker checks a feature marker's path stays within the
+// capabilities directory.
+func validBundleMarker(rel string) bool {
+	clean := filepath.Clean(filepath.FromSlash(rel))
+	return rel != "" && !filepath.IsAbs(clean) && clean != "." &&
+		clean != ".." && !strings.HasPrefix(clean, ".."+string(filepath.Separator))
+}
+
+func readConfigBundle(bundle string) (map[string][]byte, error) {
+	if len(bundle) > base64.StdEncoding.EncodedLen(maxConfigBundleSize) {
+		return nil, newInvalidBundleError("larger than %d bytes",
+			maxConfigBundleSize)
+	}
+	buf, err := base64.StdEncoding.DecodeString(bundle)
+	if err != nil {
+		return nil, newInvalidBundleError("%s", err)
+	}
+	files := make(map[string][]byte)
+	tr := tar.NewReader(bytes.NewReader(buf))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, newInvalidBundleError("%s", err)
+		}
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+			continue
+		}
+		if strings.HasPrefix(hdr.Name, bundleMarkerDir) &&
+			!validBundleMarker(strings.TrimPrefix(hdr.Name, bundleMarkerDir)) {
+			return nil, newInvalidBundleError("invalid feature marker %s",
+				hdr.Name)
+		}
+		content, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, newInvalidBundleError("%s", err)
+		}
+		files[hdr.Name] = content
+	}
+
+	var manifest configBundleManifest
+	if err := json.Unmarshal(files[bundleManifest], &manifest); err != nil {
+		return nil, newInvalidBundleError("no manifest")
+	}
+	if manifest.Version != configBundleVersion {
+		return nil, newInvalidBundleError("unsupported version %d",
+			manifest.Version)
+	}
+	if _, ok := files[bundleConfig]; !ok {
+		return nil, newInvalidBundleError("no configuration")
+	}
+	return files, nil
+}
+
+func bundleFeatureMarkers(files map[string][]byte) map[string][]byte {
+	markers := make(map[string][]byte)
+	for name, buf := range files {
+		if strings.HasPrefix(name, bundleMarkerDir) {
+			markers[strings.TrimPrefix(name, bundleMarkerDir)] = buf
+		}
+	}
+	return markers
+}
+
+// compareBundleModules returns warnings for the modules whose deviations or
+// features differ from those in the bundle.
+func compareBundleModules(kind string, bundled, installed map[string]string) []error {
+	mods := make([]string, 0, len(bundled))
+	for mod := range bundled {
+		mods = append(mods, mod)
+	}
+	sort.Strings(mods)
+
+	var warns []error
+	for _, mod := range mods {
+		cur, ok := installed[mod]
+		switch {
+		case !ok:
+			warns = append(warns, fmt.Errorf(
+				"Module %s in bundle is not installed", mod))
+		case cur != bundled[mod]:
+			warns = append(warns, fmt.Errorf(
+				"%s of module %s differ: bundle [%s], installed [%s]",
+				kind, mod, bundled[mod], cur))
+		}
+	}
+	return warns
+}
+
+func (d *Disp) capabilitiesDir() string {
+	if d.ctx.Config == nil {
+		return ""
+	}
+	return d.ctx.Config.Capabilities
+}
+
+func (d *Disp) exportConfigBundleInternal() (string, error) {
+	// The bundle must restore the configuration in full, secrets included.
+	if !d.ctx.Configd {
+		d.ctx.RaisePrivileges()
+		defer d.ctx.DropPrivileges()
+	}
+	cfg, err := d.show(rpc.RUNNING, "", nil, false, false)
+	if err != nil {
+		return "", err
+	}
+	schemas, _ := d.GetModuleSchemas()
+	features, _ := d.GetFeatures()
+	deviations, _ := d.GetDeviations()
+	markers, err := readFeatureMarkers(d.capabilitiesDir())
+	if err != nil {
+		return "", err
+	}
+
+	manifest := configBundleManifest{
+		Version: configBundleVersion,
+		Created: time.Now().Format(time.RFC3339),
+	}
+	if d.ctx.Config != nil {
+		manifest.Instance = d.ctx.Config.Instance
+	}
+	files := map[string][]byte{
+		bundleConfig:  []byte(cfg + getCurrentConfigVersion()),
+		bundleSchemas: []byte(schemas),
+	}
+	for name, v := range map[string]interface{}{
+		bundleManifest:   manifest,
+		bundleFeatures:   features,
+		bundleDeviations: deviations,
+	} {
+		buf, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		files[name] = buf
+	}
+	for rel, buf := range markers {
+		files[bundleMarkerDir+rel] = buf
+	}
+	return writeConfigBundle(files)
+}
+
+// ExportConfigBundle returns a config bundle of the running configuration,
+// feature markers and schema details, for restoring on a replacement
+// device.  As the bundle includes secrets, only superusers may export one.
+func (d *Disp) ExportConfigBundle() (string, error) {
+	if !d.ctx.Superuser {
+		return "", mgmterror.NewAccessDeniedApplicationError()
+	}
+	args := d.newCommandArgsForAaa("export-config-bundle", nil, nil)
+	if !d.authCommand(args) {
+		return "", mgmterror.NewAccessDeniedApplicationError()
+	}
+	return d.accountCmdWrapStrErr(args, func() (interface{}, error) {
+		return d.exportConfigBundleInternal()
+	})
+}
+
+func (d *Disp) importConfigBundleInternal(sid, bundle string) (bool, error) {
+	files, err := readConfigBundle(bundle)
+	if err != nil {
+		return false, err
+	}
+	sess, err := d.smgr.Get(d.ctx, sid)
+	if err != nil {
+		return false, err
+	}
+
+	var warns []error
+	var features, deviations map[string]string
+	if json.Unmarshal(files[bundleDeviations], &deviations) == nil {
+		installed, _ := d.GetDeviations()
+		warns = append(warns,
+			compareBundleModules("Deviations", deviations, installed)...)
+	}
+	if json.Unmarshal(files[bundleFeatures], &features) == nil {
+		installed, _ := d.GetFeatures()
+		warns = append(warns,
+			compareBundleModules("Features", features, installed)...)
+	}
+
+	if !d.ctx.Configd {
+		d.ctx.RaisePrivileges()
+		defer d.ctx.DropPrivileges()
+	}
+	err, loadWarns := sess.Load(d.ctx, bundleConfig,
+		bytes.NewReader(files[bundleConfig]))
+	if err != nil {
+		d.discardInternal(sid)
+		return false, err
+	}
+	warns = append(warns, loadWarns...)
+
+	root := d.capabilitiesDir()
+	oldMarkers, err := readFeatureMarkers(root)
+	if err != nil {
+		d.discardInternal(sid)
+		return false, err
+	}
+	markers := bundleFeatureMarkers(files)
+	restore := func() {
+		if err := writeFeatureMarkers(root, oldMarkers); err != nil {
+			d.ctx.Elog.Printf("Unable to restore feature markers: %s", err)
+		}
+		d.discardInternal(sid)
+	}
+	if err := writeFeatureMarkers(root, markers); err != nil {
+		restore()
+		return false, err
+	}
+	if sess.Changed(d.ctx) {
+		_, err := d.commitInternal(sid, "Restored from config bundle",
+			false, 0, false)
+		if err != nil {
+			restore()
+			return false, err
+		}
+	}
+	if !reflect.DeepEqual(oldMarkers, markers) {
+		warns = append(warns, fmt.Errorf(
+			"Feature changes take effect once configd is restarted"))
+	}
+	return true, common.FormatWarnings(warns)
+}
+
+// ImportConfigBundle restores the configuration and feature markers of a
+// config bundle, committing them through the given session.  Differences
+// between the bundle's modules and those installed are returned as
+// warnings.
+func (d *Disp) ImportConfigBundle(sid, bundle string) (bool, error) {
+	if !d.ctx.Superuser {
+		return false, mgmterror.NewAccessDeniedApplicationError()
+	}
+	args := d.newCommandArgsForAaa("import-config-bundle", nil, nil)
+	if !d.authCommand(args) {
+		return false, mgmterror.NewAccessDeniedApplicationError()
+	}
+	return d.accountCmdWrapBoolErr(args, func() (interface{}, error) {
+		return d.importConfigBundleInternal(sid, bundle)
+	})
+}
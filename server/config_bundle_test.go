@@ -0,0 +1,98 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func newTestBundleManifest() []byte {
+	return []byte(`{"version":1,"created":"2021-01-01T00:00:00Z"}`)
+}
+
+func TestConfigBundleRoundTrip(t *testing.T) {
+	files := map[string][]byte{
+		bundleManifest:            newTestBundleManifest(),
+		bundleConfig:              []byte("system {\n}\n"),
+		bundleMarkerDir + "a/foo": []byte("foo"),
+	}
+	bundle, err := writeConfigBundle(files)
+	if err != nil {
+		t.Fatalf("Unable to write bundle: %s", err)
+	}
+	got, err := readConfigBundle(bundle)
+	if err != nil {
+		t.Fatalf("Unable to read bundle: %s", err)
+	}
+	if !reflect.DeepEqual(got, files) {
+		t.Fatalf("Unexpected bundle content.\nExp: %v\nGot: %v", files, got)
+	}
+	exp := map[string][]byte{"a/foo": []byte("foo")}
+	if markers := bundleFeatureMarkers(got); !reflect.DeepEqual(markers, exp) {
+		t.Fatalf("Unexpected markers.\nExp: %v\nGot: %v", exp, markers)
+	}
+}
+
+func TestConfigBundleRejectsEscapingMarker(t *testing.T) {
+	var b bytes.Buffer
+	tw := tar.NewWriter(&b)
+	for name, buf := range map[string][]byte{
+		bundleManifest:                  newTestBundleManifest(),
+		bundleConfig:                    []byte(""),
+		bundleMarkerDir + "../../etc/x": []byte("x"),
+	} {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0600,
+			Size: int64(len(buf))})
+		tw.Write(buf)
+	}
+	tw.Close()
+	_, err := readConfigBundle(base64.StdEncoding.EncodeToString(b.Bytes()))
+	if err == nil {
+		t.Fatalf("Bundle with escaping feature marker accepted")
+	}
+}
+
+func TestConfigBundleRejectsUnknownVersion(t *testing.T) {
+	bundle, _ := writeConfigBundle(map[string][]byte{
+		bundleManifest: []byte(`{"version":99}`),
+		bundleConfig:   []byte(""),
+	})
+	if _, err := readConfigBundle(bundle); err == nil {
+		t.Fatalf("Bundle with unsupported version accepted")
+	}
+}
+
+func TestWriteFeatureMarkers(t *testing.T) {
+	root, err := ioutil.TempDir("", "bundle-features")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	os.MkdirAll(filepath.Join(root, "mod"), 0755)
+	ioutil.WriteFile(filepath.Join(root, "mod", "stale"), []byte("x"), 0644)
+
+	markers := map[string][]byte{
+		"mod/keep":     []byte("1"),
+		"other/marker": []byte("2"),
+	}
+	if err := writeFeatureMarkers(root, markers); err != nil {
+		t.Fatalf("Unable to write markers: %s", err)
+	}
+	got, err := readFeatureMarkers(root)
+	if err != nil {
+		t.Fatalf("Unable to read markers: %s", err)
+	}
+	if !reflect.DeepEqual(got, markers) {
+		t.Fatalf("Unexpected markers.\nExp: %v\nGot: %v", markers, got)
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/danos/config/auth"
+	"github.com/danos/mgmterror"
+	"github.com/danos/utils/pathutil"
+)
+
+// Configuration groups are held by the commit manager, and expanded into a
+// session's candidate as the user validating or committing it (see
+// session/config_groups.go).  As groups change configuration on many
+// nodes, defining or deleting one is limited to superusers and configd;
+// applying one requires permission to update the path it is applied to.
+
+func (d *Disp) authConfigGroupDefinition() error {
+	if !d.ctx.Superuser && !d.ctx.Configd {
+		return mgmterror.NewAccessDeniedApplicationError()
+	}
+	return nil
+}
+
+func newEmptyConfigGroupNameError() error {
+	err := mgmterror.NewInvalidValueApplicationError()
+	err.Message = "Configuration group name must not be empty"
+	return err
+}
+
+// SetConfigGroup creates, or replaces, the named group, which sets the
+// JSON encoded list of paths relative to wherever it is applied.
+func (d *Disp) SetConfigGroup(name, paths string) (bool, error) {
+	if err := d.authConfigGroupDefinition(); err != nil {
+		return false, err
+	}
+	if name == "" {
+		return false, newEmptyConfigGroupNameError()
+	}
+	var rels []string
+	if err := json.Unmarshal([]byte(paths), &rels); err != nil {
+		merr := mgmterror.NewInvalidValueApplicationError()
+		merr.Message = fmt.Sprintf("Invalid configuration group: %s", err)
+		return false, merr
+	}
+	if err := d.cmgr.SetConfigGroup(d.ctx.Config.Runfile, name,
+		rels); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (d *Disp) DeleteConfigGroup(name string) (bool, error) {
+	if err := d.authConfigGroupDefinition(); err != nil {
+		return false, err
+	}
+	found, err := d.cmgr.DeleteConfigGroup(d.ctx.Config.Runfile, name)
+	if err != nil {
+		return false, err
+	}
+	if !found {
+		err := mgmterror.NewInvalidValueApplicationError()
+		err.Message = fmt.Sprintf("No configuration group %s", name)
+		return false, err
+	}
+	return true, nil
+}
+
+// ApplyConfigGroup applies the named group to path, in which a "*" element
+// matches every list entry.
+func (d *Disp) ApplyConfigGroup(path, name string) (bool, error) {
+	ps := pathutil.Makepath(path)
+	if !d.ctx.Superuser && !d.authPath(ps, auth.P_UPDATE) {
+		return false, mgmterror.NewAccessDeniedApplicationError()
+	}
+	if err := d.cmgr.ApplyConfigGroup(d.ctx.Config.Runfile, ps,
+		name); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (d *Disp) UnapplyConfigGroup(path, name string) (bool, error) {
+	ps := pathutil.Makepath(path)
+	if !d.ctx.Superuser && !d.authPath(ps, auth.P_UPDATE) {
+		return false, mgmterror.NewAccessDeniedApplicationError()
+	}
+	found, err := d.cmgr.UnapplyConfigGroup(d.ctx.Config.Runfile, ps, name)
+	if err != nil {
+		return false, err
+	}
+	if !found {
+		err := mgmterror.NewInvalidValueApplicationError()
+		err.Path = pathutil.Pathstr(ps)
+		err.Message = fmt.Sprintf("Configuration group %s is not applied",
+			name)
+		return false, err
+	}
+	return true, nil
+}
+
+// GetConfigGroups returns the paths set by each configuration group.
+func (d *Disp) GetConfigGroups() (map[string][]string, error) {
+	groups, _ := d.cmgr.ConfigGroups()
+	return groups, nil
+}
+
+// GetConfigGroupApplications returns the configuration groups applied at
+// each path the caller may read.
+func (d *Disp) GetConfigGroupApplications() (map[string][]string, error) {
+	_, applied := d.cmgr.ConfigGroups()
+	for pstr := range applied {
+		if !d.authRead(pathutil.Makepath(pstr)) {
+			delete(applied, pstr)
+		}
+	}
+	return applied, nil
+}
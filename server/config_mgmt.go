@@ -15,7 +15,6 @@ import (
 
 	"github.com/danos/configd/rpc"
 	"github.com/danos/mgmterror"
-	"github.com/danos/utils/pathutil"
 	spawn "os/exec"
 )
 
@@ -24,7 +23,7 @@ const (
 )
 
 // Globals which can be manipulated by UTs (see config_mgmt_internal_test.go)
-var configDir = "/config"
+var configDir = defaultConfigDir
 var tmpDir = "/var/tmp/configd"
 var callerCmdSetPrivs = true
 
@@ -154,7 +153,7 @@ func (d *Disp) parseLocalPath(path string) string {
 }
 
 func (d *Disp) writeRunningConfigToFile(file *os.File) error {
-	cfg, err := d.show(rpc.RUNNING, "", pathutil.Makepath(""), false, false)
+	cfg, err := d.showRunningForStorage()
 	if err != nil {
 		return err
 	}
@@ -282,9 +281,10 @@ func (d *Disp) LoadFrom(sid, source, routingInstance string) (bool, error) {
 		d.ctx.Wlog.Println("Load config [" + redactedSource + "] by " + d.ctx.User)
 	}
 
-	return d.accountCmdWrapBoolErr(args, func() (interface{}, error) {
-		return d.loadFromInternal(sid, source, routingInstance, local)
-	})
+	return d.accountCmdWrapBoolErr(args, d.audited(AuditLoad, sid,
+		rpc.CANDIDATE, func() (interface{}, error) {
+			return d.loadFromInternal(sid, source, routingInstance, local)
+		}))
 }
 
 func (d *Disp) saveToInternal(dest, routingInstance string, local bool) (bool, error) {
@@ -22,3 +22,19 @@ func SetTmpDir(dir string) {
 func GetProductionTmpDir() string {
 	return productionTmpDir
 }
+
+func SetConfirmedCommitJobFile(file string) {
+	confirmedCommitJobFile = file
+}
+
+func SetCommitExpiryJobFile(file string) {
+	commitExpiryJobFile = file
+}
+
+func SetConfigDir(dir string) {
+	setConfigDir(dir)
+}
+
+func ResetConfigDir() {
+	setConfigDir(defaultConfigDir)
+}
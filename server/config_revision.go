@@ -0,0 +1,104 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// configTopLevelNodes returns the names of the top-level nodes in a config
+// file, without parsing the whole file.  It only needs to track nesting of
+// braces, skipping over quoted strings and comments, so it is cheap enough
+// for use during completion.
+func configTopLevelNodes(r io.Reader) ([]string, error) {
+	seen := make(map[string]struct{})
+	depth := 0
+	inComment := false
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if depth == 0 && !inComment {
+			fields := strings.Fields(line)
+			if len(fields) > 0 &&
+				!strings.HasPrefix(fields[0], "/*") &&
+				!strings.HasPrefix(fields[0], "}") {
+				seen[strings.TrimSuffix(fields[0], "{")] = struct{}{}
+			}
+		}
+
+		inQuote := false
+		for i := 0; i < len(line); i++ {
+			switch {
+			case inComment:
+				if strings.HasPrefix(line[i:], "*/") {
+					inComment = false
+					i++
+				}
+			case inQuote:
+				if line[i] == '\\' {
+					i++
+				} else if line[i] == '"' {
+					inQuote = false
+				}
+			case line[i] == '"':
+				inQuote = true
+			case strings.HasPrefix(line[i:], "/*"):
+				inComment = true
+				i++
+			case line[i] == '{':
+				depth++
+			case line[i] == '}':
+				depth--
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	nodes := make([]string, 0, len(seen))
+	for name := range seen {
+		nodes = append(nodes, name)
+	}
+	sort.Strings(nodes)
+	return nodes, nil
+}
+
+// GetConfigRevisionNodes returns the top-level nodes of an archived
+// configuration revision that the caller may read, allowing the CLI to
+// show what a revision contains when completing eg rollback.
+func (d *Disp) GetConfigRevisionNodes(revision string) ([]string, error) {
+	if revision == "session" || !d.validCompareConfigRevision(revision) {
+		return []string{}, newInvalidConfigRevisionError(revision)
+	}
+
+	f, err := os.Open(configRevisionFileName(revision))
+	if err != nil {
+		return []string{}, err
+	}
+	defer f.Close()
+	r, err := d.cfgFileReader(f)
+	if err != nil {
+		return []string{}, err
+	}
+	all, err := configTopLevelNodes(r)
+	if err != nil {
+		return []string{}, err
+	}
+
+	nodes := make([]string, 0, len(all))
+	for _, name := range all {
+		if d.authRead([]string{name}) {
+			nodes = append(nodes, name)
+		}
+	}
+	return nodes, nil
+}
@@ -0,0 +1,41 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConfigTopLevelNodes(t *testing.T) {
+	const config = `interfaces {
+	dataplane dp0s3 {
+		description "a } brace"
+		address 10.0.0.1/24
+	}
+}
+/* a comment {
+   spanning lines */
+protocols {
+	static {
+	}
+}
+system {
+	host-name vyatta
+}
+zone-policy
+/* Warning: Do not remove the following line. */
+/* === vyatta-config-version: "system@1" === */
+`
+	nodes, err := configTopLevelNodes(strings.NewReader(config))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	exp := []string{"interfaces", "protocols", "system", "zone-policy"}
+	if !reflect.DeepEqual(nodes, exp) {
+		t.Fatalf("Unexpected nodes.\nExp: %v\nGot: %v", exp, nodes)
+	}
+}
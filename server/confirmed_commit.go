@@ -5,11 +5,16 @@
 package server
 
 import (
-	"encoding/json"
+	"compress/gzip"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"strconv"
+	"time"
 
+	"github.com/danos/config/auth"
+	"github.com/danos/configd"
+	"github.com/danos/configd/session"
 	"github.com/danos/mgmterror"
 )
 
@@ -17,25 +22,24 @@ const (
 	DefaultTimeout = 600
 )
 
-type ConfirmedCommitInfo struct {
-	Session   string `json:"session"`
-	PersistId string `json:"persist-id"`
-}
-
-func getConfirmedCommitInfo() *ConfirmedCommitInfo {
-	info := &ConfirmedCommitInfo{}
+// Confirmed commits are tracked by the commit manager (see
+// session/confirmed_commit.go) in the job file.  Before the first of a
+// series of confirmed commits, the running configuration is saved to the
+// revert file, which a timed out or cancelled confirmed commit is rolled
+// back to.
+var confirmedCommitJobFile = defaultConfigDir + "/confirmed_commit.job"
+var confirmedCommitRevertFile = defaultConfigDir + "/confirmed_commit.revert"
 
-	fl, err := os.Open("/config/confirmed_commit.job")
-	if err != nil {
-		// Ignore errors, likely no pending
-		// confirmed commit
-		return info
-	}
-	defer fl.Close()
-	dec := json.NewDecoder(fl)
-	dec.Decode(info)
+func (d *Disp) confirmedCommitInfo() session.ConfirmedCommitInfo {
+	return d.cmgr.ConfirmedCommit(confirmedCommitJobFile)
+}
 
-	return info
+// ownsConfirmedCommit returns true if this session may confirm, extend or
+// cancel the pending confirmed commit without giving its persist-id.  A
+// CLI commit-confirm belongs to no session, so any session allowed the
+// command may act on it.
+func (d *Disp) ownsConfirmedCommit(info session.ConfirmedCommitInfo) bool {
+	return info.Session == "" || info.Session == strconv.Itoa(int(d.ctx.Pid))
 }
 
 type commitInfo struct {
@@ -71,24 +75,6 @@ func newCommitInfo(confirmed bool, timeout, persist, persistid string) (*commitI
 	return cmt, nil
 }
 
-func (c *commitInfo) arguments(session string) []string {
-	args := make([]string, 0)
-	args = append(args, "--action=confirmed-commit")
-	args = append(args, fmt.Sprintf("--seconds=%d", c.timeout))
-	if c.persist != "" {
-		args = append(args, fmt.Sprintf("--persist=%s", c.persist))
-	}
-	if c.persistId != "" {
-		args = append(args, fmt.Sprintf("--persistid=%s", c.persistId))
-	}
-
-	//if c.minutes != 0 {
-	//	args = append(args, fmt.Sprintf("--minutes=%d", c.minutes))
-	//}
-	args = append(args, fmt.Sprintf("--session=%s", session))
-	return args
-}
-
 // performConfirmingCommitIfRequired checks if a confirmed commit is
 // pending and confirms it if appropriate.
 // True will be returned if a pending confirmed commit is confirmed,
@@ -96,14 +82,19 @@ func (c *commitInfo) arguments(session string) []string {
 // An error will be returned if the pending confirmed commit can not be
 // confirmed, such as if the persist-id does not match.
 func (d *Disp) performConfirmingCommitIfRequired(pid string, cmt *commitInfo, revert bool) (bool, error) {
-	info := getConfirmedCommitInfo()
+	info := d.confirmedCommitInfo()
 
-	if info.Session != "" {
+	if info.Pending() {
 		// There is an outstanding confirmed-commit
 		switch {
 		case revert == true:
 			d.ConfirmingCommit()
 			return true, nil
+		case cmt == nil && info.Session == "":
+			// CLI commit confirming a CLI commit-confirm
+			d.logConfirmedCommitEvent("Confirming pending commit-confirm")
+			d.ConfirmingCommit()
+			return true, nil
 		case cmt == nil:
 			// CLI commit, can't proceed if ongoing confirmed commit
 			err := mgmterror.NewAccessDeniedApplicationError()
@@ -115,7 +106,7 @@ func (d *Disp) performConfirmingCommitIfRequired(pid string, cmt *commitInfo, re
 			err.Message = "persist-id does not match outstanding confirmed commit"
 			return false, err
 
-		case cmt.persistId == "" && info.Session != pid:
+		case cmt.persistId == "" && info.Session != "" && info.Session != pid:
 			// Only consider the session identifier if there given persist-id
 			err := mgmterror.NewAccessDeniedApplicationError()
 			err.Message = "operation blocked by outstanding confirmed commit"
@@ -134,3 +125,171 @@ func (d *Disp) performConfirmingCommitIfRequired(pid string, cmt *commitInfo, re
 
 	return false, nil
 }
+
+func (d *Disp) extendConfirmTimeoutInternal(mins int) (string, error) {
+	if mins <= 0 {
+		err := mgmterror.NewInvalidValueProtocolError()
+		err.Message = "timeout extension must be a positive number of minutes"
+		return "", err
+	}
+
+	info := d.confirmedCommitInfo()
+	switch {
+	case !info.Pending():
+		err := mgmterror.NewOperationFailedApplicationError()
+		err.Message = "No confirmed commit pending"
+		return "", err
+	case !d.ownsConfirmedCommit(info) && !d.ctx.Superuser:
+		err := mgmterror.NewAccessDeniedApplicationError()
+		err.Message = "Pending confirmed commit initiated by another session"
+		return "", err
+	}
+
+	info, err := d.cmgr.ExtendConfirmedCommit(confirmedCommitJobFile,
+		time.Duration(mins)*time.Minute)
+	if err != nil {
+		return "", err
+	}
+	d.logConfirmedCommitEvent(fmt.Sprintf(
+		"Timeout for persist-id [%s] extended by %d minutes by %s",
+		info.PersistId, mins, d.ctx.User))
+	return confirmTimeoutMessage(info), nil
+}
+
+func confirmTimeoutMessage(info session.ConfirmedCommitInfo) string {
+	return fmt.Sprintf("Configuration will be reverted at %s unless confirmed",
+		info.Deadline.Format("15:04:05"))
+}
+
+// saveConfirmedCommitRevert saves the running configuration, to be reverted
+// to if the confirmed commit about to be made isn't confirmed.  Follow-up
+// confirmed commits revert to the configuration before the first.
+func (d *Disp) saveConfirmedCommitRevert() error {
+	if d.confirmedCommitInfo().Pending() {
+		return nil
+	}
+	if !d.ctx.Configd {
+		d.ctx.RaisePrivileges()
+		defer d.ctx.DropPrivileges()
+	}
+	f, err := os.OpenFile(confirmedCommitRevertFile,
+		os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	err = d.writeRunningConfigToFile(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// vyatta-config-mgmt.pl, which tracked confirmed commits before configd
+// did, reverted them to this archive revision, the configuration before
+// the confirmed commit, rather than to the revert file.
+const legacyConfirmedCommitRevision = "1"
+
+// migrateConfirmedCommitRevert saves the configuration a confirmed commit
+// left pending by vyatta-config-mgmt.pl, whose job file has no deadline,
+// reverts to in the revert file, so it is still reverted correctly once
+// configd has been upgraded.
+func migrateConfirmedCommitRevert(info session.ConfirmedCommitInfo) error {
+	if !info.Pending() || !info.Deadline.IsZero() {
+		return nil
+	}
+	if _, err := os.Stat(confirmedCommitRevertFile); err == nil {
+		return nil
+	}
+	f, err := os.Open(configRevisionFileName(legacyConfirmedCommitRevision))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	cfg, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(confirmedCommitRevertFile, cfg, 0600)
+}
+
+func (d *Disp) startConfirmedCommit(
+	info session.ConfirmedCommitInfo,
+	timeout time.Duration,
+) (string, error) {
+	info, err := d.cmgr.StartConfirmedCommit(confirmedCommitJobFile, info,
+		timeout)
+	if err != nil {
+		return "", err
+	}
+	return confirmTimeoutMessage(info), nil
+}
+
+// revertConfirmedCommit rolls back to the configuration saved before the
+// pending confirmed commit was made.
+func (d *Disp) revertConfirmedCommit(comment string) error {
+	sid := "confirmed-commit-revert"
+	if _, err := d.SessionSetup(sid); err != nil {
+		return err
+	}
+	defer d.SessionTeardown(sid)
+	_, err := d.rollbackInternal(sid, "revert", comment, false)
+	return err
+}
+
+// revertConfirmedCommit is called by the commit manager once a confirmed
+// commit has timed out.
+func (s *Srv) revertConfirmedCommit(info session.ConfirmedCommitInfo) {
+	d := s.newDisp(s.uid, int32(configd.SYSTEM), &auth.AuthEnv{}, s.CompMgr)
+	d.logConfirmedCommitEvent("Timed out, reverting persist-id [" +
+		info.PersistId + "]")
+	if err := d.revertConfirmedCommit("Confirmed commit timed out"); err != nil {
+		s.LogError(err)
+	}
+}
+
+// ExtendConfirmTimeout pushes back the automatic revert of a pending
+// confirmed commit, giving the operator longer to verify the change.  Only
+// the session that initiated the confirmed commit, any session for a CLI
+// commit-confirm, or a member of the supergroup, may do this.
+func (d *Disp) ExtendConfirmTimeout(mins int) (string, error) {
+	args := d.newCommandArgsForAaa(
+		"extend-confirm", []string{strconv.Itoa(mins)}, nil)
+	if !d.authCommand(args) {
+		return "", mgmterror.NewAccessDeniedApplicationError()
+	}
+
+	return d.accountCmdWrapStrErr(args, func() (interface{}, error) {
+		return d.extendConfirmTimeoutInternal(mins)
+	})
+}
+
+// ConfirmedCommitStatus returns the pending confirmed commit, if any: the
+// session that started it, left out for a CLI commit-confirm, when it will
+// be reverted and the seconds left until then.  The persist-id, which is enough to confirm or cancel the
+// commit, is only returned to the session that started it, or to a member
+// of the supergroup.  If no confirmed commit is pending the map is empty.
+func (d *Disp) ConfirmedCommitStatus(sid string) (map[string]string, error) {
+	status := make(map[string]string)
+	info := d.confirmedCommitInfo()
+	if !info.Pending() {
+		return status, nil
+	}
+
+	remaining := int64(time.Until(info.Deadline).Seconds())
+	if remaining < 0 {
+		remaining = 0
+	}
+	if info.Session != "" {
+		status["session"] = info.Session
+	}
+	status["deadline"] = info.Deadline.Format(time.RFC3339)
+	status["remaining-seconds"] = strconv.FormatInt(remaining, 10)
+	if d.ownsConfirmedCommit(info) || d.ctx.Superuser {
+		status["persist-id"] = info.PersistId
+	}
+	return status, nil
+}
@@ -0,0 +1,206 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danos/config/auth"
+	"github.com/danos/configd/server"
+)
+
+const confirmSchema = `
+container testcontainer {
+	leaf testleaf {
+		type string;
+	}
+}`
+
+func setupConfirmedCommitJob(t *testing.T, job string) func() {
+	dir, err := ioutil.TempDir("", "configd-confirm")
+	if err != nil {
+		t.Fatalf("Unable to create job dir: %s", err)
+	}
+	file := filepath.Join(dir, "confirmed_commit.job")
+	if job != "" {
+		if err := ioutil.WriteFile(file, []byte(job), 0644); err != nil {
+			t.Fatalf("Unable to write job file: %s", err)
+		}
+	}
+	server.SetConfirmedCommitJobFile(file)
+	return func() {
+		server.SetConfirmedCommitJobFile("/config/confirmed_commit.job")
+		os.RemoveAll(dir)
+	}
+}
+
+func checkExtendConfirmTimeoutFails(
+	t *testing.T,
+	d *server.Disp,
+	mins int,
+	expErr string,
+) {
+	t.Helper()
+	_, err := d.ExtendConfirmTimeout(mins)
+	if err == nil {
+		t.Fatalf("Unexpected success extending confirm timeout")
+	}
+	if !strings.Contains(err.Error(), expErr) {
+		t.Fatalf("Failed to get expected error.\nExp: %s\nGot: %s\n",
+			expErr, err.Error())
+	}
+}
+
+func TestExtendConfirmTimeoutNothingPending(t *testing.T) {
+	defer setupConfirmedCommitJob(t, "")()
+	d := newTestDispatcher(t, auth.TestAutherAllowAll(), confirmSchema,
+		emptyconfig)
+
+	checkExtendConfirmTimeoutFails(t, d, 10, "No confirmed commit pending")
+}
+
+func TestExtendConfirmTimeoutOtherSession(t *testing.T) {
+	defer setupConfirmedCommitJob(t,
+		`{"session":"1234","persist-id":""}`)()
+	d := newTestDispatcher(t, auth.TestAutherAllowAll(), confirmSchema,
+		emptyconfig)
+
+	checkExtendConfirmTimeoutFails(t, d, 10,
+		"Pending confirmed commit initiated by another session")
+}
+
+func TestExtendConfirmTimeoutInvalidMinutes(t *testing.T) {
+	defer setupConfirmedCommitJob(t, "")()
+	d := newTestDispatcher(t, auth.TestAutherAllowAll(), confirmSchema,
+		emptyconfig)
+
+	checkExtendConfirmTimeoutFails(t, d, 0, "positive number of minutes")
+}
+
+func TestExtendConfirmTimeoutCommandAuthz(t *testing.T) {
+	defer setupConfirmedCommitJob(t, "")()
+	a := auth.TestAutherAllowAll()
+	d := newTestDispatcherWithCustomAuth(
+		t, a, confirmSchema, emptyconfig,
+		false, /* not configd user, so our auther gets used! */
+		false /* not in secrets group */)
+
+	_, _ = d.ExtendConfirmTimeout(5)
+
+	assertCommandAaaNoSecrets(t, a, []string{"extend-confirm", "5"})
+}
+
+func TestConfirmedCommitStatusNothingPending(t *testing.T) {
+	defer setupConfirmedCommitJob(t, "")()
+	d := newTestDispatcher(t, auth.TestAutherAllowAll(), confirmSchema,
+		emptyconfig)
+
+	status, err := d.ConfirmedCommitStatus(testSID)
+	if err != nil {
+		t.Fatalf("Unexpected error getting status: %s", err)
+	}
+	if len(status) != 0 {
+		t.Fatalf("Unexpected status with nothing pending: %v", status)
+	}
+}
+
+func TestConfirmedCommitStatusOtherSession(t *testing.T) {
+	deadline := time.Now().Add(10 * time.Minute).Format(time.RFC3339)
+	defer setupConfirmedCommitJob(t,
+		`{"session":"1234","persist-id":"secret","deadline":"`+
+			deadline+`"}`)()
+	d := newTestDispatcher(t, auth.TestAutherAllowAll(), confirmSchema,
+		emptyconfig)
+
+	status, err := d.ConfirmedCommitStatus(testSID)
+	if err != nil {
+		t.Fatalf("Unexpected error getting status: %s", err)
+	}
+	if status["session"] != "1234" || status["deadline"] != deadline {
+		t.Fatalf("Unexpected status: %v", status)
+	}
+	remaining, err := strconv.Atoi(status["remaining-seconds"])
+	if err != nil || remaining <= 0 || remaining > 600 {
+		t.Fatalf("Unexpected remaining seconds: %s",
+			status["remaining-seconds"])
+	}
+	if _, ok := status["persist-id"]; ok {
+		t.Fatalf("persist-id of another session's commit returned")
+	}
+}
+
+// A CLI commit-confirm belongs to no session, so can be extended from any.
+func TestExtendConfirmTimeoutCLICommitConfirm(t *testing.T) {
+	deadline := time.Now().Add(10 * time.Minute).Truncate(time.Second)
+	defer setupConfirmedCommitJob(t,
+		`{"session":"","persist-id":"","deadline":"`+
+			deadline.Format(time.RFC3339)+`"}`)()
+	d := newTestDispatcher(t, auth.TestAutherAllowAll(), confirmSchema,
+		emptyconfig)
+
+	if _, err := d.ExtendConfirmTimeout(5); err != nil {
+		t.Fatalf("Unable to extend CLI commit-confirm: %s", err)
+	}
+	defer d.Confirm(testSID)
+
+	status, err := d.ConfirmedCommitStatus(testSID)
+	if err != nil {
+		t.Fatalf("Unexpected error getting status: %s", err)
+	}
+	exp := deadline.Add(5 * time.Minute).Format(time.RFC3339)
+	if status["deadline"] != exp {
+		t.Fatalf("Unexpected deadline.\nExp: %s\nGot: %s", exp,
+			status["deadline"])
+	}
+	if _, ok := status["session"]; ok {
+		t.Fatalf("Session returned for CLI commit-confirm: %v", status)
+	}
+}
+
+func TestCommitConfirmThenExtend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "configd-confirm")
+	if err != nil {
+		t.Fatalf("Unable to create config dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	server.SetConfigDir(dir)
+	defer server.ResetConfigDir()
+	d := newTestDispatcher(t, auth.TestAutherAllowAll(), confirmSchema,
+		emptyconfig)
+
+	dispTestSetupSession(t, d, testSID)
+	dispTestSet(t, d, testSID, "testcontainer/testleaf/foo")
+	if _, err := d.CommitConfirm(testSID, "", false, 5); err != nil {
+		t.Fatalf("Unable to commit-confirm: %s", err)
+	}
+	defer d.Confirm(testSID)
+
+	before, _ := d.ConfirmedCommitStatus(testSID)
+	if _, ok := before["session"]; ok {
+		t.Fatalf("Session returned for CLI commit-confirm: %v", before)
+	}
+	if _, err := d.ExtendConfirmTimeout(5); err != nil {
+		t.Fatalf("Unable to extend commit-confirm: %s", err)
+	}
+	after, _ := d.ConfirmedCommitStatus(testSID)
+
+	b, err := time.Parse(time.RFC3339, before["deadline"])
+	if err != nil {
+		t.Fatalf("Unexpected deadline before extending: %v", before)
+	}
+	a, err := time.Parse(time.RFC3339, after["deadline"])
+	if err != nil {
+		t.Fatalf("Unexpected deadline after extending: %v", after)
+	}
+	if a.Sub(b) != 5*time.Minute {
+		t.Fatalf("Deadline moved by %s, not 5m", a.Sub(b))
+	}
+}
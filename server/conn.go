@@ -10,7 +10,6 @@ package server
 import (
 	"encoding/json"
 	"fmt"
-	"io"
 	"net"
 	"os"
 	"os/user"
@@ -117,11 +116,12 @@ func getLoginUid(pid int32) (uint32, error) {
 	return u, nil
 }
 
-//Grab the credentials off of the unix socet using SO_PEERCRED and store them int the SrvConn
-func (conn *SrvConn) getCreds() (*syscall.Ucred, error) {
+//Grab the credentials off of the unix socet using SO_PEERCRED and store them int the SrvConn.
+//The returned credentials are those of the peer, uid is the verified user it acts for.
+func (conn *SrvConn) getCreds() (*syscall.Ucred, uint32, error) {
 	uf, err := conn.File()
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	cred, err := syscall.GetsockoptUcred(
 		int(uf.Fd()),
@@ -129,47 +129,39 @@ func (conn *SrvConn) getCreds() (*syscall.Ucred, error) {
 		syscall.SO_PEERCRED)
 	if err != nil {
 		conn.srv.LogError(err)
-		return nil, err
+		return nil, 0, err
 	}
 	uf.Close()
 
-	cred.Uid, err = getLoginUid(cred.Pid)
+	loginUid, err := getLoginUid(cred.Pid)
+	uid, err := conn.srv.verifyPeerIdentity(cred, loginUid, err)
 
-	return cred, err
+	return cred, uid, err
 }
 
-// Handle is the main loop for a connection. It receives the requests,  authorizes
-// the request, calls the request method and returns the response to the client.
-func (conn *SrvConn) Handle(compMgr schema.ComponentManager) {
-
-	var err error
-
-	conn.cred, err = conn.getCreds()
-	if err != nil {
-		if !IsLoginPidError(err) {
-			if !os.IsNotExist(err) {
-				conn.srv.LogError(err)
-			}
-			conn.Close()
-			return
-		}
-	}
-
+// newDisp returns a dispatcher acting for the user with the given uid.
+func (s *Srv) newDisp(
+	uid uint32,
+	pid int32,
+	authEnv *auth.AuthEnv,
+	compMgr schema.ComponentManager,
+) *Disp {
+	ms, msFull, _ := s.schemas()
 	disp := &Disp{
-		smgr:   conn.srv.smgr,
-		cmgr:   conn.srv.cmgr,
-		ms:     conn.srv.ms,
-		msFull: conn.srv.msFull,
+		smgr:   s.smgr,
+		cmgr:   s.cmgr,
+		ms:     ms,
+		msFull: msFull,
 		ctx: &configd.Context{
-			Configd:   conn.cred.Uid == conn.srv.uid,
-			Uid:       conn.cred.Uid,
-			Pid:       conn.cred.Pid,
+			Configd:   uid == s.uid,
+			Uid:       uid,
+			Pid:       pid,
 			Groups:    make([]string, 0),
-			Superuser: conn.cred.Uid == 0,
-			Config:    conn.srv.Config,
-			Elog:      conn.srv.Elog,
-			Dlog:      conn.srv.Dlog,
-			Wlog:      conn.srv.Wlog,
+			Superuser: uid == 0,
+			Config:    s.Config,
+			Elog:      s.Elog,
+			Dlog:      s.Dlog,
+			Wlog:      s.Wlog,
 			CompMgr:   compMgr,
 		},
 	}
@@ -178,52 +170,96 @@ func (conn *SrvConn) Handle(compMgr schema.ComponentManager) {
 	//groups are not needed for commit spawned processes
 	//if the uid is the same as configd auth allows it implicitly
 	//don't include groups for these users
-	if conn.cred.Uid != conn.srv.uid {
-		groups, err := group.LookupUid(strconv.Itoa(int(disp.ctx.Uid)))
-		conn.srv.LogError(err)
-		haveSuperGroup := conn.srv.Config.SuperGroup != ""
+	if uid != s.uid {
+		groups, err := group.LookupUid(strconv.Itoa(int(uid)))
+		s.LogError(err)
+		haveSuperGroup := s.Config.SuperGroup != ""
 		for _, gr := range groups {
 			disp.ctx.Groups = append(disp.ctx.Groups, gr.Name)
-			if haveSuperGroup && gr.Name == conn.srv.Config.SuperGroup {
+			if haveSuperGroup && gr.Name == s.Config.SuperGroup {
 				disp.ctx.Superuser = true
 			}
 		}
 	}
 
+	disp.ctx.Auth = auth.NewAuthForUser(s.authGlobal, uid, disp.ctx.Groups, authEnv)
+	return disp
+}
+
+// Handle is the main loop for a connection. It receives the requests,  authorizes
+// the request, calls the request method and returns the response to the client.
+func (conn *SrvConn) Handle(compMgr schema.ComponentManager) {
+
+	var err error
+	var uid uint32
+
+	conn.cred, uid, err = conn.getCreds()
+	if err != nil {
+		if !os.IsNotExist(err) {
+			conn.srv.LogError(err)
+		}
+		conn.Close()
+		return
+	}
+
 	ttyName, err := tty.TtyNameForPid(int(conn.cred.Pid))
 	if err != nil && !os.IsNotExist(err) {
 		conn.srv.LogError(err)
 	}
 
-	authEnv := &auth.AuthEnv{Tty: ttyName}
-	disp.ctx.Auth = auth.NewAuthForUser(conn.srv.authGlobal, disp.ctx.Uid, disp.ctx.Groups, authEnv)
+	disp := conn.srv.newDisp(uid, conn.cred.Pid,
+		&auth.AuthEnv{Tty: ttyName}, compMgr)
+	disp.ctx.PeerUid = conn.cred.Uid
+	disp.ctx.PeerGid = conn.cred.Gid
 
-	u, err := user.LookupId(strconv.Itoa(int(disp.ctx.Uid)))
+	uidStr := strconv.Itoa(int(disp.ctx.Uid))
+	u, err := user.LookupId(uidStr)
 	if err != nil {
-		conn.srv.LogError(err)
-		conn.Close()
-		return
+		if !conn.srv.Config.Container {
+			conn.srv.LogError(err)
+			conn.Close()
+			return
+		}
+		// Without a user database we only know the client's uid.
+		u = &user.User{Uid: uidStr, Username: uidStr, HomeDir: "/"}
 	}
 	disp.ctx.User = u.Username
 	disp.ctx.UserHome = u.HomeDir
+	if !disp.ctx.Configd && !disp.ctx.Superuser {
+		disp.ctx.Auth = newNacmAuth(disp.ctx.Auth, u.Username, disp.msFull)
+	}
+
+	if l := conn.srv.limits; l != nil {
+		if err := l.startConn(disp.ctx.Uid); err != nil {
+			conn.srv.LogError(fmt.Errorf(
+				"Refused connection from %s: %s", u.Username, err))
+			conn.refuse(err)
+			return
+		}
+		defer l.endConn(disp.ctx.Uid)
+	}
 
 	//Unlock all sessions this connection may have locked on return
 	defer conn.srv.smgr.UnlockAllPid(disp.ctx)
-	for {
-		req, err := conn.readRequest()
-		if err != nil {
-			if err != io.EOF {
-				conn.srv.LogError(err)
-			}
-			break
+	pipe := newPipeline(concurrentReads(conn.srv.Config.ConcurrentReads))
+	inflight := newInflightRequests()
+	for req := range conn.readRequests(inflight) {
+		if pipe.concurrent(disp, req.Method) {
+			pipe.start(conn, disp, req, inflight)
+			continue
 		}
-
+		pipe.wait()
+		disp.ctx.Request = inflight.begin(req.Id)
 		result, err := conn.Call(disp, req.Method, req.Args)
+		inflight.end(req.Id)
+		disp.ctx.Request = nil
 		err = conn.sendResponse(newResponse(result, err, req.Id))
 		if err != nil {
 			break
 		}
 	}
+	inflight.cancelAll()
+	pipe.wait()
 	if err = disp.sessionTermination(); err != nil {
 		conn.srv.LogError(err)
 	}
@@ -246,17 +282,30 @@ func (conn *SrvConn) Call(
 		return nil, mgmterror.NewAccessDeniedApplicationError()
 	}
 
+	if l := conn.srv.limits; l != nil {
+		if err := l.startRequest(disp.ctx.Uid); err != nil {
+			return nil, err
+		}
+		defer l.endRequest(disp.ctx.Uid)
+	}
+
 	typ := m.Func.Type()
+	nargs := typ.NumIn() - 1
 
 	//Number of args are equal?
-	if len(args) != typ.NumIn()-1 {
-		return nil, &rpc.ArgNErr{Method: method, Len: len(args), Elen: typ.NumIn() - 1}
+	//Older clients may leave out arguments added since (see rpc.go)
+	if len(args) > nargs ||
+		len(args) < nargs && disp.getProtocolVersion() > rpc.ProtocolVersion1 {
+		return nil, &rpc.ArgNErr{Method: method, Len: len(args), Elen: nargs}
 	}
 
 	//validate arguments
 	//prepending the first argument *Disp
-	vals := make([]reflect.Value, len(args)+1)
+	vals := make([]reflect.Value, nargs+1)
 	vals[0] = reflect.ValueOf(disp)
+	for i := len(args); i < nargs; i++ {
+		vals[i+1] = reflect.Zero(typ.In(i + 1))
+	}
 	for i, v := range args {
 		t1 := reflect.TypeOf(v)
 		t2 := typ.In(i + 1)
@@ -270,7 +319,11 @@ func (conn *SrvConn) Call(
 		}
 	}
 
+	//pick up any schema reloaded since the last call
+	disp.ms, disp.msFull, disp.ctx.CompMgr = conn.srv.schemas()
+
 	//call the function
+	defer trackMemory(method)()
 	rets := m.Func.Call(vals)
 	err, ok := rets[1].Interface().(error)
 	if ok {
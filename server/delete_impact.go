@@ -0,0 +1,93 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/danos/configd/common"
+	"github.com/danos/mgmterror"
+	"github.com/danos/utils/pathutil"
+)
+
+// Delete impact
+//
+// Deleting a shared object, such as an address group, can break every
+// leafref, when and must that refers to it, and a mandatory node deleted
+// from a list entry breaks that entry.  None of this shows up until the
+// next commit fails.  DeleteImpact lets operators see it beforehand: the
+// candidate is copied into a scratch session, validated, the subtree
+// deleted and validated again.  Only the errors that the deletion causes
+// are reported, so the candidate needn't already be valid.  The caller's
+// candidate is left untouched.
+
+// newValidationErrors returns the errors in after that aren't in before.
+func newValidationErrors(before, after []error) []string {
+	seen := make(map[string]bool, len(before))
+	for _, err := range before {
+		seen[err.Error()] = true
+	}
+	out := []string{}
+	for _, err := range after {
+		if msg := err.Error(); !seen[msg] {
+			seen[msg] = true
+			out = append(out, msg)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func (d *Disp) deleteImpactInternal(sid string, ps []string) ([]string, error) {
+	if !d.authDelete(ps) {
+		return nil, mgmterror.NewAccessDeniedApplicationError()
+	}
+	cand, err := d.smgr.Get(d.ctx, sid)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := cand.ShowForceSecrets(d.ctx, nil, false, false)
+	if err != nil {
+		return nil, err
+	}
+
+	sn := "DELETEIMPACT" + strconv.Itoa(int(d.ctx.Pid))
+	if _, err := d.SessionSetup(sn); err != nil {
+		return nil, err
+	}
+	defer d.SessionTeardown(sn)
+	scratch, err := d.smgr.Get(d.ctx, sn)
+	if err != nil {
+		return nil, err
+	}
+	if err, _ := scratch.Load(d.ctx, "delete-impact",
+		strings.NewReader(cfg)); err != nil {
+		return nil, err
+	}
+
+	_, before, _ := scratch.Validate(d.ctx)
+	if err := scratch.Delete(d.ctx, ps); err != nil {
+		return nil, common.FormatConfigPathErrorMultiline(err)
+	}
+	_, after, _ := scratch.Validate(d.ctx)
+	return newValidationErrors(before, after), nil
+}
+
+// DeleteImpact returns the validation errors that deleting path from the
+// candidate would cause, without deleting it.
+func (d *Disp) DeleteImpact(sid, path string) ([]string, error) {
+	ps := pathutil.Makepath(path)
+
+	args := d.newCommandArgsForAaa("delete-impact", nil, ps)
+	if !d.authCommand(args) {
+		return nil, mgmterror.NewAccessDeniedApplicationError()
+	}
+
+	return d.accountCmdWrapStrSliceErr(args, func() (interface{}, error) {
+		return d.deleteImpactInternal(sid, ps)
+	})
+}
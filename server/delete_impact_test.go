@@ -0,0 +1,71 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server_test
+
+import (
+	"testing"
+
+	"github.com/danos/config/auth"
+	"github.com/danos/configd/rpc"
+)
+
+const deleteImpactSchema = `
+container resources {
+	list group {
+		key tagnode;
+		leaf tagnode {
+			type string;
+		}
+	}
+}
+container rules {
+	list rule {
+		key tagnode;
+		leaf tagnode {
+			type string;
+		}
+		leaf group {
+			type leafref {
+				path "/resources/group/tagnode";
+			}
+		}
+	}
+}`
+
+const deleteImpactConfig = `
+resources {
+	group G1
+	group G2
+}
+rules {
+	rule R1 {
+		group G1
+	}
+}
+`
+
+func TestDeleteImpact(t *testing.T) {
+	d := newTestDispatcher(
+		t, auth.TestAutherAllowAll(), deleteImpactSchema, deleteImpactConfig)
+	dispTestSetupSession(t, d, testSID)
+
+	impact, err := d.DeleteImpact(testSID, "resources/group/G1")
+	if err != nil {
+		t.Fatalf("Unable to get delete impact: %s", err)
+	}
+	if len(impact) == 0 {
+		t.Fatalf("Deleting a referenced group should break the rule")
+	}
+	dispTestExists(t, d, rpc.CANDIDATE, testSID, "resources/group/G1", true)
+
+	impact, err = d.DeleteImpact(testSID, "resources/group/G2")
+	if err != nil {
+		t.Fatalf("Unable to get delete impact: %s", err)
+	}
+	if len(impact) != 0 {
+		t.Fatalf("Deleting an unreferenced group should break nothing: %v",
+			impact)
+	}
+}
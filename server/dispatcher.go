@@ -17,12 +17,10 @@ import (
 	"os"
 	"strconv"
 	"strings"
-
-	spawn "os/exec"
+	"time"
 
 	"github.com/danos/config/auth"
 	"github.com/danos/config/data"
-	"github.com/danos/config/diff"
 	"github.com/danos/config/load"
 	"github.com/danos/config/schema"
 	"github.com/danos/config/union"
@@ -59,9 +57,9 @@ func (d *Disp) getROSession(db rpc.DB, sid string) *session.Session {
 	var sess *session.Session
 	var err error
 	switch db {
-	case rpc.RUNNING:
+	case rpc.RUNNING, rpc.INTENDED:
 		sess, err = d.smgr.Get(d.ctx, "RUNNING")
-	case rpc.EFFECTIVE:
+	case rpc.EFFECTIVE, rpc.OPERATIONAL:
 		sess, err = d.smgr.Get(d.ctx, "EFFECTIVE")
 	case rpc.AUTO, rpc.CANDIDATE:
 		sess, err = d.smgr.Get(d.ctx, sid)
@@ -82,6 +80,11 @@ type Disp struct {
 	ms     schema.ModelSet
 	msFull schema.ModelSet
 	ctx    *configd.Context
+
+	// Negotiated by ProtocolVersion; 0 until the client does so.
+	protoVersion int
+	// Set by SetClientOrigin; empty for the CLI.
+	origin string
 }
 
 func (d *Disp) GetConfigSystemFeatures() (map[string]struct{}, error) {
@@ -91,13 +94,21 @@ func (d *Disp) GetConfigSystemFeatures() (map[string]struct{}, error) {
 		feats[common.RoutingInstanceFeature] = struct{}{}
 	}
 
-	if _, err := os.Stat("/opt/vyatta/sbin/vyatta-config-mgmt.pl"); err == nil {
-		feats[common.ConfigManagementFeature] = struct{}{}
+	feats[common.ConfigManagementFeature] = struct{}{}
+	feats[common.ConfirmedCommitFeature] = struct{}{}
+	feats[common.ExclusiveConfigFeature] = struct{}{}
+
+	if _, err := os.Stat(archiveDir()); err == nil {
+		feats[common.ArchiveFeature] = struct{}{}
 	}
 
 	if d.loadKeysIsSupported() {
 		feats[common.LoadKeysFeature] = struct{}{}
 	}
+
+	if configd.ShowSecrets(d.ctx) {
+		feats[common.SecretsAccessFeature] = struct{}{}
+	}
 	return feats, nil
 }
 
@@ -119,11 +130,20 @@ func (d *Disp) SessionSetupShared(sid string) (bool, error) {
 	_, err := d.smgr.Create(d.ctx, sid, d.cmgr, d.ms, d.msFull, session.Shared)
 	return err == nil, err
 }
+
+// SessionSetupPrivate creates a session whose candidate is isolated from
+// commits made by other sessions until it is rebased or committed.
+func (d *Disp) SessionSetupPrivate(sid string) (bool, error) {
+	_, err := d.smgr.CreatePrivate(d.ctx, sid, d.cmgr, d.ms, d.msFull)
+	return err == nil, err
+}
+
 func (d *Disp) SessionTeardown(sid string) (bool, error) {
 	err := d.smgr.Destroy(d.ctx, sid)
 	if err != nil {
 		return false, err
 	}
+	releaseExclusiveForSession(sid)
 	return true, nil
 }
 func (d *Disp) SessionChanged(sid string) (bool, error) {
@@ -134,6 +154,13 @@ func (d *Disp) SessionChanged(sid string) (bool, error) {
 	changed := sess.Changed(d.ctx)
 	return changed, nil
 }
+func (d *Disp) SessionBaseChanged(sid string) (bool, error) {
+	sess, err := d.smgr.Get(d.ctx, sid)
+	if err != nil {
+		return false, err
+	}
+	return sess.BaseChanged(d.ctx), nil
+}
 func (d *Disp) SessionSaved(sid string) (bool, error) {
 	sess, err := d.smgr.Get(d.ctx, sid)
 	if err != nil {
@@ -158,9 +185,6 @@ func (d *Disp) SessionMarkUnsaved(sid string) (bool, error) {
 	sess.MarkSaved(d.ctx, false)
 	return true, nil
 }
-func (d *Disp) SessionGetEnv(sid string) (map[string]string, error) {
-	return nil, mgmterror.NewOperationNotSupportedApplicationError()
-}
 
 func (d *Disp) SessionLock(sid string) (int32, error) {
 	sess, err := d.smgr.Get(d.ctx, sid)
@@ -215,7 +239,7 @@ func (d *Disp) TmplGet(path string) (map[string]string, error) {
 	//TODO(jhs): Remove this mess
 	m := make(map[string]string)
 
-	ps := pathutil.Makepath(path)
+	ps := common.InternPath(path)
 
 	tmpl, err := d.schemaPathDescendant(ps)
 	if err != nil {
@@ -274,7 +298,7 @@ func (d *Disp) TmplGet(path string) (map[string]string, error) {
 }
 
 func (d *Disp) TmplGetChildren(path string) ([]string, error) {
-	ps := pathutil.Makepath(path)
+	ps := common.InternPath(path)
 
 	if !d.authRead(ps) {
 		return nil, mgmterror.NewAccessDeniedApplicationError()
@@ -508,13 +532,9 @@ func (d *Disp) TmplValidateValues(path string) (bool, error) {
 	return err == nil, nil
 }
 
-func (d *Disp) EditGetEnv(sid string) (map[string]string, error) {
-	return nil, mgmterror.NewOperationNotSupportedApplicationError()
-}
-
 // NodeGet
 func (d *Disp) Get(db rpc.DB, sid string, path string) ([]string, error) {
-	ps := pathutil.Makepath(path)
+	ps := common.InternPath(path)
 
 	if !d.authRead(ps) {
 		return nil, mgmterror.NewAccessDeniedApplicationError()
@@ -536,24 +556,83 @@ func (d *Disp) Get(db rpc.DB, sid string, path string) ([]string, error) {
 	return out, nil
 }
 
+// GetCommitLog returns a brief description of each archived revision, with
+// its commit comment, by revision number.
 func (d *Disp) GetCommitLog() (map[string]string, error) {
 	comps := make(map[string]string)
-	buf, err := spawn.Command("/opt/vyatta/sbin/vyatta-config-mgmt.pl",
-		"--action=show-commit-log-brief").Output()
+	revs, err := archiveStore().List()
 	if err != nil {
+		if os.IsNotExist(err) {
+			return comps, nil
+		}
 		return comps, err
 	}
-	out := string(buf)
-	vals := strings.Split(out, " ")
-	for i, v := range vals {
-		if v == "" {
-			// Skip empty entries
+	for _, rev := range revs {
+		comps[strconv.Itoa(rev.Num)] = rev.Summary()
+	}
+	return comps, nil
+}
+
+// GetChangesSinceBoot returns the configuration paths changed by commits since
+// the system booted, condensed so that only the topmost changed path in each
+// subtree is listed, each with the ids of the commits that changed it.
+func (d *Disp) GetChangesSinceBoot() (map[string][]uint64, error) {
+	return d.cmgr.ChangesSinceBoot(), nil
+}
+
+// GetUserStats returns the number of commits, failed validations and
+// rollbacks made by each user since boot.  Only superusers may see the
+// statistics of other users.
+func (d *Disp) GetUserStats() (map[string]map[string]uint64, error) {
+	out := make(map[string]map[string]uint64)
+	for user, st := range d.cmgr.UserStats() {
+		if !d.ctx.Superuser && user != d.ctx.User {
 			continue
 		}
-		val := strings.Replace(v, "_", " ", -1)
-		comps[strconv.Itoa(i)] = val
+		out[user] = map[string]uint64{
+			"commits":            st.Commits,
+			"failed-validations": st.FailedValidations,
+			"rollbacks":          st.Rollbacks,
+		}
 	}
-	return comps, nil
+	return out, nil
+}
+
+// GetCommitUsers returns the user who made each commit since boot, keyed by
+// commit id as in GetChangesSinceBoot.
+func (d *Disp) GetCommitUsers() (map[string]string, error) {
+	out := make(map[string]string)
+	for id, user := range d.cmgr.CommitUsers() {
+		out[strconv.FormatUint(id, 10)] = user
+	}
+	return out, nil
+}
+
+func commitStatsMap(st session.CommitStats) map[string]uint64 {
+	return map[string]uint64{
+		"added":       st.Added,
+		"deleted":     st.Deleted,
+		"modified":    st.Modified,
+		"delta-bytes": st.DeltaBytes,
+		"scripts":     st.Scripts,
+	}
+}
+
+// GetCommitStats returns the paths added, deleted and modified, the size of
+// the change and the number of scripts run by each commit since boot, keyed
+// by commit id as in GetChangesSinceBoot.
+func (d *Disp) GetCommitStats() (map[string]map[string]uint64, error) {
+	out := make(map[string]map[string]uint64)
+	for id, st := range d.cmgr.CommitStats() {
+		out[strconv.FormatUint(id, 10)] = commitStatsMap(st)
+	}
+	return out, nil
+}
+
+// GetCommitStatsTotal returns the statistics of all commits since boot
+// added together, for tracking the growth of the configuration.
+func (d *Disp) GetCommitStatsTotal() (map[string]uint64, error) {
+	return commitStatsMap(d.cmgr.CommitStatsTotal()), nil
 }
 
 func (d *Disp) validatePath(ps []string) error {
@@ -584,7 +663,7 @@ func (d *Disp) getPathError(ps []string, unexpected string) error {
 // NodeExists
 func (d *Disp) Exists(db rpc.DB, sid string, path string) (bool, error) {
 
-	ps := pathutil.Makepath(path)
+	ps := common.InternPath(path)
 	if err := d.validatePath(ps); err != nil {
 		return false, common.FormatConfigPathError(err)
 	}
@@ -597,7 +676,7 @@ func (d *Disp) Exists(db rpc.DB, sid string, path string) (bool, error) {
 	return sess.Exists(d.ctx, ps), nil
 }
 func (d *Disp) NodeGetStatus(db rpc.DB, sid string, path string) (rpc.NodeStatus, error) {
-	ps := pathutil.Makepath(path)
+	ps := common.InternPath(path)
 
 	if !d.authRead(ps) {
 		return rpc.UNCHANGED, mgmterror.NewAccessDeniedApplicationError()
@@ -608,7 +687,7 @@ func (d *Disp) NodeGetStatus(db rpc.DB, sid string, path string) (rpc.NodeStatus
 }
 
 func (d *Disp) NodeIsDefault(db rpc.DB, sid string, path string) (bool, error) {
-	ps := pathutil.Makepath(path)
+	ps := common.InternPath(path)
 
 	if !d.authRead(ps) {
 		return false, mgmterror.NewAccessDeniedApplicationError()
@@ -619,7 +698,7 @@ func (d *Disp) NodeIsDefault(db rpc.DB, sid string, path string) (bool, error) {
 }
 
 func (d *Disp) NodeGetType(sid string, path string) (rpc.NodeType, error) {
-	ps := pathutil.Makepath(path)
+	ps := common.InternPath(path)
 
 	if !d.authRead(ps) {
 		return rpc.CONTAINER, mgmterror.NewAccessDeniedApplicationError()
@@ -629,8 +708,72 @@ func (d *Disp) NodeGetType(sid string, path string) (rpc.NodeType, error) {
 	return sess.GetType(d.ctx, ps)
 }
 
+// NodeGetCompleteEnv returns the flags legacy shell helpers use to decide
+// how to complete a node, each set to 1 or 0:
+//
+//	is_value     - path ends in a value rather than a node name
+//	is_leaf      - node is a leaf or leaf-list
+//	is_multi     - node is a leaf-list
+//	is_tag       - node is a list
+//	is_typeless  - node takes no value (containers and empty leaves)
+//	is_presence  - node is a presence container
+//	is_secret    - node's value is secret
+//	has_allowed  - node has an allowed script or leafref to list values
+//	exists       - node exists in the session
+//	is_frozen    - node is at or below a frozen path (see FreezePath)
 func (d *Disp) NodeGetCompleteEnv(sid string, path string) (map[string]int, error) {
-	return nil, mgmterror.NewOperationNotSupportedApplicationError()
+	ps := common.InternPath(path)
+
+	if !d.authRead(ps) {
+		return nil, mgmterror.NewAccessDeniedApplicationError()
+	}
+
+	tmpl, err := d.schemaPathDescendant(ps)
+	if err != nil {
+		return nil, err
+	}
+
+	flag := func(b bool) int {
+		if b {
+			return 1
+		}
+		return 0
+	}
+	sn := tmpl.Node
+	env := map[string]int{
+		"is_value":    flag(tmpl.Val),
+		"is_leaf":     0,
+		"is_multi":    0,
+		"is_tag":      0,
+		"is_typeless": 0,
+		"is_presence": 0,
+		"is_secret":   flag(sn.ConfigdExt().Secret),
+		"has_allowed": flag(sn.ConfigdExt().Allowed != ""),
+	}
+
+	switch v := sn.(type) {
+	case schema.List:
+		env["is_tag"] = 1
+	case schema.LeafList:
+		env["is_leaf"] = 1
+		env["is_multi"] = 1
+	case schema.Leaf:
+		env["is_leaf"] = 1
+		_, empty := sn.Type().(schema.Empty)
+		env["is_typeless"] = flag(empty)
+	case schema.Container:
+		env["is_typeless"] = 1
+		env["is_presence"] = flag(v.Presence())
+	}
+	if _, ok := sn.Type().(schema.Leafref); ok {
+		env["has_allowed"] = 1
+	}
+
+	sess := d.getROSession(rpc.AUTO, sid)
+	env["exists"] = flag(sess.Exists(d.ctx, ps))
+	env["is_frozen"] = flag(d.cmgr.IsFrozen(ps))
+
+	return env, nil
 }
 
 func (d *Disp) NodeGetComment(sid string, path string) (map[string]int, error) {
@@ -639,6 +782,9 @@ func (d *Disp) NodeGetComment(sid string, path string) (map[string]int, error) {
 
 // NOTE: ps must already have been normalized
 func (d *Disp) setInternal(sid string, ps []string) (string, error) {
+	if err := d.checkExclusive(sid); err != nil {
+		return "", err
+	}
 	sess, err := d.smgr.Get(d.ctx, sid)
 	if err != nil {
 		return "", err
@@ -654,7 +800,8 @@ func (d *Disp) setInternal(sid string, ps []string) (string, error) {
 func (d *Disp) Set(sid string, path string) (string, error) {
 	//Set data authorization is done in session_internal
 
-	ps, err := d.normalizePath(pathutil.Makepath(path))
+	ps, err := d.normalizePath(
+		d.expandVariables(sid, pathutil.Makepath(path)))
 	if err != nil {
 		return "", common.FormatConfigPathErrorMultiline(err)
 	}
@@ -674,6 +821,9 @@ func (d *Disp) deleteInternal(sid string, ps []string) (bool, error) {
 	if !d.authDelete(ps) {
 		return false, mgmterror.NewAccessDeniedApplicationError()
 	}
+	if err := d.checkExclusive(sid); err != nil {
+		return false, err
+	}
 
 	sess, err := d.smgr.Get(d.ctx, sid)
 	if err != nil {
@@ -688,7 +838,7 @@ func (d *Disp) deleteInternal(sid string, ps []string) (bool, error) {
 }
 
 func (d *Disp) Delete(sid string, path string) (bool, error) {
-	ps := pathutil.Makepath(path)
+	ps := d.expandVariables(sid, pathutil.Makepath(path))
 
 	args := d.newCommandArgsForAaa("delete", nil, ps)
 	if !d.authCommand(args) {
@@ -700,32 +850,6 @@ func (d *Disp) Delete(sid string, path string) (bool, error) {
 	})
 }
 
-func (d *Disp) Rename(sid string, fpath string, tpath string) (bool, error) {
-	return false, mgmterror.NewOperationNotSupportedApplicationError()
-}
-func (d *Disp) Copy(sid string, fpath string, tpath string) (bool, error) {
-	/*srv := d.conn.srv
-	ps := normalizePath(pathutil.Makepath(path))
-
-	//Copy authorization is done in session_internal
-
-	sess, err := srv.smgr.Get(d.ctx, sid)
-	if err != nil {
-		return "", err
-	}
-
-	err = sess.Copy(d.ctx, ps)
-	if err != nil {
-		return "", err
-	}
-	return "", nil*/
-
-	return false, mgmterror.NewOperationNotSupportedApplicationError()
-}
-func (d *Disp) Comment(sid string, path string) (bool, error) {
-	return false, mgmterror.NewOperationNotSupportedApplicationError()
-}
-
 func (d *Disp) logRollbackError(err error) {
 	d.logRollbackEvent(fmt.Sprintf("Failed with error: %s", err))
 }
@@ -790,28 +914,20 @@ func (d *Disp) rollbackCommandAuthArgs(rev, comment string) *commandArgs {
 
 func (d *Disp) sessionTermination() error {
 
-	info := getConfirmedCommitInfo()
+	info := d.confirmedCommitInfo()
 	if info.Session != "" && info.PersistId == "" &&
 		info.Session == strconv.Itoa(int(d.ctx.Pid)) {
-		cmd := spawn.Command("/opt/vyatta/sbin/vyatta-config-mgmt.pl",
-			"--action=revert-configuration")
-		out, err := cmd.CombinedOutput()
-		// out contains the output of both stdout and stderr. err is not really
-		// user relevant so shouldn't be printed.
-		if err != nil {
-			err := mgmterror.NewOperationFailedApplicationError()
-			err.Message = string(out)
-			return err
-		}
+		d.logConfirmedCommitEvent("Session terminated, reverting")
+		return d.revertConfirmedCommit("Confirmed commit session terminated")
 	}
 	return nil
 }
 
 func (d *Disp) CancelCommit(sid, comment, persistid string, force, debug bool) (string, error) {
-	info := getConfirmedCommitInfo()
+	info := d.confirmedCommitInfo()
 	if !force {
 		switch {
-		case info.Session == "":
+		case !info.Pending():
 			err := mgmterror.NewOperationFailedApplicationError()
 			err.Message = "No confirmed commit pending"
 			return "", err
@@ -819,7 +935,7 @@ func (d *Disp) CancelCommit(sid, comment, persistid string, force, debug bool) (
 			err := mgmterror.NewInvalidValueProtocolError()
 			err.Message = "persist-id does not match pending confirmed commit"
 			return "", err
-		case info.PersistId == "" && info.Session != strconv.Itoa(int(d.ctx.Pid)):
+		case info.PersistId == "" && !d.ownsConfirmedCommit(info):
 			err := mgmterror.NewAccessDeniedApplicationError()
 			err.Message = "Pending confirmed commit initiated by another session"
 			return "", err
@@ -852,15 +968,17 @@ func (d *Disp) rollbackInternal(sid, revision, comment string, debug bool) (stri
 	}
 
 	if revision != "revert" {
-		log, _ := d.GetCommitLog()
-		if _, exists := log[revision]; !exists {
+		num, err := resolveConfigRevision(revision)
+		if err != nil {
 			err := newInvalidConfigRevisionError(revision)
 			d.logRollbackError(err)
 			return retStr, err
 		}
+		log, _ := d.GetCommitLog()
 
 		d.logConfirmedCommitEvent(fmt.Sprintf("Reverting confirmed-commit revision %s [%s] from archive",
-			revision, log[revision]))
+			revision, log[num]))
+		revision = num
 	} else {
 		if _, err := os.Stat(configRevisionFileName(revision)); err != nil {
 			if os.IsNotExist(err) {
@@ -892,7 +1010,11 @@ func (d *Disp) rollbackInternal(sid, revision, comment string, debug bool) (stri
 			return retStr, err
 		}
 	}
+	if revision == "revert" {
+		os.Remove(confirmedCommitRevertFile)
+	}
 	d.logRollbackEvent("Completed successfully")
+	d.cmgr.RecordRollback(d.ctx)
 
 	return retStr, nil
 }
@@ -903,23 +1025,22 @@ func (d *Disp) Rollback(sid, revision, comment string, debug bool) (string, erro
 		return "", mgmterror.NewAccessDeniedApplicationError()
 	}
 
-	return d.accountCmdWrapStrErr(args, func() (interface{}, error) {
-		return d.rollbackInternal(sid, revision, comment, debug)
-	})
+	return d.accountCmdWrapStrErr(args, d.audited(AuditRollback, sid,
+		rpc.RUNNING, func() (interface{}, error) {
+			return d.rollbackInternal(sid, revision, comment, debug)
+		}))
 }
 
 func (d *Disp) confirmInternal(sid string) (string, error) {
-	cmd := spawn.Command("/opt/vyatta/sbin/vyatta-config-mgmt.pl",
-		"--action=confirm")
-	out, err := cmd.CombinedOutput()
-	// out contains the output of both stdout and stderr. err is not really
-	// user relevant so shouldn't be printed.
-	if err != nil {
+	info, ok := d.cmgr.ConfirmCommit(confirmedCommitJobFile)
+	if !ok {
 		err := mgmterror.NewOperationFailedApplicationError()
-		err.Message = string(out)
+		err.Message = "No confirmed commit pending"
 		return "", err
 	}
-	return string(out), err
+	os.Remove(confirmedCommitRevertFile)
+	d.logConfirmedCommitEvent("Confirmed persist-id [" + info.PersistId + "]")
+	return "", nil
 }
 
 func (d *Disp) Confirm(sid string) (string, error) {
@@ -930,18 +1051,18 @@ func (d *Disp) Confirm(sid string) (string, error) {
 }
 
 func (d *Disp) confirmPersistIdInternal(persistid string) (string, error) {
-	cmd := spawn.Command("/opt/vyatta/sbin/vyatta-config-mgmt.pl",
-		"--action=confirm",
-		fmt.Sprintf("--persistid=%s", persistid))
-	out, err := cmd.CombinedOutput()
-	// out contains the output of both stdout and stderr. err is not really
-	// user relevant so shouldn't be printed.
-	if err != nil {
+	info := d.confirmedCommitInfo()
+	switch {
+	case !info.Pending():
 		err := mgmterror.NewOperationFailedApplicationError()
-		err.Message = string(out)
+		err.Message = "No confirmed commit pending"
+		return "", err
+	case info.PersistId != persistid:
+		err := mgmterror.NewInvalidValueProtocolError()
+		err.Message = "persist-id does not match pending confirmed commit"
 		return "", err
 	}
-	return string(out), err
+	return d.confirmInternal("")
 }
 
 func (d *Disp) ConfirmPersistId(persistid string) (string, error) {
@@ -953,63 +1074,38 @@ func (d *Disp) ConfirmPersistId(persistid string) (string, error) {
 	})
 }
 
+// ConfirmingCommit confirms any pending confirmed commit.
 func (d *Disp) ConfirmingCommit() (string, error) {
-	cmd := spawn.Command("/opt/vyatta/sbin/vyatta-config-mgmt.pl",
-		"--action=confirming-commit")
-	out, err := cmd.CombinedOutput()
-	// out contains the output of both stdout and stderr. err is not really
-	// user relevant so shouldn't be printed.
-	if err != nil {
-		err := mgmterror.NewOperationFailedApplicationError()
-		err.Message = string(out)
-		return "", err
+	if _, ok := d.cmgr.ConfirmCommit(confirmedCommitJobFile); ok {
+		os.Remove(confirmedCommitRevertFile)
 	}
-	return string(out), err
-
+	return "", nil
 }
 
+// ConfirmSilent stops any pending confirmed commit from being reverted,
+// keeping the configuration to revert to as it is about to be rolled back
+// to, or superseded by another commit.
 func (d *Disp) ConfirmSilent(sid string) (string, error) {
-	cmd := spawn.Command("/opt/vyatta/sbin/vyatta-config-mgmt.pl",
-		"--action=confirm-silent")
-	out, err := cmd.CombinedOutput()
-	// out contains the output of both stdout and stderr. err is not really
-	// user relevant so shouldn't be printed.
-	if err != nil {
-		err := mgmterror.NewOperationFailedApplicationError()
-		err.Message = string(out)
-		return "", err
-	}
-	return string(out), err
+	d.cmgr.ConfirmCommit(confirmedCommitJobFile)
+	return "", nil
 }
 
 func (d *Disp) setConfirmedCommitTimeout(cmt *commitInfo) (string, error) {
-	cmd := spawn.Command("/opt/vyatta/sbin/vyatta-config-mgmt.pl",
-		cmt.arguments(strconv.Itoa(int(d.ctx.Pid)))...)
-	out, err := cmd.CombinedOutput()
-	// out contains the output of both stdout and stderr. err is not really
-	// user relevant so shouldn't be printed.
-	if err != nil {
-		err := mgmterror.NewOperationFailedApplicationError()
-		err.Message = string(out)
-		return "", err
-	} else {
+	out, err := d.startConfirmedCommit(session.ConfirmedCommitInfo{
+		Session:   strconv.Itoa(int(d.ctx.Pid)),
+		PersistId: cmt.persist,
+	}, time.Duration(cmt.timeout)*time.Second)
+	if err == nil {
 		d.logConfirmedCommitEvent("Scheduled revert for persist-id [" + cmt.persist + "]")
 	}
-	return string(out), err
+	return out, err
 }
+
+// setConfirmTimeout schedules the revert of a CLI commit-confirm, which
+// isn't tied to the session.
 func (d *Disp) setConfirmTimeout(mins int) (string, error) {
-	cmd := spawn.Command("/opt/vyatta/sbin/vyatta-config-mgmt.pl",
-		"--action=commit-confirm",
-		fmt.Sprintf("--minutes=%d", mins))
-	out, err := cmd.CombinedOutput()
-	// out contains the output of both stdout and stderr. err is not really
-	// user relevant so shouldn't be printed.
-	if err != nil {
-		err := mgmterror.NewOperationFailedApplicationError()
-		err.Message = string(out)
-		return "", err
-	}
-	return string(out), err
+	return d.startConfirmedCommit(session.ConfirmedCommitInfo{},
+		time.Duration(mins)*time.Minute)
 }
 
 func (d *Disp) CommitConfirm(
@@ -1024,9 +1120,10 @@ func (d *Disp) CommitConfirm(
 	}
 	cmdArgs := d.newCommandArgsForAaa("commit-confirm", args, nil)
 
-	return d.accountCmdWrapStrErr(cmdArgs, func() (interface{}, error) {
-		return d.commitInternal(sid, message, debug, mins, false)
-	})
+	return d.accountCmdWrapStrErr(cmdArgs, d.audited(AuditCommit, sid,
+		rpc.RUNNING, func() (interface{}, error) {
+			return d.commitInternal(sid, message, debug, mins, false)
+		}))
 }
 
 func (d *Disp) Commit(
@@ -1040,9 +1137,10 @@ func (d *Disp) Commit(
 	}
 	cmdArgs := d.newCommandArgsForAaa("commit", args, nil)
 
-	return d.accountCmdWrapStrErr(cmdArgs, func() (interface{}, error) {
-		return d.commitInternal(sid, message, debug, 0, false)
-	})
+	return d.accountCmdWrapStrErr(cmdArgs, d.audited(AuditCommit, sid,
+		rpc.RUNNING, func() (interface{}, error) {
+			return d.commitInternal(sid, message, debug, 0, false)
+		}))
 }
 
 func (d *Disp) ConfirmedCommit(
@@ -1065,9 +1163,11 @@ func (d *Disp) ConfirmedCommit(
 	}
 
 	cmdArgs := d.newCommandArgsForAaa("commit", args, nil)
-	return d.accountCmdWrapStrErr(cmdArgs, func() (interface{}, error) {
-		return d.confirmedCommitInternal(sid, message, debug, 0, cmt, false)
-	})
+	return d.accountCmdWrapStrErr(cmdArgs, d.audited(AuditCommit, sid,
+		rpc.RUNNING, func() (interface{}, error) {
+			return d.confirmedCommitInternal(
+				sid, message, debug, 0, cmt, false)
+		}))
 }
 
 func (d *Disp) commitInternal(
@@ -1091,6 +1191,12 @@ func (d *Disp) confirmedCommitInternal(
 
 	var rpcout bytes.Buffer
 
+	if !revert {
+		if err := d.checkExclusive(sid); err != nil {
+			return "", err
+		}
+	}
+
 	sess, err := d.smgr.Get(d.ctx, sid)
 	if err != nil {
 		return "", err
@@ -1106,7 +1212,15 @@ func (d *Disp) confirmedCommitInternal(
 		return "", err
 	}
 
+	if (cmt != nil && cmt.confirmed) || confirmTimeout != 0 {
+		if err := d.saveConfirmedCommitRevert(); err != nil {
+			return "", err
+		}
+	}
+
 	outs, errs, ok := sess.Commit(d.ctx, message, debug)
+	sortOutputs(outs)
+	sortErrors(errs)
 
 	if outs != nil {
 		for _, out := range outs {
@@ -1128,6 +1242,7 @@ func (d *Disp) confirmedCommitInternal(
 		if ok, err := d.Save(""); !ok {
 			return "", err
 		}
+		rpcout.WriteString(d.shadowCommitResult())
 		if cmt != nil && cmt.confirmed {
 
 			out, err := d.setConfirmedCommitTimeout(cmt)
@@ -1163,6 +1278,7 @@ func (d *Disp) confirmedCommitInternal(
 
 	// NB: a validation error found during commit will be reported as a commit
 	//     failure, with validation errors printed out.
+	sess.SetLastFailure(failedCommit, errs)
 	return "", merr
 }
 
@@ -1177,10 +1293,7 @@ func (d *Disp) Compare(old, new, spath string, ctxdiff bool) (string, error) {
 		return "", err
 	}
 
-	dtree := diff.NewNode(t1, t2, d.ms, nil)
-	dtree = dtree.Descendant(pathutil.Makepath(spath))
-	hide := !configd.InSecretsGroup(d.ctx)
-	return dtree.Serialize(ctxdiff, diff.HideSecrets(hide)), nil
+	return d.compareConfigTrees(t1, t2, spath, ctxdiff), nil
 }
 
 func (d *Disp) validCompareConfigRevision(revision string) bool {
@@ -1188,9 +1301,8 @@ func (d *Disp) validCompareConfigRevision(revision string) bool {
 		return true
 	}
 
-	log, _ := d.GetCommitLog()
-	_, exists := log[revision]
-	return exists
+	_, err := resolveConfigRevision(revision)
+	return err == nil
 }
 
 func newInvalidConfigRevisionError(revision string) error {
@@ -1207,24 +1319,14 @@ func (d *Disp) compareConfigRevisionsInternal(sid, revOne, revTwo string) (strin
 		return "", newInvalidConfigRevisionError(revTwo)
 	}
 
-	var one string
-	var err error
-	if revOne == "session" {
-		candSess := d.getROSession(rpc.CANDIDATE, sid)
-		one, err = candSess.ShowForceSecrets(d.ctx, nil, false, false)
-	} else {
-		one, err = d.readConfigFileForceShowSecrets(configRevisionFileName(revOne))
-	}
+	trees, err := d.loadConfigRevisions(sid, revOne, revTwo)
 	if err != nil {
 		return "", err
 	}
-
-	two, err := d.readConfigFileForceShowSecrets(configRevisionFileName(revTwo))
-	if err != nil {
-		return "", err
+	if requestCancelled(d.ctx.Done()) {
+		return "", newCompareCancelledError()
 	}
-
-	return d.Compare(one, two, "", true)
+	return d.compareConfigTrees(trees[0], trees[1], "", true), nil
 }
 
 func (d *Disp) CompareConfigRevisions(sid, revOne, revTwo string) (string, error) {
@@ -1270,6 +1372,77 @@ func (d *Disp) CompareSessionChanges(sid string) (string, error) {
 	})
 }
 
+// effectiveDiffInternal - as compareSessionChangesInternal, but including
+// defaults so changes that only alter the defaults in effect are shown.
+func (d *Disp) effectiveDiffInternal(sid, path string) (string, error) {
+	runningSess := d.getROSession(rpc.RUNNING, sid)
+	candSess := d.getROSession(rpc.CANDIDATE, sid)
+
+	runningShow, err := runningSess.ShowForceSecrets(d.ctx, nil, false, true)
+	if err != nil {
+		return "", err
+	}
+
+	candShow, err := candSess.ShowForceSecrets(d.ctx, nil, false, true)
+	if err != nil {
+		return "", err
+	}
+
+	return d.Compare(candShow, runningShow, path, true)
+}
+
+func (d *Disp) EffectiveDiff(sid, path string) (string, error) {
+	args := d.newCommandArgsForAaa("compare", []string{"-all"},
+		pathutil.Makepath(path))
+	if !d.authCommand(args) {
+		return "", mgmterror.NewAccessDeniedApplicationError()
+	}
+
+	return d.accountCmdWrapStrErr(args, func() (interface{}, error) {
+		return d.effectiveDiffInternal(sid, path)
+	})
+}
+
+// compareSessionsInternal - diff the candidates of two sessions
+//
+// Output is presented as the changes needed to turn sidA's candidate into
+// sidB's, in the same context diff format used by CompareSessionChanges.
+// Unlike getROSession() we do not fall back to RUNNING if either session
+// can't be found, as silently comparing against running would be misleading.
+func (d *Disp) compareSessionsInternal(sidA, sidB string) (string, error) {
+	sessA, err := d.smgr.Get(d.ctx, sidA)
+	if err != nil {
+		return "", err
+	}
+	sessB, err := d.smgr.Get(d.ctx, sidB)
+	if err != nil {
+		return "", err
+	}
+
+	showA, err := sessA.ShowForceSecrets(d.ctx, nil, false, false)
+	if err != nil {
+		return "", err
+	}
+
+	showB, err := sessB.ShowForceSecrets(d.ctx, nil, false, false)
+	if err != nil {
+		return "", err
+	}
+
+	return d.Compare(showB, showA, "", true)
+}
+
+func (d *Disp) CompareSessions(sidA, sidB string) (string, error) {
+	args := d.newCommandArgsForAaa("compare", nil, nil)
+	if !d.authCommand(args) {
+		return "", mgmterror.NewAccessDeniedApplicationError()
+	}
+
+	return d.accountCmdWrapStrErr(args, func() (interface{}, error) {
+		return d.compareSessionsInternal(sidA, sidB)
+	})
+}
+
 // If conforms to interface
 
 func (d *Disp) discardInternal(sid string) (bool, error) {
@@ -1285,6 +1458,48 @@ func (d *Disp) discardInternal(sid string) (bool, error) {
 	return true, nil
 }
 
+func (d *Disp) discardPathInternal(sid string, ps []string) (bool, error) {
+	if !d.authPath(ps, auth.P_UPDATE) {
+		return false, mgmterror.NewAccessDeniedApplicationError()
+	}
+
+	sess, err := d.smgr.Get(d.ctx, sid)
+	if err != nil {
+		return false, err
+	}
+
+	err = sess.DiscardPath(d.ctx, ps)
+	if err != nil {
+		return false, common.FormatConfigPathErrorMultiline(err)
+	}
+	return true, nil
+}
+
+func (d *Disp) rebaseInternal(sid string) ([]string, error) {
+	sess, err := d.smgr.Get(d.ctx, sid)
+	if err != nil {
+		return []string{}, err
+	}
+
+	conflicts, err := sess.Rebase(d.ctx)
+	if conflicts == nil {
+		conflicts = []string{}
+	}
+	return conflicts, err
+}
+
+// Rebase moves the session's changes onto the current running configuration
+// and returns the paths, if any, that conflict with changes committed by
+// other sessions since the session's changes were made.  The session is only
+// updated if there are no conflicts.
+func (d *Disp) Rebase(sid string) ([]string, error) {
+	args := d.newCommandArgsForAaa("rebase", nil, nil)
+
+	return d.accountCmdWrapStrSliceErr(args, func() (interface{}, error) {
+		return d.rebaseInternal(sid)
+	})
+}
+
 func (d *Disp) Discard(sid string) (bool, error) {
 	args := d.newCommandArgsForAaa("discard", nil, nil)
 
@@ -1293,17 +1508,62 @@ func (d *Disp) Discard(sid string) (bool, error) {
 	})
 }
 
-func (d *Disp) ExtractArchive(sid, revision, destination string) (string, error) {
-	cmd := spawn.Command("/opt/vyatta/sbin/vyatta-config-mgmt.pl", "--action=extract-archive", "--revnum="+revision, "--dest="+destination)
-	out, err := cmd.CombinedOutput()
-	// out contains the output of both stdout and stderr. err is not really
-	// user relevant so shouldn't be printed.
+// DiscardPath reverts the session's changes at and below path, leaving
+// its other changes in place.
+func (d *Disp) DiscardPath(sid, path string) (bool, error) {
+	ps := pathutil.Makepath(path)
+
+	args := d.newCommandArgsForAaa("discard", nil, ps)
+	if !d.authCommand(args) {
+		return false, mgmterror.NewAccessDeniedApplicationError()
+	}
+
+	return d.accountCmdWrapBoolErr(args, func() (interface{}, error) {
+		return d.discardPathInternal(sid, ps)
+	})
+}
+
+func (d *Disp) extractArchiveInternal(revision, destination string) error {
+	store := archiveStore()
+	num, err := store.Resolve(revision)
 	if err != nil {
-		cerr := mgmterror.NewOperationFailedApplicationError()
-		cerr.Message = string(out)
-		return "", cerr
+		return err
+	}
+	dest := d.parseLocalPath(destination)
+	if err := d.validLocalSaveToDest(dest); err != nil {
+		return err
+	}
+	cfg, err := store.Read(num)
+	if err != nil {
+		return err
+	}
+
+	tmpFile, err := ioutil.TempFile(tmpDir, ".extract.")
+	if err != nil {
+		return err
+	}
+	defer tmpFile.Close()
+	defer os.Remove(tmpFile.Name())
+	if _, err := tmpFile.Write(cfg); err != nil {
+		return err
+	}
+	// The extracted revision belongs to the requesting user, who copies it
+	// into place.
+	if !d.ctx.Configd {
+		if err := tmpFile.Chown(int(d.ctx.Uid), -1); err != nil {
+			return err
+		}
+	}
+	return d.copyFile(tmpFile, dest)
+}
+
+// ExtractArchive writes the uncompressed archived revision, named by number
+// or tag, to destination.
+func (d *Disp) ExtractArchive(sid, revision, destination string) (string, error) {
+	if err := d.extractArchiveInternal(revision, destination); err != nil {
+		return "", err
 	}
-	return string(out), err
+	return "", nil
 }
 
 func (d *Disp) Save(_ string) (bool, error) {
@@ -1311,12 +1571,12 @@ func (d *Disp) Save(_ string) (bool, error) {
 	// which allows us to do several things:
 	//   1) Access the entire config without being subjected to ACM
 	//   2) Obtain un-redacted secrets
-	//   3) Write to /config/config.boot which is owned by root
+	//   3) Write to config.boot in the config directory, owned by root
 	if !d.ctx.Configd {
 		d.ctx.RaisePrivileges()
 		defer d.ctx.DropPrivileges()
 	}
-	return d.SaveTo("/config/config.boot", "")
+	return d.SaveTo(configBootFile(), "")
 }
 
 func (d *Disp) Load(sid string, file string) (bool, error) {
@@ -1334,12 +1594,16 @@ func (d *Disp) LoadReportWarnings(sid string, file string) (bool, error) {
 		return false, mgmterror.NewAccessDeniedApplicationError()
 	}
 
-	return d.accountCmdWrapBoolErr(args, func() (interface{}, error) {
-		return d.loadReportWarningsReader(sid, file, nil)
-	})
+	return d.accountCmdWrapBoolErr(args, d.audited(AuditLoad, sid,
+		rpc.CANDIDATE, func() (interface{}, error) {
+			return d.loadReportWarningsReader(sid, file, nil)
+		}))
 }
 
 func (d *Disp) loadReportWarningsReader(sid string, file string, r io.Reader) (bool, error) {
+	if err := d.checkExclusive(sid); err != nil {
+		return false, err
+	}
 	sess, err := d.smgr.Get(d.ctx, sid)
 	if err != nil {
 		return false, err
@@ -1363,6 +1627,9 @@ func (d *Disp) Merge(sid string, file string) (bool, error) {
 }
 
 func (d *Disp) mergeReportWarningsInternal(sid string, file string) (bool, error) {
+	if err := d.checkExclusive(sid); err != nil {
+		return false, err
+	}
 	sess, err := d.smgr.Get(d.ctx, sid)
 	if err != nil {
 		return false, err
@@ -1382,9 +1649,10 @@ func (d *Disp) MergeReportWarnings(sid string, file string) (bool, error) {
 		return false, mgmterror.NewAccessDeniedApplicationError()
 	}
 
-	return d.accountCmdWrapBoolErr(args, func() (interface{}, error) {
-		return d.mergeReportWarningsInternal(sid, file)
-	})
+	return d.accountCmdWrapBoolErr(args, d.audited(AuditLoad, sid,
+		rpc.CANDIDATE, func() (interface{}, error) {
+			return d.mergeReportWarningsInternal(sid, file)
+		}))
 }
 
 func (d *Disp) validateInternal(sid string) (string, error) {
@@ -1395,6 +1663,8 @@ func (d *Disp) validateInternal(sid string) (string, error) {
 	}
 
 	outs, errs, ok := sess.Validate(d.ctx)
+	sortOutputs(outs)
+	sortErrors(errs)
 	if outs != nil {
 		for _, out := range outs {
 			if out == nil {
@@ -1410,6 +1680,7 @@ func (d *Disp) validateInternal(sid string) (string, error) {
 		return rpcout.String(), nil
 	}
 
+	sess.SetLastFailure(failedValidate, errs)
 	var merr mgmterror.MgmtErrorList
 	merr.MgmtErrorListAppend(errs...)
 	return "", merr
@@ -1435,13 +1706,28 @@ func (d *Disp) validateConfigInternal(sid, encoding, config string) (string, err
 		return "", err
 	}
 
-	err = sess.CopyConfig(d.ctx, "", encoding, config, "", "candidate", "")
+	if encoding == "curly" {
+		// Paths the schema doesn't have would otherwise be dropped
+		// with a warning, as for load.
+		var invalidPaths []error
+		err, invalidPaths = sess.Load(d.ctx, "validate",
+			strings.NewReader(config))
+		if err == nil && len(invalidPaths) != 0 {
+			var merr mgmterror.MgmtErrorList
+			merr.MgmtErrorListAppend(invalidPaths...)
+			err = merr
+		}
+	} else {
+		err = sess.CopyConfig(d.ctx, "", encoding, config, "", "candidate", "")
+	}
 	if err != nil {
 		return "", err
 	}
 	return d.Validate(sn)
 }
 
+// ValidateConfig validates config, encoded as curly, json, rfc7951 or xml,
+// in a scratch session, leaving the caller's candidate untouched.
 func (d *Disp) ValidateConfig(sid, encoding, config string) (string, error) {
 	args := d.newCommandArgsForAaa("validate", nil, nil)
 
@@ -1483,7 +1769,7 @@ func (d *Disp) show(db rpc.DB, sid string, path []string, hideSecrets, showDefau
 }
 
 func (d *Disp) Show(db rpc.DB, sid string, path string, hideSecrets bool) (string, error) {
-	ps := pathutil.Makepath(path)
+	ps := common.InternPath(path)
 
 	args := d.showCommandArgs(ps, false)
 	if !d.authCommand(args) {
@@ -1496,7 +1782,7 @@ func (d *Disp) Show(db rpc.DB, sid string, path string, hideSecrets bool) (strin
 }
 
 func (d *Disp) ShowDefaults(db rpc.DB, sid string, path string, hideSecrets bool) (string, error) {
-	ps := pathutil.Makepath(path)
+	ps := common.InternPath(path)
 
 	args := d.showCommandArgs(ps, true)
 	if !d.authCommand(args) {
@@ -1508,6 +1794,29 @@ func (d *Disp) ShowDefaults(db rpc.DB, sid string, path string, hideSecrets bool
 	})
 }
 
+// ShowWithOpts - show configuration, with defaults and secrets handled
+// according to the TreeOpts flags passed by the client.
+func (d *Disp) ShowWithOpts(
+	db rpc.DB, sid, path string,
+	flags map[string]interface{},
+) (string, error) {
+	ps := pathutil.Makepath(path)
+	opts := session.NewTreeOpts(flags)
+
+	args := d.showCommandArgs(ps, opts.Defaults)
+	if !d.authCommand(args) {
+		return "", mgmterror.NewAccessDeniedApplicationError()
+	}
+	if err := d.authTreeOpts(opts); err != nil {
+		return "", err
+	}
+
+	return d.accountCmdWrapStrErr(args, func() (interface{}, error) {
+		sess := d.getROSession(db, sid)
+		return sess.ShowWithOpts(d.ctx, ps, opts)
+	})
+}
+
 func (d *Disp) showConfigWithContextDiffsInternal(
 	sid string, path string, showDefaults bool,
 ) (string, error) {
@@ -1553,11 +1862,47 @@ func (d *Disp) AuthGetPerms() (map[string]string, error) {
 	return d.ctx.Auth.GetPerms(d.ctx.Groups), nil
 }
 
+// authTreeOpts - check the caller may use the options they asked for.
+// Forcing secrets to be shown bypasses the secrets group, so is restricted
+// to superusers and those already able to read secrets.
+func (d *Disp) authTreeOpts(opts *session.TreeOpts) error {
+	if opts.ForceSecrets &&
+		!d.ctx.Superuser && !configd.ShowSecrets(d.ctx) {
+		err := mgmterror.NewAccessDeniedApplicationError()
+		err.Message = "Not authorized to force secrets to be shown"
+		return err
+	}
+	return nil
+}
+
 func (d *Disp) TreeGet(db rpc.DB, sid, path, encoding string, flags map[string]interface{}) (string, error) {
-	ps := pathutil.Makepath(path)
+	if db == rpc.OPERATIONAL {
+		// The operational datastore holds state as well as config.
+		return d.TreeGetFull(db, sid, path, encoding, flags)
+	}
+	if !d.protocolOrigin() {
+		return d.treeGetInternal(db, sid, path, encoding, flags)
+	}
+	args := d.newCommandArgsForAaa("get-config", []string{datastoreName(db)},
+		pathutil.Makepath(path))
+	return d.accountCmdWrapStrErr(args, func() (interface{}, error) {
+		return d.treeGetInternal(db, sid, path, encoding, flags)
+	})
+}
+
+func (d *Disp) treeGetInternal(db rpc.DB, sid, path, encoding string, flags map[string]interface{}) (string, error) {
+	ps := common.InternPath(path)
 	sess := d.getROSession(db, sid)
 
 	opts := session.NewTreeOpts(flags)
+	if err := d.authTreeOpts(opts); err != nil {
+		return fixupEmptyStringForEncoding("", encoding), err
+	}
+	if opts.Annotations {
+		if err := checkAnnotationsEncoding(encoding); err != nil {
+			return fixupEmptyStringForEncoding("", encoding), err
+		}
+	}
 	// For NETCONF, it's not an error if a node could exist, but currently
 	// is not configured.
 	if encoding == "netconf" {
@@ -1574,7 +1919,11 @@ func (d *Disp) TreeGet(db rpc.DB, sid, path, encoding string, flags map[string]i
 
 	options := opts.ToUnionOptions()
 	options = append(options, union.Authorizer(sess.NewAuther(d.ctx)))
-	return ut.Marshal("data", encoding, options...)
+	out, err := ut.Marshal("data", encoding, options...)
+	if err != nil || !opts.Annotations {
+		return out, err
+	}
+	return d.addTreeAnnotations(out, ps)
 }
 
 func (d *Disp) TreeGetFull(
@@ -1582,8 +1931,18 @@ func (d *Disp) TreeGetFull(
 	flags map[string]interface{},
 ) (string, error) {
 
-	out, err, _ := d.TreeGetFullWithWarnings(db, sid, path, encoding, flags)
-	return out, err
+	if !d.protocolOrigin() {
+		out, err, _ := d.TreeGetFullWithWarnings(
+			db, sid, path, encoding, flags)
+		return out, err
+	}
+	args := d.newCommandArgsForAaa("get", []string{datastoreName(db)},
+		pathutil.Makepath(path))
+	return d.accountCmdWrapStrErr(args, func() (interface{}, error) {
+		out, err, _ := d.TreeGetFullWithWarnings(
+			db, sid, path, encoding, flags)
+		return out, err
+	})
 }
 
 func (d *Disp) printWarnings(warns []error) {
@@ -1622,10 +1981,13 @@ func (d *Disp) TreeGetFullWithWarnings(
 	flags map[string]interface{},
 ) (string, error, []error) {
 
-	ps := pathutil.Makepath(path)
+	ps := common.InternPath(path)
 	sess := d.getROSession(db, sid)
 
 	opts := session.NewTreeOpts(flags)
+	if err := d.authTreeOpts(opts); err != nil {
+		return fixupEmptyStringForEncoding("", encoding), err, nil
+	}
 	// Unconditionally allow for nodes that could exist, but don't have
 	// any current config, or are state nodes.  This allows us to return
 	// empty data rather than an error, saving that for when the path could
@@ -1747,13 +2109,17 @@ func (d *Disp) GetFeatures() (map[string]string, error) {
 }
 
 func (d *Disp) GetHelp(sid string, schema bool, path string) (map[string]string, error) {
-	ps := pathutil.Makepath(path)
+	ps := common.InternPath(path)
 	sess := d.getROSession(rpc.CANDIDATE, sid)
-	return sess.GetHelp(d.ctx, schema, ps)
+	help, err := sess.GetHelp(d.ctx, schema, ps)
+	if err == nil && schema {
+		d.addValueHints(ps, help)
+	}
+	return help, err
 }
 
 func (d *Disp) GetCompletions(sid string, schema bool, path string) (map[string]string, error) {
-	ps := pathutil.Makepath(path)
+	ps := common.InternPath(path)
 
 	typ, err := d.NodeGetType(sid, path)
 	if err != nil {
@@ -1792,10 +2158,16 @@ func (d *Disp) GetCompletions(sid string, schema bool, path string) (map[string]
 }
 
 func configRevisionFileName(revision string) string {
-	if revision == "saved" {
-		return "/config/config.boot"
+	switch revision {
+	case "saved":
+		return configBootFile()
+	case "revert":
+		return confirmedCommitRevertFile
 	}
-	return "/config/archive/config.boot." + revision + ".gz"
+	if num, err := resolveConfigRevision(revision); err == nil {
+		revision = num
+	}
+	return archiveDir() + "/config.boot." + revision + ".gz"
 }
 
 func (d *Disp) cfgFileReader(file *os.File) (io.Reader, error) {
@@ -1827,6 +2199,9 @@ func (d *Disp) readCfgFile(file string, raw, forceShowSecrets bool) (string, err
 		}
 		return string(text), nil
 	}
+	if r, err = session.DecryptConfigReader(r); err != nil {
+		return "", err
+	}
 	dtree, err, _ := load.LoadFile(file, r, d.ms)
 	if err != nil {
 		return "", err
@@ -1857,10 +2232,6 @@ func (d *Disp) ReadConfigFile(file string) (string, error) {
 	return d.readCfgFile(file, false, false)
 }
 
-func (d *Disp) readConfigFileForceShowSecrets(file string) (string, error) {
-	return d.readCfgFile(file, false, true)
-}
-
 func (d *Disp) MigrateConfigFile(file string) (string, error) {
 	// This is now obsolete and is due to be fully removed. For now, just do
 	// nothing.
@@ -1995,6 +2366,7 @@ func (vrc *vciRpcCaller) CallRpc(
 
 func (d *Disp) handleVciRpc(
 	ctx *configd.Context,
+	modelName string,
 	moduleName string,
 	encoding string,
 	rpc schema.Rpc,
@@ -2015,6 +2387,11 @@ func (d *Disp) handleVciRpc(
 		return "", err
 	}
 
+	err = d.validateRpcOutput(modelName, moduleName, rpcName, rpc, output)
+	if err != nil {
+		return "", err
+	}
+
 	return convertJsonOutputToRpcReply(rpc, output, encoding)
 }
 
@@ -2040,8 +2417,17 @@ func convertJsonOutputToRpcReply(rpc schema.Rpc, output, encoding string,
 
 func (d *Disp) CallRpc(moduleIdOrNamespace, rpcName, args, encoding string,
 ) (string, error) {
-	return d.callRpcInternal(moduleIdOrNamespace, rpcName, args, encoding,
-		&vciRpcCaller{})
+	if !d.protocolOrigin() {
+		return d.callRpcInternal(moduleIdOrNamespace, rpcName, args,
+			encoding, &vciRpcCaller{})
+	}
+	// The RPC's input may hold secrets, so isn't accounted.
+	cmdArgs := d.newCommandArgsForAaa("rpc",
+		[]string{moduleIdOrNamespace, rpcName}, nil)
+	return d.accountCmdWrapStrErr(cmdArgs, func() (interface{}, error) {
+		return d.callRpcInternal(moduleIdOrNamespace, rpcName, args,
+			encoding, &vciRpcCaller{})
+	})
 }
 
 func (d *Disp) callRpcInternal(
@@ -2057,7 +2443,7 @@ func (d *Disp) callRpcInternal(
 		return "", err
 	}
 
-	_, found :=
+	modelName, found :=
 		d.ctx.CompMgr.GetComponentNSMappings().GetModelNameForNamespace(moduleNs)
 	if found {
 		moduleId, _ := getModuleId(d.ms, moduleIdOrNamespace, encoding)
@@ -2065,7 +2451,7 @@ func (d *Disp) callRpcInternal(
 			return "", mgmterror.NewAccessDeniedApplicationError()
 		}
 		output, err := d.handleVciRpc(d.ctx,
-			moduleId, encoding, rpc, rpcName, args, vrc)
+			modelName, moduleId, encoding, rpc, rpcName, args, vrc)
 		return output, common.FormatRpcPathError(err)
 	}
 
@@ -2093,7 +2479,7 @@ func (d *Disp) CallRpcXml(moduleNamespace, name, args string) (string, error) {
 //
 func (d *Disp) ExpandWithPrefix(path, prefix string, pos int) (string, error) {
 	// Need prefix, and 'argpos'
-	ps, err := d.expandPath(pathutil.Makepath(path), prefix, pos+1)
+	ps, err := d.expandPath(pathutil.Makepath(path), prefix, pos+1, nil)
 	if err != nil {
 		return "", common.FormatConfigPathError(err)
 	}
@@ -2117,7 +2503,11 @@ type processNodeFn func(
 ) ([]string, error)
 
 func (d *Disp) expandPath(path []string, prefix string, pos int,
+	opts *expandOpts,
 ) ([]string, error) {
+	if opts == nil {
+		opts = &expandOpts{}
+	}
 	cpath := make([]string, 0, len(path))
 	origPath := path
 
@@ -2239,9 +2629,20 @@ func (d *Disp) expandPath(path []string, prefix string, pos int,
 				}
 				nameToAppend += val[len(prefix):]
 			}
+			expandUsageCounts.record(matches[0])
 			return processnode(
 				matches[0], path, append(cpath, nameToAppend), prefix, pos)
 		default:
+			matches = rankExpandMatches(val, matches)
+			if sel := strictPrefixMatch(matches); sel != nil &&
+				opts.autoSelect && !prefixMatch {
+				expandUsageCounts.record(sel)
+				return processnode(
+					sel, path, append(cpath, sel.Name()), prefix, pos)
+			}
+			if opts.candidates == nil {
+				opts.candidates = expandMatchNames(matches)
+			}
 			matchnames := make(map[string]string)
 			for _, v := range matches {
 				matchnames[v.Name()] = v.ConfigdExt().Help
@@ -2255,6 +2656,18 @@ func (d *Disp) expandPath(path []string, prefix string, pos int,
 }
 
 func (d *Disp) EditConfigXML(sid, config_target, default_operation, test_option, error_option, config string) (string, error) {
+	args := d.editConfigCommandArgs(config_target, default_operation,
+		editConfigXMLNames(config))
+	return d.accountCmdWrapStrErr(args, func() (interface{}, error) {
+		return d.editConfigXMLInternal(sid, config_target,
+			default_operation, test_option, error_option, config)
+	})
+}
+
+func (d *Disp) editConfigXMLInternal(sid, config_target, default_operation, test_option, error_option, config string) (string, error) {
+	if err := d.checkExclusive(sid); err != nil {
+		return "", err
+	}
 	sess, err := d.smgr.Get(d.ctx, sid)
 	if err != nil {
 		return "", err
@@ -2263,6 +2676,36 @@ func (d *Disp) EditConfigXML(sid, config_target, default_operation, test_option,
 	return "", sess.EditConfigXML(d.ctx, config_target, default_operation, test_option, error_option, config)
 }
 
+// EditConfigJSON is as EditConfigXML, but config is an RFC 7951 or plain
+// JSON document, as given by encoding.
+func (d *Disp) EditConfigJSON(
+	sid, config_target, default_operation, test_option, error_option,
+	encoding, config string,
+) (string, error) {
+	args := d.editConfigCommandArgs(config_target, default_operation,
+		editConfigJSONNames(config))
+	return d.accountCmdWrapStrErr(args, func() (interface{}, error) {
+		return d.editConfigJSONInternal(sid, config_target,
+			default_operation, test_option, error_option, encoding, config)
+	})
+}
+
+func (d *Disp) editConfigJSONInternal(
+	sid, config_target, default_operation, test_option, error_option,
+	encoding, config string,
+) (string, error) {
+	if err := d.checkExclusive(sid); err != nil {
+		return "", err
+	}
+	sess, err := d.smgr.Get(d.ctx, sid)
+	if err != nil {
+		return "", err
+	}
+
+	return "", sess.EditConfigJSON(d.ctx, config_target, default_operation,
+		test_option, error_option, encoding, config)
+}
+
 func (d *Disp) copyConfigInternal(
 	sid,
 	sourceDatastore,
@@ -2272,6 +2715,9 @@ func (d *Disp) copyConfigInternal(
 	targetDatastore,
 	targetURL string,
 ) (string, error) {
+	if err := d.checkExclusive(sid); err != nil {
+		return "", err
+	}
 	sess, err := d.smgr.Get(d.ctx, sid)
 	if err != nil {
 		return "", err
@@ -2302,11 +2748,16 @@ func (d *Disp) CopyConfig(
 		d.ctx.Wlog.Println("copy-config by " + d.ctx.User)
 	}
 
-	return d.accountCmdWrapStrErr(args, func() (interface{}, error) {
-		return d.copyConfigInternal(
-			sid, sourceDatastore, sourceEncoding, sourceConfig,
-			sourceURL, targetDatastore, targetURL)
-	})
+	target := rpc.CANDIDATE
+	if targetDatastore == "running" {
+		target = rpc.RUNNING
+	}
+	return d.accountCmdWrapStrErr(args, d.audited(AuditCopyConfig, sid,
+		target, func() (interface{}, error) {
+			return d.copyConfigInternal(
+				sid, sourceDatastore, sourceEncoding, sourceConfig,
+				sourceURL, targetDatastore, targetURL)
+		}))
 
 }
 func (d *Disp) SetConfigDebug(sid, logName, level string) (string, error) {
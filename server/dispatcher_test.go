@@ -720,6 +720,45 @@ func TestGetCompletionsOutput(t *testing.T) {
 	checkGetCompletions(t, false)
 }
 
+const patternHelpCompletionsSchema = `
+container top {
+	leaf address {
+		configd:help "Address";
+		type string {
+			pattern '[0-9.]+' {
+				configd:pattern-help "<x.x.x.x>";
+			}
+		}
+	}
+	leaf name {
+		configd:help "Name";
+		type string;
+	}
+}
+`
+
+func TestGetCompletionsPatternHelp(t *testing.T) {
+	a := auth.TestAutherAllowAll()
+	d := newTestDispatcherWithCustomAuth(
+		t, a,
+		patternHelpCompletionsSchema, emptyConfig,
+		false, /* not configd user, so our auther gets used! */
+		false /* not in secrets group */)
+
+	dispTestSetupSession(t, d, testSID)
+
+	comps := dispTestGetCompletions(t, d, testSID, "top/address", true)
+	if _, ok := comps["<x.x.x.x>"]; !ok {
+		t.Fatalf("Pattern help missing from completions: %v", comps)
+	}
+	if _, ok := comps["<text>"]; ok {
+		t.Fatalf("Generic placeholder not replaced: %v", comps)
+	}
+
+	comps = dispTestGetCompletions(t, d, testSID, "top/name", true)
+	checkCompletionsMap(t, comps, map[string]string{"<text>": "Name"})
+}
+
 func TestRollbackCommandAuthz(t *testing.T) {
 	a := auth.TestAutherAllowAll()
 	d := newTestDispatcherWithCustomAuth(
@@ -992,3 +1031,76 @@ func TestReadConfigFileAuthorisation(t *testing.T) {
 		}
 	}
 }
+
+const otherTestSID = "OTHER_TEST_SID"
+
+func dispTestCompareSessions(
+	t *testing.T,
+	d *server.Disp,
+	sidA, sidB, expOut string,
+) {
+	t.Helper()
+	act, err := d.CompareSessions(sidA, sidB)
+	if err != nil {
+		t.Fatalf("\nUnable to compare sessions. \nError: %s\n", err.Error())
+	}
+	if act != expOut {
+		t.Fatalf("Exp:\n%s\nGot:\n%s\n", expOut, act)
+	}
+}
+
+func TestCompareSessionsNoDiff(t *testing.T) {
+	a := auth.TestAutherAllowAll()
+	d := newTestDispatcherWithCustomAuth(
+		t, a,
+		showConfigWithContextDiffsTestSchema,
+		showConfigWithContextDiffsConfig,
+		false, /* not configd user, so our auther gets used! */
+		false /* not in secrets group */)
+
+	dispTestSetupSession(t, d, testSID)
+	dispTestSetupSession(t, d, otherTestSID)
+
+	dispTestCompareSessions(t, d, testSID, otherTestSID, "")
+
+	assertCommandAaaNoSecrets(t, a, []string{"compare"})
+}
+
+func TestCompareSessionsDiff(t *testing.T) {
+	a := auth.TestAutherAllowAll()
+	d := newTestDispatcherWithCustomAuth(
+		t, a,
+		showConfigWithContextDiffsTestSchema,
+		showConfigWithContextDiffsConfig,
+		false, /* not configd user, so our auther gets used! */
+		false /* not in secrets group */)
+
+	dispTestSetupSession(t, d, testSID)
+	dispTestSetupSession(t, d, otherTestSID)
+
+	dispTestSet(t, d, otherTestSID, "interfaces/dataplane/dp0s2")
+	dispTestDelete(t, d, otherTestSID, "protocols")
+
+	// Set/Delete will have generated some requests
+	clearAllCmdRequestsAndUserAuditLogs(a)
+
+	// Changes in the second session are reported relative to the first
+	expOut := FormatCtxDiffHunk("interfaces", compareSessionChangesConfigAdd) +
+		FormatCtxDiffHunk("", compareSessionChangesConfigDel)
+
+	dispTestCompareSessions(t, d, testSID, otherTestSID, expOut)
+
+	assertCommandAaaNoSecrets(t, a, []string{"compare"})
+}
+
+func TestCompareSessionsNonExistentSession(t *testing.T) {
+	d := newTestDispatcher(t, auth.TestAutherAllowAll(),
+		showConfigWithContextDiffsTestSchema,
+		showConfigWithContextDiffsConfig)
+
+	dispTestSetupSession(t, d, testSID)
+
+	if _, err := d.CompareSessions(testSID, otherTestSID); err == nil {
+		t.Fatalf("Comparison with non-existent session should fail")
+	}
+}
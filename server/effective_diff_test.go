@@ -0,0 +1,61 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/danos/config/auth"
+)
+
+const effectiveDiffSchema = `
+container feature {
+	presence "Enables the feature";
+	leaf mode {
+		type string;
+		default "fast";
+	}
+}`
+
+func TestEffectiveDiffIncludesDefaults(t *testing.T) {
+	a := auth.TestAutherAllowAll()
+	d := newTestDispatcher(t, a, effectiveDiffSchema, emptyconfig)
+	dispTestSetupSession(t, d, testSID)
+	dispTestSet(t, d, testSID, "feature")
+
+	changes, err := d.CompareSessionChanges(testSID)
+	if err != nil {
+		t.Fatalf("Unable to compare session changes: %s", err)
+	}
+	if strings.Contains(changes, "mode") {
+		t.Fatalf("Session changes should not include defaults:\n%s",
+			changes)
+	}
+
+	out, err := d.EffectiveDiff(testSID, "")
+	if err != nil {
+		t.Fatalf("Unable to get effective diff: %s", err)
+	}
+	if !strings.Contains(out, "feature") ||
+		!strings.Contains(out, "mode fast") {
+		t.Fatalf("Effective diff should include default change:\n%s", out)
+	}
+}
+
+func TestEffectiveDiffNoChanges(t *testing.T) {
+	a := auth.TestAutherAllowAll()
+	d := newTestDispatcher(t, a, effectiveDiffSchema, emptyconfig)
+	dispTestSetupSession(t, d, testSID)
+
+	out, err := d.EffectiveDiff(testSID, "")
+	if err != nil {
+		t.Fatalf("Unable to get effective diff: %s", err)
+	}
+	if strings.TrimSpace(out) != "" {
+		t.Fatalf("Unexpected effective diff:\n%s", out)
+	}
+	assertCommandAaaNoSecrets(t, a, []string{"compare", "-all"})
+}
@@ -0,0 +1,193 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/danos/mgmterror"
+)
+
+// Exclusive configuration
+//
+// A session lock only stops others using that one session.  'configure
+// exclusive' takes a lock over the whole configuration instead: while a
+// session holds it, no other session may set, delete or commit.  Others
+// may wait for it to be released with WaitExclusiveRelease, and see who
+// holds it with ExclusiveStatus, eg in their prompt.  The lock is released
+// by its holder, by a superuser, when the holding session is torn down,
+// or once the process that took it has gone.  Commits configd makes
+// itself, such as reverting an unconfirmed commit, are not blocked.
+
+type exclusiveHolder struct {
+	sid   string
+	user  string
+	pid   int32
+	since time.Time
+}
+
+var exclusiveConfig = struct {
+	mu     sync.Mutex
+	holder *exclusiveHolder
+	// Closed, and replaced, each time the lock is released
+	released chan struct{}
+}{released: make(chan struct{})}
+
+// releaseExclusiveLocked releases the lock, waking anyone waiting for it.
+// exclusiveConfig.mu must be held.
+func releaseExclusiveLocked() {
+	exclusiveConfig.holder = nil
+	close(exclusiveConfig.released)
+	exclusiveConfig.released = make(chan struct{})
+}
+
+// currentExclusiveLocked returns who holds the lock, if anyone, releasing
+// it first if its process has gone.  exclusiveConfig.mu must be held.
+func currentExclusiveLocked() *exclusiveHolder {
+	h := exclusiveConfig.holder
+	if h != nil && h.pid > 0 &&
+		syscall.Kill(int(h.pid), 0) == syscall.ESRCH {
+		releaseExclusiveLocked()
+		return nil
+	}
+	return h
+}
+
+// releaseExclusiveForSession releases the lock if sid holds it.
+func releaseExclusiveForSession(sid string) {
+	exclusiveConfig.mu.Lock()
+	defer exclusiveConfig.mu.Unlock()
+	if h := exclusiveConfig.holder; h != nil && h.sid == sid {
+		releaseExclusiveLocked()
+	}
+}
+
+func newExclusiveDeniedError(h *exclusiveHolder) error {
+	err := mgmterror.NewLockDeniedError(h.sid)
+	err.Message = "configuration is held exclusively by " + h.user +
+		" (session " + h.sid + ")"
+	return err
+}
+
+// checkExclusive returns an error if another session holds the
+// configuration exclusively.
+func (d *Disp) checkExclusive(sid string) error {
+	if d.ctx.Configd {
+		return nil
+	}
+	exclusiveConfig.mu.Lock()
+	defer exclusiveConfig.mu.Unlock()
+	if h := currentExclusiveLocked(); h != nil && h.sid != sid {
+		return newExclusiveDeniedError(h)
+	}
+	return nil
+}
+
+// ConfigureExclusive takes the exclusive configuration lock for the
+// session.
+func (d *Disp) ConfigureExclusive(sid string) (bool, error) {
+	args := d.newCommandArgsForAaa("configure-exclusive", nil, nil)
+	if !d.authCommand(args) {
+		return false, mgmterror.NewAccessDeniedApplicationError()
+	}
+	if _, err := d.smgr.Get(d.ctx, sid); err != nil {
+		return false, err
+	}
+
+	return d.accountCmdWrapBoolErr(args, func() (interface{}, error) {
+		exclusiveConfig.mu.Lock()
+		defer exclusiveConfig.mu.Unlock()
+		if h := currentExclusiveLocked(); h != nil {
+			if h.sid == sid {
+				return true, nil
+			}
+			return false, newExclusiveDeniedError(h)
+		}
+		exclusiveConfig.holder = &exclusiveHolder{
+			sid:   sid,
+			user:  d.ctx.User,
+			pid:   d.ctx.Pid,
+			since: time.Now(),
+		}
+		return true, nil
+	})
+}
+
+// ReleaseExclusive releases the exclusive configuration lock held by the
+// session.  Superusers may release it whichever session holds it.
+func (d *Disp) ReleaseExclusive(sid string) (bool, error) {
+	args := d.newCommandArgsForAaa("release-exclusive", nil, nil)
+	if !d.authCommand(args) {
+		return false, mgmterror.NewAccessDeniedApplicationError()
+	}
+
+	return d.accountCmdWrapBoolErr(args, func() (interface{}, error) {
+		exclusiveConfig.mu.Lock()
+		defer exclusiveConfig.mu.Unlock()
+		h := currentExclusiveLocked()
+		if h == nil {
+			err := mgmterror.NewOperationFailedApplicationError()
+			err.Message = "Configuration is not held exclusively"
+			return false, err
+		}
+		if h.sid != sid && !d.ctx.Superuser {
+			return false, newExclusiveDeniedError(h)
+		}
+		releaseExclusiveLocked()
+		return true, nil
+	})
+}
+
+// ExclusiveStatus returns who holds the exclusive configuration lock, and
+// whether it is the session, or an empty map if nobody does.
+func (d *Disp) ExclusiveStatus(sid string) (map[string]string, error) {
+	exclusiveConfig.mu.Lock()
+	defer exclusiveConfig.mu.Unlock()
+	status := make(map[string]string)
+	h := currentExclusiveLocked()
+	if h == nil {
+		return status, nil
+	}
+	status["session"] = h.sid
+	status["user"] = h.user
+	status["pid"] = strconv.Itoa(int(h.pid))
+	status["since"] = h.since.Format(time.RFC3339)
+	status["held-by-session"] = strconv.FormatBool(h.sid == sid)
+	return status, nil
+}
+
+// How often waiters check whether the holder's process has gone
+const exclusiveRecheckInterval = 5 * time.Second
+
+// WaitExclusiveRelease waits up to timeout seconds for nobody to hold the
+// exclusive configuration lock, returning whether nobody does.  Waiters
+// are all woken when it is released, so the first to take it gets it.
+func (d *Disp) WaitExclusiveRelease(timeout int) (bool, error) {
+	timer := time.NewTimer(time.Duration(timeout) * time.Second)
+	defer timer.Stop()
+	recheck := time.NewTicker(exclusiveRecheckInterval)
+	defer recheck.Stop()
+	for {
+		exclusiveConfig.mu.Lock()
+		h := currentExclusiveLocked()
+		released := exclusiveConfig.released
+		exclusiveConfig.mu.Unlock()
+		if h == nil {
+			return true, nil
+		}
+
+		select {
+		case <-released:
+		case <-recheck.C:
+		case <-timer.C:
+			return false, nil
+		case <-d.ctx.Done():
+			return false, nil
+		}
+	}
+}
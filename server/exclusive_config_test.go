@@ -0,0 +1,141 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/danos/configd/session/sessiontest"
+)
+
+const exclusiveConfigSchema = `
+container cont {
+	leaf value {
+		type string;
+	}
+}`
+
+func TestConfigureExclusive(t *testing.T) {
+	srv, _ := sessiontest.NewTestSpec(t).
+		SetSingleSchema(exclusiveConfigSchema).
+		Init()
+	d := &Disp{smgr: srv.Smgr, cmgr: srv.Cmgr, ms: srv.Ms,
+		msFull: srv.MsFull, ctx: srv.Ctx}
+	for _, sid := range []string{"one", "two"} {
+		if _, err := d.SessionSetup(sid); err != nil {
+			t.Fatalf("Unable to set up session %s: %s", sid, err)
+		}
+	}
+	defer releaseExclusiveForSession("one")
+
+	if _, err := d.ConfigureExclusive("one"); err != nil {
+		t.Fatalf("Unable to configure exclusively: %s", err)
+	}
+	if _, err := d.ConfigureExclusive("two"); err == nil {
+		t.Fatal("Second session also configuring exclusively")
+	}
+	if _, err := d.Set("two", "cont/value/foo"); err == nil {
+		t.Fatal("Set allowed from another session")
+	}
+	if _, err := d.Delete("two", "cont/value"); err == nil {
+		t.Fatal("Delete allowed from another session")
+	}
+	if _, err := d.Commit("two", "", false); err == nil {
+		t.Fatal("Commit allowed from another session")
+	}
+	if _, err := d.Set("one", "cont/value/foo"); err != nil {
+		t.Fatalf("Set not allowed from holding session: %s", err)
+	}
+
+	status, _ := d.ExclusiveStatus("two")
+	if status["session"] != "one" || status["held-by-session"] != "false" {
+		t.Fatalf("Unexpected status: %v", status)
+	}
+	if released, _ := d.WaitExclusiveRelease(0); released {
+		t.Fatal("Held configuration reported as released")
+	}
+
+	waited := make(chan bool)
+	go func() {
+		released, _ := d.WaitExclusiveRelease(10)
+		waited <- released
+	}()
+	if _, err := d.ReleaseExclusive("one"); err != nil {
+		t.Fatalf("Unable to release: %s", err)
+	}
+	if !<-waited {
+		t.Fatal("Waiter not told of release")
+	}
+	if _, err := d.Set("two", "cont/value/bar"); err != nil {
+		t.Fatalf("Set not allowed once released: %s", err)
+	}
+	if status, _ := d.ExclusiveStatus("two"); len(status) != 0 {
+		t.Fatalf("Unexpected status once released: %v", status)
+	}
+}
+
+func TestConfigureExclusiveDeniesEdits(t *testing.T) {
+	srv, _ := sessiontest.NewTestSpec(t).
+		SetSingleSchema(exclusiveConfigSchema).
+		Init()
+	d := &Disp{smgr: srv.Smgr, cmgr: srv.Cmgr, ms: srv.Ms,
+		msFull: srv.MsFull, ctx: srv.Ctx}
+	for _, sid := range []string{"one", "two"} {
+		if _, err := d.SessionSetup(sid); err != nil {
+			t.Fatalf("Unable to set up session %s: %s", sid, err)
+		}
+	}
+	defer releaseExclusiveForSession("one")
+
+	if _, err := d.ConfigureExclusive("one"); err != nil {
+		t.Fatalf("Unable to configure exclusively: %s", err)
+	}
+
+	const batch = `[{"op":"set","path":"cont/value/foo"}]`
+	const config = `{"cont":{"value":"foo"}}`
+	edits := map[string]func() error{
+		"SetMultiple": func() error {
+			_, err := d.SetMultiple("two", batch)
+			return err
+		},
+		"Load": func() error {
+			_, err := d.Load("two", "/no/such/config")
+			return err
+		},
+		"Merge": func() error {
+			_, err := d.Merge("two", "/no/such/config")
+			return err
+		},
+		"EditConfigXML": func() error {
+			_, err := d.EditConfigXML("two", "candidate", "merge", "",
+				"", "<config/>")
+			return err
+		},
+		"EditConfigJSON": func() error {
+			_, err := d.EditConfigJSON("two", "candidate", "merge", "",
+				"", "json", config)
+			return err
+		},
+		"CopyConfig": func() error {
+			_, err := d.CopyConfig("two", "", "json", config, "",
+				"candidate", "")
+			return err
+		},
+	}
+	for name, edit := range edits {
+		err := edit()
+		if err == nil || !strings.Contains(err.Error(), "held exclusively") {
+			t.Errorf("%s not denied by exclusive session: %v", name, err)
+		}
+	}
+
+	if _, err := d.ReleaseExclusive("one"); err != nil {
+		t.Fatalf("Unable to release: %s", err)
+	}
+	if _, err := d.SetMultiple("two", batch); err != nil {
+		t.Fatalf("SetMultiple not allowed once released: %s", err)
+	}
+}
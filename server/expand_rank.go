@@ -0,0 +1,175 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/danos/config/schema"
+	"github.com/danos/configd/common"
+	"github.com/danos/utils/pathutil"
+)
+
+// Ranking of ambiguous expansions
+//
+// When an abbreviated path element matches several nodes we rank the
+// candidates so the most likely ones can be offered first: closest to what
+// was typed, then most often expanded to.  Optionally, where one candidate
+// is a strict prefix of all the others (eg 'address' and 'address-group')
+// we select it, as there is no other way to type it unambiguously short of
+// typing it in full.
+
+type expandOpts struct {
+	// Select the candidate that is a strict prefix of all others
+	autoSelect bool
+
+	// Set to the ranked candidates if expansion is ambiguous
+	candidates []string
+}
+
+type expandUsage struct {
+	mu     sync.Mutex
+	counts map[schema.Node]uint64
+}
+
+var expandUsageCounts = &expandUsage{counts: make(map[schema.Node]uint64)}
+
+func (u *expandUsage) record(sch schema.Node) {
+	u.mu.Lock()
+	u.counts[sch]++
+	u.mu.Unlock()
+}
+
+func (u *expandUsage) get(sch schema.Node) uint64 {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.counts[sch]
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(minInt(prev[j]+1, cur[j-1]+1), prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// rankExpandMatches orders the candidates by edit distance from what was
+// typed, then by how often each has been expanded to, then by name.
+func rankExpandMatches(val string, matches []schema.Node) []schema.Node {
+	type ranked struct {
+		sch   schema.Node
+		dist  int
+		usage uint64
+	}
+	rs := make([]ranked, 0, len(matches))
+	for _, m := range matches {
+		rs = append(rs, ranked{
+			sch:   m,
+			dist:  editDistance(val, m.Name()),
+			usage: expandUsageCounts.get(m),
+		})
+	}
+	sort.SliceStable(rs, func(i, j int) bool {
+		switch {
+		case rs[i].dist != rs[j].dist:
+			return rs[i].dist < rs[j].dist
+		case rs[i].usage != rs[j].usage:
+			return rs[i].usage > rs[j].usage
+		}
+		return rs[i].sch.Name() < rs[j].sch.Name()
+	})
+
+	out := make([]schema.Node, 0, len(rs))
+	for _, r := range rs {
+		out = append(out, r.sch)
+	}
+	return out
+}
+
+// strictPrefixMatch returns the candidate that is a strict prefix of every
+// other candidate, if there is one.
+func strictPrefixMatch(matches []schema.Node) schema.Node {
+	for _, m := range matches {
+		isPrefix := true
+		for _, o := range matches {
+			if o == m {
+				continue
+			}
+			if o.Name() == m.Name() || !strings.HasPrefix(o.Name(), m.Name()) {
+				isPrefix = false
+				break
+			}
+		}
+		if isPrefix {
+			return m
+		}
+	}
+	return nil
+}
+
+func expandMatchNames(matches []schema.Node) []string {
+	names := make([]string, 0, len(matches))
+	for _, m := range matches {
+		names = append(names, m.Name())
+	}
+	return names
+}
+
+// ExpandWithOptions - as ExpandWithPrefix, with the following flags:
+//
+//	AutoSelect - where one candidate for an ambiguous element is a strict
+//	             prefix of all the others, expand to that candidate.
+func (d *Disp) ExpandWithOptions(
+	path, prefix string, pos int,
+	flags map[string]interface{},
+) (string, error) {
+	opts := &expandOpts{}
+	if v, ok := flags["AutoSelect"].(bool); ok {
+		opts.autoSelect = v
+	}
+	ps, err := d.expandPath(pathutil.Makepath(path), prefix, pos+1, opts)
+	if err != nil {
+		return "", common.FormatConfigPathError(err)
+	}
+	return pathutil.Pathstr(ps), nil
+}
+
+// ExpandCandidates returns the candidates for the first ambiguous element
+// of the path, most likely first, or an empty list if the path isn't
+// ambiguous.  This allows the CLI to offer the ranked list when
+// ExpandWithPrefix reports ambiguity.
+func (d *Disp) ExpandCandidates(path, prefix string, pos int) ([]string, error) {
+	opts := &expandOpts{}
+	_, err := d.expandPath(pathutil.Makepath(path), prefix, pos+1, opts)
+	if opts.candidates != nil {
+		return opts.candidates, nil
+	}
+	if err != nil {
+		return []string{}, common.FormatConfigPathError(err)
+	}
+	return []string{}, nil
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/danos/config/auth"
+	"github.com/danos/configd/server"
+)
+
+const expandRankSchema = `
+container address {
+	presence "For testing";
+}
+container address-group {
+	presence "For testing";
+}
+container admin {
+	presence "For testing";
+}
+container beta1 {
+	presence "For testing";
+}
+container beta2 {
+	presence "For testing";
+}`
+
+func checkExpandCandidates(t *testing.T, d *server.Disp, path string, exp []string) {
+	t.Helper()
+	cands, err := d.ExpandCandidates(path, server.NoPrefix, server.InvalidPos)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(cands, exp) {
+		t.Fatalf("Unexpected candidates for %s.\nExp: %v\nGot: %v",
+			path, exp, cands)
+	}
+}
+
+func TestExpandCandidatesRankedByDistance(t *testing.T) {
+	d := newTestDispatcher(t, auth.TestAutherAllowAll(),
+		expandRankSchema, emptyconfig)
+
+	checkExpandCandidates(t, d, "/ad",
+		[]string{"admin", "address", "address-group"})
+}
+
+func TestExpandCandidatesRankedByUsage(t *testing.T) {
+	d := newTestDispatcher(t, auth.TestAutherAllowAll(),
+		expandRankSchema, emptyconfig)
+
+	checkExpandCandidates(t, d, "/bet", []string{"beta1", "beta2"})
+	if _, err := d.Expand("/beta2"); err != nil {
+		t.Fatalf("Unable to expand: %s", err)
+	}
+	checkExpandCandidates(t, d, "/bet", []string{"beta2", "beta1"})
+}
+
+func TestExpandCandidatesNotAmbiguous(t *testing.T) {
+	d := newTestDispatcher(t, auth.TestAutherAllowAll(),
+		expandRankSchema, emptyconfig)
+
+	checkExpandCandidates(t, d, "/adm", []string{})
+}
+
+func TestExpandAutoSelectStrictPrefix(t *testing.T) {
+	d := newTestDispatcher(t, auth.TestAutherAllowAll(),
+		expandRankSchema, emptyconfig)
+
+	if _, err := d.ExpandWithOptions("/addr", server.NoPrefix,
+		server.InvalidPos, nil); err == nil {
+		t.Fatalf("Expansion should be ambiguous without auto-select")
+	}
+
+	out, err := d.ExpandWithOptions("/addr", server.NoPrefix,
+		server.InvalidPos, map[string]interface{}{"AutoSelect": true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if out != "/address" {
+		t.Fatalf("Unexpected expansion: %s", out)
+	}
+
+	// 'ad' also matches 'admin', so there's nothing to select.
+	if _, err := d.ExpandWithOptions("/ad", server.NoPrefix,
+		server.InvalidPos, map[string]interface{}{"AutoSelect": true}); err == nil {
+		t.Fatalf("Expansion should be ambiguous")
+	}
+}
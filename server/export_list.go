@@ -0,0 +1,287 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/danos/config/schema"
+	"github.com/danos/configd"
+	"github.com/danos/configd/rpc"
+	"github.com/danos/mgmterror"
+	"github.com/danos/utils/pathutil"
+)
+
+// Export of YANG list data as CSV, TSV or an aligned text table, with one
+// row per list entry and one column per leaf / leaf-list.  Key leaves come
+// first, followed by the remaining leaves in schema order.  Leaves inside
+// (non-list) containers are included as 'container.leaf' columns if
+// flattening is requested.  Nested lists can't sensibly be represented in
+// a single row, so are always omitted, as are columns with no values in
+// any entry.
+
+const (
+	ExportFormatCSV   = "csv"
+	ExportFormatTSV   = "tsv"
+	ExportFormatTable = "table"
+
+	// Separator for leaf-list values within a single cell
+	exportLeafListSep = ";"
+)
+
+func isExportFormat(format string) bool {
+	switch format {
+	case ExportFormatCSV, ExportFormatTSV, ExportFormatTable:
+		return true
+	}
+	return false
+}
+
+// Defaults - include default values
+// State - include operational state (config false) nodes
+// Flatten - include leaves in nested containers as extra columns
+type exportOpts struct {
+	defaults, state, flatten bool
+}
+
+func newExportOpts(flags map[string]interface{}) *exportOpts {
+	opts := &exportOpts{}
+	for flag, val := range flags {
+		v, ok := val.(bool)
+		if !ok {
+			continue
+		}
+		switch flag {
+		case "Defaults":
+			opts.defaults = v
+		case "State":
+			opts.state = v
+		case "Flatten":
+			opts.flatten = v
+		}
+	}
+	return opts
+}
+
+func (d *Disp) ExportList(
+	db rpc.DB,
+	sid, path, format string,
+	flags map[string]interface{},
+) (string, error) {
+	ps := pathutil.Makepath(path)
+	opts := newExportOpts(flags)
+
+	args := d.showCommandArgs(ps, opts.defaults)
+	if !d.authCommand(args) {
+		return "", mgmterror.NewAccessDeniedApplicationError()
+	}
+
+	return d.accountCmdWrapStrErr(args, func() (interface{}, error) {
+		return d.exportListInternal(db, sid, ps, format, opts)
+	})
+}
+
+func (d *Disp) exportListInternal(
+	db rpc.DB,
+	sid string,
+	ps []string,
+	format string,
+	opts *exportOpts,
+) (string, error) {
+	if !isExportFormat(format) {
+		err := mgmterror.NewInvalidValueApplicationError()
+		err.Message = fmt.Sprintf(
+			"Unsupported export format '%s'. Use <%s|%s|%s>.", format,
+			ExportFormatCSV, ExportFormatTSV, ExportFormatTable)
+		return "", err
+	}
+
+	tmpl, err := d.schemaPathDescendant(ps)
+	if err != nil {
+		return "", err
+	}
+	list, ok := tmpl.Node.(schema.List)
+	if !ok || tmpl.Val {
+		err := mgmterror.NewInvalidValueApplicationError()
+		err.Message = fmt.Sprintf("%s is not a list",
+			mgmterror.ErrPath(ps))
+		return "", err
+	}
+
+	treeFlags := map[string]interface{}{
+		"Defaults": opts.defaults,
+		"Secrets":  configd.ShowSecrets(d.ctx),
+	}
+	// ExportList has already been authorized and is being accounted, so
+	// use the internal tree gets rather than TreeGet and TreeGetFull.
+	var out string
+	if opts.state || db == rpc.OPERATIONAL {
+		out, err, _ = d.TreeGetFullWithWarnings(db, sid,
+			pathutil.Pathstr(ps), "json", treeFlags)
+	} else {
+		out, err = d.treeGetInternal(db, sid, pathutil.Pathstr(ps), "json",
+			treeFlags)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	var tree map[string]interface{}
+	dec := json.NewDecoder(strings.NewReader(out))
+	dec.UseNumber()
+	if err := dec.Decode(&tree); err != nil {
+		return "", err
+	}
+	entries, _ := tree[list.Name()].([]interface{})
+	if len(entries) == 0 {
+		return "", nil
+	}
+
+	header, rows := exportRows(list, entries, opts.flatten)
+	return renderExport(format, header, rows)
+}
+
+// exportColumns - schema paths, relative to a list entry, of the leaves
+// that make up the columns of the export.
+func exportColumns(list schema.List, flatten bool) [][]string {
+	cols := make([][]string, 0)
+	for _, key := range list.Keys() {
+		cols = append(cols, []string{key})
+	}
+
+	var addChildren func(sch schema.Node, prefix []string, skip []string)
+	addChildren = func(sch schema.Node, prefix []string, skip []string) {
+		for _, c := range sch.Children() {
+			child := c.(schema.Node)
+			if isElemOf(skip, child.Name()) {
+				continue
+			}
+			path := append(append([]string{}, prefix...), child.Name())
+			switch child.(type) {
+			case schema.Leaf, schema.LeafList:
+				cols = append(cols, path)
+			case schema.Container:
+				if flatten {
+					addChildren(child, path, nil)
+				}
+			}
+		}
+	}
+	addChildren(list, nil, list.Keys())
+
+	return cols
+}
+
+func exportLookup(entry interface{}, path []string) (interface{}, bool) {
+	for _, elem := range path {
+		m, ok := entry.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if entry, ok = m[elem]; !ok {
+			return nil, false
+		}
+	}
+	return entry, true
+}
+
+func exportValue(val interface{}) string {
+	switch v := val.(type) {
+	case nil:
+		return ""
+	case []interface{}:
+		vals := make([]string, 0, len(v))
+		for _, elem := range v {
+			vals = append(vals, exportValue(elem))
+		}
+		return strings.Join(vals, exportLeafListSep)
+	}
+	return fmt.Sprint(val)
+}
+
+func exportRows(
+	list schema.List,
+	entries []interface{},
+	flatten bool,
+) ([]string, [][]string) {
+	cols := exportColumns(list, flatten)
+
+	// Work out which columns have a value in at least one entry, so we
+	// can drop the others.
+	present := make([]bool, len(cols))
+	for _, entry := range entries {
+		for i, col := range cols {
+			if _, ok := exportLookup(entry, col); ok {
+				present[i] = true
+			}
+		}
+	}
+
+	header := make([]string, 0, len(cols))
+	for i, col := range cols {
+		if present[i] {
+			header = append(header, strings.Join(col, "."))
+		}
+	}
+
+	rows := make([][]string, 0, len(entries))
+	for _, entry := range entries {
+		row := make([]string, 0, len(header))
+		for i, col := range cols {
+			if !present[i] {
+				continue
+			}
+			val, _ := exportLookup(entry, col)
+			row = append(row, exportValue(val))
+		}
+		rows = append(rows, row)
+	}
+	return header, rows
+}
+
+func renderExport(format string, header []string, rows [][]string) (string, error) {
+	var buf bytes.Buffer
+
+	if format == ExportFormatTable {
+		tw := tabwriter.NewWriter(&buf, 0, 8, 2, ' ', 0)
+		underline := make([]string, 0, len(header))
+		for _, col := range header {
+			underline = append(underline, strings.Repeat("-", len(col)))
+		}
+		fmt.Fprintln(tw, strings.Join(header, "\t"))
+		fmt.Fprintln(tw, strings.Join(underline, "\t"))
+		for _, row := range rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		if err := tw.Flush(); err != nil {
+			return "", err
+		}
+		// Empty trailing cells leave padding at the end of the line
+		lines := strings.SplitAfter(buf.String(), "\n")
+		for i, line := range lines {
+			lines[i] = strings.TrimRight(line, " \n")
+			if strings.HasSuffix(line, "\n") {
+				lines[i] += "\n"
+			}
+		}
+		return strings.Join(lines, ""), nil
+	}
+
+	w := csv.NewWriter(&buf)
+	if format == ExportFormatTSV {
+		w.Comma = '\t'
+	}
+	w.Write(header)
+	w.WriteAll(rows)
+	if err := w.Error(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,186 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server_test
+
+import (
+	"testing"
+
+	"github.com/danos/config/auth"
+	. "github.com/danos/config/testutils"
+	"github.com/danos/configd/rpc"
+	"github.com/danos/configd/server"
+)
+
+const exportListSchema = `
+container interfaces {
+	list dataplane {
+		key tagnode;
+		leaf tagnode {
+			type string;
+		}
+		leaf-list address {
+			type string;
+			ordered-by user;
+		}
+		leaf description {
+			type string;
+		}
+		leaf mtu {
+			type uint16;
+			default 1500;
+		}
+		container options {
+			leaf speed {
+				type string;
+			}
+		}
+		list vif {
+			key tagnode;
+			leaf tagnode {
+				type uint16;
+			}
+		}
+	}
+}`
+
+var exportListConfig = Root(
+	Cont("interfaces",
+		List("dataplane",
+			ListEntry("dp0s1",
+				LeafList("address",
+					LeafListEntry("10.0.0.1/24"),
+					LeafListEntry("10.0.1.1/24")),
+				Leaf("description", "uplink, primary"),
+				Cont("options",
+					Leaf("speed", "1g")),
+				List("vif",
+					ListEntry("10"))),
+			ListEntry("dp0s2",
+				Leaf("mtu", "9000")))))
+
+func dispTestExportList(
+	t *testing.T,
+	d *server.Disp,
+	path, format string,
+	flags map[string]interface{},
+	expOut string,
+) {
+	t.Helper()
+	out, err := d.ExportList(rpc.RUNNING, testSID, path, format, flags)
+	if err != nil {
+		t.Fatalf("Unable to export list: %s", err)
+	}
+	if out != expOut {
+		t.Fatalf("Exp:\n%s\nGot:\n%s\n", expOut, out)
+	}
+}
+
+func TestExportListCSV(t *testing.T) {
+	d := newTestDispatcher(t, auth.TestAutherAllowAll(),
+		exportListSchema, exportListConfig)
+
+	expOut := "tagnode,address,description,mtu\n" +
+		"dp0s1,10.0.0.1/24;10.0.1.1/24,\"uplink, primary\",\n" +
+		"dp0s2,,,9000\n"
+
+	dispTestExportList(t, d, "interfaces/dataplane", server.ExportFormatCSV,
+		nil, expOut)
+}
+
+func TestExportListTSVWithDefaults(t *testing.T) {
+	d := newTestDispatcher(t, auth.TestAutherAllowAll(),
+		exportListSchema, exportListConfig)
+
+	expOut := "tagnode\taddress\tdescription\tmtu\n" +
+		"dp0s1\t10.0.0.1/24;10.0.1.1/24\tuplink, primary\t1500\n" +
+		"dp0s2\t\t\t9000\n"
+
+	dispTestExportList(t, d, "interfaces/dataplane", server.ExportFormatTSV,
+		map[string]interface{}{"Defaults": true}, expOut)
+}
+
+func TestExportListFlattened(t *testing.T) {
+	d := newTestDispatcher(t, auth.TestAutherAllowAll(),
+		exportListSchema, exportListConfig)
+
+	// Nested list (vif) is never included.
+	expOut := "tagnode,address,description,mtu,options.speed\n" +
+		"dp0s1,10.0.0.1/24;10.0.1.1/24,\"uplink, primary\",,1g\n" +
+		"dp0s2,,,9000,\n"
+
+	dispTestExportList(t, d, "interfaces/dataplane", server.ExportFormatCSV,
+		map[string]interface{}{"Flatten": true}, expOut)
+}
+
+func TestExportListTable(t *testing.T) {
+	d := newTestDispatcher(t, auth.TestAutherAllowAll(),
+		exportListSchema, exportListConfig)
+
+	expOut := "tagnode  address                  description      mtu\n" +
+		"-------  -------                  -----------      ---\n" +
+		"dp0s1    10.0.0.1/24;10.0.1.1/24  uplink, primary\n" +
+		"dp0s2                                              9000\n"
+
+	dispTestExportList(t, d, "interfaces/dataplane",
+		server.ExportFormatTable, nil, expOut)
+}
+
+func TestExportListNotAList(t *testing.T) {
+	d := newTestDispatcher(t, auth.TestAutherAllowAll(),
+		exportListSchema, exportListConfig)
+
+	_, err := d.ExportList(rpc.RUNNING, testSID, "interfaces",
+		server.ExportFormatCSV, nil)
+	if err == nil {
+		t.Fatalf("Export of non-list should fail")
+	}
+}
+
+func TestExportListInvalidFormat(t *testing.T) {
+	d := newTestDispatcher(t, auth.TestAutherAllowAll(),
+		exportListSchema, exportListConfig)
+
+	_, err := d.ExportList(rpc.RUNNING, testSID, "interfaces/dataplane",
+		"xls", nil)
+	if err == nil {
+		t.Fatalf("Export with invalid format should fail")
+	}
+}
+
+func TestExportListCommandAuthz(t *testing.T) {
+	a := auth.TestAutherAllowAll()
+	d := newTestDispatcherWithCustomAuth(
+		t, a, exportListSchema, exportListConfig,
+		false, /* not configd user, so our auther gets used! */
+		false /* not in secrets group */)
+
+	_, _ = d.ExportList(rpc.RUNNING, testSID, "interfaces/dataplane",
+		server.ExportFormatCSV, nil)
+
+	assertCommandAaaNoSecrets(t, a,
+		[]string{"show", "interfaces", "dataplane"})
+}
+
+// The tree ExportList reads is part of the export, so isn't accounted as
+// a get-config of its own.
+func TestExportListAccountedOnce(t *testing.T) {
+	a := auth.NewTestAuther(
+		auth.NewTestRule(auth.Allow, auth.AllOps, "*"))
+	d := newTestDispatcherWithCustomAuth(
+		t, a, exportListSchema, exportListConfig,
+		false, /* not configd user, so our auther gets used! */
+		false /* not in secrets group */)
+	if _, err := d.SetClientOrigin("netconf"); err != nil {
+		t.Fatalf("Unable to set origin: %s", err)
+	}
+	clearAllCmdRequestsAndUserAuditLogs(a)
+
+	if _, err := d.ExportList(rpc.RUNNING, testSID, "interfaces/dataplane",
+		server.ExportFormatCSV, nil); err != nil {
+		t.Fatalf("Unable to export list: %s", err)
+	}
+	assertCmdAccounted(t, a,
+		[]string{"netconf", "show", "interfaces", "dataplane"})
+}
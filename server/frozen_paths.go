@@ -0,0 +1,73 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/danos/configd/common"
+	"github.com/danos/configd/rpc"
+	"github.com/danos/mgmterror"
+	"github.com/danos/utils/pathutil"
+)
+
+// Frozen paths are held by the commit manager, which also describes how
+// they are enforced (see session/frozen.go).  Only superusers may freeze
+// and unfreeze paths, but anyone may see which are frozen.
+
+func (d *Disp) authFreeze(ps []string) error {
+	if !d.ctx.Superuser && !d.ctx.Configd {
+		return mgmterror.NewAccessDeniedApplicationError()
+	}
+	if err := d.validatePath(ps); err != nil {
+		return common.FormatConfigPathError(err)
+	}
+	return nil
+}
+
+// FreezePath stops anyone but superusers changing the configuration at or
+// below path, giving the reason why.
+func (d *Disp) FreezePath(path, reason string) (bool, error) {
+	ps := pathutil.Makepath(path)
+	if err := d.authFreeze(ps); err != nil {
+		return false, err
+	}
+	err := d.cmgr.FreezePath(d.ctx.Config.Runfile, rpc.FrozenPath{
+		Path:   pathutil.Pathstr(ps),
+		User:   d.ctx.User,
+		Time:   time.Now().Format(time.RFC3339),
+		Reason: reason,
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (d *Disp) UnfreezePath(path string) (bool, error) {
+	ps := pathutil.Makepath(path)
+	if err := d.authFreeze(ps); err != nil {
+		return false, err
+	}
+	found, err := d.cmgr.UnfreezePath(d.ctx.Config.Runfile, ps)
+	if err != nil {
+		return false, err
+	}
+	if !found {
+		err := mgmterror.NewInvalidValueApplicationError()
+		err.Path = pathutil.Pathstr(ps)
+		err.Message = "Path is not frozen"
+		return false, err
+	}
+	return true, nil
+}
+
+// GetFrozenPaths returns the frozen paths as a JSON encoded list of
+// rpc.FrozenPaths.
+func (d *Disp) GetFrozenPaths() (string, error) {
+	buf, err := json.Marshal(d.cmgr.FrozenPaths())
+	return string(buf), err
+}
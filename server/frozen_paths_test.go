@@ -0,0 +1,88 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/danos/configd/rpc"
+	"github.com/danos/configd/session/sessiontest"
+	"github.com/danos/utils/pathutil"
+)
+
+const frozenPathsSchema = `
+container cont {
+	container login {
+		leaf user {
+			type string;
+		}
+	}
+	leaf other {
+		type string;
+	}
+}`
+
+func TestFreezePath(t *testing.T) {
+	srv, sess := sessiontest.NewTestSpec(t).
+		SetSingleSchema(frozenPathsSchema).
+		Init()
+
+	adminCtx := *srv.Ctx
+	adminCtx.Configd, adminCtx.Superuser = false, true
+	admin := &Disp{smgr: srv.Smgr, cmgr: srv.Cmgr, ms: srv.Ms,
+		msFull: srv.MsFull, ctx: &adminCtx}
+	userCtx := *srv.Ctx
+	userCtx.Configd, userCtx.Superuser = false, false
+	user := &Disp{smgr: srv.Smgr, cmgr: srv.Cmgr, ms: srv.Ms,
+		msFull: srv.MsFull, ctx: &userCtx}
+
+	set := func(ctx *Disp, path string) error {
+		return sess.Set(ctx.ctx, pathutil.Makepath(path))
+	}
+
+	if _, err := user.FreezePath("/cont/login", ""); err == nil {
+		t.Fatal("Path frozen by a user who isn't a superuser")
+	}
+	if _, err := admin.FreezePath("/cont/login", "CHG-42"); err != nil {
+		t.Fatalf("Unable to freeze path: %s", err)
+	}
+
+	if err := set(user, "/cont/login/user/fred"); err == nil {
+		t.Fatal("Frozen path changed by a user who isn't a superuser")
+	}
+	if err := set(user, "/cont/other/foo"); err != nil {
+		t.Fatalf("Unable to change a path which isn't frozen: %s", err)
+	}
+	if err := sess.Delete(&userCtx, pathutil.Makepath("/cont")); err == nil {
+		t.Fatal("Ancestor of a frozen path deleted")
+	}
+	if err := set(admin, "/cont/login/user/fred"); err != nil {
+		t.Fatalf("Superuser unable to change frozen path: %s", err)
+	}
+
+	out, err := user.GetFrozenPaths()
+	if err != nil {
+		t.Fatalf("Unable to get frozen paths: %s", err)
+	}
+	var frozen []rpc.FrozenPath
+	if err := json.Unmarshal([]byte(out), &frozen); err != nil {
+		t.Fatalf("Unable to decode frozen paths: %s", err)
+	}
+	if len(frozen) != 1 || frozen[0].Path != "/cont/login" ||
+		frozen[0].Reason != "CHG-42" {
+		t.Fatalf("Unexpected frozen paths: %v", frozen)
+	}
+
+	if _, err := admin.UnfreezePath("/cont/login"); err != nil {
+		t.Fatalf("Unable to unfreeze path: %s", err)
+	}
+	if err := set(user, "/cont/login/user/barney"); err != nil {
+		t.Fatalf("Unable to change unfrozen path: %s", err)
+	}
+	if _, err := admin.UnfreezePath("/cont/login"); err == nil {
+		t.Fatal("Unfroze a path which wasn't frozen")
+	}
+}
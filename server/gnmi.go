@@ -0,0 +1,733 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os/user"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/danos/config/auth"
+	"github.com/danos/config/schema"
+	"github.com/danos/configd/rpc"
+	"github.com/danos/utils/pathutil"
+	pb "github.com/openconfig/gnmi/proto/gnmi"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// gNMI
+//
+// Telemetry and provisioning stacks expect to talk gNMI rather than the
+// JSON RPC used over the unix socket, so configd can optionally serve gNMI
+// over gRPC as well.  gNMI paths map directly onto configd paths, with the
+// key of each list entry following the list's name, and requests are carried
+// out through the same Disp methods used by local clients, so the usual
+// authorization and accounting applies.
+//
+// As gNMI clients are remote, they must present a TLS client certificate
+// signed by the configured CA; the common name of the certificate names the
+// local user the client acts as.  Root and the user configd runs as bypass
+// authorization, so certificates naming them are refused.
+//
+//	Capabilities - lists the loaded modules; JSON and JSON_IETF are supported
+//	Get          - returns the RUNNING subtree at each path, with state
+//	               data unless only CONFIG is requested
+//	Set          - applies the deletes, replaces and updates to a private
+//	               candidate, in that order, and commits it
+//	Subscribe    - ONCE, POLL and STREAM.  Streamed subscriptions are
+//	               sampled; ON_CHANGE and TARGET_DEFINED subscriptions are
+//	               sampled at gnmiDefaultSampleInterval and only sent when
+//	               the subtree has changed.
+
+const (
+	gnmiVersion = "0.7.0"
+
+	gnmiDefaultSampleInterval = 10 * time.Second
+	gnmiMinSampleInterval     = time.Second
+)
+
+// GnmiConfig describes where and how to serve gNMI.
+type GnmiConfig struct {
+	Listen string
+	Cert   string
+	Key    string
+	CA     string
+}
+
+func (g *GnmiConfig) tlsConfig() (*tls.Config, error) {
+	if g.Cert == "" || g.Key == "" || g.CA == "" {
+		return nil, fmt.Errorf(
+			"gNMI requires a TLS certificate, key and client CA")
+	}
+	cert, err := tls.LoadX509KeyPair(g.Cert, g.Key)
+	if err != nil {
+		return nil, err
+	}
+	ca, err := ioutil.ReadFile(g.CA)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("No certificates found in %s", g.CA)
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+type gnmiServer struct {
+	srv *Srv
+}
+
+// ServeGnmi serves gNMI on the configured address until it fails.
+func (s *Srv) ServeGnmi(config *GnmiConfig) error {
+	tlsConfig, err := config.tlsConfig()
+	if err != nil {
+		return err
+	}
+	l, err := net.Listen("tcp", config.Listen)
+	if err != nil {
+		return err
+	}
+	g := grpc.NewServer(grpc.Creds(credentials.NewTLS(tlsConfig)))
+	pb.RegisterGNMIServer(g, &gnmiServer{srv: s})
+	return g.Serve(l)
+}
+
+// gnmiUser returns the user named by the client's certificate.
+func gnmiUser(ctx context.Context) (*user.User, error) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "No peer")
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 ||
+		len(info.State.VerifiedChains[0]) == 0 {
+		return nil, status.Error(codes.Unauthenticated,
+			"No verified client certificate")
+	}
+	name := info.State.VerifiedChains[0][0].Subject.CommonName
+	u, err := user.Lookup(name)
+	if err != nil {
+		return nil, status.Errorf(codes.Unauthenticated,
+			"Unknown user %s", name)
+	}
+	return u, nil
+}
+
+// gnmiUid returns the uid a gNMI client acting as u is given.  A client
+// acting as root or as configd's own user would skip authorization, so
+// neither may be used.
+func (s *Srv) gnmiUid(u *user.User) (uint32, error) {
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return 0, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if uid == 0 || uint32(uid) == s.uid {
+		return 0, status.Errorf(codes.PermissionDenied,
+			"User %s may not be used over gNMI", u.Username)
+	}
+	return uint32(uid), nil
+}
+
+// disp returns a dispatcher acting for the client's user.
+func (g *gnmiServer) disp(ctx context.Context) (*Disp, error) {
+	u, err := gnmiUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	uid, err := g.srv.gnmiUid(u)
+	if err != nil {
+		return nil, err
+	}
+	d := g.srv.newDisp(uid, 0, &auth.AuthEnv{}, g.srv.CompMgr)
+	d.ctx.User = u.Username
+	d.ctx.UserHome = u.HomeDir
+	return d, nil
+}
+
+func gnmiError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	return status.Error(codes.Unknown, err.Error())
+}
+
+func newGnmiPathError(format string, args ...interface{}) error {
+	return status.Errorf(codes.InvalidArgument, format, args...)
+}
+
+// stripModulePrefix removes the module name qualifying a gNMI path element
+// or JSON_IETF member name.
+func stripModulePrefix(name string) string {
+	if i := strings.IndexByte(name, ':'); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
+
+// gnmiPath converts the gNMI path below prefix to a configd path.
+func gnmiPath(prefix, path *pb.Path) ([]string, error) {
+	var ps []string
+	for _, p := range []*pb.Path{prefix, path} {
+		for _, elem := range p.GetElem() {
+			ps = append(ps, stripModulePrefix(elem.GetName()))
+			switch len(elem.GetKey()) {
+			case 0:
+			case 1:
+				for _, key := range elem.GetKey() {
+					ps = append(ps, key)
+				}
+			default:
+				return nil, newGnmiPathError(
+					"Lists with more than one key are not supported: %s",
+					elem.GetName())
+			}
+		}
+	}
+	return ps, nil
+}
+
+func (d *Disp) schemaNodeForPath(ps []string) (schema.Node, error) {
+	var sn schema.Node = d.ms
+	for _, v := range ps {
+		if sn = sn.SchemaChild(v); sn == nil {
+			return nil, newGnmiPathError("Path is invalid: %s",
+				pathutil.Pathstr(ps))
+		}
+	}
+	return sn, nil
+}
+
+func gnmiScalarString(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(v), true
+	}
+	return "", false
+}
+
+// jsonValuePaths returns the configd paths to set to configure the JSON
+// value v at path, whose schema is sn.
+func jsonValuePaths(sn schema.Node, path []string, v interface{}) ([][]string, error) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 {
+			return [][]string{path}, nil
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		var paths [][]string
+		for _, name := range names {
+			cname := stripModulePrefix(name)
+			csn := sn.SchemaChild(cname)
+			if csn == nil {
+				return nil, newGnmiPathError("Unknown element %s at %s",
+					cname, pathutil.Pathstr(path))
+			}
+			cpaths, err := jsonValuePaths(csn,
+				pathutil.CopyAppend(path, cname), v[name])
+			if err != nil {
+				return nil, err
+			}
+			paths = append(paths, cpaths...)
+		}
+		return paths, nil
+
+	case []interface{}:
+		switch sch := sn.(type) {
+		case schema.List:
+			return jsonListPaths(sch, path, v)
+		case schema.LeafList:
+			var paths [][]string
+			for _, val := range v {
+				s, ok := gnmiScalarString(val)
+				if !ok {
+					return nil, newGnmiPathError(
+						"Invalid value for %s", pathutil.Pathstr(path))
+				}
+				paths = append(paths, pathutil.CopyAppend(path, s))
+			}
+			return paths, nil
+		case schema.Leaf:
+			// Empty leaves are encoded as [null]
+			if len(v) == 1 && v[0] == nil {
+				return [][]string{path}, nil
+			}
+		}
+		return nil, newGnmiPathError("Invalid value for %s",
+			pathutil.Pathstr(path))
+	}
+
+	s, ok := gnmiScalarString(v)
+	if _, isLeaf := sn.(schema.Leaf); !ok || !isLeaf {
+		return nil, newGnmiPathError("Invalid value for %s",
+			pathutil.Pathstr(path))
+	}
+	return [][]string{pathutil.CopyAppend(path, s)}, nil
+}
+
+func jsonListPaths(sch schema.List, path []string, entries []interface{}) ([][]string, error) {
+	key := sch.Keys()[0]
+	var paths [][]string
+	for _, e := range entries {
+		entry, ok := e.(map[string]interface{})
+		if !ok {
+			return nil, newGnmiPathError("Invalid list entry for %s",
+				pathutil.Pathstr(path))
+		}
+		rest := make(map[string]interface{}, len(entry))
+		var keyval string
+		var haveKey bool
+		for name, val := range entry {
+			if stripModulePrefix(name) == key {
+				keyval, haveKey = gnmiScalarString(val)
+				continue
+			}
+			rest[name] = val
+		}
+		if !haveKey {
+			return nil, newGnmiPathError("List entry for %s has no %s",
+				pathutil.Pathstr(path), key)
+		}
+		epath := pathutil.CopyAppend(path, keyval)
+		esn := sch.SchemaChild(keyval)
+		if esn == nil {
+			return nil, newGnmiPathError("Invalid key %s for %s",
+				keyval, pathutil.Pathstr(path))
+		}
+		if len(rest) == 0 {
+			paths = append(paths, epath)
+			continue
+		}
+		epaths, err := jsonValuePaths(esn, epath, rest)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, epaths...)
+	}
+	return paths, nil
+}
+
+// valuePaths returns the configd paths to set to configure val at path.
+func (d *Disp) valuePaths(path []string, val *pb.TypedValue) ([][]string, error) {
+	sn, err := d.schemaNodeForPath(path)
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	switch tv := val.GetValue().(type) {
+	case *pb.TypedValue_JsonIetfVal:
+		err = json.Unmarshal(tv.JsonIetfVal, &v)
+	case *pb.TypedValue_JsonVal:
+		err = json.Unmarshal(tv.JsonVal, &v)
+	case *pb.TypedValue_StringVal:
+		v = tv.StringVal
+	case *pb.TypedValue_IntVal:
+		v = strconv.FormatInt(tv.IntVal, 10)
+	case *pb.TypedValue_UintVal:
+		v = strconv.FormatUint(tv.UintVal, 10)
+	case *pb.TypedValue_BoolVal:
+		v = tv.BoolVal
+	default:
+		return nil, status.Errorf(codes.Unimplemented,
+			"Unsupported value type %T", tv)
+	}
+	if err != nil {
+		return nil, newGnmiPathError("Invalid value for %s: %s",
+			pathutil.Pathstr(path), err)
+	}
+	return jsonValuePaths(sn, path, v)
+}
+
+func (d *Disp) gnmiGet(path []string, dataType pb.GetRequest_DataType) ([]byte, error) {
+	flags := map[string]interface{}{"Defaults": true}
+	var out string
+	var err error
+	if dataType == pb.GetRequest_CONFIG {
+		out, err = d.TreeGet(rpc.RUNNING, "RUNNING",
+			pathutil.Pathstr(path), "rfc7951", flags)
+	} else {
+		out, err = d.TreeGetFull(rpc.RUNNING, "RUNNING",
+			pathutil.Pathstr(path), "rfc7951", flags)
+	}
+	return []byte(out), err
+}
+
+func checkGnmiEncoding(enc pb.Encoding) error {
+	switch enc {
+	case pb.Encoding_JSON, pb.Encoding_JSON_IETF:
+		return nil
+	}
+	return status.Errorf(codes.Unimplemented, "Unsupported encoding %s", enc)
+}
+
+func gnmiTypedValue(enc pb.Encoding, val []byte) *pb.TypedValue {
+	if enc == pb.Encoding_JSON {
+		return &pb.TypedValue{Value: &pb.TypedValue_JsonVal{JsonVal: val}}
+	}
+	return &pb.TypedValue{
+		Value: &pb.TypedValue_JsonIetfVal{JsonIetfVal: val}}
+}
+
+func (g *gnmiServer) Capabilities(
+	ctx context.Context,
+	req *pb.CapabilityRequest,
+) (*pb.CapabilityResponse, error) {
+	d, err := g.disp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var models []*pb.ModelData
+	for _, m := range d.ms.Modules() {
+		models = append(models, &pb.ModelData{Name: m.Identifier()})
+	}
+	sort.Slice(models, func(i, j int) bool {
+		return models[i].Name < models[j].Name
+	})
+	return &pb.CapabilityResponse{
+		SupportedModels: models,
+		SupportedEncodings: []pb.Encoding{
+			pb.Encoding_JSON, pb.Encoding_JSON_IETF},
+		GNMIVersion: gnmiVersion,
+	}, nil
+}
+
+func (g *gnmiServer) Get(
+	ctx context.Context,
+	req *pb.GetRequest,
+) (*pb.GetResponse, error) {
+	if err := checkGnmiEncoding(req.GetEncoding()); err != nil {
+		return nil, err
+	}
+	d, err := g.disp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp := &pb.GetResponse{}
+	for _, p := range req.GetPath() {
+		ps, err := gnmiPath(req.GetPrefix(), p)
+		if err != nil {
+			return nil, err
+		}
+		val, err := d.gnmiGet(ps, req.GetType())
+		if err != nil {
+			return nil, gnmiError(err)
+		}
+		resp.Notification = append(resp.Notification, &pb.Notification{
+			Timestamp: time.Now().UnixNano(),
+			Prefix:    req.GetPrefix(),
+			Update: []*pb.Update{{
+				Path: p,
+				Val:  gnmiTypedValue(req.GetEncoding(), val),
+			}},
+		})
+	}
+	return resp, nil
+}
+
+func newGnmiSessionId() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "gnmi-" + hex.EncodeToString(b), nil
+}
+
+func (d *Disp) gnmiDelete(sid string, ps []string) error {
+	path := pathutil.Pathstr(ps)
+	// Deleting what doesn't exist isn't an error in gNMI
+	if exists, _ := d.Exists(rpc.CANDIDATE, sid, path); !exists {
+		return nil
+	}
+	_, err := d.Delete(sid, path)
+	return err
+}
+
+func (d *Disp) gnmiUpdate(sid string, ps []string, val *pb.TypedValue) error {
+	paths, err := d.valuePaths(ps, val)
+	if err != nil {
+		return err
+	}
+	for _, p := range paths {
+		if _, err := d.Set(sid, pathutil.Pathstr(p)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// gnmiSet makes the changes in req to a new candidate and commits them.
+func (d *Disp) gnmiSet(req *pb.SetRequest) ([]*pb.UpdateResult, error) {
+	sid, err := newGnmiSessionId()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := d.SessionSetup(sid); err != nil {
+		return nil, err
+	}
+	defer d.SessionTeardown(sid)
+
+	var results []*pb.UpdateResult
+	for _, p := range req.GetDelete() {
+		ps, err := gnmiPath(req.GetPrefix(), p)
+		if err != nil {
+			return nil, err
+		}
+		if err := d.gnmiDelete(sid, ps); err != nil {
+			return nil, err
+		}
+		results = append(results,
+			&pb.UpdateResult{Path: p, Op: pb.UpdateResult_DELETE})
+	}
+	for _, u := range req.GetReplace() {
+		ps, err := gnmiPath(req.GetPrefix(), u.GetPath())
+		if err != nil {
+			return nil, err
+		}
+		if err := d.gnmiDelete(sid, ps); err != nil {
+			return nil, err
+		}
+		if err := d.gnmiUpdate(sid, ps, u.GetVal()); err != nil {
+			return nil, err
+		}
+		results = append(results,
+			&pb.UpdateResult{Path: u.GetPath(), Op: pb.UpdateResult_REPLACE})
+	}
+	for _, u := range req.GetUpdate() {
+		ps, err := gnmiPath(req.GetPrefix(), u.GetPath())
+		if err != nil {
+			return nil, err
+		}
+		if err := d.gnmiUpdate(sid, ps, u.GetVal()); err != nil {
+			return nil, err
+		}
+		results = append(results,
+			&pb.UpdateResult{Path: u.GetPath(), Op: pb.UpdateResult_UPDATE})
+	}
+
+	if _, err := d.Commit(sid, "gNMI set", false); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (g *gnmiServer) Set(
+	ctx context.Context,
+	req *pb.SetRequest,
+) (*pb.SetResponse, error) {
+	d, err := g.disp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	results, err := d.gnmiSet(req)
+	if err != nil {
+		return nil, gnmiError(err)
+	}
+	return &pb.SetResponse{
+		Prefix:    req.GetPrefix(),
+		Response:  results,
+		Timestamp: time.Now().UnixNano(),
+	}, nil
+}
+
+// gnmiSubscription tracks what was last sent for a subscription, so
+// on-change subscriptions are only sent when their subtree changes.
+type gnmiSubscription struct {
+	sub  *pb.Subscription
+	path []string
+	last []byte
+}
+
+type gnmiSubscriber struct {
+	d      *Disp
+	stream pb.GNMI_SubscribeServer
+	list   *pb.SubscriptionList
+	subs   []*gnmiSubscription
+
+	mu sync.Mutex
+}
+
+func (s *gnmiSubscriber) send(resp *pb.SubscribeResponse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stream.Send(resp)
+}
+
+func (s *gnmiSubscriber) sendSync() error {
+	return s.send(&pb.SubscribeResponse{
+		Response: &pb.SubscribeResponse_SyncResponse{SyncResponse: true}})
+}
+
+// update sends the subtree for sub, unless onlyChanged is set and it
+// hasn't changed since it was last sent.
+func (s *gnmiSubscriber) update(sub *gnmiSubscription, onlyChanged bool) error {
+	val, err := s.d.gnmiGet(sub.path, pb.GetRequest_ALL)
+	if err != nil {
+		return gnmiError(err)
+	}
+	if onlyChanged && sub.last != nil && string(val) == string(sub.last) {
+		return nil
+	}
+	sub.last = val
+	return s.send(&pb.SubscribeResponse{
+		Response: &pb.SubscribeResponse_Update{Update: &pb.Notification{
+			Timestamp: time.Now().UnixNano(),
+			Prefix:    s.list.GetPrefix(),
+			Update: []*pb.Update{{
+				Path: sub.sub.GetPath(),
+				Val:  gnmiTypedValue(s.list.GetEncoding(), val),
+			}},
+		}},
+	})
+}
+
+func (s *gnmiSubscriber) updateAll() error {
+	for _, sub := range s.subs {
+		if err := s.update(sub, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func gnmiSampleInterval(sub *pb.Subscription) time.Duration {
+	interval := time.Duration(sub.GetSampleInterval())
+	if interval == 0 {
+		return gnmiDefaultSampleInterval
+	}
+	if interval < gnmiMinSampleInterval {
+		return gnmiMinSampleInterval
+	}
+	return interval
+}
+
+// sample samples sub until the client goes away.
+func (s *gnmiSubscriber) sample(sub *gnmiSubscription, errch chan<- error) {
+	onlyChanged := sub.sub.GetMode() != pb.SubscriptionMode_SAMPLE ||
+		sub.sub.GetSuppressRedundant()
+	ticker := time.NewTicker(gnmiSampleInterval(sub.sub))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stream.Context().Done():
+			return
+		case <-ticker.C:
+			if err := s.update(sub, onlyChanged); err != nil {
+				errch <- err
+				return
+			}
+		}
+	}
+}
+
+func (g *gnmiServer) Subscribe(stream pb.GNMI_SubscribeServer) error {
+	d, err := g.disp(stream.Context())
+	if err != nil {
+		return err
+	}
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	list := req.GetSubscribe()
+	if list == nil {
+		return status.Error(codes.InvalidArgument,
+			"First request must be a subscription list")
+	}
+	if err := checkGnmiEncoding(list.GetEncoding()); err != nil {
+		return err
+	}
+
+	s := &gnmiSubscriber{d: d, stream: stream, list: list}
+	for _, sub := range list.GetSubscription() {
+		ps, err := gnmiPath(list.GetPrefix(), sub.GetPath())
+		if err != nil {
+			return err
+		}
+		s.subs = append(s.subs, &gnmiSubscription{sub: sub, path: ps})
+	}
+
+	if !list.GetUpdatesOnly() {
+		if err := s.updateAll(); err != nil {
+			return err
+		}
+	}
+	if err := s.sendSync(); err != nil {
+		return err
+	}
+
+	switch list.GetMode() {
+	case pb.SubscriptionList_ONCE:
+		return nil
+	case pb.SubscriptionList_POLL:
+		for {
+			req, err := stream.Recv()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if req.GetPoll() == nil {
+				return status.Error(codes.InvalidArgument,
+					"Only polls may follow a poll subscription")
+			}
+			if err := s.updateAll(); err != nil {
+				return err
+			}
+			if err := s.sendSync(); err != nil {
+				return err
+			}
+		}
+	}
+
+	errch := make(chan error, len(s.subs))
+	for _, sub := range s.subs {
+		// Nothing has been sent for updates_only, so note what
+		// later samples are to be compared with.
+		if list.GetUpdatesOnly() {
+			sub.last, _ = d.gnmiGet(sub.path, pb.GetRequest_ALL)
+		}
+		go s.sample(sub, errch)
+	}
+	select {
+	case <-stream.Context().Done():
+		return nil
+	case err := <-errch:
+		return err
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"os/user"
+	"reflect"
+	"testing"
+
+	"github.com/danos/configd/session/sessiontest"
+	pb "github.com/openconfig/gnmi/proto/gnmi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const gnmiSchema = `
+container cont {
+	list entry {
+		key name;
+		leaf name {
+			type string;
+		}
+		leaf value {
+			type uint32;
+		}
+	}
+	leaf-list multi {
+		type string;
+	}
+	leaf flag {
+		type empty;
+	}
+	leaf value {
+		type string;
+	}
+}`
+
+func newGnmiTestDisp(t *testing.T) *Disp {
+	srv, _ := sessiontest.NewTestSpec(t).
+		SetSingleSchema(gnmiSchema).
+		Init()
+	return &Disp{smgr: srv.Smgr, cmgr: srv.Cmgr, ms: srv.Ms,
+		msFull: srv.MsFull, ctx: srv.Ctx}
+}
+
+func TestGnmiPath(t *testing.T) {
+	prefix := &pb.Path{Elem: []*pb.PathElem{{Name: "test:cont"}}}
+	path := &pb.Path{Elem: []*pb.PathElem{
+		{Name: "entry", Key: map[string]string{"name": "foo"}},
+		{Name: "value"},
+	}}
+	ps, err := gnmiPath(prefix, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []string{"cont", "entry", "foo", "value"}
+	if !reflect.DeepEqual(ps, exp) {
+		t.Fatalf("Unexpected path:\nExp: %v\nGot: %v\n", exp, ps)
+	}
+
+	path.Elem[0].Key["other"] = "bar"
+	if _, err := gnmiPath(prefix, path); err == nil {
+		t.Fatal("Converted a path with multiple keys")
+	}
+}
+
+func TestGnmiValuePaths(t *testing.T) {
+	d := newGnmiTestDisp(t)
+	val := &pb.TypedValue{Value: &pb.TypedValue_JsonIetfVal{JsonIetfVal: []byte(`{
+		"entry": [{"name": "foo", "value": 1}, {"name": "bar"}],
+		"multi": ["a", "b"],
+		"test:flag": [null],
+		"value": "baz"
+	}`)}}
+	paths, err := d.valuePaths([]string{"cont"}, val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := [][]string{
+		{"cont", "entry", "foo", "value", "1"},
+		{"cont", "entry", "bar"},
+		{"cont", "multi", "a"},
+		{"cont", "multi", "b"},
+		{"cont", "flag"},
+		{"cont", "value", "baz"},
+	}
+	if !reflect.DeepEqual(paths, exp) {
+		t.Fatalf("Unexpected paths:\nExp: %v\nGot: %v\n", exp, paths)
+	}
+
+	val = &pb.TypedValue{Value: &pb.TypedValue_StringVal{StringVal: "baz"}}
+	paths, err = d.valuePaths([]string{"cont", "value"}, val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp = [][]string{{"cont", "value", "baz"}}
+	if !reflect.DeepEqual(paths, exp) {
+		t.Fatalf("Unexpected paths:\nExp: %v\nGot: %v\n", exp, paths)
+	}
+
+	val = &pb.TypedValue{Value: &pb.TypedValue_JsonIetfVal{
+		JsonIetfVal: []byte(`{"unknown": "baz"}`)}}
+	if _, err := d.valuePaths([]string{"cont"}, val); err == nil {
+		t.Fatal("Converted a value for an unknown node")
+	}
+}
+
+func TestGnmiUidRefusesPrivilegedUsers(t *testing.T) {
+	s := &Srv{uid: 123}
+	for _, uid := range []string{"0", "123"} {
+		u := &user.User{Uid: uid, Username: "u" + uid}
+		if _, err := s.gnmiUid(u); status.Code(err) != codes.PermissionDenied {
+			t.Errorf("User with uid %s not refused: %v", uid, err)
+		}
+	}
+	uid, err := s.gnmiUid(&user.User{Uid: "1000", Username: "alice"})
+	if err != nil || uid != 1000 {
+		t.Errorf("Unexpected uid %d for user alice: %v", uid, err)
+	}
+}
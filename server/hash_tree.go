@@ -0,0 +1,113 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+
+	"github.com/danos/config/union"
+	"github.com/danos/configd"
+	"github.com/danos/configd/rpc"
+	"github.com/danos/configd/session"
+	"github.com/danos/mgmterror"
+	"github.com/danos/utils/pathutil"
+)
+
+// Subtree hashes
+//
+// Fleet management systems checking thousands of devices for drift had to
+// fetch and compare whole trees.  HashTree instead returns a hash of the
+// configuration below a path, to fetch only those trees whose hashes
+// differ.  The hash covers the names and values of the configured nodes,
+// in sorted order, or the user's order for lists ordered by the user, so
+// it doesn't depend on encoding or on the order changes were made in.
+// Defaults aren't included, nor are nodes the caller may not read.  The
+// values of secrets are only included if the caller may see them, so a
+// hash can't be used to guess a secret.
+
+const defaultHashAlgo = "sha256"
+
+var hashAlgos = map[string]func() hash.Hash{
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+type treeHasher struct {
+	d           *Disp
+	h           hash.Hash
+	showSecrets bool
+}
+
+func (th *treeHasher) writeString(s string) {
+	var l [binary.MaxVarintLen64]byte
+	th.h.Write(l[:binary.PutUvarint(l[:], uint64(len(s)))])
+	th.h.Write([]byte(s))
+}
+
+func (th *treeHasher) walk(n union.Node, path []string) {
+	sch := n.GetSchema()
+	if sch != nil && sch.ConfigdExt().Secret && !th.showSecrets {
+		th.writeString(hiddenSecret)
+		return
+	}
+	th.h.Write([]byte{'{'})
+	for _, ch := range n.SortedChildren() {
+		chPath := pathutil.CopyAppend(path, ch.Name())
+		if !th.d.authRead(chPath) {
+			continue
+		}
+		th.writeString(ch.Name())
+		th.walk(ch, chPath)
+	}
+	th.h.Write([]byte{'}'})
+}
+
+// HashTree returns the hex encoded hash, using algo (sha1, sha256 or
+// sha512, sha256 if empty), of the committed configuration at and below
+// path in the running or effective database.
+func (d *Disp) HashTree(db rpc.DB, path, algo string) (string, error) {
+	if algo == "" {
+		algo = defaultHashAlgo
+	}
+	newHash, ok := hashAlgos[algo]
+	if !ok {
+		err := mgmterror.NewInvalidValueApplicationError()
+		err.Message = fmt.Sprintf("Unknown hash algorithm %s", algo)
+		return "", err
+	}
+	switch db {
+	case rpc.RUNNING, rpc.EFFECTIVE:
+	default:
+		err := mgmterror.NewInvalidValueApplicationError()
+		err.Message = "Only the running and effective databases may be hashed"
+		return "", err
+	}
+
+	ps := pathutil.Makepath(path)
+	if !d.authRead(ps) {
+		return "", mgmterror.NewAccessDeniedApplicationError()
+	}
+	ut, err := d.getROSession(db, "").GetTree(d.ctx, ps,
+		&session.TreeOpts{Defaults: false, Secrets: true})
+	if err != nil {
+		return "", err
+	}
+	th := &treeHasher{
+		d:           d,
+		h:           newHash(),
+		showSecrets: configd.ShowSecrets(d.ctx),
+	}
+	if ut != nil {
+		th.walk(ut, ps)
+	}
+	return hex.EncodeToString(th.h.Sum(nil)), nil
+}
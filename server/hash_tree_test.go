@@ -0,0 +1,82 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"testing"
+
+	"github.com/danos/configd/rpc"
+	"github.com/danos/configd/session/sessiontest"
+	"github.com/danos/utils/pathutil"
+)
+
+const hashTreeSchema = `
+container cont {
+	leaf one {
+		type string;
+	}
+	leaf two {
+		type string;
+	}
+	list entries {
+		key name;
+		leaf name {
+			type string;
+		}
+	}
+}`
+
+func TestHashTree(t *testing.T) {
+	srv, sess := sessiontest.NewTestSpec(t).
+		SetSingleSchema(hashTreeSchema).
+		Init()
+	d := &Disp{smgr: srv.Smgr, cmgr: srv.Cmgr, ms: srv.Ms,
+		msFull: srv.MsFull, ctx: srv.Ctx}
+
+	commit := func(paths ...string) {
+		for _, p := range paths {
+			if err := sess.Set(srv.Ctx, pathutil.Makepath(p)); err != nil {
+				t.Fatalf("Unable to set %s: %s", p, err)
+			}
+		}
+		if _, errs, ok := sess.Commit(srv.Ctx, "", false); !ok {
+			t.Fatalf("Unable to commit: %v", errs)
+		}
+	}
+	hash := func(path string) string {
+		h, err := d.HashTree(rpc.RUNNING, path, "")
+		if err != nil {
+			t.Fatalf("Unable to hash %s: %s", path, err)
+		}
+		return h
+	}
+
+	commit("/cont/one/a", "/cont/entries/x", "/cont/entries/y")
+	first := hash("/cont")
+
+	if err := sess.Delete(srv.Ctx, pathutil.Makepath("/cont")); err != nil {
+		t.Fatalf("Unable to delete: %s", err)
+	}
+	commit("/cont/entries/y", "/cont/entries/x", "/cont/one/a")
+	if second := hash("/cont"); second != first {
+		t.Fatalf("Same configuration hashed differently: %s, %s",
+			first, second)
+	}
+
+	commit("/cont/two/b")
+	if third := hash("/cont"); third == first {
+		t.Fatal("Changed configuration hashed the same")
+	}
+	if hash("/cont/entries") == hash("/cont") {
+		t.Fatal("Subtree hashed the same as its parent")
+	}
+
+	if _, err := d.HashTree(rpc.RUNNING, "/cont", "crc32"); err == nil {
+		t.Fatal("Hashed with an unknown algorithm")
+	}
+	if _, err := d.HashTree(rpc.CANDIDATE, "/cont", ""); err == nil {
+		t.Fatal("Hashed the candidate database")
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"sync"
+)
+
+// Health alarms are raised for conditions an operator needs to know about
+// but which don't prevent configd from running, eg having to discard a
+// corrupt running configuration file at startup.  They remain raised until
+// configd is restarted, other than those for conditions that are checked
+// periodically, which are cleared once the condition is no longer found.
+
+const (
+	RunfileIntegrityAlarm    = "runfile-integrity"
+	RunfileDriftAlarm        = "runfile-drift"
+	RevalidationAlarm        = "running-revalidation"
+	BootConfigIntegrityAlarm = "boot-config-integrity"
+)
+
+type healthAlarms struct {
+	mu     sync.Mutex
+	alarms map[string]string
+}
+
+var alarms = &healthAlarms{alarms: make(map[string]string)}
+
+func raiseAlarm(name, msg string) {
+	alarms.mu.Lock()
+	defer alarms.mu.Unlock()
+	alarms.alarms[name] = msg
+}
+
+func clearAlarm(name string) {
+	alarms.mu.Lock()
+	defer alarms.mu.Unlock()
+	delete(alarms.alarms, name)
+}
+
+func (a *healthAlarms) get() map[string]string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	out := make(map[string]string, len(a.alarms))
+	for name, msg := range a.alarms {
+		out[name] = msg
+	}
+	return out
+}
+
+// GetHealthAlarms returns the currently raised alarms, with a description of
+// each.
+func (d *Disp) GetHealthAlarms() (map[string]string, error) {
+	return alarms.get(), nil
+}
@@ -0,0 +1,54 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"github.com/danos/configd"
+)
+
+// Multiple instances
+//
+// Several configd instances, each with its own yangdir, socket and runtime
+// files, may run on one host.  None of the paths an instance uses are
+// hardcoded: the runtime files come from the configd.Config, and the saved
+// configuration, archive and confirmed commit job live in the config
+// directory it names.  Each instance tags its logs and events with its name
+// so they can be told apart.
+
+const defaultConfigDir = "/config"
+
+// setConfigDir moves the files kept in the config directory to dir.
+func setConfigDir(dir string) {
+	configDir = dir
+	confirmedCommitJobFile = dir + "/confirmed_commit.job"
+	confirmedCommitRevertFile = dir + "/confirmed_commit.revert"
+	commitExpiryJobFile = dir + "/commit_expiry.job"
+}
+
+func configBootFile() string {
+	return configDir + "/config.boot"
+}
+
+func initInstance(config *configd.Config) {
+	if config.ConfigDir != "" {
+		setConfigDir(config.ConfigDir)
+	}
+}
+
+// GetInstance identifies this configd instance, and the paths it uses, so
+// statistics gathered from several instances can be attributed.
+func (d *Disp) GetInstance() (map[string]string, error) {
+	cfg := d.ctx.Config
+	if cfg == nil {
+		return map[string]string{"config-dir": configDir}, nil
+	}
+	return map[string]string{
+		"instance":   cfg.Instance,
+		"yangdir":    cfg.Yangdir,
+		"socket":     cfg.Socket,
+		"runfile":    cfg.Runfile,
+		"config-dir": configDir,
+	}, nil
+}
@@ -0,0 +1,44 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"testing"
+
+	"github.com/danos/configd"
+)
+
+func TestInstanceConfigDir(t *testing.T) {
+	origDir, origJob := configDir, confirmedCommitJobFile
+	defer func() { configDir, confirmedCommitJobFile = origDir, origJob }()
+
+	initInstance(&configd.Config{Instance: "tenant1"})
+	if configDir != defaultConfigDir {
+		t.Fatalf("Config dir changed without being configured: %s",
+			configDir)
+	}
+
+	initInstance(&configd.Config{
+		Instance:  "tenant1",
+		ConfigDir: "/config/tenant1",
+	})
+	checks := []struct {
+		name, got, exp string
+	}{
+		{"archive", archiveDir(), "/config/tenant1/archive"},
+		{"saved", configRevisionFileName("saved"),
+			"/config/tenant1/config.boot"},
+		{"revision", configRevisionFileName("2"),
+			"/config/tenant1/archive/config.boot.2.gz"},
+		{"confirmed commit job", confirmedCommitJobFile,
+			"/config/tenant1/confirmed_commit.job"},
+	}
+	for _, c := range checks {
+		if c.got != c.exp {
+			t.Errorf("Unexpected %s path.\nExp: %s\nGot: %s",
+				c.name, c.exp, c.got)
+		}
+	}
+}
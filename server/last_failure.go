@@ -0,0 +1,82 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/danos/config/schema"
+	"github.com/danos/configd/common"
+	"github.com/danos/configd/rpc"
+	"github.com/danos/mgmterror"
+	"github.com/danos/utils/pathutil"
+)
+
+const (
+	failedValidate = "validate"
+	failedCommit   = "commit"
+)
+
+// failureComponent returns the model of the component err is attributed
+// to: the one reporting it, for a structured component error, otherwise
+// the one owning the namespace of the path in error.
+func (d *Disp) failureComponent(me mgmterror.Formattable) string {
+	for _, info := range me.GetInfo() {
+		if info.XMLName.Local == common.ComponentErrorModelInfo {
+			return info.Value
+		}
+	}
+	if d.ctx.CompMgr == nil {
+		return ""
+	}
+	sch := schema.Descendant(d.ms, pathutil.Makepath(me.GetPath()))
+	if sch == nil {
+		return ""
+	}
+	model, _ := d.ctx.CompMgr.GetComponentNSMappings().
+		GetModelNameForNamespace(sch.Namespace())
+	return model
+}
+
+func (d *Disp) failureErrors(errs []error) []rpc.FailureError {
+	out := make([]rpc.FailureError, 0, len(errs))
+	for _, err := range errs {
+		me, ok := err.(mgmterror.Formattable)
+		if !ok {
+			out = append(out, rpc.FailureError{Message: err.Error()})
+			continue
+		}
+		out = append(out, rpc.FailureError{
+			Path:      me.GetPath(),
+			Message:   me.GetMessage(),
+			Component: d.failureComponent(me),
+		})
+	}
+	return out
+}
+
+// GetLastError returns the session's most recent failed validation or
+// commit as a JSON encoded rpc.LastFailure, or an empty string if there
+// has been none.
+func (d *Disp) GetLastError(sid string) (string, error) {
+	sess, err := d.smgr.Get(d.ctx, sid)
+	if err != nil {
+		return "", err
+	}
+	failure := sess.LastFailure()
+	if failure == nil {
+		return "", nil
+	}
+	buf, err := json.Marshal(&rpc.LastFailure{
+		Operation: failure.Operation,
+		Time:      failure.Time.Format(time.RFC3339),
+		Errors:    d.failureErrors(failure.Errors),
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
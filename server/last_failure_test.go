@@ -0,0 +1,56 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/danos/config/auth"
+	"github.com/danos/configd/rpc"
+)
+
+const lastFailureSchema = `
+container top {
+	leaf checked {
+		type string;
+		must "../other";
+	}
+	leaf other {
+		type string;
+	}
+}`
+
+func TestGetLastError(t *testing.T) {
+	d := newTestDispatcher(t, auth.TestAutherAllowAll(), lastFailureSchema,
+		emptyconfig)
+	dispTestSetupSession(t, d, testSID)
+
+	if out, err := d.GetLastError(testSID); err != nil || out != "" {
+		t.Fatalf("Unexpected failure before any validation: %s %v", out, err)
+	}
+
+	dispTestSet(t, d, testSID, "top/checked/foo")
+	if _, err := d.Validate(testSID); err == nil {
+		t.Fatalf("Validation should fail")
+	}
+	if _, err := d.Commit(testSID, "", false); err == nil {
+		t.Fatalf("Commit should fail")
+	}
+
+	out, err := d.GetLastError(testSID)
+	if err != nil {
+		t.Fatalf("Unable to get last error: %s", err)
+	}
+	var failure rpc.LastFailure
+	if err := json.Unmarshal([]byte(out), &failure); err != nil {
+		t.Fatalf("Invalid failure %s: %s", out, err)
+	}
+	if failure.Operation != "commit" || len(failure.Errors) != 1 ||
+		failure.Errors[0].Path != "/top/checked/foo" ||
+		failure.Errors[0].Message == "" {
+		t.Fatalf("Unexpected failure: %s", out)
+	}
+}
@@ -0,0 +1,324 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/danos/config/data"
+	"github.com/danos/config/schema"
+	"github.com/danos/configd"
+	"github.com/danos/configd/rpc"
+	"github.com/danos/configd/session"
+	"github.com/danos/mgmterror"
+	"github.com/danos/utils/pathutil"
+)
+
+// Leaf watches
+//
+// Agents tracking a handful of leaves, eg a hostname or an interface's
+// MTU, had to subscribe to commit notifications and pick the values out of
+// each.  WatchLeaf instead reports only commits changing the committed
+// value of one leaf, with its old and new value, as an rpc.LeafChange.
+// Watches are indexed by path, so each commit only looks up the paths it
+// changed rather than every watch checking every commit; a change to a
+// container or list entry reaches the watches on leaves below it.
+// Changes are queued, waited for and discarded as for commit
+// notifications.  Secrets are hidden unless the watcher may see them.
+
+type leafWatch struct {
+	id     string
+	uid    uint32
+	path   []string
+	secret bool // Values are hidden
+	wake   chan struct{}
+
+	mu         sync.Mutex
+	queue      []rpc.LeafChange
+	dropped    uint64
+	lastActive time.Time
+}
+
+func (w *leafWatch) push(ch rpc.LeafChange) {
+	w.mu.Lock()
+	if len(w.queue) == maxQueuedNotifications {
+		w.queue = w.queue[1:]
+		w.dropped++
+	}
+	w.queue = append(w.queue, ch)
+	w.mu.Unlock()
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (w *leafWatch) pop() (rpc.LeafChange, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.lastActive = time.Now()
+	if len(w.queue) == 0 {
+		return rpc.LeafChange{}, false
+	}
+	ch := w.queue[0]
+	w.queue = w.queue[1:]
+	ch.Dropped = w.dropped
+	w.dropped = 0
+	return ch, true
+}
+
+func (w *leafWatch) idle() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return time.Since(w.lastActive) > notificationIdleTimeout
+}
+
+// watchNode holds the watches on the leaf at its path, and the nodes for
+// the paths below.
+type watchNode struct {
+	children map[string]*watchNode
+	watches  map[string]*leafWatch
+}
+
+func newWatchNode() *watchNode {
+	return &watchNode{
+		children: make(map[string]*watchNode),
+		watches:  make(map[string]*leafWatch),
+	}
+}
+
+// collect adds the watches at and below n to out.
+func (n *watchNode) collect(out map[string]*leafWatch) {
+	for id, w := range n.watches {
+		out[id] = w
+	}
+	for _, ch := range n.children {
+		ch.collect(out)
+	}
+}
+
+type leafWatches struct {
+	mu   sync.Mutex
+	m    map[string]*leafWatch
+	root *watchNode
+}
+
+var leafWatchers = &leafWatches{
+	m:    make(map[string]*leafWatch),
+	root: newWatchNode(),
+}
+
+func (l *leafWatches) add(w *leafWatch) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.m[w.id] = w
+	n := l.root
+	for _, elem := range w.path {
+		ch, ok := n.children[elem]
+		if !ok {
+			ch = newWatchNode()
+			n.children[elem] = ch
+		}
+		n = ch
+	}
+	n.watches[w.id] = w
+}
+
+func (l *leafWatches) removeLocked(w *leafWatch) {
+	delete(l.m, w.id)
+	n := l.root
+	nodes := []*watchNode{n}
+	for _, elem := range w.path {
+		if n = n.children[elem]; n == nil {
+			return
+		}
+		nodes = append(nodes, n)
+	}
+	delete(n.watches, w.id)
+	// Prune nodes left without watches
+	for i := len(w.path) - 1; i >= 0; i-- {
+		n := nodes[i+1]
+		if len(n.watches) != 0 || len(n.children) != 0 {
+			break
+		}
+		delete(nodes[i].children, w.path[i])
+	}
+}
+
+func (l *leafWatches) remove(w *leafWatch) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.removeLocked(w)
+}
+
+// affected returns the watches on leaves at or below the changed paths.
+func (l *leafWatches) affected(changes []session.CommitChange) map[string]*leafWatch {
+	out := make(map[string]*leafWatch)
+	for _, ch := range changes {
+		n := l.root
+		for _, elem := range ch.Path {
+			if n = n.children[elem]; n == nil {
+				break
+			}
+		}
+		if n != nil {
+			n.collect(out)
+		}
+	}
+	return out
+}
+
+// committedLeafValue returns the value of the leaf at path in t.
+func committedLeafValue(t *data.Node, path []string) (string, bool) {
+	n := t
+	for _, elem := range path {
+		if n = n.Child(elem); n == nil {
+			return "", false
+		}
+	}
+	for _, val := range n.Children() {
+		return val.Name(), true
+	}
+	return "", true
+}
+
+// publish queues the change for each watch on a leaf whose value the
+// commit changed, discarding any watches left idle.
+func (l *leafWatches) publish(n *session.CommitNotification) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, w := range l.affected(n.Changes) {
+		if w.idle() {
+			l.removeLocked(w)
+			continue
+		}
+		old, hadOld := committedLeafValue(n.Old, w.path)
+		new, hasNew := committedLeafValue(n.New, w.path)
+		if hadOld == hasNew && old == new {
+			continue
+		}
+		ch := rpc.LeafChange{
+			CommitId: n.Id,
+			User:     n.User,
+			Time:     n.Time.Format(time.RFC3339),
+			Op:       session.ChangeUpdate,
+			Path:     pathutil.Pathstr(w.path),
+			Old:      old,
+			New:      new,
+		}
+		switch {
+		case !hadOld:
+			ch.Op = session.ChangeCreate
+		case !hasNew:
+			ch.Op = session.ChangeDelete
+		}
+		if w.secret {
+			if hadOld {
+				ch.Old = hiddenSecret
+			}
+			if hasNew {
+				ch.New = hiddenSecret
+			}
+		}
+		w.push(ch)
+	}
+}
+
+func newNoWatchError(id string) error {
+	err := mgmterror.NewInvalidValueApplicationError()
+	err.Message = fmt.Sprintf("No watch %s", id)
+	return err
+}
+
+// getLeafWatch returns the watch, which must belong to the caller.
+func (d *Disp) getLeafWatch(id string) (*leafWatch, error) {
+	leafWatchers.mu.Lock()
+	w, ok := leafWatchers.m[id]
+	leafWatchers.mu.Unlock()
+	if !ok {
+		return nil, newNoWatchError(id)
+	}
+	if w.uid != d.ctx.Uid && !d.ctx.Configd && !d.ctx.Superuser {
+		return nil, newNoWatchError(id)
+	}
+	return w, nil
+}
+
+// WatchLeaf registers for changes to the committed value of the leaf at
+// path, returning the id to wait on.
+func (d *Disp) WatchLeaf(path string) (string, error) {
+	ps := pathutil.Makepath(path)
+	if !d.authRead(ps) {
+		return "", mgmterror.NewAccessDeniedApplicationError()
+	}
+	if err := d.validatePath(ps); err != nil {
+		return "", err
+	}
+	sch := schema.Descendant(d.ms, ps)
+	if _, ok := sch.(schema.Leaf); !ok {
+		err := mgmterror.NewInvalidValueApplicationError()
+		err.Path = path
+		err.Message = "Only leaves may be watched"
+		return "", err
+	}
+
+	id, err := newSubscriptionId()
+	if err != nil {
+		return "", err
+	}
+	leafWatchers.add(&leafWatch{
+		id:         id,
+		uid:        d.ctx.Uid,
+		path:       ps,
+		secret:     sch.ConfigdExt().Secret && !configd.ShowSecrets(d.ctx),
+		wake:       make(chan struct{}, 1),
+		lastActive: time.Now(),
+	})
+	return id, nil
+}
+
+func (d *Disp) UnwatchLeaf(id string) (bool, error) {
+	w, err := d.getLeafWatch(id)
+	if err != nil {
+		return false, err
+	}
+	leafWatchers.remove(w)
+	return true, nil
+}
+
+// WaitLeafChange returns the next change to the watched leaf, as a JSON
+// encoded rpc.LeafChange, waiting up to timeout seconds for one.  An empty
+// string is returned if none arrives in time.
+func (d *Disp) WaitLeafChange(id string, timeout int) (string, error) {
+	w, err := d.getLeafWatch(id)
+	if err != nil {
+		return "", err
+	}
+	wait := time.Duration(timeout) * time.Second
+	if wait > maxNotificationWait {
+		wait = maxNotificationWait
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	for {
+		if ch, ok := w.pop(); ok {
+			// Access may have been revoked since the watch was set up.
+			if !d.authRead(w.path) {
+				return "", mgmterror.NewAccessDeniedApplicationError()
+			}
+			buf, err := json.Marshal(ch)
+			return string(buf), err
+		}
+		select {
+		case <-w.wake:
+		case <-timer.C:
+			return "", nil
+		}
+	}
+}
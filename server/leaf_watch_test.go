@@ -0,0 +1,109 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/danos/configd/rpc"
+	"github.com/danos/configd/session"
+	"github.com/danos/configd/session/sessiontest"
+)
+
+const leafWatchSchema = `
+container cont {
+	leaf value {
+		type string;
+	}
+	leaf other {
+		type string;
+	}
+	container inner {
+		leaf deep {
+			type string;
+		}
+	}
+}`
+
+func TestWatchLeaf(t *testing.T) {
+	srv, sess := sessiontest.NewTestSpec(t).
+		SetSingleSchema(leafWatchSchema).
+		Init()
+	srv.Cmgr.AddCommitListener(leafWatchers.publish)
+	d := &Disp{smgr: srv.Smgr, cmgr: srv.Cmgr, ms: srv.Ms,
+		msFull: srv.MsFull, ctx: srv.Ctx}
+
+	if _, err := d.WatchLeaf("/cont/inner"); err == nil {
+		t.Fatal("Watched a container")
+	}
+	id, err := d.WatchLeaf("/cont/value")
+	if err != nil {
+		t.Fatalf("Unable to watch: %s", err)
+	}
+	defer d.UnwatchLeaf(id)
+	deepId, err := d.WatchLeaf("/cont/inner/deep")
+	if err != nil {
+		t.Fatalf("Unable to watch: %s", err)
+	}
+	defer d.UnwatchLeaf(deepId)
+
+	commit := func() {
+		if _, errs, ok := sess.Commit(srv.Ctx, "", false); !ok {
+			t.Fatalf("Unable to commit: %v", errs)
+		}
+	}
+	wait := func(id string) rpc.LeafChange {
+		out, err := d.WaitLeafChange(id, 1)
+		if err != nil || out == "" {
+			t.Fatalf("No change: %v", err)
+		}
+		var ch rpc.LeafChange
+		if err := json.Unmarshal([]byte(out), &ch); err != nil {
+			t.Fatalf("Invalid change %s: %s", out, err)
+		}
+		return ch
+	}
+
+	sessiontest.ValidateSet(t, sess, srv.Ctx,
+		[]string{"cont", "other", "foo"}, false)
+	commit()
+	if out, _ := d.WaitLeafChange(id, 0); out != "" {
+		t.Fatalf("Unexpected change of other leaf: %s", out)
+	}
+
+	sessiontest.ValidateSet(t, sess, srv.Ctx,
+		[]string{"cont", "value", "one"}, false)
+	commit()
+	ch := wait(id)
+	if ch.Op != session.ChangeCreate || ch.New != "one" ||
+		ch.Path != "/cont/value" {
+		t.Fatalf("Unexpected change: %+v", ch)
+	}
+
+	sessiontest.ValidateSet(t, sess, srv.Ctx,
+		[]string{"cont", "value", "two"}, false)
+	commit()
+	ch = wait(id)
+	if ch.Op != session.ChangeUpdate || ch.Old != "one" || ch.New != "two" {
+		t.Fatalf("Unexpected change: %+v", ch)
+	}
+
+	// Creating the container reaches the leaf below it
+	sessiontest.ValidateSet(t, sess, srv.Ctx,
+		[]string{"cont", "inner", "deep", "x"}, false)
+	commit()
+	if ch := wait(deepId); ch.Op != session.ChangeCreate || ch.New != "x" {
+		t.Fatalf("Unexpected change: %+v", ch)
+	}
+
+	if err := sess.Delete(srv.Ctx, []string{"cont"}); err != nil {
+		t.Fatalf("Unable to delete: %s", err)
+	}
+	commit()
+	if ch := wait(id); ch.Op != session.ChangeDelete || ch.Old != "two" {
+		t.Fatalf("Unexpected change: %+v", ch)
+	}
+}
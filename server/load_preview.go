@@ -0,0 +1,185 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/danos/config/schema"
+	"github.com/danos/config/union"
+	"github.com/danos/configd"
+	"github.com/danos/configd/session"
+	"github.com/danos/mgmterror"
+	"github.com/danos/utils/pathutil"
+)
+
+// Load preview
+//
+// A configuration from another device, or another release, may hold
+// paths this device doesn't know, and secrets shown as hidden when it was
+// saved.  Loading it showed what was dropped only as a list of warnings,
+// after the candidate had been replaced.  LoadPreview loads the file into
+// a scratch session instead and reports, without touching the caller's
+// candidate:
+//
+//	invalid       - paths that are unknown, or whose values aren't valid
+//	needs-feature - paths known only with a feature that isn't enabled
+//	secrets       - secrets saved hidden, whose values must be re-entered
+//
+// Telling the paths needing a feature from those that are unknown needs
+// the schema compiled with every feature enabled, which the daemon may
+// supply through its SchemaLoader; without it they are reported invalid.
+// Values of secrets in the reported paths are hidden unless the caller
+// may see secrets.
+
+const (
+	LoadPreviewInvalid      = "invalid"
+	LoadPreviewNeedsFeature = "needs-feature"
+	LoadPreviewSecrets      = "secrets"
+)
+
+// FeatureSchemaLoader is implemented by a SchemaLoader able to compile the
+// schema with every feature enabled.
+type FeatureSchemaLoader interface {
+	LoadAllFeatures() (schema.ModelSet, error)
+}
+
+func loadAllFeatures() schema.ModelSet {
+	schemaReloader.mu.Lock()
+	load, ok := schemaReloader.load.(FeatureSchemaLoader)
+	schemaReloader.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	ms, err := load.LoadAllFeatures()
+	if err != nil {
+		return nil
+	}
+	return ms
+}
+
+// loadWarningPath returns the path a load warning is for.
+func loadWarningPath(err error) []string {
+	me, ok := err.(mgmterror.Formattable)
+	if !ok {
+		return nil
+	}
+	path := pathutil.Makepath(me.GetPath())
+	if _, ok := err.(*mgmterror.UnknownElementApplicationError); ok {
+		// The path is of the unknown element's parent.
+		if len(me.GetInfo()) > 0 {
+			path = append(path, me.GetInfo()[0].Value)
+		}
+	}
+	return path
+}
+
+// hiddenSecretValue reports whether path sets a secret to the value shown
+// in place of a hidden secret.
+func hiddenSecretValue(sch schema.Node, path []string) bool {
+	if len(path) == 0 || path[len(path)-1] != session.RedactedSecret {
+		return false
+	}
+	parent := schema.Descendant(sch, path[:len(path)-1])
+	return parent != nil && parent.ConfigdExt().Secret
+}
+
+type loadPreview struct {
+	ms          schema.ModelSet
+	allFeatures schema.ModelSet
+	showSecrets bool
+	report      map[string][]string
+}
+
+func (p *loadPreview) add(kind string, sch schema.Node, path []string) {
+	if !p.showSecrets {
+		path = session.RedactPath(sch, path)
+	}
+	p.report[kind] = append(p.report[kind], pathutil.Pathstr(path))
+}
+
+func (p *loadPreview) addWarning(err error) {
+	path := loadWarningPath(err)
+	switch {
+	case hiddenSecretValue(p.ms, path):
+		p.add(LoadPreviewSecrets, p.ms, path)
+	case p.allFeatures != nil && schema.Descendant(p.allFeatures, path) != nil:
+		p.add(LoadPreviewNeedsFeature, p.allFeatures, path)
+	default:
+		p.add(LoadPreviewInvalid, p.ms, path)
+	}
+}
+
+// findHiddenSecrets adds the secrets below n set to the hidden value.
+func (p *loadPreview) findHiddenSecrets(n union.Node, path []string) {
+	for _, ch := range n.SortedChildren() {
+		chPath := pathutil.CopyAppend(path, ch.Name())
+		if hiddenSecretValue(p.ms, chPath) {
+			p.add(LoadPreviewSecrets, p.ms, chPath)
+			continue
+		}
+		p.findHiddenSecrets(ch, chPath)
+	}
+}
+
+func (d *Disp) loadPreviewInternal(file string) (map[string][]string, error) {
+	sn := "LOADPREVIEW" + strconv.Itoa(int(d.ctx.Pid))
+	if _, err := d.SessionSetup(sn); err != nil {
+		return nil, err
+	}
+	defer d.SessionTeardown(sn)
+	scratch, err := d.smgr.Get(d.ctx, sn)
+	if err != nil {
+		return nil, err
+	}
+	err, warns := scratch.Load(d.ctx, file, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &loadPreview{
+		ms:          d.ms,
+		showSecrets: configd.ShowSecrets(d.ctx),
+		report: map[string][]string{
+			LoadPreviewInvalid:      {},
+			LoadPreviewNeedsFeature: {},
+			LoadPreviewSecrets:      {},
+		},
+	}
+	if len(warns) != 0 {
+		p.allFeatures = loadAllFeatures()
+	}
+	for _, warn := range warns {
+		p.addWarning(warn)
+	}
+	ut, err := scratch.GetTree(d.ctx, nil,
+		&session.TreeOpts{Defaults: false, Secrets: true})
+	if err != nil {
+		return nil, err
+	}
+	if ut != nil {
+		p.findHiddenSecrets(ut, nil)
+	}
+	for _, paths := range p.report {
+		sort.Strings(paths)
+	}
+	return p.report, nil
+}
+
+// LoadPreview reports what of the configuration in file would not survive
+// being loaded, without loading it into a session.
+func (d *Disp) LoadPreview(file string) (map[string][]string, error) {
+	args := d.newCommandArgsForAaa("load-preview", []string{file}, nil)
+	if !d.authCommand(args) {
+		return nil, mgmterror.NewAccessDeniedApplicationError()
+	}
+
+	ret, err := d.accountCmdWrap(args, func() (interface{}, error) {
+		return d.loadPreviewInternal(file)
+	})
+	report, _ := ret.(map[string][]string)
+	return report, err
+}
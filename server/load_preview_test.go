@@ -0,0 +1,111 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/danos/config/schema"
+	"github.com/danos/configd/session/sessiontest"
+)
+
+const loadPreviewSchema = `
+container cont {
+	leaf value {
+		type uint8;
+	}
+	leaf password {
+		type string;
+		configd:secret "true";
+	}
+}`
+
+const loadPreviewFeatureSchema = `
+container cont {
+	leaf value {
+		type uint8;
+	}
+	leaf password {
+		type string;
+		configd:secret "true";
+	}
+	leaf extra {
+		type string;
+	}
+}`
+
+const loadPreviewConfig = `
+cont {
+	value 300
+	password "********"
+	extra foo
+	bogus bar
+}
+`
+
+type testFeatureLoader struct {
+	ms schema.ModelSet
+}
+
+func (l *testFeatureLoader) Load() (
+	schema.ModelSet, schema.ModelSet, schema.ComponentManager, error,
+) {
+	return nil, nil, nil, nil
+}
+
+func (l *testFeatureLoader) Loaded() {}
+
+func (l *testFeatureLoader) LoadAllFeatures() (schema.ModelSet, error) {
+	return l.ms, nil
+}
+
+func TestLoadPreview(t *testing.T) {
+	f, err := ioutil.TempFile("", "configd-load-preview")
+	if err != nil {
+		t.Fatalf("Unable to create config file: %s", err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(loadPreviewConfig)
+	f.Close()
+
+	full, _ := sessiontest.NewTestSpec(t).
+		SetSingleSchema(loadPreviewFeatureSchema).
+		Init()
+	schemaReloader.mu.Lock()
+	schemaReloader.load = &testFeatureLoader{ms: full.Ms}
+	schemaReloader.mu.Unlock()
+	defer func() {
+		schemaReloader.mu.Lock()
+		schemaReloader.load = nil
+		schemaReloader.mu.Unlock()
+	}()
+
+	srv, sess := sessiontest.NewTestSpec(t).
+		SetSingleSchema(loadPreviewSchema).
+		Init()
+	d := &Disp{smgr: srv.Smgr, cmgr: srv.Cmgr, ms: srv.Ms,
+		msFull: srv.MsFull, ctx: srv.Ctx}
+
+	report, err := d.LoadPreview(f.Name())
+	if err != nil {
+		t.Fatalf("Unable to preview load: %s", err)
+	}
+	exp := map[string][]string{
+		LoadPreviewInvalid:      {"/cont/bogus", "/cont/value/300"},
+		LoadPreviewNeedsFeature: {"/cont/extra"},
+		LoadPreviewSecrets:      {"/cont/password/********"},
+	}
+	if !reflect.DeepEqual(report, exp) {
+		t.Fatalf("Unexpected report\nexpect: %v\nactual: %v", exp, report)
+	}
+
+	// The candidate is untouched.
+	if sess.Exists(srv.Ctx, []string{"cont"}) {
+		t.Fatal("Preview changed the candidate")
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/danos/configd/rpc"
+	"github.com/danos/mgmterror"
+)
+
+// Lock owners are recorded by the session (see session/lock_info.go).
+// Anyone may see who holds a session's lock, but only superusers may force
+// it to be released, and doing so is accounted like any other command.
+
+// SessionLockWithReason locks the session, recording why.
+func (d *Disp) SessionLockWithReason(sid, reason string) (int32, error) {
+	sess, err := d.smgr.Get(d.ctx, sid)
+	if err != nil {
+		return -1, err
+	}
+	return sess.LockWithReason(d.ctx, reason)
+}
+
+// SessionLockInfo returns who holds the session's lock as a JSON encoded
+// rpc.LockInfo, or an empty string if it is unlocked.
+func (d *Disp) SessionLockInfo(sid string) (string, error) {
+	sess, err := d.smgr.Get(d.ctx, sid)
+	if err != nil {
+		return "", err
+	}
+	info, err := sess.LockInfo(d.ctx)
+	if err != nil || info == nil {
+		return "", err
+	}
+	out := &rpc.LockInfo{
+		Pid:    info.Pid,
+		User:   info.User,
+		Reason: info.Reason,
+	}
+	if !info.Time.IsZero() {
+		out.Time = info.Time.Format(time.RFC3339)
+	}
+	buf, err := json.Marshal(out)
+	if err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+// SessionForceUnlock releases the session's lock, whichever process holds
+// it, returning that process.
+func (d *Disp) SessionForceUnlock(sid string) (int32, error) {
+	args := d.newCommandArgsForAaa("force-unlock", []string{sid}, nil)
+	if !d.ctx.Superuser || !d.authCommand(args) {
+		return -1, mgmterror.NewAccessDeniedApplicationError()
+	}
+	sess, err := d.smgr.Get(d.ctx, sid)
+	if err != nil {
+		return -1, err
+	}
+
+	ret, err := d.accountCmdWrap(args, func() (interface{}, error) {
+		return sess.ForceUnlock(d.ctx)
+	})
+	return ret.(int32), err
+}
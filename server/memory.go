@@ -0,0 +1,92 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"runtime"
+	"runtime/debug"
+	"sync/atomic"
+)
+
+// Memory release
+//
+// Loading or retrieving a large configuration allocates far more than
+// configd needs at rest, and the Go runtime is slow to return the freed
+// memory to the OS, leaving the daemon's footprint inflated.  For the
+// methods that can do this we measure what was allocated, and if it is over
+// memReleaseThreshold we force a GC and return freed memory to the OS once
+// the call has completed.  Only one release runs at a time, so a burst of
+// large operations results in a single release.
+
+var memReleaseThreshold uint64 = 64 * 1024 * 1024
+
+var memTrackedMethods = map[string]struct{}{
+	"CopyConfig":                 {},
+	"ExportList":                 {},
+	"Load":                       {},
+	"LoadFrom":                   {},
+	"LoadReportWarnings":         {},
+	"Merge":                      {},
+	"MergeReportWarnings":        {},
+	"ReadConfigFile":             {},
+	"ReadConfigFileRaw":          {},
+	"Show":                       {},
+	"ShowConfigWithContextDiffs": {},
+	"ShowDefaults":               {},
+	"ShowWithOpts":               {},
+	"TreeGet":                    {},
+	"TreeGetFull":                {},
+}
+
+var (
+	memReleaseRunning uint32
+	memReleases       uint64
+)
+
+func releaseMemory() {
+	if !atomic.CompareAndSwapUint32(&memReleaseRunning, 0, 1) {
+		return
+	}
+	go func() {
+		defer atomic.StoreUint32(&memReleaseRunning, 0)
+		debug.FreeOSMemory()
+		atomic.AddUint64(&memReleases, 1)
+	}()
+}
+
+// trackMemory returns a function to be called when the method completes,
+// which releases memory if the method allocated more than the threshold.
+func trackMemory(method string) func() {
+	if _, ok := memTrackedMethods[method]; !ok {
+		return func() {}
+	}
+	var before runtime.MemStats
+	runtime.ReadMemStats(&before)
+	return func() {
+		var after runtime.MemStats
+		runtime.ReadMemStats(&after)
+		if after.TotalAlloc-before.TotalAlloc >= memReleaseThreshold {
+			releaseMemory()
+		}
+	}
+}
+
+// GetHeapStats returns the current heap statistics, in bytes, along with
+// the number of GCs run and of memory releases triggered by large
+// operations.
+func (d *Disp) GetHeapStats() (map[string]uint64, error) {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return map[string]uint64{
+		"heap-alloc":      ms.HeapAlloc,
+		"heap-inuse":      ms.HeapInuse,
+		"heap-idle":       ms.HeapIdle,
+		"heap-released":   ms.HeapReleased,
+		"heap-sys":        ms.HeapSys,
+		"sys":             ms.Sys,
+		"num-gc":          uint64(ms.NumGC),
+		"memory-releases": atomic.LoadUint64(&memReleases),
+	}, nil
+}
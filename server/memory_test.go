@@ -0,0 +1,74 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var memTestSink []byte
+
+func waitForMemReleases(exp uint64) bool {
+	for i := 0; i < 100; i++ {
+		if atomic.LoadUint64(&memReleases) >= exp &&
+			atomic.LoadUint32(&memReleaseRunning) == 0 {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
+func TestTrackMemoryReleasesAfterLargeOperation(t *testing.T) {
+	oldThreshold := memReleaseThreshold
+	memReleaseThreshold = 1024 * 1024
+	defer func() { memReleaseThreshold = oldThreshold }()
+
+	waitForMemReleases(0)
+	releases := atomic.LoadUint64(&memReleases)
+
+	done := trackMemory("Load")
+	memTestSink = make([]byte, 4*1024*1024)
+	done()
+	memTestSink = nil
+
+	if !waitForMemReleases(releases + 1) {
+		t.Fatalf("Memory not released after large operation")
+	}
+}
+
+func TestTrackMemoryIgnoresSmallAndUntrackedOperations(t *testing.T) {
+	waitForMemReleases(0)
+	releases := atomic.LoadUint64(&memReleases)
+
+	done := trackMemory("Load")
+	done()
+	done = trackMemory("Exists")
+	memTestSink = make([]byte, 2*memReleaseThreshold)
+	done()
+	memTestSink = nil
+
+	if waitForMemReleases(releases + 1) {
+		t.Fatalf("Memory released unexpectedly")
+	}
+}
+
+func TestGetHeapStats(t *testing.T) {
+	stats, err := (&Disp{}).GetHeapStats()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	for _, key := range []string{"heap-alloc", "heap-sys", "num-gc",
+		"memory-releases"} {
+		if _, ok := stats[key]; !ok {
+			t.Fatalf("Heap stats missing %s: %v", key, stats)
+		}
+	}
+	if stats["heap-alloc"] == 0 {
+		t.Fatalf("Heap alloc should be non-zero")
+	}
+}
@@ -0,0 +1,450 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/danos/config/auth"
+	"github.com/danos/config/data"
+	"github.com/danos/config/schema"
+	"github.com/danos/configd/common"
+	"github.com/danos/configd/session"
+	"github.com/danos/utils/pathutil"
+)
+
+// NETCONF Access Control Model
+//
+// ACM rules are per path and per command, and set outside the
+// configuration.  NACM (RFC 8341) rules are configured under /nacm, from
+// ietf-netconf-acm, and applied as soon as they are committed.  Each
+// rule-list applies to the groups it names, which are those configured
+// under /nacm/groups holding the user, and the user's system groups.  The
+// rules of the rule-lists applying to the user are tried in order, and
+// the first matching the module, the RPC or the path (the node and those
+// below it) and the operation permits or denies it.  Requests no rule
+// matches get the default for their kind, except for /nacm itself, which
+// is denied.
+//
+// NACM applies once /nacm is configured and enable-nacm isn't false, and
+// only to users other than configd and superusers, who thus can always
+// recover from a locked out configuration.  It is checked after ACM, so
+// a request must be permitted by both.
+
+const (
+	nacmRead   = "read"
+	nacmCreate = "create"
+	nacmUpdate = "update"
+	nacmDelete = "delete"
+	nacmExec   = "exec"
+
+	nacmAll = "*"
+)
+
+type nacmRule struct {
+	module string
+	rpc    string   // Set for protocol operation rules
+	path   []string // Set for data node rules
+	ops    map[string]bool
+	permit bool
+}
+
+type nacmRuleList struct {
+	groups []string
+	rules  []nacmRule
+}
+
+type nacmConfig struct {
+	enabled      bool
+	readDefault  bool // Permit
+	writeDefault bool
+	execDefault  bool
+	groups       map[string]map[string]bool // Users of each group
+	ruleLists    []nacmRuleList
+}
+
+// nacmRequest is a request to check, either for a protocol operation (rpc)
+// or for a data node (path).
+type nacmRequest struct {
+	module string
+	rpc    string
+	path   []string
+	op     string
+}
+
+var nacmRules = struct {
+	mu  sync.RWMutex
+	cfg *nacmConfig
+}{}
+
+func nacmChildValues(n *data.Node, name string) []string {
+	if n = n.Child(name); n == nil {
+		return nil
+	}
+	var vals []string
+	for _, ch := range n.Children() {
+		vals = append(vals, ch.Name())
+	}
+	return vals
+}
+
+func nacmPermitLeaf(n *data.Node, name string, def bool) bool {
+	if val, ok := committedLeafValue(n, []string{name}); ok {
+		return val == "permit"
+	}
+	return def
+}
+
+// nacmPath returns the path of an instance identifier, eg
+// /if:interfaces/if:interface[if:name='dp0s1']/if:mtu, without its
+// prefixes and with each key's value as a path element.
+func nacmPath(id string) []string {
+	var steps []string
+	var step strings.Builder
+	var quote rune
+	for _, c := range id {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '/':
+			steps = append(steps, step.String())
+			step.Reset()
+			continue
+		}
+		step.WriteRune(c)
+	}
+	steps = append(steps, step.String())
+
+	path := []string{}
+	for _, step := range steps {
+		name := step
+		preds := ""
+		if i := strings.IndexByte(step, '['); i >= 0 {
+			name, preds = step[:i], step[i:]
+		}
+		if i := strings.IndexByte(name, ':'); i >= 0 {
+			name = name[i+1:]
+		}
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		path = append(path, name)
+		for preds != "" {
+			end := strings.IndexByte(preds, ']')
+			if end < 0 {
+				break
+			}
+			pred := preds[1:end]
+			if i := strings.IndexByte(pred, '='); i >= 0 {
+				path = append(path,
+					strings.Trim(strings.TrimSpace(pred[i+1:]), `'"`))
+			}
+			preds = preds[end+1:]
+		}
+	}
+	return path
+}
+
+func newNacmRule(n *data.Node) nacmRule {
+	rule := nacmRule{module: nacmAll}
+	if val, ok := committedLeafValue(n, []string{"module-name"}); ok {
+		rule.module = val
+	}
+	if val, ok := committedLeafValue(n, []string{"rpc-name"}); ok {
+		rule.rpc = val
+	}
+	if val, ok := committedLeafValue(n, []string{"path"}); ok {
+		rule.path = nacmPath(val)
+	}
+	if val, ok := committedLeafValue(n, []string{"access-operations"}); ok &&
+		val != nacmAll {
+		rule.ops = make(map[string]bool)
+		for _, op := range strings.Fields(val) {
+			rule.ops[op] = true
+		}
+	}
+	rule.permit = nacmPermitLeaf(n, "action", false)
+	return rule
+}
+
+// newNacmConfig returns the NACM configuration in the tree t, or nil if
+// there is none.
+func newNacmConfig(t *data.Node) *nacmConfig {
+	if t == nil {
+		return nil
+	}
+	n := t.Child("nacm")
+	if n == nil {
+		return nil
+	}
+
+	cfg := &nacmConfig{
+		enabled:      true,
+		readDefault:  nacmPermitLeaf(n, "read-default", true),
+		writeDefault: nacmPermitLeaf(n, "write-default", false),
+		execDefault:  nacmPermitLeaf(n, "exec-default", true),
+		groups:       make(map[string]map[string]bool),
+	}
+	if val, ok := committedLeafValue(n, []string{"enable-nacm"}); ok {
+		cfg.enabled = val == "true"
+	}
+	if groups := n.Child("groups"); groups != nil {
+		if group := groups.Child("group"); group != nil {
+			for _, g := range group.Children() {
+				users := make(map[string]bool)
+				for _, user := range nacmChildValues(g, "user-name") {
+					users[user] = true
+				}
+				cfg.groups[g.Name()] = users
+			}
+		}
+	}
+	if ruleList := n.Child("rule-list"); ruleList != nil {
+		for _, rl := range ruleList.Children() {
+			list := nacmRuleList{groups: nacmChildValues(rl, "group")}
+			if rule := rl.Child("rule"); rule != nil {
+				for _, r := range rule.Children() {
+					list.rules = append(list.rules, newNacmRule(r))
+				}
+			}
+			cfg.ruleLists = append(cfg.ruleLists, list)
+		}
+	}
+	return cfg
+}
+
+func (r *nacmRule) matches(req *nacmRequest) bool {
+	if r.module != nacmAll && r.module != req.module {
+		return false
+	}
+	if r.ops != nil && !r.ops[req.op] {
+		return false
+	}
+	switch {
+	case r.rpc != "":
+		return req.rpc != "" && (r.rpc == nacmAll || r.rpc == req.rpc)
+	case r.path != nil:
+		if req.rpc != "" || len(req.path) < len(r.path) {
+			return false
+		}
+		for i, elem := range r.path {
+			if req.path[i] != elem {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (c *nacmConfig) userGroups(user string, sysGroups []string) map[string]bool {
+	groups := make(map[string]bool)
+	for name, users := range c.groups {
+		if users[user] {
+			groups[name] = true
+		}
+	}
+	for _, name := range sysGroups {
+		groups[name] = true
+	}
+	return groups
+}
+
+// permitted reports whether the user, with the given system groups, may
+// make the request.
+func (c *nacmConfig) permitted(
+	user string,
+	sysGroups []string,
+	req *nacmRequest,
+) bool {
+	if c == nil || !c.enabled {
+		return true
+	}
+	groups := c.userGroups(user, sysGroups)
+	for _, list := range c.ruleLists {
+		applies := false
+		for _, group := range list.groups {
+			if group == nacmAll || groups[group] {
+				applies = true
+				break
+			}
+		}
+		if !applies {
+			continue
+		}
+		for i := range list.rules {
+			if list.rules[i].matches(req) {
+				return list.rules[i].permit
+			}
+		}
+	}
+
+	switch {
+	case req.rpc == "" && len(req.path) > 0 && req.path[0] == "nacm":
+		return false
+	case req.op == nacmRead:
+		return c.readDefault
+	case req.op == nacmExec:
+		return c.execDefault
+	}
+	return c.writeDefault
+}
+
+func (c *nacmConfig) permittedLog(
+	user string,
+	sysGroups []string,
+	req *nacmRequest,
+) bool {
+	if c.permitted(user, sysGroups, req) {
+		return true
+	}
+	// Not the path, as it may hold secrets.
+	common.Log(common.LevelDebug, common.TypeAaa,
+		"NACM denied", "user", user, "module", req.module,
+		"rpc", req.rpc, "operation", req.op)
+	return false
+}
+
+func loadNacmRules(t *data.Node) {
+	cfg := newNacmConfig(t)
+	nacmRules.mu.Lock()
+	nacmRules.cfg = cfg
+	nacmRules.mu.Unlock()
+}
+
+// applyNacmRules applies the rules committed, as a commit listener.
+func applyNacmRules(n *session.CommitNotification) {
+	loadNacmRules(n.New)
+}
+
+func currentNacmRules() *nacmConfig {
+	nacmRules.mu.RLock()
+	defer nacmRules.mu.RUnlock()
+	return nacmRules.cfg
+}
+
+// nacmModule returns the name of the module defining the node at path,
+// or of its deepest ancestor in the schema.
+func nacmModule(ms schema.ModelSet, path []string) string {
+	ns := ""
+	for i := len(path); i > 0 && ns == ""; i-- {
+		if sch := schema.Descendant(ms, path[:i]); sch != nil {
+			ns = sch.Namespace()
+		}
+	}
+	for name, mod := range ms.Modules() {
+		if mod.Namespace() == ns {
+			return name
+		}
+	}
+	return ""
+}
+
+// nacmAuth checks requests against the NACM rules after the user's
+// Auther.
+type nacmAuth struct {
+	auth.Auther
+	user string
+	ms   schema.ModelSet
+}
+
+func newNacmAuth(a auth.Auther, user string, ms schema.ModelSet) *nacmAuth {
+	return &nacmAuth{Auther: a, user: user, ms: ms}
+}
+
+func (a *nacmAuth) permittedPath(groups, path []string, op string) bool {
+	cfg := currentNacmRules()
+	if cfg == nil || !cfg.enabled {
+		return true
+	}
+	return cfg.permittedLog(a.user, groups, &nacmRequest{
+		module: nacmModule(a.ms, path),
+		path:   path,
+		op:     op,
+	})
+}
+
+func (a *nacmAuth) AuthorizeRead(
+	uid uint32,
+	groups, path []string,
+	attrs *pathutil.PathAttrs,
+) bool {
+	return a.Auther.AuthorizeRead(uid, groups, path, attrs) &&
+		a.permittedPath(groups, path, nacmRead)
+}
+
+func (a *nacmAuth) AuthorizeCreate(
+	uid uint32,
+	groups, path []string,
+	attrs *pathutil.PathAttrs,
+) bool {
+	return a.Auther.AuthorizeCreate(uid, groups, path, attrs) &&
+		a.permittedPath(groups, path, nacmCreate)
+}
+
+func (a *nacmAuth) AuthorizeUpdate(
+	uid uint32,
+	groups, path []string,
+	attrs *pathutil.PathAttrs,
+) bool {
+	return a.Auther.AuthorizeUpdate(uid, groups, path, attrs) &&
+		a.permittedPath(groups, path, nacmUpdate)
+}
+
+func (a *nacmAuth) AuthorizeDelete(
+	uid uint32,
+	groups, path []string,
+	attrs *pathutil.PathAttrs,
+) bool {
+	return a.Auther.AuthorizeDelete(uid, groups, path, attrs) &&
+		a.permittedPath(groups, path, nacmDelete)
+}
+
+var nacmPathOps = map[auth.AuthPerm]string{
+	auth.P_READ:    nacmRead,
+	auth.P_CREATE:  nacmCreate,
+	auth.P_UPDATE:  nacmUpdate,
+	auth.P_DELETE:  nacmDelete,
+	auth.P_EXECUTE: nacmExec,
+}
+
+func (a *nacmAuth) AuthorizePath(
+	uid uint32,
+	groups, path []string,
+	attrs *pathutil.PathAttrs,
+	perm auth.AuthPerm,
+) bool {
+	if !a.Auther.AuthorizePath(uid, groups, path, attrs, perm) {
+		return false
+	}
+	op, ok := nacmPathOps[perm]
+	if !ok {
+		return true
+	}
+	return a.permittedPath(groups, path, op)
+}
+
+func (a *nacmAuth) AuthorizeRPC(
+	uid uint32,
+	groups []string,
+	module, rpcName string,
+) bool {
+	if !a.Auther.AuthorizeRPC(uid, groups, module, rpcName) {
+		return false
+	}
+	cfg := currentNacmRules()
+	if cfg == nil || !cfg.enabled {
+		return true
+	}
+	return cfg.permittedLog(a.user, groups, &nacmRequest{
+		module: module,
+		rpc:    rpcName,
+		op:     nacmExec,
+	})
+}
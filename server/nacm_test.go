@@ -0,0 +1,184 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/danos/config/auth"
+	"github.com/danos/configd/session/sessiontest"
+)
+
+const nacmTestSchema = `
+container cont {
+	leaf value {
+		type string;
+	}
+	container secure {
+		leaf key {
+			type string;
+		}
+	}
+}
+container nacm {
+	leaf enable-nacm {
+		type boolean;
+	}
+	leaf write-default {
+		type enumeration {
+			enum permit;
+			enum deny;
+		}
+	}
+	container groups {
+		list group {
+			key name;
+			leaf name {
+				type string;
+			}
+			leaf-list user-name {
+				type string;
+			}
+		}
+	}
+	list rule-list {
+		key name;
+		ordered-by user;
+		leaf name {
+			type string;
+		}
+		leaf-list group {
+			type string;
+		}
+		list rule {
+			key name;
+			ordered-by user;
+			leaf name {
+				type string;
+			}
+			leaf module-name {
+				type string;
+			}
+			leaf rpc-name {
+				type string;
+			}
+			leaf path {
+				type string;
+			}
+			leaf access-operations {
+				type string;
+			}
+			leaf action {
+				type enumeration {
+					enum permit;
+					enum deny;
+				}
+			}
+		}
+	}
+}`
+
+func TestNacmPath(t *testing.T) {
+	tests := []struct {
+		id   string
+		path []string
+	}{
+		{"/", []string{}},
+		{"/cont", []string{"cont"}},
+		{"/t:cont/t:value", []string{"cont", "value"}},
+		{"/if:interfaces/if:interface[if:name='dp0s1']/if:mtu",
+			[]string{"interfaces", "interface", "dp0s1", "mtu"}},
+		{`/routes/route[prefix="10.0.0.0/8"]`,
+			[]string{"routes", "route", "10.0.0.0/8"}},
+	}
+	for _, test := range tests {
+		if got := nacmPath(test.id); !reflect.DeepEqual(got, test.path) {
+			t.Errorf("%s: expected %v, got %v", test.id, test.path, got)
+		}
+	}
+}
+
+func TestNacmRules(t *testing.T) {
+	srv, sess := sessiontest.NewTestSpec(t).
+		SetSingleSchema(nacmTestSchema).
+		Init()
+	srv.Cmgr.AddCommitListener(applyNacmRules)
+	defer loadNacmRules(nil)
+
+	a := newNacmAuth(auth.TestAutherAllowAll(), "alice", srv.MsFull)
+	read := func(path ...string) bool {
+		return a.AuthorizeRead(1000, nil, path, nil)
+	}
+	update := func(path ...string) bool {
+		return a.AuthorizeUpdate(1000, nil, path, nil)
+	}
+
+	// Without NACM configured everything is permitted.
+	if !update("cont", "value") || !update("nacm") {
+		t.Fatal("Denied without NACM configured")
+	}
+
+	for _, path := range [][]string{
+		{"nacm", "groups", "group", "operators", "user-name", "alice"},
+		{"nacm", "rule-list", "ops", "group", "operators"},
+		{"nacm", "rule-list", "ops", "rule", "no-secure",
+			"path", "/t:cont/t:secure"},
+		{"nacm", "rule-list", "ops", "rule", "no-secure", "action", "deny"},
+		{"nacm", "rule-list", "ops", "rule", "cont", "path", "/cont"},
+		{"nacm", "rule-list", "ops", "rule", "cont",
+			"access-operations", "update create"},
+		{"nacm", "rule-list", "ops", "rule", "cont", "action", "permit"},
+		{"nacm", "rule-list", "ops", "rule", "no-reboot",
+			"rpc-name", "reboot"},
+		{"nacm", "rule-list", "ops", "rule", "no-reboot", "action", "deny"},
+	} {
+		sessiontest.ValidateSet(t, sess, srv.Ctx, path, false)
+	}
+	if _, errs, ok := sess.Commit(srv.Ctx, "", false); !ok {
+		t.Fatalf("Unable to commit: %v", errs)
+	}
+
+	if !update("cont", "value") {
+		t.Error("Update permitted by rule denied")
+	}
+	if update("cont", "secure", "key") || read("cont", "secure", "key") {
+		t.Error("Access denied by earlier rule permitted")
+	}
+	if !read("cont", "value") {
+		t.Error("Read permitted by default denied")
+	}
+	if a.AuthorizeDelete(1000, nil, []string{"cont", "value"}, nil) {
+		t.Error("Delete denied by default permitted")
+	}
+	if read("nacm") || update("nacm") {
+		t.Error("NACM configuration accessible without a rule")
+	}
+	if a.AuthorizeRPC(1000, nil, "test-mod", "reboot") {
+		t.Error("RPC denied by rule permitted")
+	}
+	if !a.AuthorizeRPC(1000, nil, "test-mod", "ping") {
+		t.Error("RPC permitted by default denied")
+	}
+
+	// Rule lists apply only to their groups.
+	bob := newNacmAuth(auth.TestAutherAllowAll(), "bob", srv.MsFull)
+	if bob.AuthorizeUpdate(1000, nil, []string{"cont", "value"}, nil) {
+		t.Error("Rule applied to user outside its group")
+	}
+	if !bob.AuthorizeUpdate(1000, []string{"operators"},
+		[]string{"cont", "value"}, nil) {
+		t.Error("Rule not applied to user in its system group")
+	}
+
+	sessiontest.ValidateSet(t, sess, srv.Ctx,
+		[]string{"nacm", "enable-nacm", "false"}, false)
+	if _, errs, ok := sess.Commit(srv.Ctx, "", false); !ok {
+		t.Fatalf("Unable to commit: %v", errs)
+	}
+	if !update("cont", "secure", "key") {
+		t.Error("Denied with NACM disabled")
+	}
+}
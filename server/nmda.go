@@ -0,0 +1,248 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/danos/config/schema"
+	"github.com/danos/configd/rpc"
+	"github.com/danos/configd/session"
+	"github.com/danos/mgmterror"
+	"github.com/danos/utils/pathutil"
+)
+
+// NMDA datastores (RFC 8342)
+//
+// NMDA-aware NETCONF and RESTCONF front ends read a named datastore with
+// <get-data> (RFC 8526, RFC 8527) rather than choosing between <get> and
+// <get-config>.  GetData maps the datastore names onto rpc.DB:
+//
+//   running     - RUNNING
+//   candidate   - the session's candidate
+//   intended    - INTENDED, which is RUNNING as configd has no inactive or
+//                 templated configuration
+//   operational - OPERATIONAL, the applied configuration merged with state
+//
+// Operational data may be returned with RFC 7952 origin metadata, in the
+// JSON encodings only.  A node is "learned" if it is state, "default" if
+// it is configuration not explicitly set, and otherwise "intended".  As in
+// RFC 8342, a node's origin is that of its parent unless annotated, so only
+// top level nodes and nodes whose origin differs from their parent's are.
+
+const (
+	originMember = "ietf-origin:origin"
+
+	originIntended = "ietf-origin:intended"
+	originDefault  = "ietf-origin:default"
+	originLearned  = "ietf-origin:learned"
+)
+
+var nmdaDatastores = map[string]rpc.DB{
+	"running":     rpc.RUNNING,
+	"candidate":   rpc.CANDIDATE,
+	"intended":    rpc.INTENDED,
+	"operational": rpc.OPERATIONAL,
+}
+
+// parseDatastore returns the database for the datastore identity, which
+// may be qualified by the ietf-datastores module name.
+func parseDatastore(name string) (rpc.DB, error) {
+	db, ok := nmdaDatastores[strings.TrimPrefix(name, "ietf-datastores:")]
+	if !ok {
+		err := mgmterror.NewInvalidValueApplicationError()
+		err.Message = fmt.Sprintf("Unsupported datastore %s", name)
+		return rpc.AUTO, err
+	}
+	return db, nil
+}
+
+type originAnnotator struct {
+	d    *Disp
+	sess *session.Session
+}
+
+func (o *originAnnotator) origin(sch schema.Node, ps []string) string {
+	if !sch.Config() {
+		return originLearned
+	}
+	if def, err := o.sess.IsDefault(o.d.ctx, ps); err == nil && def {
+		return originDefault
+	}
+	return originIntended
+}
+
+func originMetadata(origin string) map[string]interface{} {
+	return map[string]interface{}{originMember: origin}
+}
+
+// entryKey returns the value of the list entry's key, which may be
+// qualified by its module name.
+func entryKey(entry map[string]interface{}, key string) (string, bool) {
+	for name, val := range entry {
+		if name == key || strings.HasSuffix(name, ":"+key) {
+			return fmt.Sprint(val), true
+		}
+	}
+	return "", false
+}
+
+// annotate adds origin metadata to the members of obj, the node at ps with
+// schema sch and the given origin.
+func (o *originAnnotator) annotate(
+	obj map[string]interface{},
+	sch schema.Node,
+	ps []string,
+	parentOrigin string,
+) {
+	for name, val := range obj {
+		if strings.HasPrefix(name, "@") {
+			continue
+		}
+		local := name
+		if i := strings.Index(name, ":"); i >= 0 {
+			local = name[i+1:]
+		}
+		chSch := sch.SchemaChild(local)
+		if chSch == nil {
+			continue
+		}
+		chPath := pathutil.CopyAppend(ps, local)
+
+		switch v := val.(type) {
+		case map[string]interface{}:
+			o.annotateEntry(v, chSch, chPath, parentOrigin)
+		case []interface{}:
+			list, ok := chSch.(schema.List)
+			if !ok {
+				// Leaf-list, or an empty leaf given as [null]
+				origin := o.origin(chSch, chPath)
+				if origin == parentOrigin {
+					continue
+				}
+				if _, ok := chSch.(schema.LeafList); !ok {
+					obj["@"+name] = originMetadata(origin)
+					continue
+				}
+				metas := make([]interface{}, len(v))
+				for i := range v {
+					metas[i] = originMetadata(origin)
+				}
+				obj["@"+name] = metas
+				continue
+			}
+			for _, elem := range v {
+				entry, ok := elem.(map[string]interface{})
+				if !ok || len(list.Keys()) == 0 {
+					continue
+				}
+				key, ok := entryKey(entry, list.Keys()[0])
+				if !ok {
+					continue
+				}
+				entrySch := chSch.SchemaChild(key)
+				if entrySch == nil {
+					continue
+				}
+				o.annotateEntry(entry, entrySch,
+					pathutil.CopyAppend(chPath, key), parentOrigin)
+			}
+		default:
+			if origin := o.origin(chSch, chPath); origin != parentOrigin {
+				obj["@"+name] = originMetadata(origin)
+			}
+		}
+	}
+}
+
+// annotateEntry adds origin metadata to a container or list entry, and its
+// members.
+func (o *originAnnotator) annotateEntry(
+	obj map[string]interface{},
+	sch schema.Node,
+	ps []string,
+	parentOrigin string,
+) {
+	origin := o.origin(sch, ps)
+	if origin != parentOrigin {
+		obj["@"] = originMetadata(origin)
+	}
+	o.annotate(obj, sch, ps, origin)
+}
+
+// outputParent returns the path of the parent of the top level members of
+// the data returned for ps, which are named for the node at ps, or for its
+// list if ps is a list entry.
+func outputParent(obj map[string]interface{}, ps []string) []string {
+	for name := range obj {
+		if i := strings.Index(name, ":"); i >= 0 {
+			name = name[i+1:]
+		}
+		for i := len(ps) - 1; i >= 0; i-- {
+			if ps[i] == name {
+				return ps[:i]
+			}
+		}
+	}
+	return ps
+}
+
+// addOrigins returns the operational data in out, read at path ps, with
+// origin metadata added.
+func (d *Disp) addOrigins(out string, ps []string) (string, error) {
+	var obj map[string]interface{}
+	if err := json.Unmarshal([]byte(out), &obj); err != nil {
+		return out, err
+	}
+	parent := outputParent(obj, ps)
+	var sch schema.Node = d.msFull
+	for _, elem := range parent {
+		if sch = sch.SchemaChild(elem); sch == nil {
+			return out, nil
+		}
+	}
+	o := &originAnnotator{d: d, sess: d.getROSession(rpc.OPERATIONAL, "")}
+	// Top level nodes are always annotated.
+	o.annotate(obj, sch, parent, "")
+
+	buf, err := json.Marshal(obj)
+	if err != nil {
+		return out, err
+	}
+	return string(buf), nil
+}
+
+// GetData returns the data at or below path in the named NMDA datastore,
+// as for RFC 8526 <get-data>.  withOrigin adds origin metadata, and is
+// only valid for the operational datastore in a JSON encoding.
+func (d *Disp) GetData(
+	sid, datastore, path, encoding string,
+	withOrigin bool,
+	flags map[string]interface{},
+) (string, error) {
+	db, err := parseDatastore(datastore)
+	if err != nil {
+		return fixupEmptyStringForEncoding("", encoding), err
+	}
+	if withOrigin {
+		if db != rpc.OPERATIONAL {
+			err := mgmterror.NewInvalidValueApplicationError()
+			err.Message =
+				"Origin is only available for the operational datastore"
+			return fixupEmptyStringForEncoding("", encoding), err
+		}
+		if err := checkAnnotationsEncoding(encoding); err != nil {
+			return fixupEmptyStringForEncoding("", encoding), err
+		}
+	}
+
+	out, err := d.TreeGet(db, sid, path, encoding, flags)
+	if err != nil || !withOrigin {
+		return out, err
+	}
+	return d.addOrigins(out, pathutil.Makepath(path))
+}
@@ -0,0 +1,141 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/danos/config/auth"
+	"github.com/danos/configd/rpc"
+	"github.com/danos/configd/server"
+)
+
+const nmdaSchemaTemplate = `
+container system {
+	leaf name {
+		type string;
+	}
+	leaf mode {
+		type string;
+		default "fast";
+	}
+	container status {
+		config false;
+		configd:get-state '%s';
+		leaf uptime {
+			type uint32;
+		}
+	}
+}`
+
+const nmdaConfig = `
+system {
+	name test
+}
+`
+
+func newNMDATestDispatcher(t *testing.T) *server.Disp {
+	return newTestDispatcherWithMultipleSchemas(t, auth.TestAutherAllowAll(),
+		genTestSchema(nmdaSchemaTemplate, `echo {"uptime":42}`), nmdaConfig)
+}
+
+func nmdaTestOpts() map[string]interface{} {
+	return map[string]interface{}{"Defaults": true}
+}
+
+func checkOrigin(
+	t *testing.T,
+	obj map[string]interface{},
+	member, exp string,
+) {
+	t.Helper()
+	meta, ok := obj[member].(map[string]interface{})
+	if !ok {
+		t.Fatalf("No %s metadata in %v", member, obj)
+	}
+	if got := meta["ietf-origin:origin"]; got != exp {
+		t.Fatalf("%s origin: expected %s, got %v", member, exp, got)
+	}
+}
+
+func TestGetDataOperationalWithOrigin(t *testing.T) {
+	d := newNMDATestDispatcher(t)
+	dispTestSetupSession(t, d, testSID)
+
+	out, err := d.GetData(testSID, "ietf-datastores:operational", "/system",
+		"json", true, nmdaTestOpts())
+	if err != nil {
+		t.Fatalf("Unable to get operational data: %s", err)
+	}
+	var obj map[string]interface{}
+	if err := json.Unmarshal([]byte(out), &obj); err != nil {
+		t.Fatalf("Invalid JSON %s: %s", out, err)
+	}
+	system, ok := obj["system"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("No system container in %s", out)
+	}
+	checkOrigin(t, system, "@", "ietf-origin:intended")
+	checkOrigin(t, system, "@mode", "ietf-origin:default")
+	if _, ok := system["@name"]; ok {
+		t.Fatalf("name should inherit its origin: %s", out)
+	}
+	status, ok := system["status"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("No status container in %s", out)
+	}
+	checkOrigin(t, status, "@", "ietf-origin:learned")
+	if _, ok := status["@uptime"]; ok {
+		t.Fatalf("uptime should inherit its origin: %s", out)
+	}
+}
+
+func TestTreeGetOperationalIncludesState(t *testing.T) {
+	d := newNMDATestDispatcher(t)
+	dispTestSetupSession(t, d, testSID)
+
+	out, err := d.TreeGet(rpc.OPERATIONAL, testSID, "/system", "json",
+		nmdaTestOpts())
+	if err != nil {
+		t.Fatalf("Unable to get operational tree: %s", err)
+	}
+	if !strings.Contains(out, `"uptime":42`) {
+		t.Fatalf("Operational tree should include state: %s", out)
+	}
+
+	out, err = d.TreeGet(rpc.INTENDED, testSID, "/system", "json",
+		nmdaTestOpts())
+	if err != nil {
+		t.Fatalf("Unable to get intended tree: %s", err)
+	}
+	if strings.Contains(out, "uptime") {
+		t.Fatalf("Intended tree should not include state: %s", out)
+	}
+	running, _ := d.TreeGet(rpc.RUNNING, testSID, "/system", "json",
+		nmdaTestOpts())
+	if out != running {
+		t.Fatalf("Intended should read as running:\n%s\n%s", out, running)
+	}
+}
+
+func TestGetDataErrors(t *testing.T) {
+	d := newNMDATestDispatcher(t)
+	dispTestSetupSession(t, d, testSID)
+
+	if _, err := d.GetData(testSID, "startup", "/system", "json", false,
+		nmdaTestOpts()); err == nil {
+		t.Fatalf("Unsupported datastore should fail")
+	}
+	if _, err := d.GetData(testSID, "running", "/system", "json", true,
+		nmdaTestOpts()); err == nil {
+		t.Fatalf("Origin should only be available for operational")
+	}
+	if _, err := d.GetData(testSID, "operational", "/system", "xml", true,
+		nmdaTestOpts()); err == nil {
+		t.Fatalf("Origin should only be available for JSON encodings")
+	}
+}
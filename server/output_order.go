@@ -0,0 +1,60 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"sort"
+
+	"github.com/danos/mgmterror"
+	"github.com/danos/utils/exec"
+	"github.com/danos/utils/natsort"
+	"github.com/danos/utils/pathutil"
+)
+
+// Output ordering
+//
+// Validation runs scripts in parallel, so their outputs and errors were
+// reported in whatever order the scripts finished, making logs of the same
+// validation or commit differ from run to run.  The outputs and errors
+// returned by Validate and Commit are now ordered by path, each element
+// compared in natural order, eg "dp0s2" before "dp0s10".  Those with no
+// path, such as the output of commit hooks, come first.  The sort is
+// stable, so outputs for the same path keep the order they were produced
+// in.
+
+// pathLess orders paths element by element, a path before any below it.
+func pathLess(a, b []string) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return natsort.Less(a[i], b[i])
+		}
+	}
+	return len(a) < len(b)
+}
+
+func sortOutputs(outs []*exec.Output) {
+	path := func(i int) []string {
+		if outs[i] == nil {
+			return nil
+		}
+		return outs[i].Path
+	}
+	sort.SliceStable(outs, func(i, j int) bool {
+		return pathLess(path(i), path(j))
+	})
+}
+
+func errorPath(err error) []string {
+	if me, ok := err.(mgmterror.Formattable); ok {
+		return pathutil.Makepath(me.GetPath())
+	}
+	return nil
+}
+
+func sortErrors(errs []error) {
+	sort.SliceStable(errs, func(i, j int) bool {
+		return pathLess(errorPath(errs[i]), errorPath(errs[j]))
+	})
+}
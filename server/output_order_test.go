@@ -0,0 +1,69 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/danos/mgmterror"
+	"github.com/danos/utils/exec"
+)
+
+func TestSortOutputs(t *testing.T) {
+	outs := []*exec.Output{
+		{Path: []string{"interfaces", "dataplane", "dp0s10"}, Output: "a"},
+		{Path: nil, Output: "hook"},
+		{Path: []string{"interfaces", "dataplane", "dp0s2"}, Output: "b"},
+		{Path: []string{"interfaces"}, Output: "c"},
+		{Path: []string{"interfaces", "dataplane", "dp0s2"}, Output: "d"},
+		nil,
+		{Path: []string{"protocols"}, Output: "e"},
+	}
+	sortOutputs(outs)
+
+	var got []string
+	for _, out := range outs {
+		if out == nil {
+			got = append(got, "<nil>")
+			continue
+		}
+		got = append(got, out.Output)
+	}
+	exp := []string{"hook", "<nil>", "c", "b", "d", "a", "e"}
+	if !reflect.DeepEqual(got, exp) {
+		t.Fatalf("Unexpected order\nexpect: %v\nactual: %v", exp, got)
+	}
+}
+
+func TestSortErrors(t *testing.T) {
+	newErr := func(path string) error {
+		err := mgmterror.NewOperationFailedApplicationError()
+		err.Path = path
+		err.Message = path
+		return err
+	}
+	plain := errors.New("no path")
+	errs := []error{
+		newErr("/system/host-name"),
+		newErr("/interfaces/dataplane/dp0s10"),
+		plain,
+		newErr("/interfaces/dataplane/dp0s2"),
+	}
+	sortErrors(errs)
+
+	var got []string
+	for _, err := range errs {
+		got = append(got, err.Error())
+	}
+	exp := []string{plain.Error(),
+		newErr("/interfaces/dataplane/dp0s2").Error(),
+		newErr("/interfaces/dataplane/dp0s10").Error(),
+		newErr("/system/host-name").Error()}
+	if !reflect.DeepEqual(got, exp) {
+		t.Fatalf("Unexpected order\nexpect: %v\nactual: %v", exp, got)
+	}
+}
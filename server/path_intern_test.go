@@ -0,0 +1,61 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/danos/configd/rpc"
+	"github.com/danos/configd/session/sessiontest"
+)
+
+// Entries in the configuration the dispatcher benchmarks read and commit,
+// large enough that the garbage of each request shows
+const benchTreeEntries = 10000
+
+func newBenchTreeDisp() *Disp {
+	// sessiontest needs a *testing.T
+	srv, _ := sessiontest.NewTestSpec(new(testing.T)).
+		SetSingleSchema(compareRevisionsSchema).
+		SetConfig(compareRevisionsConfig(benchTreeEntries, 0)).
+		Init()
+	return &Disp{smgr: srv.Smgr, cmgr: srv.Cmgr, ms: srv.Ms,
+		msFull: srv.MsFull, ctx: srv.Ctx}
+}
+
+func BenchmarkTreeGetLarge(b *testing.B) {
+	d := newBenchTreeDisp()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := d.TreeGet(rpc.RUNNING, "", "cont", "json",
+			nil); err != nil {
+			b.Fatalf("Unable to get tree: %s", err)
+		}
+	}
+}
+
+func BenchmarkCommitLarge(b *testing.B) {
+	d := newBenchTreeDisp()
+	sid := "bench"
+	if _, err := d.SessionSetup(sid); err != nil {
+		b.Fatalf("Unable to setup session: %s", err)
+	}
+	defer d.SessionTeardown(sid)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		path := fmt.Sprintf("cont/entry/entry%d/value/v%d",
+			i%benchTreeEntries, i)
+		if _, err := d.Set(sid, path); err != nil {
+			b.Fatalf("Unable to set %s: %s", path, err)
+		}
+		if _, err := d.Commit(sid, "", false); err != nil {
+			b.Fatalf("Unable to commit: %s", err)
+		}
+	}
+}
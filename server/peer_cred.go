@@ -0,0 +1,60 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// Peer credentials
+//
+// A connection acts for the user in the client's audit loginuid, so that
+// commands run through sudo or su are still attributed to whoever logged
+// in.  The loginuid is only a claim though: the kernel's SO_PEERCRED
+// credentials say who actually opened the connection.  The two are cross
+// checked and a connection whose loginuid differs from its peer uid is
+// refused, unless the peer is root or the configd user, which legitimately
+// act on behalf of others.  Likewise a peer without a loginuid, such as a
+// daemon, only acts as root if it is one of these privileged users, and
+// otherwise acts as itself.  The verified peer uid and gid are kept in the
+// context so they can be recorded alongside the user acted for.
+
+type PeerCredError struct {
+	pid      int32
+	uid      uint32
+	loginUid uint32
+}
+
+func (e *PeerCredError) Error() string {
+	return fmt.Sprintf(
+		"Login User Id %d for PID %d does not match peer User Id %d",
+		e.loginUid, e.pid, e.uid)
+}
+
+func (s *Srv) isPrivilegedPeer(uid uint32) bool {
+	return uid == 0 || uid == s.uid
+}
+
+// verifyPeerIdentity returns the uid a connection from peer acts for, given
+// the result of looking up the peer's loginuid.
+func (s *Srv) verifyPeerIdentity(
+	peer *syscall.Ucred,
+	loginUid uint32,
+	loginErr error,
+) (uint32, error) {
+	switch {
+	case IsLoginPidError(loginErr):
+		if s.isPrivilegedPeer(peer.Uid) {
+			return 0, nil
+		}
+		return peer.Uid, nil
+	case loginErr != nil:
+		return 0, loginErr
+	case loginUid == peer.Uid, s.isPrivilegedPeer(peer.Uid):
+		return loginUid, nil
+	}
+	return 0, &PeerCredError{pid: peer.Pid, uid: peer.Uid, loginUid: loginUid}
+}
@@ -0,0 +1,54 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+)
+
+func TestVerifyPeerIdentity(t *testing.T) {
+	const configdUid = 50
+	srv := &Srv{uid: configdUid}
+	unset := newLoginPidError(100)
+
+	tests := []struct {
+		name     string
+		peerUid  uint32
+		loginUid uint32
+		loginErr error
+		expUid   uint32
+		expFail  bool
+	}{
+		{name: "matching", peerUid: 1000, loginUid: 1000, expUid: 1000},
+		{name: "sudo", peerUid: 0, loginUid: 1000, expUid: 1000},
+		{name: "configd", peerUid: configdUid, loginUid: 1000, expUid: 1000},
+		{name: "mismatch", peerUid: 1001, loginUid: 1000, expFail: true},
+		{name: "root daemon", peerUid: 0, loginErr: unset, expUid: 0},
+		{name: "user daemon", peerUid: 1001, loginErr: unset, expUid: 1001},
+		{name: "lookup error", peerUid: 0, loginErr: errors.New("no pid"),
+			expFail: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			peer := &syscall.Ucred{Pid: 100, Uid: test.peerUid}
+			uid, err := srv.verifyPeerIdentity(peer, test.loginUid,
+				test.loginErr)
+			if test.expFail {
+				if err == nil {
+					t.Fatalf("Expected failure, got uid %d", uid)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if uid != test.expUid {
+				t.Fatalf("Expected uid %d, got %d", test.expUid, uid)
+			}
+		})
+	}
+}
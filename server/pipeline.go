@@ -0,0 +1,107 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"sync"
+
+	"github.com/danos/configd/rpc"
+)
+
+// Pipelined requests
+//
+// Each connection's requests were handled one at a time, so a slow
+// TreeGetFull held up the quick Exists calls a client made alongside it.
+// Clients speaking rpc.ProtocolVersion3 match responses to requests by
+// their ids, so for them the reads below, which only look at a session or
+// running, are run concurrently, up to a limit per connection.  Anything
+// else waits for the reads in progress to finish and is run on its own,
+// so it sees, and is seen by, the requests around it in the order they
+// were sent.
+
+// Requests each connection runs at once if not configured.
+const defaultConcurrentReads = 4
+
+var concurrentMethods = map[string]struct{}{
+	"Exists":           {},
+	"Get":              {},
+	"GetComment":       {},
+	"GetCompletions":   {},
+	"GetHelp":          {},
+	"NodeGetComment":   {},
+	"NodeGetStatus":    {},
+	"NodeGetType":      {},
+	"NodeIsDefault":    {},
+	"SchemaGet":        {},
+	"TmplGet":          {},
+	"TreeGet":          {},
+	"TreeGetFull":      {},
+	"ValidatePath":     {},
+	"TmplValidatePath": {},
+}
+
+func concurrentReads(config int) int {
+	if config <= 0 {
+		return defaultConcurrentReads
+	}
+	return config
+}
+
+// pipeline tracks the requests a connection is running concurrently.
+type pipeline struct {
+	running sync.WaitGroup
+	slots   chan struct{}
+}
+
+func newPipeline(limit int) *pipeline {
+	return &pipeline{slots: make(chan struct{}, limit)}
+}
+
+// concurrent reports whether method may be run alongside other requests.
+func (p *pipeline) concurrent(disp *Disp, method string) bool {
+	if cap(p.slots) < 2 ||
+		disp.getProtocolVersion() < rpc.ProtocolVersion3 {
+		return false
+	}
+	_, ok := concurrentMethods[method]
+	return ok
+}
+
+// forRequest returns a copy of disp for a request run concurrently, so
+// picking up a reloaded schema doesn't race with the other requests.
+func (disp *Disp) forRequest() *Disp {
+	d := *disp
+	ctx := *disp.ctx
+	d.ctx = &ctx
+	return &d
+}
+
+// start runs req, waiting for a free slot first.
+func (p *pipeline) start(
+	conn *SrvConn,
+	disp *Disp,
+	req *rpc.Request,
+	inflight *inflightRequests,
+) {
+	p.slots <- struct{}{}
+	p.running.Add(1)
+	d := disp.forRequest()
+	d.ctx.Request = inflight.begin(req.Id)
+	go func() {
+		defer func() {
+			inflight.end(req.Id)
+			<-p.slots
+			p.running.Done()
+		}()
+		result, err := conn.Call(d, req.Method, req.Args)
+		// A failure to send is seen by the next read of the connection.
+		conn.sendResponse(newResponse(result, err, req.Id))
+	}()
+}
+
+// wait returns once the requests started have completed.
+func (p *pipeline) wait() {
+	p.running.Wait()
+}
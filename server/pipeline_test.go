@@ -0,0 +1,67 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/danos/configd/rpc"
+)
+
+func TestPipelineConcurrentMethods(t *testing.T) {
+	_, disp := newProtocolTestConn(t)
+	pipe := newPipeline(concurrentReads(0))
+
+	if pipe.concurrent(disp, "Exists") {
+		t.Fatalf("Version 1 clients must be handled in order")
+	}
+	disp.ProtocolVersion(rpc.ProtocolVersion3)
+	if !pipe.concurrent(disp, "Exists") {
+		t.Fatalf("Exists should run concurrently")
+	}
+	if pipe.concurrent(disp, "Set") {
+		t.Fatalf("Set must be handled in order")
+	}
+	if newPipeline(1).concurrent(disp, "Exists") {
+		t.Fatalf("A limit of 1 should handle requests in order")
+	}
+}
+
+func TestPipelineResponsesCarryIds(t *testing.T) {
+	conn, disp := newProtocolTestConn(t, "ProtocolVersion")
+	var buf bytes.Buffer
+	conn.enc = json.NewEncoder(&buf)
+	conn.sending = new(sync.Mutex)
+
+	pipe := newPipeline(2)
+	inflight := newInflightRequests()
+	for id := 1; id <= 3; id++ {
+		pipe.start(conn, disp, &rpc.Request{
+			Method: "ProtocolVersion",
+			Args:   []interface{}{float64(rpc.ProtocolVersion)},
+			Id:     id,
+		}, inflight)
+	}
+	pipe.wait()
+
+	dec := json.NewDecoder(&buf)
+	seen := make(map[int]bool)
+	for i := 0; i < 3; i++ {
+		var resp rpc.Response
+		if err := dec.Decode(&resp); err != nil {
+			t.Fatalf("Unable to decode response %d: %s", i, err)
+		}
+		if resp.Result != float64(rpc.ProtocolVersion) {
+			t.Fatalf("Unexpected result: %v", resp.Result)
+		}
+		seen[resp.Id] = true
+	}
+	if len(seen) != 3 {
+		t.Fatalf("Expected responses to 3 requests, got %v", seen)
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"runtime/trace"
+	"sync"
+	"time"
+
+	"github.com/danos/mgmterror"
+)
+
+// Runtime profiling
+//
+// Profiling used to be toggled with signals, which races with anything else
+// using them and gives no feedback.  Instead profiles are started and stopped
+// through the API, with each written to a timestamped file in the profile
+// directory.  CPU profiles and execution traces run until stopped; heap and
+// goroutine profiles are snapshots, written when started, so have nothing
+// to stop.  Only one profile of each kind can run at a time, and the signal
+// handlers go through here too so they can't clash with the API.
+
+const (
+	ProfileCPU       = "cpu"
+	ProfileHeap      = "heap"
+	ProfileTrace     = "trace"
+	ProfileGoroutine = "goroutine"
+
+	defaultProfileDir = "/run/configd/profiles"
+)
+
+type runningProfile struct {
+	file *os.File
+	stop func()
+}
+
+var profiles = struct {
+	mu      sync.Mutex
+	running map[string]*runningProfile
+}{running: make(map[string]*runningProfile)}
+
+func newUnknownProfileError(kind string) error {
+	err := mgmterror.NewInvalidValueApplicationError()
+	err.Message = fmt.Sprintf("Unknown profile %s", kind)
+	return err
+}
+
+func isSnapshotProfile(kind string) bool {
+	return kind == ProfileHeap || kind == ProfileGoroutine
+}
+
+func writeSnapshotProfile(kind string, f *os.File) error {
+	if kind == ProfileHeap {
+		return pprof.WriteHeapProfile(f)
+	}
+	return pprof.Lookup(kind).WriteTo(f, 0)
+}
+
+// StartProfileToFile starts a profile of the given kind written to file,
+// or for snapshot profiles writes it there.
+func StartProfileToFile(kind, file string) error {
+	switch kind {
+	case ProfileCPU, ProfileTrace, ProfileHeap, ProfileGoroutine:
+	default:
+		return newUnknownProfileError(kind)
+	}
+
+	profiles.mu.Lock()
+	defer profiles.mu.Unlock()
+	if _, ok := profiles.running[kind]; ok {
+		err := mgmterror.NewOperationFailedApplicationError()
+		err.Message = fmt.Sprintf("A %s profile is already running", kind)
+		return err
+	}
+
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if isSnapshotProfile(kind) {
+		err = writeSnapshotProfile(kind, f)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		return err
+	}
+
+	p := &runningProfile{file: f}
+	switch kind {
+	case ProfileCPU:
+		err = pprof.StartCPUProfile(f)
+		p.stop = pprof.StopCPUProfile
+	case ProfileTrace:
+		err = trace.Start(f)
+		p.stop = trace.Stop
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	profiles.running[kind] = p
+	return nil
+}
+
+// StopProfile stops the running profile of the given kind, returning the
+// file it was written to.
+func StopProfile(kind string) (string, error) {
+	profiles.mu.Lock()
+	defer profiles.mu.Unlock()
+	p, ok := profiles.running[kind]
+	if !ok {
+		err := mgmterror.NewOperationFailedApplicationError()
+		err.Message = fmt.Sprintf("No %s profile is running", kind)
+		return "", err
+	}
+	delete(profiles.running, kind)
+	p.stop()
+	return p.file.Name(), p.file.Close()
+}
+
+// ProfileRunning returns true if a profile of the given kind is running.
+func ProfileRunning(kind string) bool {
+	profiles.mu.Lock()
+	defer profiles.mu.Unlock()
+	_, ok := profiles.running[kind]
+	return ok
+}
+
+func (d *Disp) profileDir() string {
+	if d.ctx.Config != nil && d.ctx.Config.ProfileDir != "" {
+		return d.ctx.Config.ProfileDir
+	}
+	return defaultProfileDir
+}
+
+func profileFileName(kind string, now time.Time) string {
+	ext := ".pprof"
+	if kind == ProfileTrace {
+		ext = ".trace"
+	}
+	return fmt.Sprintf("configd-%s-%s%s", kind,
+		now.Format("20060102-150405.000"), ext)
+}
+
+// StartProfile starts a cpu profile or execution trace, or writes a heap or
+// goroutine profile, returning the file written to.
+func (d *Disp) StartProfile(kind string) (string, error) {
+	if !d.ctx.Superuser {
+		return "", mgmterror.NewAccessDeniedApplicationError()
+	}
+	dir := d.profileDir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	file := filepath.Join(dir, profileFileName(kind, time.Now()))
+	if err := StartProfileToFile(kind, file); err != nil {
+		return "", err
+	}
+	return file, nil
+}
+
+// StopProfile stops a running cpu profile or execution trace, returning
+// the file written to.
+func (d *Disp) StopProfile(kind string) (string, error) {
+	if !d.ctx.Superuser {
+		return "", mgmterror.NewAccessDeniedApplicationError()
+	}
+	return StopProfile(kind)
+}
@@ -0,0 +1,88 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/danos/configd"
+)
+
+func TestProfileStartStop(t *testing.T) {
+	dir, err := ioutil.TempDir("", "configd-profile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "cpu.pprof")
+
+	if _, err := StopProfile(ProfileCPU); err == nil {
+		t.Fatal("Stopped a profile that isn't running")
+	}
+	if err := StartProfileToFile(ProfileCPU, file); err != nil {
+		t.Fatal(err)
+	}
+	if !ProfileRunning(ProfileCPU) {
+		t.Fatal("CPU profile not running")
+	}
+	if err := StartProfileToFile(ProfileCPU, file); err == nil {
+		t.Fatal("Started a second CPU profile")
+	}
+	name, err := StopProfile(ProfileCPU)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != file {
+		t.Fatalf("Profile written to %s, expected %s", name, file)
+	}
+	if ProfileRunning(ProfileCPU) {
+		t.Fatal("CPU profile still running")
+	}
+}
+
+func TestProfileSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "configd-profile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	d := &Disp{ctx: &configd.Context{
+		Superuser: true,
+		Config:    &configd.Config{ProfileDir: dir},
+	}}
+	file, err := d.StartProfile(ProfileHeap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Dir(file) != dir {
+		t.Fatalf("Profile written to %s, expected it in %s", file, dir)
+	}
+	if fi, err := os.Stat(file); err != nil || fi.Size() == 0 {
+		t.Fatalf("Heap profile not written to %s: %v", file, err)
+	}
+	if ProfileRunning(ProfileHeap) {
+		t.Fatal("Heap profile left running")
+	}
+}
+
+func TestProfileUnknownKind(t *testing.T) {
+	if err := StartProfileToFile("bogus", os.DevNull); err == nil {
+		t.Fatal("Started an unknown profile")
+	}
+}
+
+func TestProfileRequiresSuperuser(t *testing.T) {
+	d := &Disp{ctx: &configd.Context{}}
+	if _, err := d.StartProfile(ProfileCPU); err == nil {
+		t.Fatal("Non-superuser started a profile")
+	}
+	if _, err := d.StopProfile(ProfileCPU); err == nil {
+		t.Fatal("Non-superuser stopped a profile")
+	}
+}
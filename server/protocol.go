@@ -0,0 +1,38 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"fmt"
+
+	"github.com/danos/configd/rpc"
+	"github.com/danos/mgmterror"
+)
+
+// getProtocolVersion returns the version negotiated with the client, which
+// is rpc.ProtocolVersion1 if it hasn't negotiated one.
+func (d *Disp) getProtocolVersion() int {
+	if d.protoVersion == 0 {
+		return rpc.ProtocolVersion1
+	}
+	return d.protoVersion
+}
+
+// ProtocolVersion is called by clients on connecting, with the latest
+// protocol version they speak, and returns the version to be used for the
+// rest of the connection.
+func (d *Disp) ProtocolVersion(clientVersion int) (int, error) {
+	if clientVersion < rpc.ProtocolVersion1 {
+		err := mgmterror.NewOperationNotSupportedApplicationError()
+		err.Message = fmt.Sprintf("Unsupported protocol version %d",
+			clientVersion)
+		return 0, err
+	}
+	d.protoVersion = clientVersion
+	if d.protoVersion > rpc.ProtocolVersion {
+		d.protoVersion = rpc.ProtocolVersion
+	}
+	return d.protoVersion, nil
+}
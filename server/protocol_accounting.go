@@ -0,0 +1,147 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/danos/configd/rpc"
+	"github.com/danos/mgmterror"
+	"github.com/danos/utils/pathutil"
+)
+
+// Protocol accounting
+//
+// Command accounting covered the CLI's commands, but of the operations
+// NETCONF and RESTCONF servers make on their clients' behalf only
+// copy-config and commit were accounted.  Edit-config is now always
+// accounted, with its target, default operation and the top level nodes it
+// edits.  A server may also tell configd which protocol it serves, with
+// SetClientOrigin; the connection's gets, get-configs and RPCs are then
+// accounted too, and each of its records starts with the protocol, eg
+// "netconf edit-config candidate merge interfaces".  The CLI's records are
+// unchanged.
+
+const (
+	OriginCLI      = "cli"
+	OriginNetconf  = "netconf"
+	OriginRestconf = "restconf"
+)
+
+// SetClientOrigin records the protocol the client serves, for accounting.
+func (d *Disp) SetClientOrigin(origin string) (bool, error) {
+	switch origin {
+	case OriginCLI, OriginNetconf, OriginRestconf:
+	default:
+		err := mgmterror.NewInvalidValueApplicationError()
+		err.Message = fmt.Sprintf("Unknown client origin %s", origin)
+		return false, err
+	}
+	d.origin = origin
+	return true, nil
+}
+
+// protocolOrigin reports whether the client serves a protocol other than
+// the CLI.
+func (d *Disp) protocolOrigin() bool {
+	return d.origin != "" && d.origin != OriginCLI
+}
+
+// withOrigin returns the command args prefixed with the origin.
+func (args *commandArgs) withOrigin(origin string) *commandArgs {
+	attrs := pathutil.NewPathAttrs()
+	elemAttrs := pathutil.NewPathElementAttrs()
+	elemAttrs.Secret = false
+	attrs.Attrs = append(attrs.Attrs, elemAttrs)
+	attrs.Attrs = append(attrs.Attrs, args.attrs.Attrs...)
+	return &commandArgs{
+		cmd:   append([]string{origin}, args.cmd...),
+		attrs: &attrs,
+	}
+}
+
+func datastoreName(db rpc.DB) string {
+	switch db {
+	case rpc.RUNNING:
+		return "running"
+	case rpc.CANDIDATE:
+		return "candidate"
+	case rpc.EFFECTIVE:
+		return "effective"
+	case rpc.INTENDED:
+		return "intended"
+	case rpc.OPERATIONAL:
+		return "operational"
+	}
+	return "auto"
+}
+
+func sortedNames(seen map[string]bool) []string {
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// editConfigXMLNames returns the top level nodes an XML edit-config edits.
+func editConfigXMLNames(config string) []string {
+	seen := make(map[string]bool)
+	dec := xml.NewDecoder(strings.NewReader(config))
+	depth := 0
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			// Depth 1 is within the <config> element.
+			if depth == 1 {
+				seen[t.Name.Local] = true
+			}
+			depth++
+		case xml.EndElement:
+			depth--
+		}
+	}
+	return sortedNames(seen)
+}
+
+// editConfigJSONNames returns the top level nodes a JSON edit-config
+// edits, without any module name.
+func editConfigJSONNames(config string) []string {
+	var top map[string]json.RawMessage
+	dec := json.NewDecoder(bytes.NewReader([]byte(config)))
+	if dec.Decode(&top) != nil {
+		return nil
+	}
+	seen := make(map[string]bool)
+	for name := range top {
+		if i := strings.IndexByte(name, ':'); i >= 0 {
+			name = name[i+1:]
+		}
+		seen[name] = true
+	}
+	return sortedNames(seen)
+}
+
+func (d *Disp) editConfigCommandArgs(
+	target, defaultOperation string,
+	names []string,
+) *commandArgs {
+	args := []string{target}
+	if defaultOperation != "" {
+		args = append(args, defaultOperation)
+	}
+	// The node names are schema names, never secret.
+	return d.newCommandArgsForAaa("edit-config", append(args, names...), nil)
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server_test
+
+import (
+	"testing"
+
+	"github.com/danos/config/auth"
+	"github.com/danos/configd/rpc"
+	"github.com/danos/configd/server"
+	"github.com/danos/utils/pathutil"
+)
+
+const editConfigAcctXML = `<config xmlns="urn:ietf:params:xml:ns:netconf:base:1.0">
+	<interfaces xmlns="urn:vyatta.com:test:configd-session">
+		<dataplane>
+			<tagnode>dp0s3</tagnode>
+		</dataplane>
+	</interfaces>
+</config>`
+
+func assertCmdAccounted(t *testing.T, a auth.TestAuther, cmd []string) {
+	t.Helper()
+	attrs := pathutil.NewPathAttrs()
+	for range cmd {
+		attrs.Attrs = append(attrs.Attrs,
+			pathutil.PathElementAttrs{Secret: false})
+	}
+	assertCmdAcctRequests(t, a, auth.NewTestAutherRequests(
+		auth.NewTestAutherCommandRequest(auth.T_REQ_ACCT_START, cmd, &attrs),
+		auth.NewTestAutherCommandRequest(auth.T_REQ_ACCT_STOP, cmd, &attrs)))
+}
+
+func protocolAcctTest(t *testing.T) (auth.TestAuther, *server.Disp) {
+	t.Helper()
+	a := auth.NewTestAuther(
+		auth.NewTestRule(auth.Allow, auth.AllOps, "*"))
+	d := newTestDispatcherWithCustomAuth(
+		t, a,
+		authTestSchema, emptyconfig,
+		false, /* not configd user, so our auther gets used! */
+		false /* not in secrets group */)
+	dispTestSetupSession(t, d, testSID)
+	clearAllCmdRequestsAndUserAuditLogs(a)
+	return a, d
+}
+
+func TestEditConfigAccounted(t *testing.T) {
+	a, d := protocolAcctTest(t)
+
+	if _, err := d.EditConfigXML(testSID, "candidate", "merge", "", "",
+		editConfigAcctXML); err != nil {
+		t.Fatalf("Unable to edit config: %s", err)
+	}
+	assertCmdAccounted(t, a,
+		[]string{"edit-config", "candidate", "merge", "interfaces"})
+}
+
+func TestNetconfOriginAccounted(t *testing.T) {
+	a, d := protocolAcctTest(t)
+
+	if _, err := d.SetClientOrigin("netconf"); err != nil {
+		t.Fatalf("Unable to set origin: %s", err)
+	}
+	if _, err := d.EditConfigXML(testSID, "candidate", "merge", "", "",
+		editConfigAcctXML); err != nil {
+		t.Fatalf("Unable to edit config: %s", err)
+	}
+	assertCmdAccounted(t, a, []string{
+		"netconf", "edit-config", "candidate", "merge", "interfaces"})
+
+	if _, err := d.TreeGet(rpc.CANDIDATE, testSID, "/interfaces", "xml",
+		map[string]interface{}{}); err != nil {
+		t.Fatalf("Unable to get tree: %s", err)
+	}
+	assertCmdAccounted(t, a, []string{
+		"netconf", "get-config", "candidate", "interfaces"})
+}
+
+func TestCliOriginReadsNotAccounted(t *testing.T) {
+	a, d := protocolAcctTest(t)
+
+	d.TreeGet(rpc.CANDIDATE, testSID, "/interfaces", "xml",
+		map[string]interface{}{})
+	assertCmdAcctRequests(t, a, auth.NewTestAutherRequests())
+}
+
+func TestSetClientOriginInvalid(t *testing.T) {
+	_, d := protocolAcctTest(t)
+	if _, err := d.SetClientOrigin("telnet"); err == nil {
+		t.Fatal("Unknown origin accepted")
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/danos/config/auth"
+	"github.com/danos/configd"
+	"github.com/danos/configd/rpc"
+	"github.com/danos/mgmterror"
+)
+
+func newProtocolTestConn(t *testing.T, methods ...string) (*SrvConn, *Disp) {
+	srv := &Srv{m: make(map[string]reflect.Method)}
+	dtyp := reflect.TypeOf(new(Disp))
+	for _, name := range methods {
+		meth, ok := dtyp.MethodByName(name)
+		if !ok {
+			t.Fatalf("No method %s", name)
+		}
+		srv.m[name] = meth
+	}
+	disp := &Disp{ctx: &configd.Context{Auth: auth.TestAutherAllowAll()}}
+	return &SrvConn{srv: srv}, disp
+}
+
+func TestProtocolVersionNegotiation(t *testing.T) {
+	conn, disp := newProtocolTestConn(t, "ProtocolVersion")
+
+	if v := disp.getProtocolVersion(); v != rpc.ProtocolVersion1 {
+		t.Fatalf("Unexpected version before negotiation: %d", v)
+	}
+
+	// A client newer than us gets our version
+	v, err := conn.Call(disp, "ProtocolVersion",
+		[]interface{}{float64(rpc.ProtocolVersion + 1)})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if v != rpc.ProtocolVersion {
+		t.Fatalf("Unexpected negotiated version: %v", v)
+	}
+
+	if _, err := disp.ProtocolVersion(0); err == nil {
+		t.Fatalf("Version 0 should not be supported")
+	}
+}
+
+func TestProtocolVersion1MayOmitArguments(t *testing.T) {
+	conn, disp := newProtocolTestConn(t, "ProtocolVersion",
+		"SetArchivePolicy")
+
+	// Not a superuser, so access is denied before the policy is set.
+	args := []interface{}{float64(5)}
+	_, err := conn.Call(disp, "SetArchivePolicy", args)
+	if err == nil || err.Error() !=
+		mgmterror.NewAccessDeniedApplicationError().Error() {
+		t.Fatalf("Expected access denied, got: %v", err)
+	}
+
+	if _, err := disp.ProtocolVersion(rpc.ProtocolVersion2); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	_, err = conn.Call(disp, "SetArchivePolicy", args)
+	if _, ok := err.(*rpc.ArgNErr); !ok {
+		t.Fatalf("Expected wrong number of arguments, got: %v", err)
+	}
+}
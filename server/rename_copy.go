@@ -0,0 +1,94 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"github.com/danos/configd/common"
+	"github.com/danos/mgmterror"
+	"github.com/danos/utils/pathutil"
+)
+
+// Rename and copy make a new list entry from an existing one (see
+// session/rename_copy.go).  Setting the new entry is authorized by the
+// session, path by path, as for any set, while deleting the original on
+// rename is authorized here, as for any delete.
+
+// renameCopyPaths returns the normalized paths of the entry to rename or
+// copy and of the entry it becomes.
+func (d *Disp) renameCopyPaths(
+	sid, fpath, tpath string,
+) ([]string, []string, error) {
+	fps, err := d.normalizePath(
+		d.expandVariables(sid, pathutil.Makepath(fpath)))
+	if err != nil {
+		return nil, nil, common.FormatConfigPathErrorMultiline(err)
+	}
+	tps, err := d.normalizePath(
+		d.expandVariables(sid, pathutil.Makepath(tpath)))
+	if err != nil {
+		return nil, nil, common.FormatConfigPathErrorMultiline(err)
+	}
+	return fps, tps, nil
+}
+
+func (d *Disp) renameCopyInternal(
+	sid string, fps, tps []string, rename bool,
+) (bool, error) {
+	if rename && !d.authDelete(fps) {
+		return false, mgmterror.NewAccessDeniedApplicationError()
+	}
+	if err := d.checkExclusive(sid); err != nil {
+		return false, err
+	}
+
+	sess, err := d.smgr.Get(d.ctx, sid)
+	if err != nil {
+		return false, err
+	}
+
+	if rename {
+		err = sess.Rename(d.ctx, fps, tps)
+	} else {
+		err = sess.Copy(d.ctx, fps, tps)
+	}
+	if err != nil {
+		return false, common.FormatConfigPathErrorMultiline(err)
+	}
+	return true, nil
+}
+
+// Rename moves the list entry at fpath, and everything below it, to tpath.
+func (d *Disp) Rename(sid string, fpath string, tpath string) (bool, error) {
+	fps, tps, err := d.renameCopyPaths(sid, fpath, tpath)
+	if err != nil {
+		return false, err
+	}
+
+	args := d.newCommandArgsForAaa("rename", nil, fps)
+	if !d.authCommand(args) {
+		return false, mgmterror.NewAccessDeniedApplicationError()
+	}
+
+	return d.accountCmdWrapBoolErr(args, func() (interface{}, error) {
+		return d.renameCopyInternal(sid, fps, tps, true)
+	})
+}
+
+// Copy copies the list entry at fpath, and everything below it, to tpath.
+func (d *Disp) Copy(sid string, fpath string, tpath string) (bool, error) {
+	fps, tps, err := d.renameCopyPaths(sid, fpath, tpath)
+	if err != nil {
+		return false, err
+	}
+
+	args := d.newCommandArgsForAaa("copy", nil, fps)
+	if !d.authCommand(args) {
+		return false, mgmterror.NewAccessDeniedApplicationError()
+	}
+
+	return d.accountCmdWrapBoolErr(args, func() (interface{}, error) {
+		return d.renameCopyInternal(sid, fps, tps, false)
+	})
+}
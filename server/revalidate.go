@@ -0,0 +1,107 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/danos/config/data"
+	"github.com/danos/config/schema"
+	"github.com/danos/config/union"
+	"github.com/danos/utils/pathutil"
+)
+
+// Running revalidation
+//
+// Running was valid when committed, but may not be against the schema in
+// use now, eg after a feature is toggled or a deviation changes and the
+// schema is reloaded, which is otherwise only found when the next commit
+// fails.  When enabled, a scheduled job periodically checks running
+// against the current schema: that each path is valid, and that the must,
+// when, mandatory and unique constraints hold, as for ValidateSchemaOnly.
+// No scripts are run.  Violations are logged and raise a health alarm,
+// which is cleared once running is valid again.  The job can be run on
+// demand, but is never scheduled more often than every few minutes, so a
+// large configuration can't keep configd busy.
+
+const (
+	RevalidateRunningJob  = "running-revalidation"
+	minRevalidateInterval = 5 * time.Minute
+	// The alarm lists at most this many violations.
+	maxRevalidationErrors = 10
+)
+
+func revalidateInterval(mins int) time.Duration {
+	interval := time.Duration(mins) * time.Minute
+	if interval < minRevalidateInterval {
+		return minRevalidateInterval
+	}
+	return interval
+}
+
+func dataPaths(n *data.Node, path []string, paths [][]string) [][]string {
+	chs := n.Children()
+	if len(chs) == 0 {
+		return append(paths, path)
+	}
+	for _, ch := range chs {
+		paths = dataPaths(ch, pathutil.CopyAppend(path, ch.Name()), paths)
+	}
+	return paths
+}
+
+// revalidate returns the violations of ms in the configuration running.
+func revalidate(running *data.Node, ms schema.ModelSet) []error {
+	var errs []error
+	for _, path := range dataPaths(running, []string{}, nil) {
+		if len(path) == 0 {
+			continue
+		}
+		vctx := schema.ValidateCtx{
+			CurPath: path,
+			Path:    pathutil.Pathstr(path),
+			Sid:     "RUNNING",
+			Noexec:  true,
+			St:      ms,
+		}
+		if err := ms.Validate(vctx, []string{}, path); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) != 0 {
+		// The tree can't be built against a schema it doesn't fit.
+		return errs
+	}
+	return validateAgainstSchema(union.NewNode(nil, running, ms, nil, 0))
+}
+
+func formatRevalidationErrors(errs []error) string {
+	msgs := make([]string, 0, maxRevalidationErrors)
+	for i, err := range errs {
+		if i == maxRevalidationErrors {
+			msgs = append(msgs, fmt.Sprintf("and %d more",
+				len(errs)-maxRevalidationErrors))
+			break
+		}
+		msgs = append(msgs, strings.TrimSpace(err.Error()))
+	}
+	return fmt.Sprintf("Running configuration is not valid against the "+
+		"current schema: %s", strings.Join(msgs, "; "))
+}
+
+func (s *Srv) revalidateRunning() error {
+	ms, _, _ := s.schemas()
+	errs := revalidate(s.cmgr.Running(), ms)
+	if len(errs) == 0 {
+		clearAlarm(RevalidationAlarm)
+		return nil
+	}
+	msg := formatRevalidationErrors(errs)
+	s.Elog.Println(msg)
+	raiseAlarm(RevalidationAlarm, msg)
+	return fmt.Errorf("%d violations found", len(errs))
+}
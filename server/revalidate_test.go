@@ -0,0 +1,94 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/danos/configd/session/sessiontest"
+)
+
+const revalidateSchema = `
+container top {
+	leaf value {
+		type string;
+	}
+	leaf other {
+		type string;
+	}
+}`
+
+const revalidateStrictSchema = `
+container top {
+	leaf value {
+		type string;
+		must "../other";
+	}
+	leaf other {
+		type string;
+	}
+}`
+
+const revalidateConfig = `
+top {
+	value foo
+}
+`
+
+func TestRevalidateRunning(t *testing.T) {
+	srv, _ := sessiontest.NewTestSpec(t).
+		SetSingleSchema(revalidateSchema).
+		SetConfig(revalidateConfig).
+		Init()
+	s := &Srv{cmgr: srv.Cmgr, ms: srv.Ms, msFull: srv.MsFull,
+		Elog: log.New(ioutil.Discard, "", 0)}
+
+	raiseAlarm(RevalidationAlarm, "stale")
+	if err := s.revalidateRunning(); err != nil {
+		t.Fatalf("Unexpected revalidation failure: %s", err)
+	}
+	if _, ok := alarms.get()[RevalidationAlarm]; ok {
+		t.Fatalf("Alarm not cleared once running is valid")
+	}
+
+	// As if a deviation had added a must to the schema
+	strict, _ := sessiontest.NewTestSpec(t).
+		SetSingleSchema(revalidateStrictSchema).
+		Init()
+	s.ms = strict.Ms
+	if err := s.revalidateRunning(); err == nil {
+		t.Fatalf("Running should be invalid against the new schema")
+	}
+	msg, ok := alarms.get()[RevalidationAlarm]
+	if !ok || !strings.Contains(msg, "'must' condition is false") {
+		t.Fatalf("Unexpected alarm: %q", msg)
+	}
+	clearAlarm(RevalidationAlarm)
+}
+
+func TestFormatRevalidationErrors(t *testing.T) {
+	var errs []error
+	for i := 0; i < maxRevalidationErrors+2; i++ {
+		errs = append(errs, errors.New("bad"))
+	}
+	msg := formatRevalidationErrors(errs)
+	if strings.Count(msg, "bad") != maxRevalidationErrors ||
+		!strings.HasSuffix(msg, "and 2 more") {
+		t.Fatalf("Unexpected message: %s", msg)
+	}
+}
+
+func TestRevalidateIntervalLimited(t *testing.T) {
+	if revalidateInterval(1) != minRevalidateInterval {
+		t.Fatalf("Interval not limited: %s", revalidateInterval(1))
+	}
+	if revalidateInterval(60).Minutes() != 60 {
+		t.Fatalf("Unexpected interval: %s", revalidateInterval(60))
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/danos/config/schema"
+	"github.com/danos/configd"
+	"github.com/danos/mgmterror"
+	yangenc "github.com/danos/yang/data/encoding"
+)
+
+// Validation of RPC output returned by VCI components.
+//
+// Components are expected to return output that conforms to the YANG
+// output statement for the RPC, but until now we have only checked that
+// the nodes returned exist in the schema.  Output is now fully validated
+// and, depending on configuration, a violation is either logged and the
+// output returned anyway (the default, so existing components keep
+// working) or the RPC fails.  Violations are counted per component model
+// so that misbehaving components can be identified.
+
+const (
+	RpcOutputValidationNone = "none"
+	RpcOutputValidationWarn = "warn"
+	RpcOutputValidationFail = "fail"
+)
+
+func IsRpcOutputValidationMode(mode string) bool {
+	switch mode {
+	case RpcOutputValidationNone, RpcOutputValidationWarn,
+		RpcOutputValidationFail:
+		return true
+	}
+	return false
+}
+
+func rpcOutputValidationMode(ctx *configd.Context) string {
+	if ctx.Config == nil ||
+		!IsRpcOutputValidationMode(ctx.Config.RpcOutputValidation) {
+		return RpcOutputValidationWarn
+	}
+	return ctx.Config.RpcOutputValidation
+}
+
+type rpcOutputViolations struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// Shared by all connections, so not held in the dispatcher.
+var rpcOutputViolationCounts = &rpcOutputViolations{
+	counts: make(map[string]int),
+}
+
+func (v *rpcOutputViolations) inc(modelName string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.counts[modelName]++
+}
+
+func (v *rpcOutputViolations) get() map[string]int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	counts := make(map[string]int, len(v.counts))
+	for modelName, count := range v.counts {
+		counts[modelName] = count
+	}
+	return counts
+}
+
+// validateRpcOutput checks component output against the RPC's output schema.
+// An error is only returned if validation is configured to fail the RPC.
+func (d *Disp) validateRpcOutput(
+	modelName, moduleName, rpcName string,
+	rpc schema.Rpc,
+	output string,
+) error {
+	mode := rpcOutputValidationMode(d.ctx)
+	if mode == RpcOutputValidationNone {
+		return nil
+	}
+
+	if output == "" {
+		output = "{}"
+	}
+	_, verr := yangenc.UnmarshalRFC7951(rpc.Output(), []byte(output))
+	if verr == nil {
+		return nil
+	}
+
+	rpcOutputViolationCounts.inc(modelName)
+	if mode == RpcOutputValidationWarn {
+		if d.ctx.Wlog != nil {
+			d.ctx.Wlog.Printf("RPC %s:%s (%s) returned invalid output: %s",
+				moduleName, rpcName, modelName, verr)
+		}
+		return nil
+	}
+
+	err := mgmterror.NewOperationFailedApplicationError()
+	err.Message = fmt.Sprintf("RPC %s:%s returned invalid output: %s",
+		moduleName, rpcName, verr)
+	return err
+}
+
+// GetRpcOutputViolations returns the number of times each component (by
+// model name) has returned RPC output that failed validation since configd
+// started.
+func (d *Disp) GetRpcOutputViolations() (map[string]int, error) {
+	return rpcOutputViolationCounts.get(), nil
+}
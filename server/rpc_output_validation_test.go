@@ -0,0 +1,135 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/danos/configd/server"
+	"github.com/danos/configd/session/sessiontest"
+)
+
+const rpcOutputValidationModel = "net.vyatta.test.validation"
+
+// Output with a mandatory leaf, so an empty reply is well-formed but
+// not valid.
+var rpcOutputValidationSchema = []sessiontest.TestSchema{
+	{
+		Name: sessiontest.NameDef{
+			Namespace: "vyatta-test-validation-v1",
+			Prefix:    "validation",
+		},
+		SchemaSnippet: `
+	rpc testRpc {
+		input {
+			leaf session-count {
+				type uint8;
+			}
+		}
+		output {
+			leaf status {
+				type string;
+				mandatory true;
+			}
+		}
+		configd:call-rpc "echo {\"status\":\"ok\"}";
+	}`,
+	},
+}
+
+func newRpcOutputValidationDispatcher(t *testing.T, mode string) *server.Disp {
+	srv, _ := sessiontest.NewTestSpec(t).
+		SetSchemaDefs(rpcOutputValidationSchema).
+		SetComponents(testRpcModelSet, []string{rpcTestComp}).
+		Init()
+	srv.Ctx.Config.RpcOutputValidation = mode
+	return server.NewDispatcher(srv.Smgr, srv.Cmgr, srv.Ms, srv.MsFull,
+		srv.Ctx)
+}
+
+func getRpcOutputViolations(t *testing.T, d *server.Disp) int {
+	t.Helper()
+	counts, err := d.GetRpcOutputViolations()
+	if err != nil {
+		t.Fatalf("Unable to get RPC output violations: %s", err)
+	}
+	return counts[rpcOutputValidationModel]
+}
+
+func callRpcOutputValidationRpc(
+	t *testing.T,
+	d *server.Disp,
+	output string,
+) error {
+	trc := &testRpcCaller{
+		t:            t,
+		expInputJson: validSessionCountJSON,
+		outputJson:   output}
+
+	_, err := d.CallRpcWithCaller(testRpcModule, testRpcName,
+		validSessionCountJSON, "json", trc)
+	return err
+}
+
+func TestRpcOutputValidationValidOutput(t *testing.T) {
+	d := newRpcOutputValidationDispatcher(t, server.RpcOutputValidationFail)
+	before := getRpcOutputViolations(t, d)
+
+	if err := callRpcOutputValidationRpc(
+		t, d, validStatusReplyJSON); err != nil {
+		t.Fatalf("Unexpected failure: %s", err)
+	}
+	if after := getRpcOutputViolations(t, d); after != before {
+		t.Fatalf("Unexpected violation count: exp %d, got %d",
+			before, after)
+	}
+}
+
+func TestRpcOutputValidationWarn(t *testing.T) {
+	d := newRpcOutputValidationDispatcher(t, server.RpcOutputValidationWarn)
+	before := getRpcOutputViolations(t, d)
+
+	if err := callRpcOutputValidationRpc(t, d, ""); err != nil {
+		t.Fatalf("Unexpected failure: %s", err)
+	}
+	if after := getRpcOutputViolations(t, d); after != before+1 {
+		t.Fatalf("Unexpected violation count: exp %d, got %d",
+			before+1, after)
+	}
+}
+
+func TestRpcOutputValidationFail(t *testing.T) {
+	d := newRpcOutputValidationDispatcher(t, server.RpcOutputValidationFail)
+	before := getRpcOutputViolations(t, d)
+
+	err := callRpcOutputValidationRpc(t, d, "")
+	if err == nil {
+		t.Fatalf("Unexpected success")
+	}
+	expErr := "RPC " + testRpcModule + ":" + testRpcName +
+		" returned invalid output"
+	if !strings.Contains(err.Error(), expErr) {
+		t.Fatalf("Failed to get expected error.\nExp: %s\nGot: %s\n",
+			expErr, err.Error())
+	}
+	if after := getRpcOutputViolations(t, d); after != before+1 {
+		t.Fatalf("Unexpected violation count: exp %d, got %d",
+			before+1, after)
+	}
+}
+
+func TestRpcOutputValidationNone(t *testing.T) {
+	d := newRpcOutputValidationDispatcher(t, server.RpcOutputValidationNone)
+	before := getRpcOutputViolations(t, d)
+
+	if err := callRpcOutputValidationRpc(t, d, ""); err != nil {
+		t.Fatalf("Unexpected failure: %s", err)
+	}
+	if after := getRpcOutputViolations(t, d); after != before {
+		t.Fatalf("Unexpected violation count: exp %d, got %d",
+			before, after)
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/danos/config/data"
+	"github.com/danos/config/load"
+	"github.com/danos/config/schema"
+	"github.com/danos/configd"
+	"github.com/danos/configd/session"
+)
+
+// yangDirHash - hash of the YANG files configd loaded its schema from, so
+// we can tell if the running config file was written with a different
+// schema.
+func yangDirHash(dir string) string {
+	files, _ := filepath.Glob(filepath.Join(dir, "*.yang"))
+	sort.Strings(files)
+
+	h := sha256.New()
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		h.Write([]byte(filepath.Base(file)))
+		h.Write(content)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// loadRunning loads the running config file written before configd was
+// restarted, returning the tree and the id of the commit that produced it.
+// If the file fails verification, or its secrets can't be decrypted, it
+// can't be trusted, so we fall back to the boot configuration and raise an
+// alarm.  A legacy file, from before runfiles were verified, is loaded as
+// it is.
+func loadRunning(
+	config *configd.Config,
+	ms schema.ModelSet,
+	schemaHash string,
+	elog *log.Logger,
+) (*data.Node, uint64) {
+	content, err := ioutil.ReadFile(config.Runfile)
+	if os.IsNotExist(err) {
+		t, _, _ := load.Load(config.Runfile, ms)
+		return t, 0
+	}
+
+	var hdr *session.RunfileHeader
+	var body []byte
+	if err == nil {
+		hdr, body, err = session.ParseRunfile(content)
+	}
+	if err == nil {
+		body, err = session.DecryptConfig(body)
+	}
+	if err != nil {
+		bootfile := configRevisionFileName("saved")
+		elog.Printf("Unable to verify %s, loading %s instead: %s",
+			config.Runfile, bootfile, err)
+		raiseAlarm(RunfileIntegrityAlarm, config.Runfile+
+			" failed verification at startup, boot configuration loaded: "+
+			err.Error())
+		return loadBootConfig(bootfile, ms, elog), 0
+	}
+
+	if hdr.Legacy {
+		elog.Printf("%s was written by an earlier configd, "+
+			"loading it unverified", config.Runfile)
+	} else if hdr.SchemaHash != schemaHash {
+		elog.Printf("%s was written with a different schema", config.Runfile)
+	}
+	t, _, _ := load.LoadFile(config.Runfile, bytes.NewReader(body), ms)
+	return t, hdr.CommitId
+}
+
+// loadBootConfig loads the boot configuration, decrypting its secrets.  If
+// they can't be decrypted the file is refused, rather than loading the
+// encrypted values, and an empty configuration is returned with an alarm
+// raised.
+func loadBootConfig(file string, ms schema.ModelSet, elog *log.Logger) *data.Node {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		t, _, _ := load.Load(file, ms)
+		return t
+	}
+	content, err = session.DecryptConfig(content)
+	if err != nil {
+		elog.Printf("Unable to decrypt secrets in %s, not loading it: %s",
+			file, err)
+		raiseAlarm(BootConfigIntegrityAlarm, file+
+			" could not be decrypted at startup, no configuration loaded: "+
+			err.Error())
+		return data.New("root")
+	}
+	t, _, _ := load.LoadFile(file, bytes.NewReader(content), ms)
+	return t
+}
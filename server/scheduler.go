@@ -0,0 +1,224 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/danos/configd/session"
+	"github.com/danos/mgmterror"
+)
+
+// Scheduled jobs
+//
+// Some features need work doing periodically, eg checking the running
+// config file hasn't drifted from the running configuration.  Rather than
+// each starting its own goroutine and timer, they register a job with the
+// scheduler here, so all such jobs can be listed, run on demand, or
+// disabled through the dispatcher.  A job that is still running when it
+// is next due is not run again until it completes.
+
+type scheduledJob struct {
+	name     string
+	interval time.Duration
+	fn       func() error
+	stop     chan struct{}
+
+	mu      sync.Mutex
+	enabled bool
+	running bool
+	runs    uint64
+	lastRun time.Time
+	lastErr error
+}
+
+func (j *scheduledJob) run() error {
+	j.mu.Lock()
+	if j.running {
+		j.mu.Unlock()
+		return fmt.Errorf("Scheduled job %s is already running", j.name)
+	}
+	j.running = true
+	j.mu.Unlock()
+
+	err := j.fn()
+
+	j.mu.Lock()
+	j.running = false
+	j.runs++
+	j.lastRun = time.Now()
+	j.lastErr = err
+	j.mu.Unlock()
+	return err
+}
+
+func (j *scheduledJob) loop() {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			j.mu.Lock()
+			enabled := j.enabled
+			j.mu.Unlock()
+			if enabled {
+				go j.run()
+			}
+		case <-j.stop:
+			return
+		}
+	}
+}
+
+func (j *scheduledJob) info() map[string]string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	info := map[string]string{
+		"interval":   j.interval.String(),
+		"enabled":    strconv.FormatBool(j.enabled),
+		"runs":       strconv.FormatUint(j.runs, 10),
+		"last-run":   "",
+		"last-error": "",
+	}
+	if !j.lastRun.IsZero() {
+		info["last-run"] = j.lastRun.Format(time.RFC3339)
+	}
+	if j.lastErr != nil {
+		info["last-error"] = j.lastErr.Error()
+	}
+	return info
+}
+
+type scheduler struct {
+	mu   sync.Mutex
+	jobs map[string]*scheduledJob
+}
+
+var jobScheduler = &scheduler{jobs: make(map[string]*scheduledJob)}
+
+// RegisterScheduledJob runs fn every interval until the job is
+// unregistered, replacing any existing job of the same name.
+func RegisterScheduledJob(name string, interval time.Duration, fn func() error) {
+	job := &scheduledJob{
+		name:     name,
+		interval: interval,
+		fn:       fn,
+		stop:     make(chan struct{}),
+		enabled:  true,
+	}
+
+	jobScheduler.mu.Lock()
+	if old, ok := jobScheduler.jobs[name]; ok {
+		close(old.stop)
+	}
+	jobScheduler.jobs[name] = job
+	jobScheduler.mu.Unlock()
+
+	go job.loop()
+}
+
+func UnregisterScheduledJob(name string) {
+	jobScheduler.mu.Lock()
+	defer jobScheduler.mu.Unlock()
+	if job, ok := jobScheduler.jobs[name]; ok {
+		close(job.stop)
+		delete(jobScheduler.jobs, name)
+	}
+}
+
+func (s *scheduler) get(name string) (*scheduledJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[name]
+	if !ok {
+		err := mgmterror.NewInvalidValueApplicationError()
+		err.Message = fmt.Sprintf("No scheduled job %s", name)
+		return nil, err
+	}
+	return job, nil
+}
+
+func (s *scheduler) list() map[string]map[string]string {
+	s.mu.Lock()
+	jobs := make([]*scheduledJob, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		jobs = append(jobs, job)
+	}
+	s.mu.Unlock()
+
+	out := make(map[string]map[string]string, len(jobs))
+	for _, job := range jobs {
+		out[job.name] = job.info()
+	}
+	return out
+}
+
+const (
+	RunfileDriftJob      = "runfile-drift-check"
+	runfileDriftInterval = time.Hour
+)
+
+// checkRunfileDrift - the running config file should always reflect the
+// latest commit, otherwise a configd restart would lose configuration.
+func checkRunfileDrift(runfile string, cmgr *session.CommitMgr) error {
+	content, err := ioutil.ReadFile(runfile)
+	if os.IsNotExist(err) && cmgr.CommitId() == 0 {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	hdr, _, err := session.ParseRunfile(content)
+	if err != nil {
+		raiseAlarm(RunfileDriftAlarm,
+			runfile+" failed verification: "+err.Error())
+		return err
+	}
+	if hdr.CommitId != cmgr.CommitId() {
+		err := fmt.Errorf("%s is from commit %d, running is from commit %d",
+			runfile, hdr.CommitId, cmgr.CommitId())
+		raiseAlarm(RunfileDriftAlarm, err.Error())
+		return err
+	}
+	return nil
+}
+
+func (d *Disp) ListScheduledJobs() (map[string]map[string]string, error) {
+	return jobScheduler.list(), nil
+}
+
+// RunScheduledJob runs the job now, returning once it has completed.
+func (d *Disp) RunScheduledJob(name string) (bool, error) {
+	if !d.ctx.Superuser {
+		return false, mgmterror.NewAccessDeniedApplicationError()
+	}
+	job, err := jobScheduler.get(name)
+	if err != nil {
+		return false, err
+	}
+	if err := job.run(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (d *Disp) SetScheduledJobEnabled(name string, enabled bool) (bool, error) {
+	if !d.ctx.Superuser {
+		return false, mgmterror.NewAccessDeniedApplicationError()
+	}
+	job, err := jobScheduler.get(name)
+	if err != nil {
+		return false, err
+	}
+	job.mu.Lock()
+	job.enabled = enabled
+	job.mu.Unlock()
+	return true, nil
+}
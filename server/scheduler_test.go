@@ -0,0 +1,106 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/danos/configd"
+)
+
+func newSchedulerTestDisp(superuser bool) *Disp {
+	return &Disp{ctx: &configd.Context{Superuser: superuser}}
+}
+
+func TestScheduledJobRunsPeriodically(t *testing.T) {
+	var runs int32
+	RegisterScheduledJob("test-periodic", 10*time.Millisecond, func() error {
+		atomic.AddInt32(&runs, 1)
+		return nil
+	})
+	defer UnregisterScheduledJob("test-periodic")
+
+	for i := 0; i < 100 && atomic.LoadInt32(&runs) < 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if atomic.LoadInt32(&runs) < 2 {
+		t.Fatalf("Job did not run periodically")
+	}
+}
+
+func TestScheduledJobListAndRun(t *testing.T) {
+	RegisterScheduledJob("test-run", time.Hour, func() error {
+		return fmt.Errorf("job failed")
+	})
+	defer UnregisterScheduledJob("test-run")
+	d := newSchedulerTestDisp(true)
+
+	jobs, _ := d.ListScheduledJobs()
+	info, ok := jobs["test-run"]
+	if !ok || info["runs"] != "0" || info["interval"] != "1h0m0s" {
+		t.Fatalf("Unexpected job info: %v", jobs)
+	}
+
+	if _, err := d.RunScheduledJob("test-run"); err == nil {
+		t.Fatalf("Job error should be returned")
+	}
+	jobs, _ = d.ListScheduledJobs()
+	info = jobs["test-run"]
+	if info["runs"] != "1" || info["last-error"] != "job failed" ||
+		info["last-run"] == "" {
+		t.Fatalf("Unexpected job info after run: %v", info)
+	}
+}
+
+func TestScheduledJobDisabled(t *testing.T) {
+	var runs int32
+	RegisterScheduledJob("test-disabled", 10*time.Millisecond, func() error {
+		atomic.AddInt32(&runs, 1)
+		return nil
+	})
+	defer UnregisterScheduledJob("test-disabled")
+	d := newSchedulerTestDisp(true)
+
+	if _, err := d.SetScheduledJobEnabled("test-disabled", false); err != nil {
+		t.Fatalf("Unable to disable job: %s", err)
+	}
+	// Allow for a run that started before the job was disabled
+	time.Sleep(20 * time.Millisecond)
+	before := atomic.LoadInt32(&runs)
+	time.Sleep(50 * time.Millisecond)
+	if atomic.LoadInt32(&runs) != before {
+		t.Fatalf("Disabled job should not run")
+	}
+
+	jobs, _ := d.ListScheduledJobs()
+	if jobs["test-disabled"]["enabled"] != "false" {
+		t.Fatalf("Job should be listed as disabled: %v", jobs)
+	}
+}
+
+func TestScheduledJobControlRequiresSuperuser(t *testing.T) {
+	RegisterScheduledJob("test-super", time.Hour, func() error {
+		return nil
+	})
+	defer UnregisterScheduledJob("test-super")
+	d := newSchedulerTestDisp(false)
+
+	if _, err := d.RunScheduledJob("test-super"); err == nil {
+		t.Fatalf("Only superusers should be able to run jobs")
+	}
+	if _, err := d.SetScheduledJobEnabled("test-super", false); err == nil {
+		t.Fatalf("Only superusers should be able to disable jobs")
+	}
+}
+
+func TestScheduledJobUnknown(t *testing.T) {
+	d := newSchedulerTestDisp(true)
+	if _, err := d.RunScheduledJob("no-such-job"); err == nil {
+		t.Fatalf("Running an unknown job should fail")
+	}
+}
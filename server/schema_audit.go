@@ -0,0 +1,92 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/danos/config/schema"
+	"github.com/danos/configd/common"
+	"github.com/danos/configd/rpc"
+	"github.com/danos/utils/pathutil"
+)
+
+// Schema audit
+//
+// What a configuration means depends as much on what it leaves out as on
+// what it says: leaves with defaults take effect without being set, which
+// also makes the non-presence containers above them visible, mandatory
+// nodes must be set before anything can be committed, and presence
+// containers enable behaviour by merely existing.  Platform integrators
+// need to review these across the whole model set.  GetSchemaAudit lists
+// the nodes with defaults, the mandatory nodes and the presence containers
+// at and below a path of the schema, sorted by path.
+
+type mandatoryNode interface {
+	Mandatory() bool
+}
+
+type schemaAuditWalk struct {
+	audit rpc.SchemaAudit
+}
+
+func (w *schemaAuditWalk) walk(sn schema.Node, path []string) {
+	pstr := pathutil.Pathstr(path)
+	if mn, ok := sn.(mandatoryNode); ok && mn.Mandatory() {
+		w.audit.Mandatory = append(w.audit.Mandatory, pstr)
+	}
+	switch v := sn.(type) {
+	case schema.Leaf:
+		if def, ok := v.Default(); ok {
+			w.audit.Defaults = append(w.audit.Defaults,
+				rpc.SchemaDefault{Path: pstr, Value: def})
+		}
+		return
+	case schema.LeafList:
+		return
+	case schema.Container:
+		if v.Presence() {
+			w.audit.Presence = append(w.audit.Presence, pstr)
+		}
+	}
+	for _, ch := range sn.Children() {
+		w.walk(ch, pathutil.CopyAppend(path, ch.Name()))
+	}
+}
+
+// GetSchemaAudit returns, as a JSON encoded rpc.SchemaAudit, the nodes with
+// defaults, the mandatory nodes and the presence containers at and below
+// path.
+func (d *Disp) GetSchemaAudit(path string) (string, error) {
+	ps := common.InternPath(path)
+	tmpl, err := d.schemaPathDescendant(ps)
+	if err != nil {
+		return "", err
+	}
+
+	w := &schemaAuditWalk{audit: rpc.SchemaAudit{
+		Defaults:  []rpc.SchemaDefault{},
+		Mandatory: []string{},
+		Presence:  []string{},
+	}}
+	if len(ps) == 0 {
+		for _, ch := range tmpl.Node.Children() {
+			w.walk(ch, []string{ch.Name()})
+		}
+	} else {
+		w.walk(tmpl.Node, ps)
+	}
+	sort.Slice(w.audit.Defaults, func(i, j int) bool {
+		return w.audit.Defaults[i].Path < w.audit.Defaults[j].Path
+	})
+	sort.Strings(w.audit.Mandatory)
+	sort.Strings(w.audit.Presence)
+	buf, err := json.Marshal(&w.audit)
+	if err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
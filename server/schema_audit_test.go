@@ -0,0 +1,81 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/danos/config/auth"
+	"github.com/danos/configd/rpc"
+)
+
+const schemaAuditSchema = `
+container top {
+	leaf mtu {
+		type uint32;
+		default 1500;
+	}
+	leaf name {
+		type string;
+		mandatory true;
+	}
+	container enable {
+		presence "Enables the feature";
+		leaf level {
+			type uint8;
+			default 3;
+		}
+	}
+}
+container other {
+	leaf plain {
+		type string;
+	}
+}`
+
+func getTestSchemaAudit(t *testing.T, path string) rpc.SchemaAudit {
+	t.Helper()
+	d := newTestDispatcher(t, auth.TestAutherAllowAll(), schemaAuditSchema,
+		emptyconfig)
+	out, err := d.GetSchemaAudit(path)
+	if err != nil {
+		t.Fatalf("Unable to audit schema: %s", err)
+	}
+	var audit rpc.SchemaAudit
+	if err := json.Unmarshal([]byte(out), &audit); err != nil {
+		t.Fatalf("Invalid audit %s: %s", out, err)
+	}
+	return audit
+}
+
+func TestGetSchemaAudit(t *testing.T) {
+	exp := rpc.SchemaAudit{
+		Defaults: []rpc.SchemaDefault{
+			{Path: "/top/enable/level", Value: "3"},
+			{Path: "/top/mtu", Value: "1500"},
+		},
+		Mandatory: []string{"/top/name"},
+		Presence:  []string{"/top/enable"},
+	}
+	if audit := getTestSchemaAudit(t, ""); !reflect.DeepEqual(audit, exp) {
+		t.Fatalf("Expected:\n%v\nGot:\n%v", exp, audit)
+	}
+}
+
+func TestGetSchemaAuditBelowPath(t *testing.T) {
+	exp := rpc.SchemaAudit{
+		Defaults: []rpc.SchemaDefault{
+			{Path: "/top/enable/level", Value: "3"},
+		},
+		Mandatory: []string{},
+		Presence:  []string{"/top/enable"},
+	}
+	if audit := getTestSchemaAudit(t, "/top/enable"); !reflect.DeepEqual(
+		audit, exp) {
+		t.Fatalf("Expected:\n%v\nGot:\n%v", exp, audit)
+	}
+}
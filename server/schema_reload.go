@@ -0,0 +1,113 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"sync"
+
+	"github.com/danos/config/schema"
+	"github.com/danos/configd/session"
+	"github.com/danos/mgmterror"
+)
+
+// Schema reload
+//
+// Installing a package that adds or changes YANG used to need configd to
+// be restarted, losing every open session.  Instead the schema can be
+// recompiled, on SIGHUP or through ReloadSchema, and swapped in while
+// running.  The commit manager moves over first, once any commit in
+// progress has finished, and only if the running configuration is valid
+// with the new schema.  Each session is then moved over if its
+// configuration is valid; those that aren't stay on the old schema and are
+// reported, so they can be saved and recreated.  Connections pick up the
+// new schema on their next request.  How the schema is compiled belongs to
+// the daemon, which supplies it with SetSchemaLoader.
+
+// SchemaLoader compiles the schema afresh for reload.
+type SchemaLoader interface {
+	Load() (ms, msFull schema.ModelSet, compMgr schema.ComponentManager,
+		err error)
+	// Loaded is called once configd has moved onto the schema last
+	// returned by Load.
+	Loaded()
+}
+
+var schemaReloader struct {
+	mu   sync.Mutex
+	srv  *Srv
+	load SchemaLoader
+}
+
+// SetSchemaLoader enables schema reload for the server, using load to
+// compile the new schema.
+func (s *Srv) SetSchemaLoader(load SchemaLoader) {
+	schemaReloader.mu.Lock()
+	defer schemaReloader.mu.Unlock()
+	schemaReloader.srv = s
+	schemaReloader.load = load
+}
+
+// schemas returns the schema currently in use.
+func (s *Srv) schemas() (schema.ModelSet, schema.ModelSet, schema.ComponentManager) {
+	s.schemaMu.RLock()
+	defer s.schemaMu.RUnlock()
+	return s.ms, s.msFull, s.CompMgr
+}
+
+func newSchemaReloadError(msg string) error {
+	err := mgmterror.NewOperationFailedApplicationError()
+	err.Message = msg
+	return err
+}
+
+// reloadSchema returns the error for each session left on the old schema.
+func reloadSchema() (map[string]string, error) {
+	schemaReloader.mu.Lock()
+	defer schemaReloader.mu.Unlock()
+	s := schemaReloader.srv
+	if s == nil || schemaReloader.load == nil {
+		return nil, newSchemaReloadError("Schema reload is not supported")
+	}
+
+	ms, msFull, compMgr, err := schemaReloader.load.Load()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.cmgr.ReloadSchema(ms); err != nil {
+		s.LogError(err)
+		return nil, newSchemaReloadError(
+			"Running configuration is not valid with the new schema:\n" +
+				err.Error())
+	}
+
+	s.schemaMu.Lock()
+	s.ms, s.msFull, s.CompMgr = ms, msFull, compMgr
+	s.schemaMu.Unlock()
+	// The new schema may have different get-state scripts.
+	session.InvalidateStateCache(nil)
+
+	incompatible := make(map[string]string)
+	for sid, err := range s.smgr.ReloadSchema(ms, msFull) {
+		incompatible[sid] = err.Error()
+	}
+	s.cmgr.SetRunfileInfo(yangDirHash(s.Config.Yangdir), s.cmgr.CommitId())
+	schemaReloader.load.Loaded()
+	return incompatible, nil
+}
+
+// ReloadSchema recompiles the schema and moves configd onto it, returning
+// the error for each session left on the old schema.
+func (s *Srv) ReloadSchema() (map[string]string, error) {
+	return reloadSchema()
+}
+
+// ReloadSchema recompiles the schema and moves configd onto it, returning
+// the error for each session left on the old schema.
+func (d *Disp) ReloadSchema() (map[string]string, error) {
+	if !d.ctx.Superuser {
+		return nil, mgmterror.NewAccessDeniedApplicationError()
+	}
+	return reloadSchema()
+}
@@ -0,0 +1,146 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/danos/config/load"
+	"github.com/danos/config/union"
+	"github.com/danos/configd/rpc"
+	"github.com/danos/configd/session"
+	"github.com/danos/mgmterror"
+)
+
+// showRunningForStorage returns the running configuration, as the caller
+// may read it, to be saved to a file, with its secrets encrypted if a key
+// is set.
+func (d *Disp) showRunningForStorage() (string, error) {
+	sess := d.getROSession(rpc.RUNNING, "")
+	if !session.EncryptingSecrets() {
+		return sess.Show(d.ctx, nil, false, false)
+	}
+	t, err := session.EncryptSecrets(d.ms, d.cmgr.Running())
+	if err != nil {
+		return "", err
+	}
+	out, err := union.NewNode(nil, t, d.ms, nil, 0).Show(
+		nil, union.Authorizer(sess.NewAuther(d.ctx)))
+	if err != nil {
+		return "", err
+	}
+	return d.cmgr.AddRunningComments(out, nil), nil
+}
+
+// configVersionTrailer returns the comments, such as the configuration
+// version, following the configuration in a file.
+func configVersionTrailer(config string) string {
+	lines := strings.SplitAfter(config, "\n")
+	i := len(lines)
+	for i > 0 {
+		line := strings.TrimSpace(lines[i-1])
+		if line != "" && !strings.HasPrefix(line, "/*") {
+			break
+		}
+		i--
+	}
+	return strings.Join(lines[i:], "")
+}
+
+func (d *Disp) encryptConfigFileInternal(file string) (bool, error) {
+	if !session.EncryptingSecrets() {
+		err := mgmterror.NewOperationFailedApplicationError()
+		err.Message = "No secret key is set"
+		return false, err
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+	r, err := d.cfgFileReader(f)
+	if err != nil {
+		return false, err
+	}
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return false, err
+	}
+	content, err = session.DecryptConfig(content)
+	if err != nil {
+		return false, err
+	}
+	t, err, invalidPaths := load.LoadFile(file, bytes.NewReader(content), d.ms)
+	if err != nil {
+		return false, err
+	}
+	// Rewriting the file would drop what this system can't load.
+	if len(invalidPaths) != 0 {
+		err := mgmterror.NewOperationFailedApplicationError()
+		err.Message = "Configuration has paths that are not valid " +
+			"on this system; not rewritten"
+		return false, err
+	}
+	if t, err = session.EncryptSecrets(d.ms, t); err != nil {
+		return false, err
+	}
+	cfg, err := union.NewNode(nil, t, d.ms, nil, 0).Show(
+		nil, union.ForceShowSecrets)
+	if err != nil {
+		return false, err
+	}
+	out := []byte(cfg + configVersionTrailer(string(content)))
+
+	if strings.HasSuffix(file, ".gz") {
+		var b bytes.Buffer
+		w := gzip.NewWriter(&b)
+		if _, err := w.Write(out); err != nil {
+			return false, err
+		}
+		if err := w.Close(); err != nil {
+			return false, err
+		}
+		out = b.Bytes()
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(file), ".encrypt.")
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(out); err != nil {
+		tmp.Close()
+		return false, err
+	}
+	if err := tmp.Chmod(fi.Mode()); err != nil {
+		tmp.Close()
+		return false, err
+	}
+	if err := tmp.Close(); err != nil {
+		return false, err
+	}
+	return true, os.Rename(tmp.Name(), file)
+}
+
+// EncryptConfigFile rewrites a configuration file, such as one saved or
+// archived before a secret key was set, with its secrets encrypted.
+func (d *Disp) EncryptConfigFile(file string) (bool, error) {
+	args := d.newCommandArgsForAaa("encrypt-config-file", []string{file}, nil)
+	if !d.ctx.Superuser || !d.authCommand(args) {
+		return false, mgmterror.NewAccessDeniedApplicationError()
+	}
+
+	return d.accountCmdWrapBoolErr(args, func() (interface{}, error) {
+		return d.encryptConfigFileInternal(file)
+	})
+}
@@ -16,13 +16,13 @@ import (
 	"os/user"
 	"reflect"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 	"unicode"
 
 	"github.com/danos/config/auth"
 	"github.com/danos/config/data"
-	"github.com/danos/config/load"
 	"github.com/danos/config/schema"
 	"github.com/danos/configd"
 	"github.com/danos/configd/session"
@@ -32,6 +32,7 @@ type Srv struct {
 	*net.UnixListener
 	ms         schema.ModelSet
 	msFull     schema.ModelSet
+	schemaMu   sync.RWMutex // Guards ms, msFull and CompMgr on reload
 	m          map[string]reflect.Method
 	smgr       *session.SessionMgr
 	cmgr       *session.CommitMgr
@@ -42,11 +43,7 @@ type Srv struct {
 	Wlog       *log.Logger
 	Config     *configd.Config
 	CompMgr    schema.ComponentManager
-}
-
-func loadRunning(config *configd.Config, ms schema.ModelSet) *data.Node {
-	t, _, _ := load.Load(config.Runfile, ms)
-	return t
+	limits     *userLimits // Per-user limits, if any
 }
 
 func NewSrv(
@@ -57,22 +54,28 @@ func NewSrv(
 	elog *log.Logger,
 	compMgr schema.ComponentManager,
 ) *Srv {
-	rt := loadRunning(config, ms)
+	initInstance(config)
+	schemaHash := yangDirHash(config.Yangdir)
+	rt, commitId := loadRunning(config, ms, schemaHash, elog)
 
-	dlog, err := configd.NewLogger(syslog.LOG_DEBUG|syslog.LOG_DAEMON, 0)
+	dlog, err := configd.NewInstanceLogger(syslog.LOG_DEBUG|syslog.LOG_DAEMON, 0,
+		config.Instance)
 	if err != nil {
 		elog.Println(err)
 		dlog = log.New(ioutil.Discard, "", 0)
 	}
 
-	wlog, err := configd.NewLogger(syslog.LOG_WARNING|syslog.LOG_DAEMON, 0)
+	wlog, err := configd.NewInstanceLogger(syslog.LOG_WARNING|syslog.LOG_DAEMON, 0,
+		config.Instance)
 	if err != nil {
 		elog.Println(err)
 		wlog = log.New(ioutil.Discard, "", 0)
 	}
 
-	u, _ := user.Lookup(username)
-	uid, _ := strconv.ParseUint(u.Uid, 10, 32)
+	uid, err := configdUid(username, config)
+	if err != nil {
+		elog.Println(err)
+	}
 
 	s := &Srv{
 		UnixListener: l,
@@ -81,12 +84,48 @@ func NewSrv(
 		m:            make(map[string]reflect.Method),
 		smgr:         session.NewSessionMgr(),
 		cmgr:         session.NewCommitMgr(data.NewAtomicNode(rt), ms),
-		uid:          uint32(uid),
+		uid:          uid,
 		Dlog:         dlog,
 		Elog:         elog,
 		Wlog:         wlog,
 		Config:       config,
 		CompMgr:      compMgr,
+		limits: newUserLimits(config.MaxUserConnections,
+			config.MaxUserRequests),
+	}
+
+	session.SetStateCacheTTL(time.Duration(config.StateCacheTTL) * time.Second)
+	session.SetStateScriptTimeout(
+		time.Duration(config.StateScriptTimeout) * time.Second)
+	session.SetScriptTimeout(time.Duration(config.ScriptTimeout) * time.Second)
+	s.cmgr.SetRunfileInfo(schemaHash, commitId)
+	s.cmgr.LoadChangesSinceBoot(config.Runfile)
+	s.cmgr.LoadAnnotations(config.Runfile)
+	s.cmgr.LoadComments(config.Runfile)
+	s.cmgr.LoadFrozenPaths(config.Runfile)
+	s.cmgr.LoadConfigGroups(config.Runfile)
+	s.cmgr.LoadUserStats(config.Runfile)
+	s.cmgr.LoadCommitStats(config.Runfile)
+	s.cmgr.AddCommitListener(subscriptions.publish)
+	s.cmgr.AddCommitListener(leafWatchers.publish)
+	s.cmgr.AddCommitListener(s.archiveCommit)
+	loadNacmRules(rt)
+	s.cmgr.AddCommitListener(applyNacmRules)
+	if config.StandbyPeer != "" {
+		s.startStandbyReplication(config.StandbyPeer)
+	}
+	if config.ApprovalHook != "" {
+		s.cmgr.SetCommitApprover(newApprovalHook(config.ApprovalHook))
+	}
+	RegisterScheduledJob(RunfileDriftJob, runfileDriftInterval, func() error {
+		return checkRunfileDrift(config.Runfile, s.cmgr)
+	})
+	RegisterScheduledJob(ArchivePruneJob, archivePruneInterval,
+		runArchivePrune)
+	if config.RevalidateInterval > 0 {
+		RegisterScheduledJob(RevalidateRunningJob,
+			revalidateInterval(config.RevalidateInterval),
+			s.revalidateRunning)
 	}
 
 	s.authGlobal = auth.NewAuthGlobal(username, s.Dlog, s.Elog)
@@ -109,6 +148,15 @@ func NewSrv(
 	s.smgr.Lock(ctx, "EFFECTIVE")
 	s.cmgr.SetEffective(effective)
 
+	// CLI sessions persisted before a restart, run as configd so the
+	// restored configuration isn't subject to the user's authorization.
+	rctx := *ctx
+	rctx.RaisePrivileges()
+	if sids := s.smgr.RestoreSessions(
+		&rctx, s.cmgr, s.ms, s.msFull); len(sids) != 0 {
+		s.Elog.Printf("Restored sessions: %s", strings.Join(sids, ", "))
+	}
+
 	t := reflect.TypeOf(new(Disp))
 	for m := 0; m < t.NumMethod(); m++ {
 		meth := t.Method(m)
@@ -128,9 +176,37 @@ func NewSrv(
 
 		s.m[meth.Name] = meth
 	}
+
+	// A confirmed commit pending when configd was restarted must still be
+	// reverted if it isn't confirmed in time.
+	s.cmgr.SetConfirmedCommitRevert(s.revertConfirmedCommit)
+	if err := migrateConfirmedCommitRevert(
+		s.cmgr.ConfirmedCommit(confirmedCommitJobFile)); err != nil {
+		s.LogError(err)
+	}
+	s.cmgr.ResumeConfirmedCommit(confirmedCommitJobFile,
+		DefaultTimeout*time.Second)
+	// Likewise any commit expiries pending.
+	s.cmgr.SetCommitExpiryRevert(s.revertCommitExpiry)
+	s.cmgr.ResumeCommitExpiries(commitExpiryJobFile)
 	return s
 }
 
+// configdUid - the uid connections from which are implicitly allowed.  It
+// may be given statically, where the user database isn't available.
+func configdUid(username string, config *configd.Config) (uint32, error) {
+	id := config.UserId
+	if id == "" {
+		u, err := user.Lookup(username)
+		if err != nil {
+			return 0, err
+		}
+		id = u.Uid
+	}
+	uid, err := strconv.ParseUint(id, 10, 32)
+	return uint32(uid), err
+}
+
 //Serve is the server main loop. It accepts connections and spawns a goroutine to handle that connection.
 func (s *Srv) Serve() error {
 	var err error
@@ -146,7 +222,8 @@ func (s *Srv) Serve() error {
 		}
 		sconn := s.NewConn(conn)
 
-		go sconn.Handle(s.CompMgr)
+		_, _, compMgr := s.schemas()
+		go sconn.Handle(compMgr)
 	}
 	return err
 }
@@ -0,0 +1,112 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/danos/configd"
+	"github.com/danos/configd/session"
+)
+
+func TestConfigdUidStatic(t *testing.T) {
+	cfg := &configd.Config{UserId: "1234"}
+	uid, err := configdUid("no-such-user-for-configd-test", cfg)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if uid != 1234 {
+		t.Fatalf("Unexpected uid: %d", uid)
+	}
+}
+
+func TestConfigdUidLookupFails(t *testing.T) {
+	_, err := configdUid("no-such-user-for-configd-test", &configd.Config{})
+	if err == nil {
+		t.Fatalf("Lookup of unknown user should fail")
+	}
+}
+
+func TestConfigdUidInvalid(t *testing.T) {
+	_, err := configdUid("", &configd.Config{UserId: "configd"})
+	if err == nil {
+		t.Fatalf("Non-numeric uid should fail")
+	}
+}
+
+func TestMigrateLegacyConfirmedCommitRevert(t *testing.T) {
+	dir, err := ioutil.TempDir("", "configd-confirm")
+	if err != nil {
+		t.Fatalf("Unable to create config dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	setConfigDir(dir)
+	defer setConfigDir(defaultConfigDir)
+
+	const cfg = "testcontainer {\n\ttestleaf before\n}\n"
+	if err := os.Mkdir(archiveDir(), 0755); err != nil {
+		t.Fatalf("Unable to create archive: %s", err)
+	}
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write([]byte(cfg))
+	w.Close()
+	if err := ioutil.WriteFile(configRevisionFileName("1"),
+		buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Unable to write archive revision: %s", err)
+	}
+
+	// No deadline, as written by vyatta-config-mgmt.pl
+	info := session.ConfirmedCommitInfo{Session: "1234"}
+	if err := migrateConfirmedCommitRevert(info); err != nil {
+		t.Fatalf("Unable to migrate revert file: %s", err)
+	}
+	got, err := ioutil.ReadFile(confirmedCommitRevertFile)
+	if err != nil {
+		t.Fatalf("Revert file not written: %s", err)
+	}
+	if string(got) != cfg {
+		t.Fatalf("Unexpected revert file:\n%s", got)
+	}
+}
+
+func TestUndecryptableRunfileRefused(t *testing.T) {
+	dir, err := ioutil.TempDir("", "configd-runfile")
+	if err != nil {
+		t.Fatalf("Unable to create config dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	setConfigDir(dir)
+	defer setConfigDir(defaultConfigDir)
+	defer clearAlarm(RunfileIntegrityAlarm)
+	defer clearAlarm(BootConfigIntegrityAlarm)
+
+	// No secret key is set, so neither can be decrypted
+	const cfg = "testcontainer {\n\tpassword \"$configd-aes256gcm$AAAA\"\n}\n"
+	config := &configd.Config{Runfile: dir + "/running.config"}
+	for _, file := range []string{config.Runfile, configBootFile()} {
+		if err := ioutil.WriteFile(file, []byte(cfg), 0600); err != nil {
+			t.Fatalf("Unable to write %s: %s", file, err)
+		}
+	}
+
+	rt, commitId := loadRunning(config, nil, "",
+		log.New(ioutil.Discard, "", 0))
+	if commitId != 0 || len(rt.Children()) != 0 {
+		t.Fatalf("Encrypted configuration should not have been loaded")
+	}
+	raised := alarms.get()
+	for _, name := range []string{
+		RunfileIntegrityAlarm, BootConfigIntegrityAlarm} {
+		if _, ok := raised[name]; !ok {
+			t.Fatalf("%s alarm not raised", name)
+		}
+	}
+}
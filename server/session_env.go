@@ -0,0 +1,87 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/danos/configd/common"
+	"github.com/danos/configd/rpc"
+	"github.com/danos/mgmterror"
+	"github.com/danos/utils/pathutil"
+	yang "github.com/danos/yang/schema"
+)
+
+// The shell wrappers export the environment returned by SessionGetEnv
+// when a session starts, and that of EditGetEnv each time the edit level
+// moves, so the edit level and prompt always come from the session (see
+// session/edit_level.go).
+
+const (
+	editLevelEnv = "VYATTA_EDIT_LEVEL"
+	promptEnv    = "PS1"
+)
+
+// editEnv returns the environment for the edit level path: the level
+// itself, and the prompt showing it.
+func editEnv(path []string) map[string]string {
+	level := "/"
+	if len(path) > 0 {
+		level = pathutil.Pathstr(path)
+	}
+	prompt := strings.Join(append([]string{"edit"}, path...), " ")
+	return map[string]string{
+		editLevelEnv: level,
+		promptEnv:    "[" + prompt + `]\n\u@\h# `,
+	}
+}
+
+// SessionGetEnv returns the environment for the session's shell: its
+// edit level and prompt, its id, and whether it has changes that are
+// uncommitted, or committed but not saved.
+func (d *Disp) SessionGetEnv(sid string) (map[string]string, error) {
+	sess, err := d.smgr.Get(d.ctx, sid)
+	if err != nil {
+		return nil, err
+	}
+	env := editEnv(sess.EditLevel())
+	env["VYATTA_CONFIG_SID"] = sid
+	env["VYATTA_CONFIG_CHANGED"] = strconv.FormatBool(sess.Changed(d.ctx))
+	env["VYATTA_CONFIG_SAVED"] = strconv.FormatBool(sess.Saved(d.ctx))
+	return env, nil
+}
+
+// EditGetEnv returns the environment for the session's edit level.
+func (d *Disp) EditGetEnv(sid string) (map[string]string, error) {
+	sess, err := d.smgr.Get(d.ctx, sid)
+	if err != nil {
+		return nil, err
+	}
+	return editEnv(sess.EditLevel()), nil
+}
+
+// EditSetLevel moves the session's edit level to path, which must exist
+// in its candidate, or to the top level if path is empty.
+func (d *Disp) EditSetLevel(sid string, path string) (bool, error) {
+	sess, err := d.smgr.Get(d.ctx, sid)
+	if err != nil {
+		return false, err
+	}
+	ps := pathutil.Makepath(path)
+	if len(ps) > 0 {
+		if err := d.validatePath(ps); err != nil {
+			return false, common.FormatConfigPathError(err)
+		}
+		if !d.authRead(ps) {
+			return false, mgmterror.NewAccessDeniedApplicationError()
+		}
+		if !d.getROSession(rpc.CANDIDATE, sid).Exists(d.ctx, ps) {
+			return false, yang.NewNodeNotExistsError(ps)
+		}
+	}
+	sess.SetEditLevel(ps)
+	return true, nil
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/danos/config/auth"
+)
+
+const sessionEnvSchema = `
+container interfaces {
+	list dataplane {
+		key tagnode;
+		leaf tagnode {
+			type string;
+		}
+		leaf description {
+			type string;
+		}
+	}
+}`
+
+const sessionEnvConfig = `interfaces {
+	dataplane dp0s1 {
+		description uplink
+	}
+}
+`
+
+func checkEditEnv(t *testing.T, env map[string]string, level, prompt string) {
+	t.Helper()
+	exp := map[string]string{
+		"VYATTA_EDIT_LEVEL": level,
+		"PS1":               prompt,
+	}
+	for name, value := range exp {
+		if env[name] != value {
+			t.Fatalf("%s: expected %q, got %q", name, value, env[name])
+		}
+	}
+}
+
+func TestEditSetLevel(t *testing.T) {
+	d := newTestDispatcher(t, auth.TestAutherAllowAll(),
+		sessionEnvSchema, sessionEnvConfig)
+	dispTestSetupSession(t, d, testSID)
+
+	env, err := d.EditGetEnv(testSID)
+	if err != nil {
+		t.Fatalf("Unable to get edit environment: %s", err)
+	}
+	checkEditEnv(t, env, "/", `[edit]\n\u@\h# `)
+
+	if _, err := d.EditSetLevel(testSID,
+		"/interfaces/dataplane/dp0s1"); err != nil {
+		t.Fatalf("Unable to set edit level: %s", err)
+	}
+	env, err = d.EditGetEnv(testSID)
+	if err != nil {
+		t.Fatalf("Unable to get edit environment: %s", err)
+	}
+	checkEditEnv(t, env, "/interfaces/dataplane/dp0s1",
+		`[edit interfaces dataplane dp0s1]\n\u@\h# `)
+
+	if _, err := d.EditSetLevel(testSID, ""); err != nil {
+		t.Fatalf("Unable to return to the top level: %s", err)
+	}
+	env, _ = d.EditGetEnv(testSID)
+	checkEditEnv(t, env, "/", `[edit]\n\u@\h# `)
+}
+
+func TestEditSetLevelInvalid(t *testing.T) {
+	d := newTestDispatcher(t, auth.TestAutherAllowAll(),
+		sessionEnvSchema, sessionEnvConfig)
+	dispTestSetupSession(t, d, testSID)
+
+	for _, path := range []string{
+		"/interfaces/dataplane/dp0s2",
+		"/interfaces/loopback",
+	} {
+		if _, err := d.EditSetLevel(testSID, path); err == nil {
+			t.Fatalf("%s: unexpected success", path)
+		}
+	}
+	env, _ := d.EditGetEnv(testSID)
+	checkEditEnv(t, env, "/", `[edit]\n\u@\h# `)
+}
+
+func TestSessionGetEnv(t *testing.T) {
+	d := newTestDispatcher(t, auth.TestAutherAllowAll(),
+		sessionEnvSchema, sessionEnvConfig)
+	dispTestSetupSession(t, d, testSID)
+
+	if _, err := d.Set(testSID,
+		"/interfaces/dataplane/dp0s1/description/core"); err != nil {
+		t.Fatalf("Unable to set description: %s", err)
+	}
+	env, err := d.SessionGetEnv(testSID)
+	if err != nil {
+		t.Fatalf("Unable to get session environment: %s", err)
+	}
+	exp := map[string]string{
+		"VYATTA_EDIT_LEVEL":     "/",
+		"PS1":                   `[edit]\n\u@\h# `,
+		"VYATTA_CONFIG_SID":     testSID,
+		"VYATTA_CONFIG_CHANGED": "true",
+		"VYATTA_CONFIG_SAVED":   "false",
+	}
+	if !reflect.DeepEqual(env, exp) {
+		t.Fatalf("Expected %v, got %v", exp, env)
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"fmt"
+
+	"github.com/danos/mgmterror"
+)
+
+// Session variables are held by the session (see session/variables.go),
+// and expanded in the paths given to Set, Delete and SetMultiple before
+// those are normalized and authorized, so authorization sees the path
+// actually changed.
+
+// expandVariables returns ps with the session's variables expanded.  An
+// unknown session is left for the caller to report.
+func (d *Disp) expandVariables(sid string, ps []string) []string {
+	sess, err := d.smgr.Get(d.ctx, sid)
+	if err != nil {
+		return ps
+	}
+	return sess.ExpandVariables(ps)
+}
+
+func (d *Disp) SetSessionVariable(sid, name, value string) (bool, error) {
+	sess, err := d.smgr.Get(d.ctx, sid)
+	if err != nil {
+		return false, err
+	}
+	if err := sess.SetVariable(name, value); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (d *Disp) UnsetSessionVariable(sid, name string) (bool, error) {
+	sess, err := d.smgr.Get(d.ctx, sid)
+	if err != nil {
+		return false, err
+	}
+	if !sess.UnsetVariable(name) {
+		err := mgmterror.NewInvalidValueApplicationError()
+		err.Message = fmt.Sprintf("No variable %s", name)
+		return false, err
+	}
+	return true, nil
+}
+
+func (d *Disp) ClearSessionVariables(sid string) (bool, error) {
+	sess, err := d.smgr.Get(d.ctx, sid)
+	if err != nil {
+		return false, err
+	}
+	sess.ClearVariables()
+	return true, nil
+}
+
+func (d *Disp) GetSessionVariables(sid string) (map[string]string, error) {
+	sess, err := d.smgr.Get(d.ctx, sid)
+	if err != nil {
+		return nil, err
+	}
+	return sess.Variables(), nil
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/danos/config/auth"
+	"github.com/danos/configd/rpc"
+	"github.com/danos/configd/server"
+)
+
+const sessionVariablesSchema = `
+container top {
+	leaf address {
+		type string;
+	}
+	leaf description {
+		type string;
+	}
+	leaf password {
+		type string;
+	}
+}`
+
+func checkSessionVariableValue(
+	t *testing.T,
+	d *server.Disp,
+	path, exp string,
+) {
+	t.Helper()
+	vals, err := d.Get(rpc.CANDIDATE, testSID, path)
+	if err != nil {
+		t.Fatalf("Unable to get %s: %s", path, err)
+	}
+	if len(vals) != 1 || vals[0] != exp {
+		t.Fatalf("%s: expected %s, got %v", path, exp, vals)
+	}
+}
+
+func TestSessionVariablesExpanded(t *testing.T) {
+	d := newTestDispatcher(t, auth.TestAutherAllowAll(),
+		sessionVariablesSchema, emptyconfig)
+	dispTestSetupSession(t, d, testSID)
+
+	if _, err := d.SetSessionVariable(testSID, "ADDR",
+		"10.0.0.1/24"); err != nil {
+		t.Fatalf("Unable to set variable: %s", err)
+	}
+	dispTestSet(t, d, testSID, "top/address/$ADDR")
+	dispTestSet(t, d, testSID, "top/description/${ADDR}-uplink")
+	// Undefined references are left alone
+	dispTestSet(t, d, testSID, "top/password/$6$salt$hash")
+
+	checkSessionVariableValue(t, d, "top/address", "10.0.0.1/24")
+	checkSessionVariableValue(t, d, "top/description", "10.0.0.1/24-uplink")
+	checkSessionVariableValue(t, d, "top/password", "$6$salt$hash")
+}
+
+func TestSessionVariablesListAndClear(t *testing.T) {
+	d := newTestDispatcher(t, auth.TestAutherAllowAll(),
+		sessionVariablesSchema, emptyconfig)
+	dispTestSetupSession(t, d, testSID)
+
+	if _, err := d.SetSessionVariable(testSID, "1BAD", "x"); err == nil {
+		t.Fatalf("Invalid variable name should be rejected")
+	}
+	d.SetSessionVariable(testSID, "ONE", "1")
+	d.SetSessionVariable(testSID, "TWO", "2")
+
+	vars, _ := d.GetSessionVariables(testSID)
+	exp := map[string]string{"ONE": "1", "TWO": "2"}
+	if !reflect.DeepEqual(vars, exp) {
+		t.Fatalf("Expected variables %v, got %v", exp, vars)
+	}
+	if _, err := d.UnsetSessionVariable(testSID, "ONE"); err != nil {
+		t.Fatalf("Unable to unset variable: %s", err)
+	}
+	if _, err := d.UnsetSessionVariable(testSID, "ONE"); err == nil {
+		t.Fatalf("Unsetting an undefined variable should fail")
+	}
+	if _, err := d.ClearSessionVariables(testSID); err != nil {
+		t.Fatalf("Unable to clear variables: %s", err)
+	}
+	if vars, _ := d.GetSessionVariables(testSID); len(vars) != 0 {
+		t.Fatalf("Variables not cleared: %v", vars)
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/danos/config/auth"
+	"github.com/danos/configd/rpc"
+	"github.com/danos/configd/session"
+	"github.com/danos/mgmterror"
+	"github.com/danos/utils/pathutil"
+)
+
+// Batched set and delete
+//
+// Loading hundreds of paths with a Set or Delete call for each is slow, as
+// every call is authorized, normalized and queued on the session on its
+// own.  SetMultiple takes an ordered batch of operations, as JSON encoded
+// rpc.PathOps, and applies them to the candidate in one go (see
+// session/batch.go).  Either all of them are applied or none are, in which
+// case an error is returned for each operation that failed.
+
+// batchOpError returns err as a management error for the operation's path.
+func batchOpError(path string, err error) error {
+	if merr, ok := err.(mgmterror.MgmtErrorRef); ok {
+		return merr
+	}
+	merr := mgmterror.NewOperationFailedApplicationError()
+	merr.Path = path
+	merr.Message = err.Error()
+	return merr
+}
+
+func (d *Disp) setMultipleInternal(
+	sid string,
+	pathOps []rpc.PathOp,
+	ops []session.BatchOp,
+	args []*commandArgs,
+) (bool, error) {
+	if err := d.checkExclusive(sid); err != nil {
+		return false, err
+	}
+	sess, err := d.smgr.Get(d.ctx, sid)
+	if err != nil {
+		return false, err
+	}
+
+	accounters := make([]auth.TaskAccounter, len(args))
+	for i, a := range args {
+		if accounter := d.getAccounter(a); accounter != nil {
+			accounter.AccountStart()
+			accounters[i] = accounter
+		}
+	}
+	err, opErrs := sess.ApplyBatch(d.ctx, ops)
+	for i, accounter := range accounters {
+		if accounter == nil {
+			continue
+		}
+		accErr := err
+		if opErrs != nil {
+			accErr = opErrs[i]
+		}
+		accounter.AccountStop(&accErr)
+	}
+	if err != nil {
+		return false, err
+	}
+	if opErrs == nil {
+		return true, nil
+	}
+
+	var merr mgmterror.MgmtErrorList
+	for i, opErr := range opErrs {
+		if opErr != nil {
+			merr.MgmtErrorListAppend(batchOpError(pathOps[i].Path, opErr))
+		}
+	}
+	return false, merr
+}
+
+// SetMultiple applies the JSON encoded list of rpc.PathOps to the session's
+// candidate, in order.  If any fails none are applied, and an error is
+// returned for each that failed.
+func (d *Disp) SetMultiple(sid, pathOps string) (bool, error) {
+	var pops []rpc.PathOp
+	if err := json.Unmarshal([]byte(pathOps), &pops); err != nil {
+		merr := mgmterror.NewInvalidValueApplicationError()
+		merr.Message = fmt.Sprintf("Invalid batch: %s", err)
+		return false, merr
+	}
+
+	var merr mgmterror.MgmtErrorList
+	ops := make([]session.BatchOp, len(pops))
+	args := make([]*commandArgs, len(pops))
+	for i, pop := range pops {
+		ps := d.expandVariables(sid, pathutil.Makepath(pop.Path))
+		switch pop.Op {
+		case session.BatchSet:
+			// Set data authorization is done in session_internal
+			nps, err := d.normalizePath(ps)
+			if err != nil {
+				merr.MgmtErrorListAppend(batchOpError(pop.Path, err))
+				continue
+			}
+			ps = nps
+		case session.BatchDelete:
+			if !d.authDelete(ps) {
+				err := mgmterror.NewAccessDeniedApplicationError()
+				err.Path = pop.Path
+				merr.MgmtErrorListAppend(err)
+				continue
+			}
+		default:
+			err := mgmterror.NewInvalidValueApplicationError()
+			err.Path = pop.Path
+			err.Message = fmt.Sprintf("Unknown batch operation %s", pop.Op)
+			merr.MgmtErrorListAppend(err)
+			continue
+		}
+
+		args[i] = d.newCommandArgsForAaa(pop.Op, nil, ps)
+		if !d.authCommand(args[i]) {
+			err := mgmterror.NewAccessDeniedApplicationError()
+			err.Path = pop.Path
+			merr.MgmtErrorListAppend(err)
+			continue
+		}
+		ops[i] = session.BatchOp{Op: pop.Op, Path: ps}
+	}
+	if len(merr.Errors()) != 0 {
+		return false, merr
+	}
+
+	return d.setMultipleInternal(sid, pops, ops, args)
+}
@@ -0,0 +1,108 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/danos/config/auth"
+	"github.com/danos/configd/rpc"
+	"github.com/danos/configd/server"
+	"github.com/danos/mgmterror"
+)
+
+const setMultipleSchema = `
+container cont {
+	leaf name {
+		type string;
+	}
+	leaf mtu {
+		type uint16;
+	}
+	leaf existing {
+		type string;
+	}
+}`
+
+const setMultipleConfig = `
+cont {
+	existing foo
+}
+`
+
+func setMultiple(d *server.Disp, sid string, ops ...rpc.PathOp) error {
+	buf, _ := json.Marshal(ops)
+	_, err := d.SetMultiple(sid, string(buf))
+	return err
+}
+
+func checkExists(t *testing.T, d *server.Disp, sid, path string, exp bool) {
+	t.Helper()
+	exists, _ := d.Exists(rpc.CANDIDATE, sid, path)
+	if exists != exp {
+		t.Fatalf("%s exists: %v, expected %v", path, exists, exp)
+	}
+}
+
+func TestSetMultiple(t *testing.T) {
+	d := newTestDispatcher(t, auth.TestAutherAllowAll(),
+		setMultipleSchema, setMultipleConfig)
+	sid := "TestSetMultiple"
+	dispTestSetupSession(t, d, sid)
+
+	err := setMultiple(d, sid,
+		rpc.PathOp{Op: "set", Path: "/cont/name/foo"},
+		rpc.PathOp{Op: "set", Path: "/cont/mtu/1500"},
+		rpc.PathOp{Op: "delete", Path: "/cont/existing"})
+	if err != nil {
+		t.Fatalf("Unable to apply batch: %s", err)
+	}
+	checkExists(t, d, sid, "/cont/name/foo", true)
+	checkExists(t, d, sid, "/cont/mtu/1500", true)
+	checkExists(t, d, sid, "/cont/existing", false)
+}
+
+func TestSetMultipleIsAtomic(t *testing.T) {
+	d := newTestDispatcher(t, auth.TestAutherAllowAll(),
+		setMultipleSchema, setMultipleConfig)
+	sid := "TestSetMultipleIsAtomic"
+	dispTestSetupSession(t, d, sid)
+
+	err := setMultiple(d, sid,
+		rpc.PathOp{Op: "set", Path: "/cont/name/foo"},
+		rpc.PathOp{Op: "set", Path: "/cont/mtu/notanumber"},
+		rpc.PathOp{Op: "delete", Path: "/cont/existing"},
+		rpc.PathOp{Op: "set", Path: "/cont/mtu/70000"})
+	merr, ok := err.(mgmterror.MgmtErrorList)
+	if !ok {
+		t.Fatalf("Expected error list, got %v", err)
+	}
+	if n := len(merr.Errors()); n != 2 {
+		t.Fatalf("Expected 2 errors, got %d: %s", n, err)
+	}
+
+	checkExists(t, d, sid, "/cont/name/foo", false)
+	checkExists(t, d, sid, "/cont/existing/foo", true)
+	if changed, _ := d.SessionChanged(sid); changed {
+		t.Fatal("Candidate changed by failed batch")
+	}
+}
+
+func TestSetMultipleInvalidOp(t *testing.T) {
+	d := newTestDispatcher(t, auth.TestAutherAllowAll(),
+		setMultipleSchema, setMultipleConfig)
+	sid := "TestSetMultipleInvalidOp"
+	dispTestSetupSession(t, d, sid)
+
+	err := setMultiple(d, sid,
+		rpc.PathOp{Op: "set", Path: "/cont/name/foo"},
+		rpc.PathOp{Op: "rename", Path: "/cont/existing"})
+	if err == nil || !strings.Contains(err.Error(), "rename") {
+		t.Fatalf("Expected unknown operation error, got %v", err)
+	}
+	checkExists(t, d, sid, "/cont/name/foo", false)
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/danos/config/union"
+	"github.com/danos/configd/client"
+)
+
+// Shadow commit
+//
+// In an HA pair changes are usually made to one node and then, once they
+// are seen to work, to the other.  Configuration that is valid on one node
+// isn't necessarily valid on the other, if eg the software or hardware
+// differ, which is only found when it is too late to back out cheaply.
+// With a shadow peer configured, the configuration resulting from each
+// commit is also validated on the peer, through its ValidateConfig API, and
+// the result added to the commit output.  The peer's own configuration is
+// never changed, and failure there doesn't affect the local commit.
+
+const shadowValidateTimeout = 2 * time.Minute
+
+// validateOnShadowPeer validates cfg on the configd listening on socket.
+func validateOnShadowPeer(socket, cfg string) error {
+	c, err := client.Dial("unix", socket, "SHADOW"+strconv.Itoa(os.Getpid()))
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	errch := make(chan error, 1)
+	go func() {
+		_, err := c.ValidateConfig("curly", cfg)
+		errch <- err
+	}()
+	select {
+	case err := <-errch:
+		return err
+	case <-time.After(shadowValidateTimeout):
+		return fmt.Errorf("No response after %s", shadowValidateTimeout)
+	}
+}
+
+func formatShadowResult(peer string, err error) string {
+	if err == nil {
+		return fmt.Sprintf("Shadow validation on %s succeeded.\n", peer)
+	}
+	return fmt.Sprintf("Shadow validation on %s failed:\n  %s\n", peer,
+		strings.Replace(strings.TrimSpace(err.Error()), "\n", "\n  ", -1))
+}
+
+// shadowCommitResult validates the running configuration on the shadow
+// peer, if there is one, returning the result for the commit output.
+func (d *Disp) shadowCommitResult() string {
+	if d.ctx.Config == nil || d.ctx.Config.ShadowPeer == "" {
+		return ""
+	}
+	peer := d.ctx.Config.ShadowPeer
+	cfg, err := union.NewNode(nil, d.cmgr.Running(), d.ms, nil, 0).Show(
+		nil, union.ForceShowSecrets)
+	if err == nil {
+		err = validateOnShadowPeer(peer, cfg)
+	}
+	if err != nil && d.ctx.Wlog != nil {
+		d.ctx.Wlog.Printf("Shadow validation on %s failed: %s", peer, err)
+	}
+	return formatShadowResult(peer, err)
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/danos/configd/rpc"
+)
+
+// startTestShadowPeer answers ValidateConfig with the given error, or
+// success if it is empty, returning the socket and the configs received.
+func startTestShadowPeer(t *testing.T, errMsg string) (string, chan string, func()) {
+	dir, err := ioutil.TempDir("", "configd-shadow")
+	if err != nil {
+		t.Fatalf("Unable to create socket dir: %s", err)
+	}
+	socket := filepath.Join(dir, "peer.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("Unable to listen: %s", err)
+	}
+	configs := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		dec, enc := json.NewDecoder(conn), json.NewEncoder(conn)
+		for {
+			var req rpc.Request
+			if err := dec.Decode(&req); err != nil {
+				return
+			}
+			resp := rpc.Response{Result: "", Id: req.Id}
+			switch req.Method {
+			case "ValidateConfig":
+				configs <- req.Args[2].(string)
+				if errMsg != "" {
+					resp = rpc.Response{Error: errMsg, Id: req.Id}
+				}
+			default:
+				resp = rpc.Response{Error: "Unknown method", Id: req.Id}
+			}
+			enc.Encode(&resp)
+		}
+	}()
+	return socket, configs, func() {
+		l.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestValidateOnShadowPeer(t *testing.T) {
+	socket, configs, cleanup := startTestShadowPeer(t, "")
+	defer cleanup()
+
+	if err := validateOnShadowPeer(socket, "system {\n}\n"); err != nil {
+		t.Fatalf("Unexpected shadow validation failure: %s", err)
+	}
+	if cfg := <-configs; cfg != "system {\n}\n" {
+		t.Fatalf("Peer received unexpected config: %q", cfg)
+	}
+}
+
+func TestValidateOnShadowPeerFails(t *testing.T) {
+	socket, _, cleanup := startTestShadowPeer(t, "Configuration path is invalid")
+	defer cleanup()
+
+	err := validateOnShadowPeer(socket, "bogus\n")
+	if err == nil {
+		t.Fatalf("Shadow validation should fail")
+	}
+	out := formatShadowResult(socket, err)
+	if !strings.Contains(out, "failed:\n  Configuration path is invalid") {
+		t.Fatalf("Unexpected shadow result: %s", out)
+	}
+}
+
+func TestValidateOnShadowPeerUnreachable(t *testing.T) {
+	if err := validateOnShadowPeer("/nonexistent/peer.sock", ""); err == nil {
+		t.Fatalf("Shadow validation should fail without a peer")
+	}
+}
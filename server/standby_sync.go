@@ -0,0 +1,251 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/danos/config/union"
+	"github.com/danos/configd/client"
+	"github.com/danos/configd/rpc"
+	"github.com/danos/configd/server/archive"
+	"github.com/danos/configd/session"
+	"github.com/danos/mgmterror"
+	"github.com/danos/utils/pathutil"
+)
+
+// Warm standby
+//
+// With a standby peer configured, every commit is replicated to the peer
+// configd: the paths changed, who committed it and when, and the resulting
+// configuration.  The standby holds each replicated revision in its
+// archive, tagged so the latest can be found, without touching its own
+// running configuration.  Failing over is then a matter of committing the
+// latest replicated revision on the standby, as a rollback to it.
+//
+// Replication is done in commit order by a single sender so commits never
+// wait on the peer.  Each revision carries the complete configuration, so
+// one that is dropped because the queue is full, or that the peer doesn't
+// receive, is made good by the next.  The channel is the peer's socket, on
+// which the kernel verified credentials say who is connecting, and the
+// standby only accepts revisions from root or the configd user.
+
+const (
+	standbyReplicaTag     = "replicated"
+	standbyVia            = "replication"
+	maxQueuedReplications = 100
+	replicateTimeout      = 2 * time.Minute
+)
+
+var standbySync struct {
+	mu sync.Mutex
+
+	// As the primary
+	peer     string
+	queue    chan *rpc.ReplicatedRevision
+	sentId   uint64
+	sentTime time.Time
+	sendErr  error
+	dropped  uint64
+
+	// As the standby
+	receivedId   uint64
+	receivedTime time.Time
+}
+
+// replicateToStandby sends rev to the configd listening on socket.
+func replicateToStandby(socket string, rev *rpc.ReplicatedRevision) error {
+	c, err := client.Dial("unix", socket, "STANDBY"+strconv.Itoa(os.Getpid()))
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	errch := make(chan error, 1)
+	go func() {
+		errch <- c.ReplicateRevision(rev)
+	}()
+	select {
+	case err := <-errch:
+		return err
+	case <-time.After(replicateTimeout):
+		return fmt.Errorf("No response after %s", replicateTimeout)
+	}
+}
+
+func (s *Srv) startStandbyReplication(peer string) {
+	queue := make(chan *rpc.ReplicatedRevision, maxQueuedReplications)
+	standbySync.mu.Lock()
+	standbySync.peer = peer
+	standbySync.queue = queue
+	standbySync.mu.Unlock()
+
+	go func() {
+		for rev := range queue {
+			err := replicateToStandby(peer, rev)
+			if err != nil {
+				s.LogError(fmt.Errorf(
+					"Unable to replicate commit %d to %s: %s",
+					rev.CommitId, peer, err))
+			}
+			standbySync.mu.Lock()
+			standbySync.sendErr = err
+			if err == nil {
+				standbySync.sentId = rev.CommitId
+				standbySync.sentTime = time.Now()
+			}
+			standbySync.mu.Unlock()
+		}
+	}()
+	s.cmgr.AddCommitListener(s.replicateCommit)
+}
+
+func (s *Srv) replicateCommit(n *session.CommitNotification) {
+	ms, _, _ := s.schemas()
+	cfg, err := union.NewNode(nil, n.New, ms, nil, 0).Show(
+		nil, union.ForceShowSecrets)
+	if err != nil {
+		s.LogError(err)
+		return
+	}
+	rev := &rpc.ReplicatedRevision{
+		CommitId: n.Id,
+		User:     n.User,
+		Time:     n.Time.Format(time.RFC3339),
+		Message:  n.Message,
+		Changes:  make([]rpc.TreeChange, 0, len(n.Changes)),
+		Config:   cfg,
+	}
+	for _, ch := range n.Changes {
+		rev.Changes = append(rev.Changes, rpc.TreeChange{
+			Op:   ch.Operation,
+			Path: pathutil.Pathstr(ch.Path),
+		})
+	}
+
+	standbySync.mu.Lock()
+	defer standbySync.mu.Unlock()
+	select {
+	case standbySync.queue <- rev:
+	default:
+		standbySync.dropped++
+	}
+}
+
+func (d *Disp) isReplicationPeer() bool {
+	return d.ctx.Configd || d.ctx.PeerUid == 0
+}
+
+// ReplicateRevision stores the JSON encoded rpc.ReplicatedRevision in the
+// archive as the latest replicated revision.  Revisions older than the
+// latest received are ignored.
+func (d *Disp) ReplicateRevision(revision string) (bool, error) {
+	if !d.isReplicationPeer() {
+		return false, mgmterror.NewAccessDeniedApplicationError()
+	}
+	var rev rpc.ReplicatedRevision
+	if err := json.Unmarshal([]byte(revision), &rev); err != nil {
+		merr := mgmterror.NewInvalidValueApplicationError()
+		merr.Message = "Invalid replicated revision: " + err.Error()
+		return false, merr
+	}
+
+	standbySync.mu.Lock()
+	defer standbySync.mu.Unlock()
+	if rev.CommitId != 0 && rev.CommitId <= standbySync.receivedId {
+		return true, nil
+	}
+	when, err := time.Parse(time.RFC3339, rev.Time)
+	if err != nil {
+		when = time.Now()
+	}
+
+	archiveMu.Lock()
+	defer archiveMu.Unlock()
+	store := archiveStore()
+	err = store.Add([]byte(rev.Config), archive.Revision{
+		Time:    when,
+		User:    rev.User,
+		Via:     standbyVia,
+		Comment: rev.Message,
+	})
+	if err != nil {
+		return false, err
+	}
+	if err := store.Tag(0, standbyReplicaTag); err != nil {
+		return false, err
+	}
+	standbySync.receivedId = rev.CommitId
+	standbySync.receivedTime = time.Now()
+	return true, nil
+}
+
+func formatSyncTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// GetStandbySyncStatus returns the peer replicated to and the last commit
+// sent to it, and the last commit received from a primary.
+func (d *Disp) GetStandbySyncStatus() (map[string]string, error) {
+	if !d.ctx.Superuser && !d.ctx.Configd {
+		return nil, mgmterror.NewAccessDeniedApplicationError()
+	}
+	standbySync.mu.Lock()
+	defer standbySync.mu.Unlock()
+
+	role := "none"
+	switch {
+	case standbySync.peer != "":
+		role = "primary"
+	case standbySync.receivedId != 0:
+		role = "standby"
+	}
+	status := map[string]string{
+		"role":                 role,
+		"peer":                 standbySync.peer,
+		"last-sent-commit":     strconv.FormatUint(standbySync.sentId, 10),
+		"last-sent-time":       formatSyncTime(standbySync.sentTime),
+		"dropped":              strconv.FormatUint(standbySync.dropped, 10),
+		"last-received-commit": strconv.FormatUint(standbySync.receivedId, 10),
+		"last-received-time":   formatSyncTime(standbySync.receivedTime),
+	}
+	if standbySync.queue != nil {
+		status["pending"] = strconv.Itoa(len(standbySync.queue))
+	}
+	if standbySync.sendErr != nil {
+		status["last-error"] = standbySync.sendErr.Error()
+	}
+	return status, nil
+}
+
+// StandbyFailover commits the latest revision replicated to this standby,
+// as a rollback to it.
+func (d *Disp) StandbyFailover(sid, comment string) (string, error) {
+	if !d.ctx.Superuser && !d.ctx.Configd {
+		return "", mgmterror.NewAccessDeniedApplicationError()
+	}
+	if _, err := resolveConfigRevision(standbyReplicaTag); err != nil {
+		merr := mgmterror.NewOperationFailedApplicationError()
+		merr.Message = "No replicated revision to fail over to"
+		return "", merr
+	}
+	if comment == "" {
+		comment = "Failover to replicated revision"
+	}
+	args := d.newCommandArgsForAaa("failover", nil, nil)
+
+	return d.accountCmdWrapStrErr(args, d.audited(AuditRollback, sid,
+		rpc.RUNNING, func() (interface{}, error) {
+			return d.rollbackInternal(sid, standbyReplicaTag, comment, false)
+		}))
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/danos/configd"
+	"github.com/danos/configd/rpc"
+)
+
+func replicateTestRevision(t *testing.T, d *Disp, id uint64, cfg string) {
+	t.Helper()
+	buf, err := json.Marshal(&rpc.ReplicatedRevision{
+		CommitId: id,
+		User:     "vyatta",
+		Time:     "2021-06-01T10:00:00Z",
+		Message:  "replicated",
+		Changes:  []rpc.TreeChange{{Op: "update", Path: "/system"}},
+		Config:   cfg,
+	})
+	if err != nil {
+		t.Fatalf("Unable to encode revision: %s", err)
+	}
+	if ok, err := d.ReplicateRevision(string(buf)); !ok || err != nil {
+		t.Fatalf("Unable to replicate revision %d: %s", id, err)
+	}
+}
+
+func TestReplicateRevision(t *testing.T) {
+	dir, err := ioutil.TempDir("", "configd-standby")
+	if err != nil {
+		t.Fatalf("Unable to create config dir: %s", err)
+	}
+	orig := configDir
+	configDir = dir
+	defer func() {
+		configDir = orig
+		os.RemoveAll(dir)
+	}()
+
+	d := &Disp{ctx: &configd.Context{Configd: true}}
+	if _, err := d.StandbyFailover("", ""); err == nil {
+		t.Fatalf("Failover without a replicated revision should fail")
+	}
+
+	replicateTestRevision(t, d, 1, "system {\n}\n")
+	replicateTestRevision(t, d, 2, "system {\n\thost-name two\n}\n")
+	// Stale revisions are ignored
+	replicateTestRevision(t, d, 1, "system {\n}\n")
+
+	revs, err := archiveStore().List()
+	if err != nil {
+		t.Fatalf("Unable to list archive: %s", err)
+	}
+	if len(revs) != 2 || revs[0].Via != standbyVia ||
+		revs[0].User != "vyatta" || len(revs[0].Tags) != 1 ||
+		revs[0].Tags[0] != standbyReplicaTag {
+		t.Fatalf("Unexpected archive: %+v", revs)
+	}
+	cfg, err := archiveStore().Read(0)
+	if err != nil || string(cfg) != "system {\n\thost-name two\n}\n" {
+		t.Fatalf("Unexpected replicated config %q: %v", cfg, err)
+	}
+
+	status, err := d.GetStandbySyncStatus()
+	if err != nil {
+		t.Fatalf("Unable to get sync status: %s", err)
+	}
+	if status["role"] != "standby" || status["last-received-commit"] != "2" {
+		t.Fatalf("Unexpected sync status: %v", status)
+	}
+
+	d.ctx = &configd.Context{PeerUid: 1000}
+	if _, err := d.ReplicateRevision("{}"); err == nil {
+		t.Fatalf("Revision accepted from an unprivileged peer")
+	}
+}
@@ -0,0 +1,22 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"github.com/danos/configd/common"
+	"github.com/danos/configd/session"
+	"github.com/danos/mgmterror"
+)
+
+// InvalidateStateCache drops the cached output of the get-state scripts of
+// the node at path, and of those below it, so the next request for their
+// state runs the scripts again.  It returns the number of entries dropped.
+func (d *Disp) InvalidateStateCache(path string) (int, error) {
+	ps := common.InternPath(path)
+	if !d.authRead(ps) {
+		return 0, mgmterror.NewAccessDeniedApplicationError()
+	}
+	return session.InvalidateStateCache(ps), nil
+}
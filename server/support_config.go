@@ -0,0 +1,268 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/danos/config/data"
+	"github.com/danos/config/schema"
+	"github.com/danos/config/union"
+	"github.com/danos/mgmterror"
+	"github.com/danos/utils/pathutil"
+)
+
+// Support configuration
+//
+// Attaching the running configuration to a vendor support ticket gives
+// away secrets, addresses and names.  SupportConfig returns a copy safe to
+// attach: secrets are hidden, and addresses and the values of chosen
+// leaves are replaced with pseudonyms.  Each value is given the same
+// pseudonym wherever it appears, so the configuration still shows which
+// parts refer to the same address or host; pseudonyms are only stable
+// within one copy.  Addresses become addresses from the benchmarking
+// (198.18.0.0/15) and documentation (2001:db8::/32) ranges, keeping any
+// prefix length.
+//
+// What is masked is set by a profile, a JSON file such as:
+//
+//	{
+//		"addresses": true,
+//		"keep-addresses": ["127.0.0.0/8", "::1/128"],
+//		"hostnames": ["/system/host-name", "/system/domain-name"],
+//		"values": ["/interfaces/dataplane/description"]
+//	}
+//
+// Hostnames and values are given by schema path, without list keys, and
+// become "host-N" and "value-N".  Without a profile the default profile
+// is used.
+
+type supportMaskProfile struct {
+	Addresses     bool     `json:"addresses"`
+	KeepAddresses []string `json:"keep-addresses"`
+	Hostnames     []string `json:"hostnames"`
+	Values        []string `json:"values"`
+}
+
+var defaultSupportMaskProfile = supportMaskProfile{
+	Addresses: true,
+	KeepAddresses: []string{
+		"0.0.0.0/32", "127.0.0.0/8", "255.255.255.255/32",
+		"::/128", "::1/128",
+	},
+	Hostnames: []string{
+		"/system/host-name",
+		"/system/domain-name",
+		"/system/domain-search/domain",
+	},
+}
+
+func newInvalidMaskProfileError(file, reason string) error {
+	err := mgmterror.NewInvalidValueApplicationError()
+	err.Message = fmt.Sprintf("Invalid masking profile %s: %s", file, reason)
+	return err
+}
+
+// readSupportMaskProfile reads the profile from file.  Errors don't quote
+// the file, as it may be one the caller can't read.
+func readSupportMaskProfile(file string) (*supportMaskProfile, error) {
+	if file == "" {
+		profile := defaultSupportMaskProfile
+		return &profile, nil
+	}
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, newInvalidMaskProfileError(file, "unable to read")
+	}
+	profile := &supportMaskProfile{}
+	if json.Unmarshal(content, profile) != nil {
+		return nil, newInvalidMaskProfileError(file, "not a valid profile")
+	}
+	return profile, nil
+}
+
+type supportConfigMasker struct {
+	addresses bool
+	keep      []*net.IPNet
+	hostnames map[string]bool
+	values    map[string]bool
+
+	pseudonyms map[string]string
+	nextV4     uint32
+	nextV6     uint32
+	nextHost   int
+	nextValue  int
+}
+
+func newSupportConfigMasker(
+	file string,
+	profile *supportMaskProfile,
+) (*supportConfigMasker, error) {
+	m := &supportConfigMasker{
+		addresses:  profile.Addresses,
+		hostnames:  make(map[string]bool),
+		values:     make(map[string]bool),
+		pseudonyms: make(map[string]string),
+	}
+	for _, prefix := range profile.KeepAddresses {
+		_, ipnet, err := net.ParseCIDR(prefix)
+		if err != nil {
+			return nil, newInvalidMaskProfileError(file,
+				"invalid prefix in keep-addresses")
+		}
+		m.keep = append(m.keep, ipnet)
+	}
+	for _, path := range profile.Hostnames {
+		m.hostnames[pathutil.Pathstr(pathutil.Makepath(path))] = true
+	}
+	for _, path := range profile.Values {
+		m.values[pathutil.Pathstr(pathutil.Makepath(path))] = true
+	}
+	return m, nil
+}
+
+func (m *supportConfigMasker) kept(ip net.IP) bool {
+	for _, ipnet := range m.keep {
+		if ipnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *supportConfigMasker) pseudonymizeIP(ip net.IP) string {
+	key := "ip " + ip.String()
+	if p, ok := m.pseudonyms[key]; ok {
+		return p
+	}
+	var p net.IP
+	if ip4 := ip.To4(); ip4 != nil {
+		m.nextV4++
+		p = make(net.IP, net.IPv4len)
+		binary.BigEndian.PutUint32(p, 0xc6120000+m.nextV4)
+	} else {
+		m.nextV6++
+		p = make(net.IP, net.IPv6len)
+		copy(p, net.ParseIP("2001:db8::"))
+		binary.BigEndian.PutUint32(p[12:], m.nextV6)
+	}
+	m.pseudonyms[key] = p.String()
+	return m.pseudonyms[key]
+}
+
+// maskAddress returns the pseudonym of value if it is an address or
+// prefix.
+func (m *supportConfigMasker) maskAddress(value string) (string, bool) {
+	addr, plen := value, ""
+	if i := strings.IndexByte(value, '/'); i >= 0 {
+		addr, plen = value[:i], value[i:]
+		if _, err := strconv.Atoi(plen[1:]); err != nil {
+			return "", false
+		}
+	}
+	ip := net.ParseIP(addr)
+	if ip == nil || m.kept(ip) {
+		return "", false
+	}
+	return m.pseudonymizeIP(ip) + plen, true
+}
+
+func (m *supportConfigMasker) pseudonymize(
+	kind string, next *int, value string,
+) string {
+	key := kind + " " + value
+	if p, ok := m.pseudonyms[key]; ok {
+		return p
+	}
+	*next++
+	m.pseudonyms[key] = kind + "-" + strconv.Itoa(*next)
+	return m.pseudonyms[key]
+}
+
+// mask returns the value to show for a value, or list key, of the node
+// at the schema path.
+func (m *supportConfigMasker) mask(schPath []string, value string) string {
+	p := pathutil.Pathstr(schPath)
+	switch {
+	case m.hostnames[p]:
+		return m.pseudonymize("host", &m.nextHost, value)
+	case m.values[p]:
+		return m.pseudonymize("value", &m.nextValue, value)
+	case m.addresses:
+		if masked, ok := m.maskAddress(value); ok {
+			return masked
+		}
+	}
+	return value
+}
+
+// copyMaskedTree returns a masked copy, named name, of n, the node at path
+// with the given schema path, leaving out what the caller may not read.
+func (d *Disp) copyMaskedTree(
+	m *supportConfigMasker,
+	sch schema.Node,
+	n *data.Node,
+	name string,
+	path, schPath []string,
+) *data.Node {
+	out := data.New(name)
+	for _, ch := range n.Children() {
+		chPath := pathutil.CopyAppend(path, ch.Name())
+		if !d.authRead(chPath) {
+			continue
+		}
+		switch sch.(type) {
+		case schema.Leaf, schema.LeafList:
+			out.AddChild(data.New(m.mask(schPath, ch.Name())))
+			continue
+		}
+		chSch := schema.Descendant(sch, []string{ch.Name()})
+		if chSch == nil {
+			continue
+		}
+		chName, chSchPath := ch.Name(), schPath
+		if _, ok := sch.(schema.List); ok {
+			chName = m.mask(schPath, ch.Name())
+		} else {
+			chSchPath = pathutil.CopyAppend(schPath, ch.Name())
+		}
+		out.AddChild(d.copyMaskedTree(m, chSch, ch, chName,
+			chPath, chSchPath))
+	}
+	return out
+}
+
+func (d *Disp) supportConfigInternal(profileFile string) (string, error) {
+	profile, err := readSupportMaskProfile(profileFile)
+	if err != nil {
+		return "", err
+	}
+	m, err := newSupportConfigMasker(profileFile, profile)
+	if err != nil {
+		return "", err
+	}
+	masked := d.copyMaskedTree(m, d.ms, d.cmgr.Running(), "root", nil, nil)
+	return union.NewNode(nil, masked, d.ms, nil, 0).Show(
+		nil, union.HideSecrets)
+}
+
+// SupportConfig returns the running configuration masked, as set by the
+// masking profile in profileFile, to attach to support tickets.
+func (d *Disp) SupportConfig(profileFile string) (string, error) {
+	args := d.newCommandArgsForAaa("support-config", []string{profileFile}, nil)
+	if !d.authCommand(args) {
+		return "", mgmterror.NewAccessDeniedApplicationError()
+	}
+
+	return d.accountCmdWrapStrErr(args, func() (interface{}, error) {
+		return d.supportConfigInternal(profileFile)
+	})
+}
@@ -0,0 +1,120 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/danos/configd/session/sessiontest"
+)
+
+const supportConfigSchema = `
+container system {
+	leaf host-name {
+		type string;
+	}
+	leaf password {
+		type string;
+		configd:secret "true";
+	}
+}
+container routes {
+	list route {
+		key prefix;
+		leaf prefix {
+			type string;
+		}
+		leaf next-hop {
+			type string;
+		}
+		leaf description {
+			type string;
+		}
+	}
+}`
+
+func newSupportConfigTestDisp(t *testing.T) *Disp {
+	srv, sess := sessiontest.NewTestSpec(t).
+		SetSingleSchema(supportConfigSchema).
+		Init()
+	for _, path := range [][]string{
+		{"system", "host-name", "customer-edge-1"},
+		{"system", "password", "hunter2"},
+		{"routes", "route", "10.1.0.0/16", "next-hop", "192.0.2.1"},
+		{"routes", "route", "10.1.0.0/16", "description", "Acme Corp"},
+		{"routes", "route", "10.2.0.0/16", "next-hop", "192.0.2.1"},
+		{"routes", "route", "127.0.0.0/8", "next-hop", "2001:db8:9::1"},
+	} {
+		sessiontest.ValidateSet(t, sess, srv.Ctx, path, false)
+	}
+	if _, errs, ok := sess.Commit(srv.Ctx, "", false); !ok {
+		t.Fatalf("Unable to commit: %v", errs)
+	}
+	return &Disp{smgr: srv.Smgr, cmgr: srv.Cmgr, ms: srv.Ms,
+		msFull: srv.MsFull, ctx: srv.Ctx}
+}
+
+func TestSupportConfigDefaultProfile(t *testing.T) {
+	d := newSupportConfigTestDisp(t)
+
+	out, err := d.SupportConfig("")
+	if err != nil {
+		t.Fatalf("Unable to get support config: %s", err)
+	}
+	for _, hidden := range []string{
+		"customer-edge-1", "hunter2", "10.1.0.0", "10.2.0.0",
+		"192.0.2.1", "2001:db8:9::1",
+	} {
+		if strings.Contains(out, hidden) {
+			t.Errorf("Support config shows %s:\n%s", hidden, out)
+		}
+	}
+	for _, shown := range []string{
+		"host-name host-1", "198.18.0.1/16", "198.18.0.3/16",
+		"127.0.0.0/8", "Acme Corp",
+	} {
+		if !strings.Contains(out, shown) {
+			t.Errorf("Support config doesn't show %s:\n%s", shown, out)
+		}
+	}
+	// The same address has the same pseudonym wherever it appears.
+	if strings.Count(out, "next-hop 198.18.0.2") != 2 {
+		t.Errorf("Next hop not masked consistently:\n%s", out)
+	}
+}
+
+func TestSupportConfigProfile(t *testing.T) {
+	d := newSupportConfigTestDisp(t)
+
+	f, err := ioutil.TempFile("", "mask-profile")
+	if err != nil {
+		t.Fatalf("Unable to create profile: %s", err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(`{"values": ["/routes/route/description"]}`)
+	f.Close()
+
+	out, err := d.SupportConfig(f.Name())
+	if err != nil {
+		t.Fatalf("Unable to get support config: %s", err)
+	}
+	for _, shown := range []string{
+		"customer-edge-1", "10.1.0.0/16", "description value-1",
+	} {
+		if !strings.Contains(out, shown) {
+			t.Errorf("Support config doesn't show %s:\n%s", shown, out)
+		}
+	}
+	if strings.Contains(out, "Acme Corp") {
+		t.Errorf("Support config shows masked value:\n%s", out)
+	}
+
+	if _, err := d.SupportConfig(f.Name() + ".missing"); err == nil {
+		t.Fatal("Missing profile accepted")
+	}
+}
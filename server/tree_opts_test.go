@@ -0,0 +1,110 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/danos/config/auth"
+	"github.com/danos/configd"
+	"github.com/danos/configd/rpc"
+	"github.com/danos/configd/server"
+	"github.com/danos/configd/session/sessiontest"
+)
+
+func newTreeOptsTestDispatcher(t *testing.T, inSecretsGroup bool) *server.Disp {
+	return newTestDispatcherWithCustomAuth(
+		t, auth.TestAutherAllowAll(),
+		showConfigWithContextDiffsTestSchema,
+		showConfigWithContextDiffsSecretList,
+		false, /* not configd user, so our auther gets used! */
+		inSecretsGroup)
+}
+
+func TestShowWithOptsSecrets(t *testing.T) {
+	d := newTreeOptsTestDispatcher(t, true)
+
+	out, err := d.ShowWithOpts(rpc.RUNNING, testSID, "secrets",
+		map[string]interface{}{"Secrets": true})
+	if err != nil {
+		t.Fatalf("Unexpected show error: %s", err)
+	}
+	if !strings.Contains(out, "one") {
+		t.Fatalf("Secret not shown:\n%s", out)
+	}
+}
+
+func TestShowWithOptsSecretsRedacted(t *testing.T) {
+	d := newTreeOptsTestDispatcher(t, false)
+
+	out, err := d.ShowWithOpts(rpc.RUNNING, testSID, "secrets",
+		map[string]interface{}{"Secrets": true})
+	if err != nil {
+		t.Fatalf("Unexpected show error: %s", err)
+	}
+	if strings.Contains(out, "one") || !strings.Contains(out, "********") {
+		t.Fatalf("Secret not redacted:\n%s", out)
+	}
+}
+
+func TestShowWithOptsForceSecretsDenied(t *testing.T) {
+	d := newTreeOptsTestDispatcher(t, false)
+
+	_, err := d.ShowWithOpts(rpc.RUNNING, testSID, "secrets",
+		map[string]interface{}{"ForceSecrets": true})
+	if err == nil {
+		t.Fatalf("Forcing secrets should be denied outside secrets group")
+	}
+}
+
+func TestTreeGetForceSecretsDenied(t *testing.T) {
+	d := newTreeOptsTestDispatcher(t, false)
+
+	_, err := d.TreeGet(rpc.RUNNING, testSID, "secrets", "json",
+		map[string]interface{}{"ForceSecrets": true})
+	if err == nil {
+		t.Fatalf("Forcing secrets should be denied outside secrets group")
+	}
+}
+
+func TestTreeGetForceSecretsAllowed(t *testing.T) {
+	d := newTreeOptsTestDispatcher(t, true)
+
+	out, err := d.TreeGet(rpc.RUNNING, testSID, "secrets", "json",
+		map[string]interface{}{"ForceSecrets": true})
+	if err != nil {
+		t.Fatalf("Unexpected tree get error: %s", err)
+	}
+	if !strings.Contains(out, "one") {
+		t.Fatalf("Secret not shown:\n%s", out)
+	}
+}
+
+func TestShowWithOptsStrictRedaction(t *testing.T) {
+	srv, _ := sessiontest.NewTestSpec(t).
+		SetSingleSchema(showConfigWithContextDiffsTestSchema).
+		SetConfig(showConfigWithContextDiffsSecretList).
+		SetAuther(auth.TestAutherAllowAll(), false, true).
+		Init()
+	srv.Ctx.Config.Redaction = configd.StrictRedaction
+	d := server.NewDispatcher(srv.Smgr, srv.Cmgr, srv.Ms, srv.MsFull,
+		srv.Ctx)
+
+	out, err := d.ShowWithOpts(rpc.RUNNING, testSID, "secrets",
+		map[string]interface{}{"Secrets": true})
+	if err != nil {
+		t.Fatalf("Unexpected show error: %s", err)
+	}
+	if strings.Contains(out, "one") || !strings.Contains(out, "********") {
+		t.Fatalf("Secret not redacted in strict mode:\n%s", out)
+	}
+
+	_, err = d.TreeGet(rpc.RUNNING, testSID, "secrets", "json",
+		map[string]interface{}{"ForceSecrets": true})
+	if err == nil {
+		t.Fatalf("Forcing secrets should be denied in strict mode")
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/danos/mgmterror"
+)
+
+// Per-user limits
+//
+// One misbehaving automation client could open hundreds of connections,
+// or pipeline requests on many of them, and leave configd no time for
+// anyone else.  With limits configured, each user (by login uid) may hold
+// only so many connections, and have only so many requests running at
+// once across them.  A request over the limit is refused, rather than
+// queued, with a resource-denied error saying which limit was hit, so the
+// client learns why.  A connection over the limit has its first request
+// refused in the same way and is then closed.  Limits of 0 mean there is
+// no limit.
+
+// How long a refused connection is given to send its first request.
+const refusedConnTimeout = 5 * time.Second
+
+type userLimits struct {
+	maxConns    int
+	maxRequests int
+
+	mu       sync.Mutex
+	conns    map[uint32]int
+	requests map[uint32]int
+}
+
+func newUserLimits(maxConns, maxRequests int) *userLimits {
+	return &userLimits{
+		maxConns:    maxConns,
+		maxRequests: maxRequests,
+		conns:       make(map[uint32]int),
+		requests:    make(map[uint32]int),
+	}
+}
+
+func newUserLimitError(what string, limit int) error {
+	err := mgmterror.NewResourceDeniedProtocolError()
+	err.Message = fmt.Sprintf(
+		"Too many %s for this user, the limit is %d", what, limit)
+	return err
+}
+
+// acquire counts one more of what in counts for uid, failing if that
+// would exceed limit.
+func (l *userLimits) acquire(
+	counts map[uint32]int,
+	uid uint32,
+	limit int,
+	what string,
+) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if limit > 0 && counts[uid] >= limit {
+		return newUserLimitError(what, limit)
+	}
+	counts[uid]++
+	return nil
+}
+
+func (l *userLimits) release(counts map[uint32]int, uid uint32) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if counts[uid]--; counts[uid] <= 0 {
+		delete(counts, uid)
+	}
+}
+
+func (l *userLimits) startConn(uid uint32) error {
+	return l.acquire(l.conns, uid, l.maxConns, "connections")
+}
+
+func (l *userLimits) endConn(uid uint32) {
+	l.release(l.conns, uid)
+}
+
+func (l *userLimits) startRequest(uid uint32) error {
+	return l.acquire(l.requests, uid, l.maxRequests, "concurrent requests")
+}
+
+func (l *userLimits) endRequest(uid uint32) {
+	l.release(l.requests, uid)
+}
+
+// refuse answers the connection's first request with err, and closes it.
+func (conn *SrvConn) refuse(err error) {
+	conn.SetReadDeadline(time.Now().Add(refusedConnTimeout))
+	if req, rerr := conn.readRequest(); rerr == nil {
+		conn.sendResponse(newResponse(nil, err, req.Id))
+	}
+	conn.Close()
+}
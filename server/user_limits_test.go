@@ -0,0 +1,57 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUserLimits(t *testing.T) {
+	l := newUserLimits(2, 1)
+
+	for i := 0; i < 2; i++ {
+		if err := l.startConn(1000); err != nil {
+			t.Fatalf("Connection %d refused: %s", i, err)
+		}
+	}
+	err := l.startConn(1000)
+	if err == nil {
+		t.Fatal("Connection over the limit allowed")
+	}
+	if !strings.Contains(err.Error(), "the limit is 2") {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if err := l.startConn(1001); err != nil {
+		t.Fatalf("Other user's connection refused: %s", err)
+	}
+	l.endConn(1000)
+	if err := l.startConn(1000); err != nil {
+		t.Fatalf("Connection refused after one ended: %s", err)
+	}
+
+	if err := l.startRequest(1000); err != nil {
+		t.Fatalf("Request refused: %s", err)
+	}
+	if err := l.startRequest(1000); err == nil {
+		t.Fatal("Request over the limit allowed")
+	}
+	l.endRequest(1000)
+	if err := l.startRequest(1000); err != nil {
+		t.Fatalf("Request refused after one ended: %s", err)
+	}
+}
+
+func TestUserLimitsUnlimited(t *testing.T) {
+	l := newUserLimits(0, 0)
+	for i := 0; i < 100; i++ {
+		if err := l.startConn(1000); err != nil {
+			t.Fatalf("Connection %d refused: %s", i, err)
+		}
+		if err := l.startRequest(1000); err != nil {
+			t.Fatalf("Request %d refused: %s", i, err)
+		}
+	}
+}
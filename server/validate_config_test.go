@@ -277,6 +277,15 @@ func TestValidateConfig(t *testing.T) {
 			errInfoTags: []*mgmterror.MgmtErrorInfoTag{
 				mgmterror.NewMgmtErrorInfoTag("", "bad-element", "val")},
 		},
+		{
+			name:           "Must statement not satisfied - curly",
+			sourceEncoding: "curly",
+			sourceConfig:   "musts {\n\tval1 foo\n}\n",
+			errPath:        "/musts/val1/foo",
+			errType:        "application",
+			errTag:         "operation-failed",
+			errMsg:         "Must have val2",
+		},
 		{
 			name:           "Must statement satisfied - XML",
 			sourceEncoding: "xml",
@@ -296,6 +305,12 @@ func TestValidateConfig(t *testing.T) {
 			sourceConfig:   `{"vyatta-test-validation-v1:musts":{"val1":"foo","val2":"bar"}}`,
 			noErr:          true,
 		},
+		{
+			name:           "Must statement satisfied - curly",
+			sourceEncoding: "curly",
+			sourceConfig:   "musts {\n\tval1 foo\n\tval2 bar\n}\n",
+			noErr:          true,
+		},
 		{
 			name:           "Missing list key - XML",
 			sourceEncoding: "xml",
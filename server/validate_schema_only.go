@@ -0,0 +1,225 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/danos/config/schema"
+	"github.com/danos/config/union"
+	"github.com/danos/configd/common"
+	"github.com/danos/configd/rpc"
+	"github.com/danos/configd/session"
+	"github.com/danos/mgmterror"
+	"github.com/danos/utils/pathutil"
+	yang "github.com/danos/yang/schema"
+	"github.com/danos/yang/xpath"
+	"github.com/danos/yang/xpath/xutils"
+)
+
+// Schema only validation
+//
+// CI pipelines pre-check configuration by loading it into a session, eg
+// with ValidateConfig, and validating it, but validation also runs the
+// configd:validate scripts, which expect to be run on the device and may
+// be slow or have side effects.  ValidateSchemaOnly checks only what the
+// schema says: that must expressions hold and when expressions hold for
+// nodes that exist, that mandatory nodes are present and that unique
+// constraints on lists are met.  Types are checked as each path is set,
+// without scripts for a session loaded by configd.  No script is run, so a
+// configuration passing this may still fail full validation on the device.
+
+type uniqueNode interface {
+	Uniques() [][]string
+}
+
+type schemaOnlyWalk struct {
+	root  schema.Node
+	xroot xutils.XpathNode
+	errs  []error
+}
+
+func (w *schemaOnlyWalk) fail(path []string, format string, args ...interface{}) {
+	err := mgmterror.NewOperationFailedApplicationError()
+	err.Path = pathutil.Pathstr(path)
+	err.Message = fmt.Sprintf(format, args...)
+	w.errs = append(w.errs, err)
+}
+
+func (w *schemaOnlyWalk) holds(mach *xpath.Machine, ctxNode xutils.XpathNode) bool {
+	res, err := xpath.NewCtxFromMach(mach, ctxNode).
+		EnableValidation().Run().GetBoolResult()
+	if err != nil {
+		common.Log(common.LevelError, common.TypeXpath,
+			"Unable to evaluate expression", "expr", mach.GetExpr(),
+			"error", err)
+	}
+	return err == nil && res
+}
+
+// checkXpath checks the must and when expressions of sch for the node at
+// path.
+func (w *schemaOnlyWalk) checkXpath(sch schema.Node, path []string) {
+	xn := xutils.FindNode(w.xroot, MakeNodeRef(path, w.root))
+	if xn == nil {
+		return
+	}
+	if _, ok := sch.(schema.List); ok {
+		// The reference to a list entry is to its key leaf.
+		if xn = xn.XParent(); xn == nil {
+			return
+		}
+	}
+	if wn, ok := sch.(whenNode); ok {
+		for _, when := range wn.Whens() {
+			ctxNode := xn
+			if when.AddParentNode {
+				ctxNode = xn.XParent()
+			}
+			if !w.holds(when.Mach, ctxNode) {
+				w.fail(path, "'when' condition is false: '%s'",
+					when.Mach.GetExpr())
+			}
+		}
+	}
+	if mn, ok := sch.(mustNode); ok {
+		for _, must := range mn.Musts() {
+			if !w.holds(must.Mach, xn) {
+				w.fail(path, "'must' condition is false: '%s'",
+					must.Mach.GetExpr())
+			}
+		}
+	}
+}
+
+// checkMandatory checks that the mandatory children of sch are present
+// below n.
+func (w *schemaOnlyWalk) checkMandatory(sch schema.Node, n union.Node, path []string) {
+	present := make(map[string]bool)
+	for _, ch := range n.Children() {
+		present[ch.Name()] = true
+	}
+	for _, ch := range sch.Children() {
+		if mn, ok := ch.(mandatoryNode); ok && mn.Mandatory() &&
+			!present[ch.Name()] {
+			w.fail(pathutil.CopyAppend(path, ch.Name()),
+				"Missing mandatory node %s", ch.Name())
+		}
+	}
+}
+
+func leafValue(entry union.Node, name string) (string, bool) {
+	for _, ch := range entry.Children() {
+		if ch.Name() != name {
+			continue
+		}
+		for _, val := range ch.Children() {
+			return val.Name(), true
+		}
+	}
+	return "", false
+}
+
+// checkUnique checks that no two entries of the list n have the same
+// values for any of its unique constraints.
+func (w *schemaOnlyWalk) checkUnique(sch schema.List, n union.Node, path []string) {
+	un, ok := sch.(uniqueNode)
+	if !ok {
+		return
+	}
+	for _, leaves := range un.Uniques() {
+		seen := make(map[string]string)
+	Entries:
+		for _, entry := range n.Children() {
+			vals := make([]string, 0, len(leaves))
+			for _, leaf := range leaves {
+				val, ok := leafValue(entry, leaf)
+				if !ok {
+					continue Entries
+				}
+				vals = append(vals, val)
+			}
+			key := strings.Join(vals, "\x00")
+			if other, ok := seen[key]; ok {
+				w.fail(pathutil.CopyAppend(path, entry.Name()),
+					"Non-unique %s: same as entry %s",
+					strings.Join(leaves, " "), other)
+				continue
+			}
+			seen[key] = entry.Name()
+		}
+	}
+}
+
+func (w *schemaOnlyWalk) walk(n union.Node, path []string) {
+	switch sch := n.GetSchema().(type) {
+	case schema.List:
+		w.checkUnique(sch, n, path)
+		for _, entry := range n.Children() {
+			epath := pathutil.CopyAppend(path, entry.Name())
+			w.checkXpath(sch, epath)
+			w.checkMandatory(sch, entry, epath)
+			w.walkChildren(entry, epath)
+		}
+		return
+	case schema.Leaf, schema.LeafList:
+		w.checkXpath(sch, path)
+		return
+	}
+	if len(path) != 0 {
+		w.checkXpath(n.GetSchema(), path)
+	}
+	w.checkMandatory(n.GetSchema(), n, path)
+	w.walkChildren(n, path)
+}
+
+func (w *schemaOnlyWalk) walkChildren(n union.Node, path []string) {
+	for _, ch := range n.Children() {
+		w.walk(ch, pathutil.CopyAppend(path, ch.Name()))
+	}
+}
+
+// validateAgainstSchema returns the schema violations in ut.
+func validateAgainstSchema(ut union.Node) []error {
+	w := &schemaOnlyWalk{
+		root:  ut.GetSchema(),
+		xroot: yang.ConvertToXpathNode(ut, ut.GetSchema()),
+	}
+	w.walk(ut, []string{})
+	return w.errs
+}
+
+func (d *Disp) validateSchemaOnlyInternal(sid string) (string, error) {
+	sess, err := d.smgr.Get(d.ctx, sid)
+	if err != nil {
+		return "", err
+	}
+	ut, err := d.getROSession(rpc.CANDIDATE, sid).GetTree(d.ctx,
+		pathutil.Makepath(""),
+		&session.TreeOpts{Defaults: true, Secrets: true})
+	if err != nil {
+		return "", err
+	}
+
+	errs := validateAgainstSchema(ut)
+	if len(errs) == 0 {
+		return "", nil
+	}
+	sess.SetLastFailure(failedValidate, errs)
+	var merr mgmterror.MgmtErrorList
+	merr.MgmtErrorListAppend(errs...)
+	return "", merr
+}
+
+// ValidateSchemaOnly validates the session's candidate against the schema
+// alone, without running configd:validate scripts.
+func (d *Disp) ValidateSchemaOnly(sid string) (string, error) {
+	args := d.newCommandArgsForAaa("validate", nil, nil)
+
+	return d.accountCmdWrapStrErr(args, func() (interface{}, error) {
+		return d.validateSchemaOnlyInternal(sid)
+	})
+}
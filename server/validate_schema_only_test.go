@@ -0,0 +1,68 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/danos/config/auth"
+	"github.com/danos/mgmterror"
+)
+
+const schemaOnlySchema = `
+container top {
+	configd:validate "false";
+	leaf checked {
+		type string;
+		must "../other";
+	}
+	leaf other {
+		type string;
+	}
+	container needed {
+		presence "Has a mandatory leaf";
+		leaf name {
+			type string;
+			mandatory true;
+		}
+	}
+}`
+
+func TestValidateSchemaOnlySkipsScripts(t *testing.T) {
+	d := newTestDispatcher(t, auth.TestAutherAllowAll(), schemaOnlySchema,
+		emptyconfig)
+	dispTestSetupSession(t, d, testSID)
+	dispTestSet(t, d, testSID, "top/checked/foo")
+	dispTestSet(t, d, testSID, "top/other/bar")
+
+	if _, err := d.Validate(testSID); err == nil {
+		t.Fatalf("Full validation should run the failing script")
+	}
+	if _, err := d.ValidateSchemaOnly(testSID); err != nil {
+		t.Fatalf("Unexpected schema only validation failure: %s", err)
+	}
+}
+
+func TestValidateSchemaOnlyFails(t *testing.T) {
+	d := newTestDispatcher(t, auth.TestAutherAllowAll(), schemaOnlySchema,
+		emptyconfig)
+	dispTestSetupSession(t, d, testSID)
+	dispTestSet(t, d, testSID, "top/checked/foo")
+	dispTestSet(t, d, testSID, "top/needed")
+
+	_, err := d.ValidateSchemaOnly(testSID)
+	merr, ok := err.(mgmterror.MgmtErrorList)
+	if !ok || len(merr.Errors()) != 2 {
+		t.Fatalf("Expected must and mandatory failures, got: %v", err)
+	}
+	for i, exp := range []string{"'must' condition is false",
+		"Missing mandatory node name"} {
+		if !strings.Contains(merr.Errors()[i].Error(), exp) {
+			t.Fatalf("Expected error containing %q, got: %s", exp,
+				merr.Errors()[i])
+		}
+	}
+}
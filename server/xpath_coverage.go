@@ -0,0 +1,203 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/danos/config/schema"
+	"github.com/danos/config/union"
+	"github.com/danos/configd/rpc"
+	"github.com/danos/configd/session"
+	"github.com/danos/mgmterror"
+	"github.com/danos/utils/pathutil"
+	yang "github.com/danos/yang/schema"
+	"github.com/danos/yang/xpath"
+	"github.com/danos/yang/xpath/xutils"
+)
+
+// XPath coverage
+//
+// Validation time is dominated by must and when expressions, and a single
+// badly written one, evaluated for every entry of a large list, can make
+// every commit slow.  Commit logging can report individual expressions
+// that take too long, but not which were evaluated how often or what they
+// cost in total.  XPathCoverage evaluates every must and when expression
+// that applies to the candidate, as validation would, and reports for each
+// expression, per schema node, how many times it held, didn't hold or
+// failed to evaluate, and the total and longest time it took.  Expressions
+// are listed most expensive first.  Only nodes the user may read are
+// covered.
+
+type mustNode interface {
+	Musts() []yang.MustContext
+}
+
+type whenNode interface {
+	Whens() []yang.WhenContext
+}
+
+type xpathCoverage struct {
+	Path   string `json:"path"`
+	Kind   string `json:"kind"`
+	Expr   string `json:"expression"`
+	True   int    `json:"true"`
+	False  int    `json:"false"`
+	Errors int    `json:"errors,omitempty"`
+	Total  int64  `json:"total-us"`
+	Max    int64  `json:"max-us"`
+}
+
+type xpathCoverageWalk struct {
+	root     schema.Node
+	xroot    xutils.XpathNode
+	authRead func([]string) bool
+	stats    map[string]*xpathCoverage
+}
+
+func (w *xpathCoverageWalk) record(
+	kind string, spath []string, mach *xpath.Machine, ctxNode xutils.XpathNode,
+) {
+	path := pathutil.Pathstr(spath)
+	key := kind + " " + path + " " + mach.GetExpr()
+	st, ok := w.stats[key]
+	if !ok {
+		st = &xpathCoverage{Path: path, Kind: kind, Expr: mach.GetExpr()}
+		w.stats[key] = st
+	}
+
+	start := time.Now()
+	res, err := xpath.NewCtxFromMach(mach, ctxNode).
+		EnableValidation().Run().GetBoolResult()
+	taken := time.Since(start).Microseconds()
+
+	st.Total += taken
+	if taken > st.Max {
+		st.Max = taken
+	}
+	switch {
+	case err != nil:
+		st.Errors++
+	case res:
+		st.True++
+	default:
+		st.False++
+	}
+}
+
+// evaluate evaluates the expressions of sch for the node at path, whose
+// schema path is spath.
+func (w *xpathCoverageWalk) evaluate(sch schema.Node, path, spath []string) {
+	xn := xutils.FindNode(w.xroot, MakeNodeRef(path, w.root))
+	if xn == nil {
+		return
+	}
+	if _, ok := sch.(schema.List); ok {
+		// The reference to a list entry is to its key leaf.
+		if xn = xn.XParent(); xn == nil {
+			return
+		}
+	}
+	if mn, ok := sch.(mustNode); ok {
+		for _, must := range mn.Musts() {
+			w.record("must", spath, must.Mach, xn)
+		}
+	}
+	if wn, ok := sch.(whenNode); ok {
+		for _, when := range wn.Whens() {
+			ctxNode := xn
+			if when.AddParentNode {
+				ctxNode = xn.XParent()
+			}
+			w.record("when", spath, when.Mach, ctxNode)
+		}
+	}
+}
+
+func (w *xpathCoverageWalk) walk(n union.Node, path, spath []string) {
+	if !w.authRead(path) {
+		return
+	}
+	switch sch := n.GetSchema().(type) {
+	case schema.List:
+		for _, entry := range n.Children() {
+			epath := pathutil.CopyAppend(path, entry.Name())
+			w.evaluate(sch, epath, spath)
+			w.walkChildren(entry, epath, spath)
+		}
+		return
+	case schema.Leaf, schema.LeafList:
+		w.evaluate(sch, path, spath)
+		return
+	}
+	if len(path) != 0 {
+		w.evaluate(n.GetSchema(), path, spath)
+	}
+	w.walkChildren(n, path, spath)
+}
+
+func (w *xpathCoverageWalk) walkChildren(n union.Node, path, spath []string) {
+	for _, ch := range n.Children() {
+		w.walk(ch, pathutil.CopyAppend(path, ch.Name()),
+			pathutil.CopyAppend(spath, ch.Name()))
+	}
+}
+
+func (w *xpathCoverageWalk) report() []*xpathCoverage {
+	out := make([]*xpathCoverage, 0, len(w.stats))
+	for _, st := range w.stats {
+		out = append(out, st)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Total != out[j].Total {
+			return out[i].Total > out[j].Total
+		}
+		if out[i].Path != out[j].Path {
+			return out[i].Path < out[j].Path
+		}
+		return out[i].Expr < out[j].Expr
+	})
+	return out
+}
+
+func (d *Disp) xpathCoverage(sid string) ([]*xpathCoverage, error) {
+	if _, err := d.smgr.Get(d.ctx, sid); err != nil {
+		return nil, err
+	}
+	sess := d.getROSession(rpc.CANDIDATE, sid)
+	ut, err := sess.GetTree(d.ctx, pathutil.Makepath(""),
+		&session.TreeOpts{Defaults: true, Secrets: true})
+	if err != nil {
+		return nil, err
+	}
+
+	w := &xpathCoverageWalk{
+		root:     ut.GetSchema(),
+		xroot:    yang.ConvertToXpathNode(ut, ut.GetSchema()),
+		authRead: d.authRead,
+		stats:    make(map[string]*xpathCoverage),
+	}
+	w.walk(ut, []string{}, []string{})
+	return w.report(), nil
+}
+
+// XPathCoverage returns, as JSON, how often each must and when expression
+// applying to the candidate held and how long it took to evaluate.
+func (d *Disp) XPathCoverage(sid string) (string, error) {
+	if !d.authRead([]string{}) {
+		return "", mgmterror.NewAccessDeniedApplicationError()
+	}
+	report, err := d.xpathCoverage(sid)
+	if err != nil {
+		return "", err
+	}
+	buf, err := json.Marshal(report)
+	if err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
@@ -0,0 +1,85 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/danos/config/auth"
+)
+
+const xpathCoverageSchema = `
+container limits {
+	list limit {
+		key tagnode;
+		leaf tagnode {
+			type string;
+		}
+		leaf max {
+			type uint32;
+			must ". >= ../min";
+		}
+		leaf min {
+			type uint32;
+		}
+		leaf burst {
+			when "../max > 10";
+			type uint32;
+		}
+	}
+}`
+
+const xpathCoverageConfig = `
+limits {
+	limit A {
+		max 20
+		min 10
+		burst 5
+	}
+	limit B {
+		max 5
+		min 10
+	}
+}
+`
+
+type testXPathCoverage struct {
+	Path  string `json:"path"`
+	Kind  string `json:"kind"`
+	Expr  string `json:"expression"`
+	True  int    `json:"true"`
+	False int    `json:"false"`
+}
+
+func TestXPathCoverage(t *testing.T) {
+	d := newTestDispatcher(
+		t, auth.TestAutherAllowAll(), xpathCoverageSchema, xpathCoverageConfig)
+	dispTestSetupSession(t, d, testSID)
+
+	out, err := d.XPathCoverage(testSID)
+	if err != nil {
+		t.Fatalf("Unable to get coverage: %s", err)
+	}
+	var report []testXPathCoverage
+	if err := json.Unmarshal([]byte(out), &report); err != nil {
+		t.Fatalf("Unable to decode coverage: %s\n%s", err, out)
+	}
+
+	exp := map[string]testXPathCoverage{
+		"must": {Path: "/limits/limit/max", True: 1, False: 1},
+		"when": {Path: "/limits/limit/burst", True: 1},
+	}
+	if len(report) != len(exp) {
+		t.Fatalf("Unexpected coverage: %s", out)
+	}
+	for _, cov := range report {
+		e, ok := exp[cov.Kind]
+		if !ok || cov.Path != e.Path || cov.True != e.True ||
+			cov.False != e.False || cov.Expr == "" {
+			t.Fatalf("Unexpected %s coverage: %+v", cov.Kind, cov)
+		}
+	}
+}
@@ -0,0 +1,321 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/danos/configd/rpc"
+	"github.com/danos/mgmterror"
+	"github.com/danos/utils/pathutil"
+)
+
+// YANG-Push subscriptions (RFC 8641)
+//
+// Commit notifications only cover configuration, so NETCONF and RESTCONF
+// front ends streaming operational data to collectors had to poll
+// TreeGetFull themselves.  EstablishSubscription instead registers a
+// subscription to the config and state at or below a path, sampled as for
+// TreeGetFull, with one of two triggers:
+//
+//   periodic  - an update is sent every period
+//   on-change - the data is sampled every dampening period, and an update
+//               is sent only when it differs from that last sent
+//
+// Periods are in centiseconds, as in RFC 8641.  As with commit
+// notifications, the front end waits for each update in turn, which blocks
+// the connection, and subscriptions not waited on for a while are
+// discarded.  Sampling is done by the waiting caller, so updates never
+// reveal more than the subscriber could read with TreeGetFull.
+
+const (
+	PushTriggerPeriodic = "periodic"
+	PushTriggerOnChange = "on-change"
+
+	pushUpdate       = "push-update"
+	pushChangeUpdate = "push-change-update"
+
+	// Each sample fetches state from the components, so bound how often.
+	minPushPeriod = 100 * time.Millisecond
+)
+
+type pushSubscription struct {
+	id       string
+	uid      uint32
+	path     []string
+	encoding string
+	trigger  string
+
+	mu         sync.Mutex
+	period     time.Duration
+	next       time.Time
+	last       string
+	sent       bool
+	lastActive time.Time
+	// Closed when the subscription is modified or deleted, waking waiters.
+	changed chan struct{}
+}
+
+func (s *pushSubscription) idle() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return time.Since(s.lastActive) > notificationIdleTimeout
+}
+
+func (s *pushSubscription) setPeriod(period time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.period = period
+	s.next = time.Now().Add(period)
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// due returns how long until the next sample is due, and a channel closed
+// should the subscription be modified before then.
+func (s *pushSubscription) due() (time.Duration, <-chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastActive = time.Now()
+	return time.Until(s.next), s.changed
+}
+
+// sampled records the sample just taken, returning the kind of update to
+// send for it, or "" if there is nothing to send.
+func (s *pushSubscription) sampled(out string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.next = time.Now().Add(s.period)
+	if s.trigger == PushTriggerPeriodic {
+		return pushUpdate
+	}
+	if !s.sent {
+		// RFC 8641 starts on-change subscriptions with the full contents.
+		s.sent = true
+		s.last = out
+		return pushUpdate
+	}
+	if out == s.last {
+		return ""
+	}
+	s.last = out
+	return pushChangeUpdate
+}
+
+type pushSubscriptions struct {
+	mu sync.Mutex
+	m  map[string]*pushSubscription
+}
+
+var pushSubs = &pushSubscriptions{m: make(map[string]*pushSubscription)}
+
+// add registers the subscription, discarding any left idle.
+func (p *pushSubscriptions) add(sub *pushSubscription) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for id, s := range p.m {
+		if s.idle() {
+			delete(p.m, id)
+		}
+	}
+	p.m[sub.id] = sub
+}
+
+func (p *pushSubscriptions) remove(id string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if sub, ok := p.m[id]; ok {
+		sub.mu.Lock()
+		close(sub.changed)
+		sub.changed = make(chan struct{})
+		sub.mu.Unlock()
+		delete(p.m, id)
+	}
+}
+
+func (p *pushSubscriptions) get(id string) (*pushSubscription, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	sub, ok := p.m[id]
+	return sub, ok
+}
+
+// getPushSubscription returns the subscription, which must belong to the
+// caller.
+func (d *Disp) getPushSubscription(id string) (*pushSubscription, error) {
+	sub, ok := pushSubs.get(id)
+	if !ok {
+		return nil, newNoSubscriptionError(id)
+	}
+	if sub.uid != d.ctx.Uid && !d.ctx.Configd && !d.ctx.Superuser {
+		return nil, newNoSubscriptionError(id)
+	}
+	return sub, nil
+}
+
+func parsePushPeriod(period int) (time.Duration, error) {
+	dur := time.Duration(period) * 10 * time.Millisecond
+	if dur < minPushPeriod {
+		err := mgmterror.NewInvalidValueApplicationError()
+		err.Message = fmt.Sprintf(
+			"Invalid period %d: must be at least %d centiseconds",
+			period, minPushPeriod/(10*time.Millisecond))
+		return 0, err
+	}
+	return dur, nil
+}
+
+// EstablishSubscription subscribes to the config and state at or below
+// path in the given encoding, sent on the trigger, either "periodic" or
+// "on-change", with period the update or dampening period in centiseconds.
+// It returns the id to wait on with WaitPushUpdate.
+func (d *Disp) EstablishSubscription(
+	path, encoding, trigger string,
+	period int,
+) (string, error) {
+	switch encoding {
+	case "json", "rfc7951", "xml":
+	default:
+		err := mgmterror.NewInvalidValueApplicationError()
+		err.Message = fmt.Sprintf("Unsupported encoding %s", encoding)
+		return "", err
+	}
+	switch trigger {
+	case PushTriggerPeriodic, PushTriggerOnChange:
+	default:
+		err := mgmterror.NewInvalidValueApplicationError()
+		err.Message = fmt.Sprintf("Unsupported trigger %s", trigger)
+		return "", err
+	}
+	dur, err := parsePushPeriod(period)
+	if err != nil {
+		return "", err
+	}
+	ps := pathutil.Makepath(path)
+	if !d.authRead(ps) {
+		return "", mgmterror.NewAccessDeniedApplicationError()
+	}
+	if err := d.validatePath(ps); err != nil {
+		return "", err
+	}
+
+	id, err := newSubscriptionId()
+	if err != nil {
+		return "", err
+	}
+	// The first update is sent straight away.
+	pushSubs.add(&pushSubscription{
+		id:         id,
+		uid:        d.ctx.Uid,
+		path:       ps,
+		encoding:   encoding,
+		trigger:    trigger,
+		period:     dur,
+		next:       time.Now(),
+		lastActive: time.Now(),
+		changed:    make(chan struct{}),
+	})
+	return id, nil
+}
+
+// ModifySubscription changes the update or dampening period of the
+// subscription.
+func (d *Disp) ModifySubscription(id string, period int) (bool, error) {
+	sub, err := d.getPushSubscription(id)
+	if err != nil {
+		return false, err
+	}
+	dur, err := parsePushPeriod(period)
+	if err != nil {
+		return false, err
+	}
+	sub.setPeriod(dur)
+	return true, nil
+}
+
+func (d *Disp) DeleteSubscription(id string) (bool, error) {
+	sub, err := d.getPushSubscription(id)
+	if err != nil {
+		return false, err
+	}
+	pushSubs.remove(sub.id)
+	return true, nil
+}
+
+type pushNotification struct {
+	Id       string          `json:"id"`
+	Type     string          `json:"type"`
+	Time     string          `json:"time"`
+	Contents json.RawMessage `json:"datastore-contents"`
+}
+
+func (d *Disp) newPushNotification(
+	sub *pushSubscription, kind, out string,
+) (string, error) {
+	contents := json.RawMessage(out)
+	if sub.encoding == "xml" {
+		buf, err := json.Marshal(out)
+		if err != nil {
+			return "", err
+		}
+		contents = buf
+	}
+	buf, err := json.Marshal(&pushNotification{
+		Id:       sub.id,
+		Type:     kind,
+		Time:     time.Now().Format(time.RFC3339),
+		Contents: contents,
+	})
+	return string(buf), err
+}
+
+// WaitPushUpdate returns the next push-update or push-change-update for the
+// subscription, as JSON, waiting up to timeout seconds for one.  An empty
+// string is returned if none is due in time.
+func (d *Disp) WaitPushUpdate(id string, timeout int) (string, error) {
+	sub, err := d.getPushSubscription(id)
+	if err != nil {
+		return "", err
+	}
+	wait := time.Duration(timeout) * time.Second
+	if wait > maxNotificationWait {
+		wait = maxNotificationWait
+	}
+	deadline := time.NewTimer(wait)
+	defer deadline.Stop()
+
+	for {
+		until, changed := sub.due()
+		if until > 0 {
+			timer := time.NewTimer(until)
+			select {
+			case <-timer.C:
+			case <-changed:
+				timer.Stop()
+				// Modified or deleted; check it is still there.
+				if _, err := d.getPushSubscription(id); err != nil {
+					return "", err
+				}
+				continue
+			case <-deadline.C:
+				timer.Stop()
+				return "", nil
+			}
+		}
+
+		out, err := d.TreeGetFull(rpc.RUNNING, "RUNNING",
+			pathutil.Pathstr(sub.path), sub.encoding,
+			map[string]interface{}{})
+		if err != nil {
+			return "", err
+		}
+		if kind := sub.sampled(out); kind != "" {
+			return d.newPushNotification(sub, kind, out)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package server
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/danos/configd/session/sessiontest"
+)
+
+func waitPushUpdate(t *testing.T, d *Disp, id string) *pushNotification {
+	out, err := d.WaitPushUpdate(id, 1)
+	if err != nil {
+		t.Fatalf("Unable to wait for push update: %s", err)
+	}
+	if out == "" {
+		return nil
+	}
+	var n pushNotification
+	if err := json.Unmarshal([]byte(out), &n); err != nil {
+		t.Fatalf("Invalid push update %s: %s", out, err)
+	}
+	return &n
+}
+
+func TestYangPushOnChange(t *testing.T) {
+	srv, sess := sessiontest.NewTestSpec(t).
+		SetSingleSchema(notifySchema).
+		SetConfig(notifyConfig).
+		Init()
+	d := &Disp{smgr: srv.Smgr, cmgr: srv.Cmgr, ms: srv.Ms,
+		msFull: srv.MsFull, ctx: srv.Ctx}
+
+	if _, err := d.EstablishSubscription("/cont", "rfc7951", "sometimes",
+		10); err == nil {
+		t.Fatal("Subscribed with an unsupported trigger")
+	}
+	if _, err := d.EstablishSubscription("/cont", "rfc7951",
+		PushTriggerOnChange, 1); err == nil {
+		t.Fatal("Subscribed with too short a period")
+	}
+	id, err := d.EstablishSubscription("/cont", "rfc7951",
+		PushTriggerOnChange, 10)
+	if err != nil {
+		t.Fatalf("Unable to subscribe: %s", err)
+	}
+	defer d.DeleteSubscription(id)
+
+	n := waitPushUpdate(t, d, id)
+	if n == nil || n.Type != pushUpdate ||
+		!strings.Contains(string(n.Contents), "foo") {
+		t.Fatalf("Unexpected initial update: %v", n)
+	}
+	if n := waitPushUpdate(t, d, id); n != nil {
+		t.Fatalf("Unexpected update without a change: %s", n.Contents)
+	}
+
+	sessiontest.ValidateSet(t, sess, srv.Ctx,
+		[]string{"cont", "value", "bar"}, false)
+	if _, errs, ok := sess.Commit(srv.Ctx, "", false); !ok {
+		t.Fatalf("Unable to commit: %v", errs)
+	}
+	n = waitPushUpdate(t, d, id)
+	if n == nil || n.Type != pushChangeUpdate ||
+		!strings.Contains(string(n.Contents), "bar") {
+		t.Fatalf("Unexpected change update: %v", n)
+	}
+
+	if _, err := d.DeleteSubscription(id); err != nil {
+		t.Fatalf("Unable to delete subscription: %s", err)
+	}
+	if _, err := d.WaitPushUpdate(id, 0); err == nil {
+		t.Fatal("Waited on a deleted subscription")
+	}
+}
+
+func TestYangPushPeriodic(t *testing.T) {
+	srv, _ := sessiontest.NewTestSpec(t).
+		SetSingleSchema(notifySchema).
+		SetConfig(notifyConfig).
+		Init()
+	d := &Disp{smgr: srv.Smgr, cmgr: srv.Cmgr, ms: srv.Ms,
+		msFull: srv.MsFull, ctx: srv.Ctx}
+
+	id, err := d.EstablishSubscription("/cont", "rfc7951",
+		PushTriggerPeriodic, 10)
+	if err != nil {
+		t.Fatalf("Unable to subscribe: %s", err)
+	}
+	defer d.DeleteSubscription(id)
+
+	for i := 0; i < 2; i++ {
+		n := waitPushUpdate(t, d, id)
+		if n == nil || n.Type != pushUpdate {
+			t.Fatalf("Missing periodic update %d: %v", i, n)
+		}
+	}
+
+	if _, err := d.ModifySubscription(id, 0); err == nil {
+		t.Fatal("Modified subscription with an invalid period")
+	}
+	if _, err := d.ModifySubscription(id, 6000); err != nil {
+		t.Fatalf("Unable to modify subscription: %s", err)
+	}
+	if n := waitPushUpdate(t, d, id); n != nil {
+		t.Fatal("Update sent before the modified period")
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/danos/configd"
+)
+
+// Session activity tracking
+//
+// Shells that want to show the state of their configuration session in the
+// prompt would otherwise have to call into configd on every prompt render.
+// Instead, a session created with an activity file keeps that file updated
+// with its current state ("edited" and/or "locked", space separated, or
+// empty). The file is only rewritten when the state changes so its mtime
+// can also be used to detect transitions cheaply.
+
+const (
+	activityEdited = "edited"
+	activityLocked = "locked"
+)
+
+type activity struct {
+	changed bool
+	locked  bool
+}
+
+func (a activity) String() string {
+	var state []string
+	if a.changed {
+		state = append(state, activityEdited)
+	}
+	if a.locked {
+		state = append(state, activityLocked)
+	}
+	return strings.Join(state, " ")
+}
+
+// activityFileForSession - activity file location for the given session, if
+// configd has been configured with a directory for them. SIDs that don't
+// name a file directly in that directory, such as those containing a path
+// separator, or "." and "..", are not something we want to use as a file
+// name, let alone remove.
+func activityFileForSession(ctx *configd.Context, sid string) string {
+	if ctx.Config == nil || ctx.Config.SessionActivityDir == "" {
+		return ""
+	}
+	if sid == "" {
+		return ""
+	}
+	dir := filepath.Clean(ctx.Config.SessionActivityDir)
+	file := filepath.Join(dir, sid)
+	if filepath.Dir(file) != dir || filepath.Base(file) != sid {
+		return ""
+	}
+	return file
+}
+
+func WithActivityFile(file string) SessionOption {
+	return func(s *session) {
+		s.activityFile = file
+	}
+}
+
+// activityCtx returns the context of requests that may alter the changed
+// or locked state of the session, and nil for all others, so we don't pay
+// the cost of working out if the session has changed on every read.
+func activityCtx(req request) *configd.Context {
+	switch v := req.(type) {
+	case *setreq:
+		return v.ctx
+	case *delreq:
+		return v.ctx
+	case *lockreq:
+		return v.ctx
+	case *unlockreq:
+		return v.ctx
+	case *forceunlockreq:
+		return v.ctx
+	case *discardreq:
+		return v.ctx
+	case *discardpathreq:
+		return v.ctx
+	case *commentreq:
+		return v.ctx
+	case *renamereq:
+		return v.ctx
+	case *copyreq:
+		return v.ctx
+	case *batchreq:
+		return v.ctx
+	case *loadreq:
+		return v.ctx
+	case *mergereq:
+		return v.ctx
+	case *commitreq:
+		return v.ctx
+	case *editconfigreq:
+		return v.ctx
+	case *copyconfigreq:
+		return v.ctx
+	case *restorereq:
+		return v.ctx
+	case *rebasereq:
+		return v.ctx
+	}
+	return nil
+}
+
+// updateState brings the activity and persisted files up to date after
+// req.  Besides requests that may change the session, any request that
+// released the lock of a process that has gone leaves them showing a lock
+// that is no longer there.
+func (s *session) updateState(req request) {
+	ctx := persistCtx(req)
+	expired := s.lockExpired
+	s.lockExpired = false
+	if ctx == nil && !expired {
+		return
+	}
+	if s.activityFile == "" && s.persistFile == "" {
+		return
+	}
+	if ctx != nil {
+		s.trackChanged(ctx, req)
+	}
+	s.updateActivity(ctx)
+	s.updatePersisted(ctx)
+}
+
+// trackChanged keeps track of whether the session has changes after req
+// without comparing it with the running configuration after every edit,
+// which costs a full merge of the candidate.  Once an edit has left the
+// session changed, later edits are taken to keep it so; only edits that
+// undo each other could make it unchanged again, and the session is shown
+// as edited until it is next discarded, committed, or otherwise compared.
+// Lock requests leave the configuration alone.
+func (s *session) trackChanged(ctx *configd.Context, req request) {
+	switch req.(type) {
+	case *lockreq, *unlockreq, *forceunlockreq, *marksavedreq:
+		return
+	case *setreq, *delreq, *commentreq, *renamereq, *copyreq,
+		*batchreq, *mergereq, *editconfigreq:
+		if s.edited {
+			return
+		}
+	}
+	s.edited = s.changed(ctx)
+}
+
+// updateActivity writes the activity file if the session's state has
+// changed.  ctx, which is only used to log failures, may be nil.
+func (s *session) updateActivity(ctx *configd.Context) {
+	if s.activityFile == "" {
+		return
+	}
+
+	// Only user locks are of interest; COMMIT and SYSTEM locks are
+	// transient or not something the user can do anything about.
+	cur := activity{changed: s.edited, locked: s.lpid > 0}
+	if cur == s.activity && s.activityWritten {
+		return
+	}
+	prev := s.activity
+	s.activity = cur
+	if err := s.writeActivity(); err != nil {
+		// Try again on the next request, rather than leave the file
+		// showing the wrong state until the state next changes.
+		s.activity = prev
+		if ctx != nil && ctx.Elog != nil {
+			ctx.Elog.Printf("Unable to write activity for session %s: %s",
+				s.sid, err)
+		}
+	}
+}
+
+// writeActivity writes the session's state to its activity file, noting
+// whether the file now reflects it.
+func (s *session) writeActivity() error {
+	if s.activityFile == "" {
+		return nil
+	}
+	err := ioutil.WriteFile(s.activityFile, []byte(s.activity.String()), 0644)
+	s.activityWritten = err == nil
+	return err
+}
+
+func (s *session) removeActivity() {
+	if s.activityFile == "" {
+		return
+	}
+	os.Remove(s.activityFile)
+}
@@ -0,0 +1,216 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/danos/configd/session"
+	. "github.com/danos/configd/session/sessiontest"
+)
+
+const activitySchema = `
+container testcontainer {
+	leaf testleaf {
+		type string;
+	}
+}
+`
+
+func checkActivity(t *testing.T, srv *TstSrv, sess *Session, file, exp string) {
+	t.Helper()
+
+	// Any request is processed after the activity file for the previous
+	// request has been updated, so this ensures we don't read it early.
+	sess.Locked(srv.Ctx)
+
+	act, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatalf("Unable to read activity file: %s", err)
+	}
+	if string(act) != exp {
+		t.Fatalf("Activity: expected '%s', got '%s'", exp, string(act))
+	}
+}
+
+func TestSessionActivityFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "configd-activity")
+	if err != nil {
+		t.Fatalf("Unable to create activity dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	srv, _ := TstStartup(t, activitySchema, emptyconfig)
+	srv.Ctx.Config.SessionActivityDir = dir
+	// Only real (positive) pids are shown as locked
+	srv.Ctx.Pid = 1234
+
+	sid := "activity"
+	sess, err := srv.Smgr.Create(
+		srv.Ctx, sid, srv.Cmgr, srv.Ms, srv.MsFull, Unshared)
+	if err != nil {
+		t.Fatalf("Unable to create session: %s", err)
+	}
+	defer srv.Smgr.Destroy(srv.Ctx, sid)
+	file := filepath.Join(dir, sid)
+
+	checkActivity(t, srv, sess, file, "")
+
+	if err := sess.Set(srv.Ctx,
+		[]string{"testcontainer", "testleaf", "foo"}); err != nil {
+		t.Fatalf("Unable to set path: %s", err)
+	}
+	checkActivity(t, srv, sess, file, "edited")
+
+	if _, err := sess.Lock(srv.Ctx); err != nil {
+		t.Fatalf("Unable to lock session: %s", err)
+	}
+	checkActivity(t, srv, sess, file, "edited locked")
+
+	if err := sess.Discard(srv.Ctx); err != nil {
+		t.Fatalf("Unable to discard session: %s", err)
+	}
+	checkActivity(t, srv, sess, file, "locked")
+
+	if _, err := sess.Unlock(srv.Ctx); err != nil {
+		t.Fatalf("Unable to unlock session: %s", err)
+	}
+	checkActivity(t, srv, sess, file, "")
+}
+
+func TestSessionActivityFileNotUsedForSharedSession(t *testing.T) {
+	dir, err := ioutil.TempDir("", "configd-activity")
+	if err != nil {
+		t.Fatalf("Unable to create activity dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	srv, _ := TstStartup(t, activitySchema, emptyconfig)
+	srv.Ctx.Config.SessionActivityDir = dir
+
+	sid := "shared"
+	sess, err := srv.Smgr.Create(
+		srv.Ctx, sid, srv.Cmgr, srv.Ms, srv.MsFull, Shared)
+	if err != nil {
+		t.Fatalf("Unable to create session: %s", err)
+	}
+	defer srv.Smgr.Destroy(srv.Ctx, sid)
+
+	sess.Locked(srv.Ctx)
+	if _, err := os.Stat(filepath.Join(dir, sid)); !os.IsNotExist(err) {
+		t.Fatalf("Unexpected activity file for shared session")
+	}
+}
+
+func TestSessionActivityFileNotUsedForDotSids(t *testing.T) {
+	parent, err := ioutil.TempDir("", "configd-activity")
+	if err != nil {
+		t.Fatalf("Unable to create activity dir: %s", err)
+	}
+	defer os.RemoveAll(parent)
+	dir := filepath.Join(parent, "sessions")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatalf("Unable to create activity dir: %s", err)
+	}
+
+	srv, _ := TstStartup(t, activitySchema, emptyconfig)
+	srv.Ctx.Config.SessionActivityDir = dir
+
+	for _, sid := range []string{".", ".."} {
+		sess, err := srv.Smgr.Create(
+			srv.Ctx, sid, srv.Cmgr, srv.Ms, srv.MsFull, Unshared)
+		if err != nil {
+			t.Fatalf("Unable to create session %s: %s", sid, err)
+		}
+		sess.Locked(srv.Ctx)
+		srv.Smgr.Destroy(srv.Ctx, sid)
+
+		if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+			t.Fatalf("Session %s replaced or removed activity dir", sid)
+		}
+	}
+}
+
+func TestSessionActivityFileLockReleased(t *testing.T) {
+	dir, err := ioutil.TempDir("", "configd-activity")
+	if err != nil {
+		t.Fatalf("Unable to create activity dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	srv, _ := TstStartup(t, activitySchema, emptyconfig)
+	srv.Ctx.Config.SessionActivityDir = dir
+	srv.Ctx.Pid = int32(os.Getpid())
+
+	sid := "released"
+	sess, err := srv.Smgr.Create(
+		srv.Ctx, sid, srv.Cmgr, srv.Ms, srv.MsFull, Unshared)
+	if err != nil {
+		t.Fatalf("Unable to create session: %s", err)
+	}
+	defer srv.Smgr.Destroy(srv.Ctx, sid)
+	file := filepath.Join(dir, sid)
+
+	if _, err := sess.Lock(srv.Ctx); err != nil {
+		t.Fatalf("Unable to lock session: %s", err)
+	}
+	checkActivity(t, srv, sess, file, "locked")
+
+	other := newLockTestCtx(srv, 1, "root")
+	if _, err := sess.ForceUnlock(other); err != nil {
+		t.Fatalf("Unable to force unlock: %s", err)
+	}
+	checkActivity(t, srv, sess, file, "")
+
+	// The read checkActivity makes releases the lock of a process that
+	// has gone, which must clear the lock from the file too.
+	dead := newLockTestCtx(srv, exitedPid(t), "alice")
+	if _, err := sess.Lock(dead); err != nil {
+		t.Fatalf("Unable to lock session: %s", err)
+	}
+	checkActivity(t, srv, sess, file, "")
+}
+
+func TestSessionActivityFileEditedUntilDiscarded(t *testing.T) {
+	dir, err := ioutil.TempDir("", "configd-activity")
+	if err != nil {
+		t.Fatalf("Unable to create activity dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	srv, _ := TstStartup(t, activitySchema, emptyconfig)
+	srv.Ctx.Config.SessionActivityDir = dir
+
+	sid := "undone"
+	sess, err := srv.Smgr.Create(
+		srv.Ctx, sid, srv.Cmgr, srv.Ms, srv.MsFull, Unshared)
+	if err != nil {
+		t.Fatalf("Unable to create session: %s", err)
+	}
+	defer srv.Smgr.Destroy(srv.Ctx, sid)
+	file := filepath.Join(dir, sid)
+
+	path := []string{"testcontainer", "testleaf", "foo"}
+	if err := sess.Set(srv.Ctx, path); err != nil {
+		t.Fatalf("Unable to set path: %s", err)
+	}
+	checkActivity(t, srv, sess, file, "edited")
+
+	// Edits aren't compared with the running configuration once the
+	// session is edited, so undoing them leaves it shown as edited ...
+	if err := sess.Delete(srv.Ctx, path); err != nil {
+		t.Fatalf("Unable to delete path: %s", err)
+	}
+	checkActivity(t, srv, sess, file, "edited")
+
+	// ... until something that compares them, such as a discard.
+	if err := sess.Discard(srv.Ctx); err != nil {
+		t.Fatalf("Unable to discard session: %s", err)
+	}
+	checkActivity(t, srv, sess, file, "")
+}
@@ -0,0 +1,148 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"sync"
+
+	"github.com/danos/configd/common"
+	"github.com/danos/utils/pathutil"
+)
+
+// Annotations
+//
+// External systems managing the configuration want to record things about
+// it that aren't part of the YANG data, eg which tool owns a subtree or the
+// change ticket it was configured under.  Annotations are key/value pairs
+// attached to a configuration path.  They are kept across commits until a
+// commit touches the annotated node (changes it, anything below it, or
+// removes it), at which point whatever they described may no longer hold,
+// so they are dropped.  Like the changes since boot they are kept in a file
+// alongside the runfile.
+
+type annotations struct {
+	mu    sync.Mutex
+	paths map[string]map[string]string
+}
+
+func newAnnotations() *annotations {
+	return &annotations{paths: make(map[string]map[string]string)}
+}
+
+func annotationsFileForRunfile(runfile string) string {
+	return runfile + ".annotations"
+}
+
+func (a *annotations) set(path []string, key, value string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	pstr := pathutil.Pathstr(path)
+	if a.paths[pstr] == nil {
+		a.paths[pstr] = make(map[string]string)
+	}
+	a.paths[pstr][key] = value
+}
+
+func (a *annotations) remove(path []string, key string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	pstr := pathutil.Pathstr(path)
+	if _, ok := a.paths[pstr][key]; !ok {
+		return false
+	}
+	delete(a.paths[pstr], key)
+	if len(a.paths[pstr]) == 0 {
+		delete(a.paths, pstr)
+	}
+	return true
+}
+
+// under returns a copy of the annotations at or below path.
+func (a *annotations) under(path []string) map[string]map[string]string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	out := make(map[string]map[string]string)
+	for pstr, kvs := range a.paths {
+		if !pathIsPrefix(path, common.InternPath(pstr)) {
+			continue
+		}
+		cp := make(map[string]string, len(kvs))
+		for k, v := range kvs {
+			cp[k] = v
+		}
+		out[pstr] = cp
+	}
+	return out
+}
+
+// prune drops annotations on nodes touched by the changed paths, returning
+// true if any were dropped.
+func (a *annotations) prune(changed [][]string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	pruned := false
+	for pstr := range a.paths {
+		path := common.InternPath(pstr)
+		for _, ch := range changed {
+			if pathIsPrefix(path, ch) || pathIsPrefix(ch, path) {
+				delete(a.paths, pstr)
+				pruned = true
+				break
+			}
+		}
+	}
+	return pruned
+}
+
+func (a *annotations) write(file string) error {
+	a.mu.Lock()
+	buf, err := json.Marshal(a.paths)
+	a.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, buf, 0600)
+}
+
+func (a *annotations) read(file string) error {
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	paths := make(map[string]map[string]string)
+	if err := json.Unmarshal(buf, &paths); err != nil {
+		return err
+	}
+	a.mu.Lock()
+	a.paths = paths
+	a.mu.Unlock()
+	return nil
+}
+
+// LoadAnnotations restores the annotations recorded before configd was
+// restarted.
+func (m *CommitMgr) LoadAnnotations(runfile string) error {
+	return m.annotations.read(annotationsFileForRunfile(runfile))
+}
+
+func (m *CommitMgr) SetAnnotation(runfile string, path []string, key, value string) error {
+	m.annotations.set(path, key, value)
+	return m.annotations.write(annotationsFileForRunfile(runfile))
+}
+
+// DeleteAnnotation returns false if there was no such annotation.
+func (m *CommitMgr) DeleteAnnotation(runfile string, path []string, key string) (bool, error) {
+	if !m.annotations.remove(path, key) {
+		return false, nil
+	}
+	return true, m.annotations.write(annotationsFileForRunfile(runfile))
+}
+
+// Annotations returns the annotations at or below path, keyed by path.
+func (m *CommitMgr) Annotations(path []string) map[string]map[string]string {
+	return m.annotations.under(path)
+}
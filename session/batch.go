@@ -0,0 +1,81 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session
+
+import (
+	"github.com/danos/configd"
+)
+
+// Batched set and delete
+//
+// Applying many paths one request at a time is slow, and leaves the
+// candidate half changed should one of them fail.  A batch is applied in a
+// single request against a copy of the candidate, which only replaces the
+// candidate if every operation succeeds.  Operations are applied in order,
+// and all of them are attempted, so each failure can be reported.
+
+const (
+	BatchSet    = "set"
+	BatchDelete = "delete"
+)
+
+type BatchOp struct {
+	Op   string
+	Path []string
+}
+
+// applyBatch returns an error for each operation, nil for those that
+// succeeded.
+func (s *session) applyBatch(ctx *configd.Context, ops []BatchOp) (error, []error) {
+	if err := s.trylock(ctx.Pid); err != nil {
+		return err, nil
+	}
+	orig := s.candidate
+	candidate, err := s.replayCandidate(ctx, nil)
+	if err != nil {
+		return err, nil
+	}
+	s.candidate = candidate
+
+	errs := make([]error, len(ops))
+	failed := false
+	for i, op := range ops {
+		switch op.Op {
+		case BatchDelete:
+			errs[i] = s.del(ctx, op.Path)
+		default:
+			errs[i] = s.set(ctx, op.Path)
+		}
+		if errs[i] != nil {
+			failed = true
+		}
+	}
+	if failed {
+		s.candidate = orig
+		return nil, errs
+	}
+	return nil, nil
+}
+
+// ApplyBatch applies the operations in order, changing the candidate only
+// if they all succeed.  Otherwise an error is returned for each operation,
+// nil for those that would have succeeded.
+func (s *Session) ApplyBatch(
+	ctx *configd.Context, ops []BatchOp,
+) (error, []error) {
+	respch := make(chan batchresp)
+	req := &batchreq{
+		ctx:  ctx,
+		ops:  ops,
+		resp: respch,
+	}
+	select {
+	case s.s.reqch <- req:
+		resp := <-respch
+		return resp.err, resp.opErrs
+	case <-s.s.term:
+	}
+	return sessTermError(), nil
+}
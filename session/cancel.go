@@ -0,0 +1,36 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session
+
+import (
+	"github.com/danos/mgmterror"
+)
+
+// Cancelled requests
+//
+// A client disconnecting part way through a GetFullTree, validate or
+// commit left configd to finish the work for nobody.  The server now
+// cancels the configd.Context's Request once the client has gone or asks
+// for the request to be cancelled.  Gathering state then runs no further
+// scripts, and validation, including a commit's, is abandoned, the commit
+// being aborted before anything is applied.  Scripts already running are
+// left to finish, their output discarded; once a commit has started
+// applying changes it runs to completion.
+
+func newCancelledError() error {
+	err := mgmterror.NewOperationFailedApplicationError()
+	err.Message = "Request cancelled"
+	return err
+}
+
+// cancelled reports whether done, from a configd.Context, is closed.
+func cancelled(done <-chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"sort"
+	"sync"
+
+	"github.com/danos/configd/common"
+	"github.com/danos/utils/pathutil"
+)
+
+// Changes since boot
+//
+// Every commit records the paths it changed, along with its commit id, so
+// operators can quickly see how the configuration has drifted since the
+// system came up.  The record is kept in a file alongside the runfile, which
+// lives in /run, so it survives configd restarts but not a reboot.
+
+type changesSinceBoot struct {
+	mu    sync.Mutex
+	paths map[string][]uint64
+}
+
+func newChangesSinceBoot() *changesSinceBoot {
+	return &changesSinceBoot{paths: make(map[string][]uint64)}
+}
+
+func changesFileForRunfile(runfile string) string {
+	return runfile + ".changes"
+}
+
+func (c *changesSinceBoot) add(commitId uint64, paths [][]string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, path := range paths {
+		key := pathutil.Pathstr(path)
+		ids := c.paths[key]
+		if len(ids) == 0 || ids[len(ids)-1] != commitId {
+			c.paths[key] = append(ids, commitId)
+		}
+	}
+}
+
+// condensed returns the changed paths with any path whose ancestor also
+// changed folded into that ancestor, along with its commit ids.
+func (c *changesSinceBoot) condensed() map[string][]uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	out := make(map[string][]uint64)
+	for key, ids := range c.paths {
+		top := key
+		path := common.InternPath(key)
+		for i := 1; i < len(path); i++ {
+			anc := pathutil.Pathstr(path[:i])
+			if _, ok := c.paths[anc]; ok {
+				top = anc
+				break
+			}
+		}
+		out[top] = mergeCommitIds(out[top], ids)
+	}
+	return out
+}
+
+func mergeCommitIds(a, b []uint64) []uint64 {
+	seen := make(map[uint64]struct{}, len(a)+len(b))
+	out := make([]uint64, 0, len(a)+len(b))
+	for _, id := range append(append([]uint64{}, a...), b...) {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		out = append(out, id)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+func (c *changesSinceBoot) write(file string) error {
+	c.mu.Lock()
+	buf, err := json.Marshal(c.paths)
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, buf, 0600)
+}
+
+func (c *changesSinceBoot) read(file string) error {
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	paths := make(map[string][]uint64)
+	if err := json.Unmarshal(buf, &paths); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.paths = paths
+	c.mu.Unlock()
+	return nil
+}
+
+// LoadChangesSinceBoot restores the changes recorded before configd was
+// restarted.
+func (m *CommitMgr) LoadChangesSinceBoot(runfile string) error {
+	return m.changes.read(changesFileForRunfile(runfile))
+}
+
+// ChangesSinceBoot returns the paths changed by commits since boot, each
+// with the ids of the commits that changed it or anything below it.
+func (m *CommitMgr) ChangesSinceBoot() map[string][]uint64 {
+	return m.changes.condensed()
+}
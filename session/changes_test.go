@@ -0,0 +1,78 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	. "github.com/danos/configd/session"
+	. "github.com/danos/configd/session/sessiontest"
+)
+
+const changesConfig = `
+testcontainer {
+	first foo
+}
+`
+
+func checkChangesSinceBoot(
+	t *testing.T,
+	srv *TstSrv,
+	exp map[string][]uint64,
+) {
+	t.Helper()
+	if act := srv.Cmgr.ChangesSinceBoot(); !reflect.DeepEqual(act, exp) {
+		t.Fatalf("Unexpected changes since boot:\nExp: %v\nGot: %v\n",
+			exp, act)
+	}
+}
+
+func TestChangesSinceBoot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "configd-changes")
+	if err != nil {
+		t.Fatalf("Unable to create runfile dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	srv, sess := TstStartup(t, rebaseSchema, changesConfig)
+	srv.Ctx.Config.Runfile = filepath.Join(dir, "running.config")
+
+	checkChangesSinceBoot(t, srv, map[string][]uint64{})
+
+	rebaseSet(t, srv, sess, "testcontainer", "first", "bar")
+	rebaseCommit(t, srv, sess)
+	rebaseSet(t, srv, sess, "testcontainer", "second", "baz")
+	rebaseCommit(t, srv, sess)
+
+	checkChangesSinceBoot(t, srv, map[string][]uint64{
+		"/testcontainer/first":  {1},
+		"/testcontainer/second": {2},
+	})
+
+	if err := sess.Delete(srv.Ctx, []string{"testcontainer"}); err != nil {
+		t.Fatalf("Unable to delete path: %s", err)
+	}
+	rebaseCommit(t, srv, sess)
+
+	// Changes below a changed path are folded into it
+	exp := map[string][]uint64{
+		"/testcontainer": {1, 2, 3},
+	}
+	checkChangesSinceBoot(t, srv, exp)
+
+	// ... and survive a restart
+	cmgr := NewCommitMgr(nil, srv.Ms)
+	if err := cmgr.LoadChangesSinceBoot(srv.Ctx.Config.Runfile); err != nil {
+		t.Fatalf("Unable to load changes since boot: %s", err)
+	}
+	if act := cmgr.ChangesSinceBoot(); !reflect.DeepEqual(act, exp) {
+		t.Fatalf("Unexpected changes after reload:\nExp: %v\nGot: %v\n",
+			exp, act)
+	}
+}
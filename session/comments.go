@@ -0,0 +1,338 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session
+
+import (
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/danos/config/data"
+	"github.com/danos/config/schema"
+	"github.com/danos/configd"
+	"github.com/danos/mgmterror"
+	"github.com/danos/utils/pathutil"
+	yang "github.com/danos/yang/schema"
+)
+
+// Comments
+//
+// Operators may attach a comment to any node of the configuration to say
+// why it is there.  Each session has its own comments alongside its
+// candidate, starting from those of the running configuration, and a
+// commit makes them the running comments, dropping any on nodes that no
+// longer exist.  A session that has only changed comments commits just
+// those.  Comments are shown on the line before their node, as in
+//
+//	/* Uplink to the core */
+//	dataplane dp0s1 {
+//
+// so they are kept in saved configuration files and the running config
+// file, and such a line is read back as a comment when a file is loaded.
+// A comment on a leaf is on the leaf itself, whatever its value, while one
+// on a leaf-list is on a single entry.
+
+const (
+	commentStart = "/*"
+	commentEnd   = "*/"
+)
+
+// Comments keyed by the path of their node
+type nodeComments map[string]string
+
+func (c nodeComments) copy() nodeComments {
+	out := make(nodeComments, len(c))
+	for k, v := range c {
+		out[k] = v
+	}
+	return out
+}
+
+func (c nodeComments) equal(o nodeComments) bool {
+	if len(c) != len(o) {
+		return false
+	}
+	for k, v := range c {
+		if ov, ok := o[k]; !ok || ov != v {
+			return false
+		}
+	}
+	return true
+}
+
+// present returns the comments on nodes in tree.
+func (c nodeComments) present(tree *data.Node) nodeComments {
+	out := make(nodeComments, len(c))
+	for k, v := range c {
+		if dataDescendant(tree, pathutil.Makepath(k)) != nil {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+type runningComments struct {
+	mu    sync.Mutex
+	paths nodeComments
+}
+
+func newRunningComments() *runningComments {
+	return &runningComments{paths: make(nodeComments)}
+}
+
+// get returns a copy of the running comments.
+func (r *runningComments) get() nodeComments {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.paths.copy()
+}
+
+func (r *runningComments) set(c nodeComments) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.paths = c
+}
+
+// commentPath returns the path a comment on the node at path is kept
+// under, which doesn't include a leaf's value.
+func commentPath(sn schema.Node, path []string) []string {
+	if len(path) < 2 {
+		return path
+	}
+	parent := path[:len(path)-1]
+	if _, ok := schema.Descendant(sn, parent).(schema.Leaf); ok {
+		return parent
+	}
+	return path
+}
+
+func checkComment(path []string, text string) error {
+	if !strings.Contains(text, commentEnd) &&
+		!strings.ContainsAny(text, "\r\n") {
+		return nil
+	}
+	err := mgmterror.NewInvalidValueApplicationError()
+	err.Path = pathutil.Pathstr(path)
+	err.Message = "A comment must be a single line, without " + commentEnd
+	return err
+}
+
+// configLine returns the path elements a line of configuration, in the
+// curly brace format show gives, adds below the block it is in, and
+// whether it opens a block of its own.  A line holding no node, such as a
+// comment or a closing brace, gives no elements.
+func configLine(line string) (elems []string, open bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || line == "}" || strings.HasPrefix(line, commentStart) ||
+		strings.HasPrefix(line, "//") {
+		return nil, false
+	}
+	if strings.HasSuffix(line, "{") {
+		open = true
+		line = strings.TrimSpace(strings.TrimSuffix(line, "{"))
+	}
+	i := strings.IndexAny(line, " \t")
+	if i < 0 {
+		return []string{line}, open
+	}
+	value := strings.TrimSpace(line[i:])
+	if unquoted, err := strconv.Unquote(value); err == nil {
+		value = unquoted
+	}
+	return []string{line[:i], value}, open
+}
+
+// walkConfig calls fn with each line of config, shown at path, and the
+// path of the node on it, if any.
+func walkConfig(
+	config string, path []string, fn func(line string, node []string),
+) {
+	var depths []int
+	for _, line := range strings.SplitAfter(config, "\n") {
+		if strings.TrimSpace(line) == "}" && len(depths) > 0 {
+			path = path[:depths[len(depths)-1]]
+			depths = depths[:len(depths)-1]
+		}
+		elems, open := configLine(line)
+		if len(elems) == 0 {
+			fn(line, nil)
+			continue
+		}
+		node := append(pathutil.Copypath(path), elems...)
+		fn(line, node)
+		if open {
+			depths = append(depths, len(path))
+			path = node
+		}
+	}
+}
+
+// addComments returns config, shown at path, with each node's comment on
+// the line before it.
+func addComments(
+	sn schema.Node, config string, path []string, comments nodeComments,
+) string {
+	if len(comments) == 0 {
+		return config
+	}
+	var out strings.Builder
+	shown := make(map[string]bool)
+	walkConfig(config, path, func(line string, node []string) {
+		if node != nil {
+			key := pathutil.Pathstr(commentPath(sn, node))
+			if text, ok := comments[key]; ok && !shown[key] {
+				shown[key] = true
+				indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+				out.WriteString(indent + commentStart + " " + text + " " +
+					commentEnd + "\n")
+			}
+		}
+		out.WriteString(line)
+	})
+	return out.String()
+}
+
+// parseComments returns the comments in config, being those on the line
+// before a node.
+func parseComments(sn schema.Node, config string) nodeComments {
+	comments := make(nodeComments)
+	var pending string
+	walkConfig(config, nil, func(line string, node []string) {
+		text := strings.TrimSpace(line)
+		switch {
+		case node != nil && pending != "":
+			comments[pathutil.Pathstr(commentPath(sn, node))] = pending
+			pending = ""
+		case strings.HasPrefix(text, commentStart) &&
+			strings.HasSuffix(text, commentEnd):
+			pending = strings.TrimSpace(strings.TrimSuffix(
+				strings.TrimPrefix(text, commentStart), commentEnd))
+		default:
+			pending = ""
+		}
+	})
+	return comments
+}
+
+// getComments returns the session's comments, which are the running
+// comments until the session changes them.
+func (s *session) getComments() nodeComments {
+	if s.comments != nil {
+		return s.comments
+	}
+	return s.cmgr.comments.get()
+}
+
+// comment sets the comment on the node at path, removing it if text is
+// empty.
+func (s *session) comment(
+	ctx *configd.Context, path []string, text string,
+) error {
+	if err := s.trylock(ctx.Pid); err != nil {
+		return err
+	}
+	if s.frozenFor(ctx, path, false) {
+		return newFrozenError(path)
+	}
+	if err := checkComment(path, text); err != nil {
+		return err
+	}
+	if !s.existsInTree(s.getUnion(), ctx, path, excludeDefault) {
+		return yang.NewNodeNotExistsError(path)
+	}
+	if !s.newAuther(ctx).AuthUpdate(path) {
+		return mgmterror.NewAccessDeniedApplicationError()
+	}
+
+	if s.comments == nil {
+		s.comments = s.cmgr.comments.get()
+	}
+	key := pathutil.Pathstr(commentPath(s.schema, path))
+	if text == "" {
+		delete(s.comments, key)
+	} else {
+		s.comments[key] = text
+	}
+	return nil
+}
+
+func (s *session) getComment(
+	ctx *configd.Context, path []string,
+) (string, error) {
+	if !s.newAuther(ctx).AuthRead(path) {
+		return "", mgmterror.NewAccessDeniedApplicationError()
+	}
+	return s.getComments()[pathutil.Pathstr(commentPath(s.schema, path))],
+		nil
+}
+
+// loadComments takes the comments of a loaded configuration, replacing
+// the session's own if the configuration replaced the candidate.
+func (s *session) loadComments(comments nodeComments, replace bool) {
+	if replace {
+		s.comments = comments
+		return
+	}
+	if len(comments) == 0 {
+		return
+	}
+	merged := s.getComments().copy()
+	for k, v := range comments {
+		merged[k] = v
+	}
+	s.comments = merged
+}
+
+// commentsChanged returns true if the session's comments, on nodes in its
+// configuration, aren't the running comments.
+func (s *session) commentsChanged() bool {
+	if s.comments == nil {
+		return false
+	}
+	mcan := s.getUnion().MergeWithoutDefaults()
+	return !s.comments.present(mcan).equal(
+		s.cmgr.comments.get().present(mcan))
+}
+
+// commitComments commits a session that has only changed comments.
+func (s *session) commitComments(ctx *configd.Context) *commitresp {
+	s.cmgr.commitComments(s.comments)
+	if err := s.cmgr.writeRunning(ctx); err != nil {
+		ctx.Elog.Printf("Unable to write running config: %s", err)
+	}
+	s.comments = nil
+	return &commitresp{ok: true}
+}
+
+// commitComments makes those of comments on nodes in running the running
+// comments.
+func (m *CommitMgr) commitComments(comments nodeComments) {
+	if comments == nil {
+		return
+	}
+	m.comments.set(comments.present(m.Running()))
+}
+
+// AddRunningComments returns config, the running configuration shown at
+// path, with the running comments added.
+func (m *CommitMgr) AddRunningComments(config string, path []string) string {
+	return addComments(m.schema, config, path, m.comments.get())
+}
+
+// LoadComments restores the running comments from the running config
+// file written before configd was restarted.
+func (m *CommitMgr) LoadComments(runfile string) error {
+	content, err := ioutil.ReadFile(runfile)
+	if err != nil {
+		return err
+	}
+	_, body, err := ParseRunfile(content)
+	if err != nil {
+		return err
+	}
+	m.comments.set(parseComments(m.schema, string(body)))
+	return nil
+}
@@ -0,0 +1,120 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session_test
+
+import (
+	"testing"
+
+	"github.com/danos/utils/pathutil"
+
+	. "github.com/danos/configd/session/sessiontest"
+)
+
+const commentSchema = `
+container interfaces {
+	list dataplane {
+		key tagnode;
+		leaf tagnode {
+			type string;
+		}
+		leaf description {
+			type string;
+		}
+	}
+}
+`
+
+const commentConfig = `interfaces {
+	dataplane dp0s1 {
+		description uplink
+	}
+}
+`
+
+func TestCommentShownAndCommitted(t *testing.T) {
+	const expconfig = `interfaces {
+	/* Uplink to the core */
+	dataplane dp0s1 {
+		/* Set by the installer */
+		description uplink
+	}
+}
+`
+	srv, sess := TstStartup(t, commentSchema, commentConfig)
+	defer sess.Kill()
+
+	comments := []struct {
+		path, text string
+	}{
+		{"interfaces/dataplane/dp0s1", "Uplink to the core"},
+		{"interfaces/dataplane/dp0s1/description/uplink",
+			"Set by the installer"},
+	}
+	for _, c := range comments {
+		err := sess.Comment(srv.Ctx, pathutil.Makepath(c.path), c.text)
+		if err != nil {
+			t.Fatalf("Unable to comment on %s: %s", c.path, err)
+		}
+	}
+	ValidateShow(t, sess, srv.Ctx, emptypath, false, expconfig, true)
+
+	if !sess.Changed(srv.Ctx) {
+		t.Fatal("Session with new comments is unchanged")
+	}
+	if _, errs, ok := sess.Commit(srv.Ctx, "", false); !ok {
+		t.Fatalf("Unable to commit comments: %v", errs)
+	}
+	if sess.Changed(srv.Ctx) {
+		t.Fatal("Session is changed after commit")
+	}
+	ValidateShow(t, sess, srv.Ctx, emptypath, false, expconfig, true)
+
+	text, err := sess.GetComment(srv.Ctx,
+		pathutil.Makepath("interfaces/dataplane/dp0s1/description"))
+	if err != nil || text != "Set by the installer" {
+		t.Fatalf("Unexpected comment %q, error %v", text, err)
+	}
+}
+
+func TestCommentRemoved(t *testing.T) {
+	srv, sess := TstStartup(t, commentSchema, commentConfig)
+	defer sess.Kill()
+
+	path := pathutil.Makepath("interfaces/dataplane/dp0s1")
+	if err := sess.Comment(srv.Ctx, path, "Uplink"); err != nil {
+		t.Fatalf("Unable to comment: %s", err)
+	}
+	if err := sess.Comment(srv.Ctx, path, ""); err != nil {
+		t.Fatalf("Unable to remove comment: %s", err)
+	}
+	if sess.Changed(srv.Ctx) {
+		t.Fatal("Session is changed after removing its only comment")
+	}
+	ValidateShow(t, sess, srv.Ctx, emptypath, false, commentConfig, true)
+}
+
+func TestCommentInvalid(t *testing.T) {
+	tests := []struct {
+		name, path, text string
+	}{
+		{"missing node", "interfaces/dataplane/dp0s2", "Spare"},
+		{"end of comment", "interfaces/dataplane/dp0s1", "Uplink */"},
+		{"multiple lines", "interfaces/dataplane/dp0s1", "Up\nlink"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv, sess := TstStartup(t, commentSchema, commentConfig)
+			defer sess.Kill()
+
+			err := sess.Comment(srv.Ctx, pathutil.Makepath(test.path),
+				test.text)
+			if err == nil {
+				t.Fatal("Unexpected comment success")
+			}
+			ValidateShow(t, sess, srv.Ctx, emptypath, false,
+				commentConfig, true)
+		})
+	}
+}
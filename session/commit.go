@@ -9,6 +9,7 @@ package session
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"strconv"
 	"time"
@@ -35,6 +36,7 @@ type commitctx struct {
 	sctx               *configd.Context
 	ctx                *configd.Context
 	message            string
+	trace              []string // What the commit ran, if traced
 }
 
 func newctx(
@@ -181,7 +183,9 @@ func (c *commitctx) validate() ([]*exec.Output, []error, bool) {
 func (c *commitctx) execute_hooks(hookdir string, env []string) (*exec.Output, error) {
 	out := new(bytes.Buffer)
 	err := new(bytes.Buffer)
-	cmd := spawn.Command("/bin/run-parts", "--regex=^[a-zA-Z0-9._-]+$", "--", hookdir)
+	ctx, cancel := scriptContext()
+	defer cancel()
+	cmd := spawn.CommandContext(ctx, "/bin/run-parts", "--regex=^[a-zA-Z0-9._-]+$", "--", hookdir)
 	cmd.Stdout = out
 	cmd.Stderr = err
 	if env != nil {
@@ -190,6 +194,10 @@ func (c *commitctx) execute_hooks(hookdir string, env []string) (*exec.Output, e
 
 	c.sctx.Dlog.Printf("Executing %s hooks\n", hookdir)
 	if cmd.Run() != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return &exec.Output{Output: out.String()},
+				newScriptTimeoutError(hookdir+" hooks", nil)
+		}
 		cerr := mgmterror.NewOperationFailedApplicationError()
 		cerr.Message = err.String()
 		return &exec.Output{Output: out.String()}, cerr
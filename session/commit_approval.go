@@ -0,0 +1,64 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session
+
+import (
+	"sync"
+
+	"github.com/danos/config/diff"
+	"github.com/danos/configd"
+)
+
+// Commit approval
+//
+// Change-approval workflows need to vet each commit before it is applied.
+// If an approver is set, it is asked about every commit that changes the
+// configuration once it has been validated, before any hooks or scripts
+// are run.  The approver is given who is committing, their comment, the
+// paths changed and the diff, with secrets hidden, along with any approval
+// token the committer supplied.  The commit only proceeds if the approver
+// returns no error; its error is returned as the reason for failing the
+// commit.
+
+type CommitApprovalRequest struct {
+	Session string
+	User    string
+	Message string
+	Token   string
+	Changes []CommitChange
+	Diff    string
+}
+
+type commitApprover struct {
+	mu sync.Mutex
+	fn func(*CommitApprovalRequest) error
+}
+
+// SetCommitApprover sets the function asked to approve each commit; nil
+// approves every commit.
+func (m *CommitMgr) SetCommitApprover(fn func(*CommitApprovalRequest) error) {
+	m.approver.mu.Lock()
+	defer m.approver.mu.Unlock()
+	m.approver.fn = fn
+}
+
+func (m *CommitMgr) approveCommit(
+	sid string, ctx *configd.Context, message string, dn *diff.Node,
+) error {
+	m.approver.mu.Lock()
+	fn := m.approver.fn
+	m.approver.mu.Unlock()
+	if fn == nil || dn == nil {
+		return nil
+	}
+	return fn(&CommitApprovalRequest{
+		Session: sid,
+		User:    statsUser(ctx),
+		Message: message,
+		Token:   ctx.ApprovalToken,
+		Changes: commitChanges(dn, diffChangedPaths(dn, nil, nil)),
+		Diff:    dn.Serialize(false, diff.HideSecrets(true)),
+	})
+}
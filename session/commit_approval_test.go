@@ -0,0 +1,71 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	. "github.com/danos/configd/session"
+	. "github.com/danos/configd/session/sessiontest"
+)
+
+const commitApprovalSchema = `
+container testcontainer {
+	leaf testleaf {
+		type string;
+	}
+	leaf other {
+		type string;
+	}
+}
+`
+
+const commitApprovalConfig = `
+testcontainer {
+	other foo
+}
+`
+
+func TestCommitApproval(t *testing.T) {
+	srv, sess := TstStartup(t, commitApprovalSchema, commitApprovalConfig)
+	defer sess.Kill()
+
+	var got *CommitApprovalRequest
+	srv.Cmgr.SetCommitApprover(func(req *CommitApprovalRequest) error {
+		got = req
+		if req.Token != "approved" {
+			return errors.New("Needs approval")
+		}
+		return nil
+	})
+	defer srv.Cmgr.SetCommitApprover(nil)
+
+	ValidateSet(t, sess, srv.Ctx,
+		[]string{"testcontainer", "testleaf", "foo"}, false)
+	if _, _, ok := sess.Commit(srv.Ctx, "first try", false); ok {
+		t.Fatal("Commit succeeded without approval")
+	}
+	if got == nil {
+		t.Fatal("Approver not asked to approve the commit")
+	}
+	if got.Message != "first try" || got.Diff == "" {
+		t.Fatalf("Unexpected approval request: %+v", got)
+	}
+	exp := []CommitChange{{
+		Path:      []string{"testcontainer", "testleaf"},
+		Operation: ChangeCreate,
+	}}
+	if !reflect.DeepEqual(got.Changes, exp) {
+		t.Fatalf("Unexpected changes:\nExp: %v\nGot: %v", exp, got.Changes)
+	}
+
+	ctx := *srv.Ctx
+	ctx.ApprovalToken = "approved"
+	if _, errs, ok := sess.Commit(&ctx, "second try", false); !ok {
+		t.Fatalf("Unable to commit with approval: %v", errs)
+	}
+}
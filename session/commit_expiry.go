@@ -0,0 +1,215 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/danos/config/data"
+	"github.com/danos/mgmterror"
+	"github.com/danos/utils/pathutil"
+)
+
+// Commit expiry
+//
+// Temporary changes, eg enabling a packet capture filter while debugging,
+// are easily forgotten.  A commit may instead be given an expiry, after
+// which exactly the changes it made are backed out, leaving any other
+// changes made since alone, unless the expiry is cancelled first, making
+// the changes permanent.  Unlike a confirmed commit, which rolls back to a
+// whole revision, any number of expiries may be pending at once.
+//
+// The commit manager tracks pending expiries in a job file, so they survive
+// a configd restart, along with the leaf paths the commit added and those
+// it removed.  Backing the changes out needs them to be committed, so is
+// done by the function given to SetCommitExpiryRevert.
+
+type CommitExpiry struct {
+	Id       string    `json:"id"`
+	User     string    `json:"user"`
+	Comment  string    `json:"comment,omitempty"`
+	Deadline time.Time `json:"deadline"`
+	Added    []string  `json:"added,omitempty"`
+	Removed  []string  `json:"removed,omitempty"`
+}
+
+type commitExpiry struct {
+	mu     sync.Mutex
+	timers map[string]*time.Timer
+	revert func(CommitExpiry)
+}
+
+func newNoCommitExpiryError(id string) error {
+	err := mgmterror.NewOperationFailedApplicationError()
+	err.Message = "No pending commit expiry " + id
+	return err
+}
+
+func readCommitExpiries(file string) map[string]CommitExpiry {
+	exps := make(map[string]CommitExpiry)
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		// Likely no pending expiries
+		return exps
+	}
+	json.Unmarshal(buf, &exps)
+	return exps
+}
+
+func writeCommitExpiries(file string, exps map[string]CommitExpiry) error {
+	if len(exps) == 0 {
+		os.Remove(file)
+		return nil
+	}
+	buf, err := json.Marshal(exps)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, buf, 0600)
+}
+
+// CommitLeafChanges returns the leaf paths present in after but not
+// before, and those present in before but not after.
+func CommitLeafChanges(before, after *data.Node) (added, removed []string) {
+	inBefore := make(map[string]bool)
+	for _, p := range dataLeafPaths(before, []string{}, nil) {
+		if len(p) != 0 {
+			inBefore[pathutil.Pathstr(p)] = true
+		}
+	}
+	for _, p := range dataLeafPaths(after, []string{}, nil) {
+		if len(p) == 0 {
+			continue
+		}
+		ps := pathutil.Pathstr(p)
+		if inBefore[ps] {
+			delete(inBefore, ps)
+			continue
+		}
+		added = append(added, ps)
+	}
+	for ps := range inBefore {
+		removed = append(removed, ps)
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
+
+// armCommitExpiry (re)starts the timer for the expiry.
+func (m *CommitMgr) armCommitExpiry(file string, exp CommitExpiry) {
+	if m.expiry.timers == nil {
+		m.expiry.timers = make(map[string]*time.Timer)
+	}
+	if t, ok := m.expiry.timers[exp.Id]; ok {
+		t.Stop()
+	}
+	id := exp.Id
+	m.expiry.timers[id] = time.AfterFunc(time.Until(exp.Deadline), func() {
+		m.expireCommit(file, id)
+	})
+}
+
+func (m *CommitMgr) expireCommit(file, id string) {
+	m.expiry.mu.Lock()
+	exps := readCommitExpiries(file)
+	exp, ok := exps[id]
+	// The expiry may have been cancelled while the timer fired.
+	if !ok || time.Now().Before(exp.Deadline) {
+		m.expiry.mu.Unlock()
+		return
+	}
+	delete(exps, id)
+	writeCommitExpiries(file, exps)
+	delete(m.expiry.timers, id)
+	revert := m.expiry.revert
+	m.expiry.mu.Unlock()
+
+	if revert != nil {
+		revert(exp)
+	}
+}
+
+// SetCommitExpiryRevert sets the function called to back out the changes
+// of a commit which has expired.
+func (m *CommitMgr) SetCommitExpiryRevert(fn func(CommitExpiry)) {
+	m.expiry.mu.Lock()
+	defer m.expiry.mu.Unlock()
+	m.expiry.revert = fn
+}
+
+// ResumeCommitExpiries restarts the timers for expiries left pending when
+// configd was restarted.  Those which have already expired are backed out
+// straight away.
+func (m *CommitMgr) ResumeCommitExpiries(file string) {
+	m.expiry.mu.Lock()
+	defer m.expiry.mu.Unlock()
+	for _, exp := range readCommitExpiries(file) {
+		m.armCommitExpiry(file, exp)
+	}
+}
+
+// CommitExpiries returns the pending expiries, soonest first.
+func (m *CommitMgr) CommitExpiries(file string) []CommitExpiry {
+	m.expiry.mu.Lock()
+	defer m.expiry.mu.Unlock()
+	var out []CommitExpiry
+	for _, exp := range readCommitExpiries(file) {
+		out = append(out, exp)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Deadline.Before(out[j].Deadline)
+	})
+	return out
+}
+
+// StartCommitExpiry records the expiry, to be backed out after timeout
+// unless cancelled, returning it with its id.
+func (m *CommitMgr) StartCommitExpiry(
+	file string,
+	exp CommitExpiry,
+	timeout time.Duration,
+) (CommitExpiry, error) {
+	m.expiry.mu.Lock()
+	defer m.expiry.mu.Unlock()
+	exps := readCommitExpiries(file)
+	exp.Id = strconv.FormatUint(m.CommitId(), 10)
+	exp.Deadline = time.Now().Add(timeout)
+	exps[exp.Id] = exp
+	if err := writeCommitExpiries(file, exps); err != nil {
+		return exp, err
+	}
+	m.armCommitExpiry(file, exp)
+	return exp, nil
+}
+
+// CancelCommitExpiry cancels the pending expiry, so the changes are kept,
+// returning it.
+func (m *CommitMgr) CancelCommitExpiry(
+	file, id string,
+) (CommitExpiry, error) {
+	m.expiry.mu.Lock()
+	defer m.expiry.mu.Unlock()
+	exps := readCommitExpiries(file)
+	exp, ok := exps[id]
+	if !ok {
+		return exp, newNoCommitExpiryError(id)
+	}
+	delete(exps, id)
+	if err := writeCommitExpiries(file, exps); err != nil {
+		return exp, err
+	}
+	if t, ok := m.expiry.timers[id]; ok {
+		t.Stop()
+		delete(m.expiry.timers, id)
+	}
+	return exp, nil
+}
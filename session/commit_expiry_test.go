@@ -0,0 +1,116 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	. "github.com/danos/configd/session"
+	. "github.com/danos/configd/session/sessiontest"
+)
+
+const expirySchema = `
+container testcontainer {
+	leaf testleaf {
+		type string;
+	}
+	leaf otherleaf {
+		type string;
+	}
+}
+`
+
+const expiryConfig = `
+testcontainer {
+	testleaf foo
+}
+`
+
+func TestCommitLeafChanges(t *testing.T) {
+	srv, sess := TstStartup(t, expirySchema, expiryConfig)
+	before := srv.Cmgr.Running()
+
+	for _, path := range [][]string{
+		{"testcontainer", "testleaf", "bar"},
+		{"testcontainer", "otherleaf", "baz"},
+	} {
+		if err := sess.Set(srv.Ctx, path); err != nil {
+			t.Fatalf("Unable to set %v: %s", path, err)
+		}
+	}
+	if _, errs, ok := sess.Commit(srv.Ctx, "", false); !ok {
+		t.Fatalf("Unable to commit: %v", errs)
+	}
+
+	added, removed := CommitLeafChanges(before, srv.Cmgr.Running())
+	expAdded := []string{
+		"/testcontainer/otherleaf/baz",
+		"/testcontainer/testleaf/bar",
+	}
+	expRemoved := []string{"/testcontainer/testleaf/foo"}
+	if !reflect.DeepEqual(added, expAdded) {
+		t.Fatalf("Added:\nExp: %v\nGot: %v", expAdded, added)
+	}
+	if !reflect.DeepEqual(removed, expRemoved) {
+		t.Fatalf("Removed:\nExp: %v\nGot: %v", expRemoved, removed)
+	}
+}
+
+func TestCommitExpiry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "configd-expiry")
+	if err != nil {
+		t.Fatalf("Unable to create job dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "commit_expiry.job")
+
+	srv, _ := TstStartup(t, expirySchema, emptyconfig)
+	expired := make(chan CommitExpiry, 1)
+	srv.Cmgr.SetCommitExpiryRevert(func(exp CommitExpiry) {
+		expired <- exp
+	})
+
+	exp, err := srv.Cmgr.StartCommitExpiry(file, CommitExpiry{
+		User:  "test",
+		Added: []string{"/testcontainer/testleaf/foo"},
+	}, time.Hour)
+	if err != nil {
+		t.Fatalf("Unable to start expiry: %s", err)
+	}
+	if exps := srv.Cmgr.CommitExpiries(file); len(exps) != 1 ||
+		exps[0].Id != exp.Id {
+		t.Fatalf("Unexpected pending expiries: %v", exps)
+	}
+	if _, err := srv.Cmgr.CancelCommitExpiry(file, exp.Id); err != nil {
+		t.Fatalf("Unable to cancel expiry: %s", err)
+	}
+	if _, err := srv.Cmgr.CancelCommitExpiry(file, exp.Id); err == nil {
+		t.Fatalf("Cancelled expiry cancelled again")
+	}
+
+	exp, err = srv.Cmgr.StartCommitExpiry(file, CommitExpiry{
+		User:  "test",
+		Added: []string{"/testcontainer/testleaf/foo"},
+	}, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Unable to start expiry: %s", err)
+	}
+	select {
+	case got := <-expired:
+		if !reflect.DeepEqual(got.Added, exp.Added) {
+			t.Fatalf("Unexpected expiry reverted: %v", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Commit didn't expire")
+	}
+	if exps := srv.Cmgr.CommitExpiries(file); len(exps) != 0 {
+		t.Fatalf("Expiry still pending once expired: %v", exps)
+	}
+}
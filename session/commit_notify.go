@@ -0,0 +1,81 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session
+
+import (
+	"sync"
+	"time"
+
+	"github.com/danos/config/data"
+	"github.com/danos/config/diff"
+)
+
+// Commit notifications
+//
+// Monitoring agents want to know when RUNNING changes without polling it.
+// Listeners registered with the commit manager are told of every commit
+// that changes RUNNING, once the new configuration has been stored, with
+// the paths changed and the trees before and after.  The trees are never
+// modified once stored, so listeners may hold on to them.  Listeners are
+// called from the commit, so must not block.
+
+const (
+	ChangeCreate = "create"
+	ChangeDelete = "delete"
+	ChangeUpdate = "update"
+)
+
+type CommitChange struct {
+	Path      []string
+	Operation string
+}
+
+type CommitNotification struct {
+	Id      uint64
+	User    string
+	Time    time.Time
+	Message string
+	Changes []CommitChange
+	Old     *data.Node
+	New     *data.Node
+}
+
+type commitListeners struct {
+	mu        sync.Mutex
+	listeners []func(*CommitNotification)
+}
+
+// AddCommitListener registers fn to be called for every commit that
+// changes RUNNING.
+func (m *CommitMgr) AddCommitListener(fn func(*CommitNotification)) {
+	m.listeners.mu.Lock()
+	defer m.listeners.mu.Unlock()
+	m.listeners.listeners = append(m.listeners.listeners, fn)
+}
+
+func (l *commitListeners) notify(n *CommitNotification) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, fn := range l.listeners {
+		fn(n)
+	}
+}
+
+func commitChanges(dn *diff.Node, changed [][]string) []CommitChange {
+	changes := make([]CommitChange, 0, len(changed))
+	for _, path := range changed {
+		op := ChangeUpdate
+		if ch := dn.Descendant(path); ch != nil {
+			switch {
+			case ch.Added():
+				op = ChangeCreate
+			case ch.Deleted():
+				op = ChangeDelete
+			}
+		}
+		changes = append(changes, CommitChange{Path: path, Operation: op})
+	}
+	return changes
+}
@@ -0,0 +1,156 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"sync"
+
+	"github.com/danos/config/diff"
+	"github.com/danos/config/schema"
+	"github.com/danos/utils/exec"
+)
+
+// Commit statistics
+//
+// To help plan for growth in the size of the configuration, every commit
+// records how much it changed: the number of paths added, deleted and
+// modified, the size of the change as shown by compare, and the number of
+// scripts run to apply it.  As for the changes since boot, the statistics
+// are kept by commit id in a file alongside the runfile.
+
+type CommitStats struct {
+	Added      uint64 `json:"added"`
+	Deleted    uint64 `json:"deleted"`
+	Modified   uint64 `json:"modified"`
+	DeltaBytes uint64 `json:"delta-bytes"`
+	Scripts    uint64 `json:"scripts"`
+}
+
+func (st *CommitStats) add(o CommitStats) {
+	st.Added += o.Added
+	st.Deleted += o.Deleted
+	st.Modified += o.Modified
+	st.DeltaBytes += o.DeltaBytes
+	st.Scripts += o.Scripts
+}
+
+type commitStats struct {
+	mu      sync.Mutex
+	commits map[uint64]CommitStats
+}
+
+func newCommitStats() *commitStats {
+	return &commitStats{commits: make(map[uint64]CommitStats)}
+}
+
+func commitStatsFileForRunfile(runfile string) string {
+	return runfile + ".commitstats"
+}
+
+// countDiff counts the paths added, deleted and modified below dn.  A leaf
+// whose value was replaced counts as modified, rather than as a deletion
+// and an addition of its value.
+func countDiff(dn *diff.Node, st *CommitStats) {
+	for _, ch := range dn.Children() {
+		_, isLeaf := ch.Schema().(schema.Leaf)
+		switch {
+		case ch.Added():
+			st.Added++
+		case ch.Deleted():
+			st.Deleted++
+		case isLeaf && ch.Changed():
+			st.Modified++
+		case ch.Changed():
+			countDiff(ch, st)
+		}
+	}
+}
+
+// newCommitStatsForDiff returns the statistics for a commit making the
+// changes in dn and running the scripts that produced outs.
+func newCommitStatsForDiff(dn *diff.Node, outs []*exec.Output) CommitStats {
+	var st CommitStats
+	if dn != nil {
+		countDiff(dn, &st)
+		st.DeltaBytes = uint64(len(dn.Serialize(false, diff.HideSecrets(true))))
+	}
+	for _, out := range outs {
+		if out != nil {
+			st.Scripts++
+		}
+	}
+	return st
+}
+
+func (c *commitStats) add(commitId uint64, st CommitStats) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.commits[commitId] = st
+}
+
+func (c *commitStats) snapshot() map[uint64]CommitStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make(map[uint64]CommitStats, len(c.commits))
+	for id, st := range c.commits {
+		out[id] = st
+	}
+	return out
+}
+
+func (c *commitStats) total() CommitStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var total CommitStats
+	for _, st := range c.commits {
+		total.add(st)
+	}
+	return total
+}
+
+func (c *commitStats) write(file string) error {
+	c.mu.Lock()
+	buf, err := json.Marshal(c.commits)
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, buf, 0600)
+}
+
+func (c *commitStats) read(file string) error {
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	commits := make(map[uint64]CommitStats)
+	if err := json.Unmarshal(buf, &commits); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.commits = commits
+	c.mu.Unlock()
+	return nil
+}
+
+// LoadCommitStats restores the statistics recorded before configd was
+// restarted.
+func (m *CommitMgr) LoadCommitStats(runfile string) error {
+	return m.commitStats.read(commitStatsFileForRunfile(runfile))
+}
+
+// CommitStats returns the statistics of each commit since boot, by commit
+// id.
+func (m *CommitMgr) CommitStats() map[uint64]CommitStats {
+	return m.commitStats.snapshot()
+}
+
+// CommitStatsTotal returns the statistics of all commits since boot added
+// together.
+func (m *CommitMgr) CommitStatsTotal() CommitStats {
+	return m.commitStats.total()
+}
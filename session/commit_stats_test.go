@@ -0,0 +1,84 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/danos/configd/session"
+	. "github.com/danos/configd/session/sessiontest"
+)
+
+const commitStatsSchema = `
+container testcontainer {
+	leaf one {
+		type string;
+	}
+	leaf two {
+		type string;
+	}
+	leaf three {
+		type string;
+	}
+}
+`
+
+func checkCommitStats(t *testing.T, act, exp CommitStats) {
+	if act.Added != exp.Added || act.Deleted != exp.Deleted ||
+		act.Modified != exp.Modified {
+		t.Fatalf("Unexpected commit stats:\nExp: %+v\nGot: %+v\n", exp, act)
+	}
+	if act.DeltaBytes == 0 {
+		t.Fatalf("Commit stats have no delta bytes: %+v", act)
+	}
+}
+
+func TestCommitStats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "configd-commitstats")
+	if err != nil {
+		t.Fatalf("Unable to create runfile dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	srv, sess := TstStartup(t, commitStatsSchema, emptyconfig)
+	defer sess.Kill()
+	srv.Ctx.Config.Runfile = filepath.Join(dir, "running.config")
+
+	// Adding the container counts as a single addition.
+	rebaseSet(t, srv, sess, "testcontainer", "one", "foo")
+	rebaseSet(t, srv, sess, "testcontainer", "two", "foo")
+	rebaseCommit(t, srv, sess)
+
+	rebaseSet(t, srv, sess, "testcontainer", "one", "bar")
+	if err := sess.Delete(srv.Ctx,
+		[]string{"testcontainer", "two"}); err != nil {
+		t.Fatalf("Unable to delete: %s", err)
+	}
+	rebaseSet(t, srv, sess, "testcontainer", "three", "foo")
+	rebaseCommit(t, srv, sess)
+
+	stats := srv.Cmgr.CommitStats()
+	if len(stats) != 2 {
+		t.Fatalf("Expected stats for 2 commits, got %v", stats)
+	}
+	checkCommitStats(t, stats[1], CommitStats{Added: 1})
+	checkCommitStats(t, stats[2],
+		CommitStats{Added: 1, Deleted: 1, Modified: 1})
+	checkCommitStats(t, srv.Cmgr.CommitStatsTotal(),
+		CommitStats{Added: 2, Deleted: 1, Modified: 1})
+
+	// ... and survive a restart
+	cmgr := NewCommitMgr(nil, srv.Ms)
+	if err := cmgr.LoadCommitStats(srv.Ctx.Config.Runfile); err != nil {
+		t.Fatalf("Unable to load commit stats: %s", err)
+	}
+	if act := cmgr.CommitStats(); act[2] != stats[2] {
+		t.Fatalf("Unexpected commit stats after reload:\nExp: %+v\nGot: %+v\n",
+			stats[2], act[2])
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session
+
+import (
+	"github.com/danos/configd"
+	"github.com/danos/utils/exec"
+	"github.com/danos/utils/pathutil"
+)
+
+// Commit trace
+//
+// The order in which a commit runs things matters to scripts depending on
+// one another, but was never checked as nothing recorded it.  When
+// configd is run with -trace-commit, as sessiontest always does, each
+// commit records what it invoked, in order, in its result, and logs it.
+// A commit always runs, in this order:
+//
+//   - the pre-commit hooks
+//   - the components with changed configuration, in the order the
+//     component manager sets their running configuration
+//   - the configuration scripts, in the order the changed nodes' priorities
+//     give, each reported with the path it was run for
+//   - the post-commit hooks
+//
+// so tests may rely on the relative order of the entries, each of which is
+// the kind of thing run followed by what it was.  The values of secrets in
+// script paths are hidden.
+
+const (
+	TraceHooks     = "hooks"
+	TraceComponent = "component"
+	TraceScript    = "script"
+)
+
+func traceEnabled(ctx *configd.Context) bool {
+	return ctx.Config != nil && ctx.Config.TraceCommit
+}
+
+// traceCommit records, and logs, the entry if the commit is being traced.
+func (c *commitctx) traceCommit(kind, what string) {
+	if !traceEnabled(c.sctx) {
+		return
+	}
+	entry := kind + " " + what
+	c.trace = append(c.trace, entry)
+	c.sctx.Dlog.Printf("Commit trace: %s\n", entry)
+}
+
+// traceScripts records the configuration scripts which produced outs.
+func (c *commitctx) traceScripts(outs []*exec.Output) {
+	for _, out := range outs {
+		if out != nil {
+			c.traceCommit(TraceScript,
+				pathutil.Pathstr(RedactPath(c.schema, out.Path)))
+		}
+	}
+}
@@ -8,9 +8,10 @@
 package session
 
 import (
-	"os"
 	"os/user"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/danos/config/data"
@@ -24,33 +25,57 @@ import (
 )
 
 type commitmgrreq struct {
-	sid     string
-	ctx     *configd.Context
-	t       *data.Node
-	message string
-	debug   bool
-	resp    chan *commitresp
+	sid      string
+	ctx      *configd.Context
+	t        *data.Node
+	comments nodeComments
+	message  string
+	debug    bool
+	resp     chan *commitresp
 }
 
 type commitresp struct {
-	out []*exec.Output
-	err []error
-	ok  bool
+	out   []*exec.Output
+	err   []error
+	ok    bool
+	trace []string
 }
 
 type CommitMgr struct {
-	running   *data.AtomicNode
-	effective *Session
-	schema    schema.ModelSet
-	reqch     chan commitmgrreq
-	hadcommit bool
+	running     *data.AtomicNode
+	effective   *Session
+	schema      schema.ModelSet
+	schemaMu    sync.RWMutex // Held for reading while committing
+	reqch       chan commitmgrreq
+	hadcommit   bool
+	schemaHash  string
+	commitId    uint64
+	changes     *changesSinceBoot
+	annotations *annotations
+	comments    *runningComments
+	frozen      *frozenPaths
+	groups      *configGroups
+	userStats   *userStats
+	commitStats *commitStats
+	listeners   commitListeners
+	confirmed   confirmedCommit
+	expiry      commitExpiry
+	approver    commitApprover
+	validated   validationCache
 }
 
 func NewCommitMgr(running *data.AtomicNode, schema schema.ModelSet) *CommitMgr {
 	c := &CommitMgr{
-		running: running,
-		schema:  schema,
-		reqch:   make(chan commitmgrreq),
+		running:     running,
+		schema:      schema,
+		reqch:       make(chan commitmgrreq),
+		changes:     newChangesSinceBoot(),
+		annotations: newAnnotations(),
+		comments:    newRunningComments(),
+		frozen:      newFrozenPaths(),
+		groups:      newConfigGroups(),
+		userStats:   newUserStats(),
+		commitStats: newCommitStats(),
 	}
 	go c.run()
 	return c
@@ -60,29 +85,52 @@ func (m *CommitMgr) SetEffective(effective *Session) {
 	m.effective = effective
 }
 
+// SetRunfileInfo sets the schema hash recorded in the runfile, and the id of
+// the commit that produced the running configuration loaded at startup.
+func (m *CommitMgr) SetRunfileInfo(schemaHash string, commitId uint64) {
+	m.schemaHash = schemaHash
+	atomic.StoreUint64(&m.commitId, commitId)
+}
+
+// CommitId returns the id of the last successful commit.
+func (m *CommitMgr) CommitId() uint64 {
+	return atomic.LoadUint64(&m.commitId)
+}
+
 func (m *CommitMgr) writeRunning(ctx *configd.Context) error {
-	f, err := os.Create(ctx.Config.Runfile)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-	// The running file contains the running configuration with secrets, and
-	// should definitely NOT be world readable.
-	err = f.Chmod(0600)
-	if err != nil {
-		return err
+	var out string
+	var err error
+	if currentSecretCrypter() != nil {
+		var enc *data.Node
+		if enc, err = EncryptSecrets(m.schema, m.Running()); err != nil {
+			return err
+		}
+		out, err = union.NewNode(nil, enc, m.schema, nil, 0).Show(
+			nil, union.ForceShowSecrets)
+		if err == nil {
+			out = m.AddRunningComments(out, nil)
+		}
+	} else {
+		//Effective and running are equivalent here use that
+		//fact to avoid creating another union tree.
+		out, err = m.effective.Show(ctx, []string{}, false, false)
 	}
-	//Effective and running are equivalent here use that
-	//fact to avoid creating another union tree.
-	out, err := m.effective.Show(ctx, []string{}, false, false)
 	if err != nil {
 		return err
 	}
-	_, err = f.WriteString(out)
-	return err
+	return writeRunfile(ctx.Config.Runfile,
+		NewRunfile(m.schemaHash, m.CommitId(), []byte(out)))
 }
 
-func (m *CommitMgr) commit(sid string, sctx *configd.Context, candidate *data.Node, message string, debug bool) *commitresp {
+func (m *CommitMgr) commit(sid string, sctx *configd.Context, candidate *data.Node, comments nodeComments, message string, debug bool) *commitresp {
+	m.schemaMu.RLock()
+	defer m.schemaMu.RUnlock()
+
+	// The client may have gone while the commit was queued.
+	if cancelled(sctx.Done()) {
+		return MakeCommitError(newCancelledError())
+	}
+
 	//"and now for the subtle bit..."
 	//This is important so it deserves an explanation.
 	//In order for the defaults to be propagated to the upper layers correctly
@@ -118,12 +166,40 @@ func (m *CommitMgr) commit(sid string, sctx *configd.Context, candidate *data.No
 	mustThreshold, _ := common.LoggingValueAndStatus(common.TypeMust)
 	ctx := newctx(sid, sctx, m.effective, mcan, run, m.schema, message,
 		debug, mustThreshold)
+
+	// Scripts reading EFFECTIVE see the configuration before the commit
+	// until it has been applied in full (see effective_snapshot.go).
+	m.effective.Snapshot(ctx.ctx)
+	defer m.effective.ReleaseSnapshot(ctx.ctx)
 	ctx.LogCommitMsg("Starting validation and commit")
-	outs, errs, ok := ctx.validate()
+	common.Log(common.LevelDebug, common.TypeCommit, "Commit started",
+		"sid", sid, "user", sctx.User)
+	var outs []*exec.Output
+	var errs []error
+	var ok bool
+	if m.validated.lookup(validationKey(sid, mcan), m.schema,
+		m.CommitId()) && !debug {
+		ctx.LogCommitMsg("Candidate unchanged since validated")
+		outs, errs, ok = ctx.validateScriptsWithTimeout()
+	} else {
+		outs, errs, ok = ctx.validateWithTimeout()
+	}
 	if !ok {
+		common.Log(common.LevelError, common.TypeCommit,
+			"Commit validation failed", "sid", sid, "user", sctx.User,
+			"errors", len(errs))
+		emitEvent(sctx, sid, EventValidationFailed, nil, errs)
+		m.recordFailedValidation(sctx)
 		return &commitresp{out: outs, err: errs, ok: ok}
 	}
 
+	dn := diff.NewNode(mcan, run, m.schema, nil)
+	if err := m.approveCommit(sid, sctx, message, dn); err != nil {
+		errs = append(errs, err)
+		return &commitresp{out: outs, err: errs, ok: false}
+	}
+	errs = append(errs, priorityWarnings(dn)...)
+
 	// Create environment for hooks
 	commitStart := time.Now()
 	env := make([]string, 0)
@@ -139,6 +215,7 @@ func (m *CommitMgr) commit(sid string, sctx *configd.Context, candidate *data.No
 	env = append(env, "PATH=/bin:/usr/bin:/sbin:/usr/sbin:/opt/vyatta/bin:/opt/vyatta/sbin")
 
 	// Run pre-hooks
+	ctx.traceCommit(TraceHooks, "/etc/commit/pre-hooks.d")
 	hout, herr := ctx.execute_hooks("/etc/commit/pre-hooks.d", env)
 	outs = append(outs, hout)
 	if herr != nil {
@@ -146,28 +223,73 @@ func (m *CommitMgr) commit(sid string, sctx *configd.Context, candidate *data.No
 	}
 	ctx.LogCommitTime("Pre-commit hooks", commitStart)
 
+	var changed [][]string
+	if dn != nil {
+		changed = diffChangedPaths(dn, nil, nil)
+	}
+
 	// Can't use AppendOutput because ctx.commit signature is different
 	var couts []*exec.Output
 	var cerrs []error
 	changedNSMap := diff.CreateChangedNSMap(mcan, run, m.schema, nil)
 	couts = sctx.CompMgr.ComponentSetRunningWithLog(
-		m.schema, ucan, changedNSMap, ctx.LogCommitTime)
+		m.schema, ucan, changedNSMap,
+		func(msg string, startTime time.Time) {
+			ctx.traceCommit(TraceComponent, msg)
+			ctx.LogCommitTime(msg, startTime)
+			common.Log(common.LevelDebug, common.TypeVci, msg,
+				"duration", time.Since(startTime))
+		})
 	outs = append(outs, couts...)
 
 	couts, cerrs, _ = ctx.commit(&env)
+	ctx.traceScripts(couts)
 	outs = append(outs, couts...)
+	stats := newCommitStatsForDiff(dn, couts)
 	errs = append(errs, cerrs...)
 
 	writeStart := time.Now()
+	// The changes have all been applied, so reads may see them, and
+	// continue to while running is replaced.
+	m.effective.ReleaseSnapshot(ctx.ctx)
 	effective := m.effective.MergeTreeWithoutDefaults(ctx.ctx)
+	m.effective.Snapshot(ctx.ctx)
 	m.effective.Discard(ctx.ctx) //we got what we needed
 	m.running.Store(effective)
-	m.writeRunning(ctx.ctx)
+	m.commitComments(comments)
+	m.effective.ReleaseSnapshot(ctx.ctx)
+	commitId := atomic.AddUint64(&m.commitId, 1)
+	if err := m.writeRunning(ctx.ctx); err != nil {
+		ctx.ctx.Elog.Printf("Unable to write running config: %s", err)
+	}
+	m.changes.add(commitId, changed)
+	m.changes.write(changesFileForRunfile(ctx.ctx.Config.Runfile))
+	m.userStats.commit(statsUser(sctx), commitId)
+	m.userStats.write(userStatsFileForRunfile(ctx.ctx.Config.Runfile))
+	m.commitStats.add(commitId, stats)
+	m.commitStats.write(commitStatsFileForRunfile(ctx.ctx.Config.Runfile))
+	if m.annotations.prune(changed) {
+		m.annotations.write(
+			annotationsFileForRunfile(ctx.ctx.Config.Runfile))
+	}
 	ctx.LogCommitTime("Write config", writeStart)
 
+	if len(changed) > 0 {
+		m.listeners.notify(&CommitNotification{
+			Id:      commitId,
+			User:    statsUser(sctx),
+			Time:    time.Now(),
+			Message: message,
+			Changes: commitChanges(dn, changed),
+			Old:     rtree,
+			New:     effective,
+		})
+	}
+
 	// Run post-hooks after we've written out the running cfg
 	postCmtHookStart := time.Now()
 	env = append(env, "COMMIT_COMMENT="+ctx.message)
+	ctx.traceCommit(TraceHooks, "/etc/commit/post-hooks.d")
 	hout, herr = ctx.execute_hooks("/etc/commit/post-hooks.d", env)
 	outs = append(outs, hout)
 	if herr != nil {
@@ -180,7 +302,7 @@ func (m *CommitMgr) commit(sid string, sctx *configd.Context, candidate *data.No
 
 	// errs here are warnings, so we return true in all cases as the commit
 	// will have been committed if we have got this far.
-	return &commitresp{out: outs, err: errs, ok: true}
+	return &commitresp{out: outs, err: errs, ok: true, trace: ctx.trace}
 }
 
 func (m *CommitMgr) run() {
@@ -198,7 +320,8 @@ func (m *CommitMgr) run() {
 			}
 			inCommit = true
 			go func(r commitmgrreq) {
-				resp := m.commit(r.sid, r.ctx, r.t, r.message, r.debug)
+				resp := m.commit(r.sid, r.ctx, r.t, r.comments, r.message,
+					r.debug)
 				donech <- done
 				r.resp <- resp
 			}(req)
@@ -208,15 +331,16 @@ func (m *CommitMgr) run() {
 	}
 }
 
-func (m *CommitMgr) Commit(sid string, ctx *configd.Context, candidate *data.Node, message string, debug bool) *commitresp {
+func (m *CommitMgr) Commit(sid string, ctx *configd.Context, candidate *data.Node, comments nodeComments, message string, debug bool) *commitresp {
 	respch := make(chan *commitresp)
 	m.reqch <- commitmgrreq{
-		sid:     sid,
-		ctx:     ctx,
-		t:       candidate,
-		resp:    respch,
-		message: message,
-		debug:   debug,
+		sid:      sid,
+		ctx:      ctx,
+		t:        candidate,
+		comments: comments,
+		resp:     respch,
+		message:  message,
+		debug:    debug,
 	}
 	return <-respch
 }
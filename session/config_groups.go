@@ -0,0 +1,281 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"sync"
+
+	"github.com/danos/config/data"
+	"github.com/danos/config/schema"
+	"github.com/danos/configd"
+	"github.com/danos/mgmterror"
+	"github.com/danos/utils/pathutil"
+)
+
+// Configuration groups
+//
+// Large deployments repeat near identical stanzas on many nodes, eg every
+// interface or VRF.  A configuration group is a named template: the paths,
+// relative to wherever the group is applied, that it sets.  Groups are
+// applied to paths, in which a "*" element matches every list entry, and
+// are expanded into the candidate when it is validated or committed, so
+// the expanded configuration is what gets checked and applied.
+//
+// Configuration set explicitly wins: a leaf that already has a value is
+// left alone, including one set by a group expanded before it.  A group is
+// only expanded below nodes that exist, so deleting an interface isn't
+// undone by a group applied to every interface.  Expansion is done as the
+// user validating or committing, so a group can't set anything they
+// couldn't set themselves.
+//
+// Groups, and where they are applied, are not YANG data so, like
+// annotations, they are held by the commit manager in a file alongside the
+// runfile, and shared by all sessions.
+
+const groupWildcard = "*"
+
+type configGroups struct {
+	mu sync.Mutex
+	// Relative paths set by each group
+	Groups map[string][]string `json:"groups"`
+	// Groups applied at each path, in the order they are expanded
+	Applied map[string][]string `json:"applied"`
+}
+
+func newConfigGroups() *configGroups {
+	return &configGroups{
+		Groups:  make(map[string][]string),
+		Applied: make(map[string][]string),
+	}
+}
+
+func configGroupsFileForRunfile(runfile string) string {
+	return runfile + ".groups"
+}
+
+func unknownConfigGroupError(name string) error {
+	err := mgmterror.NewInvalidValueApplicationError()
+	err.Message = fmt.Sprintf("No configuration group %s", name)
+	return err
+}
+
+func (g *configGroups) set(name string, paths []string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.Groups[name] = append([]string(nil), paths...)
+}
+
+// remove deletes the group, and wherever it is applied, returning false if
+// there is no such group.
+func (g *configGroups) remove(name string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, ok := g.Groups[name]; !ok {
+		return false
+	}
+	delete(g.Groups, name)
+	for pstr := range g.Applied {
+		g.unapplyLocked(pstr, name)
+	}
+	return true
+}
+
+func (g *configGroups) apply(path []string, name string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, ok := g.Groups[name]; !ok {
+		return unknownConfigGroupError(name)
+	}
+	pstr := pathutil.Pathstr(path)
+	for _, applied := range g.Applied[pstr] {
+		if applied == name {
+			return nil
+		}
+	}
+	g.Applied[pstr] = append(g.Applied[pstr], name)
+	return nil
+}
+
+func (g *configGroups) unapplyLocked(pstr, name string) bool {
+	names := g.Applied[pstr]
+	for i, applied := range names {
+		if applied != name {
+			continue
+		}
+		names = append(names[:i:i], names[i+1:]...)
+		if len(names) == 0 {
+			delete(g.Applied, pstr)
+		} else {
+			g.Applied[pstr] = names
+		}
+		return true
+	}
+	return false
+}
+
+func (g *configGroups) unapply(path []string, name string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.unapplyLocked(pathutil.Pathstr(path), name)
+}
+
+func copyStringsMap(m map[string][]string) map[string][]string {
+	out := make(map[string][]string, len(m))
+	for k, v := range m {
+		out[k] = append([]string(nil), v...)
+	}
+	return out
+}
+
+// snapshot returns copies of the groups and where they are applied.
+func (g *configGroups) snapshot() (map[string][]string, map[string][]string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return copyStringsMap(g.Groups), copyStringsMap(g.Applied)
+}
+
+func (g *configGroups) write(file string) error {
+	g.mu.Lock()
+	buf, err := json.Marshal(g)
+	g.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, buf, 0600)
+}
+
+func (g *configGroups) read(file string) error {
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	loaded := newConfigGroups()
+	if err := json.Unmarshal(buf, loaded); err != nil {
+		return err
+	}
+	g.mu.Lock()
+	g.Groups = loaded.Groups
+	g.Applied = loaded.Applied
+	g.mu.Unlock()
+	return nil
+}
+
+// LoadConfigGroups restores the configuration groups recorded before
+// configd was restarted.
+func (m *CommitMgr) LoadConfigGroups(runfile string) error {
+	return m.groups.read(configGroupsFileForRunfile(runfile))
+}
+
+// SetConfigGroup creates, or replaces, the group setting the paths, which
+// are relative to wherever it is applied.
+func (m *CommitMgr) SetConfigGroup(runfile, name string, paths []string) error {
+	m.groups.set(name, paths)
+	return m.groups.write(configGroupsFileForRunfile(runfile))
+}
+
+// DeleteConfigGroup returns false if there was no such group.
+func (m *CommitMgr) DeleteConfigGroup(runfile, name string) (bool, error) {
+	if !m.groups.remove(name) {
+		return false, nil
+	}
+	return true, m.groups.write(configGroupsFileForRunfile(runfile))
+}
+
+func (m *CommitMgr) ApplyConfigGroup(runfile string, path []string, name string) error {
+	if err := m.groups.apply(path, name); err != nil {
+		return err
+	}
+	return m.groups.write(configGroupsFileForRunfile(runfile))
+}
+
+// UnapplyConfigGroup returns false if the group wasn't applied at path.
+func (m *CommitMgr) UnapplyConfigGroup(runfile string, path []string, name string) (bool, error) {
+	if !m.groups.unapply(path, name) {
+		return false, nil
+	}
+	return true, m.groups.write(configGroupsFileForRunfile(runfile))
+}
+
+// ConfigGroups returns the paths set by each group, and the groups applied
+// at each path.
+func (m *CommitMgr) ConfigGroups() (map[string][]string, map[string][]string) {
+	return m.groups.snapshot()
+}
+
+// matchDataPaths returns the paths of the nodes below n matching pattern.
+func matchDataPaths(
+	n *data.Node, pattern, path []string, out [][]string,
+) [][]string {
+	if len(pattern) == 0 {
+		return append(out, path)
+	}
+	if pattern[0] == groupWildcard {
+		for _, ch := range n.Children() {
+			out = matchDataPaths(ch, pattern[1:],
+				pathutil.CopyAppend(path, ch.Name()), out)
+		}
+		return out
+	}
+	ch := n.Child(pattern[0])
+	if ch == nil {
+		return out
+	}
+	return matchDataPaths(ch, pattern[1:],
+		pathutil.CopyAppend(path, pattern[0]), out)
+}
+
+// expandGroup sets the group's paths below path in the candidate, other
+// than those for leaves that already have a value.
+func (s *session) expandGroup(
+	ctx *configd.Context, path, rels []string,
+) error {
+	for _, rel := range rels {
+		full := append(pathutil.Copypath(path), pathutil.Makepath(rel)...)
+		if len(full) < 2 {
+			continue
+		}
+		leaf := full[:len(full)-1]
+		if _, ok := schema.Descendant(s.schema, leaf).(schema.Leaf); ok &&
+			s.existsInTree(s.getUnion(), ctx, leaf,
+				false /* Defaults may be overridden */) {
+			continue
+		}
+		if err := s.set(ctx, full); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expandGroups sets the paths of the groups applied to nodes in the
+// candidate.
+func (s *session) expandGroups(ctx *configd.Context) error {
+	groups, applied := s.cmgr.ConfigGroups()
+	if len(applied) == 0 {
+		return nil
+	}
+	targets := make([]string, 0, len(applied))
+	for pstr := range applied {
+		targets = append(targets, pstr)
+	}
+	sort.Strings(targets)
+
+	mcan := s.getUnion().Merge()
+	for _, target := range targets {
+		paths := matchDataPaths(mcan, pathutil.Makepath(target), nil, nil)
+		for _, path := range paths {
+			for _, name := range applied[target] {
+				if err := s.expandGroup(ctx, path, groups[name]); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	. "github.com/danos/configd/session"
+	. "github.com/danos/configd/session/sessiontest"
+)
+
+const configGroupsSchema = `
+list intf {
+	key name;
+	leaf name {
+		type string;
+	}
+	leaf mtu {
+		type uint32;
+	}
+	leaf description {
+		type string;
+	}
+}
+`
+
+const configGroupsConfig = `
+intf eth0 {
+	mtu 1500
+}
+intf eth1
+intf eth2
+`
+
+func setupConfigGroups(t *testing.T) (*TstSrv, *Session, func()) {
+	dir, err := ioutil.TempDir("", "configd-groups")
+	if err != nil {
+		t.Fatalf("Unable to create runfile dir: %s", err)
+	}
+	srv, sess := TstStartup(t, configGroupsSchema, configGroupsConfig)
+	srv.Ctx.Config.Runfile = filepath.Join(dir, "running.config")
+
+	if err := srv.Cmgr.SetConfigGroup(srv.Ctx.Config.Runfile, "jumbo",
+		[]string{"mtu/9000", "description/managed"}); err != nil {
+		t.Fatalf("Unable to set group: %s", err)
+	}
+	if err := srv.Cmgr.ApplyConfigGroup(srv.Ctx.Config.Runfile,
+		[]string{"intf", "*"}, "jumbo"); err != nil {
+		t.Fatalf("Unable to apply group: %s", err)
+	}
+	return srv, sess, func() {
+		sess.Kill()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestConfigGroupsExpandedOnCommit(t *testing.T) {
+	srv, sess, cleanup := setupConfigGroups(t)
+	defer cleanup()
+
+	if err := sess.Delete(srv.Ctx, []string{"intf", "eth2"}); err != nil {
+		t.Fatalf("Unable to delete: %s", err)
+	}
+	rebaseCommit(t, srv, sess)
+
+	// Explicit configuration wins over the group.
+	checkDiscardPathValue(t, srv, sess, []string{"intf", "eth0", "mtu"},
+		"1500")
+	checkDiscardPathValue(t, srv, sess,
+		[]string{"intf", "eth0", "description"}, "managed")
+	checkDiscardPathValue(t, srv, sess, []string{"intf", "eth1", "mtu"},
+		"9000")
+	checkDiscardPathValue(t, srv, sess,
+		[]string{"intf", "eth1", "description"}, "managed")
+	// Groups are not expanded below deleted nodes.
+	ValidateExists(t, sess, srv.Ctx, []string{"intf", "eth2"}, false)
+}
+
+func TestConfigGroupsExpandedOnValidate(t *testing.T) {
+	srv, sess, cleanup := setupConfigGroups(t)
+	defer cleanup()
+
+	rebaseSet(t, srv, sess, "intf", "eth3")
+	if _, errs, ok := sess.Validate(srv.Ctx); !ok {
+		t.Fatalf("Validation failed: %v", errs)
+	}
+	checkDiscardPathValue(t, srv, sess, []string{"intf", "eth3", "mtu"},
+		"9000")
+}
+
+func TestConfigGroupsPersist(t *testing.T) {
+	srv, sess, cleanup := setupConfigGroups(t)
+	defer cleanup()
+
+	if err := srv.Cmgr.ApplyConfigGroup(srv.Ctx.Config.Runfile,
+		[]string{"intf", "eth0"}, "missing"); err == nil {
+		t.Fatalf("Applying an unknown group should fail")
+	}
+
+	groups, applied := srv.Cmgr.ConfigGroups()
+	cmgr := NewCommitMgr(nil, srv.Ms)
+	if err := cmgr.LoadConfigGroups(srv.Ctx.Config.Runfile); err != nil {
+		t.Fatalf("Unable to load groups: %s", err)
+	}
+	actGroups, actApplied := cmgr.ConfigGroups()
+	if !reflect.DeepEqual(groups, actGroups) ||
+		!reflect.DeepEqual(applied, actApplied) {
+		t.Fatalf("Unexpected groups after reload:\n%v %v\n%v %v",
+			groups, applied, actGroups, actApplied)
+	}
+
+	found, err := srv.Cmgr.DeleteConfigGroup(srv.Ctx.Config.Runfile, "jumbo")
+	if err != nil || !found {
+		t.Fatalf("Unable to delete group: %v %s", found, err)
+	}
+	if _, applied := srv.Cmgr.ConfigGroups(); len(applied) != 0 {
+		t.Fatalf("Deleted group still applied: %v", applied)
+	}
+	// Nothing left to expand, so nothing to commit
+	ValidateCommit(t, sess, srv.Ctx, false)
+}
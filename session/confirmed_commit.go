@@ -0,0 +1,200 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/danos/mgmterror"
+)
+
+// Confirmed commit
+//
+// A confirmed commit is reverted unless it is confirmed before its timeout
+// expires.  The commit manager tracks the pending confirmed commit: the
+// session or persist-id that may confirm it, and when it times out.  The
+// state is kept in a job file rather than in memory so that a pending
+// confirmed commit survives a configd restart, and is still reverted in
+// time once the timer is resumed.  Reverting needs the configuration to be
+// loaded and committed as for a rollback, so is done by the function given
+// to SetConfirmedCommitRevert, called once the timeout has expired.
+//
+// A confirmed commit started from the CLI, rather than NETCONF, belongs to
+// no session, so isn't reverted when its session ends.  Any session allowed
+// to may confirm, extend or cancel it, and a later CLI commit confirms it.
+
+type ConfirmedCommitInfo struct {
+	Session   string    `json:"session"`
+	PersistId string    `json:"persist-id"`
+	Deadline  time.Time `json:"deadline"`
+}
+
+// Pending returns true if there is a confirmed commit awaiting
+// confirmation.
+func (i ConfirmedCommitInfo) Pending() bool {
+	return i.Session != "" || i.PersistId != "" || !i.Deadline.IsZero()
+}
+
+type confirmedCommit struct {
+	mu     sync.Mutex
+	timer  *time.Timer
+	revert func(ConfirmedCommitInfo)
+}
+
+func newNoConfirmedCommitError() error {
+	err := mgmterror.NewOperationFailedApplicationError()
+	err.Message = "No confirmed commit pending"
+	return err
+}
+
+func readConfirmedCommit(file string) ConfirmedCommitInfo {
+	var info ConfirmedCommitInfo
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		// Likely no pending confirmed commit
+		return info
+	}
+	json.Unmarshal(buf, &info)
+	return info
+}
+
+func writeConfirmedCommit(file string, info ConfirmedCommitInfo) error {
+	buf, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, buf, 0600)
+}
+
+// arm (re)starts the timer to expire the confirmed commit at deadline.
+func (m *CommitMgr) armConfirmedCommit(file string, deadline time.Time) {
+	if m.confirmed.timer != nil {
+		m.confirmed.timer.Stop()
+	}
+	m.confirmed.timer = time.AfterFunc(time.Until(deadline), func() {
+		m.expireConfirmedCommit(file)
+	})
+}
+
+func (m *CommitMgr) expireConfirmedCommit(file string) {
+	m.confirmed.mu.Lock()
+	info := readConfirmedCommit(file)
+	// The timer may have been superseded by a confirmation or an
+	// extension while it fired.
+	if !info.Pending() || time.Now().Before(info.Deadline) {
+		m.confirmed.mu.Unlock()
+		return
+	}
+	os.Remove(file)
+	m.confirmed.timer = nil
+	revert := m.confirmed.revert
+	m.confirmed.mu.Unlock()
+
+	if revert != nil {
+		revert(info)
+	}
+}
+
+// SetConfirmedCommitRevert sets the function called to revert a confirmed
+// commit which has timed out.
+func (m *CommitMgr) SetConfirmedCommitRevert(fn func(ConfirmedCommitInfo)) {
+	m.confirmed.mu.Lock()
+	defer m.confirmed.mu.Unlock()
+	m.confirmed.revert = fn
+}
+
+// ResumeConfirmedCommit restarts the timer for a confirmed commit left
+// pending when configd was restarted.  One which has already timed out is
+// reverted straight away.  A job file written by vyatta-config-mgmt.pl,
+// before confirmed commits were tracked here, has no deadline, so is
+// given one of legacyTimeout from now rather than blocking commits until
+// it is confirmed.
+func (m *CommitMgr) ResumeConfirmedCommit(
+	file string,
+	legacyTimeout time.Duration,
+) {
+	m.confirmed.mu.Lock()
+	defer m.confirmed.mu.Unlock()
+	info := readConfirmedCommit(file)
+	if !info.Pending() {
+		return
+	}
+	if info.Deadline.IsZero() {
+		info.Deadline = time.Now().Add(legacyTimeout)
+		// The timer is armed even if this fails, so the commit is still
+		// reverted in time.
+		writeConfirmedCommit(file, info)
+	}
+	m.armConfirmedCommit(file, info.Deadline)
+}
+
+// ConfirmedCommit returns the pending confirmed commit, if any.
+func (m *CommitMgr) ConfirmedCommit(file string) ConfirmedCommitInfo {
+	m.confirmed.mu.Lock()
+	defer m.confirmed.mu.Unlock()
+	return readConfirmedCommit(file)
+}
+
+// StartConfirmedCommit records the confirmed commit, to be reverted unless
+// confirmed within timeout.  A follow-up confirmed commit replaces the one
+// pending, restarting its timer.
+func (m *CommitMgr) StartConfirmedCommit(
+	file string,
+	info ConfirmedCommitInfo,
+	timeout time.Duration,
+) (ConfirmedCommitInfo, error) {
+	m.confirmed.mu.Lock()
+	defer m.confirmed.mu.Unlock()
+	info.Deadline = time.Now().Add(timeout)
+	if err := writeConfirmedCommit(file, info); err != nil {
+		return info, err
+	}
+	m.armConfirmedCommit(file, info.Deadline)
+	return info, nil
+}
+
+// ExtendConfirmedCommit pushes back the timeout of the pending confirmed
+// commit.
+func (m *CommitMgr) ExtendConfirmedCommit(
+	file string,
+	by time.Duration,
+) (ConfirmedCommitInfo, error) {
+	m.confirmed.mu.Lock()
+	defer m.confirmed.mu.Unlock()
+	info := readConfirmedCommit(file)
+	if !info.Pending() {
+		return info, newNoConfirmedCommitError()
+	}
+	if info.Deadline.IsZero() {
+		info.Deadline = time.Now()
+	}
+	info.Deadline = info.Deadline.Add(by)
+	if err := writeConfirmedCommit(file, info); err != nil {
+		return info, err
+	}
+	m.armConfirmedCommit(file, info.Deadline)
+	return info, nil
+}
+
+// ConfirmCommit confirms the pending confirmed commit, so it is no longer
+// reverted, returning it.  False is returned if none was pending.
+func (m *CommitMgr) ConfirmCommit(file string) (ConfirmedCommitInfo, bool) {
+	m.confirmed.mu.Lock()
+	defer m.confirmed.mu.Unlock()
+	info := readConfirmedCommit(file)
+	if m.confirmed.timer != nil {
+		m.confirmed.timer.Stop()
+		m.confirmed.timer = nil
+	}
+	if !info.Pending() {
+		return info, false
+	}
+	os.Remove(file)
+	return info, true
+}
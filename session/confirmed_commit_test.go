@@ -0,0 +1,165 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/danos/configd/session"
+	. "github.com/danos/configd/session/sessiontest"
+)
+
+const confirmedCommitSchema = `
+container testcontainer {
+	leaf testleaf {
+		type string;
+	}
+}
+`
+
+func setupConfirmedCommit(t *testing.T) (*CommitMgr, string, chan ConfirmedCommitInfo, func()) {
+	srv, sess := TstStartup(t, confirmedCommitSchema, emptyconfig)
+	dir, err := ioutil.TempDir("", "configd-confirmed")
+	if err != nil {
+		t.Fatalf("Unable to create job dir: %s", err)
+	}
+	reverted := make(chan ConfirmedCommitInfo, 1)
+	srv.Cmgr.SetConfirmedCommitRevert(func(info ConfirmedCommitInfo) {
+		reverted <- info
+	})
+	return srv.Cmgr, filepath.Join(dir, "confirmed_commit.job"), reverted,
+		func() {
+			sess.Kill()
+			os.RemoveAll(dir)
+		}
+}
+
+func TestConfirmedCommitTimesOut(t *testing.T) {
+	cmgr, file, reverted, cleanup := setupConfirmedCommit(t)
+	defer cleanup()
+
+	_, err := cmgr.StartConfirmedCommit(file,
+		ConfirmedCommitInfo{Session: "1234", PersistId: "abc"},
+		50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Unable to start confirmed commit: %s", err)
+	}
+	if info := cmgr.ConfirmedCommit(file); info.PersistId != "abc" {
+		t.Fatalf("Unexpected pending confirmed commit: %+v", info)
+	}
+
+	select {
+	case info := <-reverted:
+		if info.PersistId != "abc" {
+			t.Fatalf("Unexpected confirmed commit reverted: %+v", info)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Confirmed commit not reverted")
+	}
+	if info := cmgr.ConfirmedCommit(file); info.Pending() {
+		t.Fatalf("Confirmed commit still pending after revert: %+v", info)
+	}
+}
+
+func TestConfirmedCommitConfirmed(t *testing.T) {
+	cmgr, file, reverted, cleanup := setupConfirmedCommit(t)
+	defer cleanup()
+
+	if _, ok := cmgr.ConfirmCommit(file); ok {
+		t.Fatal("Confirmed a commit when none was pending")
+	}
+	_, err := cmgr.StartConfirmedCommit(file,
+		ConfirmedCommitInfo{Session: "1234"}, 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Unable to start confirmed commit: %s", err)
+	}
+	if _, ok := cmgr.ConfirmCommit(file); !ok {
+		t.Fatal("Unable to confirm commit")
+	}
+
+	select {
+	case info := <-reverted:
+		t.Fatalf("Confirmed commit reverted: %+v", info)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestConfirmedCommitExtended(t *testing.T) {
+	cmgr, file, reverted, cleanup := setupConfirmedCommit(t)
+	defer cleanup()
+
+	if _, err := cmgr.ExtendConfirmedCommit(file, time.Minute); err == nil {
+		t.Fatal("Extended a confirmed commit when none was pending")
+	}
+	start, err := cmgr.StartConfirmedCommit(file,
+		ConfirmedCommitInfo{Session: "1234"}, 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Unable to start confirmed commit: %s", err)
+	}
+	info, err := cmgr.ExtendConfirmedCommit(file, time.Minute)
+	if err != nil {
+		t.Fatalf("Unable to extend confirmed commit: %s", err)
+	}
+	if !info.Deadline.Equal(start.Deadline.Add(time.Minute)) {
+		t.Fatalf("Unexpected deadline %s, expected %s", info.Deadline,
+			start.Deadline.Add(time.Minute))
+	}
+
+	select {
+	case info := <-reverted:
+		t.Fatalf("Extended confirmed commit reverted: %+v", info)
+	case <-time.After(200 * time.Millisecond):
+	}
+	cmgr.ConfirmCommit(file)
+}
+
+func TestConfirmedCommitResumed(t *testing.T) {
+	cmgr, file, reverted, cleanup := setupConfirmedCommit(t)
+	defer cleanup()
+
+	// As left by a configd which was restarted after the timeout expired
+	job := `{"session":"1234","persist-id":"","deadline":"2001-01-01T00:00:00Z"}`
+	if err := ioutil.WriteFile(file, []byte(job), 0600); err != nil {
+		t.Fatalf("Unable to write job file: %s", err)
+	}
+	cmgr.ResumeConfirmedCommit(file, time.Hour)
+
+	select {
+	case info := <-reverted:
+		if info.Session != "1234" {
+			t.Fatalf("Unexpected confirmed commit reverted: %+v", info)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Resumed confirmed commit not reverted")
+	}
+}
+
+func TestLegacyConfirmedCommitResumed(t *testing.T) {
+	cmgr, file, reverted, cleanup := setupConfirmedCommit(t)
+	defer cleanup()
+
+	// As left by vyatta-config-mgmt.pl, which kept no deadline
+	job := `{"session":"1234","persist-id":""}`
+	if err := ioutil.WriteFile(file, []byte(job), 0600); err != nil {
+		t.Fatalf("Unable to write job file: %s", err)
+	}
+	cmgr.ResumeConfirmedCommit(file, 50*time.Millisecond)
+
+	if info := cmgr.ConfirmedCommit(file); info.Deadline.IsZero() {
+		t.Fatalf("Legacy confirmed commit given no deadline: %+v", info)
+	}
+	select {
+	case info := <-reverted:
+		if info.Session != "1234" {
+			t.Fatalf("Unexpected confirmed commit reverted: %+v", info)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Legacy confirmed commit not reverted")
+	}
+}
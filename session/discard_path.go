@@ -0,0 +1,124 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session
+
+import (
+	"github.com/danos/config/data"
+	"github.com/danos/config/union"
+	"github.com/danos/configd"
+	"github.com/danos/utils/pathutil"
+)
+
+// Partial discard
+//
+// Discarding a subtree reverts it to running while keeping the session's
+// changes elsewhere.  Rather than unpicking the candidate, which only holds
+// the changes layered over running, we work out what has changed outside
+// the subtree and replay just those changes onto a new candidate.  As for
+// rebase, changes are compared against the current running tree.
+
+// dataDescendant returns the node at path below n, or nil if there isn't
+// one.
+func dataDescendant(n *data.Node, path []string) *data.Node {
+	for _, elem := range path {
+		if n = n.Child(elem); n == nil {
+			return nil
+		}
+	}
+	return n
+}
+
+// dataLeafPaths returns the paths of the leaf-most nodes at and below n,
+// which is at path, ie the paths that must be set to recreate n.
+func dataLeafPaths(n *data.Node, path []string, paths [][]string) [][]string {
+	chs := n.Children()
+	if len(chs) == 0 {
+		return append(paths, path)
+	}
+	for _, ch := range chs {
+		paths = dataLeafPaths(ch, pathutil.CopyAppend(path, ch.Name()), paths)
+	}
+	return paths
+}
+
+// setLeafPaths sets the paths needed to recreate the node at path in tree,
+// other than those at or below skip.
+func setLeafPaths(
+	ut union.Node, auther union.Auther, tree *data.Node, path, skip []string,
+) error {
+	n := dataDescendant(tree, path)
+	if n == nil {
+		return nil
+	}
+	for _, p := range dataLeafPaths(n, path, nil) {
+		if skip != nil && pathIsPrefix(skip, p) {
+			continue
+		}
+		if err := ut.Set(auther, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replayChange makes the node at changed in ut match that in the merged
+// candidate mcan, apart from anything at or below the discarded path,
+// which is left as it is in the merged running tree mrun.
+func replayChange(
+	ut union.Node, auther union.Auther, mcan, mrun *data.Node,
+	changed, discarded []string,
+) error {
+	if dataDescendant(mcan, changed) != nil {
+		return setLeafPaths(ut, auther, mcan, changed, discarded)
+	}
+	if err := ut.Delete(auther, changed, union.DontCheckAuth); err != nil {
+		return err
+	}
+	if pathIsPrefix(changed, discarded) {
+		return setLeafPaths(ut, auther, mrun, discarded, nil)
+	}
+	return nil
+}
+
+// replayCandidate returns a new candidate holding the session's changes,
+// other than those at or below skip, if given.
+func (s *session) replayCandidate(
+	ctx *configd.Context, skip []string,
+) (*data.Node, error) {
+	running := s.viewRunning()
+	mcan := s.getUnion().MergeWithoutDefaults()
+	mrun := union.NewNode(nil, running, s.schema, nil, 0).MergeWithoutDefaults()
+
+	candidate := data.New("root")
+	ut := union.NewNode(candidate, running, s.schema, nil, 0)
+	auther := s.newAuther(ctx)
+	for _, changed := range s.changedPaths(mcan, mrun) {
+		if skip != nil && pathIsPrefix(skip, changed) {
+			continue
+		}
+		err := replayChange(ut, auther, mcan, mrun, changed, skip)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return candidate, nil
+}
+
+func (s *session) discardPath(ctx *configd.Context, path []string) error {
+	if len(path) == 0 {
+		return s.discard(ctx)
+	}
+	if err := s.trylock(ctx.Pid); err != nil {
+		return err
+	}
+
+	candidate, err := s.replayCandidate(ctx, path)
+	if err != nil {
+		// Leave the candidate as it was.
+		return err
+	}
+	s.candidate = candidate
+	return nil
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session_test
+
+import (
+	"testing"
+
+	. "github.com/danos/configd/session"
+	. "github.com/danos/configd/session/sessiontest"
+)
+
+const discardPathSchema = `
+container first {
+	leaf value {
+		type string;
+	}
+	leaf other {
+		type string;
+	}
+}
+container second {
+	leaf value {
+		type string;
+	}
+}
+`
+
+const discardPathConfig = `
+first {
+	value one
+	other keep
+}
+second {
+	value two
+}
+`
+
+func checkDiscardPathValue(
+	t *testing.T,
+	srv *TstSrv,
+	sess *Session,
+	path []string,
+	exp string,
+) {
+	t.Helper()
+	vals, err := sess.Get(srv.Ctx, path)
+	switch {
+	case exp == "" && err == nil:
+		t.Fatalf("%v should not exist, has %v", path, vals)
+	case exp == "":
+		return
+	case err != nil:
+		t.Fatalf("Unable to get %v: %s", path, err)
+	case len(vals) != 1 || vals[0] != exp:
+		t.Fatalf("Unexpected value for %v: %v", path, vals)
+	}
+}
+
+func TestDiscardPath(t *testing.T) {
+	srv, sess := TstStartup(t, discardPathSchema, discardPathConfig)
+	defer sess.Kill()
+
+	rebaseSet(t, srv, sess, "first", "value", "changed")
+	if err := sess.Delete(srv.Ctx, []string{"first", "other"}); err != nil {
+		t.Fatalf("Unable to delete: %s", err)
+	}
+	rebaseSet(t, srv, sess, "second", "value", "changed")
+
+	if err := sess.DiscardPath(srv.Ctx, []string{"first"}); err != nil {
+		t.Fatalf("Unable to discard path: %s", err)
+	}
+
+	checkDiscardPathValue(t, srv, sess, []string{"first", "value"}, "one")
+	checkDiscardPathValue(t, srv, sess, []string{"first", "other"}, "keep")
+	checkDiscardPathValue(t, srv, sess, []string{"second", "value"}, "changed")
+	if !sess.Changed(srv.Ctx) {
+		t.Fatalf("Session should still have changes")
+	}
+
+	if err := sess.DiscardPath(srv.Ctx, []string{"second", "value"}); err != nil {
+		t.Fatalf("Unable to discard path: %s", err)
+	}
+	checkDiscardPathValue(t, srv, sess, []string{"second", "value"}, "two")
+	if sess.Changed(srv.Ctx) {
+		t.Fatalf("Session should have no changes left")
+	}
+}
+
+func TestDiscardPathInsideAddedSubtree(t *testing.T) {
+	srv, sess := TstStartup(t, discardPathSchema, emptyconfig)
+	defer sess.Kill()
+
+	rebaseSet(t, srv, sess, "first", "value", "new")
+	rebaseSet(t, srv, sess, "first", "other", "new")
+
+	if err := sess.DiscardPath(srv.Ctx, []string{"first", "other"}); err != nil {
+		t.Fatalf("Unable to discard path: %s", err)
+	}
+	checkDiscardPathValue(t, srv, sess, []string{"first", "value"}, "new")
+	checkDiscardPathValue(t, srv, sess, []string{"first", "other"}, "")
+}
@@ -196,12 +196,15 @@ func (o *operation) UnmarshalXMLAttr(attr xml.Attr) error {
 }
 
 type edit_node struct {
-	XMLName   xml.Name
-	Operation operation   `xml:"operation,attr"`
-	Value     string      `xml:",chardata"`
-	Children  []edit_node `xml:",any"`
-	Path      string
-	Type      rpc.NodeType
+	XMLName     xml.Name
+	Operation   operation   `xml:"operation,attr"`
+	Insert      string      `xml:"urn:ietf:params:xml:ns:yang:1 insert,attr"`
+	InsertValue string      `xml:"urn:ietf:params:xml:ns:yang:1 value,attr"`
+	InsertKey   string      `xml:"urn:ietf:params:xml:ns:yang:1 key,attr"`
+	Value       string      `xml:",chardata"`
+	Children    []edit_node `xml:",any"`
+	Path        string
+	Type        rpc.NodeType
 }
 
 func (en edit_node) getOperation(parentop operation) operation {
@@ -224,6 +227,10 @@ type edit_op struct {
 	op        operation
 	path      []string
 	pathAttrs *pathutil.PathAttrs
+
+	// Where a list or leaf-list entry goes, see ordered_insert.go
+	insert      string
+	insertValue string
 }
 
 func (e edit_op) getPathAttrsForPerm(perm auth.AuthPerm, ec edit_config) ([]string, *pathutil.PathAttrs) {
@@ -256,6 +263,9 @@ func (ec edit_config) authorize(
 	e edit_op,
 ) bool {
 
+	if ec.sess.frozenFor(ec.ctx, e.path, perm == auth.P_DELETE) {
+		return false
+	}
 	cmd, attrs := e.getPathAttrsForPerm(perm, ec)
 	if attrs == nil {
 		return false
@@ -430,6 +440,13 @@ func (e edit_op) Remove(ec edit_config) error {
 }
 
 func (e edit_op) Set(ec edit_config) error {
+	if err := e.set(ec); err != nil || e.insert == "" {
+		return err
+	}
+	return e.reposition(ec)
+}
+
+func (e edit_op) set(ec edit_config) error {
 	switch e.op {
 	case op_merge:
 		return e.Merge(ec)
@@ -487,6 +504,19 @@ type edit_config struct {
 }
 
 func newEditConfigXML(s *session, ctx *configd.Context, config_target, def_operation, test_option, error_option string, config []byte) (*edit_config, error) {
+	ec, err := newEditConfig(s, ctx, config_target, def_operation, test_option, error_option)
+	if err != nil {
+		return nil, err
+	}
+	if err := xml.Unmarshal(config, ec); err != nil {
+		return nil, err
+	}
+	return ec, nil
+}
+
+// newEditConfig returns an edit_config with the given options, but nothing
+// yet to edit.
+func newEditConfig(s *session, ctx *configd.Context, config_target, def_operation, test_option, error_option string) (*edit_config, error) {
 	ec := edit_config{sess: s, ctx: ctx}
 	if err := ec.Target.Set(config_target); err != nil {
 		return nil, err
@@ -500,9 +530,6 @@ func newEditConfigXML(s *session, ctx *configd.Context, config_target, def_opera
 	if err := ec.ErrorOption.Set(error_option); err != nil {
 		return nil, err
 	}
-	if err := xml.Unmarshal(config, &ec); err != nil {
-		return nil, err
-	}
 	return &ec, nil
 }
 
@@ -513,6 +540,14 @@ func (ec *edit_config) Add(op operation, path []string) {
 	ec.ops = append(ec.ops, edit_op{op: op, path: p})
 }
 
+func (ec *edit_config) addInsert(
+	op operation, path []string, insert, insertValue string,
+) {
+	ec.Add(op, path)
+	ec.ops[len(ec.ops)-1].insert = insert
+	ec.ops[len(ec.ops)-1].insertValue = insertValue
+}
+
 func (en edit_node) traversePostOrder(ec *edit_config, parentop operation, curpath []string) {
 	op := en.getOperation(parentop)
 	for _, c := range en.Children {
@@ -646,9 +681,37 @@ func (en edit_node) traverseList(ec *edit_config, parentop operation, curpath []
 		}
 		return
 	}
+	if en.Insert != "" {
+		en.traverseInsert(ec, parentop, sch, path)
+		return
+	}
 	en.traverseSubtree(ec, parentop, path)
 }
 
+// traverseInsert adds the list entry at path, which goes where its insert
+// attribute says, followed by what is below it.
+func (en edit_node) traverseInsert(
+	ec *edit_config, parentop operation, sch schema.Node, path []string,
+) {
+	listPath := path[:len(path)-1]
+	point, err := insertKeyValue(listPath, en.InsertKey)
+	if err == nil {
+		err = checkInsert(sch, listPath, en.Insert, point)
+	}
+	if err != nil {
+		panic(err)
+	}
+	op := en.getOperation(parentop)
+	if op == op_delete || op == op_remove {
+		en.traverseSubtree(ec, parentop, path)
+		return
+	}
+	ec.addInsert(op, path, en.Insert, point)
+	for _, c := range en.Children {
+		c.traverse(ec, op, path)
+	}
+}
+
 func (en edit_node) traverseLeaf(ec *edit_config, parentop operation, curpath []string) {
 	sch := schema.Descendant(ec.sess.schema, curpath)
 	if sch == nil {
@@ -660,6 +723,15 @@ func (en edit_node) traverseLeaf(ec *edit_config, parentop operation, curpath []
 		panic(mgmterror.NewUnknownNamespaceApplicationError(pathutil.Pathstr(curpath), en.XMLName.Space))
 	}
 	op := en.getOperation(parentop)
+	if en.Insert != "" {
+		if err := checkInsert(sch, curpath, en.Insert,
+			en.InsertValue); err != nil {
+			panic(err)
+		}
+		ec.addInsert(op, append(curpath, en.Value),
+			en.Insert, en.InsertValue)
+		return
+	}
 	_, isEmpty := sch.Type().(schema.Empty)
 	if !isEmpty && en.Value != "" {
 		path := append(curpath, en.Value)
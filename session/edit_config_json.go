@@ -0,0 +1,371 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/danos/config/schema"
+	"github.com/danos/configd"
+	"github.com/danos/mgmterror"
+	"github.com/danos/utils/pathutil"
+)
+
+// JSON edit-config
+//
+// Building NETCONF XML is a chore for programmatic clients, which mostly
+// speak JSON.  editConfigJSON accepts the configuration as an RFC 7951
+// document, where top level members are qualified by their module name, or
+// as plain JSON, where namespaces are taken from the schema, and turns it
+// into the same tree of edit nodes an XML edit-config gives, so it is
+// applied in exactly the same way.
+//
+// Operations are given as RFC 7951 metadata: "@" within an object for the
+// container or list entry itself, and "@name" alongside a leaf or leaf-list
+// called name, each being an object with an "ietf-netconf:operation"
+// member.  For a leaf-list "@name" may instead be an array holding the
+// metadata of each entry in turn, which may also say where the entry is
+// inserted, as may the "@" of an entry of a list (see ordered_insert.go).
+// As in a JSON merge-patch (RFC 7386),
+// a member whose value is null is removed.
+
+const (
+	EditConfigEncodingRFC7951 = "rfc7951"
+	EditConfigEncodingJSON    = "json"
+
+	jsonMetadataPrefix  = "@"
+	jsonOperationMember = "ietf-netconf:operation"
+	jsonOperationAlone  = "operation"
+	jsonInsertMember    = "yang:insert"
+	jsonInsertAlone     = "insert"
+	jsonValueMember     = "yang:value"
+	jsonValueAlone      = "value"
+	jsonKeyMember       = "yang:key"
+	jsonKeyAlone        = "key"
+)
+
+type jsonEditConfig struct {
+	sess     *session
+	encoding string
+	modules  map[string]string // Namespace of each module
+}
+
+func newJSONEditConfig(s *session, encoding string) (*jsonEditConfig, error) {
+	switch encoding {
+	case EditConfigEncodingRFC7951, EditConfigEncodingJSON:
+	default:
+		err := mgmterror.NewInvalidValueProtocolError()
+		err.Message = fmt.Sprintf("Unsupported encoding %s", encoding)
+		return nil, err
+	}
+	je := &jsonEditConfig{
+		sess:     s,
+		encoding: encoding,
+		modules:  make(map[string]string),
+	}
+	for name, mod := range s.schema.Modules() {
+		je.modules[name] = mod.Namespace()
+	}
+	return je, nil
+}
+
+func jsonEditError(path []string, msg string) error {
+	err := mgmterror.NewInvalidValueApplicationError()
+	err.Path = pathutil.Pathstr(path)
+	err.Message = msg
+	return err
+}
+
+// qualify returns the node name and namespace of the member called name
+// below path.
+func (je *jsonEditConfig) qualify(
+	path []string, parentNS, name string,
+) (string, string, error) {
+	if i := strings.Index(name, ":"); i >= 0 {
+		ns, ok := je.modules[name[:i]]
+		if !ok {
+			return "", "", mgmterror.NewUnknownNamespaceApplicationError(
+				pathutil.Pathstr(path), name[:i])
+		}
+		return name[i+1:], ns, nil
+	}
+	if je.encoding == EditConfigEncodingRFC7951 {
+		if parentNS == "" {
+			return "", "", jsonEditError(path, fmt.Sprintf(
+				"%s must be qualified by its module name", name))
+		}
+		return name, parentNS, nil
+	}
+
+	sch := schema.Descendant(je.sess.schema, pathutil.CopyAppend(path, name))
+	if sch == nil {
+		return name, "", nil
+	}
+	return name, sch.Namespace(), nil
+}
+
+// jsonOperation returns the operation in the metadata given for a node, if
+// any.
+func jsonOperation(path []string, meta interface{}) (operation, error) {
+	var op operation
+	if meta == nil {
+		return op, nil
+	}
+	m, ok := meta.(map[string]interface{})
+	if !ok {
+		return op, jsonEditError(path, "Metadata must be an object")
+	}
+	val, ok := m[jsonOperationMember]
+	if !ok {
+		val, ok = m[jsonOperationAlone]
+	}
+	if !ok {
+		return op, nil
+	}
+	opName, ok := val.(string)
+	if !ok {
+		return op, jsonEditError(path, "Operation must be a string")
+	}
+	return op, op.set(opName)
+}
+
+// jsonMetaString returns the string member of meta called member, or
+// alone.
+func jsonMetaString(
+	path []string, meta map[string]interface{}, member, alone string,
+) (string, error) {
+	val, ok := meta[member]
+	if !ok {
+		val, ok = meta[alone]
+	}
+	if !ok {
+		return "", nil
+	}
+	str, ok := val.(string)
+	if !ok {
+		return "", jsonEditError(path, alone+" must be a string")
+	}
+	return str, nil
+}
+
+// jsonInsert returns where the metadata given for a leaf-list entry says
+// it is inserted, if anywhere, and the value it goes before or after.
+func jsonInsert(path []string, meta interface{}) (string, string, error) {
+	m, ok := meta.(map[string]interface{})
+	if !ok {
+		return "", "", nil
+	}
+	insert, err := jsonMetaString(path, m, jsonInsertMember, jsonInsertAlone)
+	if err != nil {
+		return "", "", err
+	}
+	value, err := jsonMetaString(path, m, jsonValueMember, jsonValueAlone)
+	return insert, value, err
+}
+
+// jsonLeafListEntryMeta applies the metadata given for a leaf-list entry
+// to its edit node.
+func jsonLeafListEntryMeta(
+	path []string, meta interface{}, en *edit_node,
+) error {
+	op, err := jsonOperation(path, meta)
+	if err != nil {
+		return err
+	}
+	if op != op_notset {
+		en.Operation = op
+	}
+	en.Insert, en.InsertValue, err = jsonInsert(path, meta)
+	return err
+}
+
+func jsonScalar(path []string, val interface{}) (string, error) {
+	switch v := val.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	}
+	return "", jsonEditError(path, "Unexpected value")
+}
+
+// keysFirst moves the list entry's first key to the front of its children,
+// where the edit-config traversal expects it.
+func keysFirst(sch schema.Node, children []edit_node) {
+	list, ok := sch.(schema.List)
+	if !ok || len(list.Keys()) == 0 {
+		return
+	}
+	for i, ch := range children {
+		if ch.XMLName.Local == list.Keys()[0] {
+			copy(children[1:i+1], children[:i])
+			children[0] = ch
+			return
+		}
+	}
+}
+
+// entry returns the edit node for a container or list entry.
+func (je *jsonEditConfig) entry(
+	path []string, name xml.Name, op operation, obj map[string]interface{},
+) (edit_node, error) {
+	en := edit_node{XMLName: name, Operation: op}
+	ownOp, err := jsonOperation(path, obj[jsonMetadataPrefix])
+	if err != nil {
+		return en, err
+	}
+	if ownOp != op_notset {
+		en.Operation = ownOp
+	}
+	if meta, ok := obj[jsonMetadataPrefix].(map[string]interface{}); ok {
+		en.Insert, err = jsonMetaString(path, meta,
+			jsonInsertMember, jsonInsertAlone)
+		if err == nil {
+			en.InsertKey, err = jsonMetaString(path, meta,
+				jsonKeyMember, jsonKeyAlone)
+		}
+		if err != nil {
+			return en, err
+		}
+	}
+	en.Children, err = je.members(path, name.Space, obj)
+	if err != nil {
+		return en, err
+	}
+	keysFirst(schema.Descendant(je.sess.schema, path), en.Children)
+	return en, nil
+}
+
+// members returns the edit nodes for the members of obj, which is at path.
+func (je *jsonEditConfig) members(
+	path []string, parentNS string, obj map[string]interface{},
+) ([]edit_node, error) {
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		if !strings.HasPrefix(name, jsonMetadataPrefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var nodes []edit_node
+	for _, name := range names {
+		local, ns, err := je.qualify(path, parentNS, name)
+		if err != nil {
+			return nil, err
+		}
+		chPath := pathutil.CopyAppend(path, local)
+		if schema.Descendant(je.sess.schema, chPath) == nil {
+			cerr := mgmterror.NewUnknownElementApplicationError(local)
+			cerr.Path = pathutil.Pathstr(path)
+			return nil, cerr
+		}
+		xmlName := xml.Name{Space: ns, Local: local}
+		meta := obj[jsonMetadataPrefix+name]
+		entryMeta, perEntry := meta.([]interface{})
+		if perEntry {
+			meta = nil
+		}
+		op, err := jsonOperation(chPath, meta)
+		if err != nil {
+			return nil, err
+		}
+
+		switch v := obj[name].(type) {
+		case nil:
+			if op == op_notset {
+				op = op_remove
+			}
+			nodes = append(nodes, edit_node{XMLName: xmlName, Operation: op})
+		case map[string]interface{}:
+			en, err := je.entry(chPath, xmlName, op, v)
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, en)
+		case []interface{}:
+			for i, elem := range v {
+				var en edit_node
+				switch ev := elem.(type) {
+				case map[string]interface{}:
+					en, err = je.entry(chPath, xmlName, op, ev)
+				case nil:
+					// An empty leaf is [null]
+					en = edit_node{XMLName: xmlName, Operation: op}
+				default:
+					en = edit_node{XMLName: xmlName, Operation: op}
+					en.Value, err = jsonScalar(chPath, ev)
+					if err == nil && perEntry && i < len(entryMeta) {
+						err = jsonLeafListEntryMeta(
+							chPath, entryMeta[i], &en)
+					}
+				}
+				if err != nil {
+					return nil, err
+				}
+				nodes = append(nodes, en)
+			}
+		default:
+			en := edit_node{XMLName: xmlName, Operation: op}
+			if en.Value, err = jsonScalar(chPath, v); err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, en)
+		}
+	}
+	return nodes, nil
+}
+
+func (je *jsonEditConfig) editNodes(config string) ([]*edit_node, error) {
+	dec := json.NewDecoder(bytes.NewBufferString(config))
+	dec.UseNumber()
+	var obj map[string]interface{}
+	if err := dec.Decode(&obj); err != nil {
+		merr := mgmterror.NewMalformedMessageError()
+		merr.Message = err.Error()
+		return nil, merr
+	}
+	nodes, err := je.members([]string{}, "", obj)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*edit_node, len(nodes))
+	for i := range nodes {
+		out[i] = &nodes[i]
+	}
+	return out, nil
+}
+
+func (s *session) editConfigJSON(
+	ctx *configd.Context,
+	config_target, default_operation, test_option, error_option,
+	encoding, config string,
+) error {
+	if err := s.trylock(ctx.Pid); err != nil {
+		return err
+	}
+
+	ec, err := newEditConfig(s, ctx, config_target, default_operation,
+		test_option, error_option)
+	if err != nil {
+		return err
+	}
+	je, err := newJSONEditConfig(s, encoding)
+	if err != nil {
+		return err
+	}
+	if ec.Children, err = je.editNodes(config); err != nil {
+		return err
+	}
+	return ec.EditConfig()
+}
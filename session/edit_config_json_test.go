@@ -0,0 +1,180 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session_test
+
+import (
+	"testing"
+
+	"github.com/danos/configd"
+	. "github.com/danos/configd/session"
+	. "github.com/danos/configd/session/sessiontest"
+)
+
+func validateEditConfigJSON(t *testing.T, experr bool, sess *Session,
+	ctx *configd.Context, default_operation, encoding, config string,
+) {
+	t.Helper()
+	err := sess.EditConfigJSON(ctx, target_candidate, default_operation,
+		testopt_testset, erropt_stop, encoding, config)
+	if (err != nil) != experr {
+		if err == nil {
+			t.Fatal("Unexpected edit-config success")
+		}
+		t.Fatalf("Unexpected edit-config failure: %s", err)
+	}
+}
+
+func TestEditConfigJSONMergeList(t *testing.T) {
+	const config = `protocols {
+	ospf {
+		area 0 {
+			network 10.1.1.0/24
+		}
+		area 1 {
+			network 2.2.2.2/32
+		}
+	}
+}
+`
+	const expconfig = `protocols {
+	ospf {
+		area 0 {
+			network 10.1.1.0/24
+			network 1.1.1.1/32
+		}
+		area 1 {
+			network 2.2.2.2/32
+		}
+		area 2 {
+			network 3.3.3.3/32
+		}
+	}
+}
+`
+	const edit_config = `{
+	"vyatta-protocols:protocols": {
+		"vyatta-protocols-ospf:ospf": {
+			"area": [
+				{"network": ["1.1.1.1/32"], "tagnode": "0"},
+				{"tagnode": "2", "network": ["3.3.3.3/32"]}
+			]
+		}
+	}
+}`
+	srv, sess := TstStartupMultipleSchemas(t, edit_config_schema, config)
+	defer sess.Kill()
+	validateEditConfigJSON(t, false, sess, srv.Ctx, defop_merge,
+		EditConfigEncodingRFC7951, edit_config)
+	ValidateShow(t, sess, srv.Ctx, emptypath, true, expconfig, true)
+}
+
+func TestEditConfigJSONOperations(t *testing.T) {
+	const config = `protocols {
+	ospf {
+		area 0 {
+			network 10.1.1.0/24
+		}
+		area 1 {
+			network 2.2.2.2/32
+		}
+	}
+}
+`
+	const expconfig = `protocols {
+	ospf {
+		area 0 {
+			network 10.1.1.0/24
+		}
+		parameters {
+			opaque-lsa
+		}
+	}
+}
+`
+	const edit_config = `{
+	"vyatta-protocols:protocols": {
+		"vyatta-protocols-ospf:ospf": {
+			"area": [
+				{"tagnode": "1", "@": {"ietf-netconf:operation": "delete"}}
+			],
+			"parameters": {
+				"opaque-lsa": [null],
+				"@opaque-lsa": {"ietf-netconf:operation": "create"}
+			}
+		}
+	}
+}`
+	srv, sess := TstStartupMultipleSchemas(t, edit_config_schema, config)
+	defer sess.Kill()
+	validateEditConfigJSON(t, false, sess, srv.Ctx, defop_none,
+		EditConfigEncodingRFC7951, edit_config)
+	ValidateShow(t, sess, srv.Ctx, emptypath, true, expconfig, true)
+}
+
+func TestEditConfigJSONMergePatchRemoves(t *testing.T) {
+	const config = `protocols {
+	ospf {
+		area 0 {
+			network 10.1.1.0/24
+		}
+		parameters {
+			abr-type ibm
+		}
+	}
+}
+`
+	const expconfig = `protocols {
+	ospf {
+		area 0 {
+			network 10.1.1.0/24
+		}
+		parameters {
+			router-id FOO
+		}
+	}
+}
+`
+	const edit_config = `{
+	"protocols": {
+		"ospf": {
+			"parameters": {
+				"abr-type": null,
+				"router-id": "1.1.1.1"
+			}
+		}
+	}
+}`
+	srv, sess := TstStartupMultipleSchemas(t, edit_config_schema, config)
+	defer sess.Kill()
+	validateEditConfigJSON(t, false, sess, srv.Ctx, defop_merge,
+		EditConfigEncodingJSON, edit_config)
+	ValidateShow(t, sess, srv.Ctx, emptypath, true, expconfig, true)
+}
+
+func TestEditConfigJSONErrors(t *testing.T) {
+	srv, sess := TstStartupMultipleSchemas(t, edit_config_schema, emptyconfig)
+	defer sess.Kill()
+
+	for _, test := range []struct {
+		name, encoding, config string
+	}{
+		{"unqualified", EditConfigEncodingRFC7951,
+			`{"protocols": {}}`},
+		{"unknown module", EditConfigEncodingRFC7951,
+			`{"no-such-module:protocols": {}}`},
+		{"unknown element", EditConfigEncodingJSON,
+			`{"protocols": {"bgp": {}}}`},
+		{"bad operation", EditConfigEncodingJSON,
+			`{"protocols": {"@": {"ietf-netconf:operation": "frob"}}}`},
+		{"malformed", EditConfigEncodingJSON, `{"protocols": `},
+		{"bad encoding", "yaml", `{}`},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			validateEditConfigJSON(t, true, sess, srv.Ctx, defop_merge,
+				test.encoding, test.config)
+		})
+	}
+	ValidateShow(t, sess, srv.Ctx, emptypath, true, emptyconfig, true)
+}
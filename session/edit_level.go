@@ -0,0 +1,46 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session
+
+import (
+	"sync"
+
+	"github.com/danos/utils/pathutil"
+)
+
+// Edit level
+//
+// The edit, up and top commands move a session's edit level, below which
+// relative paths are given.  The session holds it, rather than only the
+// shell, so the environment and prompt the shell is given always agree
+// with the session, whichever client moved it.  It starts at the top.
+
+type editLevel struct {
+	mu   sync.Mutex
+	path []string
+}
+
+func (l *editLevel) set(path []string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.path = pathutil.Copypath(path)
+}
+
+func (l *editLevel) get() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return pathutil.Copypath(l.path)
+}
+
+// SetEditLevel moves the session's edit level to path, the top level if
+// it is empty.
+func (s *Session) SetEditLevel(path []string) {
+	s.s.editLevel.set(path)
+}
+
+// EditLevel returns a copy of the session's edit level.
+func (s *Session) EditLevel() []string {
+	return s.s.editLevel.get()
+}
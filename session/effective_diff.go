@@ -0,0 +1,46 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session
+
+import (
+	"github.com/danos/config/union"
+	"github.com/danos/configd"
+)
+
+// Effective changes
+//
+// A change may leave the explicitly configured values alone and only alter
+// the defaults in effect, eg enabling a feature whose presence changes the
+// defaults of the nodes beneath it.  Comparing explicit configuration then
+// shows no changes, although committing would change the system's
+// behaviour.  We therefore also compare the configuration with defaults
+// included before deciding there's nothing to commit.
+
+// effectiveShow - the whole of the tree, with defaults and secrets, as
+// the system would see it.  Reading is forced as this is for internal
+// comparison, not for display.
+func (s *session) effectiveShow(ctx *configd.Context, ut union.Node) (string, error) {
+	sysctx := *ctx
+	sysctx.Configd = true
+	return ut.Show(nil,
+		union.Authorizer(s.newShowSecAuther(&sysctx)),
+		union.IncludeDefaults,
+		union.ForceShowSecrets)
+}
+
+// effectiveChanged - does the candidate differ from running once defaults
+// are taken into account?
+func (s *session) effectiveChanged(ctx *configd.Context) bool {
+	cand, err := s.effectiveShow(ctx, s.getUnion())
+	if err != nil {
+		return false
+	}
+	run, err := s.effectiveShow(ctx,
+		union.NewNode(nil, s.viewRunning(), s.schema, nil, 0))
+	if err != nil {
+		return false
+	}
+	return cand != run
+}
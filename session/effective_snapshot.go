@@ -0,0 +1,65 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session
+
+import (
+	"github.com/danos/config/data"
+	"github.com/danos/config/union"
+)
+
+// EFFECTIVE snapshots
+//
+// A commit applies its changes to the EFFECTIVE session as each action
+// script succeeds, so scripts reading EFFECTIVE used to see whatever part
+// of the commit had been applied so far.  Instead, while a commit runs,
+// reads of EFFECTIVE are served from a snapshot taken as each phase of the
+// commit starts, while the commit itself keeps working on the live tree:
+//
+//	validation             - the configuration before the commit
+//	components and scripts - the configuration before the commit
+//	post-commit hooks      - the committed configuration
+//
+// A snapshot is a merged tree, so is read without reference to running,
+// which the commit replaces once the changes have been applied.
+
+// snapshot takes a snapshot of the session's tree for reads to be served
+// from, or drops it if take isn't set.
+func (s *session) snapshot(take bool) {
+	s.snapshotTree = nil
+	if take {
+		s.snapshotTree = s.getUnion().MergeWithoutDefaults()
+	}
+}
+
+// viewSnapshot makes reads see the snapshot, if there is one, until
+// viewLive is called.
+func (s *session) viewSnapshot() {
+	if s.snapshotTree == nil {
+		return
+	}
+	s.snapshotView = true
+	s.invalidateDiff()
+}
+
+func (s *session) viewLive() {
+	if !s.snapshotView {
+		return
+	}
+	s.snapshotView = false
+	s.invalidateDiff()
+}
+
+func (s *session) getSnapshotUnion() union.Node {
+	return union.NewNode(nil, s.snapshotTree, s.schema, nil, 0)
+}
+
+// snapshotRunning returns the running tree reads are compared against;
+// while the snapshot is viewed it is its own running tree.
+func (s *session) snapshotRunning(running *data.Node) *data.Node {
+	if s.snapshotView {
+		return s.snapshotTree
+	}
+	return running
+}
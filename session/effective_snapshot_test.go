@@ -0,0 +1,66 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session_test
+
+import (
+	"testing"
+
+	. "github.com/danos/configd/session/sessiontest"
+)
+
+const snapshotSchema = `
+container testcontainer {
+	leaf one {
+		type string;
+	}
+	leaf two {
+		type string;
+	}
+}
+`
+
+func TestSnapshotHidesChanges(t *testing.T) {
+	srv, sess := TstStartup(t, snapshotSchema, emptyconfig)
+	defer sess.Kill()
+
+	one := []string{"testcontainer", "one", "foo"}
+	two := []string{"testcontainer", "two", "bar"}
+
+	rebaseSet(t, srv, sess, one...)
+	sess.Snapshot(srv.Ctx)
+	rebaseSet(t, srv, sess, two...)
+
+	if !sess.Exists(srv.Ctx, one) {
+		t.Fatalf("Snapshot is missing %v", one)
+	}
+	if sess.Exists(srv.Ctx, two) {
+		t.Fatalf("Snapshot shows %v, set after it was taken", two)
+	}
+
+	sess.ReleaseSnapshot(srv.Ctx)
+	if !sess.Exists(srv.Ctx, two) {
+		t.Fatalf("%v not seen once snapshot released", two)
+	}
+}
+
+func TestSnapshotHidesDeletes(t *testing.T) {
+	srv, sess := TstStartup(t, snapshotSchema, emptyconfig)
+	defer sess.Kill()
+
+	one := []string{"testcontainer", "one", "foo"}
+	rebaseSet(t, srv, sess, one...)
+	sess.Snapshot(srv.Ctx)
+	if err := sess.Delete(srv.Ctx, one); err != nil {
+		t.Fatalf("Unable to delete %v: %s", one, err)
+	}
+	if !sess.Exists(srv.Ctx, one) {
+		t.Fatalf("Snapshot is missing %v, deleted after it was taken", one)
+	}
+
+	sess.ReleaseSnapshot(srv.Ctx)
+	if sess.Exists(srv.Ctx, one) {
+		t.Fatalf("%v still seen once snapshot released", one)
+	}
+}
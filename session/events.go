@@ -0,0 +1,128 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session
+
+import (
+	"encoding/json"
+	"io"
+	"log/syslog"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/danos/configd"
+)
+
+// Session event log
+//
+// Session lifecycle events are written as JSON Lines (one JSON object per
+// line) so that simple external dashboards can follow what is happening
+// without needing any metrics infrastructure.  The sink is chosen at daemon
+// start:
+//
+//	syslog            - each event is logged at info level
+//	unix:<socket>     - events are sent to a listening unix stream socket
+//	<file>            - events are appended to the file
+//
+// No events are written unless a sink has been set.
+
+const (
+	EventSessionCreated   = "session-created"
+	EventSessionDestroyed = "session-destroyed"
+	EventSessionLocked    = "session-locked"
+	EventSessionUnlocked  = "session-unlocked"
+	EventCommitStarted    = "commit-started"
+	EventCommitFinished   = "commit-finished"
+	EventValidationFailed = "validation-failed"
+)
+
+type Event struct {
+	Time     string   `json:"time"`
+	Event    string   `json:"event"`
+	Instance string   `json:"instance,omitempty"`
+	Session  string   `json:"session"`
+	User     string   `json:"user,omitempty"`
+	Uid      uint32   `json:"uid"`
+	PeerUid  uint32   `json:"peer-uid"`
+	PeerGid  uint32   `json:"peer-gid"`
+	Pid      int32    `json:"pid"`
+	Ok       *bool    `json:"ok,omitempty"`
+	Errors   []string `json:"errors,omitempty"`
+}
+
+var eventLog struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// SetEventLogWriter sets where events are written; nil disables the event
+// log.
+func SetEventLogWriter(w io.Writer) {
+	eventLog.mu.Lock()
+	defer eventLog.mu.Unlock()
+	eventLog.w = w
+}
+
+// OpenEventLog opens the sink described by spec (see above) and starts
+// writing events to it.
+func OpenEventLog(spec string) error {
+	var w io.Writer
+	var err error
+	switch {
+	case spec == "syslog":
+		w, err = syslog.New(syslog.LOG_INFO|syslog.LOG_DAEMON,
+			"configd-events")
+	case strings.HasPrefix(spec, "unix:"):
+		w, err = net.Dial("unix", strings.TrimPrefix(spec, "unix:"))
+	default:
+		w, err = os.OpenFile(spec,
+			os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0640)
+	}
+	if err != nil {
+		return err
+	}
+	SetEventLogWriter(w)
+	return nil
+}
+
+func emitEvent(ctx *configd.Context, sid, event string, ok *bool, errs []error) {
+	eventLog.mu.Lock()
+	defer eventLog.mu.Unlock()
+	if eventLog.w == nil {
+		return
+	}
+
+	ev := &Event{
+		Time:    time.Now().Format(time.RFC3339Nano),
+		Event:   event,
+		Session: sid,
+		User:    ctx.User,
+		Uid:     ctx.Uid,
+		PeerUid: ctx.PeerUid,
+		PeerGid: ctx.PeerGid,
+		Pid:     ctx.Pid,
+		Ok:      ok,
+	}
+	if ctx.Config != nil {
+		ev.Instance = ctx.Config.Instance
+	}
+	for _, err := range errs {
+		if err != nil {
+			ev.Errors = append(ev.Errors, err.Error())
+		}
+	}
+	buf, err := json.Marshal(ev)
+	if err != nil {
+		return
+	}
+	// Events are best effort, a failing sink mustn't affect sessions.
+	eventLog.w.Write(append(buf, '\n'))
+}
+
+func emitResultEvent(ctx *configd.Context, sid, event string, ok bool, errs []error) {
+	emitEvent(ctx, sid, event, &ok, errs)
+}
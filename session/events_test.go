@@ -0,0 +1,85 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	. "github.com/danos/configd/session"
+	. "github.com/danos/configd/session/sessiontest"
+)
+
+const eventsSchema = `
+container testcontainer {
+	leaf testleaf {
+		type string;
+	}
+}
+`
+
+func readEvents(t *testing.T, buf *bytes.Buffer) []Event {
+	var events []Event
+	scanner := bufio.NewScanner(buf)
+	for scanner.Scan() {
+		var ev Event
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			t.Fatalf("Invalid event %s: %s", scanner.Text(), err)
+		}
+		events = append(events, ev)
+	}
+	return events
+}
+
+func TestSessionEvents(t *testing.T) {
+	srv, sess := TstStartup(t, eventsSchema, emptyconfig)
+	defer sess.Kill()
+
+	var buf bytes.Buffer
+	SetEventLogWriter(&buf)
+	defer SetEventLogWriter(nil)
+
+	evSess, err := srv.Smgr.Create(srv.Ctx, "events", srv.Cmgr, srv.Ms,
+		srv.MsFull, false)
+	if err != nil {
+		t.Fatalf("Unable to create session: %s", err)
+	}
+	if _, err := srv.Smgr.Lock(srv.Ctx, "events"); err != nil {
+		t.Fatalf("Unable to lock session: %s", err)
+	}
+	if _, err := srv.Smgr.Unlock(srv.Ctx, "events"); err != nil {
+		t.Fatalf("Unable to unlock session: %s", err)
+	}
+	ValidateSet(t, evSess, srv.Ctx,
+		[]string{"testcontainer", "testleaf", "foo"}, false)
+	if _, errs, ok := evSess.Commit(srv.Ctx, "", false); !ok {
+		t.Fatalf("Unable to commit: %v", errs)
+	}
+	if err := srv.Smgr.Destroy(srv.Ctx, "events"); err != nil {
+		t.Fatalf("Unable to destroy session: %s", err)
+	}
+
+	var names []string
+	for _, ev := range readEvents(t, &buf) {
+		if ev.Session != "events" {
+			t.Fatalf("Unexpected session in event: %v", ev)
+		}
+		names = append(names, ev.Event)
+	}
+	exp := []string{
+		EventSessionCreated,
+		EventSessionLocked,
+		EventSessionUnlocked,
+		EventCommitStarted,
+		EventCommitFinished,
+		EventSessionDestroyed,
+	}
+	if !reflect.DeepEqual(names, exp) {
+		t.Fatalf("Unexpected events.\nExp: %v\nGot: %v", exp, names)
+	}
+}
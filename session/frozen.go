@@ -0,0 +1,153 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"sort"
+	"sync"
+
+	"github.com/danos/configd"
+	"github.com/danos/configd/rpc"
+	"github.com/danos/mgmterror"
+	"github.com/danos/utils/pathutil"
+)
+
+// Frozen paths
+//
+// Change control of sensitive areas of the configuration, eg system login,
+// relied on every user with access to them following procedure.  A
+// superuser may now freeze a path, after which only superusers, and
+// configd itself, may change anything at or below it until it is
+// unfrozen.  Deleting an ancestor of a frozen path is refused too, as it
+// would remove the frozen configuration.  The check is made by the
+// session's Auther, so applies to set, delete, load and edit-config alike.
+// Like annotations, frozen paths are kept in a file alongside the runfile.
+
+type frozenPaths struct {
+	mu    sync.Mutex
+	paths map[string]rpc.FrozenPath
+}
+
+func newFrozenPaths() *frozenPaths {
+	return &frozenPaths{paths: make(map[string]rpc.FrozenPath)}
+}
+
+func frozenFileForRunfile(runfile string) string {
+	return runfile + ".frozen"
+}
+
+// covers reports whether changing path would change frozen configuration,
+// counting its descendants too if it is being deleted.
+func (f *frozenPaths) covers(path []string, deleting bool) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for pstr := range f.paths {
+		frozen := pathutil.Makepath(pstr)
+		if pathIsPrefix(frozen, path) ||
+			deleting && pathIsPrefix(path, frozen) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *frozenPaths) write(file string) error {
+	f.mu.Lock()
+	buf, err := json.Marshal(f.paths)
+	f.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, buf, 0600)
+}
+
+func (f *frozenPaths) read(file string) error {
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	paths := make(map[string]rpc.FrozenPath)
+	if err := json.Unmarshal(buf, &paths); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	f.paths = paths
+	f.mu.Unlock()
+	return nil
+}
+
+// frozenFor reports whether the change to path is refused to the caller.
+func (m *CommitMgr) frozenFor(
+	ctx *configd.Context,
+	path []string,
+	deleting bool,
+) bool {
+	if ctx.Configd || ctx.Superuser {
+		return false
+	}
+	return m.frozen.covers(path, deleting)
+}
+
+func (s *session) frozenFor(
+	ctx *configd.Context,
+	path []string,
+	deleting bool,
+) bool {
+	if s.cmgr == nil {
+		return false
+	}
+	return s.cmgr.frozenFor(ctx, path, deleting)
+}
+
+func newFrozenError(path []string) error {
+	err := mgmterror.NewAccessDeniedApplicationError()
+	err.Path = pathutil.Pathstr(path)
+	err.Message = "Configuration is frozen"
+	return err
+}
+
+// LoadFrozenPaths restores the paths frozen before configd was restarted.
+func (m *CommitMgr) LoadFrozenPaths(runfile string) error {
+	return m.frozen.read(frozenFileForRunfile(runfile))
+}
+
+func (m *CommitMgr) FreezePath(runfile string, frozen rpc.FrozenPath) error {
+	m.frozen.mu.Lock()
+	m.frozen.paths[frozen.Path] = frozen
+	m.frozen.mu.Unlock()
+	return m.frozen.write(frozenFileForRunfile(runfile))
+}
+
+// UnfreezePath returns false if the path wasn't frozen.
+func (m *CommitMgr) UnfreezePath(runfile string, path []string) (bool, error) {
+	pstr := pathutil.Pathstr(path)
+	m.frozen.mu.Lock()
+	_, ok := m.frozen.paths[pstr]
+	delete(m.frozen.paths, pstr)
+	m.frozen.mu.Unlock()
+	if !ok {
+		return false, nil
+	}
+	return true, m.frozen.write(frozenFileForRunfile(runfile))
+}
+
+// FrozenPaths returns the frozen paths, in order.
+func (m *CommitMgr) FrozenPaths() []rpc.FrozenPath {
+	m.frozen.mu.Lock()
+	defer m.frozen.mu.Unlock()
+	out := make([]rpc.FrozenPath, 0, len(m.frozen.paths))
+	for _, frozen := range m.frozen.paths {
+		out = append(out, frozen)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+	return out
+}
+
+// IsFrozen reports whether path is at or below a frozen path.
+func (m *CommitMgr) IsFrozen(path []string) bool {
+	return m.frozen.covers(path, false)
+}
@@ -0,0 +1,48 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session
+
+import (
+	"sync"
+	"time"
+)
+
+// Last failure
+//
+// The errors from a failed validation or commit are printed once, and in a
+// long commit they scroll away before they can be read.  Each session
+// keeps the errors from its most recent failure, until the next one, so
+// they can be fetched again.
+
+type Failure struct {
+	Operation string
+	Time      time.Time
+	Errors    []error
+}
+
+type lastFailure struct {
+	mu      sync.Mutex
+	failure *Failure
+}
+
+// SetLastFailure records the errors from a failed operation, such as
+// validate or commit.
+func (s *Session) SetLastFailure(op string, errs []error) {
+	s.s.lastFailure.mu.Lock()
+	defer s.s.lastFailure.mu.Unlock()
+	s.s.lastFailure.failure = &Failure{
+		Operation: op,
+		Time:      time.Now(),
+		Errors:    append([]error(nil), errs...),
+	}
+}
+
+// LastFailure returns the session's most recent failure, or nil if it has
+// had none.
+func (s *Session) LastFailure() *Failure {
+	s.s.lastFailure.mu.Lock()
+	defer s.s.lastFailure.mu.Unlock()
+	return s.s.lastFailure.failure
+}
@@ -11,6 +11,8 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"os"
 
 	"github.com/danos/config/data"
 	"github.com/danos/config/load"
@@ -32,10 +34,34 @@ func (l lderrs) Error() string {
 	return b.String()
 }
 
-func (s *session) readFile(file string, r io.Reader) (union.Node, error, []error) {
+// readFile returns the configuration in file, or read from r, and the
+// comments in it (see comments.go).
+func (s *session) readFile(
+	file string, r io.Reader,
+) (union.Node, nodeComments, error, []error) {
 	var err error
 	var can *data.Node
 	var invalidPaths []error
+	var comments nodeComments
+
+	if r == nil {
+		if f, oerr := os.Open(file); oerr == nil {
+			defer f.Close()
+			r = f
+		}
+	}
+	if r != nil {
+		// Secrets may be encrypted, see secret_crypt.go
+		if r, err = DecryptConfigReader(r); err != nil {
+			return nil, nil, err, nil
+		}
+		buf, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, nil, err, nil
+		}
+		comments = parseComments(s.schema, string(buf))
+		r = bytes.NewReader(buf)
+	}
 
 	if r == nil {
 		can, err, invalidPaths = load.Load(file, s.schema)
@@ -43,27 +69,36 @@ func (s *session) readFile(file string, r io.Reader) (union.Node, error, []error
 		can, err, invalidPaths = load.LoadFile(file, r, s.schema)
 	}
 	if err != nil {
-		return nil, err, invalidPaths
+		return nil, nil, err, invalidPaths
 	}
-	return union.NewNode(nil, can, s.schema, nil, 0), nil, invalidPaths
+	return union.NewNode(nil, can, s.schema, nil, 0), comments, nil,
+		invalidPaths
 }
 
 func (s *session) merge(ctx *configd.Context, file string, r io.Reader) (error, []error) {
-	ltree, err, invalidPaths := s.readFile(file, r)
+	ltree, comments, err, invalidPaths := s.readFile(file, r)
 	if err != nil {
 		return err, invalidPaths
 	}
 
-	return s.merge_tree(ctx, ltree), invalidPaths
+	if err := s.merge_tree(ctx, ltree); err != nil {
+		return err, invalidPaths
+	}
+	s.loadComments(comments, false)
+	return nil, invalidPaths
 }
 
 func (s *session) load(ctx *configd.Context, file string, r io.Reader) (error, []error) {
-	ltree, err, invalidPaths := s.readFile(file, r)
+	ltree, comments, err, invalidPaths := s.readFile(file, r)
 	if err != nil {
 		return err, invalidPaths
 	}
 
-	return s.delete_then_merge_tree(ctx, ltree), invalidPaths
+	if err := s.delete_then_merge_tree(ctx, ltree); err != nil {
+		return err, invalidPaths
+	}
+	s.loadComments(comments, true)
+	return nil, invalidPaths
 }
 
 func (s *session) loadFromStringUsingEncoding(
@@ -145,6 +180,9 @@ func (s *session) delete_then_merge_tree(
 	ctx *configd.Context,
 	ltree union.Node,
 ) error {
+	// Whatever was in the session is replaced, so its changes are now
+	// relative to the current running configuration.
+	s.resetBase()
 	stree := s.getUnion()
 
 	stree.Delete(s.newAuther(ctx), []string{} /* unused */, union.CheckAuth)
@@ -0,0 +1,81 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session
+
+import (
+	"time"
+
+	"github.com/danos/configd"
+	"github.com/danos/mgmterror"
+)
+
+// Lock owners
+//
+// A session lock only recorded the pid holding it, so finding out who
+// held it, and why, meant hunting for the process, and a lock left behind
+// by a process that died could only be cleared by killing the session.
+// Each lock now records the user holding it, when it was taken and,
+// optionally, why.  A user lock whose process no longer exists is released
+// as soon as another process comes across it, and a superuser may force a
+// session unlocked.  Locks taken by configd itself, such as for a commit,
+// are never released early.
+
+type LockInfo struct {
+	Pid    int32     `json:"pid"`
+	User   string    `json:"user,omitempty"`
+	Time   time.Time `json:"time"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+func (s *session) setLockInfo(ctx *configd.Context, reason string) {
+	s.lockInfo = &LockInfo{
+		Pid:    ctx.Pid,
+		User:   ctx.User,
+		Time:   time.Now(),
+		Reason: reason,
+	}
+}
+
+// getLockInfo returns a copy of who holds the lock, or nil if the session
+// is unlocked.
+func (s *session) getLockInfo() *LockInfo {
+	switch {
+	case s.lpid == 0:
+		return nil
+	case s.lpid < 0:
+		return &LockInfo{Pid: s.lpid, User: configd.LockId(s.lpid).String()}
+	case s.lockInfo == nil || s.lockInfo.Pid != s.lpid:
+		return &LockInfo{Pid: s.lpid}
+	}
+	info := *s.lockInfo
+	return &info
+}
+
+// expireLock releases a user lock held by a process, other than pid, that
+// no longer exists, returning true if it did.
+func (s *session) expireLock(pid int32) bool {
+	if s.lpid <= 0 || s.lpid == pid || processAlive(s.lpid) {
+		return false
+	}
+	s.lpid = 0
+	s.lockInfo = nil
+	s.lockExpired = true
+	return true
+}
+
+func (s *session) forceUnlock() (int32, error) {
+	pid := s.lpid
+	switch {
+	case pid == 0:
+		err := mgmterror.NewOperationFailedProtocolError()
+		err.Message = "session is not locked"
+		return pid, err
+	case pid < 0:
+		return pid, lockDenied(configd.LockId(pid).String())
+	}
+	s.lpid = 0
+	s.lockInfo = nil
+	return pid, nil
+}
@@ -0,0 +1,107 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session_test
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/danos/configd"
+	. "github.com/danos/configd/session/sessiontest"
+)
+
+func newLockTestCtx(srv *TstSrv, pid int32, user string) *configd.Context {
+	return &configd.Context{
+		Pid:  pid,
+		User: user,
+		Auth: srv.Auth,
+		Dlog: srv.Dlog,
+		Elog: srv.Elog,
+	}
+}
+
+// exitedPid returns the pid of a process that has exited.
+func exitedPid(t *testing.T) int32 {
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Unable to run process: %s", err)
+	}
+	return int32(cmd.Process.Pid)
+}
+
+func TestLockInfo(t *testing.T) {
+	srv, sess := TstStartup(t, emptyschema, emptyconfig)
+	defer sess.Kill()
+	ctx := newLockTestCtx(srv, int32(os.Getpid()), "alice")
+
+	if info, _ := sess.LockInfo(ctx); info != nil {
+		t.Fatalf("Unlocked session has lock info: %+v", info)
+	}
+	if _, err := sess.LockWithReason(ctx, "maintenance"); err != nil {
+		t.Fatalf("Unable to lock session: %s", err)
+	}
+	info, err := sess.LockInfo(ctx)
+	if err != nil || info == nil {
+		t.Fatalf("No lock info: %v", err)
+	}
+	if info.Pid != ctx.Pid || info.User != "alice" ||
+		info.Reason != "maintenance" || info.Time.IsZero() {
+		t.Fatalf("Unexpected lock info: %+v", info)
+	}
+
+	if _, err := sess.Unlock(ctx); err != nil {
+		t.Fatalf("Unable to unlock session: %s", err)
+	}
+	if info, _ := sess.LockInfo(ctx); info != nil {
+		t.Fatalf("Unlocked session has lock info: %+v", info)
+	}
+}
+
+func TestLockExpiresWithProcess(t *testing.T) {
+	srv, sess := TstStartup(t, emptyschema, emptyconfig)
+	defer sess.Kill()
+
+	dead := newLockTestCtx(srv, exitedPid(t), "alice")
+	if _, err := sess.Lock(dead); err != nil {
+		t.Fatalf("Unable to lock session: %s", err)
+	}
+	// The owner itself still sees its lock.
+	if pid, _ := sess.Locked(dead); pid != dead.Pid {
+		t.Fatalf("Session not locked by %d, but by %d", dead.Pid, pid)
+	}
+
+	ctx := newLockTestCtx(srv, int32(os.Getpid()), "bob")
+	if pid, _ := sess.Locked(ctx); pid != 0 {
+		t.Fatalf("Lock of exited process not released, locked by %d", pid)
+	}
+	if _, err := sess.Lock(ctx); err != nil {
+		t.Fatalf("Unable to lock session: %s", err)
+	}
+}
+
+func TestForceUnlock(t *testing.T) {
+	srv, sess := TstStartup(t, emptyschema, emptyconfig)
+	defer sess.Kill()
+
+	owner := newLockTestCtx(srv, int32(os.Getpid()), "alice")
+	if _, err := sess.Lock(owner); err != nil {
+		t.Fatalf("Unable to lock session: %s", err)
+	}
+	other := newLockTestCtx(srv, 1, "root")
+	pid, err := sess.ForceUnlock(other)
+	if err != nil {
+		t.Fatalf("Unable to force unlock: %s", err)
+	}
+	if pid != owner.Pid {
+		t.Fatalf("Unlocked lock of %d, expected %d", pid, owner.Pid)
+	}
+	if pid, _ := sess.Locked(other); pid != 0 {
+		t.Fatalf("Session still locked by %d", pid)
+	}
+	if _, err := sess.ForceUnlock(other); err == nil {
+		t.Fatal("Force unlocked an unlocked session")
+	}
+}
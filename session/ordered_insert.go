@@ -0,0 +1,201 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/danos/config/schema"
+	"github.com/danos/config/union"
+	"github.com/danos/configd"
+	"github.com/danos/mgmterror"
+	"github.com/danos/utils/pathutil"
+)
+
+// User ordered lists and leaf-lists
+//
+// Entries of a list or leaf-list ordered by the system are always shown
+// sorted, however they were set, loaded or edited.  Those of one ordered
+// by the user are kept in the order they were created, new ones going
+// last.  An edit-config may instead say where an entry goes with the
+// insert attribute of RFC 6020 section 7.7.7 and 7.8.6, in XML
+//
+//	<addr yang:insert="before" yang:value="10.0.0.2">10.0.0.1</addr>
+//	<rule yang:insert="after" yang:key="[name='allow-ssh']">
+//	  <name>allow-dns</name>
+//	  ...
+//	</rule>
+//
+// where yang is urn:ietf:params:xml:ns:yang:1, or in JSON with "yang:insert"
+// and "yang:value" or "yang:key" in the entry's metadata.  Insert may be
+// "first", "last", "before" or "after", the last two needing the value, or
+// key, of the entry it goes before or after.  Keys may be given as a
+// predicate, as above, or just as the key's value.  An entry that already
+// exists is moved, taking everything below it with it.
+
+const (
+	insertFirst  = "first"
+	insertLast   = "last"
+	insertBefore = "before"
+	insertAfter  = "after"
+)
+
+type orderedBy interface {
+	OrdBy() string
+}
+
+func orderedByUser(sch schema.Node) bool {
+	o, ok := sch.(orderedBy)
+	return ok && o.OrdBy() == "user"
+}
+
+func newInsertError(path []string, msg string) error {
+	err := mgmterror.NewInvalidValueApplicationError()
+	err.Path = pathutil.Pathstr(path)
+	err.Message = msg
+	return err
+}
+
+// checkInsert returns an error if the insert attribute, and value, can't be
+// given for the node at path.
+func checkInsert(sch schema.Node, path []string, insert, value string) error {
+	what := "value"
+	switch sch.(type) {
+	case schema.LeafList:
+	case schema.List:
+		what = "key"
+	default:
+		return newInsertError(path, "insert is only allowed on lists "+
+			"and leaf-lists ordered by the user")
+	}
+	if !orderedByUser(sch) {
+		return newInsertError(path, "insert is only allowed on lists "+
+			"and leaf-lists ordered by the user")
+	}
+	switch insert {
+	case insertFirst, insertLast:
+	case insertBefore, insertAfter:
+		if value == "" {
+			return newInsertError(path,
+				fmt.Sprintf("insert %s needs a %s", insert, what))
+		}
+	default:
+		return newInsertError(path,
+			fmt.Sprintf("Unknown insert %s", insert))
+	}
+	return nil
+}
+
+// A key predicate, [name='value'], optionally with a prefix on the name
+var insertKeyPredicate = regexp.MustCompile(
+	`^\[\s*(?:[^:=\]\s]+:)?[^:=\]\s]+\s*=\s*(?:'([^']*)'|"([^"]*)")\s*\]$`)
+
+// insertKeyValue returns the value of the key of a list entry, as given by
+// the key attribute, which may be a predicate or just the value.
+func insertKeyValue(path []string, key string) (string, error) {
+	if !strings.HasPrefix(key, "[") {
+		return key, nil
+	}
+	m := insertKeyPredicate.FindStringSubmatch(key)
+	if m == nil {
+		return "", newInsertError(path,
+			fmt.Sprintf("Invalid insert key %s", key))
+	}
+	return m[1] + m[2], nil
+}
+
+// insertEntry returns entries, the entries of the list or leaf-list at
+// path, with entry put where insert, and point, say.
+func insertEntry(
+	path, entries []string,
+	entry, insert, point string,
+) ([]string, error) {
+	out := make([]string, 0, len(entries)+1)
+	for _, v := range entries {
+		if v != entry {
+			out = append(out, v)
+		}
+	}
+	switch insert {
+	case insertFirst:
+		return append([]string{entry}, out...), nil
+	case insertLast:
+		return append(out, entry), nil
+	}
+	for i, v := range out {
+		if v != point {
+			continue
+		}
+		if insert == insertAfter {
+			i++
+		}
+		out = append(out[:i], append([]string{entry}, out[i:]...)...)
+		return out, nil
+	}
+	return nil, newInsertError(path,
+		fmt.Sprintf("%s is not in the list to insert %s %s",
+			point, entry, insert))
+}
+
+func sameOrder(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// reposition moves the list or leaf-list entry just created, merged or
+// replaced to where the insert attribute says.
+func (e edit_op) reposition(ec edit_config) error {
+	switch e.op {
+	case op_merge, op_replace, op_create:
+	default:
+		return nil
+	}
+	return ec.sess.moveEntry(ec.ctx, e.path, e.insert, e.insertValue)
+}
+
+// moveEntry moves the list or leaf-list entry at path to where insert, and
+// point, say.  The entries are set again in their new order, as there is
+// no moving an entry in place.
+func (s *session) moveEntry(
+	ctx *configd.Context, path []string, insert, point string,
+) error {
+	listPath, entry := path[:len(path)-1], path[len(path)-1]
+	mcan := s.getUnion().MergeWithoutDefaults()
+	list := dataDescendant(mcan, listPath)
+	if list == nil {
+		return nil
+	}
+	var entries []string
+	for _, ch := range list.Children() {
+		entries = append(entries, ch.Name())
+	}
+	order, err := insertEntry(listPath, entries, entry, insert, point)
+	if err != nil {
+		return err
+	}
+	if sameOrder(order, entries) {
+		return nil
+	}
+
+	s.getUnion().Delete(s.newAuther(ctx), listPath, union.DontCheckAuth)
+	for _, name := range order {
+		entryPath := pathutil.CopyAppend(listPath, name)
+		for _, p := range dataLeafPaths(list.Child(name), entryPath, nil) {
+			if err := s._set(ctx, p); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
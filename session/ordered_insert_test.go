@@ -0,0 +1,252 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session_test
+
+import (
+	"testing"
+
+	. "github.com/danos/configd/session"
+	. "github.com/danos/configd/session/sessiontest"
+)
+
+const leafListInsertConfig = `protocols {
+	ospf {
+		area 0 {
+			network 10.0.0.1/32
+			network 10.0.0.2/32
+			network 10.0.0.3/32
+		}
+	}
+}
+`
+
+func leafListInsertEditConfig(networks string) string {
+	return `
+<config xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" xmlns:yang="urn:ietf:params:xml:ns:yang:1">
+<protocols xmlns="urn:vyatta.com:test:vyatta-protocols">
+  <ospf xmlns="urn:vyatta.com:test:vyatta-protocols-ospf">
+    <area>
+      <tagnode>0</tagnode>` + networks + `
+    </area>
+  </ospf>
+</protocols>
+</config>
+`
+}
+
+func leafListInsertExpConfig(networks ...string) string {
+	out := "protocols {\n\tospf {\n\t\tarea 0 {\n"
+	for _, n := range networks {
+		out += "\t\t\tnetwork " + n + "\n"
+	}
+	return out + "\t\t}\n\t}\n}\n"
+}
+
+func TestEditConfigLeafListInsert(t *testing.T) {
+	tests := []struct {
+		name     string
+		networks string
+		exp      []string
+	}{
+		{"first",
+			`<network yang:insert="first">10.0.0.4/32</network>`,
+			[]string{"10.0.0.4/32", "10.0.0.1/32", "10.0.0.2/32",
+				"10.0.0.3/32"}},
+		{"last",
+			`<network yang:insert="last">10.0.0.4/32</network>`,
+			[]string{"10.0.0.1/32", "10.0.0.2/32", "10.0.0.3/32",
+				"10.0.0.4/32"}},
+		{"before",
+			`<network yang:insert="before" yang:value="10.0.0.2/32">` +
+				`10.0.0.4/32</network>`,
+			[]string{"10.0.0.1/32", "10.0.0.4/32", "10.0.0.2/32",
+				"10.0.0.3/32"}},
+		{"after",
+			`<network yang:insert="after" yang:value="10.0.0.2/32">` +
+				`10.0.0.4/32</network>`,
+			[]string{"10.0.0.1/32", "10.0.0.2/32", "10.0.0.4/32",
+				"10.0.0.3/32"}},
+		{"move existing",
+			`<network yang:insert="first">10.0.0.3/32</network>`,
+			[]string{"10.0.0.3/32", "10.0.0.1/32", "10.0.0.2/32"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv, sess := TstStartupMultipleSchemas(t, edit_config_schema,
+				leafListInsertConfig)
+			defer sess.Kill()
+			validateEditConfig(t, false, sess, srv.Ctx, target_candidate,
+				defop_merge, testopt_testset, erropt_stop,
+				leafListInsertEditConfig(test.networks))
+			ValidateShow(t, sess, srv.Ctx, emptypath, true,
+				leafListInsertExpConfig(test.exp...), true)
+		})
+	}
+}
+
+func TestEditConfigLeafListInsertInvalid(t *testing.T) {
+	for _, networks := range []string{
+		`<network yang:insert="before">10.0.0.4/32</network>`,
+		`<network yang:insert="after" yang:value="10.0.0.9/32">` +
+			`10.0.0.4/32</network>`,
+		`<network yang:insert="middle">10.0.0.4/32</network>`,
+	} {
+		srv, sess := TstStartupMultipleSchemas(t, edit_config_schema,
+			leafListInsertConfig)
+		validateEditConfig(t, true, sess, srv.Ctx, target_candidate,
+			defop_merge, testopt_testset, erropt_stop,
+			leafListInsertEditConfig(networks))
+		sess.Kill()
+	}
+}
+
+func TestEditConfigJSONLeafListInsert(t *testing.T) {
+	const edit_config = `{
+	"vyatta-protocols:protocols": {
+		"vyatta-protocols-ospf:ospf": {
+			"area": [{
+				"tagnode": "0",
+				"network": ["10.0.0.4/32"],
+				"@network": [{"yang:insert": "before",
+					"yang:value": "10.0.0.1/32"}]
+			}]
+		}
+	}
+}`
+	srv, sess := TstStartupMultipleSchemas(t, edit_config_schema,
+		leafListInsertConfig)
+	defer sess.Kill()
+	validateEditConfigJSON(t, false, sess, srv.Ctx, defop_merge,
+		EditConfigEncodingRFC7951, edit_config)
+	ValidateShow(t, sess, srv.Ctx, emptypath, true,
+		leafListInsertExpConfig("10.0.0.4/32", "10.0.0.1/32",
+			"10.0.0.2/32", "10.0.0.3/32"), true)
+}
+
+const listInsertSchema = `
+container rules {
+	list rule {
+		key name;
+		ordered-by user;
+		leaf name {
+			type string;
+		}
+		leaf action {
+			type string;
+		}
+	}
+	list sorted {
+		key name;
+		leaf name {
+			type string;
+		}
+	}
+}
+`
+
+const listInsertConfig = `rules {
+	rule a {
+		action accept
+	}
+	rule b {
+		action drop
+	}
+	rule c
+}
+`
+
+func listInsertEditConfig(rules string) string {
+	return `
+<config xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" xmlns:yang="urn:ietf:params:xml:ns:yang:1">
+<rules xmlns="urn:vyatta.com:test:configd-session">` + rules + `
+</rules>
+</config>
+`
+}
+
+func TestEditConfigListInsert(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules string
+		exp   string
+	}{
+		{"first",
+			`<rule yang:insert="first"><name>d</name></rule>`,
+			"rule d\n\trule a {\n\t\taction accept\n\t}\n" +
+				"\trule b {\n\t\taction drop\n\t}\n\trule c\n"},
+		{"last",
+			`<rule yang:insert="last"><name>d</name></rule>`,
+			"rule a {\n\t\taction accept\n\t}\n" +
+				"\trule b {\n\t\taction drop\n\t}\n\trule c\n\trule d\n"},
+		{"before",
+			`<rule yang:insert="before" yang:key="[name='b']">` +
+				`<name>d</name><action>log</action></rule>`,
+			"rule a {\n\t\taction accept\n\t}\n" +
+				"\trule d {\n\t\taction log\n\t}\n" +
+				"\trule b {\n\t\taction drop\n\t}\n\trule c\n"},
+		{"after",
+			`<rule yang:insert="after" yang:key="[name='b']">` +
+				`<name>d</name></rule>`,
+			"rule a {\n\t\taction accept\n\t}\n" +
+				"\trule b {\n\t\taction drop\n\t}\n\trule d\n\trule c\n"},
+		{"move existing",
+			`<rule yang:insert="after" yang:key="c"><name>a</name></rule>`,
+			"rule b {\n\t\taction drop\n\t}\n\trule c\n" +
+				"\trule a {\n\t\taction accept\n\t}\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv, sess := TstStartup(t, listInsertSchema, listInsertConfig)
+			defer sess.Kill()
+			validateEditConfig(t, false, sess, srv.Ctx, target_candidate,
+				defop_merge, testopt_testset, erropt_stop,
+				listInsertEditConfig(test.rules))
+			ValidateShow(t, sess, srv.Ctx, emptypath, true,
+				"rules {\n\t"+test.exp+"}\n", true)
+		})
+	}
+}
+
+func TestEditConfigListInsertInvalid(t *testing.T) {
+	for _, rules := range []string{
+		`<rule yang:insert="before"><name>d</name></rule>`,
+		`<rule yang:insert="after" yang:key="[name='z']">` +
+			`<name>d</name></rule>`,
+		`<rule yang:insert="after" yang:key="[name=b]">` +
+			`<name>d</name></rule>`,
+		`<sorted yang:insert="first"><name>d</name></sorted>`,
+	} {
+		srv, sess := TstStartup(t, listInsertSchema, listInsertConfig)
+		validateEditConfig(t, true, sess, srv.Ctx, target_candidate,
+			defop_merge, testopt_testset, erropt_stop,
+			listInsertEditConfig(rules))
+		sess.Kill()
+	}
+}
+
+func TestEditConfigJSONListInsert(t *testing.T) {
+	const edit_config = `{
+	"rules": {
+		"rule": [{
+			"@": {"yang:insert": "before", "yang:key": "[name='a']"},
+			"name": "c"
+		}]
+	}
+}`
+	srv, sess := TstStartup(t, listInsertSchema, listInsertConfig)
+	defer sess.Kill()
+	validateEditConfigJSON(t, false, sess, srv.Ctx, defop_merge,
+		EditConfigEncodingJSON, edit_config)
+	ValidateShow(t, sess, srv.Ctx, emptypath, true, `rules {
+	rule c
+	rule a {
+		action accept
+	}
+	rule b {
+		action drop
+	}
+}
+`, true)
+}
@@ -0,0 +1,271 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/danos/config/load"
+	"github.com/danos/config/schema"
+	"github.com/danos/config/union"
+	"github.com/danos/configd"
+)
+
+// Session persistence
+//
+// Only the running configuration survives a configd restart, so a daemon
+// upgrade used to lose the uncommitted changes of every CLI session.  When
+// configd is run with -persist-sessions, each CLI session with changes, or
+// locked, is written to <sid>.json in the session directory after every
+// request that may change it, and removed once there is nothing left to
+// keep or the session is torn down.  At startup RestoreSessions recreates
+// the sessions from these files.  The whole session configuration is kept,
+// rather than just the changes, as loading it gives the same changes
+// against the running configuration, which a restart doesn't alter.  Locks
+// are only restored if the process holding them is still there.  Secrets
+// are encrypted with the secret key, if set, as in the runfile.
+
+const persistSuffix = ".json"
+
+type persistedSession struct {
+	Sid     string  `json:"sid"`
+	Owner   *uint32 `json:"owner,omitempty"`
+	Private bool    `json:"private,omitempty"`
+	Locked  int32   `json:"locked,omitempty"`
+	Saved   bool    `json:"saved,omitempty"`
+	Config  string  `json:"config"`
+
+	LockInfo *LockInfo         `json:"lock-info,omitempty"`
+	Comments map[string]string `json:"comments,omitempty"`
+}
+
+// persistFileForSession - persist file location for the given session, if
+// configd has been asked to persist sessions.
+func persistFileForSession(ctx *configd.Context, sid string) string {
+	if ctx.Config == nil || !ctx.Config.PersistSessions {
+		return ""
+	}
+	if file := activityFileForSession(ctx, sid); file != "" {
+		return file + persistSuffix
+	}
+	return ""
+}
+
+func WithPersistFile(file string) SessionOption {
+	return func(s *session) {
+		s.persistFile = file
+	}
+}
+
+// persistCtx returns the context of requests that may alter the persisted
+// state of the session, and nil for all others.
+func persistCtx(req request) *configd.Context {
+	if v, ok := req.(*marksavedreq); ok {
+		return v.ctx
+	}
+	return activityCtx(req)
+}
+
+// updatePersisted rewrites, or removes, the persisted session.  ctx, which
+// is only used to log failures, may be nil.
+func (s *session) updatePersisted(ctx *configd.Context) {
+	if s.persistFile == "" {
+		return
+	}
+	if !s.edited && s.lpid <= 0 {
+		s.removePersisted()
+		return
+	}
+	if err := s.writePersisted(); err != nil && ctx != nil && ctx.Elog != nil {
+		ctx.Elog.Printf("Unable to persist session %s: %s", s.sid, err)
+	}
+}
+
+// persistedConfig returns the session configuration to persist, with its
+// secrets encrypted if a secret key is set, as for the runfile.
+func (s *session) persistedConfig() (string, error) {
+	if currentSecretCrypter() == nil {
+		return s.getUnion().Show(nil, union.ForceShowSecrets)
+	}
+	enc, err := EncryptSecrets(s.schema, s.getUnion().MergeWithoutDefaults())
+	if err != nil {
+		return "", err
+	}
+	return union.NewNode(nil, enc, s.schema, nil, 0).Show(
+		nil, union.ForceShowSecrets)
+}
+
+func (s *session) writePersisted() error {
+	cfg, err := s.persistedConfig()
+	if err != nil {
+		return err
+	}
+	p := persistedSession{
+		Sid:     s.sid,
+		Owner:   s.owner,
+		Private: s.private,
+		Saved:   s.saved,
+		Config:  cfg,
+	}
+	if s.comments != nil {
+		p.Comments = s.comments
+	}
+	// Only user locks are of interest, as for the activity file.
+	if s.lpid > 0 {
+		p.Locked = s.lpid
+		p.LockInfo = s.lockInfo
+	}
+	buf, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	// The configuration includes secrets, in plain text unless a secret
+	// key is set, so only configd may read it, and it is renamed into
+	// place so a restart never sees it half written.
+	tmp := s.persistFile + ".tmp"
+	if err := ioutil.WriteFile(tmp, buf, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.persistFile)
+}
+
+func (s *session) removePersisted() {
+	if s.persistFile == "" {
+		return
+	}
+	os.Remove(s.persistFile)
+}
+
+// processAlive returns true if pid is a running process.
+func processAlive(pid int32) bool {
+	err := syscall.Kill(int(pid), 0)
+	return err == nil || err == syscall.EPERM
+}
+
+func (s *session) restore(ctx *configd.Context, p *persistedSession) error {
+	cfg, err := DecryptConfig([]byte(p.Config))
+	if err != nil {
+		return err
+	}
+	can, err, _ := load.LoadFile(s.sid, bytes.NewReader(cfg), s.schema)
+	if err != nil {
+		return err
+	}
+	ltree := union.NewNode(nil, can, s.schema, nil, 0)
+	if err := s.delete_then_merge_tree(ctx, ltree); err != nil {
+		return err
+	}
+	s.saved = p.Saved
+	if p.Comments != nil {
+		s.comments = nodeComments(p.Comments)
+	}
+	if p.Locked > 0 && processAlive(p.Locked) {
+		s.lpid = p.Locked
+		s.lockInfo = p.LockInfo
+	}
+	return nil
+}
+
+// restore replaces the session's configuration, lock and saved state with
+// those persisted.
+func (s *Session) restore(ctx *configd.Context, p *persistedSession) error {
+	respch := make(chan error)
+	req := &restorereq{
+		ctx:  ctx,
+		p:    p,
+		resp: respch,
+	}
+	select {
+	case s.s.reqch <- req:
+		return <-respch
+	case <-s.s.term:
+	}
+	return sessTermError()
+}
+
+func readPersistedSession(file string) (*persistedSession, error) {
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var p persistedSession
+	if err := json.Unmarshal(buf, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// RestoreSessions recreates the sessions persisted in the session
+// directory, returning the sids of those restored.  Files that can't be
+// restored, eg as the schema has since changed, are logged and removed.
+func (mgr *SessionMgr) RestoreSessions(
+	ctx *configd.Context, cmgr *CommitMgr, st, stFull schema.ModelSet,
+) []string {
+	if ctx.Config == nil || !ctx.Config.PersistSessions ||
+		ctx.Config.SessionActivityDir == "" {
+		return nil
+	}
+	files, _ := filepath.Glob(filepath.Join(ctx.Config.SessionActivityDir,
+		"*"+persistSuffix))
+
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+
+	var restored []string
+	for _, file := range files {
+		p, err := readPersistedSession(file)
+		if err == nil && persistFileForSession(ctx, p.Sid) != file {
+			err = fmt.Errorf("file is for session %s", p.Sid)
+		}
+		if err == nil {
+			err = mgr.restore(ctx, cmgr, st, stFull, p)
+		}
+		if err != nil {
+			mgr.Elog.Printf("Unable to restore session from %s: %s",
+				file, err)
+			os.Remove(file)
+			continue
+		}
+		restored = append(restored, p.Sid)
+	}
+	return restored
+}
+
+func (mgr *SessionMgr) restore(
+	ctx *configd.Context,
+	cmgr *CommitMgr,
+	st, stFull schema.ModelSet,
+	p *persistedSession,
+) error {
+	if _, ok := mgr.sessions[p.Sid]; ok {
+		return nil
+	}
+
+	opts := []SessionOption{WithPersistFile(persistFileForSession(ctx, p.Sid))}
+	if p.Owner != nil {
+		opts = append(opts, WithOwner(*p.Owner))
+		if file := activityFileForSession(ctx, p.Sid); file != "" {
+			opts = append(opts, WithActivityFile(file))
+		}
+	}
+	if p.Private {
+		opts = append(opts, WithPrivateCandidate())
+	}
+
+	sess := NewSession(p.Sid, cmgr, st, stFull, opts...)
+	if err := sess.restore(ctx, p); err != nil {
+		sess.Kill()
+		return err
+	}
+	mgr.sessions[p.Sid] = sess
+	return nil
+}
@@ -0,0 +1,178 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/danos/configd/session"
+	. "github.com/danos/configd/session/sessiontest"
+)
+
+func TestSessionPersistedAndRestored(t *testing.T) {
+	dir, err := ioutil.TempDir("", "configd-persist")
+	if err != nil {
+		t.Fatalf("Unable to create session dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	srv, _ := TstStartup(t, activitySchema, emptyconfig)
+	srv.Ctx.Config.SessionActivityDir = dir
+	srv.Ctx.Config.PersistSessions = true
+	srv.Ctx.Pid = int32(os.Getpid())
+
+	sid := "persist"
+	sess, err := srv.Smgr.Create(
+		srv.Ctx, sid, srv.Cmgr, srv.Ms, srv.MsFull, Unshared)
+	if err != nil {
+		t.Fatalf("Unable to create session: %s", err)
+	}
+	file := filepath.Join(dir, sid+".json")
+
+	if err := sess.Set(srv.Ctx,
+		[]string{"testcontainer", "testleaf", "foo"}); err != nil {
+		t.Fatalf("Unable to set path: %s", err)
+	}
+	if _, err := sess.Lock(srv.Ctx); err != nil {
+		t.Fatalf("Unable to lock session: %s", err)
+	}
+	sess.Locked(srv.Ctx)
+	if _, err := os.Stat(file); err != nil {
+		t.Fatalf("Session not persisted: %s", err)
+	}
+
+	// As if configd had restarted
+	smgr := NewSessionMgrCustomLog(log.New(ioutil.Discard, "", 0))
+	restored := smgr.RestoreSessions(srv.Ctx, srv.Cmgr, srv.Ms, srv.MsFull)
+	if len(restored) != 1 || restored[0] != sid {
+		t.Fatalf("Unexpected sessions restored: %v", restored)
+	}
+	rsess, err := smgr.Get(srv.Ctx, sid)
+	if err != nil {
+		t.Fatalf("Restored session not found: %s", err)
+	}
+	if !rsess.Changed(srv.Ctx) {
+		t.Fatalf("Restored session has no changes")
+	}
+	out, _ := rsess.Show(srv.Ctx, []string{}, false, false)
+	if !strings.Contains(out, "testleaf foo") {
+		t.Fatalf("Restored session is missing changes:\n%s", out)
+	}
+	if lpid, _ := rsess.Locked(srv.Ctx); lpid != srv.Ctx.Pid {
+		t.Fatalf("Restored session lock: expected %d, got %d",
+			srv.Ctx.Pid, lpid)
+	}
+
+	// Nothing left to keep once discarded and unlocked
+	if err := rsess.Discard(srv.Ctx); err != nil {
+		t.Fatalf("Unable to discard session: %s", err)
+	}
+	if _, err := rsess.Unlock(srv.Ctx); err != nil {
+		t.Fatalf("Unable to unlock session: %s", err)
+	}
+	rsess.Locked(srv.Ctx)
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Fatalf("Persisted session not removed")
+	}
+}
+
+func TestSessionPersistedLockForceUnlocked(t *testing.T) {
+	dir, err := ioutil.TempDir("", "configd-persist")
+	if err != nil {
+		t.Fatalf("Unable to create session dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	srv, _ := TstStartup(t, activitySchema, emptyconfig)
+	srv.Ctx.Config.SessionActivityDir = dir
+	srv.Ctx.Config.PersistSessions = true
+	srv.Ctx.Pid = int32(os.Getpid())
+
+	sid := "forced"
+	sess, err := srv.Smgr.Create(
+		srv.Ctx, sid, srv.Cmgr, srv.Ms, srv.MsFull, Unshared)
+	if err != nil {
+		t.Fatalf("Unable to create session: %s", err)
+	}
+	defer srv.Smgr.Destroy(srv.Ctx, sid)
+	file := filepath.Join(dir, sid+".json")
+
+	if _, err := sess.Lock(srv.Ctx); err != nil {
+		t.Fatalf("Unable to lock session: %s", err)
+	}
+	sess.Locked(srv.Ctx)
+	if _, err := os.Stat(file); err != nil {
+		t.Fatalf("Locked session not persisted: %s", err)
+	}
+
+	// Otherwise a restart would lock the session again.
+	if _, err := sess.ForceUnlock(newLockTestCtx(srv, 1, "root")); err != nil {
+		t.Fatalf("Unable to force unlock: %s", err)
+	}
+	sess.Locked(srv.Ctx)
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Fatalf("Force unlocked session still persisted")
+	}
+}
+
+func TestSessionPersistedSecretsEncrypted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "configd-persist")
+	if err != nil {
+		t.Fatalf("Unable to create session dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := SetSecretKey(bytes.Repeat([]byte{0x5a}, 32)); err != nil {
+		t.Fatalf("Unable to set secret key: %s", err)
+	}
+	defer SetSecretKey(nil)
+
+	srv, _ := TstStartup(t, secretCryptSchema, emptyconfig)
+	srv.Ctx.Config.SessionActivityDir = dir
+	srv.Ctx.Config.PersistSessions = true
+
+	sid := "secrets"
+	sess, err := srv.Smgr.Create(
+		srv.Ctx, sid, srv.Cmgr, srv.Ms, srv.MsFull, Unshared)
+	if err != nil {
+		t.Fatalf("Unable to create session: %s", err)
+	}
+	if err := sess.Set(srv.Ctx,
+		[]string{"testcontainer", "password", "hunter2"}); err != nil {
+		t.Fatalf("Unable to set path: %s", err)
+	}
+	sess.Locked(srv.Ctx)
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, sid+".json"))
+	if err != nil {
+		t.Fatalf("Session not persisted: %s", err)
+	}
+	if strings.Contains(string(content), "hunter2") ||
+		!strings.Contains(string(content), "$configd-aes256gcm$") {
+		t.Fatalf("Secret not encrypted in persisted session:\n%s", content)
+	}
+
+	// As if configd had restarted
+	smgr := NewSessionMgrCustomLog(log.New(ioutil.Discard, "", 0))
+	restored := smgr.RestoreSessions(srv.Ctx, srv.Cmgr, srv.Ms, srv.MsFull)
+	if len(restored) != 1 || restored[0] != sid {
+		t.Fatalf("Unexpected sessions restored: %v", restored)
+	}
+	rsess, err := smgr.Get(srv.Ctx, sid)
+	if err != nil {
+		t.Fatalf("Restored session not found: %s", err)
+	}
+	defer smgr.Destroy(srv.Ctx, sid)
+	out, _ := rsess.Get(srv.Ctx, []string{"testcontainer", "password"})
+	if len(out) != 1 || out[0] != "hunter2" {
+		t.Fatalf("Restored secret not decrypted: %v", out)
+	}
+}
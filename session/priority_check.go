@@ -0,0 +1,80 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session
+
+import (
+	"fmt"
+
+	"github.com/danos/config/diff"
+	"github.com/danos/config/schema"
+	"github.com/danos/mgmterror"
+	"github.com/danos/utils/pathutil"
+)
+
+// Priority ordering checks
+//
+// A node is always created after, and deleted before, its parent, so a
+// configd:priority lower than that of an ancestor being created or deleted
+// in the same commit can't be honoured; the commit silently processes the
+// node along with its ancestor instead.  Action scripts that rely on the
+// declared order then fail at runtime.  We check the changes being
+// committed for this and report each such node as a warning before the
+// commit is applied.
+
+type priorityScope struct {
+	prio     uint
+	prioPath []string
+	changing bool
+}
+
+func priorityOrderWarning(path []string, prio uint, scope priorityScope) error {
+	err := mgmterror.NewOperationFailedApplicationError()
+	err.Path = pathutil.Pathstr(path)
+	err.Message = fmt.Sprintf(
+		"Priority %d is lower than priority %d of [%s] which is also "+
+			"changing, so will be processed with it instead",
+		prio, scope.prio, pathutil.Pathstr(scope.prioPath))
+	return err
+}
+
+func diffPriorityWarnings(
+	dn *diff.Node,
+	path []string,
+	scope priorityScope,
+	warns []error,
+) []error {
+	for _, ch := range dn.Children() {
+		if _, isLeafVal := ch.Schema().(schema.LeafValue); isLeafVal {
+			continue
+		}
+		changing := ch.Added() || ch.Deleted()
+		if !changing && !ch.Changed() {
+			continue
+		}
+
+		chPath := pathutil.CopyAppend(path, ch.Name())
+		chScope := scope
+		chScope.changing = changing
+		if prio := uint(ch.Schema().ConfigdExt().Priority); prio != 0 {
+			if changing && scope.changing && prio < scope.prio {
+				warns = append(warns,
+					priorityOrderWarning(chPath, prio, scope))
+			}
+			chScope.prio = prio
+			chScope.prioPath = chPath
+		}
+		warns = diffPriorityWarnings(ch, chPath, chScope, warns)
+	}
+	return warns
+}
+
+// priorityWarnings - nodes whose priority can't be honoured when the
+// changes in the diff tree are committed.
+func priorityWarnings(dn *diff.Node) []error {
+	if dn == nil {
+		return nil
+	}
+	return diffPriorityWarnings(dn, nil, priorityScope{}, nil)
+}
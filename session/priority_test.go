@@ -10,6 +10,7 @@
 package session_test
 
 import (
+	"strings"
 	"testing"
 
 	. "github.com/danos/config/testutils"
@@ -296,6 +297,58 @@ testcontainer:100
 	sess.Kill()
 }
 
+const priorityOrderSchema = `
+container testcontainer {
+	configd:priority 300;
+	container testcontainer {
+		configd:priority 200;
+		leaf testleaf {
+			type string;
+		}
+	}
+	leaf testleaf {
+		type string;
+	}
+}
+`
+
+// Priority lower than an ancestor being created in the same commit can't be
+// honoured, so should be reported.
+func TestPriorityOrderWarnings(t *testing.T) {
+	var testleaffoo = []string{testcontainer, testcontainer, testleaf, "foo"}
+	srv, sess := TstStartup(t, priorityOrderSchema, emptyconfig)
+	defer sess.Kill()
+	ValidateSet(t, sess, srv.Ctx, testleaffoo, false)
+
+	_, warns, ok := sess.Validate(srv.Ctx)
+	if !ok {
+		t.Fatalf("Unexpected validation failure: %v", warns)
+	}
+	if len(warns) != 1 ||
+		!strings.Contains(warns[0].Error(), "Priority 200 is lower than") {
+		t.Fatalf("Unexpected validation warnings: %v", warns)
+	}
+}
+
+// Once the ancestor exists, the lower priority node can be processed in
+// its own right.
+func TestPriorityOrderNoWarningWhenAncestorExists(t *testing.T) {
+	const config = `
+testcontainer {
+	testleaf bar
+}
+`
+	var testleaffoo = []string{testcontainer, testcontainer, testleaf, "foo"}
+	srv, sess := TstStartup(t, priorityOrderSchema, config)
+	defer sess.Kill()
+	ValidateSet(t, sess, srv.Ctx, testleaffoo, false)
+
+	_, warns, ok := sess.Validate(srv.Ctx)
+	if !ok || len(warns) != 0 {
+		t.Fatalf("Unexpected validation result: %v", warns)
+	}
+}
+
 // Check relative order of scripts being called for multiple list entries.
 // This test was added following a problem with OSPF areas where they had
 // begin and end scripts, but still needed to get area deletes for all
@@ -0,0 +1,56 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session
+
+import (
+	"strings"
+
+	"github.com/danos/config/data"
+	"github.com/danos/mgmterror"
+)
+
+// Private candidates
+//
+// A session's candidate normally shows its changes layered over the
+// current running configuration, so commits made by other sessions show
+// through as soon as they are made.  A private candidate is instead layered
+// over the session's base, the running configuration when the session
+// started or was last rebased, so the session works on a configuration
+// that only it changes.  On commit the session is rebased onto running as
+// any other session is.  If other commits changed paths the session also
+// changed the commit fails, reporting both the conflicting paths and those
+// changed by other commits that would have merged cleanly, so the user can
+// see what they would be committing on top of.
+
+// WithPrivateCandidate makes the session's candidate private.
+func WithPrivateCandidate() SessionOption {
+	return func(s *session) {
+		s.private = true
+	}
+}
+
+func (s *Session) IsPrivate() bool {
+	return s.s.private
+}
+
+// viewRunning returns the running tree the session's candidate is layered
+// over.
+func (s *session) viewRunning() *data.Node {
+	if s.private && s.base != nil {
+		return s.base
+	}
+	return s.cmgr.Running()
+}
+
+func mergeReportError(conflicts, merged []string) error {
+	err := mgmterror.NewOperationFailedApplicationError()
+	err.Message = "Configuration has been changed by another commit. " +
+		"Conflicting paths:\n  " + strings.Join(conflicts, "\n  ")
+	if len(merged) > 0 {
+		err.Message += "\nOther changes, which merge cleanly:\n  " +
+			strings.Join(merged, "\n  ")
+	}
+	return err
+}
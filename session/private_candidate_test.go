@@ -0,0 +1,78 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session_test
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/danos/configd/session"
+	. "github.com/danos/configd/session/sessiontest"
+)
+
+const privateCandidateConfig = `
+testcontainer {
+	first base
+	second base
+}
+`
+
+func TestPrivateCandidateIsolatedUntilCommit(t *testing.T) {
+	srv, _ := TstStartup(t, rebaseSchema, privateCandidateConfig)
+	priv := NewSession("private", srv.Cmgr, srv.Ms, srv.MsFull,
+		WithPrivateCandidate())
+	defer priv.Kill()
+	other := NewSession("other", srv.Cmgr, srv.Ms, srv.MsFull)
+	defer other.Kill()
+
+	rebaseSet(t, srv, priv, "testcontainer", "first", "foo")
+	rebaseSet(t, srv, other, "testcontainer", "second", "bar")
+	rebaseCommit(t, srv, other)
+
+	if priv.Exists(srv.Ctx, []string{"testcontainer", "second", "bar"}) {
+		t.Fatalf("Private candidate should not see other commits")
+	}
+	rebaseCommit(t, srv, priv)
+
+	for _, path := range [][]string{
+		{"testcontainer", "first", "foo"},
+		{"testcontainer", "second", "bar"},
+	} {
+		if !priv.Exists(srv.Ctx, path) {
+			t.Fatalf("%v missing once private candidate committed", path)
+		}
+	}
+}
+
+func TestPrivateCandidateMergeReport(t *testing.T) {
+	srv, _ := TstStartup(t, rebaseSchema, privateCandidateConfig)
+	priv := NewSession("private", srv.Cmgr, srv.Ms, srv.MsFull,
+		WithPrivateCandidate())
+	defer priv.Kill()
+	other := NewSession("other", srv.Cmgr, srv.Ms, srv.MsFull)
+	defer other.Kill()
+
+	rebaseSet(t, srv, priv, "testcontainer", "first", "foo")
+	rebaseSet(t, srv, other, "testcontainer", "first", "bar")
+	rebaseSet(t, srv, other, "testcontainer", "second", "baz")
+	rebaseCommit(t, srv, other)
+
+	_, errs, ok := priv.Commit(srv.Ctx, "", false)
+	if ok {
+		t.Fatalf("Commit should fail with conflicting changes")
+	}
+	if len(errs) != 1 {
+		t.Fatalf("Unexpected commit errors: %v", errs)
+	}
+	msg := errs[0].Error()
+	for _, exp := range []string{
+		"Conflicting paths:\n  testcontainer first",
+		"Other changes, which merge cleanly:\n  testcontainer second",
+	} {
+		if !strings.Contains(msg, exp) {
+			t.Fatalf("Merge report missing %q:\n%s", exp, msg)
+		}
+	}
+}
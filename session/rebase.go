@@ -0,0 +1,146 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session
+
+import (
+	"strings"
+
+	"github.com/danos/config/data"
+	"github.com/danos/config/diff"
+	"github.com/danos/config/schema"
+	"github.com/danos/config/union"
+	"github.com/danos/configd"
+	"github.com/danos/mgmterror"
+	"github.com/danos/utils/pathutil"
+)
+
+// Candidate rebase
+//
+// A candidate only holds the changes made in the session, layered over
+// whatever the running configuration currently is.  If another session
+// commits, those changes end up applied to a running tree the user never
+// saw, which can give surprising results.  Each session therefore records
+// the running tree its changes were made against (its base).  When the base
+// is stale we work out what the other commit(s) changed and, provided none
+// of it overlaps with what this session changed, move the session onto the
+// new running tree.  Overlapping paths are reported as conflicts and the
+// base is left alone, so the user can decide what to do.
+
+// baseChanged - has running moved on since the session's changes were made?
+func (s *session) baseChanged() bool {
+	return s.base != s.cmgr.Running()
+}
+
+func (s *session) resetBase() {
+	s.base = s.cmgr.Running()
+}
+
+// diffChangedPaths - the topmost added or deleted nodes in the diff tree.
+// Changing a leaf's value shows up as removal of the old value and addition
+// of the new, so that is reported as a single change to the leaf itself.
+func diffChangedPaths(dn *diff.Node, path []string, paths [][]string) [][]string {
+	for _, ch := range dn.Children() {
+		chPath := pathutil.CopyAppend(path, ch.Name())
+		switch {
+		case ch.Added() || ch.Deleted():
+			_, isLeafVal := ch.Schema().(schema.LeafValue)
+			_, parentIsLeaf := dn.Schema().(schema.Leaf)
+			if isLeafVal && parentIsLeaf {
+				chPath = path
+			}
+			if len(paths) > 0 && pathsEqual(paths[len(paths)-1], chPath) {
+				continue
+			}
+			paths = append(paths, chPath)
+		case ch.Changed():
+			paths = diffChangedPaths(ch, chPath, paths)
+		}
+	}
+	return paths
+}
+
+func (s *session) changedPaths(newTree, oldTree *data.Node) [][]string {
+	dn := diff.NewNode(newTree, oldTree, s.schema, nil)
+	if dn == nil {
+		return nil
+	}
+	return diffChangedPaths(dn, nil, nil)
+}
+
+func pathIsPrefix(prefix, path []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	return pathsEqual(prefix, path[:len(prefix)])
+}
+
+// rebaseConflicts - paths changed both in this session and by commits made
+// since the session's base was taken, and the paths changed by those
+// commits that don't overlap with the session's changes.
+func (s *session) rebaseConflicts(running *data.Node) ([]string, []string) {
+	base := union.NewNode(nil, s.base, s.schema, nil, 0).Merge()
+	mcan := union.NewNode(s.candidate, s.base, s.schema, nil, 0).Merge()
+	ours := s.changedPaths(mcan, base)
+	if len(ours) == 0 {
+		return nil, nil
+	}
+
+	mrun := union.NewNode(nil, running, s.schema, nil, 0).Merge()
+	theirs := s.changedPaths(mrun, base)
+
+	overlaps := func(path []string, paths [][]string) bool {
+		for _, p := range paths {
+			if pathIsPrefix(path, p) || pathIsPrefix(p, path) {
+				return true
+			}
+		}
+		return false
+	}
+	var conflicts, merged []string
+	for _, our := range ours {
+		if overlaps(our, theirs) {
+			conflicts = append(conflicts, strings.Join(our, " "))
+		}
+	}
+	for _, their := range theirs {
+		if !overlaps(their, ours) {
+			merged = append(merged, strings.Join(their, " "))
+		}
+	}
+	return conflicts, merged
+}
+
+// rebase moves the session onto the current running tree if that can be
+// done without conflicts, and returns the conflicting paths otherwise.
+func (s *session) rebase(ctx *configd.Context) ([]string, error) {
+	conflicts, _, err := s.rebaseReport(ctx)
+	return conflicts, err
+}
+
+// rebaseReport rebases as rebase does, also returning the paths changed by
+// other commits that merge cleanly when there are conflicts.
+func (s *session) rebaseReport(ctx *configd.Context) ([]string, []string, error) {
+	if err := s.trylock(ctx.Pid); err != nil {
+		return nil, nil, err
+	}
+	if !s.baseChanged() {
+		return nil, nil, nil
+	}
+
+	running := s.cmgr.Running()
+	conflicts, merged := s.rebaseConflicts(running)
+	if len(conflicts) > 0 {
+		return conflicts, merged, nil
+	}
+	s.base = running
+	return nil, nil, nil
+}
+
+func rebaseConflictError(conflicts []string) error {
+	err := mgmterror.NewOperationFailedApplicationError()
+	err.Message = "Configuration has been changed by another commit. " +
+		"Conflicting paths:\n  " + strings.Join(conflicts, "\n  ")
+	return err
+}
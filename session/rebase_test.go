@@ -0,0 +1,120 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session_test
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/danos/configd/session"
+	. "github.com/danos/configd/session/sessiontest"
+)
+
+const rebaseSchema = `
+container testcontainer {
+	leaf first {
+		type string;
+	}
+	leaf second {
+		type string;
+	}
+}
+`
+
+func rebaseSet(t *testing.T, srv *TstSrv, sess *Session, path ...string) {
+	t.Helper()
+	if err := sess.Set(srv.Ctx, path); err != nil {
+		t.Fatalf("Unable to set path %v: %s", path, err)
+	}
+}
+
+func rebaseCommit(t *testing.T, srv *TstSrv, sess *Session) {
+	t.Helper()
+	if _, errs, ok := sess.Commit(srv.Ctx, "", false); !ok {
+		t.Fatalf("Unable to commit: %v", errs)
+	}
+}
+
+func TestRebaseWithoutExternalCommit(t *testing.T) {
+	srv, sess := TstStartup(t, rebaseSchema, emptyconfig)
+
+	rebaseSet(t, srv, sess, "testcontainer", "first", "foo")
+	if sess.BaseChanged(srv.Ctx) {
+		t.Fatalf("Base should not have changed")
+	}
+	conflicts, err := sess.Rebase(srv.Ctx)
+	if err != nil || len(conflicts) != 0 {
+		t.Fatalf("Unexpected rebase result: %v, %v", conflicts, err)
+	}
+}
+
+func TestRebaseNoConflict(t *testing.T) {
+	srv, sess := TstStartup(t, rebaseSchema, emptyconfig)
+	other := NewSession("other", srv.Cmgr, srv.Ms, srv.MsFull)
+	defer other.Kill()
+
+	rebaseSet(t, srv, sess, "testcontainer", "first", "foo")
+	rebaseSet(t, srv, other, "testcontainer", "second", "bar")
+	rebaseCommit(t, srv, other)
+
+	if !sess.BaseChanged(srv.Ctx) {
+		t.Fatalf("Base should have changed after other commit")
+	}
+	conflicts, err := sess.Rebase(srv.Ctx)
+	if err != nil || len(conflicts) != 0 {
+		t.Fatalf("Unexpected rebase result: %v, %v", conflicts, err)
+	}
+	if sess.BaseChanged(srv.Ctx) {
+		t.Fatalf("Base should be up to date after rebase")
+	}
+	rebaseCommit(t, srv, sess)
+}
+
+func TestRebaseConflict(t *testing.T) {
+	srv, sess := TstStartup(t, rebaseSchema, emptyconfig)
+	other := NewSession("other", srv.Cmgr, srv.Ms, srv.MsFull)
+	defer other.Kill()
+
+	rebaseSet(t, srv, sess, "testcontainer", "first", "foo")
+	rebaseSet(t, srv, other, "testcontainer", "first", "bar")
+	rebaseCommit(t, srv, other)
+
+	conflicts, err := sess.Rebase(srv.Ctx)
+	if err != nil {
+		t.Fatalf("Unexpected rebase error: %s", err)
+	}
+	if len(conflicts) != 1 || conflicts[0] != "testcontainer first" {
+		t.Fatalf("Unexpected conflicts: %v", conflicts)
+	}
+	if !sess.BaseChanged(srv.Ctx) {
+		t.Fatalf("Base should not be updated when there are conflicts")
+	}
+}
+
+func TestCommitFailsOnRebaseConflict(t *testing.T) {
+	srv, sess := TstStartup(t, rebaseSchema, emptyconfig)
+	other := NewSession("other", srv.Cmgr, srv.Ms, srv.MsFull)
+	defer other.Kill()
+
+	rebaseSet(t, srv, sess, "testcontainer", "first", "foo")
+	rebaseSet(t, srv, other, "testcontainer", "first", "bar")
+	rebaseCommit(t, srv, other)
+
+	_, errs, ok := sess.Commit(srv.Ctx, "", false)
+	if ok {
+		t.Fatalf("Commit should fail with conflicting changes")
+	}
+	if len(errs) != 1 ||
+		!strings.Contains(errs[0].Error(), "testcontainer first") {
+		t.Fatalf("Unexpected commit errors: %v", errs)
+	}
+
+	// Once the user has dealt with the conflict, they can commit again.
+	if err := sess.Discard(srv.Ctx); err != nil {
+		t.Fatalf("Unable to discard: %s", err)
+	}
+	rebaseSet(t, srv, sess, "testcontainer", "first", "foo")
+	rebaseCommit(t, srv, sess)
+}
@@ -0,0 +1,55 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session
+
+import (
+	"github.com/danos/config/schema"
+)
+
+// Secret path values
+//
+// The path of a set of a secret ends in the secret itself, so anything
+// recording the paths a user or commit worked on, eg command accounting or
+// the commit trace, must know which elements to hide.  An element is
+// secret if it is the value of a leaf or leaf-list marked configd:secret.
+// Elements below a node the schema doesn't have can't be classified, and
+// may be a mistyped secret, so are treated as secret too.
+
+const RedactedSecret = "********"
+
+// SecretPathElems reports, for each element of path, whether it must be
+// hidden.
+func SecretPathElems(sch schema.Node, path []string) []bool {
+	secret := make([]bool, len(path))
+	for i := range path {
+		parent := sch
+		if i > 0 {
+			parent = schema.Descendant(sch, path[:i])
+		}
+		switch p := parent.(type) {
+		case nil:
+			for j := i; j < len(path); j++ {
+				secret[j] = true
+			}
+			return secret
+		case schema.Leaf, schema.LeafList:
+			secret[i] = p.ConfigdExt().Secret
+		}
+	}
+	return secret
+}
+
+// RedactPath returns a copy of path with the elements that must be hidden
+// replaced.
+func RedactPath(sch schema.Node, path []string) []string {
+	redacted := make([]string, len(path))
+	for i, secret := range SecretPathElems(sch, path) {
+		redacted[i] = path[i]
+		if secret {
+			redacted[i] = RedactedSecret
+		}
+	}
+	return redacted
+}
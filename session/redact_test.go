@@ -0,0 +1,59 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session_test
+
+import (
+	"testing"
+
+	"github.com/danos/configd/session"
+	"github.com/danos/configd/session/sessiontest"
+	"github.com/danos/utils/pathutil"
+)
+
+const redactSchema = `
+container users {
+	list user {
+		key name;
+		leaf name {
+			type string;
+		}
+		leaf password {
+			type string;
+			configd:secret "true";
+		}
+		leaf-list keys {
+			type string;
+			configd:secret "true";
+		}
+		leaf shell {
+			type string;
+		}
+	}
+}`
+
+func TestRedactPath(t *testing.T) {
+	srv, sess := sessiontest.TstStartup(t, redactSchema, emptyconfig)
+	defer sess.Kill()
+
+	for _, tc := range []struct {
+		path, exp string
+	}{
+		{"/users/user/fred/password/hunter2",
+			"/users/user/fred/password/********"},
+		{"/users/user/fred/keys/abc", "/users/user/fred/keys/********"},
+		{"/users/user/fred/shell/bash", "/users/user/fred/shell/bash"},
+		{"/users/user/fred", "/users/user/fred"},
+		// Can't tell if the unknown element is a mistyped secret
+		{"/users/user/fred/pasword/hunter2",
+			"/users/user/fred/pasword/********"},
+	} {
+		got := pathutil.Pathstr(
+			session.RedactPath(srv.Ms, pathutil.Makepath(tc.path)))
+		if got != tc.exp {
+			t.Errorf("Redacting %s: expected %s, got %s", tc.path, tc.exp,
+				got)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session
+
+import (
+	"github.com/danos/config/data"
+	"github.com/danos/config/schema"
+	"github.com/danos/config/union"
+	"github.com/danos/configd"
+	"github.com/danos/mgmterror"
+	"github.com/danos/utils/pathutil"
+	yang "github.com/danos/yang/schema"
+)
+
+// Rename and copy
+//
+// Copy makes a new list entry in the candidate from an existing one,
+// everything below the entry coming with it, and rename then deletes the
+// original.  The new entry may be below another entry of a parent list,
+// as long as both are entries of the same list in the schema, and must not
+// already exist.  A key leaf below the entry is given the new key.  Each
+// path of the new entry is set as if by the user, so is authorized, and
+// checked, like any other set.  A renamed entry of a list ordered by the
+// user keeps its place when it stays in the same list.
+
+// copyListEntry returns the schema of the entries at from and to, or an
+// error if they aren't entries of the same list.
+func (s *session) copyListEntry(from, to []string) (schema.ListEntry, error) {
+	var sn schema.Node = s.schema
+	if len(from) == 0 || len(from) != len(to) {
+		return nil, newCopyError(to,
+			"Can only rename or copy to an entry of the same list")
+	}
+	for i := range from {
+		if sn = sn.SchemaChild(from[i]); sn == nil {
+			return nil, yang.NewInvalidPathError(from[:i+1])
+		}
+		if _, isEntry := sn.(schema.ListEntry); !isEntry && from[i] != to[i] {
+			return nil, newCopyError(to,
+				"Can only rename or copy to an entry of the same list")
+		}
+	}
+	entry, ok := sn.(schema.ListEntry)
+	if !ok {
+		return nil, newCopyError(from,
+			"Only list entries can be renamed or copied")
+	}
+	return entry, nil
+}
+
+func newCopyError(path []string, msg string) error {
+	err := mgmterror.NewInvalidValueApplicationError()
+	err.Path = pathutil.Pathstr(path)
+	err.Message = msg
+	return err
+}
+
+// copyPath returns the path, below the entry at from, as it is below the
+// entry at to, giving any key leaf the new key.
+func copyPath(path, from, to []string, key string) []string {
+	out := append(pathutil.Copypath(to), path[len(from):]...)
+	if rel := out[len(to):]; len(rel) > 1 && rel[0] == key {
+		rel[1] = to[len(to)-1]
+	}
+	return out
+}
+
+// nextEntry returns the entry following that at path in its list, if any.
+func nextEntry(mcan *data.Node, path []string) string {
+	list := dataDescendant(mcan, path[:len(path)-1])
+	if list == nil {
+		return ""
+	}
+	chs := list.Children()
+	for i, ch := range chs {
+		if ch.Name() == path[len(path)-1] && i+1 < len(chs) {
+			return chs[i+1].Name()
+		}
+	}
+	return ""
+}
+
+func (s *session) copyEntry(
+	ctx *configd.Context, from, to []string, rename bool,
+) error {
+	if err := s.trylock(ctx.Pid); err != nil {
+		return err
+	}
+	entry, err := s.copyListEntry(from, to)
+	if err != nil {
+		return err
+	}
+	if rename && s.frozenFor(ctx, from, true) {
+		return newFrozenError(from)
+	}
+
+	ut := s.getUnion()
+	if !s.existsInTree(ut, ctx, from, excludeDefault) {
+		return yang.NewNodeNotExistsError(from)
+	}
+	if s.existsInTree(ut, ctx, to, excludeDefault) {
+		return yang.NewNodeExistsError(to)
+	}
+
+	mcan := ut.MergeWithoutDefaults()
+	src := dataDescendant(mcan, from)
+	if src == nil {
+		return yang.NewNodeNotExistsError(from)
+	}
+	for _, p := range dataLeafPaths(src, from, nil) {
+		err := s.set(ctx, copyPath(p, from, to, entry.Keys()[0]))
+		if err != nil {
+			// Leave the candidate as it was.
+			ut.Delete(s.newAuther(ctx), to, union.DontCheckAuth)
+			return err
+		}
+	}
+	if !rename {
+		return nil
+	}
+
+	next := nextEntry(mcan, from)
+	if err := s.del(ctx, from); err != nil {
+		return err
+	}
+	sameList := pathutil.Pathstr(from[:len(from)-1]) ==
+		pathutil.Pathstr(to[:len(to)-1])
+	if !sameList || !orderedByUser(schema.Descendant(s.schema,
+		from[:len(from)-1])) {
+		return nil
+	}
+	if next == "" {
+		return s.moveEntry(ctx, to, insertLast, "")
+	}
+	return s.moveEntry(ctx, to, insertBefore, next)
+}
@@ -0,0 +1,205 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session_test
+
+import (
+	"testing"
+
+	"github.com/danos/utils/pathutil"
+
+	. "github.com/danos/configd/session/sessiontest"
+)
+
+const renameCopySchema = `
+container interfaces {
+	list dataplane {
+		key tagnode;
+		leaf tagnode {
+			type string;
+		}
+		leaf description {
+			type string;
+		}
+		list vif {
+			key tagnode;
+			leaf tagnode {
+				type uint32;
+			}
+			leaf mtu {
+				type uint32;
+			}
+		}
+	}
+}
+container rules {
+	list rule {
+		key name;
+		ordered-by user;
+		leaf name {
+			type string;
+		}
+		leaf action {
+			type string;
+		}
+	}
+}
+`
+
+const renameCopyConfig = `interfaces {
+	dataplane dp0s1 {
+		description uplink
+		vif 10 {
+			mtu 1400
+		}
+	}
+}
+rules {
+	rule a {
+		action accept
+	}
+	rule b {
+		action drop
+	}
+	rule c
+}
+`
+
+func TestCopyListEntry(t *testing.T) {
+	const expconfig = `interfaces {
+	dataplane dp0s1 {
+		description uplink
+		vif 10 {
+			mtu 1400
+		}
+	}
+	dataplane dp0s2 {
+		description uplink
+		vif 10 {
+			mtu 1400
+		}
+	}
+}
+rules {
+	rule a {
+		action accept
+	}
+	rule b {
+		action drop
+	}
+	rule c
+}
+`
+	srv, sess := TstStartup(t, renameCopySchema, renameCopyConfig)
+	defer sess.Kill()
+
+	err := sess.Copy(srv.Ctx,
+		pathutil.Makepath("interfaces/dataplane/dp0s1"),
+		pathutil.Makepath("interfaces/dataplane/dp0s2"))
+	if err != nil {
+		t.Fatalf("Unable to copy entry: %s", err)
+	}
+	ValidateShow(t, sess, srv.Ctx, emptypath, false,
+		expconfig, true)
+}
+
+func TestRenameListEntry(t *testing.T) {
+	const expconfig = `interfaces {
+	dataplane dp0s1 {
+		description uplink
+		vif 20 {
+			mtu 1400
+		}
+	}
+}
+rules {
+	rule a {
+		action accept
+	}
+	rule b {
+		action drop
+	}
+	rule c
+}
+`
+	srv, sess := TstStartup(t, renameCopySchema, renameCopyConfig)
+	defer sess.Kill()
+
+	err := sess.Rename(srv.Ctx,
+		pathutil.Makepath("interfaces/dataplane/dp0s1/vif/10"),
+		pathutil.Makepath("interfaces/dataplane/dp0s1/vif/20"))
+	if err != nil {
+		t.Fatalf("Unable to rename entry: %s", err)
+	}
+	ValidateShow(t, sess, srv.Ctx, emptypath, false,
+		expconfig, true)
+}
+
+func TestRenameKeepsUserOrder(t *testing.T) {
+	const expconfig = `interfaces {
+	dataplane dp0s1 {
+		description uplink
+		vif 10 {
+			mtu 1400
+		}
+	}
+}
+rules {
+	rule a {
+		action accept
+	}
+	rule d {
+		action drop
+	}
+	rule c
+}
+`
+	srv, sess := TstStartup(t, renameCopySchema, renameCopyConfig)
+	defer sess.Kill()
+
+	err := sess.Rename(srv.Ctx, pathutil.Makepath("rules/rule/b"),
+		pathutil.Makepath("rules/rule/d"))
+	if err != nil {
+		t.Fatalf("Unable to rename entry: %s", err)
+	}
+	ValidateShow(t, sess, srv.Ctx, emptypath, false,
+		expconfig, true)
+}
+
+func TestRenameCopyInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to string
+	}{
+		{"missing entry",
+			"interfaces/dataplane/dp0s9", "interfaces/dataplane/dp0s2"},
+		{"existing entry",
+			"rules/rule/a", "rules/rule/b"},
+		{"other list",
+			"interfaces/dataplane/dp0s1", "rules/rule/d"},
+		{"not a list entry",
+			"interfaces/dataplane/dp0s1/description",
+			"interfaces/dataplane/dp0s1/mtu"},
+		{"invalid key",
+			"interfaces/dataplane/dp0s1/vif/10",
+			"interfaces/dataplane/dp0s1/vif/foo"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv, sess := TstStartup(t, renameCopySchema, renameCopyConfig)
+			defer sess.Kill()
+
+			from := pathutil.Makepath(test.from)
+			to := pathutil.Makepath(test.to)
+			if err := sess.Copy(srv.Ctx, from, to); err == nil {
+				t.Fatal("Unexpected copy success")
+			}
+			if err := sess.Rename(srv.Ctx, from, to); err == nil {
+				t.Fatal("Unexpected rename success")
+			}
+			ValidateShow(t, sess, srv.Ctx, emptypath, false,
+				renameCopyConfig, true)
+		})
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// The running configuration file is used to restore running if configd is
+// restarted, so we need to know we can trust it.  The first line of the file
+// is a comment recording the hash of the schema the configuration was
+// written with, the id of the commit that produced it and the SHA-256 of the
+// remainder of the file.
+//
+//   /* configd:runfile schema=<hash> commit=<id> sha256=<hash> */
+//
+// Runfiles written by earlier versions of configd have no header.  Those
+// are still loaded, so upgrading configd doesn't lose the running
+// configuration, but are marked as legacy as they can't be verified.
+
+const (
+	runfileHeaderFmt    = "/* configd:runfile schema=%s commit=%d sha256=%s */\n"
+	runfileHeaderPrefix = "/* configd:runfile "
+
+	// Written in place of an unknown schema hash, so the header can
+	// still be parsed.
+	runfileNoSchemaHash = "none"
+)
+
+type RunfileHeader struct {
+	SchemaHash string
+	CommitId   uint64
+	BodyHash   string
+
+	// Written before runfiles had a header, so unverified
+	Legacy bool
+}
+
+func runfileBodyHash(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// NewRunfile returns the runfile contents for the given configuration.
+func NewRunfile(schemaHash string, commitId uint64, body []byte) []byte {
+	if schemaHash == "" {
+		schemaHash = runfileNoSchemaHash
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, runfileHeaderFmt, schemaHash, commitId,
+		runfileBodyHash(body))
+	buf.Write(body)
+	return buf.Bytes()
+}
+
+// ParseRunfile splits runfile contents into header and configuration,
+// returning an error if the header is malformed, or if the configuration
+// doesn't match the hash recorded in the header.  Contents without a
+// header are a legacy runfile, returned whole with a Legacy header.
+func ParseRunfile(content []byte) (*RunfileHeader, []byte, error) {
+	if !bytes.HasPrefix(content, []byte(runfileHeaderPrefix)) {
+		return &RunfileHeader{Legacy: true}, content, nil
+	}
+	idx := bytes.IndexByte(content, '\n')
+	if idx < 0 {
+		return nil, nil, fmt.Errorf("runfile header incomplete")
+	}
+	line, body := content[:idx+1], content[idx+1:]
+
+	hdr := &RunfileHeader{}
+	if _, err := fmt.Sscanf(string(line), runfileHeaderFmt,
+		&hdr.SchemaHash, &hdr.CommitId, &hdr.BodyHash); err != nil {
+		return nil, nil, fmt.Errorf("runfile header invalid: %s", err)
+	}
+	if hdr.SchemaHash == runfileNoSchemaHash {
+		hdr.SchemaHash = ""
+	}
+	if hash := runfileBodyHash(body); hash != hdr.BodyHash {
+		return nil, nil, fmt.Errorf(
+			"runfile hash mismatch: header %s, content %s",
+			hdr.BodyHash, hash)
+	}
+	return hdr, body, nil
+}
+
+// writeRunfile writes the new runfile alongside the existing one and then
+// renames it into place, having first linked, or failing that copied, the
+// previous version to <file>.prev.  A partially written file is therefore
+// never left as the runfile, and there is always a runfile once there has
+// been one.
+func writeRunfile(file string, content []byte) error {
+	tmp := file + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	// The running file contains the running configuration with secrets, and
+	// should definitely NOT be world readable.
+	if err = f.Chmod(0600); err == nil {
+		_, err = f.Write(content)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := keepPrevRunfile(file); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+func keepPrevRunfile(file string) error {
+	prev := file + ".prev"
+	if err := os.Remove(prev); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	err := os.Link(file, prev)
+	if err == nil || os.IsNotExist(err) {
+		return nil
+	}
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(prev, content, 0600)
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/danos/configd/session"
+	. "github.com/danos/configd/session/sessiontest"
+)
+
+const runfileBody = "testcontainer {\n\ttestleaf foo\n}\n"
+
+func TestRunfileRoundTrip(t *testing.T) {
+	hdr, body, err := ParseRunfile(NewRunfile("abc", 7, []byte(runfileBody)))
+	if err != nil {
+		t.Fatalf("Unable to parse runfile: %s", err)
+	}
+	if hdr.SchemaHash != "abc" || hdr.CommitId != 7 {
+		t.Fatalf("Unexpected runfile header: %+v", hdr)
+	}
+	if string(body) != runfileBody {
+		t.Fatalf("Unexpected runfile body:\n%s", body)
+	}
+}
+
+func TestRunfileWithoutSchemaHash(t *testing.T) {
+	hdr, _, err := ParseRunfile(NewRunfile("", 1, []byte(runfileBody)))
+	if err != nil {
+		t.Fatalf("Unable to parse runfile: %s", err)
+	}
+	if hdr.SchemaHash != "" {
+		t.Fatalf("Unexpected schema hash: %s", hdr.SchemaHash)
+	}
+}
+
+func TestRunfileModifiedBody(t *testing.T) {
+	content := NewRunfile("abc", 7, []byte(runfileBody))
+	content[len(content)-3] = 'X'
+
+	if _, _, err := ParseRunfile(content); err == nil {
+		t.Fatalf("Modified runfile should fail verification")
+	}
+}
+
+func TestRunfileMissingHeaderIsLegacy(t *testing.T) {
+	hdr, body, err := ParseRunfile([]byte(runfileBody))
+	if err != nil {
+		t.Fatalf("Unable to parse legacy runfile: %s", err)
+	}
+	if !hdr.Legacy || hdr.CommitId != 0 {
+		t.Fatalf("Unexpected runfile header: %+v", hdr)
+	}
+	if string(body) != runfileBody {
+		t.Fatalf("Unexpected runfile body:\n%s", body)
+	}
+}
+
+func TestRunfileMalformedHeader(t *testing.T) {
+	content := "/* configd:runfile schema=abc */\n" + runfileBody
+	if _, _, err := ParseRunfile([]byte(content)); err == nil {
+		t.Fatalf("Runfile with malformed header should fail verification")
+	}
+}
+
+func TestCommitWritesVerifiableRunfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "configd-runfile")
+	if err != nil {
+		t.Fatalf("Unable to create runfile dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	srv, sess := TstStartup(t, activitySchema, emptyconfig)
+	srv.Ctx.Config.Runfile = filepath.Join(dir, "running.config")
+	srv.Cmgr.SetRunfileInfo("abc", 41)
+
+	for _, val := range []string{"foo", "bar"} {
+		if err := sess.Set(srv.Ctx,
+			[]string{"testcontainer", "testleaf", val}); err != nil {
+			t.Fatalf("Unable to set path: %s", err)
+		}
+		if _, errs, ok := sess.Commit(srv.Ctx, "", false); !ok {
+			t.Fatalf("Unable to commit: %v", errs)
+		}
+	}
+
+	content, err := ioutil.ReadFile(srv.Ctx.Config.Runfile)
+	if err != nil {
+		t.Fatalf("Unable to read runfile: %s", err)
+	}
+	hdr, _, err := ParseRunfile(content)
+	if err != nil {
+		t.Fatalf("Unable to verify runfile: %s", err)
+	}
+	if hdr.SchemaHash != "abc" || hdr.CommitId != 43 {
+		t.Fatalf("Unexpected runfile header: %+v", hdr)
+	}
+	prev, err := ioutil.ReadFile(srv.Ctx.Config.Runfile + ".prev")
+	if err != nil {
+		t.Fatalf("Previous runfile not kept: %s", err)
+	}
+	if hdr, _, err := ParseRunfile(prev); err != nil || hdr.CommitId != 42 {
+		t.Fatalf("Unexpected previous runfile %+v: %v", hdr, err)
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session
+
+import (
+	"github.com/danos/config/data"
+	"github.com/danos/config/schema"
+	"github.com/danos/config/union"
+	"github.com/danos/mgmterror"
+	"github.com/danos/utils/pathutil"
+)
+
+// Schema reload
+//
+// Configuration trees only hold names, so a tree built against one schema
+// can be used with another provided every path in it is still valid.  When
+// the schema is reloaded the running configuration is checked first, and
+// the reload abandoned if it isn't valid, as nothing could then be
+// committed.  Each session is then checked in turn.  Sessions whose
+// configuration is valid move to the new schema; the others stay on the
+// old one, and the reasons are reported, so the user can save their
+// changes and start again rather than have them silently dropped.  Only
+// the paths themselves are checked, no scripts are run, so full validation
+// is still left to commit.
+
+// checkSchemaPaths returns the errors for the paths of tree that aren't
+// valid in st.
+func checkSchemaPaths(sid string, tree *data.Node, st schema.ModelSet) error {
+	var merr mgmterror.MgmtErrorList
+	for _, path := range dataLeafPaths(tree, []string{}, nil) {
+		if len(path) == 0 {
+			continue
+		}
+		vctx := schema.ValidateCtx{
+			CurPath: path,
+			Path:    pathutil.Pathstr(path),
+			Sid:     sid,
+			Noexec:  true,
+			St:      st,
+		}
+		if err := st.Validate(vctx, []string{}, path); err != nil {
+			merr.MgmtErrorListAppend(err)
+		}
+	}
+	if len(merr.Errors()) == 0 {
+		return nil
+	}
+	return merr
+}
+
+func (s *session) reloadSchema(st, stFull schema.ModelSet) error {
+	tree := union.NewNode(
+		s.candidate, s.viewRunning(), s.schema, nil, 0).MergeWithoutDefaults()
+	if err := checkSchemaPaths(s.sid, tree, st); err != nil {
+		return err
+	}
+	s.schema = st
+	s.schemaFull = stFull
+	return nil
+}
+
+// ReloadSchema moves the session onto the given schema, if its
+// configuration is valid there.
+func (s *Session) ReloadSchema(st, stFull schema.ModelSet) error {
+	respch := make(chan error)
+	req := &schemareq{
+		st:     st,
+		stFull: stFull,
+		resp:   respch,
+	}
+	select {
+	case s.s.reqch <- req:
+		return <-respch
+	case <-s.s.term:
+	}
+	return sessTermError()
+}
+
+// ReloadSchema moves the commit manager onto the given schema, failing
+// if the running configuration isn't valid there.  Any commit in progress
+// completes first.
+func (m *CommitMgr) ReloadSchema(st schema.ModelSet) error {
+	m.schemaMu.Lock()
+	defer m.schemaMu.Unlock()
+
+	run := union.NewNode(nil, m.Running(), m.schema, nil, 0).
+		MergeWithoutDefaults()
+	if err := checkSchemaPaths("RUNNING", run, st); err != nil {
+		return err
+	}
+	m.schema = st
+	return nil
+}
+
+// ReloadSchema moves all sessions onto the given schema, returning the
+// error for each session that couldn't be moved.
+func (mgr *SessionMgr) ReloadSchema(st, stFull schema.ModelSet) map[string]error {
+	mgr.mu.RLock()
+	defer mgr.mu.RUnlock()
+
+	errs := make(map[string]error)
+	for sid, sess := range mgr.sessions {
+		if err := sess.ReloadSchema(st, stFull); err != nil {
+			mgr.Elog.Printf("Session %s kept previous schema: %s", sid, err)
+			errs[sid] = err
+		}
+	}
+	return errs
+}
@@ -0,0 +1,58 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session_test
+
+import (
+	"testing"
+
+	. "github.com/danos/configd/session"
+	. "github.com/danos/configd/session/sessiontest"
+)
+
+// As rebaseSchema, without the second leaf.
+const reloadedSchema = `
+container testcontainer {
+	leaf first {
+		type string;
+	}
+}
+`
+
+func TestReloadSchemaSessions(t *testing.T) {
+	srv, _ := TstStartup(t, rebaseSchema, privateCandidateConfig)
+	newSrv, _ := TstStartup(t, reloadedSchema, emptyconfig)
+	compat := NewSession("compat", srv.Cmgr, srv.Ms, srv.MsFull)
+	defer compat.Kill()
+	incompat := NewSession("incompat", srv.Cmgr, srv.Ms, srv.MsFull)
+	defer incompat.Kill()
+
+	rebaseSet(t, srv, compat, "testcontainer", "first", "foo")
+	rebaseSet(t, srv, incompat, "testcontainer", "first", "foo")
+
+	// Running still has the second leaf.
+	if err := srv.Cmgr.ReloadSchema(newSrv.Ms); err == nil {
+		t.Fatalf("Reload should fail with incompatible running config")
+	}
+
+	if err := incompat.Delete(srv.Ctx,
+		[]string{"testcontainer", "second"}); err != nil {
+		t.Fatalf("Unable to delete: %s", err)
+	}
+	rebaseCommit(t, srv, incompat)
+	rebaseSet(t, srv, incompat, "testcontainer", "second", "bar")
+
+	if err := srv.Cmgr.ReloadSchema(newSrv.Ms); err != nil {
+		t.Fatalf("Unable to reload running: %s", err)
+	}
+	if err := compat.ReloadSchema(newSrv.Ms, newSrv.MsFull); err != nil {
+		t.Fatalf("Unable to reload compatible session: %s", err)
+	}
+	if err := incompat.ReloadSchema(newSrv.Ms, newSrv.MsFull); err == nil {
+		t.Fatalf("Incompatible session should keep the old schema")
+	}
+	if !incompat.Exists(srv.Ctx, []string{"testcontainer", "second", "bar"}) {
+		t.Fatalf("Incompatible session lost its changes")
+	}
+}
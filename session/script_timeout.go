@@ -0,0 +1,119 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/danos/mgmterror"
+	"github.com/danos/utils/exec"
+	"github.com/danos/utils/pathutil"
+)
+
+// Script timeouts
+//
+// A hung validate script, commit hook or subst script held the commit lock
+// for good, so no further commit could be made without restarting configd.
+// With a timeout set, a commit whose validation hasn't completed once it
+// expires is aborted before anything is applied, commit hooks still running
+// are killed, and a set whose subst scripts haven't completed fails, with
+// an error naming the path they were run for.  Validate and subst scripts
+// are run by the commit and exec packages, so can't be killed from here:
+// they are left to finish in the background and their result discarded.
+// The configuration scripts themselves aren't limited, as abandoning a
+// commit part way through applying it would leave the system in an unknown
+// state.
+
+var scriptTimeout struct {
+	mu      sync.Mutex
+	timeout time.Duration
+}
+
+// SetScriptTimeout sets how long validation, commit hooks and subst scripts
+// may take, with 0 meaning there is no limit.
+func SetScriptTimeout(timeout time.Duration) {
+	scriptTimeout.mu.Lock()
+	defer scriptTimeout.mu.Unlock()
+	scriptTimeout.timeout = timeout
+}
+
+func getScriptTimeout() time.Duration {
+	scriptTimeout.mu.Lock()
+	defer scriptTimeout.mu.Unlock()
+	return scriptTimeout.timeout
+}
+
+// scriptContext returns the context to run scripts under, cancelled once
+// the timeout expires.
+func scriptContext() (context.Context, context.CancelFunc) {
+	timeout := getScriptTimeout()
+	if timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), timeout)
+}
+
+func newScriptTimeoutError(what string, path []string) error {
+	err := mgmterror.NewOperationFailedApplicationError()
+	if path != nil {
+		err.Path = pathutil.Pathstr(path)
+	}
+	err.Message = fmt.Sprintf("%s not complete within %s", what,
+		getScriptTimeout())
+	return err
+}
+
+type validateResult struct {
+	outs []*exec.Output
+	errs []error
+	ok   bool
+}
+
+// validateWithTimeout validates the commit, failing it if validation doesn't
+// complete before the timeout, or the request is cancelled.
+func (c *commitctx) validateWithTimeout() ([]*exec.Output, []error, bool) {
+	return c.withValidateTimeout(c.validate)
+}
+
+// validateScriptsWithTimeout is as validateWithTimeout, but only runs the
+// validate scripts.
+func (c *commitctx) validateScriptsWithTimeout() ([]*exec.Output, []error, bool) {
+	return c.withValidateTimeout(c.validateScripts)
+}
+
+func (c *commitctx) withValidateTimeout(
+	validate func() ([]*exec.Output, []error, bool),
+) ([]*exec.Output, []error, bool) {
+	timeout := getScriptTimeout()
+	done := c.sctx.Done()
+	if timeout <= 0 && done == nil {
+		return validate()
+	}
+	result := make(chan validateResult, 1)
+	go func() {
+		outs, errs, ok := validate()
+		result <- validateResult{outs: outs, errs: errs, ok: ok}
+	}()
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case res := <-result:
+		return res.outs, res.errs, res.ok
+	case <-expired:
+		c.LogError(fmt.Sprintf("Validation not complete within %s, "+
+			"aborting commit", timeout))
+		return nil, []error{newScriptTimeoutError("Validation", nil)}, false
+	case <-done:
+		c.LogError("Request cancelled during validation, aborting commit")
+		return nil, []error{newCancelledError()}, false
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danos/configd/session"
+	"github.com/danos/configd/session/sessiontest"
+)
+
+func TestCommitAbortedWhenValidationTimesOut(t *testing.T) {
+	const schema = `
+container testcontainer {
+	leaf testempty {
+		type empty;
+		configd:validate "sleep 2";
+	}
+}
+`
+	session.SetScriptTimeout(100 * time.Millisecond)
+	defer session.SetScriptTimeout(0)
+
+	srv, sess := sessiontest.TstStartup(t, schema, emptyconfig)
+	defer sess.Kill()
+
+	sessiontest.ValidateSet(t, sess, srv.Ctx, testemptypath, true)
+	start := time.Now()
+	_, errs, ok := sess.Commit(srv.Ctx, "", false)
+	if ok {
+		t.Fatalf("Commit succeeded despite validation timing out")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Commit waited %s for validation", elapsed)
+	}
+	if len(errs) != 1 ||
+		!strings.Contains(errs[0].Error(), "Validation not complete within") {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+	if !sess.Changed(srv.Ctx) {
+		t.Fatalf("Candidate was committed")
+	}
+}
@@ -0,0 +1,228 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/danos/config/data"
+	"github.com/danos/config/schema"
+)
+
+// Secrets at rest
+//
+// Redaction only hides secrets when they are shown; the runfile, saved
+// configurations and the archive held them in plain text.  With a secret
+// key configured, the values of leaves marked configd:secret are written
+// to these files encrypted with AES-256-GCM, as
+//
+//	$configd-aes256gcm$<base64 of nonce and ciphertext>
+//
+// and decrypted as the files are read, so running, and so the components,
+// only ever see the plain values.  The key is 32 bytes, raw or in hex, read
+// from a file (file:<path>), eg one the init system unseals from the TPM,
+// or from a user key in the kernel keyring (keyring:<description>).
+// Files written before the key was configured are still read; their
+// secrets are encrypted once written again, or by EncryptConfigFile.
+
+const encryptedSecretPrefix = "$configd-aes256gcm$"
+
+var encryptedSecretRe = regexp.MustCompile(
+	`"?\$configd-aes256gcm\$[A-Za-z0-9_-]+"?`)
+
+var secretCrypter = struct {
+	mu   sync.RWMutex
+	aead cipher.AEAD
+}{}
+
+// ReadSecretKey reads the key from source, file:<path> or
+// keyring:<description>.
+func ReadSecretKey(source string) ([]byte, error) {
+	var key []byte
+	var err error
+	switch {
+	case strings.HasPrefix(source, "file:"):
+		key, err = ioutil.ReadFile(strings.TrimPrefix(source, "file:"))
+	case strings.HasPrefix(source, "keyring:"):
+		var id []byte
+		id, err = exec.Command("keyctl", "request", "user",
+			strings.TrimPrefix(source, "keyring:")).Output()
+		if err == nil {
+			key, err = exec.Command("keyctl", "pipe",
+				strings.TrimSpace(string(id))).Output()
+		}
+	default:
+		return nil, fmt.Errorf("Secret key source %s not recognised. "+
+			"Use <file:path|keyring:description>.", source)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read secret key: %s", err)
+	}
+	if len(key) != 32 {
+		if key, err = hex.DecodeString(
+			strings.TrimSpace(string(key))); err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("Secret key must be 32 bytes, " +
+				"raw or in hex")
+		}
+	}
+	return key, nil
+}
+
+// SetSecretKey sets the key secrets are encrypted with, or with a nil key
+// stops them being encrypted.
+func SetSecretKey(key []byte) error {
+	var aead cipher.AEAD
+	if key != nil {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return err
+		}
+		if aead, err = cipher.NewGCM(block); err != nil {
+			return err
+		}
+	}
+	secretCrypter.mu.Lock()
+	secretCrypter.aead = aead
+	secretCrypter.mu.Unlock()
+	return nil
+}
+
+// EncryptingSecrets reports whether secrets are encrypted at rest.
+func EncryptingSecrets() bool {
+	return currentSecretCrypter() != nil
+}
+
+func currentSecretCrypter() cipher.AEAD {
+	secretCrypter.mu.RLock()
+	defer secretCrypter.mu.RUnlock()
+	return secretCrypter.aead
+}
+
+func encryptSecret(aead cipher.AEAD, value string) (string, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(value), nil)
+	return encryptedSecretPrefix +
+		base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+func decryptSecret(aead cipher.AEAD, value string) (string, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(
+		strings.TrimPrefix(value, encryptedSecretPrefix))
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", fmt.Errorf("Invalid encrypted secret")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("Unable to decrypt secret: %s", err)
+	}
+	return string(plain), nil
+}
+
+func encryptSecrets(
+	aead cipher.AEAD,
+	sch schema.Node,
+	n *data.Node,
+) (*data.Node, error) {
+	out := data.New(n.Name())
+	for _, ch := range n.Children() {
+		switch s := sch.(type) {
+		case schema.Leaf, schema.LeafList:
+			value := ch.Name()
+			if s.ConfigdExt().Secret &&
+				!strings.HasPrefix(value, encryptedSecretPrefix) {
+				var err error
+				if value, err = encryptSecret(aead, value); err != nil {
+					return nil, err
+				}
+			}
+			out.AddChild(data.New(value))
+			continue
+		}
+		// Nodes the schema doesn't have are copied as they are.
+		var chSch schema.Node
+		if sch != nil {
+			chSch = schema.Descendant(sch, []string{ch.Name()})
+		}
+		copied, err := encryptSecrets(aead, chSch, ch)
+		if err != nil {
+			return nil, err
+		}
+		out.AddChild(copied)
+	}
+	return out, nil
+}
+
+// EncryptSecrets returns t, a configuration tree, with the values of its
+// secrets encrypted, to be written to a file.  Without a key t itself is
+// returned.
+func EncryptSecrets(sch schema.Node, t *data.Node) (*data.Node, error) {
+	aead := currentSecretCrypter()
+	if aead == nil || t == nil {
+		return t, nil
+	}
+	return encryptSecrets(aead, sch, t)
+}
+
+// quoteConfigValue returns value quoted for a configuration file.
+func quoteConfigValue(value string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	return `"` + r.Replace(value) + `"`
+}
+
+// DecryptConfig returns the configuration text with its encrypted secrets
+// decrypted.
+func DecryptConfig(config []byte) ([]byte, error) {
+	if !bytes.Contains(config, []byte(encryptedSecretPrefix)) {
+		return config, nil
+	}
+	aead := currentSecretCrypter()
+	if aead == nil {
+		return nil, fmt.Errorf(
+			"Configuration has encrypted secrets, but no secret key is set")
+	}
+	var err error
+	out := encryptedSecretRe.ReplaceAllFunc(config, func(tok []byte) []byte {
+		plain, derr := decryptSecret(aead, strings.Trim(string(tok), `"`))
+		if derr != nil {
+			err = derr
+			return tok
+		}
+		return []byte(quoteConfigValue(plain))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DecryptConfigReader returns a reader of the configuration read from r
+// with its encrypted secrets decrypted.
+func DecryptConfigReader(r io.Reader) (io.Reader, error) {
+	config, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	config, err = DecryptConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(config), nil
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/danos/configd/session"
+	. "github.com/danos/configd/session/sessiontest"
+)
+
+const secretCryptSchema = `
+container testcontainer {
+	leaf user {
+		type string;
+	}
+	leaf password {
+		type string;
+		configd:secret "true";
+	}
+}
+`
+
+func TestSecretsEncryptedAtRest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "configd-secretcrypt")
+	if err != nil {
+		t.Fatalf("Unable to create runfile dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := SetSecretKey(bytes.Repeat([]byte{0x5a}, 32)); err != nil {
+		t.Fatalf("Unable to set secret key: %s", err)
+	}
+	defer SetSecretKey(nil)
+
+	srv, sess := TstStartup(t, secretCryptSchema, emptyconfig)
+	defer sess.Kill()
+	srv.Ctx.Config.Runfile = filepath.Join(dir, "running.config")
+
+	rebaseSet(t, srv, sess, "testcontainer", "user", "admin")
+	rebaseSet(t, srv, sess, "testcontainer", "password", "hunter2")
+	rebaseCommit(t, srv, sess)
+
+	content, err := ioutil.ReadFile(srv.Ctx.Config.Runfile)
+	if err != nil {
+		t.Fatalf("Unable to read runfile: %s", err)
+	}
+	cfg := string(content)
+	if strings.Contains(cfg, "hunter2") ||
+		!strings.Contains(cfg, "$configd-aes256gcm$") {
+		t.Fatalf("Secret not encrypted in runfile:\n%s", cfg)
+	}
+	if !strings.Contains(cfg, "user admin") {
+		t.Fatalf("Non-secret value encrypted in runfile:\n%s", cfg)
+	}
+
+	plain, err := DecryptConfig(content)
+	if err != nil {
+		t.Fatalf("Unable to decrypt runfile: %s", err)
+	}
+	if !strings.Contains(string(plain), `password "hunter2"`) {
+		t.Fatalf("Secret not decrypted:\n%s", plain)
+	}
+
+	SetSecretKey(nil)
+	if _, err := DecryptConfig(content); err == nil {
+		t.Fatal("Encrypted secrets decrypted without a key")
+	}
+}
@@ -27,9 +27,13 @@ func init() {
 
 // Defaults - return defaults
 // Secrets - return secrets in plain text
+// ForceSecrets - return secrets even if not authorized to read them
 // CouldExist - path is valid if it *could* exist, but currently doesn't
 type TreeOpts struct {
-	Defaults, Secrets, CouldExistIsAllowed bool
+	Defaults, Secrets, ForceSecrets, CouldExistIsAllowed bool
+
+	// Include annotations (see annotations.go) in the output
+	Annotations bool
 }
 
 func NewTreeOpts(flags map[string]interface{}) *TreeOpts {
@@ -44,8 +48,12 @@ func NewTreeOpts(flags map[string]interface{}) *TreeOpts {
 			opts.Defaults = v
 		case "Secrets":
 			opts.Secrets = v
+		case "ForceSecrets":
+			opts.ForceSecrets = v
 		case "CouldExist":
 			opts.CouldExistIsAllowed = v
+		case "Annotations":
+			opts.Annotations = v
 		}
 	}
 	return opts
@@ -63,7 +71,10 @@ func (t *TreeOpts) ToUnionOptions() []union.UnionOption {
 	if !t.Secrets {
 		options = append(options, union.HideSecrets)
 	}
-	// CouldExist is not relevant in UnionOptions
+	if t.ForceSecrets {
+		options = append(options, union.ForceShowSecrets)
+	}
+	// CouldExist and Annotations are not relevant in UnionOptions
 	return options
 }
 
@@ -98,6 +109,9 @@ func NewSession(
 	for _, option := range options {
 		option(&s.s)
 	}
+	if cmgr != nil {
+		s.s.resetBase()
+	}
 
 	go s.s.run()
 	return s
@@ -333,10 +347,16 @@ func (s *Session) Validate(ctx *configd.Context) ([]*exec.Output, []error, bool)
 }
 
 func (s *Session) Lock(ctx *configd.Context) (int32, error) {
+	return s.LockWithReason(ctx, "")
+}
+
+// LockWithReason locks the session, recording why for LockInfo.
+func (s *Session) LockWithReason(ctx *configd.Context, reason string) (int32, error) {
 	respch := make(chan lockresp)
 	req := &lockreq{
-		ctx:  ctx,
-		resp: respch,
+		ctx:    ctx,
+		reason: reason,
+		resp:   respch,
 	}
 	select {
 	case s.s.reqch <- req:
@@ -377,19 +397,75 @@ func (s *Session) Locked(ctx *configd.Context) (int32, error) {
 	return -1, sessTermError()
 }
 
-func (s *Session) Comment(ctx *configd.Context, path []string) error {
+// LockInfo returns who holds the session's lock, or nil if it is unlocked.
+func (s *Session) LockInfo(ctx *configd.Context) (*LockInfo, error) {
+	respch := make(chan *LockInfo)
+	req := &lockinforeq{
+		ctx:  ctx,
+		resp: respch,
+	}
+	select {
+	case s.s.reqch <- req:
+		return <-respch, nil
+	case <-s.s.term:
+	}
+	return nil, sessTermError()
+}
+
+// ForceUnlock releases the session's lock whichever process holds it,
+// returning that process.  Callers must check the user may do so.
+func (s *Session) ForceUnlock(ctx *configd.Context) (int32, error) {
+	respch := make(chan lockresp)
+	req := &forceunlockreq{
+		ctx:  ctx,
+		resp: respch,
+	}
+	select {
+	case s.s.reqch <- req:
+		resp := <-respch
+		return resp.pid, resp.err
+	case <-s.s.term:
+	}
+	return -1, sessTermError()
+}
+
+// Comment sets the comment on the node at path, removing it if comment is
+// empty.
+func (s *Session) Comment(
+	ctx *configd.Context, path []string, comment string,
+) error {
 	respch := make(chan error)
 	req := &commentreq{
+		ctx:     ctx,
+		path:    path,
+		comment: comment,
+		resp:    respch,
+	}
+	select {
+	case s.s.reqch <- req:
+		return <-respch
+	case <-s.s.term:
+	}
+	return sessTermError()
+}
+
+// GetComment returns the comment on the node at path, if any.
+func (s *Session) GetComment(
+	ctx *configd.Context, path []string,
+) (string, error) {
+	respch := make(chan getcommentresp)
+	req := &getcommentreq{
 		ctx:  ctx,
 		path: path,
 		resp: respch,
 	}
 	select {
 	case s.s.reqch <- req:
-		return <-respch
+		resp := <-respch
+		return resp.comment, resp.err
 	case <-s.s.term:
 	}
-	return sessTermError()
+	return "", sessTermError()
 }
 
 func (s *Session) Changed(ctx *configd.Context) bool {
@@ -406,6 +482,40 @@ func (s *Session) Changed(ctx *configd.Context) bool {
 	return false
 }
 
+// BaseChanged reports whether another commit has changed the running
+// configuration since the changes in this session were made.
+func (s *Session) BaseChanged(ctx *configd.Context) bool {
+	respch := make(chan bool)
+	req := &basechangedreq{
+		ctx:  ctx,
+		resp: respch,
+	}
+	select {
+	case s.s.reqch <- req:
+		return <-respch
+	case <-s.s.term:
+	}
+	return false
+}
+
+// Rebase moves the session's changes onto the current running configuration.
+// If any of them overlap with changes made by other commits in the meantime,
+// the session is left unchanged and the conflicting paths are returned.
+func (s *Session) Rebase(ctx *configd.Context) ([]string, error) {
+	respch := make(chan rebaseresp)
+	req := &rebasereq{
+		ctx:  ctx,
+		resp: respch,
+	}
+	select {
+	case s.s.reqch <- req:
+		resp := <-respch
+		return resp.conflicts, resp.err
+	case <-s.s.term:
+	}
+	return nil, sessTermError()
+}
+
 func (s *Session) Saved(ctx *configd.Context) bool {
 	respch := make(chan bool)
 	req := &savedreq{
@@ -457,6 +567,13 @@ func (s *Session) Show(ctx *configd.Context, path []string, hideSecrets, showDef
 	return s.showInternal(ctx, path, hideSecrets, showDefaults, false)
 }
 
+// ShowWithOpts - as Show, with defaults and secrets handled according
+// to opts.  CouldExist has no effect on Show.
+func (s *Session) ShowWithOpts(ctx *configd.Context, path []string, opts *TreeOpts) (string, error) {
+	return s.showInternal(ctx, path, !opts.Secrets, opts.Defaults,
+		opts.ForceSecrets)
+}
+
 func (s *Session) ShowForceSecrets(ctx *configd.Context, path []string, hideSecrets, showDefaults bool) (string, error) {
 	return s.showInternal(ctx, path, hideSecrets, showDefaults, true)
 }
@@ -475,6 +592,83 @@ func (s *Session) Discard(ctx *configd.Context) error {
 	return sessTermError()
 }
 
+// DiscardPath reverts the candidate at and below path to running, keeping
+// changes made elsewhere in the session.
+func (s *Session) DiscardPath(ctx *configd.Context, path []string) error {
+	respch := make(chan error)
+	req := &discardpathreq{
+		ctx:  ctx,
+		path: path,
+		resp: respch,
+	}
+	select {
+	case s.s.reqch <- req:
+		return <-respch
+	case <-s.s.term:
+	}
+	return sessTermError()
+}
+
+// Rename moves the list entry at from, and everything below it, to to.
+func (s *Session) Rename(ctx *configd.Context, from, to []string) error {
+	respch := make(chan error)
+	req := &renamereq{
+		ctx:  ctx,
+		from: from,
+		to:   to,
+		resp: respch,
+	}
+	select {
+	case s.s.reqch <- req:
+		return <-respch
+	case <-s.s.term:
+	}
+	return sessTermError()
+}
+
+// Copy copies the list entry at from, and everything below it, to to.
+func (s *Session) Copy(ctx *configd.Context, from, to []string) error {
+	respch := make(chan error)
+	req := &copyreq{
+		ctx:  ctx,
+		from: from,
+		to:   to,
+		resp: respch,
+	}
+	select {
+	case s.s.reqch <- req:
+		return <-respch
+	case <-s.s.term:
+	}
+	return sessTermError()
+}
+
+// Snapshot takes a snapshot of the session's tree, from which reads are
+// served until it is released, or another snapshot is taken.  Changes made
+// meanwhile aren't seen by reads.
+func (s *Session) Snapshot(ctx *configd.Context) {
+	s.snapshot(ctx, true)
+}
+
+// ReleaseSnapshot returns reads to the session's tree.
+func (s *Session) ReleaseSnapshot(ctx *configd.Context) {
+	s.snapshot(ctx, false)
+}
+
+func (s *Session) snapshot(ctx *configd.Context, take bool) {
+	respch := make(chan error)
+	req := &snapshotreq{
+		ctx:  ctx,
+		take: take,
+		resp: respch,
+	}
+	select {
+	case s.s.reqch <- req:
+		<-respch
+	case <-s.s.term:
+	}
+}
+
 func (s *Session) Load(ctx *configd.Context, file string, r io.Reader) (error, []error) {
 	respch := make(chan loadresp)
 	req := &loadreq{
@@ -509,6 +703,17 @@ func (s *Session) Merge(ctx *configd.Context, file string) (error, []error) {
 }
 
 func (s *Session) Commit(ctx *configd.Context, message string, debug bool) ([]*exec.Output, []error, bool) {
+	out, errs, ok, _ := s.CommitWithTrace(ctx, message, debug)
+	return out, errs, ok
+}
+
+// CommitWithTrace is as Commit, but also returns what the commit ran, in
+// order, if configd is tracing commits (see commit_trace.go).
+func (s *Session) CommitWithTrace(
+	ctx *configd.Context,
+	message string,
+	debug bool,
+) ([]*exec.Output, []error, bool, []string) {
 	respch := make(chan *commitresp)
 	req := &commitreq{
 		ctx:     ctx,
@@ -519,11 +724,11 @@ func (s *Session) Commit(ctx *configd.Context, message string, debug bool) ([]*e
 	select {
 	case s.s.reqch <- req:
 		resp := <-respch
-		return resp.out, resp.err, resp.ok
+		return resp.out, resp.err, resp.ok, resp.trace
 	case <-s.s.term:
 	}
 	ret := MakeCommitError(sessTermError())
-	return ret.out, ret.err, ret.ok
+	return ret.out, ret.err, ret.ok, nil
 }
 
 func (s *Session) GetHelp(ctx *configd.Context, schema bool, path []string) (map[string]string, error) {
@@ -565,6 +770,32 @@ func (s *Session) EditConfigXML(ctx *configd.Context, config_target, default_ope
 	return sessTermError()
 }
 
+// EditConfigJSON is as EditConfigXML, but takes the configuration as an
+// RFC 7951 or plain JSON document (see edit_config_json.go).
+func (s *Session) EditConfigJSON(
+	ctx *configd.Context,
+	config_target, default_operation, test_option, error_option,
+	encoding, config string,
+) error {
+	respch := make(chan error)
+	req := &editconfigreq{
+		ctx:      ctx,
+		target:   config_target,
+		defop:    default_operation,
+		testopt:  test_option,
+		erropt:   error_option,
+		encoding: encoding,
+		config:   config,
+		resp:     respch,
+	}
+	select {
+	case s.s.reqch <- req:
+		return <-respch
+	case <-s.s.term:
+	}
+	return sessTermError()
+}
+
 func (s *Session) CopyConfig(
 	ctx *configd.Context,
 	sourceDatastore,
@@ -59,22 +59,31 @@ func (s *Auther) AuthRead(path []string) bool {
 }
 
 func (s *Auther) AuthCreate(path []string) bool {
+	if s.s.frozenFor(s.ctx, path, false) {
+		return false
+	}
 	attrs := schema.AttrsForPath(s.s.schemaFull, path)
 	return s.ctx.Configd || s.ctx.Auth.AuthorizeCreate(s.ctx.Uid, s.ctx.Groups, path, attrs)
 }
 
 func (s *Auther) AuthUpdate(path []string) bool {
+	if s.s.frozenFor(s.ctx, path, false) {
+		return false
+	}
 	attrs := schema.AttrsForPath(s.s.schemaFull, path)
 	return s.ctx.Configd || s.ctx.Auth.AuthorizeUpdate(s.ctx.Uid, s.ctx.Groups, path, attrs)
 }
 
 func (s *Auther) AuthDelete(path []string) bool {
+	if s.s.frozenFor(s.ctx, path, true) {
+		return false
+	}
 	attrs := schema.AttrsForPath(s.s.schemaFull, path)
 	return s.ctx.Configd || s.ctx.Auth.AuthorizeDelete(s.ctx.Uid, s.ctx.Groups, path, attrs)
 }
 
 func (s *Auther) AuthReadSecrets(path []string) bool {
-	return s.showSecrets || s.ctx.Configd || configd.InSecretsGroup(s.ctx)
+	return s.showSecrets || s.ctx.Configd || configd.ShowSecrets(s.ctx)
 }
 
 type session struct {
@@ -83,7 +92,15 @@ type session struct {
 	lpid  int32
 	saved bool
 
+	// Who holds a user lock, see lock_info.go
+	lockInfo *LockInfo
+
+	// Comments on the configuration, or nil while they are the running
+	// comments, see comments.go
+	comments nodeComments
+
 	candidate  *data.Node
+	base       *data.Node
 	cmgr       *CommitMgr
 	schema     schema.ModelSet
 	schemaFull schema.ModelSet
@@ -93,6 +110,33 @@ type session struct {
 
 	kill chan struct{}
 	term chan struct{}
+
+	activityFile    string
+	activity        activity
+	activityWritten bool
+
+	// Whether the session had changes when last worked out, and whether
+	// a request has released the lock of a process that has gone, for
+	// keeping the activity and persisted files up to date.
+	edited      bool
+	lockExpired bool
+
+	// Where the session is persisted across restarts, if anywhere
+	persistFile string
+
+	statusDiff *statusDiffMemo
+
+	// Snapshot reads are served from, and whether it is being read
+	snapshotTree *data.Node
+	snapshotView bool
+
+	// Whether the candidate is layered over base rather than running
+	private bool
+
+	vars      sessionVars
+	editLevel editLevel
+
+	lastFailure lastFailure
 }
 
 func (s *session) getUnionFull() union.Node {
@@ -100,13 +144,16 @@ func (s *session) getUnionFull() union.Node {
 }
 
 func (s *session) getUnion() union.Node {
-	return union.NewNode(s.candidate, s.cmgr.Running(), s.schema, nil, 0)
+	if s.snapshotView {
+		return s.getSnapshotUnion()
+	}
+	return union.NewNode(s.candidate, s.viewRunning(), s.schema, nil, 0)
 }
 
 func (s *session) getRunning() *data.Node {
 	//since trees are stored without the defaults, we need to run with the merge operation
 	//to get the actual running tree.
-	return union.NewNode(nil, s.cmgr.Running(), s.schema, nil, 0).Merge()
+	return union.NewNode(nil, s.viewRunning(), s.schema, nil, 0).Merge()
 }
 
 func (s *session) mergetree(ctx *configd.Context, defaults bool) *data.Node {
@@ -185,7 +232,7 @@ func (s *session) _set(ctx *configd.Context, path []string) error {
 	//do substitution
 	//if subst then run that and exit
 	if subst := sch.ConfigdExt().Subst; len(subst) > 0 {
-		errch := make(chan error)
+		errch := make(chan error, 1) // Not waited for once timed out
 		go func() {
 			var err error
 			for _, sub := range subst {
@@ -196,8 +243,16 @@ func (s *session) _set(ctx *configd.Context, path []string) error {
 			}
 			errch <- err
 		}()
+		var expired <-chan time.Time
+		if timeout := getScriptTimeout(); timeout > 0 {
+			timer := time.NewTimer(timeout)
+			defer timer.Stop()
+			expired = timer.C
+		}
 		for {
 			select {
+			case <-expired:
+				return newScriptTimeoutError("Substitution", path)
 			case err := <-errch:
 				//Exec returned we're done
 				if err != nil {
@@ -220,6 +275,9 @@ func (s *session) set(ctx *configd.Context, path []string) error {
 		return err
 	}
 
+	if s.frozenFor(ctx, path, false) {
+		return newFrozenError(path)
+	}
 	sauth := s.newAuther(ctx)
 
 	// Need to check authorization BEFORE we do any substitutions in
@@ -254,6 +312,9 @@ func (s *session) del(ctx *configd.Context, path []string) error {
 	if err := s.trylock(ctx.Pid); err != nil {
 		return err
 	}
+	if s.frozenFor(ctx, path, true) {
+		return newFrozenError(path)
+	}
 	return s.getUnion().Delete(s.newAuther(ctx), path, union.DontCheckAuth)
 }
 
@@ -311,30 +372,16 @@ func (s *session) getstatus(ctx *configd.Context, path []string, diffCache *diff
 		return rpc.UNCHANGED, nil
 	}
 
-	var candidate, running *data.Node
-
-	sauth := s.newAuther(ctx)
 	diffTree := diffCache
 	if diffTree == nil {
 		//if we don't have a diffCache i.e, not in commit
-		//do a faster lookup by only processing the required
-		//part of the tree.
+		//use the session's memoized diff tree, checking the
+		//caller may read the path as the union tree would.
 		ppath := path[:len(path)-1]
-		path = path[len(path)-1:]
-		ut := s.getUnion()
-		un, _ := ut.Descendant(sauth, ppath)
-		if un != nil {
-			candidate = un.Merge()
-		}
-
-		rt := union.NewNode(nil, s.cmgr.Running(), s.schema, nil, 0)
-		rn, _ := rt.Descendant(sauth, ppath)
-		if rn != nil {
-			running = rn.Merge()
+		if !s.authReadPath(s.newAuther(ctx), ppath) {
+			return rpc.UNCHANGED, yang.NewNodeNotExistsError(ppath)
 		}
-
-		sn := schema.Descendant(s.schema, ppath)
-		diffTree = diff.NewNode(candidate, running, sn, nil)
+		diffTree = s.memoizedDiff()
 		if diffTree == nil {
 			return rpc.UNCHANGED, yang.NewNodeNotExistsError(ppath)
 		}
@@ -497,9 +544,9 @@ func (s *session) getfulltree(ctx *configd.Context, path []string, opts *TreeOpt
 	// syntax validation performed, but constraint validation will be
 	// delayed until we have the full tree present to avoid false errors.
 	var errAndWarns errorAndWarnings
-	respch := make(chan errorAndWarnings)
+	respch := make(chan errorAndWarnings, 1)
 	go func() {
-		respch <- addStateToTree(ut, path, dbgLogger)
+		respch <- addStateToTree(ut, path, dbgLogger, ctx.Done())
 	}()
 
 	//Process requests that don't modify the session during commit
@@ -508,6 +555,8 @@ Loop:
 		select {
 		case errAndWarns = <-respch:
 			break Loop
+		case <-ctx.Done():
+			return nil, newCancelledError(), nil
 		case req := <-s.reqch:
 			s.processreq(req, nil)
 		}
@@ -552,6 +601,8 @@ Loop:
 	ft, err = ctx.CompMgr.ComponentGetState(
 		s.schemaFull, ut, ft, errLogger)
 	if err != nil {
+		common.Log(common.LevelError, common.TypeVci,
+			"Unable to get component state", "error", err)
 		return nil, err, nil
 	}
 	logStateTime(errLogger, "End VCI scripts", vciStart)
@@ -595,6 +646,10 @@ Loop:
 }
 
 func (s *session) changed(ctx *configd.Context) bool {
+	return s.treeChanged(ctx) || s.commentsChanged()
+}
+
+func (s *session) treeChanged(ctx *configd.Context) bool {
 	mcan := s.getUnion().Merge()
 	c := newctx(s.sid, ctx, nil, mcan, s.getRunning(), s.schema, "", false,
 		0 /* no must debug */)
@@ -606,6 +661,9 @@ func (s *session) validate(ctx *configd.Context) *commitresp {
 	if err := s.trylock(ctx.Pid); err != nil {
 		return MakeCommitError(err)
 	}
+	if err := s.expandGroups(ctx); err != nil {
+		return MakeCommitError(err)
+	}
 
 	//Lock the session from changes during validate
 	pid, _ := s.locked()
@@ -622,9 +680,15 @@ func (s *session) validate(ctx *configd.Context) *commitresp {
 		common.LoggingIsEnabledAtLevel(common.LevelDebug, common.TypeCommit),
 		mustThreshold)
 
-	respch := make(chan *commitresp)
+	respch := make(chan *commitresp, 1) // Not waited for once cancelled
 	go func() {
 		outs, errs, ok := commit.Validate(c)
+		if ok {
+			s.cmgr.validated.store(validationKey(s.sid, mcan), s.schema,
+				s.cmgr.CommitId())
+			dn := diff.NewNode(mcan, s.getRunning(), s.schema, nil)
+			errs = append(errs, priorityWarnings(dn)...)
+		}
 		respch <- &commitresp{out: outs, err: errs, ok: ok}
 	}()
 
@@ -634,15 +698,22 @@ Loop:
 		select {
 		case resp = <-respch:
 			break Loop
+		case <-ctx.Done():
+			return MakeCommitError(newCancelledError())
 		case req := <-s.reqch:
 			s.processreq(req, nil)
 		}
 	}
 
+	if !resp.ok {
+		emitEvent(ctx, s.sid, EventValidationFailed, nil, resp.err)
+		s.cmgr.recordFailedValidation(ctx)
+	}
 	return resp
 }
 
 func (s *session) lock(pid int32) (int32, error) {
+	s.expireLock(pid)
 	if s.lpid == 0 {
 		s.lpid = pid
 		return pid, nil
@@ -656,6 +727,7 @@ func (s *session) lock(pid int32) (int32, error) {
 }
 
 func (s *session) trylock(pid int32) error {
+	s.expireLock(pid)
 	if s.lpid == 0 {
 		//unlocked
 		return nil
@@ -692,13 +764,6 @@ func (s *session) unlock(pid int32) (int32, error) {
 	return s.lpid, lockDenied(strconv.Itoa(int(s.lpid)))
 }
 
-func (s *session) comment(ctx *configd.Context, path []string) error {
-	if err := s.trylock(ctx.Pid); err != nil {
-		return err
-	}
-	return nil
-}
-
 func (s *session) marksaved(ctx *configd.Context, saved bool) error {
 	if err := s.trylock(ctx.Pid); err != nil {
 		return err
@@ -722,7 +787,7 @@ func (s *session) show(ctx *configd.Context, path []string, hideSecrets, showDef
 	if err != nil {
 		return "", err
 	}
-	return out, nil
+	return addComments(s.schema, out, path, s.getComments()), nil
 }
 
 func (s *session) discard(ctx *configd.Context) error {
@@ -730,6 +795,8 @@ func (s *session) discard(ctx *configd.Context) error {
 		return err
 	}
 	s.candidate = data.New("root")
+	s.comments = nil
+	s.resetBase()
 	return nil
 }
 
@@ -758,8 +825,14 @@ func (s *session) commit(ctx *configd.Context, message string, debug bool) *comm
 	if err := s.trylock(ctx.Pid); err != nil {
 		return MakeCommitError(err)
 	}
+	if err := s.expandGroups(ctx); err != nil {
+		return MakeCommitError(err)
+	}
 
-	if !s.changed(ctx) {
+	if !s.treeChanged(ctx) && !s.effectiveChanged(ctx) {
+		if s.commentsChanged() {
+			return s.commitComments(ctx)
+		}
 		err := mgmterror.NewOperationFailedProtocolError()
 		err.Message = "No configuration changes to commit"
 		return MakeCommitError(err)
@@ -767,6 +840,18 @@ func (s *session) commit(ctx *configd.Context, message string, debug bool) *comm
 	if err := s.preCommitChecks(ctx); err != nil {
 		return MakeCommitError(err)
 	}
+	conflicts, merged, err := s.rebaseReport(ctx)
+	if err != nil {
+		return MakeCommitError(err)
+	}
+	if len(conflicts) > 0 {
+		if s.private {
+			return MakeCommitError(mergeReportError(conflicts, merged))
+		}
+		return MakeCommitError(rebaseConflictError(conflicts))
+	}
+
+	emitEvent(ctx, s.sid, EventCommitStarted, nil, nil)
 
 	//Lock the session from changes during commit
 	pid, _ := s.locked()
@@ -782,7 +867,8 @@ func (s *session) commit(ctx *configd.Context, message string, debug bool) *comm
 	diffCache := diff.NewNode(s.getUnion().Merge(), s.getRunning(), s.schema, nil)
 	respch := make(chan *commitresp)
 	go func() {
-		respch <- s.cmgr.Commit(s.sid, ctx, s.candidate, message, debug)
+		respch <- s.cmgr.Commit(s.sid, ctx, s.candidate, s.comments,
+			message, debug)
 	}()
 
 	//Process requests that don't modify the session during commit
@@ -797,10 +883,14 @@ Loop:
 	}
 
 	if !resp.ok {
+		emitResultEvent(ctx, s.sid, EventCommitFinished, false, resp.err)
 		return resp
 	}
+	emitResultEvent(ctx, s.sid, EventCommitFinished, true, nil)
 
 	s.candidate = data.New("root")
+	s.comments = nil
+	s.resetBase()
 	return resp
 }
 
@@ -810,6 +900,12 @@ func (s *session) gethelp(ctx *configd.Context, fromSchema bool, path []string)
 }
 
 func (s *session) processreq(req request, diffCache *diff.Node) {
+	if !isReadOnlyReq(req) {
+		s.invalidateDiff()
+	} else {
+		s.viewSnapshot()
+		defer s.viewLive()
+	}
 	switch v := req.(type) {
 	case *mergetreereq:
 		v.resp <- s.mergetree(v.ctx, v.defaults)
@@ -844,19 +940,49 @@ func (s *session) processreq(req request, diffCache *diff.Node) {
 		v.resp <- s.validate(v.ctx)
 	case *lockreq:
 		pid, err := s.lock(v.ctx.Pid)
+		if err == nil {
+			s.setLockInfo(v.ctx, v.reason)
+			emitEvent(v.ctx, s.sid, EventSessionLocked, nil, nil)
+		}
 		v.resp <- lockresp{pid, err}
 	case *unlockreq:
 		pid, err := s.unlock(v.ctx.Pid)
+		if err == nil {
+			s.lockInfo = nil
+			emitEvent(v.ctx, s.sid, EventSessionUnlocked, nil, nil)
+		}
 		v.resp <- lockresp{pid, err}
 	case *lockedreq:
+		s.expireLock(v.ctx.Pid)
 		pid, err := s.locked()
 		v.resp <- lockresp{pid, err}
+	case *lockinforeq:
+		s.expireLock(v.ctx.Pid)
+		v.resp <- s.getLockInfo()
+	case *forceunlockreq:
+		pid, err := s.forceUnlock()
+		if err == nil {
+			emitEvent(v.ctx, s.sid, EventSessionUnlocked, nil, nil)
+		}
+		v.resp <- lockresp{pid, err}
 	case *commentreq:
-		v.resp <- s.set(v.ctx, v.path)
+		v.resp <- s.comment(v.ctx, v.path, v.comment)
+	case *getcommentreq:
+		comment, err := s.getComment(v.ctx, v.path)
+		v.resp <- getcommentresp{comment, err}
 	case *savedreq:
 		v.resp <- s.saved
 	case *changedreq:
 		v.resp <- s.changed(v.ctx)
+	case *basechangedreq:
+		v.resp <- s.baseChanged()
+	case *rebasereq:
+		conflicts, err := s.rebase(v.ctx)
+		v.resp <- rebaseresp{conflicts, err}
+	case *schemareq:
+		v.resp <- s.reloadSchema(v.st, v.stFull)
+	case *restorereq:
+		v.resp <- s.restore(v.ctx, v.p)
 	case *marksavedreq:
 		v.resp <- s.marksaved(v.ctx, v.saved)
 	case *showreq:
@@ -864,6 +990,18 @@ func (s *session) processreq(req request, diffCache *diff.Node) {
 		v.resp <- showresp{d, err}
 	case *discardreq:
 		v.resp <- s.discard(v.ctx)
+	case *discardpathreq:
+		v.resp <- s.discardPath(v.ctx, v.path)
+	case *renamereq:
+		v.resp <- s.copyEntry(v.ctx, v.from, v.to, true)
+	case *copyreq:
+		v.resp <- s.copyEntry(v.ctx, v.from, v.to, false)
+	case *batchreq:
+		err, opErrs := s.applyBatch(v.ctx, v.ops)
+		v.resp <- batchresp{err, opErrs}
+	case *snapshotreq:
+		s.snapshot(v.take)
+		v.resp <- nil
 	case *loadreq:
 		err, invalidPaths := s.load(v.ctx, v.file, v.reader)
 		v.resp <- loadresp{err, invalidPaths}
@@ -875,6 +1013,11 @@ func (s *session) processreq(req request, diffCache *diff.Node) {
 	case *gethelpreq:
 		v.resp <- s.gethelp(v.ctx, v.schema, v.path)
 	case *editconfigreq:
+		if v.encoding != "" {
+			v.resp <- s.editConfigJSON(v.ctx, v.target, v.defop, v.testopt,
+				v.erropt, v.encoding, v.config)
+			break
+		}
 		v.resp <- s.editConfigXML(v.ctx, v.target, v.defop, v.testopt, v.erropt, v.config)
 	case *copyconfigreq:
 		v.resp <- s.copyConfig(v.ctx, v.sourceDatastore,
@@ -884,11 +1027,16 @@ func (s *session) processreq(req request, diffCache *diff.Node) {
 }
 
 func (s *session) run() {
+	// A failure is retried, and logged, by the next request
+	s.writeActivity()
 	for {
 		select {
 		case req := <-s.reqch:
 			s.processreq(req, nil)
+			s.updateState(req)
 		case <-s.kill:
+			s.removeActivity()
+			s.removePersisted()
 			close(s.term)
 			return
 		}
@@ -29,12 +29,29 @@ func isEmptyJson(jsonState []byte) bool {
 	return (jsonStr == "" || jsonStr == "{}")
 }
 
+// setOperState runs the state scripts of the node and adds the state they
+// return to the tree.
 func setOperState(
 	ut union.Node,
 	path []string,
 	logger schema.StateLogger,
+	done <-chan struct{},
 ) []error {
-	var warnings []error
+	return applyOperState(ut, path, fetchOperState(ut, path, logger, done),
+		logger)
+}
+
+// applyOperState adds the state fetched for the node to the tree.
+func applyOperState(
+	ut union.Node,
+	path []string,
+	state operState,
+	logger schema.StateLogger,
+) []error {
+	warnings := state.warns
+	if !state.run {
+		return warnings
+	}
 
 	context := ut
 	switch ut.GetSchema().(type) {
@@ -43,20 +60,9 @@ func setOperState(
 		// a value back, but since we are returned a JSON object we can only
 		// decode at the parent level.
 		context = ut.Parent()
-
-	case schema.LeafValue, schema.ListEntry:
-		// Special case for list and leaf, because script is on both
-		// List and ListEntry and Leaf and LeafValue. We just want to
-		// run on list and leaf.
-		return nil
-	}
-
-	json_state, warns := ut.GetStateJsonWithWarnings(path, logger)
-	if len(warns) > 0 {
-		warnings = append(warnings, warns...)
 	}
 
-	for _, v := range json_state {
+	for _, v := range state.json {
 
 		if isEmptyJson(v) {
 			continue
@@ -117,47 +123,35 @@ func getOperChild(ut union.Node, name string) union.Node {
 	return nil
 }
 
-func setAllOperState(
-	ut union.Node,
-	path []string,
-	logger schema.StateLogger,
-) []error {
-	var warnings []error
-
-	if warns := setOperState(ut, path, logger); len(warns) > 0 {
-		warnings = append(warnings, warns...)
-
-	}
-	warns := setChildrenOperState(ut, path, logger)
-	return append(warnings, warns...)
-}
-
 func setChildrenOperState(
 	ut union.Node,
 	path []string,
 	logger schema.StateLogger,
+	done <-chan struct{},
 ) []error {
 	var warnings []error
+	if cancelled(done) {
+		return nil
+	}
 
 	has_run := make(map[string]bool)
+	var children []operChild
 
 	// Get state for any active children of this node
 	for _, v := range ut.Children() {
 		has_run[v.Name()] = true
-		if warns := setAllOperState(v,
-			append(path, v.Name()), logger); len(warns) > 0 {
-			warnings = append(warnings, warns...)
-		}
+		children = append(children,
+			operChild{ut: v, path: pathutil.CopyAppend(path, v.Name())})
 	}
 
 	// Get state for any state only children of this node
 	// Skip lists, as we don't want to run on the raw list, but only
 	// active list entries
-	if _, ok := ut.GetSchema().(schema.List); ok {
-		return warnings
-	}
-	for _, v := range ut.GetSchema().(schema.ExtendedNode).StateChildren() {
-		if _, ok := has_run[v.Name()]; !ok {
+	if _, ok := ut.GetSchema().(schema.List); !ok {
+		for _, v := range ut.GetSchema().(schema.ExtendedNode).StateChildren() {
+			if _, ok := has_run[v.Name()]; ok {
+				continue
+			}
 			if sn, ok := ut.GetSchema().(schema.ListEntry); ok {
 				// Operational lists might not have keys
 				keys := sn.Keys()
@@ -166,13 +160,23 @@ func setChildrenOperState(
 				}
 			}
 			ut.Data().AddChild(data.New(v.Name()))
-			warns := setAllOperState(ut.Child(v.Name()),
-				append(path, v.Name()), logger)
-			if len(warns) > 0 {
-				warnings = append(warnings, warns...)
-			}
+			children = append(children, operChild{
+				ut:   ut.Child(v.Name()),
+				path: pathutil.CopyAppend(path, v.Name()),
+			})
 		}
 	}
+
+	// The children are unrelated, so their scripts are run concurrently,
+	// but their state is added to the tree, and their own children
+	// visited, one at a time.
+	states := fetchChildrenOperState(children, logger, done)
+	for i, ch := range children {
+		warnings = append(warnings, applyOperState(ch.ut, ch.path,
+			states[i], logger)...)
+		warnings = append(warnings, setChildrenOperState(ch.ut, ch.path,
+			logger, done)...)
+	}
 	return warnings
 }
 
@@ -189,6 +193,7 @@ func addStateToTree(
 	ut union.Node,
 	path []string,
 	logger schema.StateLogger,
+	done <-chan struct{},
 ) errorAndWarnings {
 	var errAndWarns errorAndWarnings
 
@@ -208,13 +213,13 @@ func addStateToTree(
 			return errAndWarns
 		}
 		warns := setOperState(ut, pathutil.CopyAppend(current, ut.Name()),
-			logger)
+			logger, done)
 		if len(warns) > 0 {
 			errAndWarns.warns = append(errAndWarns.warns, warns...)
 		}
 
 	}
-	warns := setChildrenOperState(ut, path, logger)
+	warns := setChildrenOperState(ut, path, logger, done)
 	if len(warns) > 0 {
 		errAndWarns.warns = append(errAndWarns.warns, warns...)
 	}
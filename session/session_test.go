@@ -944,18 +944,33 @@ container testcontainer {
 	}
 }
 `
-	// TODO: order-by system not supported yet
-	// var testleaflistsystempath = pathutil.CopyAppend(testcontainerpath, "testleaflistsystem")
+	var testleaflistsystempath = pathutil.CopyAppend(testcontainerpath, "testleaflistsystem")
+	// System ordered entries are sorted, user ordered ones are not.
+	const expsystem = `	testleaflistsystem bar
+	testleaflistsystem baz
+	testleaflistsystem foo
+`
+	const expuser = `	testleaflistuser foo
+	testleaflistuser bar
+	testleaflistuser baz
+`
 	tbl := []ValidateOpTbl{
 		NewValOpTblEntry("Set list-leaf without value", testleaflistuserpath, "", true),
 		NewValOpTblEntry("Set list-leaf item 1", testleaflistuserpath, "foo", false),
 		NewValOpTblEntry("Set list-leaf item 2", testleaflistuserpath, "bar", false),
 		NewValOpTblEntry("Set list-leaf item 3", testleaflistuserpath, "baz", false),
 		NewValOpTblEntry("Set list-leaf item 4", testleaflistuserpath, "foo", true),
+		NewValOpTblEntry("Set system list-leaf without value", testleaflistsystempath, "", true),
+		NewValOpTblEntry("Set system list-leaf item 1", testleaflistsystempath, "foo", false),
+		NewValOpTblEntry("Set system list-leaf item 2", testleaflistsystempath, "bar", false),
+		NewValOpTblEntry("Set system list-leaf item 3", testleaflistsystempath, "baz", false),
+		NewValOpTblEntry("Set system list-leaf item 4", testleaflistsystempath, "foo", true),
 	}
 
 	srv, sess := TstStartup(t, schema, emptyconfig)
 	ValidateSetTable(t, sess, srv.Ctx, tbl)
+	ValidateShowContains(t, sess, srv.Ctx, emptypath, false, true,
+		expsystem, expuser)
 	sess.Kill()
 }
 
@@ -2508,11 +2523,41 @@ CONFIGD_EXT=begin
 	sess.Kill()
 }
 
+func TestCommitTraceScriptOrder(t *testing.T) {
+	t.Log("Verify scripts are run in priority order, then schema order")
+	const schema = `
+container first {
+	configd:priority "400";
+	configd:end "echo end";
+	leaf value {
+		type string;
+	}
+}
+container second {
+	configd:priority "300";
+	configd:end "echo end";
+	leaf value {
+		type string;
+	}
+}
+container third {
+	configd:priority "300";
+	configd:end "echo end";
+	leaf value {
+		type string;
+	}
+}
+`
+	srv, sess := TstStartup(t, schema, emptyconfig)
+	ValidateSet(t, sess, srv.Ctx, []string{"first", "value", "foo"}, false)
+	ValidateSet(t, sess, srv.Ctx, []string{"third", "value", "foo"}, false)
+	ValidateSet(t, sess, srv.Ctx, []string{"second", "value", "foo"}, false)
+	ValidateCommitTrace(t, sess, srv.Ctx, TraceScript,
+		[]string{"/second", "/third", "/first"})
+	sess.Kill()
+}
+
 // TODO: test authorization access in APIs
-// TODO: test order of action execution
-//        - create commit dry-run that returns called scripts
-//        - or action scripts that just echo a string that we can compare with expected output
-// TODO: test node priority
 
 // TODO
 // func TestComment(t *testing.T) {
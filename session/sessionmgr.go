@@ -1,4 +1,4 @@
-// Copyright (c) 2018-2020, AT&T Intellectual Property. All rights reserved.
+// Copyright (c) 2018-2021, AT&T Intellectual Property. All rights reserved.
 //
 // Copyright (c) 2014-2017 by Brocade Communications Systems, Inc.
 // All rights reserved.
@@ -16,6 +16,7 @@ import (
 
 	"github.com/danos/config/schema"
 	"github.com/danos/configd"
+	"github.com/danos/configd/common"
 	"github.com/danos/mgmterror"
 )
 
@@ -88,7 +89,7 @@ func (mgr *SessionMgr) Get(ctx *configd.Context, sid string) (*Session, error) {
 }
 
 func (mgr *SessionMgr) create(
-	ctx *configd.Context, sid string, cmgr *CommitMgr, st, stFull schema.ModelSet, shared bool,
+	ctx *configd.Context, sid string, cmgr *CommitMgr, st, stFull schema.ModelSet, shared, private bool,
 ) (*Session, error) {
 
 	sess, err := mgr.lookup(ctx, sid)
@@ -106,6 +107,16 @@ func (mgr *SessionMgr) create(
 			}
 			return nil, err
 		}
+		if private != sess.IsPrivate() {
+			err := mgmterror.NewOperationFailedApplicationError()
+			err.Message = sid + " already exists as "
+			if sess.IsPrivate() {
+				err.Message += "a private session"
+			} else {
+				err.Message += "a non-private session"
+			}
+			return nil, err
+		}
 
 		lpid, _ := sess.Locked(ctx)
 		if lpid != 0 && lpid != ctx.Pid {
@@ -117,10 +128,22 @@ func (mgr *SessionMgr) create(
 	opts := []SessionOption{}
 	if !shared {
 		opts = append(opts, WithOwner(ctx.Uid))
+		if file := activityFileForSession(ctx, sid); file != "" {
+			opts = append(opts, WithActivityFile(file))
+		}
+		if file := persistFileForSession(ctx, sid); file != "" {
+			opts = append(opts, WithPersistFile(file))
+		}
+	}
+	if private {
+		opts = append(opts, WithPrivateCandidate())
 	}
 
 	sess = NewSession(sid, cmgr, st, stFull, opts...)
 	mgr.sessions[sid] = sess
+	emitEvent(ctx, sid, EventSessionCreated, nil, nil)
+	common.Log(common.LevelDebug, common.TypeSession, "Session created",
+		"sid", sid, "user", ctx.User, "shared", shared, "private", private)
 	return sess, nil
 }
 
@@ -133,7 +156,21 @@ func (mgr *SessionMgr) Create(
 	}
 	mgr.mu.Lock()
 	defer mgr.mu.Unlock()
-	return mgr.create(ctx, sid, cmgr, st, stFull, shared)
+	return mgr.create(ctx, sid, cmgr, st, stFull, shared, false)
+}
+
+// CreatePrivate creates an unshared session with a private candidate (see
+// private_candidate.go).
+func (mgr *SessionMgr) CreatePrivate(
+	ctx *configd.Context, sid string, cmgr *CommitMgr, st, stFull schema.ModelSet,
+) (*Session, error) {
+
+	if mgr == nil {
+		return nil, nilSessionMgrError()
+	}
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+	return mgr.create(ctx, sid, cmgr, st, stFull, Unshared, true)
 }
 
 func (mgr *SessionMgr) destroy(ctx *configd.Context, sid string) error {
@@ -148,6 +185,9 @@ func (mgr *SessionMgr) destroy(ctx *configd.Context, sid string) error {
 	}
 	delete(mgr.sessions, sid)
 	go sess.Kill()
+	emitEvent(ctx, sid, EventSessionDestroyed, nil, nil)
+	common.Log(common.LevelDebug, common.TypeSession, "Session destroyed",
+		"sid", sid, "user", ctx.User)
 
 	return nil
 }
@@ -11,6 +11,7 @@ import (
 	"io"
 
 	"github.com/danos/config/data"
+	"github.com/danos/config/schema"
 	"github.com/danos/config/union"
 	"github.com/danos/configd"
 	"github.com/danos/configd/rpc"
@@ -151,9 +152,10 @@ type lockresp struct {
 }
 
 type lockreq struct {
-	ctx  *configd.Context
-	pid  int
-	resp chan lockresp
+	ctx    *configd.Context
+	pid    int
+	reason string
+	resp   chan lockresp
 }
 
 func (*lockreq) reqty() {}
@@ -173,13 +175,41 @@ type lockedreq struct {
 
 func (*lockedreq) reqty() {}
 
+type lockinforeq struct {
+	ctx  *configd.Context
+	resp chan *LockInfo
+}
+
+func (*lockinforeq) reqty() {}
+
+type forceunlockreq struct {
+	ctx  *configd.Context
+	resp chan lockresp
+}
+
+func (*forceunlockreq) reqty() {}
+
 type commentreq struct {
+	ctx     *configd.Context
+	path    []string
+	comment string
+	resp    chan error
+}
+
+func (*commentreq) reqty() {}
+
+type getcommentresp struct {
+	comment string
+	err     error
+}
+
+type getcommentreq struct {
 	ctx  *configd.Context
 	path []string
-	resp chan error
+	resp chan getcommentresp
 }
 
-func (*commentreq) reqty() {}
+func (*getcommentreq) reqty() {}
 
 type savedreq struct {
 	ctx  *configd.Context
@@ -195,6 +225,40 @@ type changedreq struct {
 
 func (*changedreq) reqty() {}
 
+type basechangedreq struct {
+	ctx  *configd.Context
+	resp chan bool
+}
+
+func (*basechangedreq) reqty() {}
+
+type rebaseresp struct {
+	conflicts []string
+	err       error
+}
+
+type rebasereq struct {
+	ctx  *configd.Context
+	resp chan rebaseresp
+}
+
+func (*rebasereq) reqty() {}
+
+type schemareq struct {
+	st, stFull schema.ModelSet
+	resp       chan error
+}
+
+func (*schemareq) reqty() {}
+
+type restorereq struct {
+	ctx  *configd.Context
+	p    *persistedSession
+	resp chan error
+}
+
+func (*restorereq) reqty() {}
+
 type marksavedreq struct {
 	ctx   *configd.Context
 	saved bool
@@ -226,6 +290,53 @@ type discardreq struct {
 
 func (*discardreq) reqty() {}
 
+type discardpathreq struct {
+	ctx  *configd.Context
+	path []string
+	resp chan error
+}
+
+func (*discardpathreq) reqty() {}
+
+type renamereq struct {
+	ctx  *configd.Context
+	from []string
+	to   []string
+	resp chan error
+}
+
+func (*renamereq) reqty() {}
+
+type copyreq struct {
+	ctx  *configd.Context
+	from []string
+	to   []string
+	resp chan error
+}
+
+func (*copyreq) reqty() {}
+
+type batchresp struct {
+	err    error
+	opErrs []error
+}
+
+type batchreq struct {
+	ctx  *configd.Context
+	ops  []BatchOp
+	resp chan batchresp
+}
+
+func (*batchreq) reqty() {}
+
+type snapshotreq struct {
+	ctx  *configd.Context
+	take bool
+	resp chan error
+}
+
+func (*snapshotreq) reqty() {}
+
 type loadresp struct {
 	err          error
 	invalidPaths []error
@@ -272,13 +383,14 @@ type gethelpreq struct {
 func (*gethelpreq) reqty() {}
 
 type editconfigreq struct {
-	ctx     *configd.Context
-	target  string
-	defop   string
-	testopt string
-	erropt  string
-	config  string
-	resp    chan error
+	ctx      *configd.Context
+	target   string
+	defop    string
+	testopt  string
+	erropt   string
+	encoding string // JSON encoding of config, if not XML
+	config   string
+	resp     chan error
 }
 
 func (*editconfigreq) reqty() {}
@@ -287,6 +287,7 @@ func tstInit(
 			Runfile:      "session_test.runfile",
 			Yangdir:      "../../yang",
 			Capabilities: capDir,
+			TraceCommit:  true,
 		},
 	}
 	uid, err := strconv.Atoi(u.Uid)
@@ -143,6 +143,36 @@ func ValidateCommitWithDebug(
 		expPass, expCfg, expOut)
 }
 
+// ValidateCommitTrace commits, checking the entries of the given kind in
+// the commit trace are those expected, in order.
+func ValidateCommitTrace(
+	t *testing.T,
+	sess *Session,
+	ctx *configd.Context,
+	kind string,
+	expTrace []string,
+) {
+	t.Log("ValidateCommitTrace")
+	_, err, ok, trace := sess.CommitWithTrace(ctx, "", false)
+	if !ok {
+		t.Error("Unexpected commit failure")
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, entry := range trace {
+		if strings.HasPrefix(entry, kind+" ") {
+			got = append(got, strings.TrimPrefix(entry, kind+" "))
+		}
+	}
+	if strings.Join(got, "\n") != strings.Join(expTrace, "\n") {
+		t.Errorf("Unexpected %s order in commit trace", kind)
+		t.Logf("Received:\n%s", strings.Join(got, "\n"))
+		t.Logf("Expected:\n%s", strings.Join(expTrace, "\n"))
+		logStack(t)
+	}
+}
+
 func ValidateCommitMultipleOutput(
 	t *testing.T,
 	sess *Session,
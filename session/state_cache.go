@@ -0,0 +1,109 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/danos/utils/pathutil"
+)
+
+// State cache
+//
+// GetFullTree runs the configd:get-state scripts of every node it visits
+// on each request, so clients polling state with NETCONF <get> keep slow
+// scripts busy.  With a TTL set, the JSON returned by each node's scripts
+// is kept for that long and shared by all sessions, as the scripts report
+// the state of the system rather than of any one session's configuration.
+// Nothing is cached from scripts that produced warnings, so a failure is
+// retried on the next request.  Entries may be dropped early, eg by a
+// component that knows its state has changed, with InvalidateStateCache.
+
+type stateCacheEntry struct {
+	state   [][]byte
+	expires time.Time
+}
+
+var stateCache = struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	nextSweep time.Time
+	entries   map[string]stateCacheEntry
+}{entries: make(map[string]stateCacheEntry)}
+
+// SetStateCacheTTL sets how long the output of get-state scripts is
+// cached, with 0 disabling the cache.
+func SetStateCacheTTL(ttl time.Duration) {
+	stateCache.mu.Lock()
+	defer stateCache.mu.Unlock()
+	stateCache.ttl = ttl
+	if ttl <= 0 {
+		stateCache.entries = make(map[string]stateCacheEntry)
+	}
+}
+
+// InvalidateStateCache drops the cached state of the node at path and
+// those below it, returning the number of entries dropped.
+func InvalidateStateCache(path []string) int {
+	prefix := pathutil.Pathstr(path)
+	stateCache.mu.Lock()
+	defer stateCache.mu.Unlock()
+	dropped := 0
+	for key := range stateCache.entries {
+		if len(path) == 0 || key == prefix ||
+			strings.HasPrefix(key, prefix+"/") {
+			delete(stateCache.entries, key)
+			dropped++
+		}
+	}
+	return dropped
+}
+
+// cachedState returns the unexpired state cached for the node at path.
+func cachedState(path []string) ([][]byte, bool) {
+	key := pathutil.Pathstr(path)
+	stateCache.mu.Lock()
+	defer stateCache.mu.Unlock()
+	if stateCache.ttl <= 0 {
+		return nil, false
+	}
+	entry, ok := stateCache.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entry.expires) {
+		delete(stateCache.entries, key)
+		return nil, false
+	}
+	return entry.state, true
+}
+
+// storeState caches the state returned by the scripts of the node at path.
+func storeState(path []string, state [][]byte) {
+	if len(state) == 0 {
+		return
+	}
+	key := pathutil.Pathstr(path)
+	stateCache.mu.Lock()
+	defer stateCache.mu.Unlock()
+	if stateCache.ttl <= 0 {
+		return
+	}
+	now := time.Now()
+	if now.After(stateCache.nextSweep) {
+		for k, entry := range stateCache.entries {
+			if now.After(entry.expires) {
+				delete(stateCache.entries, k)
+			}
+		}
+		stateCache.nextSweep = now.Add(stateCache.ttl)
+	}
+	stateCache.entries[key] = stateCacheEntry{
+		state:   state,
+		expires: now.Add(stateCache.ttl),
+	}
+}
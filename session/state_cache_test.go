@@ -0,0 +1,81 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danos/configd/session"
+	"github.com/danos/configd/session/sessiontest"
+)
+
+// setupStateCount returns a schema whose get-state script records each run
+// in the returned file.
+func setupStateCount(t *testing.T) (string, string, func()) {
+	dir, err := ioutil.TempDir("", "configd-state-cache")
+	if err != nil {
+		t.Fatalf("Unable to create dir: %s", err)
+	}
+	file := filepath.Join(dir, "runs")
+	schema := fmt.Sprintf(`container mix {
+			leaf conf {
+				type string;
+			}
+			container state {
+				leaf state-value {
+					type string;
+					config false;
+				}
+				configd:get-state "echo run >> %s; echo {\"state-value\":\"leafvalue\"}";
+			}
+		}`, file)
+	return schema, file, func() { os.RemoveAll(dir) }
+}
+
+func checkStateRuns(t *testing.T, file string, exp int) {
+	t.Helper()
+	buf, _ := ioutil.ReadFile(file)
+	if runs := strings.Count(string(buf), "run"); runs != exp {
+		t.Fatalf("Expected %d get-state runs, got %d", exp, runs)
+	}
+}
+
+func TestStateCache(t *testing.T) {
+	schema, file, cleanup := setupStateCount(t)
+	defer cleanup()
+	session.SetStateCacheTTL(time.Minute)
+	defer session.SetStateCacheTTL(0)
+
+	srv, sess := sessiontest.TstStartup(t, schema, "mix {\n\tconf stuff\n}")
+	defer sess.Kill()
+
+	validateFullTree(t, sess, srv.Ctx, "mix", "state/state-value/leafvalue")
+	validateFullTree(t, sess, srv.Ctx, "mix", "state/state-value/leafvalue")
+	checkStateRuns(t, file, 1)
+
+	if n := session.InvalidateStateCache([]string{"mix"}); n != 1 {
+		t.Fatalf("Expected 1 entry invalidated, got %d", n)
+	}
+	validateFullTree(t, sess, srv.Ctx, "mix", "state/state-value/leafvalue")
+	checkStateRuns(t, file, 2)
+}
+
+func TestStateCacheDisabled(t *testing.T) {
+	schema, file, cleanup := setupStateCount(t)
+	defer cleanup()
+
+	srv, sess := sessiontest.TstStartup(t, schema, "mix {\n\tconf stuff\n}")
+	defer sess.Kill()
+
+	validateFullTree(t, sess, srv.Ctx, "mix", "state/state-value/leafvalue")
+	validateFullTree(t, sess, srv.Ctx, "mix", "state/state-value/leafvalue")
+	checkStateRuns(t, file, 2)
+}
@@ -0,0 +1,164 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/danos/config/schema"
+	"github.com/danos/config/union"
+	"github.com/danos/mgmterror"
+	"github.com/danos/utils/pathutil"
+)
+
+// Concurrent state scripts
+//
+// GetFullTree ran the configd:get-state scripts of each node in turn, so
+// one slow script delayed the whole response.  The scripts of a node's
+// children are unrelated, so they are now run concurrently, by a pool of
+// workers shared by all requests.  Only running the scripts is concurrent:
+// the state they return is added to the tree, and warnings collected, in
+// the same order as before.  With a timeout set, a node whose scripts
+// don't complete in time is left without state and a warning returned;
+// the script is left to finish, still holding its worker, and its output
+// is discarded.  Once the request is cancelled no further scripts are run
+// for it.
+
+// Scripts run at once across all requests
+const maxStateWorkers = 8
+
+var stateWorkers = make(chan struct{}, maxStateWorkers)
+
+var stateScriptTimeout struct {
+	mu      sync.Mutex
+	timeout time.Duration
+}
+
+// SetStateScriptTimeout sets how long the get-state scripts of a node may
+// take, with 0 meaning there is no limit.
+func SetStateScriptTimeout(timeout time.Duration) {
+	stateScriptTimeout.mu.Lock()
+	defer stateScriptTimeout.mu.Unlock()
+	stateScriptTimeout.timeout = timeout
+}
+
+func getStateScriptTimeout() time.Duration {
+	stateScriptTimeout.mu.Lock()
+	defer stateScriptTimeout.mu.Unlock()
+	return stateScriptTimeout.timeout
+}
+
+// operState is the output of a node's get-state scripts, if they were run.
+type operState struct {
+	run   bool
+	json  [][]byte
+	warns []error
+}
+
+type operChild struct {
+	ut   union.Node
+	path []string
+}
+
+func runStateScripts(
+	ut union.Node,
+	path []string,
+	logger schema.StateLogger,
+	done <-chan struct{},
+) operState {
+	select {
+	case stateWorkers <- struct{}{}:
+	case <-done:
+		return operState{}
+	}
+	defer func() { <-stateWorkers }()
+
+	json, warns := ut.GetStateJsonWithWarnings(path, logger)
+	if len(warns) == 0 {
+		storeState(path, json)
+	}
+	return operState{run: true, json: json, warns: warns}
+}
+
+func newStateTimeoutError(path []string, timeout time.Duration) error {
+	err := mgmterror.NewOperationFailedApplicationError()
+	err.Path = pathutil.Pathstr(path)
+	err.Message = fmt.Sprintf("State not returned within %s", timeout)
+	return err
+}
+
+// fetchOperState runs the get-state scripts of the node, unless their
+// output is cached.
+func fetchOperState(
+	ut union.Node,
+	path []string,
+	logger schema.StateLogger,
+	done <-chan struct{},
+) operState {
+	switch ut.GetSchema().(type) {
+	case schema.LeafValue, schema.ListEntry:
+		// Special case for list and leaf, because script is on both
+		// List and ListEntry and Leaf and LeafValue. We just want to
+		// run on list and leaf.
+		return operState{}
+	}
+
+	if json, ok := cachedState(path); ok {
+		return operState{run: true, json: json}
+	}
+	if cancelled(done) {
+		return operState{}
+	}
+
+	timeout := getStateScriptTimeout()
+	if timeout <= 0 && done == nil {
+		return runStateScripts(ut, path, logger, done)
+	}
+	result := make(chan operState, 1)
+	go func() {
+		result <- runStateScripts(ut, path, logger, done)
+	}()
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case state := <-result:
+		return state
+	case <-expired:
+		return operState{warns: []error{newStateTimeoutError(path, timeout)}}
+	case <-done:
+		return operState{}
+	}
+}
+
+// fetchChildrenOperState runs the get-state scripts of the children
+// concurrently, returning their output in the same order.
+func fetchChildrenOperState(
+	children []operChild,
+	logger schema.StateLogger,
+	done <-chan struct{},
+) []operState {
+	states := make([]operState, len(children))
+	if len(children) == 1 {
+		states[0] = fetchOperState(children[0].ut, children[0].path, logger,
+			done)
+		return states
+	}
+	var wg sync.WaitGroup
+	for i, ch := range children {
+		wg.Add(1)
+		go func(i int, ch operChild) {
+			defer wg.Done()
+			states[i] = fetchOperState(ch.ut, ch.path, logger, done)
+		}(i, ch)
+	}
+	wg.Wait()
+	return states
+}
@@ -0,0 +1,88 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danos/configd/session"
+	"github.com/danos/configd/session/sessiontest"
+	"github.com/danos/utils/pathutil"
+)
+
+const slowStateSchema = `container one {
+		config false;
+		leaf state {
+			type string;
+		}
+		configd:get-state "sleep 1; echo {\"state\":\"one\"}";
+	}
+	container two {
+		config false;
+		leaf state {
+			type string;
+		}
+		configd:get-state "sleep 1; echo {\"state\":\"two\"}";
+	}`
+
+func TestGetFullTreeRunsSiblingScriptsConcurrently(t *testing.T) {
+	srv, sess := sessiontest.TstStartup(t, slowStateSchema, emptyconfig)
+	defer sess.Kill()
+
+	start := time.Now()
+	validateFullTree(t, sess, srv.Ctx,
+		"",
+		"one/state/one",
+		"two/state/two")
+	if elapsed := time.Since(start); elapsed > 1900*time.Millisecond {
+		t.Fatalf("Scripts were run in turn, taking %s", elapsed)
+	}
+}
+
+func TestGetFullTreeStateScriptTimeout(t *testing.T) {
+	session.SetStateScriptTimeout(100 * time.Millisecond)
+	defer session.SetStateScriptTimeout(0)
+
+	srv, sess := sessiontest.TstStartup(t, slowStateSchema, emptyconfig)
+	defer sess.Kill()
+
+	_, err, warns := sess.GetFullTree(srv.Ctx, pathutil.Makepath(""),
+		&session.TreeOpts{Defaults: false, Secrets: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(warns) != 2 {
+		t.Fatalf("Expected a warning for each script, got %v", warns)
+	}
+	for _, warn := range warns {
+		if !strings.Contains(warn.Error(), "State not returned within") {
+			t.Fatalf("Unexpected warning: %s", warn)
+		}
+	}
+}
+
+func TestGetFullTreeCancelled(t *testing.T) {
+	srv, sess := sessiontest.TstStartup(t, slowStateSchema, emptyconfig)
+	defer sess.Kill()
+
+	ctx := *srv.Ctx
+	req, cancel := context.WithTimeout(context.Background(),
+		100*time.Millisecond)
+	defer cancel()
+	ctx.Request = req
+
+	start := time.Now()
+	_, err, _ := sess.GetFullTree(&ctx, pathutil.Makepath(""),
+		&session.TreeOpts{Defaults: false, Secrets: true})
+	if err == nil || !strings.Contains(err.Error(), "Request cancelled") {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 900*time.Millisecond {
+		t.Fatalf("Cancelled request took %s", elapsed)
+	}
+}
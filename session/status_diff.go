@@ -0,0 +1,65 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session
+
+import (
+	"github.com/danos/config/data"
+	"github.com/danos/config/diff"
+	"github.com/danos/config/union"
+)
+
+// Memoized diff tree for GetStatus
+//
+// Rendering 'show' output in the CLI asks for the status of every node it
+// displays, and each request used to merge and diff the candidate and
+// running trees under the node's parent.  Instead the session's diff tree
+// is built once and kept until the session is edited, or running changes
+// underneath it, so each status request is a walk down the path.
+
+type statusDiffMemo struct {
+	running *data.Node
+	tree    *diff.Node
+}
+
+// isReadOnlyReq returns true for requests known not to modify the session.
+// Anything else invalidates the memoized diff tree.
+func isReadOnlyReq(req request) bool {
+	switch req.(type) {
+	case *mergetreereq, *getreq, *typereq, *statusreq, *defaultreq,
+		*gettreereq, *getfulltreereq, *existsreq, *lockedreq, *lockinforeq, *savedreq,
+		*changedreq, *basechangedreq, *showreq, *gethelpreq, *getcommentreq:
+		return true
+	}
+	return false
+}
+
+func (s *session) invalidateDiff() {
+	s.statusDiff = nil
+}
+
+func (s *session) memoizedDiff() *diff.Node {
+	running := s.snapshotRunning(s.viewRunning())
+	if s.statusDiff == nil || s.statusDiff.running != running {
+		candidate := s.getUnion()
+		s.statusDiff = &statusDiffMemo{
+			running: running,
+			tree: diff.NewNode(candidate.Merge(),
+				union.NewNode(nil, running, s.schema, nil, 0).Merge(),
+				s.schema, nil),
+		}
+	}
+	return s.statusDiff.tree
+}
+
+// authReadPath checks each element of the path may be read, as walking
+// down the union tree would.
+func (s *session) authReadPath(auther union.Auther, path []string) bool {
+	for i := 1; i <= len(path); i++ {
+		if !auther.AuthRead(path[:i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,55 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session_test
+
+import (
+	"testing"
+
+	"github.com/danos/configd/rpc"
+	. "github.com/danos/configd/session"
+	. "github.com/danos/configd/session/sessiontest"
+)
+
+const statusDiffSchema = `
+container testcontainer {
+	leaf first {
+		type string;
+	}
+	leaf second {
+		type string;
+	}
+}
+`
+
+// Status must reflect edits and commits made after it was last requested.
+func TestGetStatusTracksChanges(t *testing.T) {
+	first := []string{"testcontainer", "first"}
+	second := []string{"testcontainer", "second"}
+
+	srv, sess := TstStartup(t, statusDiffSchema, emptyconfig)
+	defer sess.Kill()
+	other := NewSession("other", srv.Cmgr, srv.Ms, srv.MsFull)
+	defer other.Kill()
+
+	ValidateStatus(t, sess, srv.Ctx,
+		NewValStatusTblEntry(first, rpc.UNCHANGED, true))
+
+	ValidateSet(t, sess, srv.Ctx, append(first, "foo"), false)
+	ValidateStatus(t, sess, srv.Ctx,
+		NewValStatusTblEntry(first, rpc.ADDED, false))
+	ValidateStatus(t, sess, srv.Ctx,
+		NewValStatusTblEntry(second, rpc.UNCHANGED, true))
+
+	ValidateSet(t, other, srv.Ctx, append(second, "bar"), false)
+	if _, errs, ok := other.Commit(srv.Ctx, "", false); !ok {
+		t.Fatalf("Unable to commit: %v", errs)
+	}
+	ValidateStatus(t, sess, srv.Ctx,
+		NewValStatusTblEntry(second, rpc.UNCHANGED, false))
+
+	ValidateDelete(t, sess, srv.Ctx, first, false)
+	ValidateStatus(t, sess, srv.Ctx,
+		NewValStatusTblEntry(first, rpc.UNCHANGED, true))
+}
@@ -0,0 +1,156 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strconv"
+	"sync"
+
+	"github.com/danos/configd"
+)
+
+// Per-user commit statistics
+//
+// So that teams can audit who is changing the box, and how often their
+// operations fail, we count the commits, failed validations and rollbacks
+// of each user, and remember who made each commit.  As for the changes since
+// boot, the counts are kept in a file alongside the runfile so they survive
+// configd restarts but not a reboot.
+
+type UserStats struct {
+	Commits           uint64 `json:"commits"`
+	FailedValidations uint64 `json:"failed-validations"`
+	Rollbacks         uint64 `json:"rollbacks"`
+}
+
+type userStats struct {
+	mu    sync.Mutex
+	Users map[string]*UserStats `json:"users"`
+	// User who made each commit, by commit id
+	CommitUsers map[uint64]string `json:"commit-users"`
+}
+
+func newUserStats() *userStats {
+	return &userStats{
+		Users:       make(map[string]*UserStats),
+		CommitUsers: make(map[uint64]string),
+	}
+}
+
+func userStatsFileForRunfile(runfile string) string {
+	return runfile + ".userstats"
+}
+
+// statsUser names the user the context belongs to, falling back to the
+// uid if the user name isn't known.
+func statsUser(ctx *configd.Context) string {
+	if ctx.User != "" {
+		return ctx.User
+	}
+	return strconv.FormatUint(uint64(ctx.Uid), 10)
+}
+
+// update applies fn to the statistics for user, under the lock.
+func (u *userStats) update(user string, fn func(*UserStats)) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	st, ok := u.Users[user]
+	if !ok {
+		st = &UserStats{}
+		u.Users[user] = st
+	}
+	fn(st)
+}
+
+func (u *userStats) commit(user string, commitId uint64) {
+	u.update(user, func(st *UserStats) {
+		st.Commits++
+		u.CommitUsers[commitId] = user
+	})
+}
+
+func (u *userStats) failedValidation(user string) {
+	u.update(user, func(st *UserStats) { st.FailedValidations++ })
+}
+
+func (u *userStats) rollback(user string) {
+	u.update(user, func(st *UserStats) { st.Rollbacks++ })
+}
+
+func (u *userStats) snapshot() map[string]UserStats {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	out := make(map[string]UserStats, len(u.Users))
+	for user, st := range u.Users {
+		out[user] = *st
+	}
+	return out
+}
+
+func (u *userStats) commitUsers() map[uint64]string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	out := make(map[uint64]string, len(u.CommitUsers))
+	for id, user := range u.CommitUsers {
+		out[id] = user
+	}
+	return out
+}
+
+func (u *userStats) write(file string) error {
+	u.mu.Lock()
+	buf, err := json.Marshal(u)
+	u.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, buf, 0600)
+}
+
+func (u *userStats) read(file string) error {
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	stats := newUserStats()
+	if err := json.Unmarshal(buf, stats); err != nil {
+		return err
+	}
+	u.mu.Lock()
+	u.Users = stats.Users
+	u.CommitUsers = stats.CommitUsers
+	u.mu.Unlock()
+	return nil
+}
+
+// LoadUserStats restores the statistics recorded before configd was
+// restarted.
+func (m *CommitMgr) LoadUserStats(runfile string) error {
+	return m.userStats.read(userStatsFileForRunfile(runfile))
+}
+
+// UserStats returns the commit statistics of each user since boot.
+func (m *CommitMgr) UserStats() map[string]UserStats {
+	return m.userStats.snapshot()
+}
+
+// CommitUsers returns the user who made each commit since boot, by commit
+// id, so the commits recorded in the changes since boot can be attributed.
+func (m *CommitMgr) CommitUsers() map[uint64]string {
+	return m.userStats.commitUsers()
+}
+
+func (m *CommitMgr) recordFailedValidation(ctx *configd.Context) {
+	m.userStats.failedValidation(statsUser(ctx))
+	m.userStats.write(userStatsFileForRunfile(ctx.Config.Runfile))
+}
+
+// RecordRollback counts a rollback made by the context's user.
+func (m *CommitMgr) RecordRollback(ctx *configd.Context) {
+	m.userStats.rollback(statsUser(ctx))
+	m.userStats.write(userStatsFileForRunfile(ctx.Config.Runfile))
+}
@@ -0,0 +1,69 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	. "github.com/danos/configd/session"
+	. "github.com/danos/configd/session/sessiontest"
+)
+
+const userStatsSchema = `
+container testcontainer {
+	leaf-list values {
+		type string;
+		max-elements 1;
+	}
+}
+`
+
+func TestUserStats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "configd-userstats")
+	if err != nil {
+		t.Fatalf("Unable to create runfile dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	srv, sess := TstStartup(t, userStatsSchema, emptyconfig)
+	defer sess.Kill()
+	srv.Ctx.Config.Runfile = filepath.Join(dir, "running.config")
+	srv.Ctx.User = "alice"
+
+	rebaseSet(t, srv, sess, "testcontainer", "values", "one")
+	rebaseCommit(t, srv, sess)
+
+	rebaseSet(t, srv, sess, "testcontainer", "values", "two")
+	if _, _, ok := sess.Commit(srv.Ctx, "", false); ok {
+		t.Fatalf("Commit should fail validation")
+	}
+	srv.Cmgr.RecordRollback(srv.Ctx)
+
+	exp := map[string]UserStats{
+		"alice": {Commits: 1, FailedValidations: 1, Rollbacks: 1},
+	}
+	if act := srv.Cmgr.UserStats(); !reflect.DeepEqual(act, exp) {
+		t.Fatalf("Unexpected user stats:\nExp: %v\nGot: %v\n", exp, act)
+	}
+	expUsers := map[uint64]string{1: "alice"}
+	if act := srv.Cmgr.CommitUsers(); !reflect.DeepEqual(act, expUsers) {
+		t.Fatalf("Unexpected commit users:\nExp: %v\nGot: %v\n",
+			expUsers, act)
+	}
+
+	// ... and survive a restart
+	cmgr := NewCommitMgr(nil, srv.Ms)
+	if err := cmgr.LoadUserStats(srv.Ctx.Config.Runfile); err != nil {
+		t.Fatalf("Unable to load user stats: %s", err)
+	}
+	if act := cmgr.UserStats(); !reflect.DeepEqual(act, exp) {
+		t.Fatalf("Unexpected user stats after reload:\nExp: %v\nGot: %v\n",
+			exp, act)
+	}
+}
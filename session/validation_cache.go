@@ -0,0 +1,140 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+	"sync"
+	"time"
+
+	"github.com/danos/config/data"
+	"github.com/danos/config/schema"
+	"github.com/danos/config/union"
+	"github.com/danos/utils/exec"
+	"github.com/danos/utils/pathutil"
+)
+
+// Validation cache
+//
+// Commit validates the candidate in full, evaluating every must and when
+// expression, even when the user has just validated it.  On a large
+// configuration that is most of the time a commit takes.  A successful
+// validate is recorded, keyed by a hash of the session's candidate, and a
+// commit of the same candidate, against the same running configuration
+// and schema, within validationCacheLifetime, skips evaluating them again.
+// The configd:validate scripts are still run, as what they check may be
+// outside the configuration.  The entry is used at most once.  A debug
+// commit always validates in full, so its output is complete.
+
+var validationCacheLifetime = 5 * time.Minute
+
+type validationCache struct {
+	mu       sync.Mutex
+	key      string
+	schema   schema.ModelSet
+	commitId uint64
+	stored   time.Time
+}
+
+func hashString(h hash.Hash, s string) {
+	var l [binary.MaxVarintLen64]byte
+	h.Write(l[:binary.PutUvarint(l[:], uint64(len(s)))])
+	h.Write([]byte(s))
+}
+
+func hashTree(h hash.Hash, n *data.Node) {
+	hashString(h, n.Name())
+	h.Write([]byte{'{'})
+	for _, ch := range n.Children() {
+		hashTree(h, ch)
+	}
+	h.Write([]byte{'}'})
+}
+
+// validationKey identifies the merged candidate mcan of session sid.
+func validationKey(sid string, mcan *data.Node) string {
+	h := sha256.New()
+	hashString(h, sid)
+	hashTree(h, mcan)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// store records a successful validation.
+func (c *validationCache) store(
+	key string,
+	ms schema.ModelSet,
+	commitId uint64,
+) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.key, c.schema, c.commitId = key, ms, commitId
+	c.stored = time.Now()
+}
+
+// lookup returns true if a validation was stored for key, recently enough
+// and with running and the schema as they were then, and forgets it.
+func (c *validationCache) lookup(
+	key string,
+	ms schema.ModelSet,
+	commitId uint64,
+) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	hit := c.key != "" && c.key == key && c.schema == ms &&
+		c.commitId == commitId &&
+		time.Since(c.stored) < validationCacheLifetime
+	c.key, c.schema = "", nil
+	return hit
+}
+
+// validateScripts runs the configd:validate scripts of the candidate, as
+// validate does, without evaluating the schema's constraints.
+func (c *commitctx) validateScripts() ([]*exec.Output, []error, bool) {
+	var outs []*exec.Output
+	var errs []error
+	run := func(sch schema.Node, path []string) {
+		for _, script := range sch.ConfigdExt().Validate {
+			out, err := exec.Exec(exec.Env(c.sid, path, "validate", ""),
+				path, script)
+			if out != nil {
+				outs = append(outs, out)
+			}
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	var walk func(n union.Node, path []string)
+	walk = func(n union.Node, path []string) {
+		switch sch := n.GetSchema().(type) {
+		case schema.List:
+			for _, entry := range n.Children() {
+				epath := pathutil.CopyAppend(path, entry.Name())
+				run(sch, epath)
+				for _, ch := range entry.Children() {
+					walk(ch, pathutil.CopyAppend(epath, ch.Name()))
+				}
+			}
+			return
+		case schema.Leaf, schema.LeafList:
+			for _, val := range n.Children() {
+				run(sch, pathutil.CopyAppend(path, val.Name()))
+			}
+			return
+		}
+		if len(path) != 0 {
+			run(n.GetSchema(), path)
+		}
+		for _, ch := range n.Children() {
+			walk(ch, pathutil.CopyAppend(path, ch.Name()))
+		}
+	}
+	walk(union.NewNode(nil, c.candidate, c.schema, nil, 0), []string{})
+	return outs, errs, len(errs) == 0
+}
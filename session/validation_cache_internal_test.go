@@ -0,0 +1,58 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/danos/config/data"
+)
+
+func newTestTree(names ...string) *data.Node {
+	root := data.New("root")
+	for _, name := range names {
+		root.AddChild(data.New(name))
+	}
+	return root
+}
+
+// Names are length prefixed, so braces in a value can't make two trees
+// hash the same.
+func TestValidationKeyDistinguishesBraces(t *testing.T) {
+	one := validationKey("sid", newTestTree("x{}y"))
+	two := validationKey("sid", newTestTree("x", "y"))
+	if one == two {
+		t.Fatalf("Different trees have the same validation key")
+	}
+}
+
+func TestValidationCacheUsedOnce(t *testing.T) {
+	var c validationCache
+	c.store("key", nil, 1)
+	if !c.lookup("key", nil, 1) {
+		t.Fatalf("Stored validation not found")
+	}
+	if c.lookup("key", nil, 1) {
+		t.Fatalf("Stored validation used twice")
+	}
+}
+
+func TestValidationCacheExpires(t *testing.T) {
+	var c validationCache
+	c.store("key", nil, 1)
+	c.stored = time.Now().Add(-validationCacheLifetime)
+	if c.lookup("key", nil, 1) {
+		t.Fatalf("Expired validation used")
+	}
+}
+
+func TestValidationCacheRunningChanged(t *testing.T) {
+	var c validationCache
+	c.store("key", nil, 1)
+	if c.lookup("key", nil, 2) {
+		t.Fatalf("Validation against an earlier running used")
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/danos/configd/session/sessiontest"
+)
+
+// setupValidationCount returns a schema whose validate script records each
+// run in the returned file.
+func setupValidationCount(t *testing.T) (string, string, func()) {
+	dir, err := ioutil.TempDir("", "configd-validation-cache")
+	if err != nil {
+		t.Fatalf("Unable to create dir: %s", err)
+	}
+	file := filepath.Join(dir, "runs")
+	schema := fmt.Sprintf(`
+container top {
+	configd:validate "echo run >> %s";
+	leaf value {
+		type string;
+	}
+}`, file)
+	return schema, file, func() { os.RemoveAll(dir) }
+}
+
+func checkValidationRuns(t *testing.T, file string, exp int) {
+	t.Helper()
+	buf, _ := ioutil.ReadFile(file)
+	if runs := strings.Count(string(buf), "run"); runs != exp {
+		t.Fatalf("Expected %d validation runs, got %d", exp, runs)
+	}
+}
+
+// The validate scripts are run by the commit even when the validation is
+// reused, as what they check may have changed.
+func TestCommitAfterValidateRunsScripts(t *testing.T) {
+	schema, file, cleanup := setupValidationCount(t)
+	defer cleanup()
+	srv, sess := TstStartup(t, schema, emptyconfig)
+	defer sess.Kill()
+
+	ValidateSet(t, sess, srv.Ctx, []string{"top", "value", "foo"}, false)
+	if _, errs, ok := sess.Validate(srv.Ctx); !ok {
+		t.Fatalf("Validation failed: %v", errs)
+	}
+	checkValidationRuns(t, file, 1)
+	ValidateCommit(t, sess, srv.Ctx, true)
+	checkValidationRuns(t, file, 2)
+}
+
+func TestCommitAfterChangeRevalidates(t *testing.T) {
+	schema, file, cleanup := setupValidationCount(t)
+	defer cleanup()
+	srv, sess := TstStartup(t, schema, emptyconfig)
+	defer sess.Kill()
+
+	ValidateSet(t, sess, srv.Ctx, []string{"top", "value", "foo"}, false)
+	if _, errs, ok := sess.Validate(srv.Ctx); !ok {
+		t.Fatalf("Validation failed: %v", errs)
+	}
+	ValidateSet(t, sess, srv.Ctx, []string{"top", "value", "bar"}, false)
+	ValidateCommit(t, sess, srv.Ctx, true)
+	checkValidationRuns(t, file, 2)
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2021, AT&T Intellectual Property. All rights reserved.
+//
+// SPDX-License-Identifier: LGPL-2.1-only
+
+package session
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/danos/mgmterror"
+)
+
+// Session variables
+//
+// Bulk edits often repeat the same address, prefix or name in many paths,
+// and a typo in one of them goes unnoticed.  A session may instead define
+// variables, and reference them as $NAME or ${NAME} in the paths and
+// values it sets or deletes, which the dispatcher expands before the path
+// is authorized.  Only references to defined variables are expanded, so
+// values which happen to contain a $, such as hashed passwords, are left
+// alone.  Variables last as long as the session.
+
+var (
+	variableName      = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	variableReference = regexp.MustCompile(
+		`\$(?:\{([A-Za-z_][A-Za-z0-9_]*)\}|([A-Za-z_][A-Za-z0-9_]*))`)
+)
+
+type sessionVars struct {
+	mu   sync.Mutex
+	vars map[string]string
+}
+
+func (v *sessionVars) set(name, value string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.vars == nil {
+		v.vars = make(map[string]string)
+	}
+	v.vars[name] = value
+}
+
+func (v *sessionVars) unset(name string) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if _, ok := v.vars[name]; !ok {
+		return false
+	}
+	delete(v.vars, name)
+	return true
+}
+
+func (v *sessionVars) clear() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.vars = nil
+}
+
+func (v *sessionVars) copy() map[string]string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	out := make(map[string]string, len(v.vars))
+	for name, value := range v.vars {
+		out[name] = value
+	}
+	return out
+}
+
+func (v *sessionVars) expand(path []string) []string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if len(v.vars) == 0 {
+		return path
+	}
+	out := make([]string, len(path))
+	for i, elem := range path {
+		out[i] = variableReference.ReplaceAllStringFunc(elem,
+			func(ref string) string {
+				m := variableReference.FindStringSubmatch(ref)
+				name := m[1] + m[2]
+				if value, ok := v.vars[name]; ok {
+					return value
+				}
+				return ref
+			})
+	}
+	return out
+}
+
+// SetVariable defines the variable name, which must start with a letter
+// or underscore, followed by letters, digits and underscores.
+func (s *Session) SetVariable(name, value string) error {
+	if !variableName.MatchString(name) {
+		err := mgmterror.NewInvalidValueApplicationError()
+		err.Message = fmt.Sprintf("Invalid variable name %s", name)
+		return err
+	}
+	s.s.vars.set(name, value)
+	return nil
+}
+
+// UnsetVariable returns false if the variable wasn't defined.
+func (s *Session) UnsetVariable(name string) bool {
+	return s.s.vars.unset(name)
+}
+
+func (s *Session) ClearVariables() {
+	s.s.vars.clear()
+}
+
+// Variables returns a copy of the session's variables.
+func (s *Session) Variables() map[string]string {
+	return s.s.vars.copy()
+}
+
+// ExpandVariables returns path with references to the session's variables
+// replaced by their values.
+func (s *Session) ExpandVariables(path []string) []string {
+	return s.s.vars.expand(path)
+}
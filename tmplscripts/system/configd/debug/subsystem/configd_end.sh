@@ -0,0 +1,7 @@
+#!/opt/vyatta/bin/cliexec
+if [ "$COMMIT_ACTION" = DELETE ]; then
+	level=default
+else
+	level=$VAR(level/@)
+fi
+/usr/bin/cfgdbg -log-type $VAR(@) -log-level $level >/dev/null